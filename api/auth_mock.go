@@ -12,12 +12,14 @@ import (
 const (
 	CtxKeyUserID    = "user_id"
 	CtxKeyUserEmail = "user_email"
+	CtxKeyUserName  = "user_name"
 )
 
 // AuthUserInfo 鉴权后的用户信息
 type AuthUserInfo struct {
 	UserID string `json:"user_id"`
 	Email  string `json:"email"`
+	Name   string `json:"name"` // 可能为空
 }
 
 // AuthService 鉴权服务接口
@@ -78,6 +80,7 @@ func BetaAuthMiddleware() gin.HandlerFunc {
 
 		c.Set(CtxKeyUserID, userInfo.UserID)
 		c.Set(CtxKeyUserEmail, userInfo.Email)
+		c.Set(CtxKeyUserName, userInfo.Name)
 		c.Next()
 	}
 }
@@ -91,3 +94,8 @@ func GetUserID(c *gin.Context) string {
 func GetUserEmail(c *gin.Context) string {
 	return c.GetString(CtxKeyUserEmail)
 }
+
+// GetUserName 从上下文获取用户名，可能为空
+func GetUserName(c *gin.Context) string {
+	return c.GetString(CtxKeyUserName)
+}
@@ -34,6 +34,7 @@ type VerifyUserResponse struct {
 	Msg    string `json:"msg"`
 	Data   *struct {
 		UserID   string        `json:"user_id"`
+		Nickname string        `json:"nickname"`
 		Accounts []AccountInfo `json:"accounts"`
 	} `json:"data"`
 }
@@ -92,6 +93,7 @@ func (s *PlaudAuthService) ValidateToken(ctx context.Context, token string) (*Au
 	return &AuthUserInfo{
 		UserID: result.Data.UserID,
 		Email:  email,
+		Name:   result.Data.Nickname,
 	}, nil
 }
 
@@ -0,0 +1,163 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"plaud-emails/data/dto"
+	"plaud-emails/service/mindadvisor"
+
+	"github.com/gin-gonic/gin"
+)
+
+// BlocklistHandler local_part 屏蔽规则管理处理器，仅挂载在内部路由
+type BlocklistHandler struct {
+	svc *mindadvisor.MindAdvisorService
+}
+
+// NewBlocklistHandler 创建 BlocklistHandler
+func NewBlocklistHandler(svc *mindadvisor.MindAdvisorService) *BlocklistHandler {
+	return &BlocklistHandler{svc: svc}
+}
+
+// BlocklistRuleReq 创建或更新屏蔽规则请求
+type BlocklistRuleReq struct {
+	Match    string `json:"match" binding:"required"`
+	Pattern  string `json:"pattern" binding:"required"`
+	Category string `json:"category" binding:"required"`
+	Note     string `json:"note"`
+}
+
+func (r *BlocklistRuleReq) input() *mindadvisor.BlocklistRuleInput {
+	return &mindadvisor.BlocklistRuleInput{
+		Match:    r.Match,
+		Pattern:  r.Pattern,
+		Category: r.Category,
+		Note:     r.Note,
+	}
+}
+
+// newBlocklistRuleDTO 转换生效中的规则，来自配置的规则没有 id
+func newBlocklistRuleDTO(rule *mindadvisor.BlockRule) *dto.BlocklistRule {
+	source := "admin"
+	if rule.ID == 0 {
+		source = "config"
+	}
+	return &dto.BlocklistRule{
+		ID:       rule.ID,
+		Source:   source,
+		Match:    rule.Match,
+		Pattern:  rule.Pattern,
+		Category: rule.Category,
+	}
+}
+
+// ListRules 查询全部屏蔽规则，包括来自配置的规则
+// GET /v1/mailbox/blocklist/rules
+func (h *BlocklistHandler) ListRules(c *gin.Context) {
+	rules, err := h.svc.ListBlocklistRules(c.Request.Context())
+	if err != nil {
+		FailResponse(c, http.StatusInternalServerError, "list blocklist rules failed")
+		return
+	}
+
+	configRules := h.svc.Blocklist().ConfigRules()
+	resp := &dto.BlocklistRuleList{Rules: make([]*dto.BlocklistRule, 0, len(configRules)+len(rules))}
+	for _, rule := range configRules {
+		resp.Rules = append(resp.Rules, newBlocklistRuleDTO(rule))
+	}
+	for _, rule := range rules {
+		resp.Rules = append(resp.Rules, dto.NewBlocklistRuleFromModel(rule))
+	}
+	SuccessResponse(c, resp)
+}
+
+// CreateRule 创建屏蔽规则
+// POST /v1/mailbox/blocklist/rules
+func (h *BlocklistHandler) CreateRule(c *gin.Context) {
+	var req BlocklistRuleReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		FailResponse(c, http.StatusBadRequest, "invalid request: "+err.Error())
+		return
+	}
+
+	rule, err := h.svc.CreateBlocklistRule(c.Request.Context(), req.input())
+	if err != nil {
+		h.failRule(c, err, "create blocklist rule failed")
+		return
+	}
+
+	SuccessResponse(c, dto.NewBlocklistRuleFromModel(rule))
+}
+
+// UpdateRule 更新屏蔽规则
+// PUT /v1/mailbox/blocklist/rules/:id
+func (h *BlocklistHandler) UpdateRule(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil || id == 0 {
+		FailResponse(c, http.StatusBadRequest, "invalid rule id")
+		return
+	}
+	var req BlocklistRuleReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		FailResponse(c, http.StatusBadRequest, "invalid request: "+err.Error())
+		return
+	}
+
+	rule, err := h.svc.UpdateBlocklistRule(c.Request.Context(), id, req.input())
+	if err != nil {
+		h.failRule(c, err, "update blocklist rule failed")
+		return
+	}
+
+	SuccessResponse(c, dto.NewBlocklistRuleFromModel(rule))
+}
+
+// DeleteRule 删除屏蔽规则
+// DELETE /v1/mailbox/blocklist/rules/:id
+func (h *BlocklistHandler) DeleteRule(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil || id == 0 {
+		FailResponse(c, http.StatusBadRequest, "invalid rule id")
+		return
+	}
+
+	if err := h.svc.DeleteBlocklistRule(c.Request.Context(), id); err != nil {
+		h.failRule(c, err, "delete blocklist rule failed")
+		return
+	}
+
+	SuccessResponse(c, nil)
+}
+
+// Check 检查 local_part 命中的屏蔽规则，便于排查用户反馈
+// GET /v1/mailbox/blocklist/check?local_part=xxx
+func (h *BlocklistHandler) Check(c *gin.Context) {
+	localPart := c.Query("local_part")
+	if localPart == "" {
+		FailResponse(c, http.StatusBadRequest, "local_part is required")
+		return
+	}
+
+	resp := &dto.BlocklistCheck{LocalPart: localPart}
+	if rule := h.svc.Blocklist().Match(localPart); rule != nil {
+		resp.Blocked = true
+		resp.Rule = newBlocklistRuleDTO(rule)
+	}
+	SuccessResponse(c, resp)
+}
+
+// failRule 将屏蔽规则相关错误映射为响应
+func (h *BlocklistHandler) failRule(c *gin.Context, err error, msg string) {
+	switch {
+	case errors.Is(err, mindadvisor.ErrInvalidBlocklistRule):
+		FailResponse(c, http.StatusBadRequest, err.Error())
+	case errors.Is(err, mindadvisor.ErrBlocklistRuleExists):
+		FailResponse(c, http.StatusConflict, err.Error())
+	case errors.Is(err, mindadvisor.ErrBlocklistRuleNotFound):
+		FailResponse(c, http.StatusNotFound, err.Error())
+	default:
+		FailResponse(c, http.StatusInternalServerError, msg)
+	}
+}
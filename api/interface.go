@@ -2,7 +2,13 @@ package api
 
 import (
 	appconfig "plaud-emails/pkg/config"
+	"plaud-emails/service/linkedemail"
+	"plaud-emails/service/mailsync"
+	"plaud-emails/service/message"
 	"plaud-emails/service/mindadvisor"
+	"plaud-emails/service/retention"
+	"plaud-emails/service/search"
+	"plaud-emails/service/thread"
 	usersvc "plaud-emails/service/user"
 
 	scaffoldconfig "github.com/Plaud-AI/plaud-go-scaffold/pkg/config"
@@ -19,6 +25,12 @@ type Services interface {
 	GetDBClient() *dbpkg.Client
 	GetUserService() *usersvc.UserService
 	GetMindAdvisorService() *mindadvisor.MindAdvisorService
+	GetMessageService() *message.MessageService
+	GetLinkedEmailService() *linkedemail.LinkedEmailService
+	GetMailSyncScheduler() *mailsync.Scheduler
+	GetRetentionService() *retention.RetentionService
+	GetThreadService() *thread.ThreadService
+	GetSearchService() *search.SearchService
 	GetJwtAuther() *middleware.JWTAuthMiddleware
 	GetServiceRegistry() *etcd.ServiceRegistry
 }
@@ -0,0 +1,114 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"plaud-emails/data/dto"
+	"plaud-emails/service/message"
+
+	"github.com/gin-gonic/gin"
+)
+
+// LabelHandler 邮件标签处理器
+type LabelHandler struct {
+	svc *message.MessageService
+}
+
+// NewLabelHandler 创建 LabelHandler
+func NewLabelHandler(svc *message.MessageService) *LabelHandler {
+	return &LabelHandler{svc: svc}
+}
+
+// ListLabels 查询系统标签与当前用户的自定义标签
+// GET /v1/myplaud/labels
+func (h *LabelHandler) ListLabels(c *gin.Context) {
+	labels, err := h.svc.ListLabels(c.Request.Context(), GetUserID(c))
+	if err != nil {
+		FailResponse(c, http.StatusInternalServerError, "list labels failed")
+		return
+	}
+	SuccessResponse(c, dto.NewLabelList(labels))
+}
+
+// LabelReq 创建或重命名标签请求
+type LabelReq struct {
+	Name string `json:"name" binding:"required"`
+}
+
+// CreateLabel 创建自定义标签
+// POST /v1/myplaud/labels
+func (h *LabelHandler) CreateLabel(c *gin.Context) {
+	var req LabelReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		FailResponse(c, http.StatusBadRequest, "invalid request: "+err.Error())
+		return
+	}
+
+	label, err := h.svc.CreateLabel(c.Request.Context(), GetUserID(c), req.Name)
+	if err != nil {
+		h.fail(c, err, "create label failed")
+		return
+	}
+	SuccessResponse(c, dto.NewLabelFromModel(label))
+}
+
+// RenameLabel 重命名自定义标签
+// PATCH /v1/myplaud/labels/:id
+func (h *LabelHandler) RenameLabel(c *gin.Context) {
+	id, ok := parseLabelID(c)
+	if !ok {
+		return
+	}
+	var req LabelReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		FailResponse(c, http.StatusBadRequest, "invalid request: "+err.Error())
+		return
+	}
+
+	label, err := h.svc.RenameLabel(c.Request.Context(), GetUserID(c), id, req.Name)
+	if err != nil {
+		h.fail(c, err, "rename label failed")
+		return
+	}
+	SuccessResponse(c, dto.NewLabelFromModel(label))
+}
+
+// DeleteLabel 删除自定义标签，邮件上的该标签一并移除
+// DELETE /v1/myplaud/labels/:id
+func (h *LabelHandler) DeleteLabel(c *gin.Context) {
+	id, ok := parseLabelID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.svc.DeleteLabel(c.Request.Context(), GetUserID(c), id); err != nil {
+		h.fail(c, err, "delete label failed")
+		return
+	}
+	SuccessResponse(c, nil)
+}
+
+// fail 将标签相关错误映射为响应
+func (h *LabelHandler) fail(c *gin.Context, err error, msg string) {
+	switch {
+	case errors.Is(err, message.ErrInvalidLabelName):
+		FailResponse(c, http.StatusBadRequest, err.Error())
+	case errors.Is(err, message.ErrLabelNotFound):
+		FailResponse(c, http.StatusNotFound, err.Error())
+	case errors.Is(err, message.ErrLabelExists), errors.Is(err, message.ErrTooManyLabels):
+		FailResponse(c, http.StatusConflict, err.Error())
+	default:
+		FailResponse(c, http.StatusInternalServerError, msg)
+	}
+}
+
+func parseLabelID(c *gin.Context) (uint64, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil || id == 0 {
+		FailResponse(c, http.StatusBadRequest, "invalid label id")
+		return 0, false
+	}
+	return id, true
+}
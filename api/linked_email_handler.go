@@ -0,0 +1,241 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"plaud-emails/data/dto"
+	"plaud-emails/service/linkedemail"
+
+	"github.com/Plaud-AI/plaud-go-scaffold/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+)
+
+// LinkedEmailHandler 绑定邮箱处理器
+type LinkedEmailHandler struct {
+	svc *linkedemail.LinkedEmailService
+}
+
+// NewLinkedEmailHandler 创建 LinkedEmailHandler
+func NewLinkedEmailHandler(svc *linkedemail.LinkedEmailService) *LinkedEmailHandler {
+	return &LinkedEmailHandler{svc: svc}
+}
+
+// CreateLinkedEmailReq 登记绑定邮箱请求
+type CreateLinkedEmailReq struct {
+	Email string `json:"email" binding:"required"`
+}
+
+// CreateLinkedEmail 登记通过自动转发接入的外部邮箱，返回验证令牌
+// POST /v1/myplaud/linked-emails
+func (h *LinkedEmailHandler) CreateLinkedEmail(c *gin.Context) {
+	var req CreateLinkedEmailReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		FailResponse(c, http.StatusBadRequest, "invalid request: "+err.Error())
+		return
+	}
+
+	link, err := h.svc.CreateForwarding(c.Request.Context(), GetUserID(c), req.Email)
+	if err != nil {
+		switch {
+		case errors.Is(err, linkedemail.ErrInvalidEmail),
+			errors.Is(err, linkedemail.ErrDedicatedEmail):
+			FailResponse(c, http.StatusBadRequest, err.Error())
+		case errors.Is(err, linkedemail.ErrMailboxNotCreated):
+			FailResponse(c, http.StatusPreconditionFailed, err.Error())
+		case errors.Is(err, linkedemail.ErrLinkedEmailExists),
+			errors.Is(err, linkedemail.ErrTooManyLinkedEmails):
+			FailResponse(c, http.StatusConflict, err.Error())
+		default:
+			FailResponse(c, http.StatusInternalServerError, "create linked email failed")
+		}
+		return
+	}
+
+	SuccessResponse(c, dto.NewLinkedEmailFromModel(link))
+}
+
+// CreateIMAPLinkedEmailReq 绑定 IMAP 邮箱请求
+type CreateIMAPLinkedEmailReq struct {
+	Email    string `json:"email" binding:"required"`
+	Host     string `json:"host" binding:"required"`
+	Port     int    `json:"port"`
+	TLS      *bool  `json:"tls"`
+	Username string `json:"username"`
+	Password string `json:"password" binding:"required"`
+	Mailbox  string `json:"mailbox"`
+}
+
+// CreateIMAPLinkedEmail 绑定 IMAP 邮箱，登录校验通过后开始同步
+// POST /v1/myplaud/linked-emails/imap
+func (h *LinkedEmailHandler) CreateIMAPLinkedEmail(c *gin.Context) {
+	var req CreateIMAPLinkedEmailReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		FailResponse(c, http.StatusBadRequest, "invalid request: "+err.Error())
+		return
+	}
+	// 默认使用 993 端口的 IMAPS
+	in := &linkedemail.IMAPInput{
+		Email:    req.Email,
+		Host:     req.Host,
+		Port:     req.Port,
+		TLS:      req.TLS == nil || *req.TLS,
+		Username: req.Username,
+		Password: req.Password,
+		Mailbox:  req.Mailbox,
+	}
+	if in.Port == 0 {
+		in.Port = 993
+	}
+
+	link, err := h.svc.CreateIMAP(c.Request.Context(), GetUserID(c), in)
+	if err != nil {
+		switch {
+		case errors.Is(err, linkedemail.ErrInvalidEmail),
+			errors.Is(err, linkedemail.ErrDedicatedEmail),
+			errors.Is(err, linkedemail.ErrInvalidIMAPAccount),
+			errors.Is(err, linkedemail.ErrIMAPAuthFailed),
+			errors.Is(err, linkedemail.ErrIMAPUnreachable),
+			errors.Is(err, linkedemail.ErrIMAPInsecure):
+			FailResponse(c, http.StatusBadRequest, err.Error())
+		case errors.Is(err, linkedemail.ErrMailboxNotCreated):
+			FailResponse(c, http.StatusPreconditionFailed, err.Error())
+		case errors.Is(err, linkedemail.ErrLinkedEmailExists),
+			errors.Is(err, linkedemail.ErrTooManyLinkedEmails):
+			FailResponse(c, http.StatusConflict, err.Error())
+		case errors.Is(err, linkedemail.ErrCredentialStoreDisabled):
+			FailResponse(c, http.StatusServiceUnavailable, err.Error())
+		default:
+			FailResponse(c, http.StatusInternalServerError, "create linked email failed")
+		}
+		return
+	}
+
+	SuccessResponse(c, dto.NewLinkedEmailFromModel(link))
+}
+
+// AuthorizeOAuthResp OAuth 授权地址响应
+type AuthorizeOAuthResp struct {
+	AuthURL string `json:"auth_url"`
+}
+
+// AuthorizeOAuth 生成外部邮箱服务商的 OAuth 授权地址
+// GET /v1/myplaud/linked-emails/oauth/:source/authorize
+func (h *LinkedEmailHandler) AuthorizeOAuth(c *gin.Context) {
+	authURL, err := h.svc.AuthorizeURL(c.Request.Context(), GetUserID(c), c.Param("source"))
+	if err != nil {
+		switch {
+		case errors.Is(err, linkedemail.ErrOAuthProviderDisabled):
+			FailResponse(c, http.StatusNotFound, err.Error())
+		case errors.Is(err, linkedemail.ErrMailboxNotCreated):
+			FailResponse(c, http.StatusPreconditionFailed, err.Error())
+		default:
+			FailResponse(c, http.StatusInternalServerError, "authorize linked email failed")
+		}
+		return
+	}
+
+	SuccessResponse(c, &AuthorizeOAuthResp{AuthURL: authURL})
+}
+
+// OAuthCallback 服务商授权回调，通过 state 关联发起授权的用户并完成绑定
+// GET /v1/myplaud/oauth/:source/callback
+func (h *LinkedEmailHandler) OAuthCallback(c *gin.Context) {
+	if errCode := c.Query("error"); errCode != "" {
+		FailResponse(c, http.StatusBadRequest, "authorization denied: "+errCode)
+		return
+	}
+
+	link, err := h.svc.CompleteOAuth(c.Request.Context(), c.Param("source"), c.Query("state"), c.Query("code"))
+	if err != nil {
+		switch {
+		case errors.Is(err, linkedemail.ErrOAuthProviderDisabled):
+			FailResponse(c, http.StatusNotFound, err.Error())
+		case errors.Is(err, linkedemail.ErrInvalidOAuthState),
+			errors.Is(err, linkedemail.ErrOAuthExchangeFailed),
+			errors.Is(err, linkedemail.ErrInvalidEmail),
+			errors.Is(err, linkedemail.ErrDedicatedEmail):
+			FailResponse(c, http.StatusBadRequest, err.Error())
+		case errors.Is(err, linkedemail.ErrMailboxNotCreated):
+			FailResponse(c, http.StatusPreconditionFailed, err.Error())
+		case errors.Is(err, linkedemail.ErrLinkedEmailExists),
+			errors.Is(err, linkedemail.ErrTooManyLinkedEmails):
+			FailResponse(c, http.StatusConflict, err.Error())
+		case errors.Is(err, linkedemail.ErrCredentialStoreDisabled):
+			FailResponse(c, http.StatusServiceUnavailable, err.Error())
+		default:
+			FailResponse(c, http.StatusInternalServerError, "complete linked email authorization failed")
+		}
+		return
+	}
+
+	SuccessResponse(c, dto.NewLinkedEmailFromModel(link))
+}
+
+// ListLinkedEmails 查询当前用户的绑定邮箱
+// GET /v1/myplaud/linked-emails
+func (h *LinkedEmailHandler) ListLinkedEmails(c *gin.Context) {
+	links, err := h.svc.List(c.Request.Context(), GetUserID(c))
+	if err != nil {
+		FailResponse(c, http.StatusInternalServerError, "list linked emails failed")
+		return
+	}
+
+	resp := &dto.LinkedEmailList{LinkedEmails: make([]*dto.LinkedEmail, 0, len(links))}
+	for _, link := range links {
+		resp.LinkedEmails = append(resp.LinkedEmails, dto.NewLinkedEmailFromModel(link))
+	}
+	SuccessResponse(c, resp)
+}
+
+// GetSyncHistory 查询当前用户绑定邮箱的同步状态迁移记录
+// GET /v1/myplaud/linked-email/:id/sync-history?cursor=xxx&limit=50
+// 也可通过 /v1/myplaud/linked-emails/:id/sync-history 访问
+func (h *LinkedEmailHandler) GetSyncHistory(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil || id == 0 {
+		FailResponse(c, http.StatusBadRequest, "invalid linked email id")
+		return
+	}
+	cursor, limit, ok := parsePage(c, defaultSyncHistoryLimit, maxSyncHistoryLimit)
+	if !ok {
+		FailResponse(c, http.StatusBadRequest, "invalid cursor")
+		return
+	}
+
+	events, err := h.svc.SyncHistory(c.Request.Context(), GetUserID(c), id, cursor, limit)
+	if err != nil {
+		if errors.Is(err, linkedemail.ErrLinkedEmailNotFound) {
+			FailResponse(c, http.StatusNotFound, "linked email not found")
+			return
+		}
+		FailResponse(c, http.StatusInternalServerError, "list sync history failed")
+		return
+	}
+
+	SuccessResponse(c, dto.NewLinkedEmailSyncHistory(events, limit))
+}
+
+// DeleteLinkedEmail 解除绑定
+// DELETE /v1/myplaud/linked-emails/:id
+func (h *LinkedEmailHandler) DeleteLinkedEmail(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil || id == 0 {
+		FailResponse(c, http.StatusBadRequest, "invalid linked email id")
+		return
+	}
+
+	if err := h.svc.Delete(c.Request.Context(), GetUserID(c), id); err != nil {
+		if errors.Is(err, linkedemail.ErrLinkedEmailNotFound) {
+			FailResponse(c, http.StatusNotFound, "linked email not found")
+			return
+		}
+		logger.ErrorfCtx(c.Request.Context(), "delete linked email %d error: %v", id, err)
+		FailResponse(c, http.StatusInternalServerError, "delete linked email failed")
+		return
+	}
+
+	SuccessResponse(c, nil)
+}
@@ -0,0 +1,138 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"plaud-emails/data/dto"
+	datamodel "plaud-emails/data/model"
+	"plaud-emails/service/linkedemail"
+	"plaud-emails/service/mailsync"
+
+	"github.com/Plaud-AI/plaud-go-scaffold/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultScheduleLimit    = 100
+	maxScheduleLimit        = 500
+	defaultSyncHistoryLimit = 50
+	maxSyncHistoryLimit     = 200
+)
+
+// MailSyncHandler 外部邮箱同步调度处理器，仅挂载在内部路由
+type MailSyncHandler struct {
+	scheduler   *mailsync.Scheduler
+	linkedEmail *linkedemail.LinkedEmailService
+}
+
+// NewMailSyncHandler 创建 MailSyncHandler
+func NewMailSyncHandler(scheduler *mailsync.Scheduler, linkedEmail *linkedemail.LinkedEmailService) *MailSyncHandler {
+	return &MailSyncHandler{scheduler: scheduler, linkedEmail: linkedEmail}
+}
+
+// parsePage 解析 cursor 与 limit 分页参数，cursor 非法时返回 false
+func parsePage(c *gin.Context, defaultLimit, maxLimit int) (uint64, int, bool) {
+	var cursor uint64
+	if v := c.Query("cursor"); v != "" {
+		parsed, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			return 0, 0, false
+		}
+		cursor = parsed
+	}
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	if limit <= 0 {
+		limit = defaultLimit
+	}
+	if limit > maxLimit {
+		limit = maxLimit
+	}
+	return cursor, limit, true
+}
+
+// GetSchedule 查询绑定邮箱的调度与租约状态
+// GET /v1/mail-sync/schedule?status=backoff&cursor=xxx&limit=100
+func (h *MailSyncHandler) GetSchedule(c *gin.Context) {
+	cursor, limit, ok := parsePage(c, defaultScheduleLimit, maxScheduleLimit)
+	if !ok {
+		FailResponse(c, http.StatusBadRequest, "invalid cursor")
+		return
+	}
+
+	entries, err := h.scheduler.ListSchedule(c.Request.Context(), datamodel.LinkedEmailSyncStatus(c.Query("status")), cursor, limit)
+	if err != nil {
+		logger.ErrorfCtx(c.Request.Context(), "list sync schedule error: %v", err)
+		FailResponse(c, http.StatusInternalServerError, "list sync schedule failed")
+		return
+	}
+
+	resp := &dto.SyncSchedule{
+		Owner:   h.scheduler.Owner(),
+		Enabled: h.scheduler.Enabled(),
+		Sources: h.scheduler.Sources(),
+		Running: h.scheduler.Running(),
+		Entries: make([]*dto.SyncScheduleEntry, 0, len(entries)),
+	}
+	for _, e := range entries {
+		link := e.Link
+		entry := &dto.SyncScheduleEntry{
+			ID:           link.ID,
+			UserID:       link.UserID,
+			Email:        link.Email,
+			Source:       link.Source,
+			Active:       link.IsActive(),
+			SyncFailures: link.SyncFailures,
+			Running:      e.Running,
+		}
+		if link.SyncStatus != nil {
+			entry.SyncStatus = string(*link.SyncStatus)
+		}
+		if link.LastSyncAt != nil {
+			entry.LastSyncAt = link.LastSyncAt.UnixMilli()
+		}
+		if link.NextSyncAt != nil {
+			entry.NextSyncAt = link.NextSyncAt.UnixMilli()
+		}
+		if link.Extra != nil {
+			entry.LastError = link.Extra.LastSyncError
+		}
+		if e.Lease != nil {
+			entry.Lease = &dto.SyncLease{Owner: e.Lease.Owner, TTLMs: e.Lease.TTL.Milliseconds()}
+		}
+		resp.Entries = append(resp.Entries, entry)
+	}
+	if len(entries) == limit {
+		resp.NextCursor = strconv.FormatUint(entries[len(entries)-1].Link.ID, 10)
+	}
+	SuccessResponse(c, resp)
+}
+
+// GetSyncHistory 查询任意绑定邮箱（含已解除绑定）的同步状态迁移记录
+// GET /v1/mail-sync/linked-emails/:id/sync-history?cursor=xxx&limit=50
+func (h *MailSyncHandler) GetSyncHistory(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil || id == 0 {
+		FailResponse(c, http.StatusBadRequest, "invalid linked email id")
+		return
+	}
+	cursor, limit, ok := parsePage(c, defaultSyncHistoryLimit, maxSyncHistoryLimit)
+	if !ok {
+		FailResponse(c, http.StatusBadRequest, "invalid cursor")
+		return
+	}
+
+	events, err := h.linkedEmail.SyncHistoryByID(c.Request.Context(), id, cursor, limit)
+	if err != nil {
+		if errors.Is(err, linkedemail.ErrLinkedEmailNotFound) {
+			FailResponse(c, http.StatusNotFound, "linked email not found")
+			return
+		}
+		FailResponse(c, http.StatusInternalServerError, "list sync history failed")
+		return
+	}
+
+	SuccessResponse(c, dto.NewLinkedEmailSyncHistory(events, limit))
+}
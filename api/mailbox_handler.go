@@ -1,17 +1,33 @@
 package api
 
 import (
+	"context"
 	"errors"
 	"net/http"
+	"strconv"
+	"time"
 
 	"plaud-emails/data/dto"
+	datamodel "plaud-emails/data/model"
 	"plaud-emails/service/mindadvisor"
 
 	"github.com/Plaud-AI/plaud-go-scaffold/pkg/logger"
+	"github.com/Plaud-AI/plaud-go-scaffold/pkg/rdb"
 
 	"github.com/gin-gonic/gin"
 )
 
+// availabilityRateLimit local_part 可用性检查的限流窗口，防止枚举已注册的地址
+var availabilityRateLimit = rdb.NewCacheConfig("plaud-emails:rate-limit:mailbox-availability", time.Minute, true)
+
+// maxAvailabilityChecks 每个用户每个窗口内最多的可用性检查次数
+const maxAvailabilityChecks = 20
+
+const (
+	defaultMailboxAuditLimit = 50
+	maxMailboxAuditLimit     = 200
+)
+
 // MailboxHandler 邮箱处理器
 type MailboxHandler struct {
 	svc *mindadvisor.MindAdvisorService
@@ -22,10 +38,23 @@ func NewMailboxHandler(svc *mindadvisor.MindAdvisorService) *MailboxHandler {
 	return &MailboxHandler{svc: svc}
 }
 
+// newMailboxDTO 转换邮箱 DTO，国际化地址附带 ASCII 兜底地址
+func (h *MailboxHandler) newMailboxDTO(ctx context.Context, user *datamodel.MindAdvisorUser) *dto.Mailbox {
+	mailbox := dto.NewMailboxFromModel(user)
+	if mailbox == nil {
+		return nil
+	}
+	if fallback, err := h.svc.GetFallbackAddress(ctx, user.DedicatedEmail); err == nil {
+		mailbox.FallbackEmail = fallback
+	}
+	return mailbox
+}
+
 // CreateMailboxReq 创建邮箱请求
 type CreateMailboxReq struct {
-	LocalPart  string `json:"local_part" binding:"required"`
-	Salutation string `json:"salutation" binding:"required"`
+	LocalPart string `json:"local_part" binding:"required"`
+	// Salutation 称呼，为空表示不使用
+	Salutation string `json:"salutation"`
 }
 
 // CreateMailbox 创建专属邮箱
@@ -53,9 +82,16 @@ func (h *MailboxHandler) CreateMailbox(c *gin.Context) {
 		case errors.Is(err, mindadvisor.ErrMailboxConflict):
 			FailResponse(c, http.StatusConflict, "mailbox already created with different local_part")
 			return
+		case errors.Is(err, mindadvisor.ErrMailboxDeleted):
+			FailResponse(c, http.StatusGone, err.Error())
+			return
 		case errors.Is(err, mindadvisor.ErrEmailAlreadyExists):
 			FailResponse(c, http.StatusConflict, "email address already taken")
 			return
+		case errors.Is(err, mindadvisor.ErrAddressQuarantined),
+			errors.Is(err, mindadvisor.ErrAddressConfusable):
+			FailResponse(c, http.StatusConflict, err.Error())
+			return
 		case errors.Is(err, mindadvisor.ErrInvalidLocalPartLength),
 			errors.Is(err, mindadvisor.ErrInvalidLocalPartChars),
 			errors.Is(err, mindadvisor.ErrReservedWord),
@@ -68,8 +104,387 @@ func (h *MailboxHandler) CreateMailbox(c *gin.Context) {
 		}
 	}
 
-	mailbox := dto.NewMailboxFromModel(user)
-	SuccessResponse(c, mailbox)
+	SuccessResponse(c, h.newMailboxDTO(c.Request.Context(), user))
+}
+
+// AvailabilityResp local_part 可用性响应
+type AvailabilityResp struct {
+	LocalPart   string   `json:"local_part"`
+	Available   bool     `json:"available"`
+	Reason      string   `json:"reason,omitempty"` // length, chars, reserved, taken, quarantined
+	Suggestions []string `json:"suggestions,omitempty"`
+}
+
+// CheckAvailability 检查 local_part 是否可以申请，不可用时返回原因与推荐的候选名
+// GET /v1/myplaud/mailbox/availability?local_part=xxx
+func (h *MailboxHandler) CheckAvailability(c *gin.Context) {
+	localPart := c.Query("local_part")
+	if localPart == "" {
+		FailResponse(c, http.StatusBadRequest, "local_part is required")
+		return
+	}
+
+	result, err := h.svc.CheckAvailability(c.Request.Context(), GetUserID(c), localPart, &mindadvisor.SuggestionHint{
+		Name:  GetUserName(c),
+		Email: GetUserEmail(c),
+	})
+	if err != nil {
+		FailResponse(c, http.StatusInternalServerError, "check availability failed")
+		return
+	}
+
+	SuccessResponse(c, &AvailabilityResp{
+		LocalPart:   result.LocalPart,
+		Available:   result.Available,
+		Reason:      result.Reason,
+		Suggestions: result.Suggestions,
+	})
+}
+
+// RenameMailboxReq 更换邮箱 local_part 请求
+type RenameMailboxReq struct {
+	LocalPart string `json:"local_part" binding:"required"`
+}
+
+// RenameMailbox 更换专属邮箱的 local_part，旧地址在保留期内继续收信
+// POST /v1/myplaud/mailbox/rename
+func (h *MailboxHandler) RenameMailbox(c *gin.Context) {
+	var req RenameMailboxReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		FailResponse(c, http.StatusBadRequest, "invalid request: "+err.Error())
+		return
+	}
+
+	user, err := h.svc.RenameMailbox(c.Request.Context(), GetUserID(c), req.LocalPart)
+	if err != nil {
+		switch {
+		case errors.Is(err, mindadvisor.ErrMailboxNotCreated):
+			FailResponse(c, http.StatusPreconditionFailed, err.Error())
+		case errors.Is(err, mindadvisor.ErrMailboxDeleted):
+			FailResponse(c, http.StatusGone, err.Error())
+		case errors.Is(err, mindadvisor.ErrEmailAlreadyExists):
+			FailResponse(c, http.StatusConflict, "email address already taken")
+		case errors.Is(err, mindadvisor.ErrAddressQuarantined),
+			errors.Is(err, mindadvisor.ErrAddressConfusable):
+			FailResponse(c, http.StatusConflict, err.Error())
+		case errors.Is(err, mindadvisor.ErrRenameCooldown):
+			FailResponse(c, http.StatusTooManyRequests, err.Error())
+		case errors.Is(err, mindadvisor.ErrInvalidLocalPartLength),
+			errors.Is(err, mindadvisor.ErrInvalidLocalPartChars),
+			errors.Is(err, mindadvisor.ErrReservedWord):
+			FailResponse(c, http.StatusBadRequest, err.Error())
+		default:
+			FailResponse(c, http.StatusInternalServerError, "rename mailbox failed")
+		}
+		return
+	}
+
+	SuccessResponse(c, h.newMailboxDTO(c.Request.Context(), user))
+}
+
+// ListRetiredAddresses 查询当前用户更换过的旧地址及其保留期
+// GET /v1/myplaud/mailbox/retired-addresses
+func (h *MailboxHandler) ListRetiredAddresses(c *gin.Context) {
+	addrs, err := h.svc.ListRetiredAddresses(c.Request.Context(), GetUserID(c))
+	if err != nil {
+		FailResponse(c, http.StatusInternalServerError, "list retired addresses failed")
+		return
+	}
+
+	resp := &dto.RetiredAddressList{RetiredAddresses: make([]*dto.RetiredAddress, 0, len(addrs))}
+	for _, addr := range addrs {
+		resp.RetiredAddresses = append(resp.RetiredAddresses, dto.NewRetiredAddressFromModel(addr))
+	}
+	SuccessResponse(c, resp)
+}
+
+// AddAliasReq 添加别名请求
+type AddAliasReq struct {
+	LocalPart string `json:"local_part" binding:"required"`
+}
+
+// AddAlias 为当前用户添加别名邮箱
+// POST /v1/myplaud/mailbox/aliases
+func (h *MailboxHandler) AddAlias(c *gin.Context) {
+	var req AddAliasReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		FailResponse(c, http.StatusBadRequest, "invalid request: "+err.Error())
+		return
+	}
+
+	alias, err := h.svc.AddAlias(c.Request.Context(), GetUserID(c), req.LocalPart)
+	if err != nil {
+		switch {
+		case errors.Is(err, mindadvisor.ErrMailboxNotCreated):
+			FailResponse(c, http.StatusPreconditionFailed, err.Error())
+		case errors.Is(err, mindadvisor.ErrMailboxDeleted):
+			FailResponse(c, http.StatusGone, err.Error())
+		case errors.Is(err, mindadvisor.ErrEmailAlreadyExists):
+			FailResponse(c, http.StatusConflict, "email address already taken")
+		case errors.Is(err, mindadvisor.ErrAddressQuarantined),
+			errors.Is(err, mindadvisor.ErrAddressConfusable),
+			errors.Is(err, mindadvisor.ErrTooManyAliases):
+			FailResponse(c, http.StatusConflict, err.Error())
+		case errors.Is(err, mindadvisor.ErrInvalidLocalPartLength),
+			errors.Is(err, mindadvisor.ErrInvalidLocalPartChars),
+			errors.Is(err, mindadvisor.ErrReservedWord):
+			FailResponse(c, http.StatusBadRequest, err.Error())
+		default:
+			FailResponse(c, http.StatusInternalServerError, "add alias failed")
+		}
+		return
+	}
+
+	SuccessResponse(c, dto.NewAliasFromModel(alias))
+}
+
+// ListAliases 查询当前用户的别名邮箱
+// GET /v1/myplaud/mailbox/aliases
+func (h *MailboxHandler) ListAliases(c *gin.Context) {
+	aliases, err := h.svc.ListAliases(c.Request.Context(), GetUserID(c))
+	if err != nil {
+		FailResponse(c, http.StatusInternalServerError, "list aliases failed")
+		return
+	}
+
+	resp := &dto.AliasList{Aliases: make([]*dto.Alias, 0, len(aliases))}
+	for _, alias := range aliases {
+		resp.Aliases = append(resp.Aliases, dto.NewAliasFromModel(alias))
+	}
+	SuccessResponse(c, resp)
+}
+
+// RemoveAlias 删除别名邮箱
+// DELETE /v1/myplaud/mailbox/aliases/:id
+func (h *MailboxHandler) RemoveAlias(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil || id == 0 {
+		FailResponse(c, http.StatusBadRequest, "invalid alias id")
+		return
+	}
+
+	if err := h.svc.RemoveAlias(c.Request.Context(), GetUserID(c), id); err != nil {
+		if errors.Is(err, mindadvisor.ErrAliasNotFound) {
+			FailResponse(c, http.StatusNotFound, err.Error())
+			return
+		}
+		FailResponse(c, http.StatusInternalServerError, "remove alias failed")
+		return
+	}
+
+	SuccessResponse(c, nil)
+}
+
+// SetTagRuleReq 设置标签规则请求
+type SetTagRuleReq struct {
+	Label                string `json:"label"`
+	Mute                 bool   `json:"mute"`
+	ForwardLinkedEmailID uint64 `json:"forward_linked_email_id"`
+}
+
+// SetTagRule 创建或覆盖子地址标签规则
+// PUT /v1/myplaud/mailbox/tag-rules/:tag
+func (h *MailboxHandler) SetTagRule(c *gin.Context) {
+	var req SetTagRuleReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		FailResponse(c, http.StatusBadRequest, "invalid request: "+err.Error())
+		return
+	}
+
+	rule, err := h.svc.SetTagRule(c.Request.Context(), GetUserID(c), c.Param("tag"), &mindadvisor.TagRuleInput{
+		Label:                req.Label,
+		Mute:                 req.Mute,
+		ForwardLinkedEmailID: req.ForwardLinkedEmailID,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, mindadvisor.ErrInvalidTag),
+			errors.Is(err, mindadvisor.ErrInvalidLabel),
+			errors.Is(err, mindadvisor.ErrInvalidForwardTarget):
+			FailResponse(c, http.StatusBadRequest, err.Error())
+		default:
+			FailResponse(c, http.StatusInternalServerError, "set tag rule failed")
+		}
+		return
+	}
+
+	SuccessResponse(c, dto.NewTagRuleFromModel(rule))
+}
+
+// ListTagRules 查询当前用户的子地址标签规则
+// GET /v1/myplaud/mailbox/tag-rules
+func (h *MailboxHandler) ListTagRules(c *gin.Context) {
+	rules, err := h.svc.ListTagRules(c.Request.Context(), GetUserID(c))
+	if err != nil {
+		FailResponse(c, http.StatusInternalServerError, "list tag rules failed")
+		return
+	}
+
+	resp := &dto.TagRuleList{TagRules: make([]*dto.TagRule, 0, len(rules))}
+	for _, rule := range rules {
+		resp.TagRules = append(resp.TagRules, dto.NewTagRuleFromModel(rule))
+	}
+	SuccessResponse(c, resp)
+}
+
+// DeleteTagRule 删除子地址标签规则
+// DELETE /v1/myplaud/mailbox/tag-rules/:tag
+func (h *MailboxHandler) DeleteTagRule(c *gin.Context) {
+	if err := h.svc.DeleteTagRule(c.Request.Context(), GetUserID(c), c.Param("tag")); err != nil {
+		if errors.Is(err, mindadvisor.ErrTagRuleNotFound) {
+			FailResponse(c, http.StatusNotFound, err.Error())
+			return
+		}
+		FailResponse(c, http.StatusInternalServerError, "delete tag rule failed")
+		return
+	}
+
+	SuccessResponse(c, nil)
+}
+
+// MailboxLifecycleReq 停用、启用或删除邮箱请求，请求体可以为空
+type MailboxLifecycleReq struct {
+	Reason string `json:"reason"`
+}
+
+// DeactivateMailbox 停用专属邮箱，停用期间新邮件被退回
+// POST /v1/myplaud/mailbox/deactivate
+func (h *MailboxHandler) DeactivateMailbox(c *gin.Context) {
+	h.transitMailbox(c, h.svc.DeactivateMailbox)
+}
+
+// ReactivateMailbox 重新启用已停用的专属邮箱
+// POST /v1/myplaud/mailbox/reactivate
+func (h *MailboxHandler) ReactivateMailbox(c *gin.Context) {
+	h.transitMailbox(c, h.svc.ReactivateMailbox)
+}
+
+// DeleteMailbox 删除专属邮箱，保留期后清除全部数据
+// DELETE /v1/myplaud/mailbox
+func (h *MailboxHandler) DeleteMailbox(c *gin.Context) {
+	h.transitMailbox(c, h.svc.DeleteMailbox)
+}
+
+// transitMailbox 执行由用户本人发起的邮箱状态变更
+func (h *MailboxHandler) transitMailbox(c *gin.Context,
+	fn func(ctx context.Context, userID, actor, reason string) (*datamodel.MindAdvisorUser, error)) {
+	var req MailboxLifecycleReq
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			FailResponse(c, http.StatusBadRequest, "invalid request: "+err.Error())
+			return
+		}
+	}
+
+	userID := GetUserID(c)
+	user, err := fn(c.Request.Context(), userID, userID, req.Reason)
+	if err != nil {
+		switch {
+		case errors.Is(err, mindadvisor.ErrMailboxNotCreated):
+			FailResponse(c, http.StatusPreconditionFailed, err.Error())
+		case errors.Is(err, mindadvisor.ErrMailboxDeleted):
+			FailResponse(c, http.StatusGone, err.Error())
+		default:
+			FailResponse(c, http.StatusInternalServerError, "update mailbox status failed")
+		}
+		return
+	}
+
+	SuccessResponse(c, h.newMailboxDTO(c.Request.Context(), user))
+}
+
+// ListMailboxAudits 查询用户邮箱的状态变更审计记录，仅挂载在内部路由
+// GET /v1/mailbox/audits?user_id=xxx&cursor=xxx&limit=50
+func (h *MailboxHandler) ListMailboxAudits(c *gin.Context) {
+	userID := c.Query("user_id")
+	if userID == "" {
+		FailResponse(c, http.StatusBadRequest, "user_id is required")
+		return
+	}
+	cursor, limit, ok := parsePage(c, defaultMailboxAuditLimit, maxMailboxAuditLimit)
+	if !ok {
+		FailResponse(c, http.StatusBadRequest, "invalid cursor")
+		return
+	}
+
+	audits, err := h.svc.ListMailboxAudits(c.Request.Context(), userID, cursor, limit)
+	if err != nil {
+		FailResponse(c, http.StatusInternalServerError, "list mailbox audits failed")
+		return
+	}
+
+	SuccessResponse(c, dto.NewMailboxAuditList(audits, limit))
+}
+
+// UpdateSettingsReq 更新邮箱设置请求，未提供的字段保持不变，digest 与 auto_reply 整体替换
+type UpdateSettingsReq struct {
+	Salutation  *string             `json:"salutation"`
+	DisplayName *string             `json:"display_name"`
+	Language    *string             `json:"language"`
+	Timezone    *string             `json:"timezone"`
+	Digest      *dto.DigestSchedule `json:"digest"`
+	AutoReply   *dto.AutoReply      `json:"auto_reply"`
+}
+
+// GetSettings 获取邮箱设置
+// GET /v1/myplaud/mailbox/settings
+func (h *MailboxHandler) GetSettings(c *gin.Context) {
+	conf, err := h.svc.GetSettings(c.Request.Context(), GetUserID(c))
+	if err != nil {
+		if errors.Is(err, mindadvisor.ErrMailboxNotCreated) {
+			FailResponse(c, http.StatusPreconditionFailed, err.Error())
+			return
+		}
+		FailResponse(c, http.StatusInternalServerError, "get mailbox settings failed")
+		return
+	}
+
+	SuccessResponse(c, dto.NewMailboxSettingsFromModel(conf))
+}
+
+// UpdateSettings 部分更新邮箱设置
+// PATCH /v1/myplaud/mailbox/settings
+func (h *MailboxHandler) UpdateSettings(c *gin.Context) {
+	var req UpdateSettingsReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		FailResponse(c, http.StatusBadRequest, "invalid request: "+err.Error())
+		return
+	}
+
+	patch := &mindadvisor.SettingsPatch{
+		Salutation:  req.Salutation,
+		DisplayName: req.DisplayName,
+		Language:    req.Language,
+		Timezone:    req.Timezone,
+	}
+	if req.Digest != nil {
+		patch.Digest = &datamodel.DigestSchedule{Frequency: req.Digest.Frequency, Hour: req.Digest.Hour, Weekday: req.Digest.Weekday}
+	}
+	if req.AutoReply != nil {
+		patch.AutoReply = &datamodel.AutoReply{Enabled: req.AutoReply.Enabled, Subject: req.AutoReply.Subject, Body: req.AutoReply.Body}
+	}
+
+	conf, err := h.svc.UpdateSettings(c.Request.Context(), GetUserID(c), patch)
+	if err != nil {
+		switch {
+		case errors.Is(err, mindadvisor.ErrMailboxNotCreated):
+			FailResponse(c, http.StatusPreconditionFailed, err.Error())
+		case errors.Is(err, mindadvisor.ErrMailboxDeleted):
+			FailResponse(c, http.StatusGone, err.Error())
+		case errors.Is(err, mindadvisor.ErrInvalidSalutation),
+			errors.Is(err, mindadvisor.ErrInvalidDisplayName),
+			errors.Is(err, mindadvisor.ErrInvalidLanguage),
+			errors.Is(err, mindadvisor.ErrInvalidTimezone),
+			errors.Is(err, mindadvisor.ErrInvalidDigest),
+			errors.Is(err, mindadvisor.ErrInvalidAutoReply):
+			FailResponse(c, http.StatusBadRequest, err.Error())
+		default:
+			FailResponse(c, http.StatusInternalServerError, "update mailbox settings failed")
+		}
+		return
+	}
+
+	SuccessResponse(c, dto.NewMailboxSettingsFromModel(conf))
 }
 
 // GetMailbox 获取用户的专属邮箱
@@ -94,8 +509,7 @@ func (h *MailboxHandler) GetMailbox(c *gin.Context) {
 		return
 	}
 
-	mailbox := dto.NewMailboxFromModel(user)
-	SuccessResponse(c, mailbox)
+	SuccessResponse(c, h.newMailboxDTO(c.Request.Context(), user))
 }
 
 // GetUserByEmail 根据专属邮箱查询 user_id
@@ -129,7 +543,7 @@ type LinkedEmailStatusResp struct {
 }
 
 // GetLinkedEmailStatus 检查用户是否已绑定邮箱
-// GET /v1/myplaud/linked-email/status
+// GET /v1/myplaud/linked-emails/status
 func (h *MailboxHandler) GetLinkedEmailStatus(c *gin.Context) {
 	// 从中间件获取用户信息
 	userID := GetUserID(c)
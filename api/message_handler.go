@@ -0,0 +1,511 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"plaud-emails/dao"
+	"plaud-emails/data/dto"
+	datamodel "plaud-emails/data/model"
+	"plaud-emails/service/message"
+	"plaud-emails/service/mimeparse"
+	"plaud-emails/service/spam"
+
+	"github.com/Plaud-AI/plaud-go-scaffold/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MessageHandler 收件处理器
+type MessageHandler struct {
+	svc *message.MessageService
+}
+
+// NewMessageHandler 创建 MessageHandler
+func NewMessageHandler(svc *message.MessageService) *MessageHandler {
+	return &MessageHandler{svc: svc}
+}
+
+// ListMessages 分页查询当前用户的邮件，未指定 folder 时不包含垃圾箱与垃圾邮件
+// GET /v1/myplaud/messages?cursor=xxx&limit=20&tag=receipts&include_muted=true&folder=inbox&label_id=1
+func (h *MessageHandler) ListMessages(c *gin.Context) {
+	userID := GetUserID(c)
+
+	var cursor uint64
+	if v := c.Query("cursor"); v != "" {
+		parsed, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			FailResponse(c, http.StatusBadRequest, "invalid cursor")
+			return
+		}
+		cursor = parsed
+	}
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	filter := &dao.MessageFilter{Tag: strings.ToLower(c.Query("tag"))}
+	filter.IncludeMuted, _ = strconv.ParseBool(c.Query("include_muted"))
+	if folder := c.Query("folder"); folder != "" {
+		if !datamodel.IsFolder(folder) {
+			FailResponse(c, http.StatusBadRequest, message.ErrInvalidFolder.Error())
+			return
+		}
+		filter.Folder = folder
+	}
+	if v := c.Query("label_id"); v != "" {
+		labelID, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			FailResponse(c, http.StatusBadRequest, "invalid label_id")
+			return
+		}
+		filter.LabelID = labelID
+	}
+
+	msgs, next, err := h.svc.List(c.Request.Context(), userID, filter, cursor, limit)
+	if err != nil {
+		logger.ErrorfCtx(c.Request.Context(), "list messages error: %v", err)
+		FailResponse(c, http.StatusInternalServerError, "list messages failed")
+		return
+	}
+
+	labelIDs, err := h.svc.LabelIDs(c.Request.Context(), userID, msgs)
+	if err != nil {
+		FailResponse(c, http.StatusInternalServerError, "list messages failed")
+		return
+	}
+
+	resp := &dto.MessageList{Messages: dto.NewMessageSummaries(msgs, labelIDs)}
+	if next > 0 {
+		resp.NextCursor = strconv.FormatUint(next, 10)
+	}
+	SuccessResponse(c, resp)
+}
+
+// GetMessage 获取一封邮件的解析视图
+// GET /v1/myplaud/messages/:id
+func (h *MessageHandler) GetMessage(c *gin.Context) {
+	msg, ok := h.loadMessage(c)
+	if !ok {
+		return
+	}
+
+	parsed, err := h.svc.Parse(c.Request.Context(), msg)
+	if err != nil {
+		FailResponse(c, http.StatusInternalServerError, "get message failed")
+		return
+	}
+
+	labelIDs, err := h.svc.LabelIDs(c.Request.Context(), msg.UserID, []*datamodel.Message{msg})
+	if err != nil {
+		FailResponse(c, http.StatusInternalServerError, "get message failed")
+		return
+	}
+
+	attachments, err := h.svc.ListAttachments(c.Request.Context(), msg.UserID, msg.ID)
+	if err != nil {
+		FailResponse(c, http.StatusInternalServerError, "get message failed")
+		return
+	}
+
+	detail := newMessageDetailDTO(msg, parsed, attachments)
+	if ids := labelIDs[msg.ID]; len(ids) > 0 {
+		detail.LabelIDs = ids
+	}
+	SuccessResponse(c, detail)
+}
+
+// newMessageDetailDTO 由邮件记录与解析结果组装详情，附件已拆分时以附件记录为准
+func newMessageDetailDTO(m *datamodel.Message, parsed *mimeparse.Message, attachments []*datamodel.Attachment) *dto.MessageDetail {
+	detail := &dto.MessageDetail{
+		MessageSummary: dto.NewMessageSummaryFromModel(m),
+		Cc:             mimeparse.FormatAddressList(parsed.Cc),
+		ReplyTo:        mimeparse.FormatAddressList(parsed.ReplyTo),
+		InReplyTo:      parsed.InReplyTo,
+		References:     parsed.References,
+		Body:           &dto.MessageBody{Text: parsed.Text, HTML: parsed.HTML},
+		Spam:           newSpamReportDTO(spam.ParseReport(m)),
+		Auth:           dto.NewMessageAuth(m),
+	}
+	if len(attachments) > 0 {
+		detail.Attachments = dto.NewMessageAttachmentList(attachments).Attachments
+		return detail
+	}
+	detail.Attachments = make([]*dto.MessageAttachment, 0, len(parsed.Attachments))
+	for i, a := range parsed.Attachments {
+		detail.Attachments = append(detail.Attachments, &dto.MessageAttachment{
+			Index:       i,
+			Filename:    a.Filename,
+			ContentType: a.ContentType,
+			ContentID:   a.ContentID,
+			Inline:      a.Inline,
+			Size:        a.Size,
+		})
+	}
+	return detail
+}
+
+// newSpamReportDTO 转换垃圾邮件评分报告，未评分时返回 nil
+func newSpamReportDTO(r *spam.Report) *dto.SpamReport {
+	if r == nil {
+		return nil
+	}
+	report := &dto.SpamReport{Score: r.Score, Threshold: r.Threshold, Spam: r.Spam, Rules: make([]*dto.SpamRule, 0, len(r.Hits))}
+	for _, h := range r.Hits {
+		report.Rules = append(report.Rules, &dto.SpamRule{Rule: h.Rule, Score: h.Score, Description: h.Description})
+	}
+	return report
+}
+
+// DownloadRawMessage 下载原始邮件 .eml
+// GET /v1/myplaud/messages/:id/raw
+func (h *MessageHandler) DownloadRawMessage(c *gin.Context) {
+	msg, ok := h.loadMessage(c)
+	if !ok {
+		return
+	}
+
+	c.Header("Content-Type", "message/rfc822")
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%d.eml"`, msg.ID))
+	c.Header("Content-Length", strconv.FormatInt(msg.Size, 10))
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Status(http.StatusOK)
+
+	if err := h.svc.WriteRaw(c.Request.Context(), msg, c.Writer); err != nil {
+		// 响应头已写出，只能中断连接
+		logger.ErrorfCtx(c.Request.Context(), "download raw message %d error: %v", msg.ID, err)
+		c.Abort()
+	}
+}
+
+// ListAttachments 查询一封邮件已拆分的附件
+// GET /v1/myplaud/messages/:id/attachments
+func (h *MessageHandler) ListAttachments(c *gin.Context) {
+	msg, ok := h.loadMessage(c)
+	if !ok {
+		return
+	}
+
+	attachments, err := h.svc.ListAttachments(c.Request.Context(), msg.UserID, msg.ID)
+	if err != nil {
+		FailResponse(c, http.StatusInternalServerError, "list attachments failed")
+		return
+	}
+	SuccessResponse(c, dto.NewMessageAttachmentList(attachments))
+}
+
+// DownloadAttachment 下载一封邮件的附件，disposition=inline|attachment 指定展示方式，默认沿用邮件中的声明
+// 只有可以安全展示的类型才允许 inline，HTML、SVG 等可执行脚本的类型一律以 application/octet-stream 下载
+// GET /v1/myplaud/messages/:id/attachments/:attachment_id?disposition=inline
+func (h *MessageHandler) DownloadAttachment(c *gin.Context) {
+	msg, ok := h.loadMessage(c)
+	if !ok {
+		return
+	}
+	id, err := strconv.ParseUint(c.Param("attachment_id"), 10, 64)
+	if err != nil || id == 0 {
+		FailResponse(c, http.StatusBadRequest, "invalid attachment id")
+		return
+	}
+
+	attachment, err := h.svc.GetAttachment(c.Request.Context(), msg.UserID, msg.ID, id)
+	if err != nil {
+		if errors.Is(err, message.ErrAttachmentNotFound) {
+			FailResponse(c, http.StatusNotFound, "attachment not found")
+			return
+		}
+		FailResponse(c, http.StatusInternalServerError, "get attachment failed")
+		return
+	}
+
+	inline := attachment.Inline
+	if v := c.Query("disposition"); v != "" {
+		inline = v == "inline"
+	}
+	contentType, inlineSafe := attachmentServeType(attachment)
+	disposition := "attachment"
+	if inline && inlineSafe {
+		disposition = "inline"
+	}
+	filename := attachment.Filename
+	if filename == "" {
+		filename = fmt.Sprintf("attachment-%d", attachment.ID)
+	}
+	header := mime.FormatMediaType(disposition, map[string]string{"filename": filename})
+	if header == "" {
+		header = disposition
+	}
+
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Disposition", header)
+	c.Header("Content-Length", strconv.FormatInt(attachment.Size, 10))
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Header("Content-Security-Policy", "default-src 'none'; sandbox")
+	c.Header("Cache-Control", "private, max-age=0")
+	c.Status(http.StatusOK)
+
+	if err := h.svc.WriteAttachment(c.Request.Context(), attachment, c.Writer); err != nil {
+		// 响应头已写出，只能中断连接
+		logger.ErrorfCtx(c.Request.Context(), "download attachment %d of message %d error: %v", attachment.ID, msg.ID, err)
+		c.Abort()
+	}
+}
+
+// ExtractAttachments 为指定用户的历史邮件拆分附件（内部接口）
+// POST /v1/mailbox/attachments/extract?user_id=xxx
+func (h *MessageHandler) ExtractAttachments(c *gin.Context) {
+	userID := c.Query("user_id")
+	if userID == "" {
+		FailResponse(c, http.StatusBadRequest, "user_id is required")
+		return
+	}
+
+	count, err := h.svc.ExtractAttachments(c.Request.Context(), userID)
+	if err != nil {
+		if errors.Is(err, message.ErrStorageNotConfigured) {
+			FailResponse(c, http.StatusServiceUnavailable, err.Error())
+			return
+		}
+		FailResponse(c, http.StatusInternalServerError, "extract attachments failed")
+		return
+	}
+	SuccessResponse(c, &dto.AttachmentExtract{UserID: userID, Messages: count})
+}
+
+// inlineSafeTypes 浏览器直接展示时不会执行脚本的类型
+var inlineSafeTypes = map[string]bool{
+	"image/png":       true,
+	"image/jpeg":      true,
+	"image/gif":       true,
+	"image/webp":      true,
+	"image/bmp":       true,
+	"application/pdf": true,
+}
+
+// attachmentServeType 返回下载附件使用的 Content-Type 以及能否 inline 展示
+// 声明类型与识别类型任一可能执行脚本时按二进制下载，否则以识别类型为准
+func attachmentServeType(a *datamodel.Attachment) (string, bool) {
+	if isActiveType(a.DeclaredType) || isActiveType(a.SniffedType) {
+		return "application/octet-stream", false
+	}
+	contentType := a.SniffedType
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	switch {
+	case contentType == "text/plain":
+		return "text/plain; charset=utf-8", true
+	case inlineSafeTypes[contentType], strings.HasPrefix(contentType, "audio/"), strings.HasPrefix(contentType, "video/"):
+		return contentType, true
+	}
+	return contentType, false
+}
+
+// isActiveType 是否为浏览器可能作为文档渲染并执行脚本的类型
+func isActiveType(mediaType string) bool {
+	mediaType = strings.ToLower(mediaType)
+	return strings.Contains(mediaType, "html") ||
+		strings.Contains(mediaType, "svg") ||
+		strings.Contains(mediaType, "xml") ||
+		strings.Contains(mediaType, "javascript") ||
+		strings.Contains(mediaType, "ecmascript")
+}
+
+// DeleteMessage 删除一封邮件，释放存储用量
+// DELETE /v1/myplaud/messages/:id
+func (h *MessageHandler) DeleteMessage(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil || id == 0 {
+		FailResponse(c, http.StatusBadRequest, "invalid message id")
+		return
+	}
+
+	if err := h.svc.Delete(c.Request.Context(), GetUserID(c), id); err != nil {
+		switch {
+		case errors.Is(err, message.ErrMessageNotFound):
+			FailResponse(c, http.StatusNotFound, "message not found")
+		case errors.Is(err, message.ErrLegalHold):
+			FailResponse(c, http.StatusConflict, err.Error())
+		default:
+			FailResponse(c, http.StatusInternalServerError, "delete message failed")
+		}
+		return
+	}
+
+	SuccessResponse(c, nil)
+}
+
+// GetUsage 查询当前用户的邮箱存储用量与配额
+// GET /v1/myplaud/mailbox/usage
+func (h *MessageHandler) GetUsage(c *gin.Context) {
+	usage, err := h.svc.GetUsage(c.Request.Context(), GetUserID(c))
+	if err != nil {
+		FailResponse(c, http.StatusInternalServerError, "get mailbox usage failed")
+		return
+	}
+	SuccessResponse(c, newMailboxUsageDTO(usage))
+}
+
+// GetUserUsage 查询指定用户的邮箱存储用量（内部接口）
+// GET /v1/mailbox/usage?user_id=xxx
+func (h *MessageHandler) GetUserUsage(c *gin.Context) {
+	userID := c.Query("user_id")
+	if userID == "" {
+		FailResponse(c, http.StatusBadRequest, "user_id is required")
+		return
+	}
+
+	usage, err := h.svc.GetUsage(c.Request.Context(), userID)
+	if err != nil {
+		FailResponse(c, http.StatusInternalServerError, "get mailbox usage failed")
+		return
+	}
+	SuccessResponse(c, newMailboxUsageDTO(usage))
+}
+
+// ReconcileUsage 立即重新核算指定用户的用量（内部接口），verify_objects=true 时逐个校验 S3 对象
+// POST /v1/mailbox/usage/reconcile?user_id=xxx&verify_objects=true
+func (h *MessageHandler) ReconcileUsage(c *gin.Context) {
+	userID := c.Query("user_id")
+	if userID == "" {
+		FailResponse(c, http.StatusBadRequest, "user_id is required")
+		return
+	}
+	verify, _ := strconv.ParseBool(c.Query("verify_objects"))
+
+	usage, err := h.svc.Reconcile(c.Request.Context(), userID, verify)
+	if err != nil {
+		FailResponse(c, http.StatusInternalServerError, "reconcile mailbox usage failed")
+		return
+	}
+	SuccessResponse(c, newMailboxUsageDTO(usage))
+}
+
+// BulkUpdateReq 批量更新邮件状态请求，字段为 null 或空时不修改
+type BulkUpdateReq struct {
+	IDs            []uint64 `json:"ids" binding:"required"`
+	Read           *bool    `json:"read"`
+	Starred        *bool    `json:"starred"`
+	Important      *bool    `json:"important"`
+	Folder         string   `json:"folder"`
+	AddLabelIDs    []uint64 `json:"add_label_ids"`
+	RemoveLabelIDs []uint64 `json:"remove_label_ids"`
+}
+
+// BulkUpdate 批量更新当前用户邮件的已读、星标、重要标记、文件夹与标签
+// POST /v1/myplaud/messages/bulk
+func (h *MessageHandler) BulkUpdate(c *gin.Context) {
+	var req BulkUpdateReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		FailResponse(c, http.StatusBadRequest, "invalid request: "+err.Error())
+		return
+	}
+
+	updated, modSeq, err := h.svc.UpdateState(c.Request.Context(), GetUserID(c), &message.StateUpdate{
+		IDs:            req.IDs,
+		Seen:           req.Read,
+		Starred:        req.Starred,
+		Important:      req.Important,
+		Folder:         req.Folder,
+		AddLabelIDs:    req.AddLabelIDs,
+		RemoveLabelIDs: req.RemoveLabelIDs,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, message.ErrInvalidBulk),
+			errors.Is(err, message.ErrInvalidFolder),
+			errors.Is(err, message.ErrEmptyUpdate):
+			FailResponse(c, http.StatusBadRequest, err.Error())
+		case errors.Is(err, message.ErrLabelNotFound):
+			FailResponse(c, http.StatusNotFound, err.Error())
+		default:
+			FailResponse(c, http.StatusInternalServerError, "update messages failed")
+		}
+		return
+	}
+	SuccessResponse(c, &dto.BulkUpdateResult{Updated: updated, ModSeq: modSeq})
+}
+
+// ListChanges 查询 modseq 大于 since_modseq 的邮件变化，用于客户端增量同步
+// GET /v1/myplaud/messages/changes?since_modseq=100&cursor=xxx&limit=100
+func (h *MessageHandler) ListChanges(c *gin.Context) {
+	userID := GetUserID(c)
+
+	var since uint64
+	if v := c.Query("since_modseq"); v != "" {
+		parsed, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			FailResponse(c, http.StatusBadRequest, "invalid since_modseq")
+			return
+		}
+		since = parsed
+	}
+	var cursor *message.ChangeCursor
+	if v := c.Query("cursor"); v != "" {
+		parsed, err := message.ParseChangeCursor(v)
+		if err != nil {
+			FailResponse(c, http.StatusBadRequest, err.Error())
+			return
+		}
+		cursor = parsed
+	}
+	limit, _ := strconv.Atoi(c.Query("limit"))
+
+	changes, err := h.svc.ListChanges(c.Request.Context(), userID, since, cursor, limit)
+	if err != nil {
+		FailResponse(c, http.StatusInternalServerError, "list changes failed")
+		return
+	}
+	labelIDs, err := h.svc.LabelIDs(c.Request.Context(), userID, changes.Messages)
+	if err != nil {
+		FailResponse(c, http.StatusInternalServerError, "list changes failed")
+		return
+	}
+	var next string
+	if changes.Next != nil {
+		next = changes.Next.String()
+	}
+	SuccessResponse(c, dto.NewMessageChanges(changes.Messages, changes.HighestModSeq, next, labelIDs))
+}
+
+// loadMessage 解析路径中的 id 并加载当前用户的邮件，失败时已写出响应
+func (h *MessageHandler) loadMessage(c *gin.Context) (*datamodel.Message, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil || id == 0 {
+		FailResponse(c, http.StatusBadRequest, "invalid message id")
+		return nil, false
+	}
+
+	msg, err := h.svc.Get(c.Request.Context(), GetUserID(c), id)
+	if err != nil {
+		if errors.Is(err, message.ErrMessageNotFound) {
+			FailResponse(c, http.StatusNotFound, "message not found")
+			return nil, false
+		}
+		logger.ErrorfCtx(c.Request.Context(), "get message error: %v", err)
+		FailResponse(c, http.StatusInternalServerError, "get message failed")
+		return nil, false
+	}
+	return msg, true
+}
+
+// newMailboxUsageDTO 从用量转换为 DTO
+func newMailboxUsageDTO(u *message.Usage) *dto.MailboxUsage {
+	usage := &dto.MailboxUsage{
+		Cohort:           u.Cohort,
+		Bytes:            u.Bytes,
+		Messages:         u.Messages,
+		MaxBytes:         u.MaxBytes,
+		MaxMessages:      u.MaxMessages,
+		SoftLimitPercent: u.SoftLimitPercent,
+		OverSoftLimit:    u.OverSoftLimit(),
+	}
+	if u.WarnedAt != nil {
+		usage.WarnedAt = u.WarnedAt.UnixMilli()
+	}
+	if u.ReconciledAt != nil {
+		usage.ReconciledAt = u.ReconciledAt.UnixMilli()
+	}
+	return usage
+}
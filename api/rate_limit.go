@@ -0,0 +1,42 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Plaud-AI/plaud-go-scaffold/pkg/logger"
+	"github.com/Plaud-AI/plaud-go-scaffold/pkg/rdb"
+
+	"github.com/gin-gonic/gin"
+)
+
+// UserRateLimitMiddleware 按用户的固定窗口限流，窗口长度为 conf 的 TTL，需挂在鉴权中间件之后
+// Redis 不可用时放行，避免影响正常请求
+func UserRateLimitMiddleware(client *rdb.Client, conf *rdb.CacheConfig, limit int64) gin.HandlerFunc {
+	window := int64(conf.TTL() / time.Second)
+	return func(c *gin.Context) {
+		userID := GetUserID(c)
+		if client == nil || userID == "" {
+			c.Next()
+			return
+		}
+
+		now := time.Now().Unix()
+		slot := now / window
+		key := conf.NewParamKey(userID + ":" + strconv.FormatInt(slot, 10))
+		count, err := client.Incr(c.Request.Context(), key)
+		if err != nil {
+			logger.WarnfCtx(c.Request.Context(), "rate limit %s error: %v", conf.Namespace(), err)
+			c.Next()
+			return
+		}
+		if count > limit {
+			c.Header("Retry-After", strconv.FormatInt((slot+1)*window-now, 10))
+			FailResponse(c, http.StatusTooManyRequests, "too many requests")
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,146 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"plaud-emails/data/dto"
+	"plaud-emails/service/message"
+	"plaud-emails/service/mindadvisor"
+	"plaud-emails/service/retention"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RetentionHandler 邮件保留策略与法律保留处理器
+type RetentionHandler struct {
+	svc         *retention.RetentionService
+	mindAdvisor *mindadvisor.MindAdvisorService
+	messages    *message.MessageService
+}
+
+// NewRetentionHandler 创建 RetentionHandler
+func NewRetentionHandler(svc *retention.RetentionService, mindAdvisor *mindadvisor.MindAdvisorService, messages *message.MessageService) *RetentionHandler {
+	return &RetentionHandler{svc: svc, mindAdvisor: mindAdvisor, messages: messages}
+}
+
+// newRetentionPolicyDTO 从生效的保留策略转换为 DTO
+func newRetentionPolicyDTO(p *retention.Policy) *dto.RetentionPolicy {
+	return &dto.RetentionPolicy{
+		MessageDays:          p.MessageDays,
+		AttachmentDays:       p.AttachmentDays,
+		Custom:               p.Custom,
+		GlobalMessageDays:    p.GlobalMessageDays,
+		GlobalAttachmentDays: p.GlobalAttachmentDays,
+	}
+}
+
+// GetRetention 查询当前用户生效的保留策略
+// GET /v1/myplaud/mailbox/retention
+func (h *RetentionHandler) GetRetention(c *gin.Context) {
+	policy, err := h.svc.GetPolicy(c.Request.Context(), GetUserID(c))
+	if err != nil {
+		FailResponse(c, http.StatusInternalServerError, "get retention policy failed")
+		return
+	}
+	SuccessResponse(c, newRetentionPolicyDTO(policy))
+}
+
+// SetRetentionReq 设置保留策略请求，字段为 null 时沿用全局规则
+type SetRetentionReq struct {
+	MessageDays    *int `json:"message_days"`
+	AttachmentDays *int `json:"attachment_days"`
+}
+
+// SetRetention 设置当前用户的保留策略，只能短于全局规则
+// PUT /v1/myplaud/mailbox/retention
+func (h *RetentionHandler) SetRetention(c *gin.Context) {
+	var req SetRetentionReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		FailResponse(c, http.StatusBadRequest, "invalid request: "+err.Error())
+		return
+	}
+
+	policy, err := h.svc.SetPolicy(c.Request.Context(), GetUserID(c), req.MessageDays, req.AttachmentDays)
+	if err != nil {
+		if errors.Is(err, retention.ErrInvalidDays) {
+			FailResponse(c, http.StatusBadRequest, err.Error())
+			return
+		}
+		FailResponse(c, http.StatusInternalServerError, "set retention policy failed")
+		return
+	}
+	SuccessResponse(c, newRetentionPolicyDTO(policy))
+}
+
+// ResetRetention 删除当前用户的保留策略，恢复使用全局规则
+// DELETE /v1/myplaud/mailbox/retention
+func (h *RetentionHandler) ResetRetention(c *gin.Context) {
+	policy, err := h.svc.ResetPolicy(c.Request.Context(), GetUserID(c))
+	if err != nil {
+		FailResponse(c, http.StatusInternalServerError, "reset retention policy failed")
+		return
+	}
+	SuccessResponse(c, newRetentionPolicyDTO(policy))
+}
+
+// LegalHoldReq 设置法律保留请求
+type LegalHoldReq struct {
+	UserID    string `json:"user_id" binding:"required"`
+	LegalHold *bool  `json:"legal_hold" binding:"required"`
+	Actor     string `json:"actor" binding:"required"`
+	Reason    string `json:"reason"`
+}
+
+// SetLegalHold 设置或解除邮箱的法律保留，仅挂载在内部路由
+// PUT /v1/mailbox/legal-hold
+func (h *RetentionHandler) SetLegalHold(c *gin.Context) {
+	var req LegalHoldReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		FailResponse(c, http.StatusBadRequest, "invalid request: "+err.Error())
+		return
+	}
+
+	user, err := h.mindAdvisor.SetLegalHold(c.Request.Context(), req.UserID, *req.LegalHold, req.Actor, req.Reason)
+	if err != nil {
+		if errors.Is(err, mindadvisor.ErrMailboxNotCreated) {
+			FailResponse(c, http.StatusNotFound, err.Error())
+			return
+		}
+		FailResponse(c, http.StatusInternalServerError, "set legal hold failed")
+		return
+	}
+	SuccessResponse(c, &dto.LegalHold{UserID: user.UserID, LegalHold: user.LegalHold})
+}
+
+// MessageLegalHoldReq 设置邮件法律保留请求
+type MessageLegalHoldReq struct {
+	UserID    string `json:"user_id" binding:"required"`
+	LegalHold *bool  `json:"legal_hold" binding:"required"`
+}
+
+// SetMessageLegalHold 设置或解除一封邮件的法律保留，仅挂载在内部路由
+// PUT /v1/mailbox/messages/:id/legal-hold
+func (h *RetentionHandler) SetMessageLegalHold(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil || id == 0 {
+		FailResponse(c, http.StatusBadRequest, "invalid message id")
+		return
+	}
+	var req MessageLegalHoldReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		FailResponse(c, http.StatusBadRequest, "invalid request: "+err.Error())
+		return
+	}
+
+	if err := h.messages.SetLegalHold(c.Request.Context(), req.UserID, id, *req.LegalHold); err != nil {
+		if errors.Is(err, message.ErrMessageNotFound) {
+			FailResponse(c, http.StatusNotFound, "message not found")
+			return
+		}
+		FailResponse(c, http.StatusInternalServerError, "set legal hold failed")
+		return
+	}
+	SuccessResponse(c, &dto.LegalHold{UserID: req.UserID, MessageID: id, LegalHold: *req.LegalHold})
+}
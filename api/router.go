@@ -50,6 +50,14 @@ func InitRouter(services Services) (public http.Handler, private http.Handler) {
 	userHandler := NewUserHandler(services.GetUserService(), helloClient)
 	mailboxHandler := NewMailboxHandler(services.GetMindAdvisorService())
 	betaHandler := NewBetaHandler(services.GetMindAdvisorService())
+	messageHandler := NewMessageHandler(services.GetMessageService())
+	linkedEmailHandler := NewLinkedEmailHandler(services.GetLinkedEmailService())
+	blocklistHandler := NewBlocklistHandler(services.GetMindAdvisorService())
+	retentionHandler := NewRetentionHandler(services.GetRetentionService(), services.GetMindAdvisorService(), services.GetMessageService())
+	threadHandler := NewThreadHandler(services.GetThreadService())
+	searchHandler := NewSearchHandler(services.GetSearchService())
+	labelHandler := NewLabelHandler(services.GetMessageService())
+	mailSyncHandler := NewMailSyncHandler(services.GetMailSyncScheduler(), services.GetLinkedEmailService())
 
 	// 初始化 PlaudAuthService（用于 beta 路由的鉴权）
 	// 优先从配置文件 services.plaud_api.base_url 读取，否则从环境变量 PLAUD_API_URL 兜底
@@ -91,7 +99,29 @@ func InitRouter(services Services) (public http.Handler, private http.Handler) {
 	myplaudWrite.Use(ReqIDMiddleware(), BetaAuthMiddleware())
 	{
 		myplaudWrite.POST("/mailbox/create", mailboxHandler.CreateMailbox)
+		myplaudWrite.GET("/mailbox/availability",
+			UserRateLimitMiddleware(services.GetRedisClient(), availabilityRateLimit, maxAvailabilityChecks),
+			mailboxHandler.CheckAvailability)
+		myplaudWrite.POST("/mailbox/rename", mailboxHandler.RenameMailbox)
+		myplaudWrite.GET("/mailbox/settings", mailboxHandler.GetSettings)
+		myplaudWrite.PATCH("/mailbox/settings", mailboxHandler.UpdateSettings)
+		myplaudWrite.POST("/mailbox/deactivate", mailboxHandler.DeactivateMailbox)
+		myplaudWrite.POST("/mailbox/reactivate", mailboxHandler.ReactivateMailbox)
+		myplaudWrite.DELETE("/mailbox", mailboxHandler.DeleteMailbox)
+		myplaudWrite.GET("/mailbox/usage", messageHandler.GetUsage)
+		myplaudWrite.GET("/mailbox/retention", retentionHandler.GetRetention)
+		myplaudWrite.PUT("/mailbox/retention", retentionHandler.SetRetention)
+		myplaudWrite.DELETE("/mailbox/retention", retentionHandler.ResetRetention)
+		myplaudWrite.GET("/mailbox/retired-addresses", mailboxHandler.ListRetiredAddresses)
+		myplaudWrite.POST("/mailbox/aliases", mailboxHandler.AddAlias)
+		myplaudWrite.GET("/mailbox/aliases", mailboxHandler.ListAliases)
+		myplaudWrite.DELETE("/mailbox/aliases/:id", mailboxHandler.RemoveAlias)
+		myplaudWrite.PUT("/mailbox/tag-rules/:tag", mailboxHandler.SetTagRule)
+		myplaudWrite.GET("/mailbox/tag-rules", mailboxHandler.ListTagRules)
+		myplaudWrite.DELETE("/mailbox/tag-rules/:tag", mailboxHandler.DeleteTagRule)
+		// 兼容旧客户端，新接口为 /v1/myplaud/linked-emails/status
 		myplaudWrite.GET("/linked-email/status", mailboxHandler.GetLinkedEmailStatus)
+		myplaudWrite.GET("/linked-email/:id/sync-history", linkedEmailHandler.GetSyncHistory)
 	}
 
 	// myplaud beta - 内测邀请登记（对外暴露，需鉴权）
@@ -103,7 +133,95 @@ func InitRouter(services Services) (public http.Handler, private http.Handler) {
 		beta.GET("/registration/status", betaHandler.GetBetaRegistrationStatus)
 	}
 
+	// myplaud messages - 收件查询（对外暴露，需鉴权）
+	messages := publicRouter.Group("/v1/myplaud/messages")
+	messages.Use(ReqIDMiddleware(), BetaAuthMiddleware())
+	{
+		messages.GET("", messageHandler.ListMessages)
+		messages.GET("/search", searchHandler.SearchMessages)
+		messages.GET("/changes", messageHandler.ListChanges)
+		messages.POST("/bulk", messageHandler.BulkUpdate)
+		messages.GET("/:id", messageHandler.GetMessage)
+		messages.GET("/:id/raw", messageHandler.DownloadRawMessage)
+		messages.GET("/:id/attachments", messageHandler.ListAttachments)
+		messages.GET("/:id/attachments/:attachment_id", messageHandler.DownloadAttachment)
+		messages.DELETE("/:id", messageHandler.DeleteMessage)
+	}
+
+	// myplaud labels - 邮件标签（对外暴露，需鉴权）
+	labels := publicRouter.Group("/v1/myplaud/labels")
+	labels.Use(ReqIDMiddleware(), BetaAuthMiddleware())
+	{
+		labels.GET("", labelHandler.ListLabels)
+		labels.POST("", labelHandler.CreateLabel)
+		labels.PATCH("/:id", labelHandler.RenameLabel)
+		labels.DELETE("/:id", labelHandler.DeleteLabel)
+	}
+
+	// myplaud threads - 邮件会话（对外暴露，需鉴权）
+	threads := publicRouter.Group("/v1/myplaud/threads")
+	threads.Use(ReqIDMiddleware(), BetaAuthMiddleware())
+	{
+		threads.GET("", threadHandler.ListThreads)
+		threads.GET("/:id", threadHandler.GetThread)
+	}
+
+	// myplaud linked emails - 外部邮箱绑定（对外暴露，需鉴权）
+	linkedEmails := publicRouter.Group("/v1/myplaud/linked-emails")
+	linkedEmails.Use(ReqIDMiddleware(), BetaAuthMiddleware())
+	{
+		linkedEmails.POST("", linkedEmailHandler.CreateLinkedEmail)
+		linkedEmails.POST("/imap", linkedEmailHandler.CreateIMAPLinkedEmail)
+		linkedEmails.GET("/oauth/:source/authorize", linkedEmailHandler.AuthorizeOAuth)
+		linkedEmails.GET("", linkedEmailHandler.ListLinkedEmails)
+		linkedEmails.GET("/status", mailboxHandler.GetLinkedEmailStatus)
+		// 与 /v1/myplaud/linked-email/:id/sync-history 相同
+		linkedEmails.GET("/:id/sync-history", linkedEmailHandler.GetSyncHistory)
+		linkedEmails.DELETE("/:id", linkedEmailHandler.DeleteLinkedEmail)
+	}
+
+	// myplaud oauth - 外部邮箱授权回调（由服务商重定向，通过 state 关联用户）
+	oauthCallback := publicRouter.Group("/v1/myplaud/oauth")
+	oauthCallback.Use(ReqIDMiddleware())
+	{
+		oauthCallback.GET("/:source/callback", linkedEmailHandler.OAuthCallback)
+	}
+
 	// private
 	privateRouter.POST("/index", demoHandler.Index)
+
+	// 外部邮箱同步调度状态（内部排查使用）
+	privateRouter.GET("/v1/mail-sync/schedule", mailSyncHandler.GetSchedule)
+	privateRouter.GET("/v1/mail-sync/linked-emails/:id/sync-history", mailSyncHandler.GetSyncHistory)
+
+	// 专属邮箱状态变更审计
+	privateRouter.GET("/v1/mailbox/audits", mailboxHandler.ListMailboxAudits)
+
+	// 邮箱存储用量查询与核算
+	privateRouter.GET("/v1/mailbox/usage", messageHandler.GetUserUsage)
+	privateRouter.POST("/v1/mailbox/usage/reconcile", messageHandler.ReconcileUsage)
+
+	// 法律保留，保留期间不清理邮件
+	privateRouter.PUT("/v1/mailbox/legal-hold", retentionHandler.SetLegalHold)
+	privateRouter.PUT("/v1/mailbox/messages/:id/legal-hold", retentionHandler.SetMessageLegalHold)
+
+	// 为历史邮件补齐会话
+	privateRouter.POST("/v1/mailbox/threads/rebuild", threadHandler.RebuildThreads)
+
+	// 为历史邮件拆分附件
+	privateRouter.POST("/v1/mailbox/attachments/extract", messageHandler.ExtractAttachments)
+
+	// 为历史邮件补齐全文索引
+	privateRouter.POST("/v1/mailbox/search/reindex", searchHandler.Reindex)
+
+	// local_part 屏蔽规则管理
+	blocklist := privateRouter.Group("/v1/mailbox/blocklist")
+	{
+		blocklist.GET("/rules", blocklistHandler.ListRules)
+		blocklist.POST("/rules", blocklistHandler.CreateRule)
+		blocklist.PUT("/rules/:id", blocklistHandler.UpdateRule)
+		blocklist.DELETE("/rules/:id", blocklistHandler.DeleteRule)
+		blocklist.GET("/check", blocklistHandler.Check)
+	}
 	return publicRouter, privateRouter
 }
@@ -0,0 +1,73 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"plaud-emails/data/dto"
+	"plaud-emails/service/search"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SearchHandler 邮件检索处理器
+type SearchHandler struct {
+	svc *search.SearchService
+}
+
+// NewSearchHandler 创建 SearchHandler
+func NewSearchHandler(svc *search.SearchService) *SearchHandler {
+	return &SearchHandler{svc: svc}
+}
+
+// SearchMessages 检索当前用户的邮件，支持 from:、to:、subject:、filename:、has:attachment、label:、tag:、before:、after:
+// GET /v1/myplaud/messages/search?q=from:alice has:attachment&cursor=xxx&limit=20
+func (h *SearchHandler) SearchMessages(c *gin.Context) {
+	var cursor uint64
+	if v := c.Query("cursor"); v != "" {
+		parsed, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			FailResponse(c, http.StatusBadRequest, "invalid cursor")
+			return
+		}
+		cursor = parsed
+	}
+	limit, _ := strconv.Atoi(c.Query("limit"))
+
+	msgs, next, err := h.svc.Search(c.Request.Context(), GetUserID(c), c.Query("q"), cursor, limit)
+	if err != nil {
+		if errors.Is(err, search.ErrEmptyQuery) || errors.Is(err, search.ErrInvalidQuery) {
+			FailResponse(c, http.StatusBadRequest, err.Error())
+			return
+		}
+		FailResponse(c, http.StatusInternalServerError, "search messages failed")
+		return
+	}
+
+	resp := &dto.MessageList{Messages: make([]*dto.MessageSummary, 0, len(msgs))}
+	for _, m := range msgs {
+		resp.Messages = append(resp.Messages, dto.NewMessageSummaryFromModel(m))
+	}
+	if next > 0 {
+		resp.NextCursor = strconv.FormatUint(next, 10)
+	}
+	SuccessResponse(c, resp)
+}
+
+// Reindex 为用户尚未写入索引的邮件补齐全文索引，仅挂载在内部路由
+// POST /v1/mailbox/search/reindex?user_id=xxx
+func (h *SearchHandler) Reindex(c *gin.Context) {
+	userID := c.Query("user_id")
+	if userID == "" {
+		FailResponse(c, http.StatusBadRequest, "user_id is required")
+		return
+	}
+
+	count, err := h.svc.Reindex(c.Request.Context(), userID)
+	if err != nil {
+		FailResponse(c, http.StatusInternalServerError, "reindex messages failed")
+		return
+	}
+	SuccessResponse(c, &dto.SearchReindex{UserID: userID, Messages: count})
+}
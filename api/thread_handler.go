@@ -0,0 +1,107 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"plaud-emails/data/dto"
+	"plaud-emails/service/thread"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ThreadHandler 邮件会话处理器
+type ThreadHandler struct {
+	svc *thread.ThreadService
+}
+
+// NewThreadHandler 创建 ThreadHandler
+func NewThreadHandler(svc *thread.ThreadService) *ThreadHandler {
+	return &ThreadHandler{svc: svc}
+}
+
+// ListThreads 按最近邮件倒序分页查询当前用户的会话
+// GET /v1/myplaud/threads?cursor=xxx&limit=20
+func (h *ThreadHandler) ListThreads(c *gin.Context) {
+	var cursor uint64
+	if v := c.Query("cursor"); v != "" {
+		parsed, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			FailResponse(c, http.StatusBadRequest, "invalid cursor")
+			return
+		}
+		cursor = parsed
+	}
+	limit, _ := strconv.Atoi(c.Query("limit"))
+
+	threads, next, err := h.svc.List(c.Request.Context(), GetUserID(c), cursor, limit)
+	if err != nil {
+		FailResponse(c, http.StatusInternalServerError, "list threads failed")
+		return
+	}
+
+	resp := &dto.ThreadList{Threads: make([]*dto.ThreadSummary, 0, len(threads))}
+	for _, t := range threads {
+		resp.Threads = append(resp.Threads, dto.NewThreadSummaryFromModel(t))
+	}
+	if next > 0 {
+		resp.NextCursor = strconv.FormatUint(next, 10)
+	}
+	SuccessResponse(c, resp)
+}
+
+// newThreadDetailDTO 从会话详情转换为 DTO
+func newThreadDetailDTO(d *thread.Detail) *dto.ThreadDetail {
+	detail := &dto.ThreadDetail{
+		ThreadSummary: dto.NewThreadSummaryFromModel(d.Thread),
+		Messages:      make([]*dto.ThreadMessage, 0, len(d.Entries)),
+		Truncated:     d.Truncated,
+	}
+	for _, e := range d.Entries {
+		detail.Messages = append(detail.Messages, &dto.ThreadMessage{
+			MessageSummary: dto.NewMessageSummaryFromModel(e.Message),
+			ParentID:       e.ParentID,
+			Depth:          e.Depth,
+		})
+	}
+	return detail
+}
+
+// GetThread 获取会话详情，邮件按回复关系排列
+// GET /v1/myplaud/threads/:id
+func (h *ThreadHandler) GetThread(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil || id == 0 {
+		FailResponse(c, http.StatusBadRequest, "invalid thread id")
+		return
+	}
+
+	detail, err := h.svc.Get(c.Request.Context(), GetUserID(c), id)
+	if err != nil {
+		if errors.Is(err, thread.ErrThreadNotFound) {
+			FailResponse(c, http.StatusNotFound, "thread not found")
+			return
+		}
+		FailResponse(c, http.StatusInternalServerError, "get thread failed")
+		return
+	}
+	SuccessResponse(c, newThreadDetailDTO(detail))
+}
+
+// RebuildThreads 为用户尚未分配会话的邮件补齐会话，仅挂载在内部路由
+// POST /v1/mailbox/threads/rebuild?user_id=xxx
+func (h *ThreadHandler) RebuildThreads(c *gin.Context) {
+	userID := c.Query("user_id")
+	if userID == "" {
+		FailResponse(c, http.StatusBadRequest, "user_id is required")
+		return
+	}
+
+	count, err := h.svc.Rebuild(c.Request.Context(), userID)
+	if err != nil {
+		FailResponse(c, http.StatusInternalServerError, "rebuild threads failed")
+		return
+	}
+	SuccessResponse(c, &dto.ThreadRebuild{UserID: userID, Messages: count})
+}
@@ -111,7 +111,13 @@ func main() {
 	publicServer := appsvc.StartHTTPServer("public", conf.Public.IP, conf.Public.Port, publicMux, errChan)
 	privateServer := appsvc.StartHTTPServer("private", conf.Private.IP, conf.Private.Port, privateMux, errChan)
 
-	if grpcServer == nil && publicServer == nil && privateServer == nil {
+	// 启动 SMTP 收信服务，停止由 svc.StopAll 负责
+	smtpStarted, err := allServices.SMTPServer.ListenAndServe(errChan)
+	if err != nil {
+		exitNow("start smtp server fail, err:%v", err)
+	}
+
+	if grpcServer == nil && publicServer == nil && privateServer == nil && !smtpStarted {
 		exitNow("all servers are disabled")
 	}
 
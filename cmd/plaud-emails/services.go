@@ -2,14 +2,29 @@ package main
 
 import (
 	"context"
+	"time"
 
 	"plaud-emails/external/helloservice"
 	appconfig "plaud-emails/pkg/config"
+	"plaud-emails/pkg/relay"
+	"plaud-emails/pkg/secretbox"
+	"plaud-emails/service/inbound"
+	"plaud-emails/service/linkedemail"
+	"plaud-emails/service/mailauth"
+	"plaud-emails/service/mailsync"
+	"plaud-emails/service/message"
 	"plaud-emails/service/mindadvisor"
+	"plaud-emails/service/retention"
 	"plaud-emails/service/rpc/server"
+	"plaud-emails/service/search"
+	"plaud-emails/service/smtpd"
+	"plaud-emails/service/spam"
+	"plaud-emails/service/thread"
 	"plaud-emails/service/user"
 
 	"github.com/Plaud-AI/plaud-go-scaffold/pkg/app"
+	"github.com/Plaud-AI/plaud-go-scaffold/pkg/aws"
+	"github.com/Plaud-AI/plaud-go-scaffold/pkg/logger"
 
 	"google.golang.org/grpc"
 )
@@ -18,6 +33,14 @@ type Services struct {
 	*app.Services[*appconfig.AppConfig]
 	UserService        *user.UserService
 	MindAdvisorService *mindadvisor.MindAdvisorService
+	MessageService     *message.MessageService
+	LinkedEmailService *linkedemail.LinkedEmailService
+	MailSyncScheduler  *mailsync.Scheduler
+	RetentionService   *retention.RetentionService
+	ThreadService      *thread.ThreadService
+	SearchService      *search.SearchService
+	SpamService        *spam.SpamService
+	SMTPServer         *smtpd.Server
 }
 
 func (p *Services) GetUserService() *user.UserService {
@@ -28,6 +51,30 @@ func (p *Services) GetMindAdvisorService() *mindadvisor.MindAdvisorService {
 	return p.MindAdvisorService
 }
 
+func (p *Services) GetMessageService() *message.MessageService {
+	return p.MessageService
+}
+
+func (p *Services) GetLinkedEmailService() *linkedemail.LinkedEmailService {
+	return p.LinkedEmailService
+}
+
+func (p *Services) GetMailSyncScheduler() *mailsync.Scheduler {
+	return p.MailSyncScheduler
+}
+
+func (p *Services) GetRetentionService() *retention.RetentionService {
+	return p.RetentionService
+}
+
+func (p *Services) GetThreadService() *thread.ThreadService {
+	return p.ThreadService
+}
+
+func (p *Services) GetSearchService() *search.SearchService {
+	return p.SearchService
+}
+
 // BuildBizServices 构建业务服务
 func BuildBizServices(ctx context.Context, services *app.Services[*appconfig.AppConfig]) (*Services, error) {
 	userService, err := user.New(services.DBClient.GetDB(), services.Snowflake)
@@ -35,12 +82,120 @@ func BuildBizServices(ctx context.Context, services *app.Services[*appconfig.App
 		return nil, err
 	}
 
-	mindAdvisorService := mindadvisor.New(services.DBClient.GetDB())
+	conf := services.AppConfigGetter.GetConfig()
+
+	mindAdvisorService := mindadvisor.New(services.DBClient.GetDB(), conf.GetMailboxConfig(), conf.GetBlocklistConfig())
+	// 通过 AWS AppConfig 加载配置时，屏蔽词规则随配置热更新
+	if loader, ok := services.AppConfigGetter.(*aws.AppConfigLoader[*appconfig.AppConfig]); ok {
+		loader.AddConfigListener(mindAdvisorService)
+	}
+
+	// 收件存储，原始邮件写入 S3
+	var storage message.ObjectStorage
+	if s3Conf := conf.GetS3Config(); s3Conf != nil {
+		s3Client, err := aws.NewS3(s3Conf)
+		if err != nil {
+			return nil, err
+		}
+		storage = s3Client
+	} else {
+		logger.Warnf("s3 not configured, message storage is disabled")
+	}
+	messageService := message.New(services.DBClient.GetDB(), storage, conf.GetMessageStoreConfig(), conf.GetQuotaConfig())
+	// 邮箱保留期到期后清除邮件与 S3 对象，存储未配置时清除会失败并等待重试
+	// 需最先注册：仍有法律保留的邮件时返回错误，会话、索引等后续数据不清除
+	mindAdvisorService.AddPurger(messageService)
+	// 邮件保留策略，到期邮件与附件由后台任务清理
+	retentionService := retention.New(services.DBClient.GetDB(), messageService, conf.GetRetentionConfig())
+	// 邮件会话，入库时增量分配，删除或清除邮件时更新会话摘要
+	threadService := thread.New(services.DBClient.GetDB(), messageService)
+	messageService.AddStoredListener(threadService)
+	messageService.AddRemovedListener(threadService)
+	mindAdvisorService.AddPurger(threadService)
+	// 邮件全文检索，入库时写入索引
+	searchService := search.New(services.DBClient.GetDB(), messageService)
+	messageService.AddStoredListener(searchService)
+	messageService.AddRemovedListener(searchService)
+	mindAdvisorService.AddPurger(searchService)
+	// 垃圾邮件评分，入库前评分，用户移动邮件时训练贝叶斯分类器
+	spamService := spam.New(services.DBClient.GetDB(), messageService, conf.GetSpamConfig(), nil)
+	messageService.SetSpamScorer(spamService)
+	messageService.AddFolderListener(spamService)
+	mindAdvisorService.AddPurger(spamService)
+
+	// 外部邮箱凭据加密，未配置密钥时无法绑定 IMAP 等需要凭据的邮箱
+	mailSyncConf := conf.GetMailSyncConfig()
+	var credentialBox *secretbox.Box
+	if mailSyncConf.CredentialKey != "" {
+		credentialBox, err = secretbox.New(mailSyncConf.CredentialKey)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		logger.Warnf("mail credential key not configured, imap linking is disabled")
+	}
+
+	// 外部邮箱绑定，收到转发确认邮件时完成验证
+	linkedEmailService := linkedemail.New(services.DBClient.GetDB(), services.RedisClient, mailSyncConf, credentialBox)
+	messageService.AddStoredListener(linkedEmailService)
+
+	// 外部邮箱同步
+	syncers := []mailsync.Syncer{
+		mailsync.NewIMAPSyncer(services.DBClient.GetDB(), mailSyncConf, credentialBox, messageService),
+	}
+	// Gmail 绑定，未配置 services.gmail 时禁用
+	if gmail := mailsync.NewGmailConnector(services.DBClient.GetDB(), conf.GetGmailConfig(), mailSyncConf, credentialBox, messageService); gmail != nil {
+		linkedEmailService.AddOAuthProvider(gmail)
+		syncers = append(syncers, gmail)
+	} else {
+		logger.Warnf("gmail not configured, gmail linking is disabled")
+	}
+	// Outlook 绑定，未配置 services.outlook 时禁用
+	if outlook := mailsync.NewOutlookConnector(services.DBClient.GetDB(), conf.GetOutlookConfig(), mailSyncConf, credentialBox, messageService); outlook != nil {
+		linkedEmailService.AddOAuthProvider(outlook)
+		syncers = append(syncers, outlook)
+	} else {
+		logger.Warnf("outlook not configured, outlook linking is disabled")
+	}
+	mailSyncScheduler := mailsync.NewScheduler(services.DBClient.GetDB(), services.RedisClient, mailSyncConf, syncers...)
+
+	// 内置 SMTP 收信服务，未配置 smtp 时处于禁用状态
+	smtpConf := conf.GetSMTPConfig()
+	var smtpDomains []string
+	if smtpConf != nil {
+		smtpDomains = smtpConf.Domains
+	}
+	// 标签规则转发使用外发中继，未配置 relay 时禁用
+	relayConf := conf.GetRelayConfig()
+	relayClient := relay.NewClient(relayConf)
+	if relayClient == nil {
+		logger.Warnf("relay not configured, tag rule forwarding is disabled")
+	}
+	inboundService := inbound.New(mindAdvisorService, messageService, relayClient, smtpDomains)
+	if relayConf != nil {
+		if relayConf.ForwardSecret == "" {
+			logger.Warnf("relay forward_secret not configured, tag rule forwarding is disabled")
+		}
+		inboundService.SetForwardSecret(relayConf.ForwardSecret)
+	}
+	if smtpConf != nil {
+		// 发信方认证检查，结果写入 Authentication-Results 头与邮件记录，用于提示伪造的发件人
+		inboundService.SetAuthVerifier(mailauth.NewVerifier(nil), smtpConf.Hostname, time.Duration(smtpConf.AuthTimeoutSeconds)*time.Second)
+	}
+	smtpServer := smtpd.NewServer(smtpConf, inboundService)
 
 	return &Services{
 		Services:           services,
 		UserService:        userService,
 		MindAdvisorService: mindAdvisorService,
+		MessageService:     messageService,
+		LinkedEmailService: linkedEmailService,
+		MailSyncScheduler:  mailSyncScheduler,
+		RetentionService:   retentionService,
+		ThreadService:      threadService,
+		SearchService:      searchService,
+		SpamService:        spamService,
+		SMTPServer:         smtpServer,
 	}, nil
 }
 
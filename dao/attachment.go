@@ -0,0 +1,104 @@
+package dao
+
+import (
+	"context"
+	"errors"
+
+	datamodel "plaud-emails/data/model"
+
+	"gorm.io/gorm"
+)
+
+// AttachmentDao 邮件附件 DAO
+type AttachmentDao struct {
+	db *gorm.DB
+}
+
+// NewAttachmentDao 创建 AttachmentDao
+func NewAttachmentDao(db *gorm.DB) *AttachmentDao {
+	return &AttachmentDao{db: db}
+}
+
+// CreateBatch 批量创建附件记录
+func (d *AttachmentDao) CreateBatch(ctx context.Context, attachments []*datamodel.Attachment) error {
+	if len(attachments) == 0 {
+		return nil
+	}
+	return d.db.WithContext(ctx).Create(&attachments).Error
+}
+
+// GetByMessageIDAndID 查询用户一封邮件的附件
+func (d *AttachmentDao) GetByMessageIDAndID(ctx context.Context, userID string, messageID, id uint64) (*datamodel.Attachment, error) {
+	var attachment datamodel.Attachment
+	err := d.db.WithContext(ctx).
+		Where("id = ? AND message_id = ? AND user_id = ?", id, messageID, userID).
+		Take(&attachment).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &attachment, nil
+}
+
+// ListByMessageID 按序号查询一封邮件的附件
+func (d *AttachmentDao) ListByMessageID(ctx context.Context, userID string, messageID uint64) ([]*datamodel.Attachment, error) {
+	var attachments []*datamodel.Attachment
+	err := d.db.WithContext(ctx).
+		Where("message_id = ? AND user_id = ?", messageID, userID).
+		Order("part_index ASC").Find(&attachments).Error
+	if err != nil {
+		return nil, err
+	}
+	return attachments, nil
+}
+
+// ExistsByMessageID 一封邮件是否已有附件记录
+func (d *AttachmentDao) ExistsByMessageID(ctx context.Context, messageID uint64) (bool, error) {
+	var count int64
+	err := d.db.WithContext(ctx).Model(&datamodel.Attachment{}).
+		Where("message_id = ?", messageID).Limit(1).Count(&count).Error
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// GetBySHA256 查询用户引用同一内容的任一附件记录
+func (d *AttachmentDao) GetBySHA256(ctx context.Context, userID, sha256 string) (*datamodel.Attachment, error) {
+	var attachment datamodel.Attachment
+	err := d.db.WithContext(ctx).Where("user_id = ? AND sha256 = ?", userID, sha256).Take(&attachment).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &attachment, nil
+}
+
+// DeleteByIDs 删除附件记录
+func (d *AttachmentDao) DeleteByIDs(ctx context.Context, ids []uint64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return d.db.WithContext(ctx).Where("id IN ?", ids).Delete(&datamodel.Attachment{}).Error
+}
+
+// ListByUserID 按 id 升序分页查询用户的附件
+func (d *AttachmentDao) ListByUserID(ctx context.Context, userID string, afterID uint64, limit int) ([]*datamodel.Attachment, error) {
+	var attachments []*datamodel.Attachment
+	err := d.db.WithContext(ctx).
+		Where("user_id = ? AND id > ?", userID, afterID).
+		Order("id ASC").Limit(limit).Find(&attachments).Error
+	if err != nil {
+		return nil, err
+	}
+	return attachments, nil
+}
+
+// DeleteByUserID 删除用户的全部附件记录
+func (d *AttachmentDao) DeleteByUserID(ctx context.Context, userID string) error {
+	return d.db.WithContext(ctx).Where("user_id = ?", userID).Delete(&datamodel.Attachment{}).Error
+}
@@ -0,0 +1,43 @@
+package dao
+
+import (
+	"context"
+
+	datamodel "plaud-emails/data/model"
+
+	"gorm.io/gorm"
+)
+
+// LinkedEmailSyncEventDao 绑定邮箱同步状态迁移记录 DAO
+type LinkedEmailSyncEventDao struct {
+	db *gorm.DB
+}
+
+// NewLinkedEmailSyncEventDao 创建 LinkedEmailSyncEventDao
+func NewLinkedEmailSyncEventDao(db *gorm.DB) *LinkedEmailSyncEventDao {
+	return &LinkedEmailSyncEventDao{db: db}
+}
+
+// Create 创建迁移记录
+func (d *LinkedEmailSyncEventDao) Create(ctx context.Context, event *datamodel.LinkedEmailSyncEvent) error {
+	return d.db.WithContext(ctx).Create(event).Error
+}
+
+// ListByLinkedEmailID 按 id 倒序分页查询绑定邮箱的迁移记录，beforeID 为 0 时从最新开始
+func (d *LinkedEmailSyncEventDao) ListByLinkedEmailID(ctx context.Context, linkedEmailID, beforeID uint64, limit int) ([]*datamodel.LinkedEmailSyncEvent, error) {
+	var events []*datamodel.LinkedEmailSyncEvent
+	query := d.db.WithContext(ctx).Where("linked_email_id = ?", linkedEmailID)
+	if beforeID > 0 {
+		query = query.Where("id < ?", beforeID)
+	}
+	err := query.Order("id DESC").Limit(limit).Find(&events).Error
+	if err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// DeleteByUserID 删除用户全部绑定邮箱的迁移记录
+func (d *LinkedEmailSyncEventDao) DeleteByUserID(ctx context.Context, userID string) error {
+	return d.db.WithContext(ctx).Where("user_id = ?", userID).Delete(&datamodel.LinkedEmailSyncEvent{}).Error
+}
@@ -0,0 +1,75 @@
+package dao
+
+import (
+	"context"
+	"errors"
+
+	datamodel "plaud-emails/data/model"
+
+	"gorm.io/gorm"
+)
+
+// MailboxBlocklistRuleDao local_part 屏蔽规则 DAO
+type MailboxBlocklistRuleDao struct {
+	db *gorm.DB
+}
+
+// NewMailboxBlocklistRuleDao 创建 MailboxBlocklistRuleDao
+func NewMailboxBlocklistRuleDao(db *gorm.DB) *MailboxBlocklistRuleDao {
+	return &MailboxBlocklistRuleDao{db: db}
+}
+
+// Create 创建规则
+func (d *MailboxBlocklistRuleDao) Create(ctx context.Context, rule *datamodel.MailboxBlocklistRule) error {
+	return d.db.WithContext(ctx).Create(rule).Error
+}
+
+// Update 更新规则
+func (d *MailboxBlocklistRuleDao) Update(ctx context.Context, rule *datamodel.MailboxBlocklistRule) error {
+	return d.db.WithContext(ctx).Save(rule).Error
+}
+
+// GetByID 根据 id 查询
+func (d *MailboxBlocklistRuleDao) GetByID(ctx context.Context, id uint64) (*datamodel.MailboxBlocklistRule, error) {
+	var rule datamodel.MailboxBlocklistRule
+	err := d.db.WithContext(ctx).Where("id = ?", id).Take(&rule).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &rule, nil
+}
+
+// GetByMatchAndPattern 根据匹配方式与模式查询
+func (d *MailboxBlocklistRuleDao) GetByMatchAndPattern(ctx context.Context, match, pattern string) (*datamodel.MailboxBlocklistRule, error) {
+	var rule datamodel.MailboxBlocklistRule
+	err := d.db.WithContext(ctx).Where("match_type = ? AND pattern = ?", match, pattern).Take(&rule).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &rule, nil
+}
+
+// List 查询全部规则，按 id 排序
+func (d *MailboxBlocklistRuleDao) List(ctx context.Context) ([]*datamodel.MailboxBlocklistRule, error) {
+	var rules []*datamodel.MailboxBlocklistRule
+	err := d.db.WithContext(ctx).Order("id ASC").Find(&rules).Error
+	if err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+// DeleteByID 删除规则，返回是否删除了记录
+func (d *MailboxBlocklistRuleDao) DeleteByID(ctx context.Context, id uint64) (bool, error) {
+	result := d.db.WithContext(ctx).Where("id = ?", id).Delete(&datamodel.MailboxBlocklistRule{})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
@@ -0,0 +1,123 @@
+package dao
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	datamodel "plaud-emails/data/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// MailboxUsageDao 专属邮箱存储用量 DAO
+type MailboxUsageDao struct {
+	db *gorm.DB
+}
+
+// NewMailboxUsageDao 创建 MailboxUsageDao
+func NewMailboxUsageDao(db *gorm.DB) *MailboxUsageDao {
+	return &MailboxUsageDao{db: db}
+}
+
+// GetByUserID 根据 user_id 查询
+func (d *MailboxUsageDao) GetByUserID(ctx context.Context, userID string) (*datamodel.MailboxUsage, error) {
+	var usage datamodel.MailboxUsage
+	err := d.db.WithContext(ctx).Where("user_id = ?", userID).Take(&usage).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &usage, nil
+}
+
+// GetByUserIDForUpdate 根据 user_id 查询并加行锁，需在事务中调用
+func (d *MailboxUsageDao) GetByUserIDForUpdate(ctx context.Context, userID string) (*datamodel.MailboxUsage, error) {
+	var usage datamodel.MailboxUsage
+	err := d.db.WithContext(ctx).Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("user_id = ?", userID).Take(&usage).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &usage, nil
+}
+
+// Add 累加用量，记录不存在时创建，bytes 与 messages 可以为负数
+func (d *MailboxUsageDao) Add(ctx context.Context, userID string, bytes, messages int64) error {
+	usage := &datamodel.MailboxUsage{UserID: userID, Bytes: bytes, Messages: messages}
+	return d.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.Assignments(map[string]any{
+			"bytes":      gorm.Expr("GREATEST(bytes + ?, 0)", bytes),
+			"messages":   gorm.Expr("GREATEST(messages + ?, 0)", messages),
+			"updated_at": time.Now(),
+		}),
+	}).Create(usage).Error
+}
+
+// SetUsage 写入核算后的用量，记录不存在时创建
+func (d *MailboxUsageDao) SetUsage(ctx context.Context, userID string, bytes, messages int64, reconciledAt time.Time) error {
+	usage := &datamodel.MailboxUsage{UserID: userID, Bytes: bytes, Messages: messages, ReconciledAt: &reconciledAt}
+	return d.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"bytes", "messages", "reconciled_at", "updated_at"}),
+	}).Create(usage).Error
+}
+
+// MarkWarned 仅当尚未提醒时记录提醒时间，返回是否由本次调用标记
+func (d *MailboxUsageDao) MarkWarned(ctx context.Context, userID string, at time.Time) (bool, error) {
+	result := d.db.WithContext(ctx).Model(&datamodel.MailboxUsage{}).
+		Where("user_id = ? AND warned_at IS NULL", userID).
+		Update("warned_at", at)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// ClearWarned 清空提醒时间，用量回落到软限额以下后再次超出时重新提醒
+func (d *MailboxUsageDao) ClearWarned(ctx context.Context, userID string) error {
+	return d.db.WithContext(ctx).Model(&datamodel.MailboxUsage{}).
+		Where("user_id = ? AND warned_at IS NOT NULL", userID).
+		Update("warned_at", nil).Error
+}
+
+// ListReconcileDue 查询 before 之前未核算过的用量记录，从未核算的排在最前
+func (d *MailboxUsageDao) ListReconcileDue(ctx context.Context, before time.Time, limit int) ([]*datamodel.MailboxUsage, error) {
+	var usages []*datamodel.MailboxUsage
+	err := d.db.WithContext(ctx).
+		Where("reconciled_at IS NULL OR reconciled_at < ?", before).
+		Order("reconciled_at ASC").
+		Limit(limit).
+		Find(&usages).Error
+	if err != nil {
+		return nil, err
+	}
+	return usages, nil
+}
+
+// ClaimReconcile 仅当 reconciled_at 未被其他副本修改时更新为 at，用于多副本间抢占核算任务
+func (d *MailboxUsageDao) ClaimReconcile(ctx context.Context, usage *datamodel.MailboxUsage, at time.Time) (bool, error) {
+	query := d.db.WithContext(ctx).Model(&datamodel.MailboxUsage{}).Where("id = ?", usage.ID)
+	if usage.ReconciledAt == nil {
+		query = query.Where("reconciled_at IS NULL")
+	} else {
+		query = query.Where("reconciled_at = ?", *usage.ReconciledAt)
+	}
+	result := query.Update("reconciled_at", at)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// DeleteByUserID 删除用户的用量记录
+func (d *MailboxUsageDao) DeleteByUserID(ctx context.Context, userID string) error {
+	return d.db.WithContext(ctx).Where("user_id = ?", userID).Delete(&datamodel.MailboxUsage{}).Error
+}
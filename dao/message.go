@@ -0,0 +1,473 @@
+package dao
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	datamodel "plaud-emails/data/model"
+
+	"gorm.io/gorm"
+)
+
+// MessageDao 收件 DAO
+type MessageDao struct {
+	db *gorm.DB
+}
+
+// NewMessageDao 创建 MessageDao
+func NewMessageDao(db *gorm.DB) *MessageDao {
+	return &MessageDao{db: db}
+}
+
+// Create 创建邮件记录
+func (d *MessageDao) Create(ctx context.Context, msg *datamodel.Message) error {
+	return d.db.WithContext(ctx).Create(msg).Error
+}
+
+// GetByUserIDAndID 根据 user_id 和 id 查询，保证只能访问自己的邮件
+func (d *MessageDao) GetByUserIDAndID(ctx context.Context, userID string, id uint64) (*datamodel.Message, error) {
+	var msg datamodel.Message
+	err := d.db.WithContext(ctx).
+		Where("id = ? AND user_id = ? AND status = ?", id, userID, datamodel.MessageStatusActive).
+		Take(&msg).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &msg, nil
+}
+
+// MessageFilter 邮件列表过滤条件
+type MessageFilter struct {
+	// Tag 只查询投递到该子地址标签的邮件
+	Tag string
+	// IncludeMuted 是否包含被标签规则静音的邮件
+	IncludeMuted bool
+	// Folder 只查询该系统文件夹中的邮件，为空时查询垃圾箱与垃圾邮件以外的邮件
+	Folder string
+	// LabelID 只查询带有该用户标签的邮件
+	LabelID uint64
+}
+
+// ListByUserID 按 id 倒序分页查询用户的邮件，beforeID 为 0 时从最新开始，filter 为 nil 时不包含静音邮件
+func (d *MessageDao) ListByUserID(ctx context.Context, userID string, filter *MessageFilter, beforeID uint64, limit int) ([]*datamodel.Message, error) {
+	var msgs []*datamodel.Message
+	query := d.db.WithContext(ctx).Where("user_id = ? AND status = ?", userID, datamodel.MessageStatusActive)
+	if filter == nil {
+		filter = &MessageFilter{}
+	}
+	if filter.Tag != "" {
+		query = query.Where("tag = ?", filter.Tag)
+	}
+	if !filter.IncludeMuted {
+		query = query.Where("muted = ?", false)
+	}
+	if filter.Folder != "" {
+		query = query.Where("folder = ?", filter.Folder)
+	} else {
+		query = query.Where("folder NOT IN ?", []string{datamodel.FolderTrash, datamodel.FolderSpam})
+	}
+	if filter.LabelID > 0 {
+		query = query.Where("EXISTS (SELECT 1 FROM mind_advisor_message_labels ml WHERE ml.message_id = mind_advisor_messages.id AND ml.label_id = ?)", filter.LabelID)
+	}
+	if beforeID > 0 {
+		query = query.Where("id < ?", beforeID)
+	}
+	err := query.Order("id DESC").Limit(limit).Find(&msgs).Error
+	if err != nil {
+		return nil, err
+	}
+	return msgs, nil
+}
+
+// ExistsByExternalID 检查外部邮箱同步的邮件是否已入库
+func (d *MessageDao) ExistsByExternalID(ctx context.Context, linkedEmailID uint64, externalID string) (bool, error) {
+	var count int64
+	err := d.db.WithContext(ctx).Model(&datamodel.Message{}).
+		Where("linked_email_id = ? AND external_id = ?", linkedEmailID, externalID).
+		Count(&count).Error
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// ExistsDelivered 检查 since 之后是否已有相同投递幂等键的收信邮件
+func (d *MessageDao) ExistsDelivered(ctx context.Context, userID, externalID string, since time.Time) (bool, error) {
+	var count int64
+	err := d.db.WithContext(ctx).Model(&datamodel.Message{}).
+		Where("linked_email_id = 0 AND external_id = ? AND user_id = ? AND received_at >= ?", externalID, userID, since).
+		Count(&count).Error
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// SumByUserID 统计用户未删除邮件的总字节数与数量，系统通知不计入配额
+func (d *MessageDao) SumByUserID(ctx context.Context, userID string) (bytes, count int64, err error) {
+	var row struct {
+		Bytes int64
+		Count int64
+	}
+	err = d.db.WithContext(ctx).Model(&datamodel.Message{}).
+		Select("COALESCE(SUM(size), 0) AS bytes, COUNT(*) AS count").
+		Where("user_id = ? AND status = ? AND source <> ?", userID, datamodel.MessageStatusActive, datamodel.MessageSourceSystem).
+		Scan(&row).Error
+	if err != nil {
+		return 0, 0, err
+	}
+	return row.Bytes, row.Count, nil
+}
+
+// ListUsersWithoutUsage 查询有未删除邮件但没有用量记录的用户，系统通知不计入
+func (d *MessageDao) ListUsersWithoutUsage(ctx context.Context, limit int) ([]string, error) {
+	var userIDs []string
+	err := d.db.WithContext(ctx).Table(datamodel.Message{}.TableName()+" m").
+		Joins("LEFT JOIN "+datamodel.MailboxUsage{}.TableName()+" u ON u.user_id = m.user_id").
+		Where("m.status = ? AND m.source <> ? AND u.id IS NULL", datamodel.MessageStatusActive, datamodel.MessageSourceSystem).
+		Distinct().Limit(limit).
+		Pluck("m.user_id", &userIDs).Error
+	if err != nil {
+		return nil, err
+	}
+	return userIDs, nil
+}
+
+// ListActiveAfterID 按 id 升序分页查询用户未删除的邮件
+func (d *MessageDao) ListActiveAfterID(ctx context.Context, userID string, afterID uint64, limit int) ([]*datamodel.Message, error) {
+	var msgs []*datamodel.Message
+	err := d.db.WithContext(ctx).
+		Where("user_id = ? AND status = ? AND id > ?", userID, datamodel.MessageStatusActive, afterID).
+		Order("id ASC").Limit(limit).Find(&msgs).Error
+	if err != nil {
+		return nil, err
+	}
+	return msgs, nil
+}
+
+// MarkDeleted 将用户的邮件标记为已删除并记录 modseq，返回是否更新了记录
+func (d *MessageDao) MarkDeleted(ctx context.Context, userID string, id, modSeq uint64) (bool, error) {
+	result := d.db.WithContext(ctx).Model(&datamodel.Message{}).
+		Where("id = ? AND user_id = ? AND status = ?", id, userID, datamodel.MessageStatusActive).
+		Updates(map[string]any{"status": datamodel.MessageStatusDeleted, "mod_seq": modSeq})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// UpdateSize 更新邮件大小
+func (d *MessageDao) UpdateSize(ctx context.Context, id uint64, size int64) error {
+	return d.db.WithContext(ctx).Model(&datamodel.Message{}).Where("id = ?", id).Update("size", size).Error
+}
+
+// RetentionQuery 保留期到期邮件的查询条件
+type RetentionQuery struct {
+	// UserID 只查询该用户的邮件，为空时查询全部用户
+	UserID string
+	// ExcludeUserIDs 排除设置了自定义保留策略的用户
+	ExcludeUserIDs []string
+	// Before 收到时间早于该时间的邮件到期
+	Before time.Time
+	// Attachments 为 true 时只查询附件尚未移除的邮件
+	Attachments bool
+}
+
+// ListExpired 按 id 升序分页查询保留期已到的邮件，邮件或所属用户处于法律保留时不返回
+func (d *MessageDao) ListExpired(ctx context.Context, q *RetentionQuery, afterID uint64, limit int) ([]*datamodel.Message, error) {
+	var msgs []*datamodel.Message
+	query := d.db.WithContext(ctx).Scopes(notOnLegalHold).
+		Where("status = ? AND received_at < ? AND id > ?", datamodel.MessageStatusActive, q.Before, afterID)
+	if q.UserID != "" {
+		query = query.Where("user_id = ?", q.UserID)
+	}
+	if len(q.ExcludeUserIDs) > 0 {
+		query = query.Where("user_id NOT IN ?", q.ExcludeUserIDs)
+	}
+	if q.Attachments {
+		query = query.Where("has_attachments = ? AND attachments_purged_at IS NULL", true)
+	}
+	err := query.Order("id ASC").Limit(limit).Find(&msgs).Error
+	if err != nil {
+		return nil, err
+	}
+	return msgs, nil
+}
+
+// MarkExpired 将保留期到期的邮件标记为已删除并记录清除时间，返回是否由本次调用标记
+// 设置了法律保留的邮件不会被标记
+func (d *MessageDao) MarkExpired(ctx context.Context, id uint64, at time.Time, modSeq uint64) (bool, error) {
+	result := d.db.WithContext(ctx).Model(&datamodel.Message{}).Scopes(notOnLegalHold).
+		Where("id = ? AND status = ?", id, datamodel.MessageStatusActive).
+		Updates(map[string]any{"status": datamodel.MessageStatusDeleted, "expired_at": at, "mod_seq": modSeq})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// ReplaceStrippedObject 将邮件指向移除附件后的新对象并更新 modseq，仅当对象未被其他副本替换时更新，返回是否更新了记录
+// 设置了法律保留的邮件不会被更新
+func (d *MessageDao) ReplaceStrippedObject(ctx context.Context, id uint64, oldKey, newKey string, size int64, at time.Time, modSeq uint64) (bool, error) {
+	result := d.db.WithContext(ctx).Model(&datamodel.Message{}).Scopes(notOnLegalHold).
+		Where("id = ? AND status = ? AND s3_key = ?", id, datamodel.MessageStatusActive, oldKey).
+		Updates(map[string]any{
+			"s3_key":                newKey,
+			"size":                  size,
+			"has_attachments":       false,
+			"attachments_purged_at": at,
+			"mod_seq":               modSeq,
+		})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// MarkAttachmentsPurged 记录附件保留期已处理，用于没有可移除附件的邮件
+func (d *MessageDao) MarkAttachmentsPurged(ctx context.Context, id uint64, at time.Time) error {
+	return d.db.WithContext(ctx).Model(&datamodel.Message{}).
+		Where("id = ? AND attachments_purged_at IS NULL", id).
+		Update("attachments_purged_at", at).Error
+}
+
+// SetLegalHold 设置或解除用户一封邮件的法律保留，返回是否找到了记录
+func (d *MessageDao) SetLegalHold(ctx context.Context, userID string, id uint64, hold bool) (bool, error) {
+	result := d.db.WithContext(ctx).Model(&datamodel.Message{}).
+		Where("id = ? AND user_id = ? AND status = ?", id, userID, datamodel.MessageStatusActive).
+		Update("legal_hold", hold)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	if result.RowsAffected > 0 {
+		return true, nil
+	}
+	// 取值未变化时 RowsAffected 为 0，需确认记录是否存在
+	msg, err := d.GetByUserIDAndID(ctx, userID, id)
+	if err != nil {
+		return false, err
+	}
+	return msg != nil, nil
+}
+
+// notOnLegalHold 排除自身或所属用户处于法律保留状态的邮件
+func notOnLegalHold(db *gorm.DB) *gorm.DB {
+	return db.Where("legal_hold = ?", false).
+		Where("NOT EXISTS (SELECT 1 FROM users_mind_advisor u WHERE u.user_id = mind_advisor_messages.user_id AND u.legal_hold = ?)", true)
+}
+
+// SetThreadID 设置邮件所属的会话
+func (d *MessageDao) SetThreadID(ctx context.Context, id, threadID uint64) error {
+	return d.db.WithContext(ctx).Model(&datamodel.Message{}).Where("id = ?", id).Update("thread_id", threadID).Error
+}
+
+// MoveThread 将用户一个会话中的邮件移动到另一个会话，用于合并会话
+func (d *MessageDao) MoveThread(ctx context.Context, userID string, from, to uint64) error {
+	return d.db.WithContext(ctx).Model(&datamodel.Message{}).
+		Where("user_id = ? AND thread_id = ?", userID, from).
+		Update("thread_id", to).Error
+}
+
+// ListByThreadID 按 id 倒序查询会话中未删除的邮件
+func (d *MessageDao) ListByThreadID(ctx context.Context, userID string, threadID uint64, limit int) ([]*datamodel.Message, error) {
+	var msgs []*datamodel.Message
+	err := d.db.WithContext(ctx).
+		Where("user_id = ? AND thread_id = ? AND status = ?", userID, threadID, datamodel.MessageStatusActive).
+		Order("id DESC").Limit(limit).Find(&msgs).Error
+	if err != nil {
+		return nil, err
+	}
+	return msgs, nil
+}
+
+// CountByThreadID 统计会话中未删除的邮件数
+func (d *MessageDao) CountByThreadID(ctx context.Context, userID string, threadID uint64) (int64, error) {
+	var count int64
+	err := d.db.WithContext(ctx).Model(&datamodel.Message{}).
+		Where("user_id = ? AND thread_id = ? AND status = ?", userID, threadID, datamodel.MessageStatusActive).
+		Count(&count).Error
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// ListUnthreaded 按 id 升序分页查询用户尚未分配会话的邮件
+func (d *MessageDao) ListUnthreaded(ctx context.Context, userID string, afterID uint64, limit int) ([]*datamodel.Message, error) {
+	var msgs []*datamodel.Message
+	err := d.db.WithContext(ctx).
+		Where("user_id = ? AND thread_id = 0 AND status = ? AND id > ?", userID, datamodel.MessageStatusActive, afterID).
+		Order("id ASC").Limit(limit).Find(&msgs).Error
+	if err != nil {
+		return nil, err
+	}
+	return msgs, nil
+}
+
+// UpdateReferences 回填邮件的 In-Reply-To 与 References 索引
+func (d *MessageDao) UpdateReferences(ctx context.Context, id uint64, inReplyTo, referenceIDs string) error {
+	return d.db.WithContext(ctx).Model(&datamodel.Message{}).Where("id = ?", id).
+		Updates(map[string]any{"in_reply_to": inReplyTo, "reference_ids": referenceIDs}).Error
+}
+
+// ListByIDs 根据 id 批量查询用户未删除的邮件
+func (d *MessageDao) ListByIDs(ctx context.Context, userID string, ids []uint64) ([]*datamodel.Message, error) {
+	var msgs []*datamodel.Message
+	if len(ids) == 0 {
+		return msgs, nil
+	}
+	err := d.db.WithContext(ctx).
+		Where("user_id = ? AND id IN ? AND status = ?", userID, ids, datamodel.MessageStatusActive).
+		Find(&msgs).Error
+	if err != nil {
+		return nil, err
+	}
+	return msgs, nil
+}
+
+// ListUnindexed 按 id 升序分页查询用户尚未写入全文索引的邮件
+func (d *MessageDao) ListUnindexed(ctx context.Context, userID string, afterID uint64, limit int) ([]*datamodel.Message, error) {
+	var msgs []*datamodel.Message
+	err := d.db.WithContext(ctx).
+		Where("user_id = ? AND status = ? AND id > ?", userID, datamodel.MessageStatusActive, afterID).
+		Where("NOT EXISTS (SELECT 1 FROM mind_advisor_message_search s WHERE s.id = mind_advisor_messages.id)").
+		Order("id ASC").Limit(limit).Find(&msgs).Error
+	if err != nil {
+		return nil, err
+	}
+	return msgs, nil
+}
+
+// ListActiveIDs 返回 ids 中属于用户且未删除的邮件 id
+func (d *MessageDao) ListActiveIDs(ctx context.Context, userID string, ids []uint64) ([]uint64, error) {
+	var found []uint64
+	if len(ids) == 0 {
+		return found, nil
+	}
+	err := d.db.WithContext(ctx).Model(&datamodel.Message{}).
+		Where("user_id = ? AND id IN ? AND status = ?", userID, ids, datamodel.MessageStatusActive).
+		Order("id ASC").Pluck("id", &found).Error
+	if err != nil {
+		return nil, err
+	}
+	return found, nil
+}
+
+// MessageStateUpdate 邮件状态更新，nil 或空值的字段不修改
+type MessageStateUpdate struct {
+	Seen      *bool
+	Starred   *bool
+	Important *bool
+	Folder    string
+}
+
+// UpdateState 更新用户邮件的标记与文件夹并记录 modseq，返回更新的邮件数
+func (d *MessageDao) UpdateState(ctx context.Context, userID string, ids []uint64, update *MessageStateUpdate, modSeq uint64) (int64, error) {
+	values := map[string]any{"mod_seq": modSeq}
+	if update.Seen != nil {
+		values["seen"] = *update.Seen
+	}
+	if update.Starred != nil {
+		values["starred"] = *update.Starred
+	}
+	if update.Important != nil {
+		values["important"] = *update.Important
+	}
+	if update.Folder != "" {
+		values["folder"] = update.Folder
+	}
+	result := d.db.WithContext(ctx).Model(&datamodel.Message{}).
+		Where("user_id = ? AND id IN ? AND status = ?", userID, ids, datamodel.MessageStatusActive).
+		Updates(values)
+	if result.Error != nil {
+		return 0, result.Error
+	}
+	return result.RowsAffected, nil
+}
+
+// TouchByLabelID 更新带有某个用户标签的邮件的 modseq，用于删除标签前通知客户端
+func (d *MessageDao) TouchByLabelID(ctx context.Context, userID string, labelID, modSeq uint64) error {
+	return d.db.WithContext(ctx).Model(&datamodel.Message{}).
+		Where("user_id = ? AND id IN (SELECT message_id FROM mind_advisor_message_labels WHERE label_id = ?)", userID, labelID).
+		Update("mod_seq", modSeq).Error
+}
+
+// ListChanged 按 (mod_seq, id) 升序查询 modseq 大于 sinceModSeq 的邮件，包括已删除的记录
+// afterModSeq 与 afterID 为上一页最后一封邮件的位置，afterID 为 0 时从头开始
+func (d *MessageDao) ListChanged(ctx context.Context, userID string, sinceModSeq, afterModSeq, afterID uint64, limit int) ([]*datamodel.Message, error) {
+	var msgs []*datamodel.Message
+	query := d.db.WithContext(ctx).Where("user_id = ? AND mod_seq > ?", userID, sinceModSeq)
+	if afterID > 0 {
+		query = query.Where("(mod_seq > ? OR (mod_seq = ? AND id > ?))", afterModSeq, afterModSeq, afterID)
+	}
+	err := query.Order("mod_seq ASC, id ASC").Limit(limit).Find(&msgs).Error
+	if err != nil {
+		return nil, err
+	}
+	return msgs, nil
+}
+
+// ListForPurge 按 id 升序查询用户的邮件，包括已删除的记录，用于清除数据
+// 设置了法律保留的邮件不返回
+func (d *MessageDao) ListForPurge(ctx context.Context, userID string, limit int) ([]*datamodel.Message, error) {
+	var msgs []*datamodel.Message
+	err := d.db.WithContext(ctx).Scopes(notOnLegalHold).Where("user_id = ?", userID).Order("id ASC").Limit(limit).Find(&msgs).Error
+	if err != nil {
+		return nil, err
+	}
+	return msgs, nil
+}
+
+// CountOnLegalHold 统计用户设置了法律保留的邮件数量，包括已删除的记录
+func (d *MessageDao) CountOnLegalHold(ctx context.Context, userID string) (int64, error) {
+	var count int64
+	err := d.db.WithContext(ctx).Model(&datamodel.Message{}).
+		Where("user_id = ? AND legal_hold = ?", userID, true).
+		Count(&count).Error
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// DeleteByIDs 物理删除邮件记录
+func (d *MessageDao) DeleteByIDs(ctx context.Context, ids []uint64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return d.db.WithContext(ctx).Where("id IN ?", ids).Delete(&datamodel.Message{}).Error
+}
+
+// ExecTx 执行事务
+func (d *MessageDao) ExecTx(ctx context.Context, fn func(tx *gorm.DB) error) error {
+	return d.db.WithContext(ctx).Transaction(fn)
+}
+
+// ListUnextracted 按 id 升序分页查询用户带附件但尚未拆分附件的邮件，附件已被保留策略移除的邮件除外
+func (d *MessageDao) ListUnextracted(ctx context.Context, userID string, afterID uint64, limit int) ([]*datamodel.Message, error) {
+	var msgs []*datamodel.Message
+	err := d.db.WithContext(ctx).
+		Where("user_id = ? AND status = ? AND id > ? AND has_attachments = ? AND attachments_purged_at IS NULL",
+			userID, datamodel.MessageStatusActive, afterID, true).
+		Where("NOT EXISTS (SELECT 1 FROM mind_advisor_attachments a WHERE a.message_id = mind_advisor_messages.id)").
+		Order("id ASC").Limit(limit).Find(&msgs).Error
+	if err != nil {
+		return nil, err
+	}
+	return msgs, nil
+}
+
+// SetSpamTrained 将邮件的训练分类从 from 改为 to，返回是否由本次调用修改，用于避免重复训练
+func (d *MessageDao) SetSpamTrained(ctx context.Context, id uint64, from, to int8) (bool, error) {
+	result := d.db.WithContext(ctx).Model(&datamodel.Message{}).
+		Where("id = ? AND spam_trained = ?", id, from).
+		Update("spam_trained", to)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
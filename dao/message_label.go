@@ -0,0 +1,164 @@
+package dao
+
+import (
+	"context"
+	"errors"
+
+	datamodel "plaud-emails/data/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// UserLabelDao 用户标签 DAO
+type UserLabelDao struct {
+	db *gorm.DB
+}
+
+// NewUserLabelDao 创建 UserLabelDao
+func NewUserLabelDao(db *gorm.DB) *UserLabelDao {
+	return &UserLabelDao{db: db}
+}
+
+// Create 创建标签
+func (d *UserLabelDao) Create(ctx context.Context, label *datamodel.UserLabel) error {
+	return d.db.WithContext(ctx).Create(label).Error
+}
+
+// Update 更新标签
+func (d *UserLabelDao) Update(ctx context.Context, label *datamodel.UserLabel) error {
+	return d.db.WithContext(ctx).Save(label).Error
+}
+
+// GetByUserIDAndID 根据 user_id 和 id 查询
+func (d *UserLabelDao) GetByUserIDAndID(ctx context.Context, userID string, id uint64) (*datamodel.UserLabel, error) {
+	var label datamodel.UserLabel
+	err := d.db.WithContext(ctx).Where("id = ? AND user_id = ?", id, userID).Take(&label).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &label, nil
+}
+
+// GetByName 根据名称查询用户的标签
+func (d *UserLabelDao) GetByName(ctx context.Context, userID, name string) (*datamodel.UserLabel, error) {
+	var label datamodel.UserLabel
+	err := d.db.WithContext(ctx).Where("user_id = ? AND name = ?", userID, name).Take(&label).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &label, nil
+}
+
+// ListByUserID 查询用户的全部标签
+func (d *UserLabelDao) ListByUserID(ctx context.Context, userID string) ([]*datamodel.UserLabel, error) {
+	var labels []*datamodel.UserLabel
+	err := d.db.WithContext(ctx).Where("user_id = ?", userID).Order("id ASC").Find(&labels).Error
+	if err != nil {
+		return nil, err
+	}
+	return labels, nil
+}
+
+// CountByIDs 统计 ids 中属于用户的标签数量
+func (d *UserLabelDao) CountByIDs(ctx context.Context, userID string, ids []uint64) (int64, error) {
+	var count int64
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	err := d.db.WithContext(ctx).Model(&datamodel.UserLabel{}).
+		Where("user_id = ? AND id IN ?", userID, ids).Count(&count).Error
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// CountByUserID 统计用户的标签数量
+func (d *UserLabelDao) CountByUserID(ctx context.Context, userID string) (int64, error) {
+	var count int64
+	err := d.db.WithContext(ctx).Model(&datamodel.UserLabel{}).Where("user_id = ?", userID).Count(&count).Error
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// DeleteByID 删除标签
+func (d *UserLabelDao) DeleteByID(ctx context.Context, id uint64) error {
+	return d.db.WithContext(ctx).Where("id = ?", id).Delete(&datamodel.UserLabel{}).Error
+}
+
+// DeleteByUserID 删除用户的全部标签
+func (d *UserLabelDao) DeleteByUserID(ctx context.Context, userID string) error {
+	return d.db.WithContext(ctx).Where("user_id = ?", userID).Delete(&datamodel.UserLabel{}).Error
+}
+
+// ExecTx 执行事务
+func (d *UserLabelDao) ExecTx(ctx context.Context, fn func(tx *gorm.DB) error) error {
+	return d.db.WithContext(ctx).Transaction(fn)
+}
+
+// MessageLabelDao 邮件标签关联 DAO
+type MessageLabelDao struct {
+	db *gorm.DB
+}
+
+// NewMessageLabelDao 创建 MessageLabelDao
+func NewMessageLabelDao(db *gorm.DB) *MessageLabelDao {
+	return &MessageLabelDao{db: db}
+}
+
+// Add 为一组邮件添加一组标签，已存在的关联忽略
+func (d *MessageLabelDao) Add(ctx context.Context, userID string, messageIDs, labelIDs []uint64) error {
+	if len(messageIDs) == 0 || len(labelIDs) == 0 {
+		return nil
+	}
+	rows := make([]*datamodel.MessageLabel, 0, len(messageIDs)*len(labelIDs))
+	for _, messageID := range messageIDs {
+		for _, labelID := range labelIDs {
+			rows = append(rows, &datamodel.MessageLabel{UserID: userID, MessageID: messageID, LabelID: labelID})
+		}
+	}
+	return d.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(&rows).Error
+}
+
+// Remove 移除一组邮件的一组标签
+func (d *MessageLabelDao) Remove(ctx context.Context, userID string, messageIDs, labelIDs []uint64) error {
+	if len(messageIDs) == 0 || len(labelIDs) == 0 {
+		return nil
+	}
+	return d.db.WithContext(ctx).
+		Where("user_id = ? AND message_id IN ? AND label_id IN ?", userID, messageIDs, labelIDs).
+		Delete(&datamodel.MessageLabel{}).Error
+}
+
+// ListByMessageIDs 查询一组邮件的标签关联
+func (d *MessageLabelDao) ListByMessageIDs(ctx context.Context, userID string, messageIDs []uint64) ([]*datamodel.MessageLabel, error) {
+	var rows []*datamodel.MessageLabel
+	if len(messageIDs) == 0 {
+		return rows, nil
+	}
+	err := d.db.WithContext(ctx).Where("user_id = ? AND message_id IN ?", userID, messageIDs).
+		Order("label_id ASC").Find(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+// DeleteByLabelID 删除标签的全部关联
+func (d *MessageLabelDao) DeleteByLabelID(ctx context.Context, labelID uint64) error {
+	return d.db.WithContext(ctx).Where("label_id = ?", labelID).Delete(&datamodel.MessageLabel{}).Error
+}
+
+// DeleteByUserID 删除用户的全部关联
+func (d *MessageLabelDao) DeleteByUserID(ctx context.Context, userID string) error {
+	return d.db.WithContext(ctx).Where("user_id = ?", userID).Delete(&datamodel.MessageLabel{}).Error
+}
@@ -0,0 +1,108 @@
+package dao
+
+import (
+	"context"
+	"time"
+
+	datamodel "plaud-emails/data/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// MessageSearchDao 邮件全文索引 DAO
+type MessageSearchDao struct {
+	db *gorm.DB
+}
+
+// NewMessageSearchDao 创建 MessageSearchDao
+func NewMessageSearchDao(db *gorm.DB) *MessageSearchDao {
+	return &MessageSearchDao{db: db}
+}
+
+// Upsert 写入或覆盖邮件的索引文档
+func (d *MessageSearchDao) Upsert(ctx context.Context, doc *datamodel.MessageSearchDoc) error {
+	return d.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"from_text", "to_text", "subject", "body", "filenames", "tag", "has_attachments", "received_at", "updated_at"}),
+	}).Create(doc).Error
+}
+
+// DeleteByID 删除邮件的索引文档
+func (d *MessageSearchDao) DeleteByID(ctx context.Context, userID string, id uint64) error {
+	return d.db.WithContext(ctx).Where("id = ? AND user_id = ?", id, userID).Delete(&datamodel.MessageSearchDoc{}).Error
+}
+
+// DeleteByUserID 删除用户的全部索引文档
+func (d *MessageSearchDao) DeleteByUserID(ctx context.Context, userID string) error {
+	return d.db.WithContext(ctx).Where("user_id = ?", userID).Delete(&datamodel.MessageSearchDoc{}).Error
+}
+
+// SearchFilter 全文检索条件，文本字段为 BOOLEAN MODE 表达式，为空时不限制
+type SearchFilter struct {
+	Content  string
+	From     string
+	To       string
+	Subject  string
+	Filename string
+
+	HasAttachments bool
+	// Folder 只检索该系统文件夹中的邮件，为空时不检索垃圾箱与垃圾邮件
+	Folder string
+	// Label 只检索带有该名称自定义标签的邮件
+	Label  string
+	Tag    string
+	Before *time.Time
+	After  *time.Time
+}
+
+// Search 按 id 倒序分页检索用户的邮件，返回邮件记录 id，beforeID 为 0 时从最新开始
+func (d *MessageSearchDao) Search(ctx context.Context, userID string, filter *SearchFilter, beforeID uint64, limit int) ([]uint64, error) {
+	query := d.db.WithContext(ctx).Model(&datamodel.MessageSearchDoc{}).Where("user_id = ?", userID)
+	if filter.Content != "" {
+		query = query.Where("MATCH (from_text, subject, body, filenames) AGAINST (? IN BOOLEAN MODE)", filter.Content)
+	}
+	if filter.From != "" {
+		query = query.Where("MATCH (from_text) AGAINST (? IN BOOLEAN MODE)", filter.From)
+	}
+	if filter.To != "" {
+		query = query.Where("MATCH (to_text) AGAINST (? IN BOOLEAN MODE)", filter.To)
+	}
+	if filter.Subject != "" {
+		query = query.Where("MATCH (subject) AGAINST (? IN BOOLEAN MODE)", filter.Subject)
+	}
+	if filter.Filename != "" {
+		query = query.Where("MATCH (filenames) AGAINST (? IN BOOLEAN MODE)", filter.Filename)
+	}
+	if filter.HasAttachments {
+		query = query.Where("has_attachments = ?", true)
+	}
+	if filter.Folder != "" {
+		query = query.Where("EXISTS (SELECT 1 FROM mind_advisor_messages m WHERE m.id = mind_advisor_message_search.id AND m.folder = ?)", filter.Folder)
+	} else {
+		query = query.Where("NOT EXISTS (SELECT 1 FROM mind_advisor_messages m WHERE m.id = mind_advisor_message_search.id AND m.folder IN ?)",
+			[]string{datamodel.FolderTrash, datamodel.FolderSpam})
+	}
+	if filter.Label != "" {
+		query = query.Where("EXISTS (SELECT 1 FROM mind_advisor_message_labels ml JOIN mind_advisor_labels l ON l.id = ml.label_id "+
+			"WHERE ml.message_id = mind_advisor_message_search.id AND l.user_id = ? AND l.name = ?)", userID, filter.Label)
+	}
+	if filter.Tag != "" {
+		query = query.Where("tag = ?", filter.Tag)
+	}
+	if filter.Before != nil {
+		query = query.Where("received_at < ?", *filter.Before)
+	}
+	if filter.After != nil {
+		query = query.Where("received_at >= ?", *filter.After)
+	}
+	if beforeID > 0 {
+		query = query.Where("id < ?", beforeID)
+	}
+
+	var ids []uint64
+	if err := query.Order("id DESC").Limit(limit).Pluck("id", &ids).Error; err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
@@ -0,0 +1,152 @@
+package dao
+
+import (
+	"context"
+	"errors"
+
+	datamodel "plaud-emails/data/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// MessageThreadDao 邮件会话 DAO
+type MessageThreadDao struct {
+	db *gorm.DB
+}
+
+// NewMessageThreadDao 创建 MessageThreadDao
+func NewMessageThreadDao(db *gorm.DB) *MessageThreadDao {
+	return &MessageThreadDao{db: db}
+}
+
+// Create 创建会话
+func (d *MessageThreadDao) Create(ctx context.Context, thread *datamodel.MessageThread) error {
+	return d.db.WithContext(ctx).Create(thread).Error
+}
+
+// Update 更新会话
+func (d *MessageThreadDao) Update(ctx context.Context, thread *datamodel.MessageThread) error {
+	return d.db.WithContext(ctx).Save(thread).Error
+}
+
+// GetByUserIDAndID 根据 user_id 和 id 查询，保证只能访问自己的会话
+func (d *MessageThreadDao) GetByUserIDAndID(ctx context.Context, userID string, id uint64) (*datamodel.MessageThread, error) {
+	var thread datamodel.MessageThread
+	err := d.db.WithContext(ctx).Where("id = ? AND user_id = ?", id, userID).Take(&thread).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &thread, nil
+}
+
+// ListByIDs 根据 id 批量查询用户的会话
+func (d *MessageThreadDao) ListByIDs(ctx context.Context, userID string, ids []uint64) ([]*datamodel.MessageThread, error) {
+	var threads []*datamodel.MessageThread
+	if len(ids) == 0 {
+		return threads, nil
+	}
+	err := d.db.WithContext(ctx).Where("user_id = ? AND id IN ?", userID, ids).Order("id ASC").Find(&threads).Error
+	if err != nil {
+		return nil, err
+	}
+	return threads, nil
+}
+
+// GetLatestBySubjectKey 查询主题相同且最近有新邮件的会话
+func (d *MessageThreadDao) GetLatestBySubjectKey(ctx context.Context, userID, subjectKey string) (*datamodel.MessageThread, error) {
+	var thread datamodel.MessageThread
+	err := d.db.WithContext(ctx).
+		Where("user_id = ? AND subject_key = ? AND message_count > 0", userID, subjectKey).
+		Order("latest_message_id DESC").Take(&thread).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &thread, nil
+}
+
+// ListByUserID 按最近邮件倒序分页查询用户的会话，beforeLatestID 为 0 时从最新开始
+func (d *MessageThreadDao) ListByUserID(ctx context.Context, userID string, beforeLatestID uint64, limit int) ([]*datamodel.MessageThread, error) {
+	var threads []*datamodel.MessageThread
+	query := d.db.WithContext(ctx).Where("user_id = ? AND message_count > 0", userID)
+	if beforeLatestID > 0 {
+		query = query.Where("latest_message_id < ?", beforeLatestID)
+	}
+	err := query.Order("latest_message_id DESC").Limit(limit).Find(&threads).Error
+	if err != nil {
+		return nil, err
+	}
+	return threads, nil
+}
+
+// DeleteByID 删除会话
+func (d *MessageThreadDao) DeleteByID(ctx context.Context, id uint64) error {
+	return d.db.WithContext(ctx).Where("id = ?", id).Delete(&datamodel.MessageThread{}).Error
+}
+
+// DeleteByUserID 删除用户的全部会话
+func (d *MessageThreadDao) DeleteByUserID(ctx context.Context, userID string) error {
+	return d.db.WithContext(ctx).Where("user_id = ?", userID).Delete(&datamodel.MessageThread{}).Error
+}
+
+// ExecTx 执行事务
+func (d *MessageThreadDao) ExecTx(ctx context.Context, fn func(tx *gorm.DB) error) error {
+	return d.db.WithContext(ctx).Transaction(fn)
+}
+
+// MessageThreadRefDao Message-ID 到会话映射 DAO
+type MessageThreadRefDao struct {
+	db *gorm.DB
+}
+
+// NewMessageThreadRefDao 创建 MessageThreadRefDao
+func NewMessageThreadRefDao(db *gorm.DB) *MessageThreadRefDao {
+	return &MessageThreadRefDao{db: db}
+}
+
+// ListThreadIDs 查询一组 Message-ID 已归属的会话 id，去重后按 id 升序返回
+func (d *MessageThreadRefDao) ListThreadIDs(ctx context.Context, userID string, refs []string) ([]uint64, error) {
+	var ids []uint64
+	if len(refs) == 0 {
+		return ids, nil
+	}
+	err := d.db.WithContext(ctx).Model(&datamodel.MessageThreadRef{}).
+		Where("user_id = ? AND message_ref IN ?", userID, refs).
+		Distinct().Order("thread_id ASC").Pluck("thread_id", &ids).Error
+	if err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// Upsert 将一组 Message-ID 指向会话，已存在的映射改为指向该会话
+func (d *MessageThreadRefDao) Upsert(ctx context.Context, userID string, refs []string, threadID uint64) error {
+	if len(refs) == 0 {
+		return nil
+	}
+	rows := make([]*datamodel.MessageThreadRef, 0, len(refs))
+	for _, ref := range refs {
+		rows = append(rows, &datamodel.MessageThreadRef{UserID: userID, MessageRef: ref, ThreadID: threadID})
+	}
+	return d.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "message_ref"}},
+		DoUpdates: clause.AssignmentColumns([]string{"thread_id"}),
+	}).Create(&rows).Error
+}
+
+// MoveThread 将一个会话的映射改为指向另一个会话，用于合并会话
+func (d *MessageThreadRefDao) MoveThread(ctx context.Context, from, to uint64) error {
+	return d.db.WithContext(ctx).Model(&datamodel.MessageThreadRef{}).
+		Where("thread_id = ?", from).Update("thread_id", to).Error
+}
+
+// DeleteByUserID 删除用户的全部映射
+func (d *MessageThreadRefDao) DeleteByUserID(ctx context.Context, userID string) error {
+	return d.db.WithContext(ctx).Where("user_id = ?", userID).Delete(&datamodel.MessageThreadRef{}).Error
+}
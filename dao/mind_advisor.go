@@ -3,10 +3,12 @@ package dao
 import (
 	"context"
 	"errors"
+	"time"
 
 	datamodel "plaud-emails/data/model"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // MindAdvisorUserDao 心智幕僚用户 DAO
@@ -42,6 +44,37 @@ func (d *MindAdvisorUserDao) GetByUserID(ctx context.Context, userID string) (*d
 	return &user, nil
 }
 
+// GetByUserIDForUpdate 根据 user_id 查询并加行锁，需在事务中调用
+func (d *MindAdvisorUserDao) GetByUserIDForUpdate(ctx context.Context, userID string) (*datamodel.MindAdvisorUser, error) {
+	var user datamodel.MindAdvisorUser
+	err := d.db.WithContext(ctx).Clauses(clause.Locking{Strength: "UPDATE"}).Where("user_id = ?", userID).Take(&user).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &user, nil
+}
+
+// NextModSeq 递增用户邮箱的 modseq 并返回新值，用户不存在时返回 0
+// 需在事务中调用，递增后用户记录保持锁定直到提交，保证 modseq 的顺序与提交顺序一致
+func (d *MindAdvisorUserDao) NextModSeq(ctx context.Context, userID string) (uint64, error) {
+	result := d.db.WithContext(ctx).Model(&datamodel.MindAdvisorUser{}).
+		Where("user_id = ?", userID).UpdateColumn("mod_seq", gorm.Expr("mod_seq + 1"))
+	if result.Error != nil {
+		return 0, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return 0, nil
+	}
+	var user datamodel.MindAdvisorUser
+	if err := d.db.WithContext(ctx).Select("mod_seq").Where("user_id = ?", userID).Take(&user).Error; err != nil {
+		return 0, err
+	}
+	return user.ModSeq, nil
+}
+
 // GetByDedicatedEmail 根据 dedicated_email 查询
 func (d *MindAdvisorUserDao) GetByDedicatedEmail(ctx context.Context, email string) (*datamodel.MindAdvisorUser, error) {
 	var user datamodel.MindAdvisorUser
@@ -55,9 +88,53 @@ func (d *MindAdvisorUserDao) GetByDedicatedEmail(ctx context.Context, email stri
 	return &user, nil
 }
 
+// ListByDedicatedEmails 批量根据 dedicated_email 查询
+func (d *MindAdvisorUserDao) ListByDedicatedEmails(ctx context.Context, emails []string) ([]*datamodel.MindAdvisorUser, error) {
+	var users []*datamodel.MindAdvisorUser
+	if len(emails) == 0 {
+		return users, nil
+	}
+	err := d.db.WithContext(ctx).Where("dedicated_email IN ?", emails).Find(&users).Error
+	if err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
 // Update 更新心智幕僚用户
+// mod_seq 只由 NextModSeq 递增，整行保存时跳过，避免用读取时的旧值覆盖并发事务分配的序号
 func (d *MindAdvisorUserDao) Update(ctx context.Context, user *datamodel.MindAdvisorUser) error {
-	return d.db.WithContext(ctx).Save(user).Error
+	return d.db.WithContext(ctx).Omit("mod_seq").Save(user).Error
+}
+
+// ListPurgeDue 查询软删除后到期需要清除的用户，按 purge_at 升序
+func (d *MindAdvisorUserDao) ListPurgeDue(ctx context.Context, now time.Time, limit int) ([]*datamodel.MindAdvisorUser, error) {
+	var users []*datamodel.MindAdvisorUser
+	err := d.db.WithContext(ctx).
+		Where("status = ? AND purge_at <= ?", datamodel.MindAdvisorStatusSoftDeleted, now).
+		Order("purge_at ASC").
+		Limit(limit).
+		Find(&users).Error
+	if err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+// ClaimPurge 仅当 purge_at 仍为 from 时推迟到 to，用于多副本间抢占清除任务，返回是否抢占成功
+func (d *MindAdvisorUserDao) ClaimPurge(ctx context.Context, id uint64, from, to time.Time) (bool, error) {
+	result := d.db.WithContext(ctx).Model(&datamodel.MindAdvisorUser{}).
+		Where("id = ? AND status = ? AND purge_at = ?", id, datamodel.MindAdvisorStatusSoftDeleted, from).
+		Update("purge_at", to)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// DeleteByID 物理删除用户记录
+func (d *MindAdvisorUserDao) DeleteByID(ctx context.Context, id uint64) error {
+	return d.db.WithContext(ctx).Where("id = ?", id).Delete(&datamodel.MindAdvisorUser{}).Error
 }
 
 // ExecTx 执行事务
@@ -93,10 +170,30 @@ func (d *MindAdvisorLinkedEmailDao) GetByUserIDAndEmail(ctx context.Context, use
 	return &linkedEmail, nil
 }
 
-// ListByUserID 根据 user_id 查询所有绑定邮箱
+// GetByUserIDAndID 根据 user_id 和 id 查询未删除的绑定邮箱（含已停用）
+func (d *MindAdvisorLinkedEmailDao) GetByUserIDAndID(ctx context.Context, userID string, id uint64) (*datamodel.MindAdvisorLinkedEmail, error) {
+	var linkedEmail datamodel.MindAdvisorLinkedEmail
+	err := d.db.WithContext(ctx).
+		Where("id = ? AND user_id = ? AND status <> ?", id, userID, datamodel.MindAdvisorStatusSoftDeleted).
+		Take(&linkedEmail).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &linkedEmail, nil
+}
+
+// Update 更新绑定邮箱
+func (d *MindAdvisorLinkedEmailDao) Update(ctx context.Context, email *datamodel.MindAdvisorLinkedEmail) error {
+	return d.db.WithContext(ctx).Save(email).Error
+}
+
+// ListByUserID 根据 user_id 查询所有未删除的绑定邮箱（含已停用）
 func (d *MindAdvisorLinkedEmailDao) ListByUserID(ctx context.Context, userID string) ([]*datamodel.MindAdvisorLinkedEmail, error) {
 	var emails []*datamodel.MindAdvisorLinkedEmail
-	err := d.db.WithContext(ctx).Where("user_id = ? AND status = ?", userID, datamodel.MindAdvisorStatusActive).Find(&emails).Error
+	err := d.db.WithContext(ctx).Where("user_id = ? AND status <> ?", userID, datamodel.MindAdvisorStatusSoftDeleted).Find(&emails).Error
 	if err != nil {
 		return nil, err
 	}
@@ -115,6 +212,117 @@ func (d *MindAdvisorLinkedEmailDao) ExistsByUserID(ctx context.Context, userID s
 	return count > 0, nil
 }
 
+// GetByID 根据 id 查询
+func (d *MindAdvisorLinkedEmailDao) GetByID(ctx context.Context, id uint64) (*datamodel.MindAdvisorLinkedEmail, error) {
+	var linkedEmail datamodel.MindAdvisorLinkedEmail
+	err := d.db.WithContext(ctx).Where("id = ?", id).Take(&linkedEmail).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &linkedEmail, nil
+}
+
+// GetByIDForUpdate 根据 id 查询并加行锁，需在事务中调用
+func (d *MindAdvisorLinkedEmailDao) GetByIDForUpdate(ctx context.Context, id uint64) (*datamodel.MindAdvisorLinkedEmail, error) {
+	var linkedEmail datamodel.MindAdvisorLinkedEmail
+	err := d.db.WithContext(ctx).Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", id).Take(&linkedEmail).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &linkedEmail, nil
+}
+
+// ListDueForSync 查询到期需要同步的绑定邮箱，按 next_sync_at 升序，从未同步的排在最前
+// 凭据失效或已停用的绑定不参与调度
+func (d *MindAdvisorLinkedEmailDao) ListDueForSync(ctx context.Context, sources []string, now time.Time, limit int) ([]*datamodel.MindAdvisorLinkedEmail, error) {
+	var emails []*datamodel.MindAdvisorLinkedEmail
+	err := d.db.WithContext(ctx).
+		Where("status = ? AND verified = ? AND source IN ?", datamodel.MindAdvisorStatusActive, true, sources).
+		Where("next_sync_at IS NULL OR next_sync_at <= ?", now).
+		Where("sync_status IS NULL OR sync_status NOT IN ?", []datamodel.LinkedEmailSyncStatus{
+			datamodel.LinkedEmailSyncStatusAuthError,
+			datamodel.LinkedEmailSyncStatusDisabled,
+		}).
+		Order("next_sync_at ASC").
+		Limit(limit).
+		Find(&emails).Error
+	if err != nil {
+		return nil, err
+	}
+	return emails, nil
+}
+
+// ListSchedule 按 id 升序分页查询指定来源未删除的绑定邮箱，syncStatus 为空时不过滤
+func (d *MindAdvisorLinkedEmailDao) ListSchedule(ctx context.Context, sources []string, syncStatus datamodel.LinkedEmailSyncStatus, afterID uint64, limit int) ([]*datamodel.MindAdvisorLinkedEmail, error) {
+	var emails []*datamodel.MindAdvisorLinkedEmail
+	query := d.db.WithContext(ctx).
+		Where("id > ? AND source IN ? AND status <> ?", afterID, sources, datamodel.MindAdvisorStatusSoftDeleted)
+	if syncStatus != "" {
+		query = query.Where("sync_status = ?", syncStatus)
+	}
+	err := query.Order("id ASC").Limit(limit).Find(&emails).Error
+	if err != nil {
+		return nil, err
+	}
+	return emails, nil
+}
+
+// UpdateColumns 更新指定列
+func (d *MindAdvisorLinkedEmailDao) UpdateColumns(ctx context.Context, id uint64, columns map[string]any) error {
+	return d.db.WithContext(ctx).Model(&datamodel.MindAdvisorLinkedEmail{}).Where("id = ?", id).Updates(columns).Error
+}
+
+// UpdateColumnsIfSyncStatus 仅当 sync_status 仍为 from 时更新指定列，返回是否更新成功
+// from 为空表示 sync_status 为 NULL
+func (d *MindAdvisorLinkedEmailDao) UpdateColumnsIfSyncStatus(ctx context.Context, id uint64, from datamodel.LinkedEmailSyncStatus, columns map[string]any) (bool, error) {
+	query := d.db.WithContext(ctx).Model(&datamodel.MindAdvisorLinkedEmail{}).Where("id = ?", id)
+	if from == datamodel.LinkedEmailSyncStatusNone {
+		query = query.Where("sync_status IS NULL OR sync_status = ''")
+	} else {
+		query = query.Where("sync_status = ?", from)
+	}
+	result := query.Updates(columns)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// ExistsVerifiedByUserID 检查用户是否有已验证的绑定邮箱
+func (d *MindAdvisorLinkedEmailDao) ExistsVerifiedByUserID(ctx context.Context, userID string) (bool, error) {
+	var count int64
+	err := d.db.WithContext(ctx).Model(&datamodel.MindAdvisorLinkedEmail{}).
+		Where("user_id = ? AND status = ? AND verified = ?", userID, datamodel.MindAdvisorStatusActive, true).
+		Count(&count).Error
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// SoftDeleteByUserID 软删除用户的全部绑定邮箱
+func (d *MindAdvisorLinkedEmailDao) SoftDeleteByUserID(ctx context.Context, userID string) error {
+	return d.db.WithContext(ctx).Model(&datamodel.MindAdvisorLinkedEmail{}).
+		Where("user_id = ? AND status <> ?", userID, datamodel.MindAdvisorStatusSoftDeleted).
+		Update("status", datamodel.MindAdvisorStatusSoftDeleted).Error
+}
+
+// DeleteByUserID 物理删除用户的全部绑定邮箱，包括已软删除的记录
+func (d *MindAdvisorLinkedEmailDao) DeleteByUserID(ctx context.Context, userID string) error {
+	return d.db.WithContext(ctx).Where("user_id = ?", userID).Delete(&datamodel.MindAdvisorLinkedEmail{}).Error
+}
+
+// ExecTx 执行事务
+func (d *MindAdvisorLinkedEmailDao) ExecTx(ctx context.Context, fn func(tx *gorm.DB) error) error {
+	return d.db.WithContext(ctx).Transaction(fn)
+}
+
 // BetaInviteRegistrationDao 内测邀请登记 DAO
 type BetaInviteRegistrationDao struct {
 	db *gorm.DB
@@ -0,0 +1,91 @@
+package dao
+
+import (
+	"context"
+	"errors"
+
+	datamodel "plaud-emails/data/model"
+
+	"gorm.io/gorm"
+)
+
+// MindAdvisorAliasDao 别名邮箱 DAO
+type MindAdvisorAliasDao struct {
+	db *gorm.DB
+}
+
+// NewMindAdvisorAliasDao 创建 MindAdvisorAliasDao
+func NewMindAdvisorAliasDao(db *gorm.DB) *MindAdvisorAliasDao {
+	return &MindAdvisorAliasDao{db: db}
+}
+
+// Create 创建别名
+func (d *MindAdvisorAliasDao) Create(ctx context.Context, alias *datamodel.MindAdvisorAlias) error {
+	return d.db.WithContext(ctx).Create(alias).Error
+}
+
+// GetByAddress 根据地址查询
+func (d *MindAdvisorAliasDao) GetByAddress(ctx context.Context, address string) (*datamodel.MindAdvisorAlias, error) {
+	var alias datamodel.MindAdvisorAlias
+	err := d.db.WithContext(ctx).Where("address = ?", address).Take(&alias).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &alias, nil
+}
+
+// ListByAddresses 批量根据地址查询
+func (d *MindAdvisorAliasDao) ListByAddresses(ctx context.Context, addresses []string) ([]*datamodel.MindAdvisorAlias, error) {
+	var aliases []*datamodel.MindAdvisorAlias
+	if len(addresses) == 0 {
+		return aliases, nil
+	}
+	err := d.db.WithContext(ctx).Where("address IN ?", addresses).Find(&aliases).Error
+	if err != nil {
+		return nil, err
+	}
+	return aliases, nil
+}
+
+// GetByUserIDAndID 根据 user_id 与 id 查询
+func (d *MindAdvisorAliasDao) GetByUserIDAndID(ctx context.Context, userID string, id uint64) (*datamodel.MindAdvisorAlias, error) {
+	var alias datamodel.MindAdvisorAlias
+	err := d.db.WithContext(ctx).Where("id = ? AND user_id = ?", id, userID).Take(&alias).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &alias, nil
+}
+
+// ListByUserID 查询用户的别名，按创建时间升序
+func (d *MindAdvisorAliasDao) ListByUserID(ctx context.Context, userID string) ([]*datamodel.MindAdvisorAlias, error) {
+	var aliases []*datamodel.MindAdvisorAlias
+	err := d.db.WithContext(ctx).Where("user_id = ?", userID).Order("id ASC").Find(&aliases).Error
+	if err != nil {
+		return nil, err
+	}
+	return aliases, nil
+}
+
+// CountByUserID 统计用户的别名数量
+func (d *MindAdvisorAliasDao) CountByUserID(ctx context.Context, userID string) (int64, error) {
+	var count int64
+	err := d.db.WithContext(ctx).Model(&datamodel.MindAdvisorAlias{}).Where("user_id = ?", userID).Count(&count).Error
+	return count, err
+}
+
+// DeleteByID 删除别名
+func (d *MindAdvisorAliasDao) DeleteByID(ctx context.Context, id uint64) error {
+	return d.db.WithContext(ctx).Delete(&datamodel.MindAdvisorAlias{}, id).Error
+}
+
+// DeleteByUserID 删除用户的全部别名
+func (d *MindAdvisorAliasDao) DeleteByUserID(ctx context.Context, userID string) error {
+	return d.db.WithContext(ctx).Where("user_id = ?", userID).Delete(&datamodel.MindAdvisorAlias{}).Error
+}
@@ -0,0 +1,38 @@
+package dao
+
+import (
+	"context"
+
+	datamodel "plaud-emails/data/model"
+
+	"gorm.io/gorm"
+)
+
+// MindAdvisorMailboxAuditDao 专属邮箱审计记录 DAO
+type MindAdvisorMailboxAuditDao struct {
+	db *gorm.DB
+}
+
+// NewMindAdvisorMailboxAuditDao 创建 MindAdvisorMailboxAuditDao
+func NewMindAdvisorMailboxAuditDao(db *gorm.DB) *MindAdvisorMailboxAuditDao {
+	return &MindAdvisorMailboxAuditDao{db: db}
+}
+
+// Create 创建审计记录
+func (d *MindAdvisorMailboxAuditDao) Create(ctx context.Context, audit *datamodel.MindAdvisorMailboxAudit) error {
+	return d.db.WithContext(ctx).Create(audit).Error
+}
+
+// ListByUserID 按 id 倒序分页查询用户的审计记录，beforeID 为 0 时从最新开始
+func (d *MindAdvisorMailboxAuditDao) ListByUserID(ctx context.Context, userID string, beforeID uint64, limit int) ([]*datamodel.MindAdvisorMailboxAudit, error) {
+	var audits []*datamodel.MindAdvisorMailboxAudit
+	query := d.db.WithContext(ctx).Where("user_id = ?", userID)
+	if beforeID > 0 {
+		query = query.Where("id < ?", beforeID)
+	}
+	err := query.Order("id DESC").Limit(limit).Find(&audits).Error
+	if err != nil {
+		return nil, err
+	}
+	return audits, nil
+}
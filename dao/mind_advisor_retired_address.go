@@ -0,0 +1,66 @@
+package dao
+
+import (
+	"context"
+	"errors"
+
+	datamodel "plaud-emails/data/model"
+
+	"gorm.io/gorm"
+)
+
+// MindAdvisorRetiredAddressDao 已更换的旧专属邮箱 DAO
+type MindAdvisorRetiredAddressDao struct {
+	db *gorm.DB
+}
+
+// NewMindAdvisorRetiredAddressDao 创建 MindAdvisorRetiredAddressDao
+func NewMindAdvisorRetiredAddressDao(db *gorm.DB) *MindAdvisorRetiredAddressDao {
+	return &MindAdvisorRetiredAddressDao{db: db}
+}
+
+// Create 创建旧地址记录
+func (d *MindAdvisorRetiredAddressDao) Create(ctx context.Context, addr *datamodel.MindAdvisorRetiredAddress) error {
+	return d.db.WithContext(ctx).Create(addr).Error
+}
+
+// GetByAddress 根据地址查询
+func (d *MindAdvisorRetiredAddressDao) GetByAddress(ctx context.Context, address string) (*datamodel.MindAdvisorRetiredAddress, error) {
+	var addr datamodel.MindAdvisorRetiredAddress
+	err := d.db.WithContext(ctx).Where("address = ?", address).Take(&addr).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &addr, nil
+}
+
+// ListByAddresses 批量根据地址查询
+func (d *MindAdvisorRetiredAddressDao) ListByAddresses(ctx context.Context, addresses []string) ([]*datamodel.MindAdvisorRetiredAddress, error) {
+	var addrs []*datamodel.MindAdvisorRetiredAddress
+	if len(addresses) == 0 {
+		return addrs, nil
+	}
+	err := d.db.WithContext(ctx).Where("address IN ?", addresses).Find(&addrs).Error
+	if err != nil {
+		return nil, err
+	}
+	return addrs, nil
+}
+
+// ListByUserID 查询用户的旧地址，按更换时间倒序
+func (d *MindAdvisorRetiredAddressDao) ListByUserID(ctx context.Context, userID string) ([]*datamodel.MindAdvisorRetiredAddress, error) {
+	var addrs []*datamodel.MindAdvisorRetiredAddress
+	err := d.db.WithContext(ctx).Where("user_id = ?", userID).Order("id DESC").Find(&addrs).Error
+	if err != nil {
+		return nil, err
+	}
+	return addrs, nil
+}
+
+// DeleteByID 删除旧地址记录
+func (d *MindAdvisorRetiredAddressDao) DeleteByID(ctx context.Context, id uint64) error {
+	return d.db.WithContext(ctx).Delete(&datamodel.MindAdvisorRetiredAddress{}, id).Error
+}
@@ -0,0 +1,62 @@
+package dao
+
+import (
+	"context"
+	"errors"
+
+	datamodel "plaud-emails/data/model"
+
+	"gorm.io/gorm"
+)
+
+// MindAdvisorTagRuleDao 子地址标签规则 DAO
+type MindAdvisorTagRuleDao struct {
+	db *gorm.DB
+}
+
+// NewMindAdvisorTagRuleDao 创建 MindAdvisorTagRuleDao
+func NewMindAdvisorTagRuleDao(db *gorm.DB) *MindAdvisorTagRuleDao {
+	return &MindAdvisorTagRuleDao{db: db}
+}
+
+// Save 创建或更新规则
+func (d *MindAdvisorTagRuleDao) Save(ctx context.Context, rule *datamodel.MindAdvisorTagRule) error {
+	return d.db.WithContext(ctx).Save(rule).Error
+}
+
+// GetByUserIDAndTag 根据 user_id 与 tag 查询
+func (d *MindAdvisorTagRuleDao) GetByUserIDAndTag(ctx context.Context, userID, tag string) (*datamodel.MindAdvisorTagRule, error) {
+	var rule datamodel.MindAdvisorTagRule
+	err := d.db.WithContext(ctx).Where("user_id = ? AND tag = ?", userID, tag).Take(&rule).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &rule, nil
+}
+
+// ListByUserID 查询用户的规则，按 tag 排序
+func (d *MindAdvisorTagRuleDao) ListByUserID(ctx context.Context, userID string) ([]*datamodel.MindAdvisorTagRule, error) {
+	var rules []*datamodel.MindAdvisorTagRule
+	err := d.db.WithContext(ctx).Where("user_id = ?", userID).Order("tag ASC").Find(&rules).Error
+	if err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+// DeleteByUserIDAndTag 删除规则，返回是否删除了记录
+func (d *MindAdvisorTagRuleDao) DeleteByUserIDAndTag(ctx context.Context, userID, tag string) (bool, error) {
+	result := d.db.WithContext(ctx).Where("user_id = ? AND tag = ?", userID, tag).Delete(&datamodel.MindAdvisorTagRule{})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// DeleteByUserID 删除用户的全部规则
+func (d *MindAdvisorTagRuleDao) DeleteByUserID(ctx context.Context, userID string) error {
+	return d.db.WithContext(ctx).Where("user_id = ?", userID).Delete(&datamodel.MindAdvisorTagRule{}).Error
+}
@@ -0,0 +1,94 @@
+package dao
+
+import (
+	"context"
+	"errors"
+
+	datamodel "plaud-emails/data/model"
+
+	"gorm.io/gorm"
+)
+
+// MindAdvisorUnicodeAddressDao 国际化地址 DAO
+type MindAdvisorUnicodeAddressDao struct {
+	db *gorm.DB
+}
+
+// NewMindAdvisorUnicodeAddressDao 创建 MindAdvisorUnicodeAddressDao
+func NewMindAdvisorUnicodeAddressDao(db *gorm.DB) *MindAdvisorUnicodeAddressDao {
+	return &MindAdvisorUnicodeAddressDao{db: db}
+}
+
+// Create 创建记录
+func (d *MindAdvisorUnicodeAddressDao) Create(ctx context.Context, addr *datamodel.MindAdvisorUnicodeAddress) error {
+	return d.db.WithContext(ctx).Create(addr).Error
+}
+
+// GetByAddress 根据国际化地址查询
+func (d *MindAdvisorUnicodeAddressDao) GetByAddress(ctx context.Context, address string) (*datamodel.MindAdvisorUnicodeAddress, error) {
+	var addr datamodel.MindAdvisorUnicodeAddress
+	err := d.db.WithContext(ctx).Where("address = ?", address).Take(&addr).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &addr, nil
+}
+
+// GetByFallbackAddress 根据 ASCII 兜底地址查询
+func (d *MindAdvisorUnicodeAddressDao) GetByFallbackAddress(ctx context.Context, fallback string) (*datamodel.MindAdvisorUnicodeAddress, error) {
+	var addr datamodel.MindAdvisorUnicodeAddress
+	err := d.db.WithContext(ctx).Where("fallback_address = ?", fallback).Take(&addr).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &addr, nil
+}
+
+// ListByFallbackAddresses 批量根据 ASCII 兜底地址查询
+func (d *MindAdvisorUnicodeAddressDao) ListByFallbackAddresses(ctx context.Context, fallbacks []string) ([]*datamodel.MindAdvisorUnicodeAddress, error) {
+	var addrs []*datamodel.MindAdvisorUnicodeAddress
+	if len(fallbacks) == 0 {
+		return addrs, nil
+	}
+	err := d.db.WithContext(ctx).Where("fallback_address IN ?", fallbacks).Find(&addrs).Error
+	if err != nil {
+		return nil, err
+	}
+	return addrs, nil
+}
+
+// ListBySkeleton 查询骨架相同的地址
+func (d *MindAdvisorUnicodeAddressDao) ListBySkeleton(ctx context.Context, skeleton string) ([]*datamodel.MindAdvisorUnicodeAddress, error) {
+	var addrs []*datamodel.MindAdvisorUnicodeAddress
+	err := d.db.WithContext(ctx).Where("skeleton = ?", skeleton).Find(&addrs).Error
+	if err != nil {
+		return nil, err
+	}
+	return addrs, nil
+}
+
+// DeleteByID 删除记录
+func (d *MindAdvisorUnicodeAddressDao) DeleteByID(ctx context.Context, id uint64) error {
+	return d.db.WithContext(ctx).Where("id = ?", id).Delete(&datamodel.MindAdvisorUnicodeAddress{}).Error
+}
+
+// ListByUserID 查询用户的国际化地址
+func (d *MindAdvisorUnicodeAddressDao) ListByUserID(ctx context.Context, userID string) ([]*datamodel.MindAdvisorUnicodeAddress, error) {
+	var addrs []*datamodel.MindAdvisorUnicodeAddress
+	err := d.db.WithContext(ctx).Where("user_id = ?", userID).Find(&addrs).Error
+	if err != nil {
+		return nil, err
+	}
+	return addrs, nil
+}
+
+// DeleteByUserID 删除用户的全部国际化地址
+func (d *MindAdvisorUnicodeAddressDao) DeleteByUserID(ctx context.Context, userID string) error {
+	return d.db.WithContext(ctx).Where("user_id = ?", userID).Delete(&datamodel.MindAdvisorUnicodeAddress{}).Error
+}
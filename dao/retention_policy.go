@@ -0,0 +1,57 @@
+package dao
+
+import (
+	"context"
+	"errors"
+
+	datamodel "plaud-emails/data/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// RetentionPolicyDao 用户邮件保留策略 DAO
+type RetentionPolicyDao struct {
+	db *gorm.DB
+}
+
+// NewRetentionPolicyDao 创建 RetentionPolicyDao
+func NewRetentionPolicyDao(db *gorm.DB) *RetentionPolicyDao {
+	return &RetentionPolicyDao{db: db}
+}
+
+// GetByUserID 根据 user_id 查询
+func (d *RetentionPolicyDao) GetByUserID(ctx context.Context, userID string) (*datamodel.RetentionPolicy, error) {
+	var policy datamodel.RetentionPolicy
+	err := d.db.WithContext(ctx).Where("user_id = ?", userID).Take(&policy).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &policy, nil
+}
+
+// ListAll 查询全部用户的保留策略
+func (d *RetentionPolicyDao) ListAll(ctx context.Context) ([]*datamodel.RetentionPolicy, error) {
+	var policies []*datamodel.RetentionPolicy
+	err := d.db.WithContext(ctx).Order("id ASC").Find(&policies).Error
+	if err != nil {
+		return nil, err
+	}
+	return policies, nil
+}
+
+// Upsert 创建或覆盖用户的保留策略
+func (d *RetentionPolicyDao) Upsert(ctx context.Context, policy *datamodel.RetentionPolicy) error {
+	return d.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"message_days", "attachment_days", "updated_at"}),
+	}).Create(policy).Error
+}
+
+// DeleteByUserID 删除用户的保留策略，恢复使用全局规则
+func (d *RetentionPolicyDao) DeleteByUserID(ctx context.Context, userID string) error {
+	return d.db.WithContext(ctx).Where("user_id = ?", userID).Delete(&datamodel.RetentionPolicy{}).Error
+}
@@ -0,0 +1,112 @@
+package dao
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	datamodel "plaud-emails/data/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// SpamTokenDao 贝叶斯分类器词频 DAO
+type SpamTokenDao struct {
+	db *gorm.DB
+}
+
+// NewSpamTokenDao 创建 SpamTokenDao
+func NewSpamTokenDao(db *gorm.DB) *SpamTokenDao {
+	return &SpamTokenDao{db: db}
+}
+
+// ListByHashes 查询用户的一组 token 的词频，未出现过的 token 不返回
+func (d *SpamTokenDao) ListByHashes(ctx context.Context, userID string, hashes []uint64) ([]*datamodel.SpamToken, error) {
+	var tokens []*datamodel.SpamToken
+	if len(hashes) == 0 {
+		return tokens, nil
+	}
+	err := d.db.WithContext(ctx).Where("user_id = ? AND token_hash IN ?", userID, hashes).Find(&tokens).Error
+	if err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+// Add 累加一组 token 的词频，记录不存在时创建，计数不会小于 0
+func (d *SpamTokenDao) Add(ctx context.Context, userID string, hashes []uint64, spamDelta, hamDelta int64) error {
+	if len(hashes) == 0 {
+		return nil
+	}
+	now := time.Now()
+	tokens := make([]*datamodel.SpamToken, 0, len(hashes))
+	for _, h := range hashes {
+		tokens = append(tokens, &datamodel.SpamToken{
+			UserID:    userID,
+			TokenHash: h,
+			SpamCount: max(spamDelta, 0),
+			HamCount:  max(hamDelta, 0),
+			UpdatedAt: now,
+		})
+	}
+	return d.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "user_id"}, {Name: "token_hash"}},
+		DoUpdates: clause.Assignments(map[string]any{
+			"spam_count": gorm.Expr("GREATEST(spam_count + ?, 0)", spamDelta),
+			"ham_count":  gorm.Expr("GREATEST(ham_count + ?, 0)", hamDelta),
+			"updated_at": now,
+		}),
+	}).CreateInBatches(tokens, 500).Error
+}
+
+// DeleteByUserID 删除用户的全部词频
+func (d *SpamTokenDao) DeleteByUserID(ctx context.Context, userID string) error {
+	return d.db.WithContext(ctx).Where("user_id = ?", userID).Delete(&datamodel.SpamToken{}).Error
+}
+
+// SpamStatsDao 贝叶斯分类器训练统计 DAO
+type SpamStatsDao struct {
+	db *gorm.DB
+}
+
+// NewSpamStatsDao 创建 SpamStatsDao
+func NewSpamStatsDao(db *gorm.DB) *SpamStatsDao {
+	return &SpamStatsDao{db: db}
+}
+
+// GetByUserID 根据 user_id 查询
+func (d *SpamStatsDao) GetByUserID(ctx context.Context, userID string) (*datamodel.SpamStats, error) {
+	var stats datamodel.SpamStats
+	err := d.db.WithContext(ctx).Where("user_id = ?", userID).Take(&stats).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &stats, nil
+}
+
+// Add 累加已训练的邮件数，记录不存在时创建，计数不会小于 0
+func (d *SpamStatsDao) Add(ctx context.Context, userID string, spamDelta, hamDelta int64) error {
+	stats := &datamodel.SpamStats{UserID: userID, SpamMessages: max(spamDelta, 0), HamMessages: max(hamDelta, 0)}
+	return d.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.Assignments(map[string]any{
+			"spam_messages": gorm.Expr("GREATEST(spam_messages + ?, 0)", spamDelta),
+			"ham_messages":  gorm.Expr("GREATEST(ham_messages + ?, 0)", hamDelta),
+			"updated_at":    time.Now(),
+		}),
+	}).Create(stats).Error
+}
+
+// DeleteByUserID 删除用户的训练统计
+func (d *SpamStatsDao) DeleteByUserID(ctx context.Context, userID string) error {
+	return d.db.WithContext(ctx).Where("user_id = ?", userID).Delete(&datamodel.SpamStats{}).Error
+}
+
+// ExecTx 执行事务
+func (d *SpamStatsDao) ExecTx(ctx context.Context, fn func(tx *gorm.DB) error) error {
+	return d.db.WithContext(ctx).Transaction(fn)
+}
@@ -0,0 +1,35 @@
+package dto
+
+import datamodel "plaud-emails/data/model"
+
+// Label 标签 DTO，系统标签没有 id
+type Label struct {
+	ID     uint64 `json:"id,omitempty"`
+	Name   string `json:"name"`
+	System bool   `json:"system"`
+}
+
+// LabelList 标签列表 DTO，系统标签在前
+type LabelList struct {
+	Labels []*Label `json:"labels"`
+}
+
+// NewLabelFromModel 从 Model 转换为 DTO
+func NewLabelFromModel(l *datamodel.UserLabel) *Label {
+	if l == nil {
+		return nil
+	}
+	return &Label{ID: l.ID, Name: l.Name}
+}
+
+// NewLabelList 组装系统标签与用户的自定义标签
+func NewLabelList(labels []*datamodel.UserLabel) *LabelList {
+	list := &LabelList{Labels: make([]*Label, 0, len(datamodel.Folders)+len(labels))}
+	for _, f := range datamodel.Folders {
+		list.Labels = append(list.Labels, &Label{Name: f, System: true})
+	}
+	for _, l := range labels {
+		list.Labels = append(list.Labels, NewLabelFromModel(l))
+	}
+	return list
+}
@@ -0,0 +1,150 @@
+package dto
+
+import (
+	"strconv"
+
+	datamodel "plaud-emails/data/model"
+)
+
+// LinkedEmail 绑定邮箱 DTO
+type LinkedEmail struct {
+	ID               uint64 `json:"id"`
+	Email            string `json:"email"`
+	Source           string `json:"source"`
+	Verified         bool   `json:"verified"`
+	Active           bool   `json:"active"`
+	VerifiedAt       int64  `json:"verified_at,omitempty"`
+	VerifyToken      string `json:"verify_token,omitempty"`
+	ConfirmationCode string `json:"confirmation_code,omitempty"`
+	ConfirmationURL  string `json:"confirmation_url,omitempty"`
+	SyncStatus       string `json:"sync_status,omitempty"`
+	LastSyncAt       int64  `json:"last_sync_at,omitempty"`
+	LastSyncError    string `json:"last_sync_error,omitempty"`
+	NextSyncAt       int64  `json:"next_sync_at,omitempty"`
+	IMAP             *IMAP  `json:"imap,omitempty"`
+	CreatedAt        int64  `json:"created_at"`
+}
+
+// IMAP IMAP 账户 DTO，不包含凭据
+type IMAP struct {
+	Host     string `json:"host"`
+	Port     int    `json:"port"`
+	TLS      bool   `json:"tls"`
+	Username string `json:"username"`
+	Mailbox  string `json:"mailbox"`
+}
+
+// LinkedEmailList 绑定邮箱列表 DTO
+type LinkedEmailList struct {
+	LinkedEmails []*LinkedEmail `json:"linked_emails"`
+}
+
+// NewLinkedEmailFromModel 从 Model 转换为 DTO
+func NewLinkedEmailFromModel(m *datamodel.MindAdvisorLinkedEmail) *LinkedEmail {
+	if m == nil {
+		return nil
+	}
+	link := &LinkedEmail{
+		ID:        m.ID,
+		Email:     m.Email,
+		Source:    m.Source,
+		Verified:  m.IsTrustedVerified(),
+		Active:    m.IsActive(),
+		CreatedAt: m.CreatedAt.UnixMilli(),
+	}
+	if m.VerifiedAt != nil {
+		link.VerifiedAt = m.VerifiedAt.UnixMilli()
+	}
+	if m.SyncStatus != nil {
+		link.SyncStatus = string(*m.SyncStatus)
+	}
+	if m.LastSyncAt != nil {
+		link.LastSyncAt = m.LastSyncAt.UnixMilli()
+	}
+	if m.NextSyncAt != nil {
+		link.NextSyncAt = m.NextSyncAt.UnixMilli()
+	}
+	if m.Extra != nil {
+		link.VerifyToken = m.Extra.VerifyToken
+		link.ConfirmationCode = m.Extra.ConfirmationCode
+		link.ConfirmationURL = m.Extra.ConfirmationURL
+		link.LastSyncError = m.Extra.LastSyncError
+		if acct := m.Extra.IMAP; acct != nil {
+			link.IMAP = &IMAP{
+				Host:     acct.Host,
+				Port:     acct.Port,
+				TLS:      acct.TLS,
+				Username: acct.Username,
+				Mailbox:  acct.Mailbox,
+			}
+		}
+	}
+	return link
+}
+
+// SyncScheduleEntry 绑定邮箱调度状态 DTO，供内部排查使用
+type SyncScheduleEntry struct {
+	ID           uint64     `json:"id"`
+	UserID       string     `json:"user_id"`
+	Email        string     `json:"email"`
+	Source       string     `json:"source"`
+	Active       bool       `json:"active"`
+	SyncStatus   string     `json:"sync_status,omitempty"`
+	SyncFailures int        `json:"sync_failures"`
+	LastSyncAt   int64      `json:"last_sync_at,omitempty"`
+	NextSyncAt   int64      `json:"next_sync_at,omitempty"`
+	LastError    string     `json:"last_error,omitempty"`
+	Lease        *SyncLease `json:"lease,omitempty"`
+	Running      bool       `json:"running"`
+}
+
+// SyncLease 同步租约 DTO
+type SyncLease struct {
+	Owner string `json:"owner"`
+	TTLMs int64  `json:"ttl_ms"`
+}
+
+// SyncSchedule 调度状态列表 DTO
+type SyncSchedule struct {
+	Owner      string               `json:"owner"`
+	Enabled    bool                 `json:"enabled"`
+	Sources    []string             `json:"sources"`
+	Running    int                  `json:"running"`
+	Entries    []*SyncScheduleEntry `json:"entries"`
+	NextCursor string               `json:"next_cursor,omitempty"`
+}
+
+// LinkedEmailSyncEvent 同步状态迁移记录 DTO
+type LinkedEmailSyncEvent struct {
+	ID         uint64 `json:"id"`
+	FromStatus string `json:"from_status"`
+	ToStatus   string `json:"to_status"`
+	Reason     string `json:"reason"`
+	Error      string `json:"error,omitempty"`
+	CreatedAt  int64  `json:"created_at"`
+}
+
+// LinkedEmailSyncHistory 同步状态迁移记录列表 DTO
+type LinkedEmailSyncHistory struct {
+	Events     []*LinkedEmailSyncEvent `json:"events"`
+	NextCursor string                  `json:"next_cursor,omitempty"`
+}
+
+// NewLinkedEmailSyncHistory 从迁移记录构造 DTO，满页时返回下一页游标
+func NewLinkedEmailSyncHistory(events []*datamodel.LinkedEmailSyncEvent, limit int) *LinkedEmailSyncHistory {
+	history := &LinkedEmailSyncHistory{Events: make([]*LinkedEmailSyncEvent, 0, len(events))}
+	for _, e := range events {
+		history.Events = append(history.Events, &LinkedEmailSyncEvent{
+			ID:         e.ID,
+			FromStatus: string(e.FromStatus),
+			ToStatus:   string(e.ToStatus),
+			Reason:     e.Reason,
+			Error:      e.Error,
+			CreatedAt:  e.CreatedAt.UnixMilli(),
+		})
+	}
+	if limit > 0 && len(events) == limit {
+		history.NextCursor = strconv.FormatUint(events[len(events)-1].ID, 10)
+	}
+	return history
+}
@@ -1,6 +1,11 @@
 package dto
 
-import datamodel "plaud-emails/data/model"
+import (
+	"strconv"
+	"time"
+
+	datamodel "plaud-emails/data/model"
+)
 
 // MailboxConfig 邮箱配置
 type MailboxConfig struct {
@@ -13,8 +18,20 @@ type Mailbox struct {
 	LocalPart      string         `json:"local_part"`
 	Status         string         `json:"status"`
 	Config         *MailboxConfig `json:"config"`
+	// FallbackEmail 国际化地址自动生成的 ASCII 兜底地址
+	FallbackEmail string `json:"fallback_email,omitempty"`
+	// DeletedAt 与 PurgeAt 仅在邮箱已删除时返回，PurgeAt 之后数据被清除
+	DeletedAt int64 `json:"deleted_at,omitempty"`
+	PurgeAt   int64 `json:"purge_at,omitempty"`
 }
 
+// Mailbox status constants
+const (
+	MailboxStatusCreated     = "EMAIL_CREATED"
+	MailboxStatusDeactivated = "DEACTIVATED"
+	MailboxStatusDeleted     = "DELETED"
+)
+
 // MailboxResponse 邮箱响应
 type MailboxResponse struct {
 	Mailbox *Mailbox `json:"mailbox"`
@@ -36,12 +53,144 @@ func NewMailboxFromModel(m *datamodel.MindAdvisorUser) *Mailbox {
 	// 从 dedicated_email 中提取 local_part
 	localPart := extractLocalPart(m.DedicatedEmail)
 
-	return &Mailbox{
+	mailbox := &Mailbox{
 		DedicatedEmail: m.DedicatedEmail,
 		LocalPart:      localPart,
-		Status:         "EMAIL_CREATED",
+		Status:         MailboxStatusCreated,
 		Config:         config,
 	}
+	switch m.Status {
+	case datamodel.MindAdvisorStatusInactive:
+		mailbox.Status = MailboxStatusDeactivated
+	case datamodel.MindAdvisorStatusSoftDeleted:
+		mailbox.Status = MailboxStatusDeleted
+		if m.DeletedAt != nil {
+			mailbox.DeletedAt = m.DeletedAt.UnixMilli()
+		}
+		if m.PurgeAt != nil {
+			mailbox.PurgeAt = m.PurgeAt.UnixMilli()
+		}
+	}
+	return mailbox
+}
+
+// RetiredAddress 更换 local_part 后保留的旧地址 DTO
+type RetiredAddress struct {
+	Address         string `json:"address"`
+	Routing         bool   `json:"routing"`
+	RouteUntil      int64  `json:"route_until"`
+	QuarantineUntil int64  `json:"quarantine_until"`
+	RetiredAt       int64  `json:"retired_at"`
+}
+
+// RetiredAddressList 旧地址列表 DTO
+type RetiredAddressList struct {
+	RetiredAddresses []*RetiredAddress `json:"retired_addresses"`
+}
+
+// NewRetiredAddressFromModel 从 Model 转换为 DTO
+func NewRetiredAddressFromModel(m *datamodel.MindAdvisorRetiredAddress) *RetiredAddress {
+	if m == nil {
+		return nil
+	}
+	return &RetiredAddress{
+		Address:         m.Address,
+		Routing:         m.RoutesAt(time.Now()),
+		RouteUntil:      m.RouteUntil.UnixMilli(),
+		QuarantineUntil: m.QuarantineUntil.UnixMilli(),
+		RetiredAt:       m.CreatedAt.UnixMilli(),
+	}
+}
+
+// Alias 别名邮箱 DTO
+type Alias struct {
+	ID        uint64 `json:"id"`
+	Address   string `json:"address"`
+	CreatedAt int64  `json:"created_at"`
+}
+
+// AliasList 别名列表 DTO
+type AliasList struct {
+	Aliases []*Alias `json:"aliases"`
+}
+
+// NewAliasFromModel 从 Model 转换为 DTO
+func NewAliasFromModel(m *datamodel.MindAdvisorAlias) *Alias {
+	if m == nil {
+		return nil
+	}
+	return &Alias{
+		ID:        m.ID,
+		Address:   m.Address,
+		CreatedAt: m.CreatedAt.UnixMilli(),
+	}
+}
+
+// TagRule 子地址标签规则 DTO
+type TagRule struct {
+	Tag                  string `json:"tag"`
+	Label                string `json:"label,omitempty"`
+	Mute                 bool   `json:"mute"`
+	ForwardLinkedEmailID uint64 `json:"forward_linked_email_id,omitempty"`
+	UpdatedAt            int64  `json:"updated_at"`
+}
+
+// TagRuleList 标签规则列表 DTO
+type TagRuleList struct {
+	TagRules []*TagRule `json:"tag_rules"`
+}
+
+// NewTagRuleFromModel 从 Model 转换为 DTO
+func NewTagRuleFromModel(m *datamodel.MindAdvisorTagRule) *TagRule {
+	if m == nil {
+		return nil
+	}
+	return &TagRule{
+		Tag:                  m.Tag,
+		Label:                m.Label,
+		Mute:                 m.Mute,
+		ForwardLinkedEmailID: m.ForwardLinkedEmailID,
+		UpdatedAt:            m.UpdatedAt.UnixMilli(),
+	}
+}
+
+// BlocklistRule local_part 屏蔽规则 DTO，来自配置的规则 id 为 0
+type BlocklistRule struct {
+	ID        uint64 `json:"id,omitempty"`
+	Source    string `json:"source"` // config, admin
+	Match     string `json:"match"`
+	Pattern   string `json:"pattern"`
+	Category  string `json:"category"`
+	Note      string `json:"note,omitempty"`
+	UpdatedAt int64  `json:"updated_at,omitempty"`
+}
+
+// BlocklistRuleList 屏蔽规则列表 DTO
+type BlocklistRuleList struct {
+	Rules []*BlocklistRule `json:"rules"`
+}
+
+// BlocklistCheck local_part 屏蔽检查结果 DTO
+type BlocklistCheck struct {
+	LocalPart string         `json:"local_part"`
+	Blocked   bool           `json:"blocked"`
+	Rule      *BlocklistRule `json:"rule,omitempty"`
+}
+
+// NewBlocklistRuleFromModel 从 Model 转换为 DTO
+func NewBlocklistRuleFromModel(m *datamodel.MailboxBlocklistRule) *BlocklistRule {
+	if m == nil {
+		return nil
+	}
+	return &BlocklistRule{
+		ID:        m.ID,
+		Source:    "admin",
+		Match:     m.Match,
+		Pattern:   m.Pattern,
+		Category:  m.Category,
+		Note:      m.Note,
+		UpdatedAt: m.UpdatedAt.UnixMilli(),
+	}
 }
 
 // extractLocalPart 从完整邮箱地址中提取 local_part
@@ -53,3 +202,106 @@ func extractLocalPart(email string) string {
 	}
 	return email
 }
+
+// MailboxAudit 邮箱状态变更审计记录 DTO
+type MailboxAudit struct {
+	ID             uint64 `json:"id"`
+	DedicatedEmail string `json:"dedicated_email"`
+	Action         string `json:"action"`
+	FromStatus     int16  `json:"from_status"`
+	ToStatus       int16  `json:"to_status"`
+	Actor          string `json:"actor"`
+	Reason         string `json:"reason,omitempty"`
+	CreatedAt      int64  `json:"created_at"`
+}
+
+// MailboxAuditList 审计记录列表 DTO
+type MailboxAuditList struct {
+	Audits     []*MailboxAudit `json:"audits"`
+	NextCursor string          `json:"next_cursor,omitempty"`
+}
+
+// NewMailboxAuditList 从审计记录构造 DTO，满页时返回下一页游标
+func NewMailboxAuditList(audits []*datamodel.MindAdvisorMailboxAudit, limit int) *MailboxAuditList {
+	list := &MailboxAuditList{Audits: make([]*MailboxAudit, 0, len(audits))}
+	for _, a := range audits {
+		list.Audits = append(list.Audits, &MailboxAudit{
+			ID:             a.ID,
+			DedicatedEmail: a.DedicatedEmail,
+			Action:         a.Action,
+			FromStatus:     a.FromStatus,
+			ToStatus:       a.ToStatus,
+			Actor:          a.Actor,
+			Reason:         a.Reason,
+			CreatedAt:      a.CreatedAt.UnixMilli(),
+		})
+	}
+	if limit > 0 && len(audits) == limit {
+		list.NextCursor = strconv.FormatUint(audits[len(audits)-1].ID, 10)
+	}
+	return list
+}
+
+// MailboxSettings 邮箱设置 DTO
+type MailboxSettings struct {
+	Version     int             `json:"version"`
+	Salutation  string          `json:"salutation"`
+	DisplayName string          `json:"display_name"`
+	Language    string          `json:"language"`
+	Timezone    string          `json:"timezone"`
+	Digest      *DigestSchedule `json:"digest"`
+	AutoReply   *AutoReply      `json:"auto_reply"`
+}
+
+// DigestSchedule 邮件摘要推送计划 DTO
+type DigestSchedule struct {
+	Frequency string `json:"frequency"`
+	Hour      int    `json:"hour"`
+	Weekday   int    `json:"weekday"`
+}
+
+// AutoReply 自动回复 DTO
+type AutoReply struct {
+	Enabled bool   `json:"enabled"`
+	Subject string `json:"subject"`
+	Body    string `json:"body"`
+}
+
+// NewMailboxSettingsFromModel 从 Model 转换为 DTO，未设置摘要计划时返回 off
+func NewMailboxSettingsFromModel(m *datamodel.MindAdvisorUserConfig) *MailboxSettings {
+	if m == nil {
+		return nil
+	}
+	settings := &MailboxSettings{
+		Version:     m.Version,
+		Salutation:  m.Salutation,
+		DisplayName: m.DisplayName,
+		Language:    m.Language,
+		Timezone:    m.Timezone,
+		Digest:      &DigestSchedule{Frequency: datamodel.DigestFrequencyOff},
+		AutoReply:   &AutoReply{},
+	}
+	if m.Digest != nil {
+		settings.Digest = &DigestSchedule{Frequency: m.Digest.Frequency, Hour: m.Digest.Hour, Weekday: m.Digest.Weekday}
+	}
+	if m.AutoReply != nil {
+		settings.AutoReply = &AutoReply{Enabled: m.AutoReply.Enabled, Subject: m.AutoReply.Subject, Body: m.AutoReply.Body}
+	}
+	return settings
+}
+
+// RetentionPolicy 邮件保留策略 DTO，天数为 0 表示永久保留
+type RetentionPolicy struct {
+	MessageDays          int  `json:"message_days"`
+	AttachmentDays       int  `json:"attachment_days"`
+	Custom               bool `json:"custom"`
+	GlobalMessageDays    int  `json:"global_message_days"`
+	GlobalAttachmentDays int  `json:"global_attachment_days"`
+}
+
+// LegalHold 法律保留状态 DTO
+type LegalHold struct {
+	UserID    string `json:"user_id"`
+	MessageID uint64 `json:"message_id,omitempty"`
+	LegalHold bool   `json:"legal_hold"`
+}
@@ -0,0 +1,258 @@
+package dto
+
+import (
+	datamodel "plaud-emails/data/model"
+)
+
+// MessageSummary 邮件列表项 DTO
+type MessageSummary struct {
+	ID             uint64   `json:"id"`
+	MessageID      string   `json:"message_id"`
+	ThreadID       uint64   `json:"thread_id,omitempty"`
+	From           string   `json:"from"`
+	To             string   `json:"to"`
+	Alias          string   `json:"alias,omitempty"`
+	Tag            string   `json:"tag,omitempty"`
+	Label          string   `json:"label,omitempty"`
+	Muted          bool     `json:"muted,omitempty"`
+	Folder         string   `json:"folder"`
+	Read           bool     `json:"read"`
+	Starred        bool     `json:"starred"`
+	Important      bool     `json:"important"`
+	LabelIDs       []uint64 `json:"label_ids"`
+	ModSeq         uint64   `json:"mod_seq"`
+	Subject        string   `json:"subject"`
+	Snippet        string   `json:"snippet"`
+	HasAttachments bool     `json:"has_attachments"`
+	Size           int64    `json:"size"`
+	SpamScore      float64  `json:"spam_score,omitempty"`
+	Spoofed        bool     `json:"spoofed,omitempty"`
+	SentAt         int64    `json:"sent_at,omitempty"`
+	ReceivedAt     int64    `json:"received_at"`
+}
+
+// MessageList 邮件列表 DTO
+type MessageList struct {
+	Messages   []*MessageSummary `json:"messages"`
+	NextCursor string            `json:"next_cursor,omitempty"`
+}
+
+// NewMessageSummaryFromModel 从 Model 转换为 DTO
+func NewMessageSummaryFromModel(m *datamodel.Message) *MessageSummary {
+	if m == nil {
+		return nil
+	}
+	summary := &MessageSummary{
+		ID:             m.ID,
+		MessageID:      m.MessageID,
+		ThreadID:       m.ThreadID,
+		From:           m.FromAddr,
+		To:             m.ToAddrs,
+		Alias:          m.Alias,
+		Tag:            m.Tag,
+		Label:          m.Label,
+		Muted:          m.Muted,
+		Folder:         m.Folder,
+		Read:           m.Seen,
+		Starred:        m.Starred,
+		Important:      m.Important,
+		LabelIDs:       []uint64{},
+		ModSeq:         m.ModSeq,
+		Subject:        m.Subject,
+		Snippet:        m.Snippet,
+		HasAttachments: m.HasAttachments,
+		Size:           m.Size,
+		SpamScore:      m.SpamScore,
+		Spoofed:        m.Spoofed(),
+		ReceivedAt:     m.ReceivedAt.UnixMilli(),
+	}
+	if m.SentAt != nil {
+		summary.SentAt = m.SentAt.UnixMilli()
+	}
+	return summary
+}
+
+// NewMessageSummaries 转换邮件列表，labelIDs 为各邮件的自定义标签 id
+func NewMessageSummaries(msgs []*datamodel.Message, labelIDs map[uint64][]uint64) []*MessageSummary {
+	summaries := make([]*MessageSummary, 0, len(msgs))
+	for _, m := range msgs {
+		summary := NewMessageSummaryFromModel(m)
+		if ids := labelIDs[m.ID]; len(ids) > 0 {
+			summary.LabelIDs = ids
+		}
+		summaries = append(summaries, summary)
+	}
+	return summaries
+}
+
+// MessageChange 增量同步中的一项变化，邮件已删除时只返回 id
+type MessageChange struct {
+	ID      uint64          `json:"id"`
+	ModSeq  uint64          `json:"mod_seq"`
+	Deleted bool            `json:"deleted,omitempty"`
+	Message *MessageSummary `json:"message,omitempty"`
+}
+
+// MessageChanges 增量同步结果 DTO
+type MessageChanges struct {
+	Changes []*MessageChange `json:"changes"`
+	// HighestModSeq 没有 next_cursor 时，客户端保存该值作为下次同步的 since_modseq
+	HighestModSeq uint64 `json:"highest_modseq"`
+	NextCursor    string `json:"next_cursor,omitempty"`
+}
+
+// NewMessageChanges 从增量同步结果转换为 DTO，nextCursor 为空表示已到末尾
+func NewMessageChanges(msgs []*datamodel.Message, highestModSeq uint64, nextCursor string, labelIDs map[uint64][]uint64) *MessageChanges {
+	resp := &MessageChanges{Changes: make([]*MessageChange, 0, len(msgs)), HighestModSeq: highestModSeq, NextCursor: nextCursor}
+	for _, m := range msgs {
+		change := &MessageChange{ID: m.ID, ModSeq: m.ModSeq}
+		if m.IsActive() {
+			change.Message = NewMessageSummaries([]*datamodel.Message{m}, labelIDs)[0]
+		} else {
+			change.Deleted = true
+		}
+		resp.Changes = append(resp.Changes, change)
+		if m.ModSeq > resp.HighestModSeq {
+			resp.HighestModSeq = m.ModSeq
+		}
+	}
+	return resp
+}
+
+// BulkUpdateResult 批量更新结果 DTO
+type BulkUpdateResult struct {
+	Updated int    `json:"updated"`
+	ModSeq  uint64 `json:"mod_seq,omitempty"`
+}
+
+// MessageBody 邮件正文 DTO，HTML 已经过清洗
+type MessageBody struct {
+	Text string `json:"text"`
+	HTML string `json:"html,omitempty"`
+}
+
+// MessageAttachment 附件清单项 DTO，id 为 0 表示附件尚未拆分，不能单独下载
+type MessageAttachment struct {
+	ID          uint64 `json:"id,omitempty"`
+	Index       int    `json:"index"`
+	Filename    string `json:"filename"`
+	ContentType string `json:"content_type"`
+	SniffedType string `json:"sniffed_type,omitempty"`
+	ContentID   string `json:"content_id,omitempty"`
+	Inline      bool   `json:"inline"`
+	Size        int64  `json:"size"`
+}
+
+// NewMessageAttachment 从附件记录转换为 DTO
+func NewMessageAttachment(a *datamodel.Attachment) *MessageAttachment {
+	return &MessageAttachment{
+		ID:          a.ID,
+		Index:       a.PartIndex,
+		Filename:    a.Filename,
+		ContentType: a.DeclaredType,
+		SniffedType: a.SniffedType,
+		ContentID:   a.ContentID,
+		Inline:      a.Inline,
+		Size:        a.Size,
+	}
+}
+
+// MessageAttachmentList 附件列表 DTO
+type MessageAttachmentList struct {
+	Attachments []*MessageAttachment `json:"attachments"`
+}
+
+// NewMessageAttachmentList 转换附件列表
+func NewMessageAttachmentList(attachments []*datamodel.Attachment) *MessageAttachmentList {
+	list := &MessageAttachmentList{Attachments: make([]*MessageAttachment, 0, len(attachments))}
+	for _, a := range attachments {
+		list.Attachments = append(list.Attachments, NewMessageAttachment(a))
+	}
+	return list
+}
+
+// MessageDetail 邮件详情 DTO
+type MessageDetail struct {
+	*MessageSummary
+	Cc          string               `json:"cc,omitempty"`
+	ReplyTo     string               `json:"reply_to,omitempty"`
+	InReplyTo   string               `json:"in_reply_to,omitempty"`
+	References  []string             `json:"references,omitempty"`
+	Body        *MessageBody         `json:"body"`
+	Attachments []*MessageAttachment `json:"attachments"`
+	// Spam 垃圾邮件评分命中的规则，未评分的邮件为空
+	Spam *SpamReport `json:"spam,omitempty"`
+	// Auth 发信方认证结果，外部邮箱同步等未检查的邮件为空
+	Auth *MessageAuth `json:"auth,omitempty"`
+}
+
+// SpamRule 命中的一条垃圾邮件规则，score 为负时降低垃圾邮件可能性
+type SpamRule struct {
+	Rule        string  `json:"rule"`
+	Score       float64 `json:"score"`
+	Description string  `json:"description"`
+}
+
+// SpamReport 垃圾邮件评分报告 DTO
+type SpamReport struct {
+	Score     float64     `json:"score"`
+	Threshold float64     `json:"threshold"`
+	Spam      bool        `json:"spam"`
+	Rules     []*SpamRule `json:"rules"`
+}
+
+// AuthCheck 一项认证检查的结果与被检查的域名
+type AuthCheck struct {
+	Result string `json:"result"`
+	Domain string `json:"domain,omitempty"`
+}
+
+// MessageAuth 发信方认证结果 DTO，spoofed 为 true 时客户端应提示发件人可能是伪造的
+type MessageAuth struct {
+	SPF         *AuthCheck `json:"spf"`
+	DKIM        *AuthCheck `json:"dkim"`
+	DMARC       *AuthCheck `json:"dmarc"`
+	DMARCPolicy string     `json:"dmarc_policy,omitempty"`
+	FromDomain  string     `json:"from_domain,omitempty"`
+	Spoofed     bool       `json:"spoofed"`
+}
+
+// NewMessageAuth 从邮件记录转换认证结果，未检查时返回 nil
+func NewMessageAuth(m *datamodel.Message) *MessageAuth {
+	if m.AuthDMARC == "" {
+		return nil
+	}
+	return &MessageAuth{
+		SPF:         &AuthCheck{Result: m.AuthSPF, Domain: m.AuthSPFDomain},
+		DKIM:        &AuthCheck{Result: m.AuthDKIM, Domain: m.AuthDKIMDomain},
+		DMARC:       &AuthCheck{Result: m.AuthDMARC, Domain: m.AuthFromDomain},
+		DMARCPolicy: m.AuthDMARCPolicy,
+		FromDomain:  m.AuthFromDomain,
+		Spoofed:     m.Spoofed(),
+	}
+}
+
+// MailboxUsage 邮箱存储用量 DTO，配额为 0 表示不限制
+type MailboxUsage struct {
+	Cohort           string `json:"cohort"`
+	Bytes            int64  `json:"bytes"`
+	Messages         int64  `json:"messages"`
+	MaxBytes         int64  `json:"max_bytes"`
+	MaxMessages      int64  `json:"max_messages"`
+	SoftLimitPercent int    `json:"soft_limit_percent"`
+	OverSoftLimit    bool   `json:"over_soft_limit"`
+	WarnedAt         int64  `json:"warned_at,omitempty"`
+	ReconciledAt     int64  `json:"reconciled_at,omitempty"`
+}
+
+// AttachmentExtract 历史邮件附件拆分结果 DTO
+type AttachmentExtract struct {
+	UserID   string `json:"user_id"`
+	Messages int    `json:"messages"`
+}
+
+// SearchReindex 全文索引重建结果 DTO
+type SearchReindex struct {
+	UserID   string `json:"user_id"`
+	Messages int    `json:"messages"`
+}
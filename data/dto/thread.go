@@ -0,0 +1,64 @@
+package dto
+
+import (
+	datamodel "plaud-emails/data/model"
+)
+
+// ThreadSummary 会话列表项 DTO
+type ThreadSummary struct {
+	ID              uint64   `json:"id"`
+	Subject         string   `json:"subject"`
+	MessageCount    int      `json:"message_count"`
+	Participants    []string `json:"participants"`
+	LatestMessageID uint64   `json:"latest_message_id"`
+	LatestFrom      string   `json:"latest_from"`
+	LatestSnippet   string   `json:"latest_snippet"`
+	LatestAt        int64    `json:"latest_at"`
+}
+
+// ThreadList 会话列表 DTO
+type ThreadList struct {
+	Threads    []*ThreadSummary `json:"threads"`
+	NextCursor string           `json:"next_cursor,omitempty"`
+}
+
+// NewThreadSummaryFromModel 从 Model 转换为 DTO
+func NewThreadSummaryFromModel(t *datamodel.MessageThread) *ThreadSummary {
+	if t == nil {
+		return nil
+	}
+	participants := []string(t.Participants)
+	if participants == nil {
+		participants = []string{}
+	}
+	return &ThreadSummary{
+		ID:              t.ID,
+		Subject:         t.Subject,
+		MessageCount:    t.MessageCount,
+		Participants:    participants,
+		LatestMessageID: t.LatestMessageID,
+		LatestFrom:      t.LatestFrom,
+		LatestSnippet:   t.LatestSnippet,
+		LatestAt:        t.LatestAt.UnixMilli(),
+	}
+}
+
+// ThreadMessage 会话详情中的邮件 DTO，按会话树深度优先排列
+type ThreadMessage struct {
+	*MessageSummary
+	ParentID uint64 `json:"parent_id,omitempty"`
+	Depth    int    `json:"depth"`
+}
+
+// ThreadDetail 会话详情 DTO
+type ThreadDetail struct {
+	*ThreadSummary
+	Messages  []*ThreadMessage `json:"messages"`
+	Truncated bool             `json:"truncated,omitempty"`
+}
+
+// ThreadRebuild 会话重建结果 DTO
+type ThreadRebuild struct {
+	UserID   string `json:"user_id"`
+	Messages int    `json:"messages"`
+}
@@ -0,0 +1,25 @@
+package model
+
+import "time"
+
+// Attachment 入库时从原始邮件中拆分出的附件，内容按 SHA-256 去重存放在 S3
+// 同一用户内容相同的附件共用一个对象，没有记录引用时才删除对象
+// Table name: mind_advisor_attachments
+type Attachment struct {
+	ID           uint64    `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+	UserID       string    `gorm:"column:user_id;type:varchar(128);not null;index:idx_user_id_sha256,priority:1" json:"user_id"`
+	MessageID    uint64    `gorm:"column:message_id;not null;index:idx_message_id" json:"message_id"` // 邮件记录 id
+	PartIndex    int       `gorm:"column:part_index;not null;default:0" json:"part_index"`            // 在解析结果附件清单中的序号
+	Filename     string    `gorm:"column:filename;type:varchar(255);not null;default:''" json:"filename"`
+	DeclaredType string    `gorm:"column:declared_type;type:varchar(255);not null;default:''" json:"declared_type"` // 邮件中声明的 Content-Type
+	SniffedType  string    `gorm:"column:sniffed_type;type:varchar(255);not null;default:''" json:"sniffed_type"`   // 根据内容识别的类型
+	Size         int64     `gorm:"column:size;not null;default:0" json:"size"`
+	ContentID    string    `gorm:"column:content_id;type:varchar(255);not null;default:''" json:"content_id"`
+	Inline       bool      `gorm:"column:inline;not null;default:false" json:"inline"`
+	SHA256       string    `gorm:"column:sha256;type:char(64);not null;index:idx_user_id_sha256,priority:2" json:"sha256"`
+	S3Bucket     string    `gorm:"column:s3_bucket;type:varchar(128);not null" json:"-"`
+	S3Key        string    `gorm:"column:s3_key;type:varchar(512);not null" json:"-"`
+	CreatedAt    time.Time `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+}
+
+func (Attachment) TableName() string { return "mind_advisor_attachments" }
@@ -67,6 +67,7 @@ type BetaInviteRegistration struct {
 	Email         string                  `gorm:"column:email;type:varchar(255);not null;uniqueIndex:uk_email" json:"email"`
 	Questionnaire Questionnaire           `gorm:"column:questionnaire;type:json;not null" json:"questionnaire"`
 	Extra         *BetaRegistrationExtra  `gorm:"column:extra;type:json" json:"extra,omitempty"`
+	Cohort        string                  `gorm:"column:cohort;type:varchar(32);not null;default:''" json:"cohort"` // 内测批次，决定存储配额档位
 	Status        int16                   `gorm:"column:status;not null;default:1;index:idx_status" json:"status"`
 	CreatedAt     time.Time               `gorm:"column:created_at;autoCreateTime" json:"created_at"`
 	UpdatedAt     time.Time               `gorm:"column:updated_at;autoUpdateTime" json:"updated_at"`
@@ -0,0 +1,33 @@
+package model
+
+import "time"
+
+// LinkedEmailSyncEvent 绑定邮箱同步状态迁移记录
+// Table name: linked_email_sync_events
+type LinkedEmailSyncEvent struct {
+	ID            uint64                `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+	LinkedEmailID uint64                `gorm:"column:linked_email_id;not null;index:idx_linked_email_id" json:"linked_email_id"`
+	UserID        string                `gorm:"column:user_id;type:varchar(128);not null" json:"user_id"`
+	FromStatus    LinkedEmailSyncStatus `gorm:"column:from_status;type:varchar(32);not null;default:''" json:"from_status"`
+	ToStatus      LinkedEmailSyncStatus `gorm:"column:to_status;type:varchar(32);not null" json:"to_status"`
+	Reason        string                `gorm:"column:reason;type:varchar(64);not null" json:"reason"`
+	Error         string                `gorm:"column:error;type:text" json:"error"`
+	CreatedAt     time.Time             `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+}
+
+func (LinkedEmailSyncEvent) TableName() string { return "linked_email_sync_events" }
+
+// LinkedEmail sync event reason constants
+const (
+	SyncReasonLinked      = "linked"         // 新绑定或重新授权
+	SyncReasonStarted     = "sync_started"   // 开始同步
+	SyncReasonLeaseReset  = "lease_reset"    // 上次同步的副本异常退出，租约过期后重新开始
+	SyncReasonSucceeded   = "sync_ok"        // 同步成功
+	SyncReasonFailed      = "sync_failed"    // 同步失败
+	SyncReasonThrottled   = "throttled"      // 服务商限流
+	SyncReasonAuthFailed  = "auth_failed"    // 凭据失效
+	SyncReasonRevoked     = "revoked"        // 授权已撤销
+	SyncReasonInterrupted = "interrupted"    // 服务停止导致同步中断
+	SyncReasonRemoved     = "removed"        // 用户解除绑定
+	SyncReasonQuota       = "quota_exceeded" // 专属邮箱存储配额已满
+)
@@ -0,0 +1,19 @@
+package model
+
+import "time"
+
+// MailboxBlocklistRule 管理后台维护的 local_part 屏蔽规则，与配置中的规则合并生效
+// Table name: mailbox_blocklist_rules
+type MailboxBlocklistRule struct {
+	ID uint64 `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+	// Match 匹配方式：exact、prefix、substring、regex
+	Match   string `gorm:"column:match_type;type:varchar(16);not null;uniqueIndex:uk_match_pattern,priority:1" json:"match"`
+	Pattern string `gorm:"column:pattern;type:varchar(128);not null;uniqueIndex:uk_match_pattern,priority:2" json:"pattern"`
+	// Category 分类：reserved、profanity、impersonation
+	Category  string    `gorm:"column:category;type:varchar(32);not null" json:"category"`
+	Note      string    `gorm:"column:note;type:varchar(255);not null;default:''" json:"note"`
+	CreatedAt time.Time `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+	UpdatedAt time.Time `gorm:"column:updated_at;autoUpdateTime" json:"updated_at"`
+}
+
+func (MailboxBlocklistRule) TableName() string { return "mailbox_blocklist_rules" }
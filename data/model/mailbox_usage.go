@@ -0,0 +1,18 @@
+package model
+
+import "time"
+
+// MailboxUsage 专属邮箱存储用量，随邮件入库与删除在同一事务中更新，定期根据邮件记录重新核算
+// Table name: mind_advisor_mailbox_usages
+type MailboxUsage struct {
+	ID           uint64     `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+	UserID       string     `gorm:"column:user_id;type:varchar(128);not null;uniqueIndex:uk_user_id" json:"user_id"`
+	Bytes        int64      `gorm:"column:bytes;not null;default:0" json:"bytes"`
+	Messages     int64      `gorm:"column:messages;not null;default:0" json:"messages"`
+	WarnedAt     *time.Time `gorm:"column:warned_at" json:"warned_at"` // 发送软限额提醒的时间，用量回落后清空
+	ReconciledAt *time.Time `gorm:"column:reconciled_at;index:idx_reconciled_at" json:"reconciled_at"`
+	CreatedAt    time.Time  `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+	UpdatedAt    time.Time  `gorm:"column:updated_at;autoUpdateTime" json:"updated_at"`
+}
+
+func (MailboxUsage) TableName() string { return "mind_advisor_mailbox_usages" }
@@ -0,0 +1,109 @@
+package model
+
+import "time"
+
+// Message 心智幕僚收件表，原始 MIME 存放在 S3，这里只索引头部信息
+// Table name: mind_advisor_messages
+type Message struct {
+	ID                  uint64     `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+	UserID              string     `gorm:"column:user_id;type:varchar(128);not null;index:idx_user_id_status,priority:1;index:idx_user_id_tag,priority:1;index:idx_user_id_thread,priority:1;index:idx_user_id_mod_seq,priority:1" json:"user_id"`
+	DedicatedEmail      string     `gorm:"column:dedicated_email;type:varchar(255);not null" json:"dedicated_email"`
+	Alias               string     `gorm:"column:alias;type:varchar(255);not null;default:''" json:"alias"`
+	Tag                 string     `gorm:"column:tag;type:varchar(64);not null;default:'';index:idx_user_id_tag,priority:2" json:"tag"`
+	Label               string     `gorm:"column:label;type:varchar(64);not null;default:''" json:"label"`
+	Muted               bool       `gorm:"column:muted;not null;default:false" json:"muted"`
+	Folder              string     `gorm:"column:folder;type:varchar(16);not null;default:'inbox'" json:"folder"` // 所在的系统文件夹，见 Folder 常量
+	Seen                bool       `gorm:"column:seen;not null;default:false" json:"seen"`
+	Starred             bool       `gorm:"column:starred;not null;default:false" json:"starred"`
+	Important           bool       `gorm:"column:important;not null;default:false" json:"important"`
+	ModSeq              uint64     `gorm:"column:mod_seq;not null;default:0;index:idx_user_id_mod_seq,priority:2" json:"mod_seq"` // 最近一次状态变化时邮箱的 modseq
+	MessageID           string     `gorm:"column:message_id;type:varchar(255);not null;default:'';index:idx_message_id" json:"message_id"`
+	InReplyTo           string     `gorm:"column:in_reply_to;type:varchar(255);not null;default:''" json:"in_reply_to"`
+	ReferenceIDs        string     `gorm:"column:reference_ids;type:text" json:"reference_ids"` // References 头中的 msg-id，空格分隔
+	ThreadID            uint64     `gorm:"column:thread_id;not null;default:0;index:idx_user_id_thread,priority:2" json:"thread_id"`
+	FromAddr            string     `gorm:"column:from_addr;type:varchar(512);not null;default:''" json:"from_addr"`
+	ToAddrs             string     `gorm:"column:to_addrs;type:text" json:"to_addrs"`
+	Subject             string     `gorm:"column:subject;type:varchar(1024);not null;default:''" json:"subject"`
+	SentAt              *time.Time `gorm:"column:sent_at" json:"sent_at"`
+	Size                int64      `gorm:"column:size;not null;default:0" json:"size"`
+	HasAttachments      bool       `gorm:"column:has_attachments;not null;default:false" json:"has_attachments"`
+	Snippet             string     `gorm:"column:snippet;type:varchar(512);not null;default:''" json:"snippet"`
+	Source              string     `gorm:"column:source;type:varchar(32);not null" json:"source"`
+	EnvelopeFrom        string     `gorm:"column:envelope_from;type:varchar(512);not null;default:''" json:"envelope_from"`
+	RemoteIP            string     `gorm:"column:remote_ip;type:varchar(64);not null;default:''" json:"remote_ip"`
+	LinkedEmailID       uint64     `gorm:"column:linked_email_id;not null;default:0;index:idx_linked_email_external,priority:1" json:"linked_email_id"`
+	ExternalID          string     `gorm:"column:external_id;type:varchar(255);not null;default:'';index:idx_linked_email_external,priority:2" json:"external_id"`
+	S3Bucket            string     `gorm:"column:s3_bucket;type:varchar(128);not null" json:"-"`
+	S3Key               string     `gorm:"column:s3_key;type:varchar(512);not null" json:"-"`
+	Status              int16      `gorm:"column:status;not null;default:1;index:idx_user_id_status,priority:2" json:"status"`
+	LegalHold           bool       `gorm:"column:legal_hold;not null;default:false" json:"legal_hold"` // 法律保留，保留期到期后也不删除
+	ExpiredAt           *time.Time `gorm:"column:expired_at" json:"expired_at"`                        // 保留期到期被清除的时间，S3 对象已删除，记录作为墓碑保留
+	AttachmentsPurgedAt *time.Time `gorm:"column:attachments_purged_at" json:"attachments_purged_at"`  // 附件保留期到期后从原始邮件中移除附件的时间
+	SpamScore           float64    `gorm:"column:spam_score;not null;default:0" json:"spam_score"`
+	SpamReport          string     `gorm:"column:spam_report;type:text" json:"-"`                                // 评分命中的规则，JSON 格式，见 spam.Report
+	SpamTrained         int8       `gorm:"column:spam_trained;not null;default:0" json:"spam_trained"`           // 用户训练的分类，见 SpamTrained 常量
+	AuthSPF             string     `gorm:"column:auth_spf;type:varchar(16);not null;default:''" json:"auth_spf"` // 发信方认证结果，未检查（如外部邮箱同步的邮件）时为空
+	AuthSPFDomain       string     `gorm:"column:auth_spf_domain;type:varchar(255);not null;default:''" json:"auth_spf_domain"`
+	AuthDKIM            string     `gorm:"column:auth_dkim;type:varchar(16);not null;default:''" json:"auth_dkim"`
+	AuthDKIMDomain      string     `gorm:"column:auth_dkim_domain;type:varchar(255);not null;default:''" json:"auth_dkim_domain"`
+	AuthDMARC           string     `gorm:"column:auth_dmarc;type:varchar(16);not null;default:''" json:"auth_dmarc"`
+	AuthDMARCPolicy     string     `gorm:"column:auth_dmarc_policy;type:varchar(16);not null;default:''" json:"auth_dmarc_policy"`
+	AuthFromDomain      string     `gorm:"column:auth_from_domain;type:varchar(255);not null;default:''" json:"auth_from_domain"`
+	ReceivedAt          time.Time  `gorm:"column:received_at;not null;index:idx_received_at" json:"received_at"`
+	CreatedAt           time.Time  `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+	UpdatedAt           time.Time  `gorm:"column:updated_at;autoUpdateTime" json:"updated_at"`
+}
+
+func (Message) TableName() string { return "mind_advisor_messages" }
+
+// Message status constants
+const (
+	MessageStatusActive  int16 = 1   // 正常
+	MessageStatusDeleted int16 = 127 // 已删除
+)
+
+// Message source constants
+const (
+	MessageSourceSMTP    = "smtp"
+	MessageSourceIMAP    = "imap"
+	MessageSourceGmail   = "gmail"
+	MessageSourceOutlook = "outlook"
+	MessageSourceSystem  = "system" // 系统通知，如存储软限额提醒
+)
+
+// Folder constants，系统标签，一封邮件只属于其中一个
+const (
+	FolderInbox   = "inbox"
+	FolderArchive = "archive"
+	FolderTrash   = "trash"
+	FolderSpam    = "spam"
+)
+
+// Folders 全部系统文件夹
+var Folders = []string{FolderInbox, FolderArchive, FolderTrash, FolderSpam}
+
+// IsFolder 是否为系统文件夹
+func IsFolder(name string) bool {
+	for _, f := range Folders {
+		if f == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Auth result constants，与 Authentication-Results 头中的取值一致
+const (
+	AuthResultPass = "pass"
+	AuthResultFail = "fail"
+)
+
+// Spoofed 发件域名发布了 DMARC 策略，但邮件没有通过对齐的 SPF 或 DKIM 验证，From 很可能是伪造的
+func (m *Message) Spoofed() bool {
+	return m.AuthDMARC == AuthResultFail
+}
+
+// IsActive 是否有效
+func (m *Message) IsActive() bool {
+	return m.Status == MessageStatusActive
+}
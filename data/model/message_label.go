@@ -0,0 +1,27 @@
+package model
+
+import "time"
+
+// UserLabel 用户自定义标签，系统标签（文件夹）见 Folder 常量
+// Table name: mind_advisor_labels
+type UserLabel struct {
+	ID        uint64    `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+	UserID    string    `gorm:"column:user_id;type:varchar(128);not null;uniqueIndex:uk_user_id_name,priority:1" json:"user_id"`
+	Name      string    `gorm:"column:name;type:varchar(64);not null;uniqueIndex:uk_user_id_name,priority:2" json:"name"`
+	CreatedAt time.Time `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+	UpdatedAt time.Time `gorm:"column:updated_at;autoUpdateTime" json:"updated_at"`
+}
+
+func (UserLabel) TableName() string { return "mind_advisor_labels" }
+
+// MessageLabel 邮件与用户标签的关联
+// Table name: mind_advisor_message_labels
+type MessageLabel struct {
+	ID        uint64    `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+	UserID    string    `gorm:"column:user_id;type:varchar(128);not null;index:idx_user_id" json:"user_id"`
+	MessageID uint64    `gorm:"column:message_id;not null;uniqueIndex:uk_message_id_label_id,priority:1;index:idx_label_id_message_id,priority:2" json:"message_id"` // 邮件记录 id
+	LabelID   uint64    `gorm:"column:label_id;not null;uniqueIndex:uk_message_id_label_id,priority:2;index:idx_label_id_message_id,priority:1" json:"label_id"`
+	CreatedAt time.Time `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+}
+
+func (MessageLabel) TableName() string { return "mind_advisor_message_labels" }
@@ -0,0 +1,23 @@
+package model
+
+import "time"
+
+// MessageSearchDoc 邮件全文索引文档，入库时写入，删除邮件时一并删除
+// 全文索引使用 ngram 分词，支持中文等不以空格分词的语言
+// Table name: mind_advisor_message_search
+type MessageSearchDoc struct {
+	ID             uint64    `gorm:"column:id;primaryKey;autoIncrement:false" json:"id"` // 邮件记录 id
+	UserID         string    `gorm:"column:user_id;type:varchar(128);not null;index:idx_user_id_id,priority:1" json:"user_id"`
+	FromText       string    `gorm:"column:from_text;type:varchar(1024);not null;default:'';index:ft_from,class:FULLTEXT,option:WITH PARSER ngram;index:ft_content,class:FULLTEXT,option:WITH PARSER ngram,priority:1" json:"from_text"`
+	ToText         string    `gorm:"column:to_text;type:text;index:ft_to,class:FULLTEXT,option:WITH PARSER ngram" json:"to_text"`
+	Subject        string    `gorm:"column:subject;type:varchar(1024);not null;default:'';index:ft_subject,class:FULLTEXT,option:WITH PARSER ngram;index:ft_content,priority:2" json:"subject"`
+	Body           string    `gorm:"column:body;type:mediumtext;index:ft_content,priority:3" json:"body"`
+	Filenames      string    `gorm:"column:filenames;type:text;index:ft_filenames,class:FULLTEXT,option:WITH PARSER ngram;index:ft_content,priority:4" json:"filenames"` // 附件文件名，换行分隔
+	Tag            string    `gorm:"column:tag;type:varchar(64);not null;default:''" json:"tag"`
+	HasAttachments bool      `gorm:"column:has_attachments;not null;default:false" json:"has_attachments"`
+	ReceivedAt     time.Time `gorm:"column:received_at;not null" json:"received_at"`
+	CreatedAt      time.Time `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+	UpdatedAt      time.Time `gorm:"column:updated_at;autoUpdateTime" json:"updated_at"`
+}
+
+func (MessageSearchDoc) TableName() string { return "mind_advisor_message_search" }
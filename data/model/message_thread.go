@@ -0,0 +1,79 @@
+package model
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// MaxThreadParticipants 会话最多记录的参与者数量
+const MaxThreadParticipants = 20
+
+// ThreadParticipants 会话参与者地址列表
+type ThreadParticipants []string
+
+// Add 追加参与者，已存在或超出上限时忽略，返回是否追加
+func (p *ThreadParticipants) Add(address string) bool {
+	if address == "" || len(*p) >= MaxThreadParticipants {
+		return false
+	}
+	for _, a := range *p {
+		if a == address {
+			return false
+		}
+	}
+	*p = append(*p, address)
+	return true
+}
+
+// Value 实现 driver.Valuer 接口
+func (p ThreadParticipants) Value() (driver.Value, error) {
+	if p == nil {
+		p = ThreadParticipants{}
+	}
+	return json.Marshal([]string(p))
+}
+
+// Scan 实现 sql.Scanner 接口
+func (p *ThreadParticipants) Scan(value interface{}) error {
+	if value == nil {
+		return nil
+	}
+	bytes, ok := value.([]byte)
+	if !ok {
+		return errors.New("type assertion to []byte failed")
+	}
+	return json.Unmarshal(bytes, p)
+}
+
+// MessageThread 邮件会话，按 JWZ 算法将互相引用的邮件归为一组
+// Table name: mind_advisor_threads
+type MessageThread struct {
+	ID              uint64             `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+	UserID          string             `gorm:"column:user_id;type:varchar(128);not null;index:idx_user_id_latest,priority:1;index:idx_user_id_subject_key,priority:1" json:"user_id"`
+	Subject         string             `gorm:"column:subject;type:varchar(1024);not null;default:''" json:"subject"`                                                 // 会话首封邮件的主题
+	SubjectKey      string             `gorm:"column:subject_key;type:varchar(255);not null;default:'';index:idx_user_id_subject_key,priority:2" json:"subject_key"` // 去掉 Re:、Fwd: 等前缀后的主题，用于主题回退匹配
+	MessageCount    int                `gorm:"column:message_count;not null;default:0" json:"message_count"`
+	Participants    ThreadParticipants `gorm:"column:participants;type:json" json:"participants"`
+	LatestMessageID uint64             `gorm:"column:latest_message_id;not null;default:0;index:idx_user_id_latest,priority:2" json:"latest_message_id"`
+	LatestFrom      string             `gorm:"column:latest_from;type:varchar(512);not null;default:''" json:"latest_from"`
+	LatestSnippet   string             `gorm:"column:latest_snippet;type:varchar(512);not null;default:''" json:"latest_snippet"`
+	LatestAt        time.Time          `gorm:"column:latest_at;not null" json:"latest_at"`
+	CreatedAt       time.Time          `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+	UpdatedAt       time.Time          `gorm:"column:updated_at;autoUpdateTime" json:"updated_at"`
+}
+
+func (MessageThread) TableName() string { return "mind_advisor_threads" }
+
+// MessageThreadRef Message-ID 到会话的映射，包括被引用但尚未收到的邮件，入库时据此 O(1) 定位会话
+// Table name: mind_advisor_thread_refs
+type MessageThreadRef struct {
+	ID         uint64    `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+	UserID     string    `gorm:"column:user_id;type:varchar(128);not null;uniqueIndex:uk_user_id_message_ref,priority:1" json:"user_id"`
+	MessageRef string    `gorm:"column:message_ref;type:varchar(255);not null;uniqueIndex:uk_user_id_message_ref,priority:2" json:"message_ref"`
+	ThreadID   uint64    `gorm:"column:thread_id;not null;index:idx_thread_id" json:"thread_id"`
+	CreatedAt  time.Time `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+}
+
+func (MessageThreadRef) TableName() string { return "mind_advisor_thread_refs" }
@@ -0,0 +1,14 @@
+package model
+
+import "time"
+
+// MindAdvisorAlias 心智幕僚用户的别名邮箱，收信时解析为所属用户
+// Table name: mind_advisor_aliases
+type MindAdvisorAlias struct {
+	ID        uint64    `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+	UserID    string    `gorm:"column:user_id;type:varchar(128);not null;index:idx_user_id" json:"user_id"`
+	Address   string    `gorm:"column:address;type:varchar(255);not null;uniqueIndex:uk_address" json:"address"`
+	CreatedAt time.Time `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+}
+
+func (MindAdvisorAlias) TableName() string { return "mind_advisor_aliases" }
@@ -9,7 +9,37 @@ import (
 
 // LinkedEmailExtra 绑定邮箱扩展信息
 type LinkedEmailExtra struct {
-	// 预留字段
+	VerifyToken       string `json:"verify_token,omitempty"`        // 转发验证令牌
+	VerifyMethod      string `json:"verify_method,omitempty"`       // 验证方式
+	VerifiedMessageID uint64 `json:"verified_message_id,omitempty"` // 完成验证的邮件 id
+	ConfirmationCode  string `json:"confirmation_code,omitempty"`   // 邮件服务商下发的转发确认码
+	ConfirmationURL   string `json:"confirmation_url,omitempty"`    // 邮件服务商下发的转发确认链接
+
+	IMAP          *IMAPAccount     `json:"imap,omitempty"`            // IMAP 账户与同步游标
+	OAuth         *OAuthCredential `json:"oauth,omitempty"`           // OAuth2 授权凭据
+	SyncCursor    string           `json:"sync_cursor,omitempty"`     // API 同步游标，如 Gmail history id、Graph delta 链接
+	ResyncSince   *time.Time       `json:"resync_since,omitempty"`    // 全量同步进行中时跳过早于该时间的邮件
+	LastSyncError string           `json:"last_sync_error,omitempty"` // 最近一次同步失败原因
+}
+
+// OAuthCredential OAuth2 授权凭据，令牌以 secretbox 密文存放
+type OAuthCredential struct {
+	AccessToken  string     `json:"access_token"`
+	RefreshToken string     `json:"refresh_token"`
+	Expiry       *time.Time `json:"expiry,omitempty"`
+	Scope        string     `json:"scope,omitempty"`
+}
+
+// IMAPAccount IMAP 账户信息，密码以 secretbox 密文存放
+type IMAPAccount struct {
+	Host        string `json:"host"`
+	Port        int    `json:"port"`
+	TLS         bool   `json:"tls"`
+	Username    string `json:"username"`
+	Password    string `json:"password"`
+	Mailbox     string `json:"mailbox"`
+	UIDValidity uint32 `json:"uid_validity"`
+	LastUID     uint32 `json:"last_uid"`
 }
 
 // Value 实现 driver.Valuer 接口
@@ -32,18 +62,20 @@ func (e *LinkedEmailExtra) Scan(value interface{}) error {
 // MindAdvisorLinkedEmail 心智幕僚用户绑定外部邮箱表
 // Table name: mind_advisor_linked_emails
 type MindAdvisorLinkedEmail struct {
-	ID         uint64            `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
-	UserID     string            `gorm:"column:user_id;type:varchar(128);not null;uniqueIndex:uk_user_email,priority:1;index:idx_user_id" json:"user_id"`
-	Email      string            `gorm:"column:email;type:varchar(255);not null;uniqueIndex:uk_user_email,priority:2;index:idx_email" json:"email"`
-	Source     string            `gorm:"column:source;type:varchar(32);not null" json:"source"`
-	Verified   bool              `gorm:"column:verified;not null;default:0" json:"verified"`
-	VerifiedAt *time.Time        `gorm:"column:verified_at" json:"verified_at"`
-	SyncStatus *string           `gorm:"column:sync_status;type:varchar(32)" json:"sync_status"`
-	LastSyncAt *time.Time        `gorm:"column:last_sync_at" json:"last_sync_at"`
-	Extra      *LinkedEmailExtra `gorm:"column:extra;type:json" json:"extra"`
-	Status     int16             `gorm:"column:status;not null;default:1" json:"status"`
-	CreatedAt  time.Time         `gorm:"column:created_at;autoCreateTime" json:"created_at"`
-	UpdatedAt  time.Time         `gorm:"column:updated_at;autoUpdateTime" json:"updated_at"`
+	ID           uint64                 `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+	UserID       string                 `gorm:"column:user_id;type:varchar(128);not null;uniqueIndex:uk_user_email,priority:1;index:idx_user_id" json:"user_id"`
+	Email        string                 `gorm:"column:email;type:varchar(255);not null;uniqueIndex:uk_user_email,priority:2;index:idx_email" json:"email"`
+	Source       string                 `gorm:"column:source;type:varchar(32);not null" json:"source"`
+	Verified     bool                   `gorm:"column:verified;not null;default:0" json:"verified"`
+	VerifiedAt   *time.Time             `gorm:"column:verified_at" json:"verified_at"`
+	SyncStatus   *LinkedEmailSyncStatus `gorm:"column:sync_status;type:varchar(32)" json:"sync_status"`
+	LastSyncAt   *time.Time             `gorm:"column:last_sync_at" json:"last_sync_at"`
+	NextSyncAt   *time.Time             `gorm:"column:next_sync_at;index:idx_next_sync_at" json:"next_sync_at"`
+	SyncFailures int                    `gorm:"column:sync_failures;not null;default:0" json:"sync_failures"`
+	Extra        *LinkedEmailExtra      `gorm:"column:extra;type:json" json:"extra"`
+	Status       int16                  `gorm:"column:status;not null;default:1" json:"status"`
+	CreatedAt    time.Time              `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+	UpdatedAt    time.Time              `gorm:"column:updated_at;autoUpdateTime" json:"updated_at"`
 }
 
 func (MindAdvisorLinkedEmail) TableName() string { return "mind_advisor_linked_emails" }
@@ -57,7 +89,57 @@ const (
 	LinkedEmailSourceManual     = "manual"
 )
 
+// LinkedEmail verify method constants
+const (
+	LinkedEmailVerifyToken             = "token"              // 邮件中携带验证令牌
+	LinkedEmailVerifyGmailForwarding   = "gmail_forwarding"   // 通过认证的 Gmail 转发确认邮件
+	LinkedEmailVerifyOutlookForwarding = "outlook_forwarding" // 通过认证的 Outlook 转发确认邮件
+	LinkedEmailVerifyGmailConfirmation = "gmail_confirmation" // 未校验发件人认证结果的 Gmail 转发确认邮件，已不再接受
+	LinkedEmailVerifyForwardedHeader   = "forwarded_header"   // 转发邮件头（X-Forwarded-For 等），可被伪造，已不再接受
+	LinkedEmailVerifyIMAPLogin         = "imap_login"         // IMAP 登录成功
+	LinkedEmailVerifyOAuth             = "oauth"              // OAuth2 授权成功
+)
+
+// LinkedEmailSyncStatus 绑定邮箱同步状态，合法的迁移由 mailsync 状态机校验
+type LinkedEmailSyncStatus string
+
+// LinkedEmail sync status constants
+// pending -> syncing -> ok/backoff/auth_error/disabled，ok 与 backoff 到期后再次进入 syncing
+// auth_error 与 disabled 不再调度，用户重新授权后回到 pending
+const (
+	LinkedEmailSyncStatusNone      LinkedEmailSyncStatus = ""           // 未参与同步，如转发接入的邮箱
+	LinkedEmailSyncStatusPending   LinkedEmailSyncStatus = "pending"    // 等待首次同步
+	LinkedEmailSyncStatusSyncing   LinkedEmailSyncStatus = "syncing"    // 同步中
+	LinkedEmailSyncStatusOK        LinkedEmailSyncStatus = "ok"         // 最近一次同步成功
+	LinkedEmailSyncStatusAuthError LinkedEmailSyncStatus = "auth_error" // 凭据失效，需要用户重新授权
+	LinkedEmailSyncStatusBackoff   LinkedEmailSyncStatus = "backoff"    // 同步失败或被限流，退避后重试
+	LinkedEmailSyncStatusDisabled  LinkedEmailSyncStatus = "disabled"   // 绑定已停用，不再同步
+)
+
 // IsActive 是否有效
 func (m *MindAdvisorLinkedEmail) IsActive() bool {
 	return m.Status == MindAdvisorStatusActive
 }
+
+// IsTrustedVerified 是否已通过可信方式验证，历史上通过转发邮件头或未认证的 Gmail 确认邮件验证的绑定需重新验证
+func (m *MindAdvisorLinkedEmail) IsTrustedVerified() bool {
+	if !m.Verified {
+		return false
+	}
+	if m.Extra == nil {
+		return true
+	}
+	switch m.Extra.VerifyMethod {
+	case LinkedEmailVerifyForwardedHeader, LinkedEmailVerifyGmailConfirmation:
+		return false
+	}
+	return true
+}
+
+// CurrentSyncStatus 当前同步状态，未参与同步时为 LinkedEmailSyncStatusNone
+func (m *MindAdvisorLinkedEmail) CurrentSyncStatus() LinkedEmailSyncStatus {
+	if m.SyncStatus == nil {
+		return LinkedEmailSyncStatusNone
+	}
+	return *m.SyncStatus
+}
@@ -0,0 +1,32 @@
+package model
+
+import "time"
+
+// MindAdvisorMailboxAudit 专属邮箱状态变更审计记录，邮箱清除后仍保留
+// Table name: mind_advisor_mailbox_audits
+type MindAdvisorMailboxAudit struct {
+	ID             uint64    `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+	UserID         string    `gorm:"column:user_id;type:varchar(128);not null;index:idx_user_id" json:"user_id"`
+	DedicatedEmail string    `gorm:"column:dedicated_email;type:varchar(255);not null" json:"dedicated_email"`
+	Action         string    `gorm:"column:action;type:varchar(32);not null" json:"action"`
+	FromStatus     int16     `gorm:"column:from_status;not null" json:"from_status"`
+	ToStatus       int16     `gorm:"column:to_status;not null" json:"to_status"`
+	Actor          string    `gorm:"column:actor;type:varchar(128);not null" json:"actor"`
+	Reason         string    `gorm:"column:reason;type:varchar(512);not null;default:''" json:"reason"`
+	CreatedAt      time.Time `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+}
+
+func (MindAdvisorMailboxAudit) TableName() string { return "mind_advisor_mailbox_audits" }
+
+// Mailbox audit action constants
+const (
+	MailboxAuditDeactivate = "deactivate" // 停用，新邮件被退回
+	MailboxAuditReactivate = "reactivate" // 重新启用
+	MailboxAuditDelete     = "delete"     // 软删除，保留期后清除
+	MailboxAuditPurge      = "purge"      // 清除邮件、绑定邮箱与别名
+	MailboxAuditHold       = "legal_hold" // 设置法律保留
+	MailboxAuditRelease    = "release"    // 解除法律保留
+)
+
+// MailboxAuditActorSystem 后台任务执行的变更
+const MailboxAuditActorSystem = "system"
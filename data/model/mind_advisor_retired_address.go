@@ -0,0 +1,27 @@
+package model
+
+import "time"
+
+// MindAdvisorRetiredAddress 用户更换 local_part 后保留的旧专属邮箱
+// RouteUntil 之前仍投递给原用户，QuarantineUntil 之前其他用户不能申请
+// Table name: mind_advisor_retired_addresses
+type MindAdvisorRetiredAddress struct {
+	ID              uint64    `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+	UserID          string    `gorm:"column:user_id;type:varchar(128);not null;index:idx_user_id" json:"user_id"`
+	Address         string    `gorm:"column:address;type:varchar(255);not null;uniqueIndex:uk_address" json:"address"`
+	RouteUntil      time.Time `gorm:"column:route_until;not null" json:"route_until"`
+	QuarantineUntil time.Time `gorm:"column:quarantine_until;not null" json:"quarantine_until"`
+	CreatedAt       time.Time `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+}
+
+func (MindAdvisorRetiredAddress) TableName() string { return "mind_advisor_retired_addresses" }
+
+// RoutesAt 在 t 时刻是否仍投递给原用户
+func (a *MindAdvisorRetiredAddress) RoutesAt(t time.Time) bool {
+	return t.Before(a.RouteUntil)
+}
+
+// QuarantinedAt 在 t 时刻是否仍禁止其他用户申请
+func (a *MindAdvisorRetiredAddress) QuarantinedAt(t time.Time) bool {
+	return t.Before(a.QuarantineUntil)
+}
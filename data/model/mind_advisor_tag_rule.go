@@ -0,0 +1,21 @@
+package model
+
+import "time"
+
+// MindAdvisorTagRule 子地址标签规则，作用于投递到 local_part+tag@myplaud 的邮件
+// Table name: mind_advisor_tag_rules
+type MindAdvisorTagRule struct {
+	ID     uint64 `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+	UserID string `gorm:"column:user_id;type:varchar(128);not null;uniqueIndex:uk_user_id_tag,priority:1" json:"user_id"`
+	Tag    string `gorm:"column:tag;type:varchar(64);not null;uniqueIndex:uk_user_id_tag,priority:2" json:"tag"`
+	// Label 自动添加的标签，为空表示不添加
+	Label string `gorm:"column:label;type:varchar(64);not null;default:''" json:"label"`
+	// Mute 静音的邮件仍会保存，但默认不出现在收件列表中
+	Mute bool `gorm:"column:mute;not null;default:false" json:"mute"`
+	// ForwardLinkedEmailID 自动转发到的绑定邮箱，0 表示不转发
+	ForwardLinkedEmailID uint64    `gorm:"column:forward_linked_email_id;not null;default:0" json:"forward_linked_email_id"`
+	CreatedAt            time.Time `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+	UpdatedAt            time.Time `gorm:"column:updated_at;autoUpdateTime" json:"updated_at"`
+}
+
+func (MindAdvisorTagRule) TableName() string { return "mind_advisor_tag_rules" }
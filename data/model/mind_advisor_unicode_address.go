@@ -0,0 +1,18 @@
+package model
+
+import "time"
+
+// MindAdvisorUnicodeAddress 国际化地址（专属邮箱或别名）的附加信息
+// Skeleton 用于检测视觉上易混淆的地址，FallbackAddress 是自动生成的 ASCII 地址，
+// 供不支持 SMTPUTF8 的发件方投递
+// Table name: mind_advisor_unicode_addresses
+type MindAdvisorUnicodeAddress struct {
+	ID              uint64    `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+	UserID          string    `gorm:"column:user_id;type:varchar(128);not null;index:idx_user_id" json:"user_id"`
+	Address         string    `gorm:"column:address;type:varchar(255);not null;uniqueIndex:uk_address" json:"address"`
+	Skeleton        string    `gorm:"column:skeleton;type:varchar(255);not null;index:idx_skeleton" json:"skeleton"`
+	FallbackAddress string    `gorm:"column:fallback_address;type:varchar(255);not null;uniqueIndex:uk_fallback_address" json:"fallback_address"`
+	CreatedAt       time.Time `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+}
+
+func (MindAdvisorUnicodeAddress) TableName() string { return "mind_advisor_unicode_addresses" }
@@ -7,13 +7,54 @@ import (
 	"time"
 )
 
+// MindAdvisorUserConfigVersion 用户配置 JSON 的当前结构版本，结构变化时递增并在 migrate 中升级旧数据
+// 版本 0：只有 salutation，取值为 Mr 或 Mrs
+// 版本 1：增加显示名、语言、时区、摘要计划与自动回复，salutation 改为自由文本
+const MindAdvisorUserConfigVersion = 1
+
 // MindAdvisorUserConfig 心智幕僚用户配置
 type MindAdvisorUserConfig struct {
-	Salutation string `json:"salutation"` // Mr 或 Mrs
+	Version     int             `json:"version"`
+	Salutation  string          `json:"salutation"` // 称呼，如 Mr、Ms、Dr 或本地化的称呼，空表示不使用
+	DisplayName string          `json:"display_name,omitempty"`
+	Language    string          `json:"language,omitempty"` // BCP 47 语言标签
+	Timezone    string          `json:"timezone,omitempty"` // IANA 时区名
+	Digest      *DigestSchedule `json:"digest,omitempty"`
+	AutoReply   *AutoReply      `json:"auto_reply,omitempty"`
+}
+
+// DigestSchedule 邮件摘要推送计划，时间按用户时区计算
+type DigestSchedule struct {
+	Frequency string `json:"frequency"`         // off、daily 或 weekly
+	Hour      int    `json:"hour"`              // 0-23
+	Weekday   int    `json:"weekday,omitempty"` // 0-6，0 为周日，仅 weekly 使用
+}
+
+// Digest frequency constants
+const (
+	DigestFrequencyOff    = "off"
+	DigestFrequencyDaily  = "daily"
+	DigestFrequencyWeekly = "weekly"
+)
+
+// AutoReply 自动回复
+type AutoReply struct {
+	Enabled bool   `json:"enabled"`
+	Subject string `json:"subject,omitempty"`
+	Body    string `json:"body"`
+}
+
+// migrate 将旧版本的配置升级到当前版本
+func (c *MindAdvisorUserConfig) migrate() {
+	if c.Version < 1 {
+		// 版本 0 的 Mr/Mrs 在版本 1 中仍是合法的称呼，无需转换
+		c.Version = 1
+	}
 }
 
-// Value 实现 driver.Valuer 接口
+// Value 实现 driver.Valuer 接口，始终以当前版本写入
 func (c MindAdvisorUserConfig) Value() (driver.Value, error) {
+	c.Version = MindAdvisorUserConfigVersion
 	return json.Marshal(c)
 }
 
@@ -26,7 +67,11 @@ func (c *MindAdvisorUserConfig) Scan(value interface{}) error {
 	if !ok {
 		return errors.New("type assertion to []byte failed")
 	}
-	return json.Unmarshal(bytes, c)
+	if err := json.Unmarshal(bytes, c); err != nil {
+		return err
+	}
+	c.migrate()
+	return nil
 }
 
 // MindAdvisorUser 心智幕僚用户表
@@ -37,6 +82,12 @@ type MindAdvisorUser struct {
 	DedicatedEmail string                 `gorm:"column:dedicated_email;type:varchar(255);not null;uniqueIndex:uk_dedicated_email" json:"dedicated_email"`
 	Config         *MindAdvisorUserConfig `gorm:"column:config;type:json" json:"config"`
 	Status         int16                  `gorm:"column:status;not null;default:1;index:idx_status" json:"status"`
+	RenamedAt      *time.Time             `gorm:"column:renamed_at" json:"renamed_at"`
+	DeactivatedAt  *time.Time             `gorm:"column:deactivated_at" json:"deactivated_at"`
+	DeletedAt      *time.Time             `gorm:"column:deleted_at" json:"deleted_at"`
+	PurgeAt        *time.Time             `gorm:"column:purge_at;index:idx_purge_at" json:"purge_at"`         // 软删除后清除数据的时间
+	LegalHold      bool                   `gorm:"column:legal_hold;not null;default:false" json:"legal_hold"` // 法律保留，暂停保留期清理与邮箱清除
+	ModSeq         uint64                 `gorm:"column:mod_seq;not null;default:0" json:"mod_seq"`           // 邮箱的 modseq，邮件新增、状态变化或删除时递增
 	CreatedAt      time.Time              `gorm:"column:created_at;autoCreateTime" json:"created_at"`
 	UpdatedAt      time.Time              `gorm:"column:updated_at;autoUpdateTime" json:"updated_at"`
 }
@@ -54,3 +105,8 @@ const (
 func (m *MindAdvisorUser) IsActive() bool {
 	return m.Status == MindAdvisorStatusActive
 }
+
+// IsDeleted 是否已软删除
+func (m *MindAdvisorUser) IsDeleted() bool {
+	return m.Status == MindAdvisorStatusSoftDeleted
+}
@@ -0,0 +1,16 @@
+package model
+
+import "time"
+
+// RetentionPolicy 用户的邮件保留策略，字段为 NULL 时沿用全局规则
+// Table name: mind_advisor_retention_policies
+type RetentionPolicy struct {
+	ID             uint64    `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+	UserID         string    `gorm:"column:user_id;type:varchar(128);not null;uniqueIndex:uk_user_id" json:"user_id"`
+	MessageDays    *int      `gorm:"column:message_days" json:"message_days"`       // 邮件保留天数
+	AttachmentDays *int      `gorm:"column:attachment_days" json:"attachment_days"` // 附件保留天数
+	CreatedAt      time.Time `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+	UpdatedAt      time.Time `gorm:"column:updated_at;autoUpdateTime" json:"updated_at"`
+}
+
+func (RetentionPolicy) TableName() string { return "mind_advisor_retention_policies" }
@@ -0,0 +1,36 @@
+package model
+
+import "time"
+
+// SpamToken 用户贝叶斯分类器的词频，token 以 64 位哈希保存
+// Table name: mind_advisor_spam_tokens
+type SpamToken struct {
+	ID        uint64    `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+	UserID    string    `gorm:"column:user_id;type:varchar(128);not null;uniqueIndex:uk_user_id_token_hash,priority:1" json:"user_id"`
+	TokenHash uint64    `gorm:"column:token_hash;not null;uniqueIndex:uk_user_id_token_hash,priority:2" json:"token_hash"`
+	SpamCount int64     `gorm:"column:spam_count;not null;default:0" json:"spam_count"`
+	HamCount  int64     `gorm:"column:ham_count;not null;default:0" json:"ham_count"`
+	UpdatedAt time.Time `gorm:"column:updated_at;autoUpdateTime" json:"updated_at"`
+}
+
+func (SpamToken) TableName() string { return "mind_advisor_spam_tokens" }
+
+// SpamStats 用户贝叶斯分类器已训练的邮件数
+// Table name: mind_advisor_spam_stats
+type SpamStats struct {
+	ID           uint64    `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+	UserID       string    `gorm:"column:user_id;type:varchar(128);not null;uniqueIndex:uk_user_id" json:"user_id"`
+	SpamMessages int64     `gorm:"column:spam_messages;not null;default:0" json:"spam_messages"`
+	HamMessages  int64     `gorm:"column:ham_messages;not null;default:0" json:"ham_messages"`
+	CreatedAt    time.Time `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+	UpdatedAt    time.Time `gorm:"column:updated_at;autoUpdateTime" json:"updated_at"`
+}
+
+func (SpamStats) TableName() string { return "mind_advisor_spam_stats" }
+
+// Spam trained constants，邮件被用户训练为哪一类
+const (
+	SpamTrainedNone int8 = 0
+	SpamTrainedSpam int8 = 1
+	SpamTrainedHam  int8 = 2
+)
@@ -2,6 +2,7 @@ package config
 
 import (
 	"os"
+	"strings"
 
 	scaffoldconfig "github.com/Plaud-AI/plaud-go-scaffold/pkg/config"
 )
@@ -9,6 +10,8 @@ import (
 // ExternalServicesConfig 外部服务配置
 type ExternalServicesConfig struct {
 	PlaudAPI *PlaudAPIConfig `yaml:"plaud_api"`
+	Gmail    *GmailConfig    `yaml:"gmail"`
+	Outlook  *OutlookConfig  `yaml:"outlook"`
 }
 
 // PlaudAPIConfig plaud-api 服务配置
@@ -16,10 +19,318 @@ type PlaudAPIConfig struct {
 	BaseURL string `yaml:"base_url"`
 }
 
+// GmailConfig Gmail OAuth2 与 API 配置，端点可替换为测试桩
+type GmailConfig struct {
+	ClientID     string   `yaml:"client_id"`
+	ClientSecret string   `yaml:"client_secret"`
+	RedirectURL  string   `yaml:"redirect_url"`
+	AuthURL      string   `yaml:"auth_url"`
+	TokenURL     string   `yaml:"token_url"`
+	RevokeURL    string   `yaml:"revoke_url"`
+	APIBaseURL   string   `yaml:"api_base_url"`
+	Scopes       []string `yaml:"scopes"`
+}
+
+// Gmail 默认端点
+const (
+	DefaultGmailAuthURL    = "https://accounts.google.com/o/oauth2/v2/auth"
+	DefaultGmailTokenURL   = "https://oauth2.googleapis.com/token"
+	DefaultGmailRevokeURL  = "https://oauth2.googleapis.com/revoke"
+	DefaultGmailAPIBaseURL = "https://gmail.googleapis.com"
+	DefaultGmailScope      = "https://www.googleapis.com/auth/gmail.readonly"
+)
+
+// OutlookConfig Microsoft 身份平台与 Graph 配置，端点可替换为测试桩
+type OutlookConfig struct {
+	ClientID     string `yaml:"client_id"`
+	ClientSecret string `yaml:"client_secret"`
+	RedirectURL  string `yaml:"redirect_url"`
+	// Tenant 租户，common 同时支持个人与工作账户
+	Tenant       string   `yaml:"tenant"`
+	AuthorityURL string   `yaml:"authority_url"`
+	GraphBaseURL string   `yaml:"graph_base_url"`
+	Scopes       []string `yaml:"scopes"`
+}
+
+// Outlook 默认配置
+const (
+	DefaultOutlookTenant       = "common"
+	DefaultOutlookAuthorityURL = "https://login.microsoftonline.com"
+	DefaultOutlookGraphBaseURL = "https://graph.microsoft.com"
+)
+
+// DefaultOutlookScopes Outlook 默认授权范围，offline_access 用于获取 refresh token
+var DefaultOutlookScopes = []string{"offline_access", "User.Read", "Mail.Read"}
+
+// AuthURL 授权端点
+func (c *OutlookConfig) AuthURL() string {
+	return strings.TrimSuffix(c.AuthorityURL, "/") + "/" + c.Tenant + "/oauth2/v2.0/authorize"
+}
+
+// TokenURL 令牌端点
+func (c *OutlookConfig) TokenURL() string {
+	return strings.TrimSuffix(c.AuthorityURL, "/") + "/" + c.Tenant + "/oauth2/v2.0/token"
+}
+
+// SMTPConfig 内置 SMTP 收信服务配置
+type SMTPConfig struct {
+	IP   string `yaml:"ip"`
+	Port int    `yaml:"port"`
+	// Hostname 在问候语与 Received 头中使用的主机名
+	Hostname string `yaml:"hostname"`
+	// Domains 接收的收件域名，local_part 会映射到 @myplaud 专属邮箱
+	Domains             []string `yaml:"domains"`
+	MaxMessageBytes     int64    `yaml:"max_message_bytes"`
+	MaxRecipients       int      `yaml:"max_recipients"`
+	MaxConnections      int      `yaml:"max_connections"`
+	ReadTimeoutSeconds  int      `yaml:"read_timeout_seconds"`
+	WriteTimeoutSeconds int      `yaml:"write_timeout_seconds"`
+	// AuthTimeoutSeconds 每封邮件 SPF、DKIM 与 DMARC 检查的超时，超时的检查记为 temperror
+	AuthTimeoutSeconds int `yaml:"auth_timeout_seconds"`
+	// SMTPUTF8 是否接受国际化地址，由 mailbox.unicode_local_part 决定
+	SMTPUTF8 bool `yaml:"-"`
+}
+
+// SMTP 默认配置
+const (
+	DefaultSMTPHostname        = "mx.myplaud"
+	DefaultSMTPMaxMessageBytes = 25 << 20
+	DefaultSMTPMaxRecipients   = 50
+	DefaultSMTPMaxConnections  = 1000
+	DefaultSMTPTimeoutSeconds  = 60
+	DefaultSMTPAuthTimeout     = 10
+)
+
+// RelayConfig 外发中继配置，用于按规则转发收到的邮件
+type RelayConfig struct {
+	Host     string `yaml:"host"`
+	Port     int    `yaml:"port"`
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+	// EnvelopeFrom 转发邮件的信封发件人，退信会回到该地址
+	EnvelopeFrom   string `yaml:"envelope_from"`
+	TimeoutSeconds int    `yaml:"timeout_seconds"`
+	// ForwardSecret 签名转发标记头的密钥，用于识别本服务转发出去的邮件以避免转发环路，各副本需一致
+	ForwardSecret string `yaml:"forward_secret"`
+}
+
+// 外发中继默认配置
+const (
+	DefaultRelayPort           = 587
+	DefaultRelayTimeoutSeconds = 30
+)
+
+// MessageStoreConfig 收件存储配置，原始邮件存放在 S3
+type MessageStoreConfig struct {
+	Bucket    string `yaml:"bucket"`
+	KeyPrefix string `yaml:"key_prefix"`
+}
+
+// DefaultMessageKeyPrefix 默认的邮件对象 key 前缀
+const DefaultMessageKeyPrefix = "messages"
+
+// MailboxConfig 专属邮箱配置
+type MailboxConfig struct {
+	// RenameCooldownDays 两次更换 local_part 之间的最短间隔
+	RenameCooldownDays int `yaml:"rename_cooldown_days"`
+	// RetiredRouteDays 更换后旧地址继续收信的天数
+	RetiredRouteDays int `yaml:"retired_route_days"`
+	// QuarantineDays 旧地址释放后禁止其他用户申请的天数，不小于 RetiredRouteDays
+	QuarantineDays int `yaml:"quarantine_days"`
+	// MaxAliases 每个用户最多的别名数量
+	MaxAliases int `yaml:"max_aliases"`
+	// UnicodeLocalPart 允许 RFC 6531 国际化 local_part，同时 SMTP 服务会声明 SMTPUTF8
+	UnicodeLocalPart bool `yaml:"unicode_local_part"`
+	// DeletionRetentionDays 邮箱删除后保留数据的天数，到期后清除邮件、绑定邮箱与别名
+	DeletionRetentionDays int `yaml:"deletion_retention_days"`
+	// PurgeIntervalSeconds 检查到期待清除邮箱的间隔
+	PurgeIntervalSeconds int `yaml:"purge_interval_seconds"`
+}
+
+// 专属邮箱默认配置
+const (
+	DefaultMailboxRenameCooldownDays = 30
+	DefaultMailboxRetiredRouteDays   = 30
+	DefaultMailboxQuarantineDays     = 180
+	DefaultMailboxMaxAliases         = 5
+	DefaultMailboxDeletionRetention  = 30
+	DefaultMailboxPurgeInterval      = 600
+)
+
+// 屏蔽规则的匹配方式
+const (
+	BlocklistMatchExact     = "exact"
+	BlocklistMatchPrefix    = "prefix"
+	BlocklistMatchSubstring = "substring"
+	BlocklistMatchRegex     = "regex"
+)
+
+// 屏蔽规则的分类
+const (
+	BlocklistCategoryReserved      = "reserved"
+	BlocklistCategoryProfanity     = "profanity"
+	BlocklistCategoryImpersonation = "impersonation"
+)
+
+// BlocklistRuleConfig local_part 屏蔽规则，Pattern 与小写后的 local_part 比较，正则规则忽略大小写
+type BlocklistRuleConfig struct {
+	Match    string `yaml:"match"`
+	Pattern  string `yaml:"pattern"`
+	Category string `yaml:"category"`
+}
+
+// BlocklistConfig local_part 屏蔽词配置，支持通过 AppConfig 热更新
+type BlocklistConfig struct {
+	Rules []*BlocklistRuleConfig `yaml:"rules"`
+	// RefreshSeconds 从数据库重新加载管理后台维护的规则的间隔
+	RefreshSeconds int `yaml:"refresh_seconds"`
+}
+
+// DefaultBlocklistRefreshSeconds 默认的规则刷新间隔
+const DefaultBlocklistRefreshSeconds = 60
+
+// DefaultBlocklistRules 未配置屏蔽规则时使用的默认规则
+var DefaultBlocklistRules = []*BlocklistRuleConfig{
+	{Match: BlocklistMatchExact, Pattern: "admin", Category: BlocklistCategoryReserved},
+	{Match: BlocklistMatchExact, Pattern: "root", Category: BlocklistCategoryReserved},
+	{Match: BlocklistMatchExact, Pattern: "system", Category: BlocklistCategoryReserved},
+	{Match: BlocklistMatchExact, Pattern: "help", Category: BlocklistCategoryReserved},
+	{Match: BlocklistMatchExact, Pattern: "info", Category: BlocklistCategoryReserved},
+	{Match: BlocklistMatchExact, Pattern: "mail", Category: BlocklistCategoryReserved},
+	{Match: BlocklistMatchExact, Pattern: "abuse", Category: BlocklistCategoryReserved},
+	{Match: BlocklistMatchExact, Pattern: "noreply", Category: BlocklistCategoryReserved},
+	{Match: BlocklistMatchPrefix, Pattern: "admin.", Category: BlocklistCategoryReserved},
+	{Match: BlocklistMatchPrefix, Pattern: "administrator", Category: BlocklistCategoryReserved},
+	{Match: BlocklistMatchPrefix, Pattern: "postmaster", Category: BlocklistCategoryReserved},
+	{Match: BlocklistMatchPrefix, Pattern: "webmaster", Category: BlocklistCategoryReserved},
+	{Match: BlocklistMatchPrefix, Pattern: "hostmaster", Category: BlocklistCategoryReserved},
+	{Match: BlocklistMatchPrefix, Pattern: "support", Category: BlocklistCategoryImpersonation},
+	{Match: BlocklistMatchSubstring, Pattern: "plaud", Category: BlocklistCategoryImpersonation},
+	{Match: BlocklistMatchSubstring, Pattern: "security", Category: BlocklistCategoryImpersonation},
+	{Match: BlocklistMatchSubstring, Pattern: "billing", Category: BlocklistCategoryImpersonation},
+	{Match: BlocklistMatchRegex, Pattern: `^(customer|account)s?\.?(service|care|team)`, Category: BlocklistCategoryImpersonation},
+}
+
+// QuotaTierConfig 存储配额档位，0 表示不限制
+type QuotaTierConfig struct {
+	MaxBytes    int64 `yaml:"max_bytes"`
+	MaxMessages int64 `yaml:"max_messages"`
+}
+
+// QuotaConfig 专属邮箱存储配额配置
+type QuotaConfig struct {
+	// Tiers 按内测批次（beta cohort）配置的配额档位
+	Tiers map[string]*QuotaTierConfig `yaml:"tiers"`
+	// DefaultCohort 用户未分配批次或批次没有对应档位时使用的档位
+	DefaultCohort string `yaml:"default_cohort"`
+	// SoftLimitPercent 用量达到配额的该百分比时通知用户
+	SoftLimitPercent int `yaml:"soft_limit_percent"`
+	// ReconcileIntervalSeconds 根据邮件记录与 S3 对象重新核算用量的间隔
+	ReconcileIntervalSeconds int `yaml:"reconcile_interval_seconds"`
+}
+
+// 存储配额默认配置
+const (
+	DefaultQuotaCohort            = "default"
+	DefaultQuotaMaxBytes          = 1 << 30
+	DefaultQuotaMaxMessages       = 50000
+	DefaultQuotaSoftLimitPercent  = 80
+	DefaultQuotaReconcileInterval = 6 * 3600
+)
+
+// Tier 返回批次对应的配额档位，未配置的批次使用默认档位
+func (c *QuotaConfig) Tier(cohort string) *QuotaTierConfig {
+	if t, ok := c.Tiers[cohort]; ok && t != nil {
+		return t
+	}
+	return c.Tiers[c.DefaultCohort]
+}
+
+// RetentionConfig 邮件保留策略的全局规则，天数为 0 表示永久保留，用户可以设置更短的保留期
+type RetentionConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// MessageDays 邮件收到后保留的天数，到期后删除原始邮件
+	MessageDays int `yaml:"message_days"`
+	// AttachmentDays 邮件收到后保留附件的天数，到期后从原始邮件中移除附件，正文保留
+	AttachmentDays int `yaml:"attachment_days"`
+	// SweepIntervalSeconds 清理到期邮件的间隔
+	SweepIntervalSeconds int `yaml:"sweep_interval_seconds"`
+	// BatchSize 每批处理的邮件数
+	BatchSize int `yaml:"batch_size"`
+}
+
+// 邮件保留策略默认配置
+const (
+	DefaultRetentionSweepInterval = 3600
+	DefaultRetentionBatchSize     = 200
+)
+
+// SpamConfig 收信垃圾邮件评分配置
+type SpamConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// Threshold 得分不低于该值的邮件放入垃圾邮件文件夹
+	Threshold float64 `yaml:"threshold"`
+	// TimeoutSeconds 单封邮件评分的超时，包括 SPF、DKIM 与 DMARC 的 DNS 查询
+	TimeoutSeconds int `yaml:"timeout_seconds"`
+	// URLBlocklist 本地 URL 域名黑名单，同时匹配子域名
+	URLBlocklist []string `yaml:"url_blocklist"`
+	// URLBlocklistFile 域名黑名单文件，每行一个域名，# 开头的行为注释
+	URLBlocklistFile string `yaml:"url_blocklist_file"`
+	// BayesMinMessages 用户标记的垃圾邮件与正常邮件都达到该数量后才启用贝叶斯分类
+	BayesMinMessages int `yaml:"bayes_min_messages"`
+}
+
+// 垃圾邮件评分默认配置
+const (
+	DefaultSpamThreshold        = 5.0
+	DefaultSpamTimeoutSeconds   = 10
+	DefaultSpamBayesMinMessages = 10
+)
+
+// MailSyncConfig 外部邮箱同步配置
+type MailSyncConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// IntervalSeconds 同步成功后到下次同步的间隔
+	IntervalSeconds int `yaml:"interval_seconds"`
+	// PollIntervalSeconds 调度器扫描到期邮箱的间隔
+	PollIntervalSeconds int `yaml:"poll_interval_seconds"`
+	// MaxBackoffSeconds 同步失败后指数退避的上限
+	MaxBackoffSeconds int `yaml:"max_backoff_seconds"`
+	Concurrency       int `yaml:"concurrency"`
+	// BatchSize 单个邮箱单次同步最多拉取的邮件数
+	BatchSize int `yaml:"batch_size"`
+	// InitialBackfill 首次同步时回填的最近邮件数
+	InitialBackfill int   `yaml:"initial_backfill"`
+	MaxMessageBytes int64 `yaml:"max_message_bytes"`
+	TimeoutSeconds  int   `yaml:"timeout_seconds"`
+	// CredentialKey base64 编码的 32 字节密钥，用于加密外部邮箱凭据
+	CredentialKey string `yaml:"credential_key"`
+}
+
+// 外部邮箱同步默认配置
+const (
+	DefaultMailSyncIntervalSeconds     = 300
+	DefaultMailSyncPollIntervalSeconds = 10
+	DefaultMailSyncMaxBackoffSeconds   = 6 * 3600
+	DefaultMailSyncConcurrency         = 8
+	DefaultMailSyncBatchSize           = 200
+	DefaultMailSyncInitialBackfill     = 50
+	DefaultMailSyncTimeoutSeconds      = 120
+)
+
 // AppConfig 应用配置，扩展了 scaffold 的 AppConfig
 type AppConfig struct {
 	scaffoldconfig.AppConfig `yaml:",inline"`
 	Services                 *ExternalServicesConfig `yaml:"services"`
+	SMTP                     *SMTPConfig             `yaml:"smtp"`
+	MessageStore             *MessageStoreConfig     `yaml:"message_store"`
+	MailSync                 *MailSyncConfig         `yaml:"mail_sync"`
+	Mailbox                  *MailboxConfig          `yaml:"mailbox"`
+	Relay                    *RelayConfig            `yaml:"relay"`
+	Blocklist                *BlocklistConfig        `yaml:"blocklist"`
+	Quota                    *QuotaConfig            `yaml:"quota"`
+	Retention                *RetentionConfig        `yaml:"retention"`
+	Spam                     *SpamConfig             `yaml:"spam"`
 }
 
 // Parse 解析配置
@@ -41,6 +352,271 @@ func (p *AppConfig) GetPlaudAPIBaseURL() string {
 	return os.Getenv("PLAUD_API_URL")
 }
 
+// GetGmailConfig 获取 Gmail 配置，未配置的端点使用 Google 默认值
+// 未配置 client_id 时返回 nil，表示不启用 Gmail 绑定
+func (p *AppConfig) GetGmailConfig() *GmailConfig {
+	if p.Services == nil || p.Services.Gmail == nil || p.Services.Gmail.ClientID == "" {
+		return nil
+	}
+	c := *p.Services.Gmail
+	if c.AuthURL == "" {
+		c.AuthURL = DefaultGmailAuthURL
+	}
+	if c.TokenURL == "" {
+		c.TokenURL = DefaultGmailTokenURL
+	}
+	if c.RevokeURL == "" {
+		c.RevokeURL = DefaultGmailRevokeURL
+	}
+	if c.APIBaseURL == "" {
+		c.APIBaseURL = DefaultGmailAPIBaseURL
+	}
+	if len(c.Scopes) == 0 {
+		c.Scopes = []string{DefaultGmailScope}
+	}
+	return &c
+}
+
+// GetOutlookConfig 获取 Outlook 配置，未配置的端点使用 Microsoft 默认值
+// 未配置 client_id 时返回 nil，表示不启用 Outlook 绑定
+func (p *AppConfig) GetOutlookConfig() *OutlookConfig {
+	if p.Services == nil || p.Services.Outlook == nil || p.Services.Outlook.ClientID == "" {
+		return nil
+	}
+	c := *p.Services.Outlook
+	if c.Tenant == "" {
+		c.Tenant = DefaultOutlookTenant
+	}
+	if c.AuthorityURL == "" {
+		c.AuthorityURL = DefaultOutlookAuthorityURL
+	}
+	if c.GraphBaseURL == "" {
+		c.GraphBaseURL = DefaultOutlookGraphBaseURL
+	}
+	if len(c.Scopes) == 0 {
+		c.Scopes = DefaultOutlookScopes
+	}
+	return &c
+}
+
+// GetSMTPConfig 获取 SMTP 收信配置，未配置的字段使用默认值
+// 未配置 smtp 时返回 nil，表示不启动 SMTP 服务
+func (p *AppConfig) GetSMTPConfig() *SMTPConfig {
+	if p.SMTP == nil {
+		return nil
+	}
+	c := *p.SMTP
+	if c.Hostname == "" {
+		c.Hostname = DefaultSMTPHostname
+	}
+	if len(c.Domains) == 0 {
+		c.Domains = []string{"myplaud"}
+	}
+	if c.MaxMessageBytes <= 0 {
+		c.MaxMessageBytes = DefaultSMTPMaxMessageBytes
+	}
+	if c.MaxRecipients <= 0 {
+		c.MaxRecipients = DefaultSMTPMaxRecipients
+	}
+	if c.MaxConnections <= 0 {
+		c.MaxConnections = DefaultSMTPMaxConnections
+	}
+	if c.ReadTimeoutSeconds <= 0 {
+		c.ReadTimeoutSeconds = DefaultSMTPTimeoutSeconds
+	}
+	if c.WriteTimeoutSeconds <= 0 {
+		c.WriteTimeoutSeconds = DefaultSMTPTimeoutSeconds
+	}
+	if c.AuthTimeoutSeconds <= 0 {
+		c.AuthTimeoutSeconds = DefaultSMTPAuthTimeout
+	}
+	c.SMTPUTF8 = p.Mailbox != nil && p.Mailbox.UnicodeLocalPart
+	return &c
+}
+
+// GetRelayConfig 获取外发中继配置，未配置的字段使用默认值
+// 未配置 relay.host 时返回 nil，表示不启用转发
+func (p *AppConfig) GetRelayConfig() *RelayConfig {
+	if p.Relay == nil || p.Relay.Host == "" {
+		return nil
+	}
+	c := *p.Relay
+	if c.Port <= 0 {
+		c.Port = DefaultRelayPort
+	}
+	if c.TimeoutSeconds <= 0 {
+		c.TimeoutSeconds = DefaultRelayTimeoutSeconds
+	}
+	if c.Password == "" {
+		c.Password = os.Getenv("MAIL_RELAY_PASSWORD")
+	}
+	if c.ForwardSecret == "" {
+		c.ForwardSecret = os.Getenv("MAIL_FORWARD_SECRET")
+	}
+	return &c
+}
+
+// GetMessageStoreConfig 获取收件存储配置，未配置的字段使用默认值
+func (p *AppConfig) GetMessageStoreConfig() *MessageStoreConfig {
+	c := MessageStoreConfig{}
+	if p.MessageStore != nil {
+		c = *p.MessageStore
+	}
+	if c.KeyPrefix == "" {
+		c.KeyPrefix = DefaultMessageKeyPrefix
+	}
+	return &c
+}
+
+// GetMailboxConfig 获取专属邮箱配置，未配置的字段使用默认值
+func (p *AppConfig) GetMailboxConfig() *MailboxConfig {
+	c := MailboxConfig{}
+	if p.Mailbox != nil {
+		c = *p.Mailbox
+	}
+	if c.RenameCooldownDays <= 0 {
+		c.RenameCooldownDays = DefaultMailboxRenameCooldownDays
+	}
+	if c.RetiredRouteDays <= 0 {
+		c.RetiredRouteDays = DefaultMailboxRetiredRouteDays
+	}
+	if c.QuarantineDays <= 0 {
+		c.QuarantineDays = DefaultMailboxQuarantineDays
+	}
+	if c.MaxAliases <= 0 {
+		c.MaxAliases = DefaultMailboxMaxAliases
+	}
+	if c.DeletionRetentionDays <= 0 {
+		c.DeletionRetentionDays = DefaultMailboxDeletionRetention
+	}
+	if c.PurgeIntervalSeconds <= 0 {
+		c.PurgeIntervalSeconds = DefaultMailboxPurgeInterval
+	}
+	if c.QuarantineDays < c.RetiredRouteDays {
+		c.QuarantineDays = c.RetiredRouteDays
+	}
+	return &c
+}
+
+// GetQuotaConfig 获取存储配额配置，未配置的字段使用默认值，默认档位缺失时补充默认配额
+func (p *AppConfig) GetQuotaConfig() *QuotaConfig {
+	c := QuotaConfig{}
+	if p.Quota != nil {
+		c = *p.Quota
+	}
+	if c.DefaultCohort == "" {
+		c.DefaultCohort = DefaultQuotaCohort
+	}
+	tiers := make(map[string]*QuotaTierConfig, len(c.Tiers)+1)
+	for cohort, t := range c.Tiers {
+		if t != nil {
+			tiers[cohort] = t
+		}
+	}
+	if tiers[c.DefaultCohort] == nil {
+		tiers[c.DefaultCohort] = &QuotaTierConfig{MaxBytes: DefaultQuotaMaxBytes, MaxMessages: DefaultQuotaMaxMessages}
+	}
+	c.Tiers = tiers
+	if c.SoftLimitPercent <= 0 || c.SoftLimitPercent > 100 {
+		c.SoftLimitPercent = DefaultQuotaSoftLimitPercent
+	}
+	if c.ReconcileIntervalSeconds <= 0 {
+		c.ReconcileIntervalSeconds = DefaultQuotaReconcileInterval
+	}
+	return &c
+}
+
+// GetRetentionConfig 获取邮件保留策略配置，未配置的字段使用默认值
+func (p *AppConfig) GetRetentionConfig() *RetentionConfig {
+	c := RetentionConfig{}
+	if p.Retention != nil {
+		c = *p.Retention
+	}
+	if c.MessageDays < 0 {
+		c.MessageDays = 0
+	}
+	if c.AttachmentDays < 0 {
+		c.AttachmentDays = 0
+	}
+	if c.SweepIntervalSeconds <= 0 {
+		c.SweepIntervalSeconds = DefaultRetentionSweepInterval
+	}
+	if c.BatchSize <= 0 {
+		c.BatchSize = DefaultRetentionBatchSize
+	}
+	return &c
+}
+
+// GetSpamConfig 获取垃圾邮件评分配置，未配置的字段使用默认值
+func (p *AppConfig) GetSpamConfig() *SpamConfig {
+	c := SpamConfig{}
+	if p.Spam != nil {
+		c = *p.Spam
+	}
+	if c.Threshold <= 0 {
+		c.Threshold = DefaultSpamThreshold
+	}
+	if c.TimeoutSeconds <= 0 {
+		c.TimeoutSeconds = DefaultSpamTimeoutSeconds
+	}
+	if c.BayesMinMessages <= 0 {
+		c.BayesMinMessages = DefaultSpamBayesMinMessages
+	}
+	return &c
+}
+
+// GetBlocklistConfig 获取屏蔽词配置，未配置规则时使用 DefaultBlocklistRules
+func (p *AppConfig) GetBlocklistConfig() *BlocklistConfig {
+	c := BlocklistConfig{}
+	if p.Blocklist != nil {
+		c = *p.Blocklist
+	}
+	if len(c.Rules) == 0 {
+		c.Rules = DefaultBlocklistRules
+	}
+	if c.RefreshSeconds <= 0 {
+		c.RefreshSeconds = DefaultBlocklistRefreshSeconds
+	}
+	return &c
+}
+
+// GetMailSyncConfig 获取外部邮箱同步配置，未配置的字段使用默认值
+// 凭据密钥优先从配置文件读取，若未配置则从环境变量 MAIL_CREDENTIAL_KEY 兜底
+func (p *AppConfig) GetMailSyncConfig() *MailSyncConfig {
+	c := MailSyncConfig{}
+	if p.MailSync != nil {
+		c = *p.MailSync
+	}
+	if c.IntervalSeconds <= 0 {
+		c.IntervalSeconds = DefaultMailSyncIntervalSeconds
+	}
+	if c.PollIntervalSeconds <= 0 {
+		c.PollIntervalSeconds = DefaultMailSyncPollIntervalSeconds
+	}
+	if c.MaxBackoffSeconds <= 0 {
+		c.MaxBackoffSeconds = DefaultMailSyncMaxBackoffSeconds
+	}
+	if c.Concurrency <= 0 {
+		c.Concurrency = DefaultMailSyncConcurrency
+	}
+	if c.BatchSize <= 0 {
+		c.BatchSize = DefaultMailSyncBatchSize
+	}
+	if c.InitialBackfill <= 0 {
+		c.InitialBackfill = DefaultMailSyncInitialBackfill
+	}
+	if c.MaxMessageBytes <= 0 {
+		c.MaxMessageBytes = DefaultSMTPMaxMessageBytes
+	}
+	if c.TimeoutSeconds <= 0 {
+		c.TimeoutSeconds = DefaultMailSyncTimeoutSeconds
+	}
+	if c.CredentialKey == "" {
+		c.CredentialKey = os.Getenv("MAIL_CREDENTIAL_KEY")
+	}
+	return &c
+}
+
 // PlaudAPIConfigGetter 用于获取 plaud-api 配置的接口
 type PlaudAPIConfigGetter interface {
 	GetPlaudAPIBaseURL() string
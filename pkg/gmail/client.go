@@ -0,0 +1,212 @@
+// Package gmail 封装同步收件所需的 Gmail REST API
+package gmail
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxJSONBytes 普通 JSON 响应的最大长度
+const maxJSONBytes = 8 << 20
+
+// APIError Gmail API 返回的错误
+type APIError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("gmail api status %d: %s", e.StatusCode, e.Message)
+}
+
+// IsNotFound 判断是否为 404，history 起点过旧时 Gmail 返回 404
+func IsNotFound(err error) bool {
+	var ae *APIError
+	return errors.As(err, &ae) && ae.StatusCode == http.StatusNotFound
+}
+
+// IsUnauthorized 判断是否为 401，访问令牌失效
+func IsUnauthorized(err error) bool {
+	var ae *APIError
+	return errors.As(err, &ae) && ae.StatusCode == http.StatusUnauthorized
+}
+
+// Client Gmail API 客户端
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+}
+
+// NewClient 创建 Client，baseURL 形如 https://gmail.googleapis.com
+func NewClient(baseURL string) *Client {
+	return &Client{
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		httpClient: &http.Client{Timeout: 60 * time.Second},
+	}
+}
+
+// Profile 邮箱概况
+type Profile struct {
+	EmailAddress string `json:"emailAddress"`
+	HistoryID    string `json:"historyId"`
+}
+
+// GetProfile 获取当前授权邮箱的地址与最新 history id
+func (c *Client) GetProfile(ctx context.Context, accessToken string) (*Profile, error) {
+	var p Profile
+	if err := c.getJSON(ctx, accessToken, "/gmail/v1/users/me/profile", nil, &p); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// ListMessageIDs 列出收件箱中最近的邮件 id，按时间倒序
+func (c *Client) ListMessageIDs(ctx context.Context, accessToken string, max int) ([]string, error) {
+	var resp struct {
+		Messages []struct {
+			ID string `json:"id"`
+		} `json:"messages"`
+	}
+	q := url.Values{"labelIds": {"INBOX"}, "maxResults": {strconv.Itoa(max)}}
+	if err := c.getJSON(ctx, accessToken, "/gmail/v1/users/me/messages", q, &resp); err != nil {
+		return nil, err
+	}
+	ids := make([]string, 0, len(resp.Messages))
+	for _, m := range resp.Messages {
+		ids = append(ids, m.ID)
+	}
+	return ids, nil
+}
+
+// History 一条 history 记录
+type History struct {
+	ID            string `json:"id"`
+	MessagesAdded []struct {
+		Message struct {
+			ID       string   `json:"id"`
+			LabelIDs []string `json:"labelIds"`
+		} `json:"message"`
+	} `json:"messagesAdded"`
+}
+
+// HistoryPage history 列表的一页
+type HistoryPage struct {
+	History       []*History `json:"history"`
+	NextPageToken string     `json:"nextPageToken"`
+	HistoryID     string     `json:"historyId"`
+}
+
+// ListHistory 列出 startHistoryID 之后收件箱新增邮件的记录
+func (c *Client) ListHistory(ctx context.Context, accessToken, startHistoryID, pageToken string) (*HistoryPage, error) {
+	q := url.Values{
+		"startHistoryId": {startHistoryID},
+		"historyTypes":   {"messageAdded"},
+		"labelId":        {"INBOX"},
+	}
+	if pageToken != "" {
+		q.Set("pageToken", pageToken)
+	}
+	var page HistoryPage
+	if err := c.getJSON(ctx, accessToken, "/gmail/v1/users/me/history", q, &page); err != nil {
+		return nil, err
+	}
+	return &page, nil
+}
+
+// RawMessage 原始邮件
+type RawMessage struct {
+	ID           string
+	InternalDate time.Time
+	Raw          []byte
+}
+
+// GetRawMessage 获取原始 MIME 邮件，maxBytes 限制邮件大小，超出时返回 nil
+func (c *Client) GetRawMessage(ctx context.Context, accessToken, id string, maxBytes int64) (*RawMessage, error) {
+	var resp struct {
+		ID           string `json:"id"`
+		InternalDate string `json:"internalDate"`
+		SizeEstimate int64  `json:"sizeEstimate"`
+		Raw          string `json:"raw"`
+	}
+	q := url.Values{"format": {"raw"}}
+	// base64 编码约膨胀 4/3，额外预留 JSON 字段的空间
+	limit := maxBytes/3*4 + 64<<10
+	if err := c.get(ctx, accessToken, "/gmail/v1/users/me/messages/"+url.PathEscape(id), q, limit, &resp); err != nil {
+		var tooLarge *responseTooLargeError
+		if errors.As(err, &tooLarge) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if resp.SizeEstimate > maxBytes {
+		return nil, nil
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(resp.Raw, "="))
+	if err != nil {
+		return nil, fmt.Errorf("decode gmail raw message failed: %w", err)
+	}
+	msg := &RawMessage{ID: resp.ID, Raw: raw}
+	if ms, err := strconv.ParseInt(resp.InternalDate, 10, 64); err == nil {
+		msg.InternalDate = time.UnixMilli(ms)
+	}
+	return msg, nil
+}
+
+// responseTooLargeError 响应超过长度限制
+type responseTooLargeError struct{}
+
+func (*responseTooLargeError) Error() string { return "gmail api response too large" }
+
+func (c *Client) getJSON(ctx context.Context, accessToken, path string, q url.Values, out any) error {
+	return c.get(ctx, accessToken, path, q, maxJSONBytes, out)
+}
+
+func (c *Client) get(ctx context.Context, accessToken, path string, q url.Values, limit int64, out any) error {
+	u := c.baseURL + path
+	if len(q) > 0 {
+		u += "?" + q.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("gmail request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, limit+1))
+	if err != nil {
+		return fmt.Errorf("read gmail response failed: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		var e struct {
+			Error struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		_ = json.Unmarshal(body, &e)
+		return &APIError{StatusCode: resp.StatusCode, Message: e.Error.Message}
+	}
+	if int64(len(body)) > limit {
+		return &responseTooLargeError{}
+	}
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("decode gmail response failed: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,222 @@
+// Package graph 封装同步收件所需的 Microsoft Graph 邮件 API
+package graph
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// maxJSONBytes 普通 JSON 响应的最大长度
+	maxJSONBytes = 8 << 20
+	// deltaPageSize delta 查询每页的邮件数
+	deltaPageSize = 50
+	// defaultRetryAfter 限流响应未携带 Retry-After 时的等待时间
+	defaultRetryAfter = time.Minute
+)
+
+// APIError Graph API 返回的错误
+type APIError struct {
+	StatusCode int
+	Code       string
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("graph api status %d: %s %s", e.StatusCode, e.Code, e.Message)
+}
+
+// RateLimitError 请求被限流，RetryAfter 之后才能重试
+type RateLimitError struct {
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("graph api throttled, retry after %s", e.RetryAfter)
+}
+
+// IsSyncStateExpired 判断 delta 令牌是否已失效，需要全量重新同步
+func IsSyncStateExpired(err error) bool {
+	var ae *APIError
+	if !errors.As(err, &ae) {
+		return false
+	}
+	if ae.StatusCode == http.StatusGone {
+		return true
+	}
+	switch strings.ToLower(ae.Code) {
+	case "syncstatenotfound", "syncstateinvalid", "resyncrequired":
+		return true
+	}
+	return false
+}
+
+// IsNotFound 判断是否为 404
+func IsNotFound(err error) bool {
+	var ae *APIError
+	return errors.As(err, &ae) && ae.StatusCode == http.StatusNotFound
+}
+
+// IsUnauthorized 判断是否为 401，访问令牌失效
+func IsUnauthorized(err error) bool {
+	var ae *APIError
+	return errors.As(err, &ae) && ae.StatusCode == http.StatusUnauthorized
+}
+
+// Client Graph API 客户端
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+}
+
+// NewClient 创建 Client，baseURL 形如 https://graph.microsoft.com
+func NewClient(baseURL string) *Client {
+	return &Client{
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		httpClient: &http.Client{Timeout: 60 * time.Second},
+	}
+}
+
+// User 当前授权用户
+type User struct {
+	Mail              string `json:"mail"`
+	UserPrincipalName string `json:"userPrincipalName"`
+}
+
+// Address 用户的邮箱地址，个人账户可能只有 userPrincipalName
+func (u *User) Address() string {
+	if u.Mail != "" {
+		return u.Mail
+	}
+	return u.UserPrincipalName
+}
+
+// GetMe 获取当前授权用户
+func (c *Client) GetMe(ctx context.Context, accessToken string) (*User, error) {
+	var u User
+	if err := c.getJSON(ctx, accessToken, c.baseURL+"/v1.0/me?$select=mail,userPrincipalName", &u); err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+// DeltaMessage delta 结果中的一封邮件
+type DeltaMessage struct {
+	ID               string     `json:"id"`
+	ReceivedDateTime *time.Time `json:"receivedDateTime"`
+	Removed          *struct {
+		Reason string `json:"reason"`
+	} `json:"@removed"`
+}
+
+// DeltaPage delta 查询的一页，NextLink 与 DeltaLink 二者之一非空
+type DeltaPage struct {
+	Messages  []*DeltaMessage `json:"value"`
+	NextLink  string          `json:"@odata.nextLink"`
+	DeltaLink string          `json:"@odata.deltaLink"`
+}
+
+// InboxDeltaURL 收件箱全量同步的起始地址
+func (c *Client) InboxDeltaURL() string {
+	return c.baseURL + "/v1.0/me/mailFolders/inbox/messages/delta?$select=id,receivedDateTime"
+}
+
+// Delta 请求一页 delta 结果，link 为 InboxDeltaURL 或上次返回的 NextLink/DeltaLink
+func (c *Client) Delta(ctx context.Context, accessToken, link string) (*DeltaPage, error) {
+	if !strings.HasPrefix(link, c.baseURL+"/") {
+		// 游标来自持久化数据，只允许访问配置的 Graph 地址
+		return nil, &APIError{StatusCode: http.StatusGone, Code: "syncStateInvalid", Message: "delta link host mismatch"}
+	}
+	var page DeltaPage
+	if err := c.getJSON(ctx, accessToken, link, &page); err != nil {
+		return nil, err
+	}
+	return &page, nil
+}
+
+// GetMIME 获取原始 MIME 邮件，超过 maxBytes 时返回 nil
+func (c *Client) GetMIME(ctx context.Context, accessToken, id string, maxBytes int64) ([]byte, error) {
+	resp, err := c.do(ctx, accessToken, c.baseURL+"/v1.0/me/messages/"+url.PathEscape(id)+"/$value")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, maxBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("read graph message failed: %w", err)
+	}
+	if int64(len(raw)) > maxBytes {
+		return nil, nil
+	}
+	return raw, nil
+}
+
+func (c *Client) getJSON(ctx context.Context, accessToken, u string, out any) error {
+	resp, err := c.do(ctx, accessToken, u)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxJSONBytes)).Decode(out); err != nil {
+		return fmt.Errorf("decode graph response failed: %w", err)
+	}
+	return nil
+}
+
+// do 发送 GET 请求，非 200 响应转换为 APIError 或 RateLimitError
+func (c *Client) do(ctx context.Context, accessToken, u string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	req.Header.Set("Prefer", "odata.maxpagesize="+strconv.Itoa(deltaPageSize))
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("graph request failed: %w", err)
+	}
+	if resp.StatusCode == http.StatusOK {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusTooManyRequests ||
+		(resp.StatusCode == http.StatusServiceUnavailable && resp.Header.Get("Retry-After") != "") {
+		_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4<<10))
+		return nil, &RateLimitError{RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After"))}
+	}
+
+	var e struct {
+		Error struct {
+			Code    string `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	_ = json.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&e)
+	return nil, &APIError{StatusCode: resp.StatusCode, Code: e.Error.Code, Message: e.Error.Message}
+}
+
+// parseRetryAfter 解析秒数或 HTTP 日期格式的 Retry-After
+func parseRetryAfter(v string) time.Duration {
+	v = strings.TrimSpace(v)
+	if secs, err := strconv.Atoi(v); err == nil && secs > 0 {
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+	return defaultRetryAfter
+}
@@ -0,0 +1,500 @@
+// Package imap 实现同步外部邮箱所需的最小 IMAP4rev1 客户端（只读）
+package imap
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	// maxLineBytes 单行响应的最大长度
+	maxLineBytes = 64 << 10
+	// defaultMaxLiteral 默认允许的最大 literal 长度
+	defaultMaxLiteral = 64 << 20
+)
+
+// 错误定义
+var (
+	ErrAuthFailed      = errors.New("imap authentication failed")
+	ErrLineTooLong     = errors.New("imap response line too long")
+	ErrLiteralTooLarge = errors.New("imap literal too large")
+	ErrBye             = errors.New("imap server closed connection")
+	ErrPrivateAddress  = errors.New("imap server address is not public")
+	// ErrStartTLSUnsupported 未使用 TLS 直连且服务器不支持 STARTTLS，拒绝以明文发送凭据
+	ErrStartTLSUnsupported = errors.New("imap server does not support STARTTLS")
+)
+
+// StatusError 命令返回 NO 或 BAD
+type StatusError struct {
+	Command string
+	Status  string
+	Text    string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("imap %s: %s %s", e.Command, e.Status, e.Text)
+}
+
+// Options 连接选项
+type Options struct {
+	// TLS 为 true 时直接建立 TLS 连接，否则必须通过 STARTTLS 升级后才能登录
+	TLS     bool
+	Timeout time.Duration
+	// TLSConfig 自定义 TLS 配置，为空时按服务器主机名校验证书
+	TLSConfig *tls.Config
+	// MaxLiteral 单个 literal（通常是一封邮件）的最大字节数
+	MaxLiteral int64
+	// AllowPrivate 是否允许连接内网地址，仅用于本地调试
+	AllowPrivate bool
+}
+
+// MailboxStatus EXAMINE/SELECT 返回的邮箱状态
+type MailboxStatus struct {
+	Name        string
+	Exists      uint32
+	UIDValidity uint32
+	UIDNext     uint32
+}
+
+// Client IMAP 客户端，非并发安全
+type Client struct {
+	conn net.Conn
+	r    *bufio.Reader
+	w    *bufio.Writer
+	opts Options
+	seq  int
+}
+
+// response 一条服务端响应，literal 内容按出现顺序存放
+type response struct {
+	line     string
+	literals [][]byte
+}
+
+// Dial 连接 IMAP 服务器并读取问候语
+func Dial(ctx context.Context, addr string, opts Options) (*Client, error) {
+	if opts.Timeout <= 0 {
+		opts.Timeout = 30 * time.Second
+	}
+	if opts.MaxLiteral <= 0 {
+		opts.MaxLiteral = defaultMaxLiteral
+	}
+
+	dialer := &net.Dialer{Timeout: opts.Timeout}
+	if !opts.AllowPrivate {
+		dialer.Control = rejectPrivate
+	}
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	host, _, _ := net.SplitHostPort(addr)
+	if opts.TLS {
+		if conn, err = handshake(ctx, conn, host, opts); err != nil {
+			return nil, err
+		}
+	}
+
+	c := &Client{opts: opts}
+	c.setConn(conn)
+	c.extendDeadline()
+	greeting, err := c.readResponse()
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if !strings.HasPrefix(greeting.line, "* OK") && !strings.HasPrefix(greeting.line, "* PREAUTH") {
+		conn.Close()
+		return nil, fmt.Errorf("unexpected imap greeting: %q", greeting.line)
+	}
+	if !opts.TLS {
+		if err := c.startTLS(ctx, host, greeting); err != nil {
+			c.conn.Close()
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// startTLS 通过 STARTTLS 升级明文连接，服务器未声明支持时返回 ErrStartTLSUnsupported
+// PREAUTH 连接已处于认证状态，无法再协商 STARTTLS，同样拒绝
+func (c *Client) startTLS(ctx context.Context, host string, greeting *response) error {
+	if strings.HasPrefix(greeting.line, "* PREAUTH") {
+		return ErrStartTLSUnsupported
+	}
+	caps, ok := responseCode(greeting.line, "CAPABILITY")
+	if !ok {
+		resps, err := c.execute("CAPABILITY")
+		if err != nil {
+			return err
+		}
+		for _, r := range resps {
+			if fields := strings.Fields(r.line); len(fields) >= 2 && strings.EqualFold(fields[1], "CAPABILITY") {
+				caps = strings.Join(fields[2:], " ")
+			}
+		}
+	}
+	if !slices.ContainsFunc(strings.Fields(caps), func(v string) bool { return strings.EqualFold(v, "STARTTLS") }) {
+		return ErrStartTLSUnsupported
+	}
+	if _, err := c.execute("STARTTLS"); err != nil {
+		return err
+	}
+	// 升级前已缓冲的数据未经加密保护，可能是中间人注入的响应
+	if c.r.Buffered() > 0 {
+		return errors.New("imap server sent data before tls handshake")
+	}
+	conn, err := handshake(ctx, c.conn, host, c.opts)
+	if err != nil {
+		return err
+	}
+	c.setConn(conn)
+	return nil
+}
+
+// handshake 在已建立的连接上完成 TLS 握手，失败时关闭连接
+func handshake(ctx context.Context, conn net.Conn, host string, opts Options) (net.Conn, error) {
+	conf := opts.TLSConfig
+	if conf == nil {
+		conf = &tls.Config{ServerName: host, MinVersion: tls.VersionTLS12}
+	}
+	tlsConn := tls.Client(conn, conf)
+	_ = tlsConn.SetDeadline(time.Now().Add(opts.Timeout))
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return tlsConn, nil
+}
+
+func (c *Client) setConn(conn net.Conn) {
+	c.conn = conn
+	c.r = bufio.NewReader(conn)
+	c.w = bufio.NewWriter(conn)
+}
+
+// Close 关闭连接
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+// Login 使用用户名密码登录，凭据错误时返回 ErrAuthFailed
+func (c *Client) Login(username, password string) error {
+	_, err := c.execute("LOGIN", astring(username), astring(password))
+	var se *StatusError
+	if errors.As(err, &se) && se.Status == "NO" {
+		return fmt.Errorf("%w: %s", ErrAuthFailed, se.Text)
+	}
+	return err
+}
+
+// Examine 以只读方式打开邮箱，不会改变邮件的 \Seen 标记
+func (c *Client) Examine(mailbox string) (*MailboxStatus, error) {
+	resps, err := c.execute("EXAMINE", astring(mailbox))
+	if err != nil {
+		return nil, err
+	}
+	status := &MailboxStatus{Name: mailbox}
+	for _, r := range resps {
+		fields := strings.Fields(r.line)
+		if len(fields) >= 3 && strings.EqualFold(fields[2], "EXISTS") {
+			status.Exists = parseUint32(fields[1])
+		}
+		if v, ok := responseCode(r.line, "UIDVALIDITY"); ok {
+			status.UIDValidity = parseUint32(v)
+		}
+		if v, ok := responseCode(r.line, "UIDNEXT"); ok {
+			status.UIDNext = parseUint32(v)
+		}
+	}
+	if status.UIDValidity == 0 {
+		return nil, errors.New("imap server did not report UIDVALIDITY")
+	}
+	return status, nil
+}
+
+// UIDSearchFrom 返回 UID 不小于 from 的邮件 UID，按升序排列
+func (c *Client) UIDSearchFrom(from uint32) ([]uint32, error) {
+	if from == 0 {
+		from = 1
+	}
+	resps, err := c.execute("UID SEARCH", fmt.Sprintf("UID %d:*", from))
+	if err != nil {
+		return nil, err
+	}
+	var uids []uint32
+	for _, r := range resps {
+		fields := strings.Fields(r.line)
+		if len(fields) < 2 || !strings.EqualFold(fields[1], "SEARCH") {
+			continue
+		}
+		for _, f := range fields[2:] {
+			// "n:*" 在没有新邮件时也会返回当前最大 UID，调用方需自行过滤
+			if uid := parseUint32(f); uid >= from {
+				uids = append(uids, uid)
+			}
+		}
+	}
+	slices.Sort(uids)
+	return uids, nil
+}
+
+// FetchSize 查询邮件大小，邮件不存在时返回 0
+func (c *Client) FetchSize(uid uint32) (int64, error) {
+	resps, err := c.execute("UID FETCH", strconv.FormatUint(uint64(uid), 10), "(UID RFC822.SIZE)")
+	if err != nil {
+		return 0, err
+	}
+	for _, r := range resps {
+		if v, ok := fetchItem(r.line, "RFC822.SIZE"); ok {
+			return strconv.ParseInt(v, 10, 64)
+		}
+	}
+	return 0, nil
+}
+
+// FetchRaw 获取完整的原始邮件，使用 BODY.PEEK 避免标记为已读
+// 邮件已被删除时返回 nil
+func (c *Client) FetchRaw(uid uint32) ([]byte, error) {
+	resps, err := c.execute("UID FETCH", strconv.FormatUint(uint64(uid), 10), "(UID BODY.PEEK[])")
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range resps {
+		if strings.Contains(strings.ToUpper(r.line), "FETCH") && len(r.literals) > 0 {
+			return r.literals[0], nil
+		}
+	}
+	return nil, nil
+}
+
+// Logout 退出登录
+func (c *Client) Logout() error {
+	_, err := c.execute("LOGOUT")
+	if errors.Is(err, ErrBye) {
+		return nil
+	}
+	return err
+}
+
+// execute 发送命令并读取响应直至对应的 tagged 响应，返回期间的 untagged 响应
+func (c *Client) execute(command string, args ...argument) ([]*response, error) {
+	c.seq++
+	tag := "a" + strconv.Itoa(c.seq)
+	c.extendDeadline()
+
+	if _, err := c.w.WriteString(tag + " " + command); err != nil {
+		return nil, err
+	}
+	for _, arg := range args {
+		if err := c.w.WriteByte(' '); err != nil {
+			return nil, err
+		}
+		if err := c.writeArgument(arg); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := c.w.WriteString("\r\n"); err != nil {
+		return nil, err
+	}
+	if err := c.w.Flush(); err != nil {
+		return nil, err
+	}
+
+	var untagged []*response
+	for {
+		resp, err := c.readResponse()
+		if err != nil {
+			return nil, err
+		}
+		if strings.HasPrefix(resp.line, "* ") {
+			if strings.HasPrefix(strings.ToUpper(resp.line), "* BYE") && command != "LOGOUT" {
+				return nil, ErrBye
+			}
+			untagged = append(untagged, resp)
+			continue
+		}
+		if !strings.HasPrefix(resp.line, tag+" ") {
+			// 不认识的 continuation 或其他 tag，忽略
+			continue
+		}
+		status, text, _ := strings.Cut(strings.TrimPrefix(resp.line, tag+" "), " ")
+		switch strings.ToUpper(status) {
+		case "OK":
+			return untagged, nil
+		case "NO", "BAD":
+			return nil, &StatusError{Command: command, Status: strings.ToUpper(status), Text: text}
+		default:
+			return nil, fmt.Errorf("unexpected imap status line: %q", resp.line)
+		}
+	}
+}
+
+// argument 命令参数：string 原样写出，literalArg 需要等待服务端 continuation
+type argument = any
+
+// literalArg 以 literal 形式发送的参数
+type literalArg []byte
+
+// astring 将字符串编码为 quoted 字符串或 literal
+func astring(s string) argument {
+	for i := 0; i < len(s); i++ {
+		if b := s[i]; b == '\r' || b == '\n' || b >= 0x80 {
+			return literalArg(s)
+		}
+	}
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+func (c *Client) writeArgument(arg argument) error {
+	switch v := arg.(type) {
+	case string:
+		_, err := c.w.WriteString(v)
+		return err
+	case literalArg:
+		if _, err := fmt.Fprintf(c.w, "{%d}\r\n", len(v)); err != nil {
+			return err
+		}
+		if err := c.w.Flush(); err != nil {
+			return err
+		}
+		resp, err := c.readResponse()
+		if err != nil {
+			return err
+		}
+		if !strings.HasPrefix(resp.line, "+") {
+			return fmt.Errorf("imap server rejected literal: %q", resp.line)
+		}
+		_, err = c.w.Write(v)
+		return err
+	default:
+		return fmt.Errorf("unsupported imap argument %T", arg)
+	}
+}
+
+// readResponse 读取一条完整响应，行尾的 {n} literal 会被读出并拼接后续内容
+func (c *Client) readResponse() (*response, error) {
+	resp := &response{}
+	var line strings.Builder
+	for {
+		part, err := c.readLine()
+		if err != nil {
+			return nil, err
+		}
+		n, ok := literalSize(part)
+		if !ok {
+			line.WriteString(part)
+			break
+		}
+		if n > c.opts.MaxLiteral {
+			return nil, ErrLiteralTooLarge
+		}
+		line.WriteString(part)
+		c.extendDeadline()
+		lit := make([]byte, n)
+		if _, err := io.ReadFull(c.r, lit); err != nil {
+			return nil, err
+		}
+		resp.literals = append(resp.literals, lit)
+	}
+	resp.line = line.String()
+	return resp, nil
+}
+
+// readLine 读取一行并去掉 CRLF
+func (c *Client) readLine() (string, error) {
+	var buf bytes.Buffer
+	for {
+		chunk, err := c.r.ReadSlice('\n')
+		buf.Write(chunk)
+		if buf.Len() > maxLineBytes {
+			return "", ErrLineTooLong
+		}
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, bufio.ErrBufferFull) {
+			return "", err
+		}
+	}
+	return strings.TrimRight(buf.String(), "\r\n"), nil
+}
+
+func (c *Client) extendDeadline() {
+	_ = c.conn.SetDeadline(time.Now().Add(c.opts.Timeout))
+}
+
+// literalSize 解析行尾的 {n}
+func literalSize(line string) (int64, bool) {
+	if !strings.HasSuffix(line, "}") {
+		return 0, false
+	}
+	start := strings.LastIndexByte(line, '{')
+	if start < 0 {
+		return 0, false
+	}
+	n, err := strconv.ParseInt(strings.TrimSuffix(line[start+1:len(line)-1], "+"), 10, 64)
+	if err != nil || n < 0 {
+		return 0, false
+	}
+	return n, true
+}
+
+// responseCode 提取 "[CODE value]" 形式的响应码
+func responseCode(line, code string) (string, bool) {
+	upper := strings.ToUpper(line)
+	i := strings.Index(upper, "["+code+" ")
+	if i < 0 {
+		return "", false
+	}
+	rest := line[i+len(code)+2:]
+	end := strings.IndexByte(rest, ']')
+	if end < 0 {
+		return "", false
+	}
+	return strings.TrimSpace(rest[:end]), true
+}
+
+// fetchItem 提取 FETCH 响应中的数据项，如 "RFC822.SIZE 1234"
+func fetchItem(line, item string) (string, bool) {
+	fields := strings.Fields(strings.NewReplacer("(", " ", ")", " ").Replace(line))
+	for i := 0; i+1 < len(fields); i++ {
+		if strings.EqualFold(fields[i], item) {
+			return fields[i+1], true
+		}
+	}
+	return "", false
+}
+
+func parseUint32(s string) uint32 {
+	n, err := strconv.ParseUint(s, 10, 32)
+	if err != nil {
+		return 0
+	}
+	return uint32(n)
+}
+
+// rejectPrivate 拒绝连接回环、内网与链路本地地址，防止通过用户配置的主机名访问内部服务
+func rejectPrivate(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() || ip.IsUnspecified() || ip.IsMulticast() {
+		return ErrPrivateAddress
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package imap_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"plaud-emails/pkg/imap"
+	"plaud-emails/pkg/imap/imaptest"
+)
+
+func newServer(t *testing.T) *imaptest.Server {
+	t.Helper()
+	srv, err := imaptest.NewServer()
+	if err != nil {
+		t.Fatalf("start imap server: %v", err)
+	}
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func dial(t *testing.T, srv *imaptest.Server) (*imap.Client, error) {
+	t.Helper()
+	return imap.Dial(context.Background(), srv.Addr(), imap.Options{
+		Timeout:      5 * time.Second,
+		TLSConfig:    srv.ClientTLSConfig(),
+		AllowPrivate: true,
+	})
+}
+
+func TestDialUpgradesWithSTARTTLS(t *testing.T) {
+	srv := newServer(t)
+	srv.Append(imaptest.Message{UID: 3, Raw: []byte("Subject: a\r\n\r\nhello\r\n")},
+		imaptest.Message{UID: 7, Raw: []byte("Subject: b\r\n\r\nworld\r\n")})
+
+	client, err := dial(t, srv)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer client.Close()
+	if err := client.Login(srv.Username, srv.Password); err != nil {
+		t.Fatalf("login: %v", err)
+	}
+	status, err := client.Examine("INBOX")
+	if err != nil {
+		t.Fatalf("examine: %v", err)
+	}
+	if status.Exists != 2 || status.UIDValidity != 1 || status.UIDNext != 8 {
+		t.Fatalf("unexpected status %+v", status)
+	}
+	uids, err := client.UIDSearchFrom(4)
+	if err != nil {
+		t.Fatalf("search: %v", err)
+	}
+	if !slices.Equal(uids, []uint32{7}) {
+		t.Fatalf("uids = %v, want [7]", uids)
+	}
+	size, err := client.FetchSize(7)
+	if err != nil || size != 21 {
+		t.Fatalf("size = %d, %v", size, err)
+	}
+	raw, err := client.FetchRaw(7)
+	if err != nil || string(raw) != "Subject: b\r\n\r\nworld\r\n" {
+		t.Fatalf("raw = %q, %v", raw, err)
+	}
+	if err := client.Logout(); err != nil {
+		t.Fatalf("logout: %v", err)
+	}
+
+	commands := srv.Commands()
+	if len(commands) < 2 || commands[0] != "STARTTLS" || commands[1] != "LOGIN" {
+		t.Fatalf("login must follow STARTTLS, got %v", commands)
+	}
+}
+
+func TestDialRefusesPlaintextWithoutSTARTTLS(t *testing.T) {
+	srv := newServer(t)
+	srv.DisableStartTLS()
+
+	client, err := dial(t, srv)
+	if !errors.Is(err, imap.ErrStartTLSUnsupported) {
+		if client != nil {
+			client.Close()
+		}
+		t.Fatalf("err = %v, want ErrStartTLSUnsupported", err)
+	}
+	if slices.Contains(srv.Commands(), "LOGIN") {
+		t.Fatal("credentials sent over plaintext")
+	}
+}
+
+func TestLoginAuthFailed(t *testing.T) {
+	srv := newServer(t)
+
+	client, err := dial(t, srv)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer client.Close()
+	if err := client.Login(srv.Username, "wrong"); !errors.Is(err, imap.ErrAuthFailed) {
+		t.Fatalf("err = %v, want ErrAuthFailed", err)
+	}
+}
@@ -0,0 +1,376 @@
+// Package imaptest 提供进程内的 IMAP 服务端，用于测试 IMAP 客户端与同步逻辑
+package imaptest
+
+import (
+	"bufio"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"math/big"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Message 邮箱中的一封邮件
+type Message struct {
+	UID uint32
+	Raw []byte
+}
+
+// Server 只实现同步所需命令的 IMAP 服务端，监听在本地回环地址
+type Server struct {
+	// Username/Password 允许登录的凭据
+	Username string
+	Password string
+
+	ln      net.Listener
+	tlsConf *tls.Config
+	pool    *x509.CertPool
+
+	mu          sync.Mutex
+	uidValidity uint32
+	noStartTLS  bool
+	messages    []Message
+	commands    []string
+	conns       map[net.Conn]struct{}
+	wg          sync.WaitGroup
+}
+
+// NewServer 启动服务端，证书为临时生成的自签名证书
+func NewServer() (*Server, error) {
+	cert, pool, err := selfSigned()
+	if err != nil {
+		return nil, err
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	s := &Server{
+		Username:    "user",
+		Password:    "secret",
+		ln:          ln,
+		tlsConf:     &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12},
+		pool:        pool,
+		uidValidity: 1,
+		conns:       make(map[net.Conn]struct{}),
+	}
+	s.wg.Add(1)
+	go s.serve()
+	return s, nil
+}
+
+// Addr 监听地址
+func (s *Server) Addr() string {
+	return s.ln.Addr().String()
+}
+
+// ClientTLSConfig 信任服务端证书的客户端 TLS 配置
+func (s *Server) ClientTLSConfig() *tls.Config {
+	return &tls.Config{RootCAs: s.pool, ServerName: "127.0.0.1", MinVersion: tls.VersionTLS12}
+}
+
+// Close 停止监听，关闭未结束的连接并等待处理结束
+func (s *Server) Close() {
+	s.ln.Close()
+	s.mu.Lock()
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+}
+
+// SetUIDValidity 修改 UIDVALIDITY 并替换全部邮件，模拟服务端重建邮箱
+func (s *Server) SetUIDValidity(v uint32, messages ...Message) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.uidValidity = v
+	s.messages = append([]Message(nil), messages...)
+}
+
+// DisableStartTLS 之后的连接不再声明也不支持 STARTTLS
+func (s *Server) DisableStartTLS() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.noStartTLS = true
+}
+
+func (s *Server) startTLSDisabled() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.noStartTLS
+}
+
+// Append 追加邮件，UID 需大于已有邮件
+func (s *Server) Append(messages ...Message) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.messages = append(s.messages, messages...)
+}
+
+// Commands 收到的命令（不含 tag），LOGIN 的参数会被隐藏
+func (s *Server) Commands() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.commands...)
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		s.conns[conn] = struct{}{}
+		s.mu.Unlock()
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			defer func() {
+				s.mu.Lock()
+				delete(s.conns, conn)
+				s.mu.Unlock()
+				conn.Close()
+			}()
+			_ = conn.SetDeadline(time.Now().Add(30 * time.Second))
+			s.handle(conn)
+		}()
+	}
+}
+
+// session 一个连接的状态
+type session struct {
+	conn     net.Conn
+	r        *bufio.Reader
+	w        *bufio.Writer
+	secure   bool
+	loggedIn bool
+	selected bool
+}
+
+func (s *Server) handle(conn net.Conn) {
+	sess := &session{conn: conn, r: bufio.NewReader(conn), w: bufio.NewWriter(conn)}
+	sess.reply("* OK [CAPABILITY %s] imaptest ready", s.capabilities(sess))
+	for {
+		line, err := sess.r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		tag, rest, _ := strings.Cut(strings.TrimRight(line, "\r\n"), " ")
+		args := parseArgs(rest)
+		if len(args) == 0 {
+			sess.reply("%s BAD empty command", tag)
+			continue
+		}
+		command := strings.ToUpper(args[0])
+		if command == "UID" && len(args) > 1 {
+			command += " " + strings.ToUpper(args[1])
+			args = args[1:]
+		}
+		s.record(command, args[1:])
+		if !s.execute(sess, tag, command, args[1:]) {
+			return
+		}
+	}
+}
+
+func (s *Server) record(command string, args []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if command == "LOGIN" {
+		s.commands = append(s.commands, command)
+		return
+	}
+	s.commands = append(s.commands, strings.TrimSpace(command+" "+strings.Join(args, " ")))
+}
+
+func (s *Server) capabilities(sess *session) string {
+	if s.startTLSDisabled() || sess.secure {
+		return "IMAP4rev1"
+	}
+	return "IMAP4rev1 STARTTLS"
+}
+
+// execute 执行一条命令，返回 false 时关闭连接
+func (s *Server) execute(sess *session, tag, command string, args []string) bool {
+	switch command {
+	case "CAPABILITY":
+		sess.reply("* CAPABILITY %s", s.capabilities(sess))
+		sess.reply("%s OK CAPABILITY completed", tag)
+	case "STARTTLS":
+		if s.startTLSDisabled() || sess.secure {
+			sess.reply("%s BAD STARTTLS not available", tag)
+			return true
+		}
+		sess.reply("%s OK begin TLS negotiation", tag)
+		tlsConn := tls.Server(sess.conn, s.tlsConf)
+		if err := tlsConn.Handshake(); err != nil {
+			return false
+		}
+		sess.conn = tlsConn
+		sess.r = bufio.NewReader(tlsConn)
+		sess.w = bufio.NewWriter(tlsConn)
+		sess.secure = true
+	case "LOGIN":
+		if len(args) != 2 || args[0] != s.Username || args[1] != s.Password {
+			sess.reply("%s NO [AUTHENTICATIONFAILED] invalid credentials", tag)
+			return true
+		}
+		sess.loggedIn = true
+		sess.reply("%s OK LOGIN completed", tag)
+	case "EXAMINE":
+		if !sess.loggedIn {
+			sess.reply("%s NO not authenticated", tag)
+			return true
+		}
+		s.mu.Lock()
+		exists, uidValidity, uidNext := len(s.messages), s.uidValidity, uint32(1)
+		if exists > 0 {
+			uidNext = s.messages[exists-1].UID + 1
+		}
+		s.mu.Unlock()
+		sess.selected = true
+		sess.reply("* %d EXISTS", exists)
+		sess.reply("* OK [UIDVALIDITY %d] UIDs valid", uidValidity)
+		sess.reply("* OK [UIDNEXT %d] predicted next UID", uidNext)
+		sess.reply("%s OK [READ-ONLY] EXAMINE completed", tag)
+	case "UID SEARCH":
+		if !sess.selected || len(args) != 2 || !strings.EqualFold(args[0], "UID") {
+			sess.reply("%s BAD unsupported search", tag)
+			return true
+		}
+		from, _ := strconv.ParseUint(strings.TrimSuffix(args[1], ":*"), 10, 32)
+		var uids []string
+		s.mu.Lock()
+		for _, m := range s.messages {
+			if m.UID >= uint32(from) {
+				uids = append(uids, strconv.FormatUint(uint64(m.UID), 10))
+			}
+		}
+		// 与真实服务器一致，"n:*" 在没有更大 UID 时返回最大的 UID
+		if len(uids) == 0 && len(s.messages) > 0 {
+			uids = append(uids, strconv.FormatUint(uint64(s.messages[len(s.messages)-1].UID), 10))
+		}
+		s.mu.Unlock()
+		sess.reply("* SEARCH %s", strings.Join(uids, " "))
+		sess.reply("%s OK SEARCH completed", tag)
+	case "UID FETCH":
+		if !sess.selected || len(args) < 2 {
+			sess.reply("%s BAD unsupported fetch", tag)
+			return true
+		}
+		uid, _ := strconv.ParseUint(args[0], 10, 32)
+		msg, seq := s.lookup(uint32(uid))
+		if msg != nil {
+			items := strings.ToUpper(strings.Join(args[1:], " "))
+			if strings.Contains(items, "BODY.PEEK[]") {
+				sess.reply("* %d FETCH (UID %d BODY[] {%d}", seq, msg.UID, len(msg.Raw))
+				sess.w.Write(msg.Raw)
+				sess.reply(")")
+			} else {
+				sess.reply("* %d FETCH (UID %d RFC822.SIZE %d)", seq, msg.UID, len(msg.Raw))
+			}
+		}
+		sess.reply("%s OK FETCH completed", tag)
+	case "LOGOUT":
+		sess.reply("* BYE logging out")
+		sess.reply("%s OK LOGOUT completed", tag)
+		return false
+	default:
+		sess.reply("%s BAD unknown command", tag)
+	}
+	return true
+}
+
+func (s *Server) lookup(uid uint32) (*Message, int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := range s.messages {
+		if s.messages[i].UID == uid {
+			m := s.messages[i]
+			return &m, i + 1
+		}
+	}
+	return nil, 0
+}
+
+func (sess *session) reply(format string, args ...any) {
+	fmt.Fprintf(sess.w, format+"\r\n", args...)
+	sess.w.Flush()
+}
+
+// parseArgs 拆分命令参数，支持带转义的 quoted 字符串，不支持 literal
+func parseArgs(line string) []string {
+	var args []string
+	for i := 0; i < len(line); {
+		switch {
+		case line[i] == ' ':
+			i++
+		case line[i] == '"':
+			var b strings.Builder
+			for i++; i < len(line) && line[i] != '"'; i++ {
+				if line[i] == '\\' && i+1 < len(line) {
+					i++
+				}
+				b.WriteByte(line[i])
+			}
+			args = append(args, b.String())
+			i++
+		case line[i] == '(':
+			end := strings.IndexByte(line[i:], ')')
+			if end < 0 {
+				end = len(line) - i - 1
+			}
+			args = append(args, line[i:i+end+1])
+			i += end + 1
+		default:
+			end := strings.IndexByte(line[i:], ' ')
+			if end < 0 {
+				end = len(line) - i
+			}
+			args = append(args, line[i:i+end])
+			i += end
+		}
+	}
+	return args
+}
+
+// selfSigned 生成 127.0.0.1 的自签名证书
+func selfSigned() (tls.Certificate, *x509.CertPool, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, nil, err
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "imaptest"},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, nil, err
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		return tls.Certificate{}, nil, err
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(leaf)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}, pool, nil
+}
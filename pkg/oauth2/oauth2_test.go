@@ -0,0 +1,131 @@
+package oauth2
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// tokenServer 令牌端点桩，handle 根据表单参数返回状态码与 JSON
+func tokenServer(t *testing.T, handle func(form url.Values) (int, string)) (*Config, *[]url.Values) {
+	t.Helper()
+	var forms []url.Values
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/x-www-form-urlencoded" {
+			t.Errorf("unexpected token request %s %s", r.Method, r.Header.Get("Content-Type"))
+		}
+		if err := r.ParseForm(); err != nil {
+			t.Errorf("parse form: %v", err)
+		}
+		forms = append(forms, r.PostForm)
+		status, body := handle(r.PostForm)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(body))
+	}))
+	t.Cleanup(srv.Close)
+	return &Config{
+		ClientID:     "client",
+		ClientSecret: "secret",
+		AuthURL:      srv.URL + "/auth",
+		TokenURL:     srv.URL + "/token",
+		RedirectURL:  "https://example.com/callback",
+		Scopes:       []string{"a", "b"},
+	}, &forms
+}
+
+func TestAuthCodeURL(t *testing.T) {
+	c := &Config{ClientID: "client", AuthURL: "https://idp.example/auth?tenant=x", RedirectURL: "https://example.com/cb", Scopes: []string{"a", "b"}}
+	u, err := url.Parse(c.AuthCodeURL("st", map[string]string{"prompt": "consent"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := u.Query()
+	for k, want := range map[string]string{
+		"tenant": "x", "response_type": "code", "client_id": "client", "redirect_uri": "https://example.com/cb",
+		"scope": "a b", "state": "st", "prompt": "consent",
+	} {
+		if got := q.Get(k); got != want {
+			t.Errorf("%s = %q, want %q", k, got, want)
+		}
+	}
+}
+
+func TestExchange(t *testing.T) {
+	c, forms := tokenServer(t, func(form url.Values) (int, string) {
+		return http.StatusOK, `{"access_token":"at","refresh_token":"rt","token_type":"Bearer","scope":"a b","expires_in":3600}`
+	})
+
+	tok, err := c.Exchange(context.Background(), "code-1")
+	if err != nil {
+		t.Fatalf("exchange: %v", err)
+	}
+	if tok.AccessToken != "at" || tok.RefreshToken != "rt" || tok.Scope != "a b" || !tok.Valid() {
+		t.Fatalf("unexpected token %+v", tok)
+	}
+	if d := time.Until(tok.Expiry); d < 59*time.Minute || d > time.Hour {
+		t.Fatalf("expiry in %s, want about 1h", d)
+	}
+	form := (*forms)[0]
+	for k, want := range map[string]string{
+		"grant_type": "authorization_code", "code": "code-1", "redirect_uri": "https://example.com/callback",
+		"client_id": "client", "client_secret": "secret",
+	} {
+		if got := form.Get(k); got != want {
+			t.Errorf("%s = %q, want %q", k, got, want)
+		}
+	}
+}
+
+func TestRefreshKeepsRefreshToken(t *testing.T) {
+	c, forms := tokenServer(t, func(form url.Values) (int, string) {
+		return http.StatusOK, `{"access_token":"at2","expires_in":3600}`
+	})
+
+	tok, err := c.Refresh(context.Background(), "rt")
+	if err != nil {
+		t.Fatalf("refresh: %v", err)
+	}
+	if tok.AccessToken != "at2" || tok.RefreshToken != "rt" {
+		t.Fatalf("unexpected token %+v", tok)
+	}
+	if form := (*forms)[0]; form.Get("grant_type") != "refresh_token" || form.Get("refresh_token") != "rt" {
+		t.Fatalf("unexpected form %v", form)
+	}
+}
+
+func TestRefreshInvalidGrant(t *testing.T) {
+	c, _ := tokenServer(t, func(form url.Values) (int, string) {
+		return http.StatusBadRequest, `{"error":"invalid_grant","error_description":"Token has been expired or revoked."}`
+	})
+
+	_, err := c.Refresh(context.Background(), "rt")
+	if !IsInvalidGrant(err) {
+		t.Fatalf("err = %v, want invalid_grant", err)
+	}
+	if !strings.Contains(err.Error(), "400") {
+		t.Fatalf("error should carry status: %v", err)
+	}
+}
+
+func TestTokenValid(t *testing.T) {
+	cases := []struct {
+		tok  *Token
+		want bool
+	}{
+		{nil, false},
+		{&Token{}, false},
+		{&Token{AccessToken: "at"}, true},
+		{&Token{AccessToken: "at", Expiry: time.Now().Add(time.Hour)}, true},
+		{&Token{AccessToken: "at", Expiry: time.Now().Add(30 * time.Second)}, false},
+	}
+	for i, c := range cases {
+		if got := c.tok.Valid(); got != c.want {
+			t.Errorf("case %d: Valid() = %v, want %v", i, got, c.want)
+		}
+	}
+}
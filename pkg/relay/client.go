@@ -0,0 +1,84 @@
+// Package relay 通过外发中继（smarthost）投递邮件
+package relay
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strconv"
+	"time"
+
+	appconfig "plaud-emails/pkg/config"
+)
+
+// Client 外发中继客户端，每次投递建立一个连接
+type Client struct {
+	conf *appconfig.RelayConfig
+}
+
+// NewClient 创建 Client，conf 为 nil 时返回 nil
+func NewClient(conf *appconfig.RelayConfig) *Client {
+	if conf == nil {
+		return nil
+	}
+	return &Client{conf: conf}
+}
+
+// EnvelopeFrom 转发邮件使用的信封发件人
+func (c *Client) EnvelopeFrom() string {
+	return c.conf.EnvelopeFrom
+}
+
+// Send 将原始邮件投递给 to，中继支持时使用 STARTTLS，配置了用户名时进行 PLAIN 认证
+func (c *Client) Send(ctx context.Context, from, to string, data []byte) error {
+	addr := net.JoinHostPort(c.conf.Host, strconv.Itoa(c.conf.Port))
+	timeout := time.Duration(c.conf.TimeoutSeconds) * time.Second
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return fmt.Errorf("dial relay %s: %w", addr, err)
+	}
+	deadline, _ := ctx.Deadline()
+	_ = conn.SetDeadline(deadline)
+
+	client, err := smtp.NewClient(conn, c.conf.Host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("relay greeting: %w", err)
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: c.conf.Host}); err != nil {
+			return fmt.Errorf("relay starttls: %w", err)
+		}
+	}
+	if c.conf.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", c.conf.Username, c.conf.Password, c.conf.Host)); err != nil {
+			return fmt.Errorf("relay auth: %w", err)
+		}
+	}
+	if err := client.Mail(from); err != nil {
+		return fmt.Errorf("relay mail from: %w", err)
+	}
+	if err := client.Rcpt(to); err != nil {
+		return fmt.Errorf("relay rcpt to: %w", err)
+	}
+	w, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("relay data: %w", err)
+	}
+	if _, err := w.Write(data); err != nil {
+		w.Close()
+		return fmt.Errorf("relay write: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("relay data end: %w", err)
+	}
+	return client.Quit()
+}
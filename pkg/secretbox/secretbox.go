@@ -0,0 +1,66 @@
+// Package secretbox 使用 AES-256-GCM 加密需要落库的敏感数据（如外部邮箱凭据）
+package secretbox
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"strings"
+)
+
+// version 密文格式版本前缀，便于日后轮换算法或密钥
+const version = "v1:"
+
+// 错误定义
+var (
+	ErrInvalidKey        = errors.New("secretbox key must be 32 bytes base64")
+	ErrMalformedCipher   = errors.New("malformed ciphertext")
+	ErrUnsupportedFormat = errors.New("unsupported ciphertext version")
+)
+
+// Box 对称加密器
+type Box struct {
+	aead cipher.AEAD
+}
+
+// New 使用 base64 编码的 32 字节密钥创建 Box
+func New(key string) (*Box, error) {
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(key))
+	if err != nil || len(raw) != 32 {
+		return nil, ErrInvalidKey
+	}
+	block, err := aes.NewCipher(raw)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &Box{aead: aead}, nil
+}
+
+// Seal 加密明文，返回带版本前缀的 base64 密文
+func (b *Box) Seal(plaintext []byte) (string, error) {
+	nonce := make([]byte, b.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	out := b.aead.Seal(nonce, nonce, plaintext, nil)
+	return version + base64.StdEncoding.EncodeToString(out), nil
+}
+
+// Open 解密 Seal 生成的密文
+func (b *Box) Open(ciphertext string) ([]byte, error) {
+	if !strings.HasPrefix(ciphertext, version) {
+		return nil, ErrUnsupportedFormat
+	}
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(ciphertext, version))
+	if err != nil || len(raw) < b.aead.NonceSize() {
+		return nil, ErrMalformedCipher
+	}
+	nonce, data := raw[:b.aead.NonceSize()], raw[b.aead.NonceSize():]
+	return b.aead.Open(nil, nonce, data, nil)
+}
@@ -0,0 +1,17 @@
+// Package textutil 提供落库、索引前的字符串处理工具
+package textutil
+
+import "unicode/utf8"
+
+// Truncate 按字节截断字符串，保证不截断 UTF-8 字符
+func Truncate(v string, max int) string {
+	if len(v) <= max {
+		return v
+	}
+	// 截断位置落在多字节字符中间时回退到该字符的起始字节，其他位置的非法字节保留
+	cut := max
+	for i := 0; i < utf8.UTFMax-1 && cut > 0 && !utf8.RuneStart(v[cut]); i++ {
+		cut--
+	}
+	return v[:cut]
+}
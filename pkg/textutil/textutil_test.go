@@ -0,0 +1,29 @@
+package textutil
+
+import "testing"
+
+func TestTruncate(t *testing.T) {
+	cases := []struct {
+		in   string
+		max  int
+		want string
+	}{
+		{"hello", 10, "hello"},
+		{"hello", 5, "hello"},
+		{"hello", 3, "hel"},
+		{"hello", 0, ""},
+		// "中" 占 3 字节，截断位置落在字符中间时回退
+		{"a中文", 1, "a"},
+		{"a中文", 2, "a"},
+		{"a中文", 3, "a"},
+		{"a中文", 4, "a中"},
+		{"😀x", 3, ""},
+		// 截断位置之前的非法字节保留
+		{"ab\xff\x80cd", 4, "ab\xff\x80"},
+	}
+	for _, c := range cases {
+		if got := Truncate(c.in, c.max); got != c.want {
+			t.Errorf("Truncate(%q, %d) = %q, want %q", c.in, c.max, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,353 @@
+package inbound
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"time"
+
+	datamodel "plaud-emails/data/model"
+	"plaud-emails/pkg/relay"
+	"plaud-emails/service/mailauth"
+	"plaud-emails/service/message"
+	"plaud-emails/service/mindadvisor"
+	"plaud-emails/service/smtpd"
+
+	"github.com/Plaud-AI/plaud-go-scaffold/pkg/logger"
+)
+
+// forwardedHeader 标签规则转发时添加的头部，值为投递地址及其签名
+// 带有有效签名的邮件不会被再次转发以避免转发环路；收信时该头部一律移除，发信方无法借此阻止转发
+const forwardedHeader = "X-Myplaud-Forwarded-For"
+
+// duplicateWindow 按 Message-ID 判断重复投递的时间窗口，覆盖发信方的重试周期
+const duplicateWindow = 7 * 24 * time.Hour
+
+// InboundService 收信业务后端，实现 smtpd.Backend
+type InboundService struct {
+	mindAdvisor *mindadvisor.MindAdvisorService
+	messages    *message.MessageService
+	relay       *relay.Client
+	domains     map[string]struct{}
+	verifier    *mailauth.Verifier
+	authservID  string
+	authTimeout time.Duration
+	forwardKey  []byte
+}
+
+var _ smtpd.Backend = (*InboundService)(nil)
+
+// New 创建 InboundService，domains 为接收的收件域名，relayClient 为 nil 时不执行标签规则的转发
+func New(mindAdvisor *mindadvisor.MindAdvisorService, messages *message.MessageService, relayClient *relay.Client, domains []string) *InboundService {
+	ds := make(map[string]struct{}, len(domains))
+	for _, d := range domains {
+		ds[strings.ToLower(strings.TrimPrefix(d, "@"))] = struct{}{}
+	}
+	return &InboundService{
+		mindAdvisor: mindAdvisor,
+		messages:    messages,
+		relay:       relayClient,
+		domains:     ds,
+	}
+}
+
+// SetAuthVerifier 启用发信方认证检查，authservID 为 Authentication-Results 头中标识本服务的名称，通常为 SMTP 主机名
+func (s *InboundService) SetAuthVerifier(verifier *mailauth.Verifier, authservID string, timeout time.Duration) {
+	s.verifier = verifier
+	s.authservID = authservID
+	s.authTimeout = timeout
+}
+
+// SetForwardSecret 设置签名转发标记头的密钥，未设置时不执行标签规则的转发
+// 多个副本需使用相同的密钥，否则无法识别其他副本转发出去的邮件
+func (s *InboundService) SetForwardSecret(secret string) {
+	s.forwardKey = []byte(secret)
+}
+
+// ResolveRecipient 将 RCPT TO 地址解析为心智幕僚用户
+// 接收域名下的 local_part 统一映射为 local_part@myplaud 专属邮箱，local_part+tag 的子地址标签会被剥离
+// 用户存储用量已满，或存入声明的 size 后会超出配额时拒收
+func (s *InboundService) ResolveRecipient(ctx context.Context, address string, size int64) (*smtpd.Recipient, error) {
+	localPart, domain, ok := splitAddress(address)
+	if !ok {
+		return nil, smtpd.NewError(501, "5.1.3", "bad recipient address syntax")
+	}
+	if _, ok := s.domains[domain]; !ok {
+		return nil, smtpd.ErrRelayDenied
+	}
+
+	localPart, tag := mindadvisor.SplitSubaddress(localPart)
+	if tag != "" && mindadvisor.ValidateTag(tag) != nil {
+		return nil, smtpd.ErrMailboxNotFound
+	}
+
+	dedicatedEmail := localPart + mindadvisor.EmailDomain
+	rcpt, err := s.mindAdvisor.ResolveRecipient(ctx, dedicatedEmail)
+	if err != nil {
+		switch {
+		case errors.Is(err, mindadvisor.ErrRecipientNotFound):
+			return nil, smtpd.ErrMailboxNotFound
+		case errors.Is(err, mindadvisor.ErrMailboxInactive):
+			return nil, smtpd.ErrMailboxDisabled
+		default:
+			return nil, err
+		}
+	}
+
+	if err := s.messages.CheckQuota(ctx, rcpt.User.UserID, size); err != nil {
+		if errors.Is(err, message.ErrQuotaExceeded) {
+			return nil, smtpd.ErrMailboxFull
+		}
+		return nil, err
+	}
+
+	return &smtpd.Recipient{
+		Address:        address,
+		UserID:         rcpt.User.UserID,
+		DedicatedEmail: rcpt.User.DedicatedEmail,
+		Alias:          rcpt.Alias,
+		Tag:            tag,
+	}, nil
+}
+
+// Deliver 投递邮件，每个收件人各自保存一份副本，并执行子地址标签规则
+// 部分收件人保存失败时返回临时错误，发信方重试时已保存的收件人按 Message-ID 跳过，不会产生重复邮件
+func (s *InboundService) Deliver(ctx context.Context, env *smtpd.Envelope, data []byte) error {
+	var remoteIP string
+	if ip := env.RemoteIP(); ip != nil {
+		remoteIP = ip.String()
+	}
+
+	// 未声明 SIZE 时 RCPT 阶段无法判断，收到完整邮件后再检查一次；任一收件人超出配额则整封拒收，避免部分投递后重试产生重复邮件
+	size := int64(len(data))
+	for _, rcpt := range env.Recipients {
+		if err := s.messages.CheckQuota(ctx, rcpt.UserID, size); err != nil {
+			if errors.Is(err, message.ErrQuotaExceeded) {
+				logger.InfofCtx(ctx, "inbound message %s rejected, mailbox of user %s is full", env.ID, rcpt.UserID)
+				return smtpd.ErrMailboxFull
+			}
+			return smtpd.ErrTemporaryFailure
+		}
+	}
+
+	messageID := headerValue(data, "Message-Id")
+	auth, data := s.authenticate(ctx, env, data)
+	marks, data := takeHeader(data, forwardedHeader)
+	forwarded := s.forwardedByUs(marks)
+	for _, rcpt := range env.Recipients {
+		deliveredTo := deliveredAddress(rcpt)
+		key := deliveryKey(rcpt.UserID, deliveredTo, messageID, env.ID)
+		delivered, err := s.messages.DeliveredSince(ctx, rcpt.UserID, key, env.ReceivedAt.Add(-duplicateWindow))
+		if err != nil {
+			return smtpd.ErrTemporaryFailure
+		}
+		if delivered {
+			logger.InfofCtx(ctx, "inbound message %s already delivered to %s, skip", env.ID, deliveredTo)
+			continue
+		}
+
+		rule, err := s.mindAdvisor.GetTagRule(ctx, rcpt.UserID, rcpt.Tag)
+		if err != nil {
+			return smtpd.ErrTemporaryFailure
+		}
+
+		raw := append([]byte("Delivered-To: "+deliveredTo+"\r\n"), data...)
+		in := &message.StoreInput{
+			UserID:         rcpt.UserID,
+			DedicatedEmail: rcpt.DedicatedEmail,
+			Alias:          rcpt.Alias,
+			Tag:            rcpt.Tag,
+			Source:         datamodel.MessageSourceSMTP,
+			EnvelopeFrom:   env.MailFrom,
+			RemoteIP:       remoteIP,
+			Helo:           env.Helo,
+			ExternalID:     key,
+			ReceivedAt:     env.ReceivedAt,
+			Raw:            raw,
+			Auth:           auth,
+		}
+		if rule != nil {
+			in.Label = rule.Label
+			in.Muted = rule.Mute
+		}
+		msg, err := s.messages.Store(ctx, in)
+		if err != nil {
+			logger.ErrorfCtx(ctx, "store inbound message %s for %s error: %v", env.ID, deliveredTo, err)
+			return smtpd.ErrTemporaryFailure
+		}
+		logger.InfofCtx(ctx, "inbound message %s from <%s> stored as %d for %s",
+			env.ID, env.MailFrom, msg.ID, deliveredTo)
+
+		if rule != nil && rule.ForwardLinkedEmailID > 0 && !forwarded {
+			s.forward(ctx, rule, deliveredTo, raw)
+		}
+	}
+	return nil
+}
+
+// deliveryKey 收件人投递的幂等键，保存在 ExternalID 中
+// 发信方重试时 Message-ID 不变；缺少 Message-ID 时退化为按本次会话的邮件 id 去重
+func deliveryKey(userID, deliveredTo, messageID, envID string) string {
+	id := "message-id:" + strings.TrimSpace(messageID)
+	if messageID == "" {
+		id = "envelope:" + envID
+	}
+	sum := sha256.Sum256([]byte(userID + "\n" + strings.ToLower(deliveredTo) + "\n" + id))
+	return "smtp:" + hex.EncodeToString(sum[:])
+}
+
+// authenticate 检查发信方的 SPF、DKIM 与 DMARC，移除伪造的本服务 Authentication-Results 头后写入检查结果
+// 每封邮件只检查一次，各收件人的副本共用结果；未启用检查时原样返回
+func (s *InboundService) authenticate(ctx context.Context, env *smtpd.Envelope, data []byte) (*mailauth.Results, []byte) {
+	if s.verifier == nil {
+		return nil, data
+	}
+	authCtx, cancel := context.WithTimeout(ctx, s.authTimeout)
+	defer cancel()
+	res := s.verifier.Verify(authCtx, &mailauth.Input{
+		RemoteIP: env.RemoteIP(),
+		Helo:     env.Helo,
+		MailFrom: env.MailFrom,
+		Raw:      data,
+	})
+	logger.InfofCtx(ctx, "inbound message %s from <%s> auth spf=%s dmarc=%s from_domain=%s",
+		env.ID, env.MailFrom, res.SPF.Result, res.DMARC.Result, res.FromDomain)
+
+	header := mailauth.FormatResultsHeader(s.authservID, res)
+	return res, append([]byte(header), mailauth.StripResultsHeaders(data, s.authservID)...)
+}
+
+// forward 按标签规则异步转发到用户的绑定邮箱，失败只记录日志
+func (s *InboundService) forward(ctx context.Context, rule *datamodel.MindAdvisorTagRule, deliveredTo string, raw []byte) {
+	if s.relay == nil {
+		logger.WarnfCtx(ctx, "relay not configured, skip forwarding tag %s of user %s", rule.Tag, rule.UserID)
+		return
+	}
+	link, err := s.mindAdvisor.GetForwardTarget(ctx, rule.UserID, rule.ForwardLinkedEmailID)
+	if err != nil {
+		return
+	}
+	if link == nil {
+		logger.WarnfCtx(ctx, "forward target %d of tag %s is unavailable", rule.ForwardLinkedEmailID, rule.Tag)
+		return
+	}
+
+	if len(s.forwardKey) == 0 {
+		logger.WarnfCtx(ctx, "forward secret not configured, skip forwarding tag %s of user %s", rule.Tag, rule.UserID)
+		return
+	}
+	mark := deliveredTo + "; sig=" + s.forwardSignature(deliveredTo)
+	data := append([]byte(forwardedHeader+": "+mark+"\r\n"), raw...)
+	ctx = context.WithoutCancel(ctx)
+	go func() {
+		if err := s.relay.Send(ctx, s.relay.EnvelopeFrom(), link.Email, data); err != nil {
+			logger.ErrorfCtx(ctx, "forward message for %s to linked email %d error: %v", deliveredTo, link.ID, err)
+		}
+	}()
+}
+
+// deliveredAddress 收件人实际投递的地址，包含别名与子地址标签
+func deliveredAddress(rcpt *smtpd.Recipient) string {
+	addr := rcpt.DedicatedEmail
+	if rcpt.Alias != "" {
+		addr = rcpt.Alias
+	}
+	if rcpt.Tag == "" {
+		return addr
+	}
+	localPart, domain, _ := strings.Cut(addr, "@")
+	return localPart + mindadvisor.SubaddressSeparator + rcpt.Tag + "@" + domain
+}
+
+// forwardSignature 转发标记的签名
+func (s *InboundService) forwardSignature(deliveredTo string) string {
+	mac := hmac.New(sha256.New, s.forwardKey)
+	mac.Write([]byte(strings.ToLower(deliveredTo)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// forwardedByUs 判断转发标记中是否有本服务签发的有效标记
+func (s *InboundService) forwardedByUs(marks []string) bool {
+	if len(s.forwardKey) == 0 {
+		return false
+	}
+	for _, mark := range marks {
+		addr, sig, ok := strings.Cut(mark, ";")
+		if !ok {
+			continue
+		}
+		sig = strings.TrimPrefix(strings.TrimSpace(sig), "sig=")
+		expected := s.forwardSignature(strings.TrimSpace(addr))
+		if hmac.Equal([]byte(sig), []byte(expected)) {
+			return true
+		}
+	}
+	return false
+}
+
+// headerValue 返回邮件头部中指定字段的值
+func headerValue(data []byte, key string) string {
+	header, _ := textproto.NewReader(bufio.NewReader(bytes.NewReader(data))).ReadMIMEHeader()
+	return header.Get(key)
+}
+
+// takeHeader 移除邮件头部中的全部指定字段，返回这些字段的值（折行已展开）
+func takeHeader(data []byte, key string) ([]string, []byte) {
+	var (
+		values []string
+		out    bytes.Buffer
+		skip   bool
+	)
+	rest := data
+	for len(rest) > 0 {
+		end := bytes.IndexByte(rest, '\n') + 1
+		if end == 0 {
+			end = len(rest)
+		}
+		line := rest[:end]
+		if len(bytes.TrimRight(line, "\r\n")) == 0 {
+			// 头部结束
+			break
+		}
+		rest = rest[end:]
+		if line[0] == ' ' || line[0] == '\t' {
+			if skip {
+				values[len(values)-1] += " " + strings.TrimSpace(string(line))
+				continue
+			}
+		} else {
+			name, value, _ := strings.Cut(string(line), ":")
+			skip = strings.EqualFold(strings.TrimSpace(name), key)
+			if skip {
+				values = append(values, strings.TrimSpace(value))
+				continue
+			}
+		}
+		out.Write(line)
+	}
+	if len(values) == 0 {
+		return nil, data
+	}
+	out.Write(rest)
+	return values, out.Bytes()
+}
+
+// splitAddress 拆分邮箱地址为小写的 local_part 与域名
+func splitAddress(address string) (localPart, domain string, ok bool) {
+	addr, err := mail.ParseAddress("<" + address + ">")
+	if err != nil {
+		return "", "", false
+	}
+	at := strings.LastIndex(addr.Address, "@")
+	if at <= 0 || at == len(addr.Address)-1 {
+		return "", "", false
+	}
+	return strings.ToLower(addr.Address[:at]), strings.ToLower(addr.Address[at+1:]), true
+}
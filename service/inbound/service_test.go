@@ -0,0 +1,111 @@
+package inbound
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"plaud-emails/service/smtpd"
+)
+
+func TestTakeHeader(t *testing.T) {
+	data := []byte("Received: from a\r\n\tby b\r\n" +
+		"X-Myplaud-Forwarded-For: a@myplaud.com;\r\n sig=abc\r\n" +
+		"Subject: hi\r\n" +
+		"x-myplaud-forwarded-for: forged\r\n" +
+		"\r\n" +
+		"X-Myplaud-Forwarded-For: in body\r\n")
+
+	values, out := takeHeader(data, forwardedHeader)
+	if want := []string{"a@myplaud.com; sig=abc", "forged"}; !slices.Equal(values, want) {
+		t.Fatalf("values = %q, want %q", values, want)
+	}
+	want := "Received: from a\r\n\tby b\r\nSubject: hi\r\n\r\nX-Myplaud-Forwarded-For: in body\r\n"
+	if string(out) != want {
+		t.Fatalf("out = %q, want %q", out, want)
+	}
+
+	plain := []byte("Subject: hi\r\n\r\nbody")
+	if values, out := takeHeader(plain, forwardedHeader); values != nil || string(out) != string(plain) {
+		t.Fatalf("message without the header should be unchanged: %q %q", values, out)
+	}
+}
+
+func TestForwardedByUs(t *testing.T) {
+	s := &InboundService{}
+	s.SetForwardSecret("secret")
+	mark := "Tom+news@myplaud.com; sig=" + s.forwardSignature("tom+news@myplaud.com")
+
+	if !s.forwardedByUs([]string{"forged@myplaud.com", mark}) {
+		t.Fatal("signed mark should be recognized")
+	}
+	if s.forwardedByUs([]string{"tom+news@myplaud.com", "tom+news@myplaud.com; sig=deadbeef"}) {
+		t.Fatal("unsigned or forged marks must not suppress forwarding")
+	}
+	other := &InboundService{}
+	other.SetForwardSecret("other")
+	if other.forwardedByUs([]string{mark}) {
+		t.Fatal("marks signed with another key must not be trusted")
+	}
+	if (&InboundService{}).forwardedByUs([]string{mark}) {
+		t.Fatal("marks must not be trusted without a key")
+	}
+}
+
+func TestDeliveryKey(t *testing.T) {
+	a := deliveryKey("u1", "Tom@myplaud.com", "<m1@example.com>", "env1")
+	if a != deliveryKey("u1", "tom@myplaud.com", "<m1@example.com>", "env2") {
+		t.Fatal("retries of the same message should share a key")
+	}
+	if a == deliveryKey("u1", "tom+news@myplaud.com", "<m1@example.com>", "env1") {
+		t.Fatal("each delivered address keeps its own copy")
+	}
+	if a == deliveryKey("u2", "tom@myplaud.com", "<m1@example.com>", "env1") {
+		t.Fatal("keys must be scoped to the user")
+	}
+	if deliveryKey("u1", "tom@myplaud.com", "", "env1") == deliveryKey("u1", "tom@myplaud.com", "", "env2") {
+		t.Fatal("messages without Message-ID fall back to the envelope id")
+	}
+	if len(a) > 255 {
+		t.Fatalf("key too long for external_id: %d", len(a))
+	}
+}
+
+func TestDeliveredAddress(t *testing.T) {
+	cases := []struct {
+		rcpt *smtpd.Recipient
+		want string
+	}{
+		{&smtpd.Recipient{DedicatedEmail: "jane@myplaud"}, "jane@myplaud"},
+		// 通过别名收信时记录别名而不是专属邮箱
+		{&smtpd.Recipient{DedicatedEmail: "jane@myplaud", Alias: "jd@myplaud"}, "jd@myplaud"},
+		{&smtpd.Recipient{DedicatedEmail: "jane@myplaud", Tag: "receipts"}, "jane+receipts@myplaud"},
+		{&smtpd.Recipient{DedicatedEmail: "jane@myplaud", Alias: "jd@myplaud", Tag: "news"}, "jd+news@myplaud"},
+	}
+	for _, c := range cases {
+		if got := deliveredAddress(c.rcpt); got != c.want {
+			t.Errorf("deliveredAddress(%+v) = %q, want %q", c.rcpt, got, c.want)
+		}
+	}
+}
+
+func TestResolveRecipientRejectsBeforeLookup(t *testing.T) {
+	// 地址格式、域名与子地址标签的校验不查询用户
+	s := New(nil, nil, nil, []string{"@myplaud.com"})
+	cases := map[string]string{
+		"jane+Bad Tag@myplaud.com": "5.1.3",
+		"jane@@myplaud.com":        "5.1.3",
+		"jane+a+b@myplaud.com":     smtpd.ErrMailboxNotFound.EnhancedCode,
+		"jane+a/b@myplaud.com":     smtpd.ErrMailboxNotFound.EnhancedCode,
+		"jane@example.com":         smtpd.ErrRelayDenied.EnhancedCode,
+		"jane+news@Example.com":    smtpd.ErrRelayDenied.EnhancedCode,
+	}
+	for address, want := range cases {
+		_, err := s.ResolveRecipient(context.Background(), address, 0)
+		var smtpErr *smtpd.Error
+		if !errors.As(err, &smtpErr) || smtpErr.EnhancedCode != want {
+			t.Errorf("ResolveRecipient(%q) err = %v, want %s", address, err, want)
+		}
+	}
+}
@@ -0,0 +1,177 @@
+package linkedemail
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"time"
+
+	datamodel "plaud-emails/data/model"
+	"plaud-emails/pkg/oauth2"
+	"plaud-emails/service/mailsync"
+
+	"github.com/Plaud-AI/plaud-go-scaffold/pkg/logger"
+	"github.com/Plaud-AI/plaud-go-scaffold/pkg/rdb"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// oauthStateCache 授权 state 与发起用户的对应关系，一次有效
+var oauthStateCache = rdb.NewCacheConfig("plaud-emails:linked-email:oauth-state", 10*time.Minute, false)
+
+// revokeTimeout 解除绑定时撤销授权的超时时间
+const revokeTimeout = 10 * time.Second
+
+// 错误定义
+var (
+	ErrOAuthProviderDisabled = errors.New("oauth provider not configured")
+	ErrInvalidOAuthState     = errors.New("invalid or expired oauth state")
+	ErrOAuthExchangeFailed   = errors.New("oauth authorization failed")
+)
+
+// OAuthProvider 通过 OAuth2 授权绑定的邮箱服务商
+type OAuthProvider interface {
+	Source() string
+	// AuthCodeURL 生成授权页面地址
+	AuthCodeURL(state string) string
+	// Exchange 用授权码换取令牌
+	Exchange(ctx context.Context, code string) (*oauth2.Token, error)
+	// Identify 查询授权账户的邮箱地址
+	Identify(ctx context.Context, tok *oauth2.Token) (string, error)
+	// Revoke 撤销授权凭据
+	Revoke(ctx context.Context, cred *datamodel.OAuthCredential) error
+}
+
+// oauthState 授权发起时保存的上下文
+type oauthState struct {
+	UserID string `json:"user_id"`
+	Source string `json:"source"`
+}
+
+// AddOAuthProvider 注册 OAuth2 服务商
+func (s *LinkedEmailService) AddOAuthProvider(p OAuthProvider) {
+	s.providers[p.Source()] = p
+}
+
+// AuthorizeURL 为用户生成指定服务商的授权页面地址
+func (s *LinkedEmailService) AuthorizeURL(ctx context.Context, userID, source string) (string, error) {
+	p, err := s.provider(source)
+	if err != nil {
+		return "", err
+	}
+	user, err := s.userDao.GetByUserID(ctx, userID)
+	if err != nil {
+		logger.ErrorfCtx(ctx, "get mailbox error: %v", err)
+		return "", err
+	}
+	if user == nil || user.DedicatedEmail == "" {
+		return "", ErrMailboxNotCreated
+	}
+
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	state := base64.RawURLEncoding.EncodeToString(b)
+	data, err := json.Marshal(&oauthState{UserID: userID, Source: source})
+	if err != nil {
+		return "", err
+	}
+	if err := s.redisClient.SetString(ctx, oauthStateCache.NewParamKey(state), string(data)); err != nil {
+		logger.ErrorfCtx(ctx, "save oauth state error: %v", err)
+		return "", err
+	}
+	return p.AuthCodeURL(state), nil
+}
+
+// CompleteOAuth 处理授权回调：校验 state、换取令牌并绑定授权账户的邮箱
+// 对已绑定（含因撤销授权而停用）的同一账户再次授权会更新凭据并恢复同步
+func (s *LinkedEmailService) CompleteOAuth(ctx context.Context, source, state, code string) (*datamodel.MindAdvisorLinkedEmail, error) {
+	p, err := s.provider(source)
+	if err != nil {
+		return nil, err
+	}
+	if s.box == nil {
+		return nil, ErrCredentialStoreDisabled
+	}
+	if state == "" || code == "" {
+		return nil, ErrInvalidOAuthState
+	}
+
+	// state 一次有效，取出即删除
+	data, err := s.redisClient.GetClient().GetDel(ctx, oauthStateCache.NewParamKey(state).Key()).Result()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, ErrInvalidOAuthState
+		}
+		logger.ErrorfCtx(ctx, "get oauth state error: %v", err)
+		return nil, err
+	}
+	var st oauthState
+	if err := json.Unmarshal([]byte(data), &st); err != nil || st.Source != source || st.UserID == "" {
+		return nil, ErrInvalidOAuthState
+	}
+
+	tok, err := p.Exchange(ctx, code)
+	if err != nil {
+		logger.WarnfCtx(ctx, "exchange %s oauth code error: %v", source, err)
+		return nil, ErrOAuthExchangeFailed
+	}
+	if tok.RefreshToken == "" {
+		logger.WarnfCtx(ctx, "%s oauth returned no refresh token", source)
+		return nil, ErrOAuthExchangeFailed
+	}
+	email, err := p.Identify(ctx, tok)
+	if err != nil {
+		logger.WarnfCtx(ctx, "identify %s account error: %v", source, err)
+		return nil, ErrOAuthExchangeFailed
+	}
+	addr, err := normalizeEmail(email)
+	if err != nil {
+		return nil, err
+	}
+	cred, err := mailsync.SealOAuthToken(s.box, tok)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	return s.createLink(ctx, true, schedulePending(&datamodel.MindAdvisorLinkedEmail{
+		UserID:     st.UserID,
+		Email:      addr,
+		Source:     source,
+		Verified:   true,
+		VerifiedAt: &now,
+		Extra: &datamodel.LinkedEmailExtra{
+			VerifyMethod: datamodel.LinkedEmailVerifyOAuth,
+			OAuth:        cred,
+		},
+		Status: datamodel.MindAdvisorStatusActive,
+	}, now))
+}
+
+// revokeOAuth 尽力撤销绑定的授权，失败只记录日志
+func (s *LinkedEmailService) revokeOAuth(ctx context.Context, link *datamodel.MindAdvisorLinkedEmail) {
+	if link.Extra == nil || link.Extra.OAuth == nil {
+		return
+	}
+	p, ok := s.providers[link.Source]
+	if !ok {
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, revokeTimeout)
+	defer cancel()
+	if err := p.Revoke(ctx, link.Extra.OAuth); err != nil {
+		logger.WarnfCtx(ctx, "revoke oauth of linked email %d error: %v", link.ID, err)
+	}
+}
+
+func (s *LinkedEmailService) provider(source string) (OAuthProvider, error) {
+	p, ok := s.providers[source]
+	if !ok {
+		return nil, ErrOAuthProviderDisabled
+	}
+	return p, nil
+}
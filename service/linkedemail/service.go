@@ -0,0 +1,490 @@
+package linkedemail
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"net/mail"
+	"strings"
+	"time"
+
+	"plaud-emails/dao"
+	datamodel "plaud-emails/data/model"
+	appconfig "plaud-emails/pkg/config"
+	"plaud-emails/pkg/imap"
+	"plaud-emails/pkg/secretbox"
+	"plaud-emails/service/mailsync"
+	"plaud-emails/service/mimeparse"
+	"plaud-emails/service/mindadvisor"
+
+	"github.com/Plaud-AI/plaud-go-scaffold/pkg/logger"
+	"github.com/Plaud-AI/plaud-go-scaffold/pkg/rdb"
+	"github.com/Plaud-AI/plaud-go-scaffold/pkg/svc"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// MaxLinkedEmailsPerUser 每个用户最多绑定的外部邮箱数量
+	MaxLinkedEmailsPerUser = 5
+
+	// TokenPrefix 验证令牌前缀，便于在邮件正文中识别
+	TokenPrefix = "plaud-verify-"
+
+	// imapCheckTimeout 绑定 IMAP 邮箱时校验登录的超时时间
+	imapCheckTimeout = 20 * time.Second
+)
+
+// 错误定义
+var (
+	ErrInvalidEmail        = errors.New("invalid email address")
+	ErrDedicatedEmail      = errors.New("cannot link a myplaud address")
+	ErrMailboxNotCreated   = errors.New("dedicated mailbox not created")
+	ErrLinkedEmailExists   = errors.New("email already linked")
+	ErrTooManyLinkedEmails = errors.New("too many linked emails")
+	ErrLinkedEmailNotFound = errors.New("linked email not found")
+
+	ErrCredentialStoreDisabled = errors.New("credential storage not configured")
+	ErrInvalidIMAPAccount      = errors.New("invalid imap account")
+	ErrIMAPAuthFailed          = errors.New("imap authentication failed")
+	ErrIMAPUnreachable         = errors.New("imap server unreachable")
+	ErrIMAPInsecure            = errors.New("imap server requires tls or starttls")
+)
+
+// LinkedEmailService 外部邮箱绑定服务
+type LinkedEmailService struct {
+	svc.BaseService
+	userDao        *dao.MindAdvisorUserDao
+	linkedEmailDao *dao.MindAdvisorLinkedEmailDao
+	syncEventDao   *dao.LinkedEmailSyncEventDao
+	redisClient    *rdb.Client
+	conf           *appconfig.MailSyncConfig
+	box            *secretbox.Box
+	providers      map[string]OAuthProvider
+}
+
+// New 创建 LinkedEmailService，box 为 nil 时无法绑定需要保存凭据的邮箱
+func New(db *gorm.DB, redisClient *rdb.Client, conf *appconfig.MailSyncConfig, box *secretbox.Box) *LinkedEmailService {
+	return &LinkedEmailService{
+		userDao:        dao.NewMindAdvisorUserDao(db),
+		linkedEmailDao: dao.NewMindAdvisorLinkedEmailDao(db),
+		syncEventDao:   dao.NewLinkedEmailSyncEventDao(db),
+		redisClient:    redisClient,
+		conf:           conf,
+		box:            box,
+		providers:      make(map[string]OAuthProvider),
+	}
+}
+
+// CreateForwarding 登记一个通过自动转发接入的外部邮箱，并签发验证令牌
+// 已删除的同名绑定会被重新激活并重置验证状态
+func (s *LinkedEmailService) CreateForwarding(ctx context.Context, userID, email string) (*datamodel.MindAdvisorLinkedEmail, error) {
+	addr, err := normalizeEmail(email)
+	if err != nil {
+		return nil, err
+	}
+	token, err := newToken()
+	if err != nil {
+		return nil, err
+	}
+	return s.createLink(ctx, false, &datamodel.MindAdvisorLinkedEmail{
+		UserID: userID,
+		Email:  addr,
+		Source: datamodel.LinkedEmailSourceForwarding,
+		Extra:  &datamodel.LinkedEmailExtra{VerifyToken: token},
+		Status: datamodel.MindAdvisorStatusActive,
+	})
+}
+
+// IMAPInput 绑定 IMAP 邮箱的输入
+type IMAPInput struct {
+	Email    string
+	Host     string
+	Port     int
+	TLS      bool
+	Username string
+	Password string
+	Mailbox  string
+}
+
+// CreateIMAP 绑定一个 IMAP 邮箱，登录成功即视为已验证，密码加密后保存
+// 对已绑定的同一 IMAP 邮箱再次调用会更新凭据，用于凭据失效后重新授权
+func (s *LinkedEmailService) CreateIMAP(ctx context.Context, userID string, in *IMAPInput) (*datamodel.MindAdvisorLinkedEmail, error) {
+	if s.box == nil {
+		return nil, ErrCredentialStoreDisabled
+	}
+	addr, err := normalizeEmail(in.Email)
+	if err != nil {
+		return nil, err
+	}
+	if in.Host == "" || in.Port <= 0 || in.Port > 65535 || in.Password == "" {
+		return nil, ErrInvalidIMAPAccount
+	}
+	acct := &datamodel.IMAPAccount{
+		Host:     strings.ToLower(strings.TrimSpace(in.Host)),
+		Port:     in.Port,
+		TLS:      in.TLS,
+		Username: in.Username,
+		Mailbox:  in.Mailbox,
+	}
+	if acct.Username == "" {
+		acct.Username = addr
+	}
+	if acct.Mailbox == "" {
+		acct.Mailbox = mailsync.DefaultIMAPMailbox
+	}
+
+	if err := s.checkIMAPLogin(ctx, acct, in.Password); err != nil {
+		return nil, err
+	}
+
+	sealed, err := s.box.Seal([]byte(in.Password))
+	if err != nil {
+		return nil, err
+	}
+	acct.Password = sealed
+
+	now := time.Now()
+	return s.createLink(ctx, true, schedulePending(&datamodel.MindAdvisorLinkedEmail{
+		UserID:     userID,
+		Email:      addr,
+		Source:     datamodel.LinkedEmailSourceIMAP,
+		Verified:   true,
+		VerifiedAt: &now,
+		Extra: &datamodel.LinkedEmailExtra{
+			VerifyMethod: datamodel.LinkedEmailVerifyIMAPLogin,
+			IMAP:         acct,
+		},
+		Status: datamodel.MindAdvisorStatusActive,
+	}, now))
+}
+
+// schedulePending 新绑定或重新授权的邮箱进入 pending 状态，立即参与调度
+func schedulePending(link *datamodel.MindAdvisorLinkedEmail, now time.Time) *datamodel.MindAdvisorLinkedEmail {
+	status := datamodel.LinkedEmailSyncStatusPending
+	link.SyncStatus = &status
+	link.NextSyncAt = &now
+	link.SyncFailures = 0
+	return link
+}
+
+// checkIMAPLogin 登录并打开邮箱目录，确认账户可用
+func (s *LinkedEmailService) checkIMAPLogin(ctx context.Context, acct *datamodel.IMAPAccount, password string) error {
+	ctx, cancel := context.WithTimeout(ctx, imapCheckTimeout)
+	defer cancel()
+
+	client, err := mailsync.DialIMAP(ctx, acct, password, s.conf.MaxMessageBytes)
+	if err != nil {
+		logger.WarnfCtx(ctx, "check imap account %s@%s error: %v", acct.Username, acct.Host, err)
+		switch {
+		case errors.Is(err, mailsync.ErrAuthFailed):
+			return ErrIMAPAuthFailed
+		case errors.Is(err, imap.ErrStartTLSUnsupported):
+			return ErrIMAPInsecure
+		}
+		return ErrIMAPUnreachable
+	}
+	defer client.Close()
+	defer client.Logout()
+
+	if _, err := client.Examine(acct.Mailbox); err != nil {
+		logger.WarnfCtx(ctx, "examine imap mailbox %s error: %v", acct.Mailbox, err)
+		return ErrInvalidIMAPAccount
+	}
+	return nil
+}
+
+// createLink 在事务中创建绑定，已删除的同名绑定会被重新激活并覆盖
+// replace 为 true 时允许覆盖同来源的有效绑定，同一账户的同步游标会被保留
+func (s *LinkedEmailService) createLink(ctx context.Context, replace bool, link *datamodel.MindAdvisorLinkedEmail) (*datamodel.MindAdvisorLinkedEmail, error) {
+	user, err := s.userDao.GetByUserID(ctx, link.UserID)
+	if err != nil {
+		logger.ErrorfCtx(ctx, "get mailbox error: %v", err)
+		return nil, err
+	}
+	if user == nil || user.DedicatedEmail == "" {
+		return nil, ErrMailboxNotCreated
+	}
+
+	err = s.linkedEmailDao.ExecTx(ctx, func(tx *gorm.DB) error {
+		txDao := dao.NewMindAdvisorLinkedEmailDao(tx)
+
+		existing, err := txDao.GetByUserIDAndEmail(ctx, link.UserID, link.Email)
+		if err != nil {
+			return err
+		}
+		if existing != nil && existing.IsActive() {
+			if !replace || existing.Source != link.Source {
+				return ErrLinkedEmailExists
+			}
+		} else {
+			links, err := txDao.ListByUserID(ctx, link.UserID)
+			if err != nil {
+				return err
+			}
+			var count int
+			for _, l := range links {
+				if existing == nil || l.ID != existing.ID {
+					count++
+				}
+			}
+			if count >= MaxLinkedEmailsPerUser {
+				return ErrTooManyLinkedEmails
+			}
+		}
+
+		if existing != nil {
+			if existing.Source == link.Source {
+				keepCursor(existing, link)
+			}
+			link.ID = existing.ID
+			link.CreatedAt = existing.CreatedAt
+			if err := txDao.Update(ctx, link); err != nil {
+				return err
+			}
+			return recordLinked(ctx, tx, existing.CurrentSyncStatus(), link)
+		}
+
+		if err := txDao.Create(ctx, link); err != nil {
+			if strings.Contains(err.Error(), "Duplicate entry") {
+				return ErrLinkedEmailExists
+			}
+			return err
+		}
+		return recordLinked(ctx, tx, datamodel.LinkedEmailSyncStatusNone, link)
+	})
+	if err != nil {
+		logger.ErrorfCtx(ctx, "create linked email error: %v", err)
+		return nil, err
+	}
+	return link, nil
+}
+
+// recordLinked 为需要拉取同步的绑定记录进入 pending 的迁移
+func recordLinked(ctx context.Context, tx *gorm.DB, from datamodel.LinkedEmailSyncStatus, link *datamodel.MindAdvisorLinkedEmail) error {
+	if link.SyncStatus == nil {
+		return nil
+	}
+	return mailsync.RecordTransition(ctx, tx, link, from, *link.SyncStatus, datamodel.SyncReasonLinked, nil)
+}
+
+// keepCursor 重新授权同一账户时沿用原有的同步游标
+func keepCursor(existing, link *datamodel.MindAdvisorLinkedEmail) {
+	if existing.Extra == nil || link.Extra == nil {
+		return
+	}
+	if link.Extra.SyncCursor == "" {
+		link.Extra.SyncCursor = existing.Extra.SyncCursor
+		link.Extra.ResyncSince = existing.Extra.ResyncSince
+	}
+	if existing.Extra.IMAP == nil || link.Extra.IMAP == nil {
+		return
+	}
+	old, acct := existing.Extra.IMAP, link.Extra.IMAP
+	if old.Host == acct.Host && old.Username == acct.Username && old.Mailbox == acct.Mailbox {
+		acct.UIDValidity = old.UIDValidity
+		acct.LastUID = old.LastUID
+	}
+}
+
+// List 查询用户的绑定邮箱
+func (s *LinkedEmailService) List(ctx context.Context, userID string) ([]*datamodel.MindAdvisorLinkedEmail, error) {
+	links, err := s.linkedEmailDao.ListByUserID(ctx, userID)
+	if err != nil {
+		logger.ErrorfCtx(ctx, "list linked emails error: %v", err)
+		return nil, err
+	}
+	return links, nil
+}
+
+// Get 查询用户的一个绑定邮箱，不存在时返回 ErrLinkedEmailNotFound
+func (s *LinkedEmailService) Get(ctx context.Context, userID string, id uint64) (*datamodel.MindAdvisorLinkedEmail, error) {
+	link, err := s.linkedEmailDao.GetByUserIDAndID(ctx, userID, id)
+	if err != nil {
+		logger.ErrorfCtx(ctx, "get linked email error: %v", err)
+		return nil, err
+	}
+	if link == nil {
+		return nil, ErrLinkedEmailNotFound
+	}
+	return link, nil
+}
+
+// SyncHistory 按时间倒序分页查询用户绑定邮箱的同步状态迁移记录，beforeID 为 0 时从最新开始
+func (s *LinkedEmailService) SyncHistory(ctx context.Context, userID string, id, beforeID uint64, limit int) ([]*datamodel.LinkedEmailSyncEvent, error) {
+	if _, err := s.Get(ctx, userID, id); err != nil {
+		return nil, err
+	}
+	return s.listSyncEvents(ctx, id, beforeID, limit)
+}
+
+// SyncHistoryByID 查询任意绑定邮箱（含已解除绑定）的同步状态迁移记录，供内部排查使用
+func (s *LinkedEmailService) SyncHistoryByID(ctx context.Context, id, beforeID uint64, limit int) ([]*datamodel.LinkedEmailSyncEvent, error) {
+	link, err := s.linkedEmailDao.GetByID(ctx, id)
+	if err != nil {
+		logger.ErrorfCtx(ctx, "get linked email error: %v", err)
+		return nil, err
+	}
+	if link == nil {
+		return nil, ErrLinkedEmailNotFound
+	}
+	return s.listSyncEvents(ctx, id, beforeID, limit)
+}
+
+func (s *LinkedEmailService) listSyncEvents(ctx context.Context, id, beforeID uint64, limit int) ([]*datamodel.LinkedEmailSyncEvent, error) {
+	events, err := s.syncEventDao.ListByLinkedEmailID(ctx, id, beforeID, limit)
+	if err != nil {
+		logger.ErrorfCtx(ctx, "list linked email sync events error: %v", err)
+		return nil, err
+	}
+	return events, nil
+}
+
+// Delete 解除绑定（软删除），OAuth 授权的绑定会同时撤销授权
+// 参与同步的绑定同时停止调度并记录同步状态迁移，进行中的同步结果会因状态已变化而被丢弃
+func (s *LinkedEmailService) Delete(ctx context.Context, userID string, id uint64) error {
+	link, err := s.Get(ctx, userID, id)
+	if err != nil {
+		return err
+	}
+	s.revokeOAuth(ctx, link)
+
+	err = s.linkedEmailDao.ExecTx(ctx, func(tx *gorm.DB) error {
+		txDao := dao.NewMindAdvisorLinkedEmailDao(tx)
+		locked, err := txDao.GetByIDForUpdate(ctx, link.ID)
+		if err != nil {
+			return err
+		}
+		if locked == nil {
+			return ErrLinkedEmailNotFound
+		}
+		columns := map[string]any{"status": datamodel.MindAdvisorStatusSoftDeleted}
+		from := locked.CurrentSyncStatus()
+		record := from != datamodel.LinkedEmailSyncStatusNone && from != datamodel.LinkedEmailSyncStatusDisabled
+		if record {
+			columns["sync_status"] = datamodel.LinkedEmailSyncStatusDisabled
+			columns["next_sync_at"] = nil
+		}
+		if err := txDao.UpdateColumns(ctx, locked.ID, columns); err != nil {
+			return err
+		}
+		if !record {
+			return nil
+		}
+		return mailsync.RecordTransition(ctx, tx, locked, from, datamodel.LinkedEmailSyncStatusDisabled, datamodel.SyncReasonRemoved, nil)
+	})
+	if err != nil {
+		logger.ErrorfCtx(ctx, "delete linked email error: %v", err)
+		return err
+	}
+	return nil
+}
+
+// OnMessageStored 实现 message.StoredListener，检查新邮件是否完成了某个绑定的验证
+func (s *LinkedEmailService) OnMessageStored(ctx context.Context, msg *datamodel.Message, parsed *mimeparse.Message) {
+	if parsed == nil {
+		return
+	}
+
+	links, err := s.linkedEmailDao.ListByUserID(ctx, msg.UserID)
+	if err != nil {
+		logger.ErrorfCtx(ctx, "list linked emails for verification error: %v", err)
+		return
+	}
+
+	for _, link := range links {
+		if link.Verified || !link.IsActive() || link.Source != datamodel.LinkedEmailSourceForwarding {
+			continue
+		}
+		match := matchVerification(link, msg, parsed)
+		if match == nil {
+			continue
+		}
+		if err := s.markVerified(ctx, link, msg.ID, match); err != nil {
+			logger.ErrorfCtx(ctx, "verify linked email %d error: %v", link.ID, err)
+			continue
+		}
+		logger.InfofCtx(ctx, "linked email %d of user %s verified by message %d via %s",
+			link.ID, link.UserID, msg.ID, match.method)
+	}
+}
+
+// markVerified 标记绑定为已验证，验证令牌随之作废
+// 转发确认邮件的确认码与确认链接一并记录，供用户在客户端打开以启用服务商的转发
+func (s *LinkedEmailService) markVerified(ctx context.Context, link *datamodel.MindAdvisorLinkedEmail, messageID uint64, match *verification) error {
+	now := time.Now()
+	extra := link.Extra
+	if extra == nil {
+		extra = &datamodel.LinkedEmailExtra{}
+	}
+	extra.VerifyToken = ""
+	extra.VerifyMethod = match.method
+	extra.VerifiedMessageID = messageID
+	if match.confirmationCode != "" || match.confirmationURL != "" {
+		extra.ConfirmationCode = match.confirmationCode
+		extra.ConfirmationURL = match.confirmationURL
+	}
+
+	link.Verified = true
+	link.VerifiedAt = &now
+	link.Extra = extra
+	return s.linkedEmailDao.Update(ctx, link)
+}
+
+// normalizeEmail 校验并规范化外部邮箱地址
+func normalizeEmail(email string) (string, error) {
+	addr, err := mail.ParseAddress(strings.TrimSpace(email))
+	if err != nil || addr.Name != "" {
+		return "", ErrInvalidEmail
+	}
+	lower := strings.ToLower(addr.Address)
+	if strings.HasSuffix(lower, mindadvisor.EmailDomain) {
+		return "", ErrDedicatedEmail
+	}
+	at := strings.LastIndex(lower, "@")
+	if at <= 0 || !strings.Contains(lower[at+1:], ".") || len(lower) > 255 {
+		return "", ErrInvalidEmail
+	}
+	return lower, nil
+}
+
+var tokenEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// newToken 生成验证令牌，形如 plaud-verify-xxxxxxxxxxxxxxxx
+func newToken() (string, error) {
+	b := make([]byte, 10)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return TokenPrefix + strings.ToLower(tokenEncoding.EncodeToString(b)), nil
+}
+
+// Init 初始化服务
+func (s *LinkedEmailService) Init(ctx context.Context) error {
+	if s.IsInited() {
+		return nil
+	}
+	s.SetInited(true)
+	return nil
+}
+
+// Start 启动服务
+func (s *LinkedEmailService) Start(ctx context.Context) error {
+	if s.IsStarted() {
+		return nil
+	}
+	logger.Infof("start linked email service")
+	s.SetStarted(true)
+	return nil
+}
+
+// Stop 停止服务
+func (s *LinkedEmailService) Stop(ctx context.Context) error {
+	if s.IsStopped() {
+		return nil
+	}
+	defer s.SetStopped(true)
+	logger.Infof("stop linked email service")
+	return nil
+}
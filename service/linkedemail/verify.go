@@ -0,0 +1,104 @@
+package linkedemail
+
+import (
+	"net/mail"
+	"regexp"
+	"strings"
+
+	datamodel "plaud-emails/data/model"
+	"plaud-emails/service/mailauth"
+	"plaud-emails/service/mimeparse"
+)
+
+// forwardingConfirmation 邮件服务商在用户开启自动转发时发送到转发地址的确认邮件
+// 只有绑定邮箱的所有者能开启转发，因此认证通过的确认邮件可以证明用户拥有该邮箱
+type forwardingConfirmation struct {
+	method string
+	// sender 确认邮件的发件人，需通过 DMARC 或有对齐的 DKIM 签名
+	sender string
+	// requester 从主题中提取开启转发的邮箱地址；发件人显示名可由用户设置，不从正文匹配
+	requester *regexp.Regexp
+	// code 确认码，服务商不下发时为 nil
+	code *regexp.Regexp
+	url  *regexp.Regexp
+}
+
+var forwardingConfirmations = []*forwardingConfirmation{
+	{
+		// (#123456789) Gmail Forwarding Confirmation - Receive Mail from alice@gmail.com
+		method:    datamodel.LinkedEmailVerifyGmailForwarding,
+		sender:    "forwarding-noreply@google.com",
+		requester: regexp.MustCompile(`(?i)gmail forwarding confirmation - receive mail from (\S+@\S+)\s*$`),
+		code:      regexp.MustCompile(`(?i)\(#(\d{6,})\)|confirmation code:\s*(\d{6,})`),
+		url:       regexp.MustCompile(`https://mail(?:-settings)?\.google\.com/mail/[^\s"'<>]+`),
+	},
+	{
+		// Outlook Forwarding Confirmation - Receive Mail from alice@outlook.com
+		method:    datamodel.LinkedEmailVerifyOutlookForwarding,
+		sender:    "no-reply@microsoft.com",
+		requester: regexp.MustCompile(`(?i)outlook forwarding confirmation - receive mail from (\S+@\S+)\s*$`),
+		url:       regexp.MustCompile(`https://(?:outlook\.live\.com|outlook\.office\.com|account\.live\.com)/[^\s"'<>]+`),
+	},
+}
+
+// verification 一次验证匹配的结果，转发确认邮件同时记录服务商下发的确认码与确认链接
+type verification struct {
+	method           string
+	confirmationCode string
+	confirmationURL  string
+}
+
+// matchVerification 判断邮件是否能证明用户拥有绑定邮箱且已转发到专属邮箱
+// 接受两类邮件：From 为绑定邮箱、携带我们签发的令牌的邮件；Gmail 或 Outlook 为绑定邮箱发送的转发确认邮件
+// 发件人都需通过 DMARC 或有对齐的 DKIM 签名；转发相关的邮件头可被发信方伪造，不作为依据
+func matchVerification(link *datamodel.MindAdvisorLinkedEmail, msg *datamodel.Message, parsed *mimeparse.Message) *verification {
+	if link.Extra != nil && link.Extra.VerifyToken != "" && authenticatedFrom(msg, parsed, link.Email) {
+		token := link.Extra.VerifyToken
+		if strings.Contains(strings.ToLower(parsed.Subject), token) || strings.Contains(strings.ToLower(parsed.Text), token) {
+			return &verification{method: datamodel.LinkedEmailVerifyToken}
+		}
+	}
+
+	for _, c := range forwardingConfirmations {
+		if !authenticatedFrom(msg, parsed, c.sender) {
+			continue
+		}
+		m := c.requester.FindStringSubmatch(parsed.Subject)
+		if m == nil || !sameAddress(m[1], link.Email) {
+			continue
+		}
+		v := &verification{method: c.method, confirmationURL: c.url.FindString(parsed.Text)}
+		if c.code != nil {
+			if m := c.code.FindStringSubmatch(parsed.Subject + "\n" + parsed.Text); m != nil {
+				v.confirmationCode = m[1] + m[2]
+			}
+		}
+		return v
+	}
+	return nil
+}
+
+// authenticatedFrom 判断邮件的唯一发件人是否为指定地址，且收信时发件域名通过了 DMARC 或有对齐的 DKIM 签名
+// 没有认证结果的邮件（如外部邮箱同步的邮件）不满足条件
+func authenticatedFrom(msg *datamodel.Message, parsed *mimeparse.Message, address string) bool {
+	if len(parsed.From) != 1 || !strings.EqualFold(parsed.From[0].Address, address) {
+		return false
+	}
+	at := strings.LastIndex(address, "@")
+	if at < 0 {
+		return false
+	}
+	domain := strings.ToLower(address[at+1:])
+	if msg.AuthDMARC == datamodel.AuthResultPass && strings.EqualFold(msg.AuthFromDomain, domain) {
+		return true
+	}
+	return msg.AuthDKIM == datamodel.AuthResultPass && msg.AuthDKIMDomain != "" &&
+		mailauth.OrganizationalDomain(msg.AuthDKIMDomain) == mailauth.OrganizationalDomain(domain)
+}
+
+// sameAddress 判断文本是否为指定的邮箱地址，忽略大小写与两侧的尖括号、标点
+func sameAddress(text, address string) bool {
+	text = strings.Trim(text, "<>()[].,;:\"'")
+	addr, err := mail.ParseAddress(text)
+	return err == nil && strings.EqualFold(addr.Address, address)
+}
@@ -0,0 +1,115 @@
+package linkedemail
+
+import (
+	"testing"
+
+	datamodel "plaud-emails/data/model"
+	"plaud-emails/service/mimeparse"
+)
+
+const (
+	gmailConfirmation = "From: Gmail Team <forwarding-noreply@google.com>\r\n" +
+		"To: alice@myplaud.com\r\n" +
+		"Subject: (#123456789) Gmail Forwarding Confirmation - Receive Mail from alice@gmail.com\r\n" +
+		"\r\n" +
+		"alice@gmail.com has requested to automatically forward mail to your email address alice@myplaud.com.\r\n" +
+		"Confirmation code: 123456789\r\n" +
+		"To allow alice@gmail.com to automatically forward mail to your address, please click the link below to confirm the request:\r\n" +
+		"https://mail-settings.google.com/mail/vf-abc123\r\n"
+
+	outlookConfirmation = "From: Outlook <no-reply@microsoft.com>\r\n" +
+		"To: alice@myplaud.com\r\n" +
+		"Subject: Outlook Forwarding Confirmation - Receive Mail from Alice@Outlook.com\r\n" +
+		"\r\n" +
+		"Alice@Outlook.com wants to forward mail to alice@myplaud.com.\r\n" +
+		"Confirm: https://outlook.live.com/mail/forwarding/confirm?id=xyz\r\n"
+)
+
+// passDMARC 收信时 From 域名通过 DMARC 的认证结果
+func passDMARC(domain string) *datamodel.Message {
+	return &datamodel.Message{ID: 1, AuthDMARC: datamodel.AuthResultPass, AuthFromDomain: domain}
+}
+
+func parse(t *testing.T, raw string) *mimeparse.Message {
+	t.Helper()
+	parsed, err := mimeparse.Parse([]byte(raw))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	return parsed
+}
+
+func forwardingLink(email string) *datamodel.MindAdvisorLinkedEmail {
+	return &datamodel.MindAdvisorLinkedEmail{
+		Email:  email,
+		Source: datamodel.LinkedEmailSourceForwarding,
+		Extra:  &datamodel.LinkedEmailExtra{VerifyToken: "tok-4f2a9c"},
+	}
+}
+
+func TestMatchGmailConfirmation(t *testing.T) {
+	parsed := parse(t, gmailConfirmation)
+	v := matchVerification(forwardingLink("alice@gmail.com"), passDMARC("google.com"), parsed)
+	if v == nil || v.method != datamodel.LinkedEmailVerifyGmailForwarding {
+		t.Fatalf("verification = %+v, want gmail_forwarding", v)
+	}
+	if v.confirmationCode != "123456789" || v.confirmationURL != "https://mail-settings.google.com/mail/vf-abc123" {
+		t.Fatalf("confirmation = %q %q", v.confirmationCode, v.confirmationURL)
+	}
+
+	// 对齐的 DKIM 签名同样可以认证发件人
+	dkim := &datamodel.Message{AuthDKIM: datamodel.AuthResultPass, AuthDKIMDomain: "mail.google.com"}
+	if matchVerification(forwardingLink("alice@gmail.com"), dkim, parsed) == nil {
+		t.Fatal("aligned dkim pass should authenticate the confirmation")
+	}
+}
+
+func TestMatchOutlookConfirmation(t *testing.T) {
+	parsed := parse(t, outlookConfirmation)
+	v := matchVerification(forwardingLink("alice@outlook.com"), passDMARC("microsoft.com"), parsed)
+	if v == nil || v.method != datamodel.LinkedEmailVerifyOutlookForwarding {
+		t.Fatalf("verification = %+v, want outlook_forwarding", v)
+	}
+	if v.confirmationCode != "" || v.confirmationURL != "https://outlook.live.com/mail/forwarding/confirm?id=xyz" {
+		t.Fatalf("confirmation = %q %q", v.confirmationCode, v.confirmationURL)
+	}
+}
+
+func TestMatchConfirmationRejected(t *testing.T) {
+	cases := []struct {
+		name string
+		link string
+		msg  *datamodel.Message
+		raw  string
+	}{
+		{"unauthenticated sender", "alice@gmail.com", &datamodel.Message{}, gmailConfirmation},
+		{"dmarc for another domain", "alice@gmail.com", passDMARC("evil.example"), gmailConfirmation},
+		{"other requester", "bob@gmail.com", passDMARC("google.com"), gmailConfirmation},
+		{"outlook pattern from google", "alice@outlook.com", passDMARC("google.com"),
+			"From: forwarding-noreply@google.com\r\nSubject: Outlook Forwarding Confirmation - Receive Mail from alice@outlook.com\r\n\r\nx\r\n"},
+		// 请求方显示名可由用户设置，正文中出现的地址不作为依据
+		{"address only in body", "victim@gmail.com", passDMARC("google.com"),
+			"From: forwarding-noreply@google.com\r\nSubject: (#1234567) Gmail Forwarding Confirmation - Receive Mail from mallory@gmail.com\r\n\r\n" +
+				"victim@gmail.com (mallory@gmail.com) has requested to automatically forward mail\r\n"},
+		{"spoofed forwarding headers", "alice@gmail.com", &datamodel.Message{},
+			"From: alice@gmail.com\r\nX-Forwarded-For: alice@gmail.com alice@myplaud.com\r\nSubject: hi\r\n\r\nhello\r\n"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if v := matchVerification(forwardingLink(tc.link), tc.msg, parse(t, tc.raw)); v != nil {
+				t.Fatalf("verification = %+v, want nil", v)
+			}
+		})
+	}
+}
+
+func TestMatchToken(t *testing.T) {
+	raw := "From: Alice <alice@example.com>\r\nSubject: Verify TOK-4F2A9C\r\n\r\nhello\r\n"
+	v := matchVerification(forwardingLink("alice@example.com"), passDMARC("example.com"), parse(t, raw))
+	if v == nil || v.method != datamodel.LinkedEmailVerifyToken {
+		t.Fatalf("verification = %+v, want token", v)
+	}
+	if v := matchVerification(forwardingLink("alice@example.com"), &datamodel.Message{}, parse(t, raw)); v != nil {
+		t.Fatal("token from an unauthenticated sender must not verify")
+	}
+}
@@ -0,0 +1,427 @@
+package mailauth
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// dkimMaxSignatures 每封邮件最多验证的签名数
+	dkimMaxSignatures = 5
+	// dkimMinRSABits RSA 公钥的最小长度（RFC 8301）
+	dkimMinRSABits = 1024
+)
+
+// DKIMResult 一个 DKIM-Signature 的验证结果
+type DKIMResult struct {
+	Result Result
+	// Domain 签名域名（d=）
+	Domain   string
+	Selector string
+	// Identifier 签名身份（i=），未指定时为 @Domain
+	Identifier string
+	Algorithm  string
+	Reason     string
+}
+
+// headerField 保留原始字节的头部字段，Raw 含折行与末尾的 CRLF
+type headerField struct {
+	Name string
+	Raw  []byte
+}
+
+// VerifyDKIM 验证邮件中的全部 DKIM 签名（RFC 6376），没有签名时返回空切片
+// 不接受 rsa-sha1 与短于 1024 位的 RSA 公钥（RFC 8301），支持 ed25519-sha256（RFC 8463）
+func VerifyDKIM(ctx context.Context, r Resolver, raw []byte) []*DKIMResult {
+	header, body := splitMessage(raw)
+	fields := parseHeaderFields(header)
+
+	var results []*DKIMResult
+	for i, f := range fields {
+		if !strings.EqualFold(f.Name, "DKIM-Signature") {
+			continue
+		}
+		if len(results) == dkimMaxSignatures {
+			break
+		}
+		results = append(results, verifySignature(ctx, r, fields, i, body))
+	}
+	return results
+}
+
+// verifySignature 验证 fields[index] 中的签名
+func verifySignature(ctx context.Context, r Resolver, fields []*headerField, index int, body []byte) *DKIMResult {
+	sigField := fields[index]
+	res := &DKIMResult{}
+	fail := func(result Result, format string, args ...any) *DKIMResult {
+		res.Result, res.Reason = result, fmt.Sprintf(format, args...)
+		return res
+	}
+
+	tags, err := parseTags(fieldValue(sigField.Raw))
+	if err != nil {
+		return fail(ResultPermError, "malformed signature: %v", err)
+	}
+	res.Domain = normalizeDomain(tags["d"])
+	res.Selector = tags["s"]
+	res.Algorithm = strings.ToLower(tags["a"])
+	res.Identifier = tags["i"]
+	if res.Identifier == "" {
+		res.Identifier = "@" + res.Domain
+	}
+
+	if tags["v"] != "1" {
+		return fail(ResultPermError, "unsupported signature version %q", tags["v"])
+	}
+	for _, name := range []string{"a", "b", "bh", "d", "h", "s"} {
+		if tags[name] == "" {
+			return fail(ResultPermError, "missing required tag %s", name)
+		}
+	}
+	keyType, hashName, ok := strings.Cut(res.Algorithm, "-")
+	if !ok || (keyType != "rsa" && keyType != "ed25519") {
+		return fail(ResultPermError, "unsupported algorithm %s", res.Algorithm)
+	}
+	if hashName != "sha256" {
+		return fail(ResultPermError, "algorithm %s is not accepted", res.Algorithm)
+	}
+	_, identDomain, _ := strings.Cut(res.Identifier, "@")
+	identDomain = normalizeDomain(identDomain)
+	if identDomain != res.Domain && !strings.HasSuffix(identDomain, "."+res.Domain) {
+		return fail(ResultPermError, "identifier %s is not within domain %s", res.Identifier, res.Domain)
+	}
+
+	signed := splitList(tags["h"], ":")
+	hasFrom := false
+	for _, name := range signed {
+		if strings.EqualFold(name, "From") {
+			hasFrom = true
+		}
+	}
+	if !hasFrom {
+		return fail(ResultPermError, "from header is not signed")
+	}
+	if x := tags["x"]; x != "" {
+		expire, err := strconv.ParseInt(x, 10, 64)
+		if err != nil {
+			return fail(ResultPermError, "invalid expiration %q", x)
+		}
+		if time.Now().Unix() > expire {
+			return fail(ResultPermError, "signature expired")
+		}
+	}
+
+	headerCanon, bodyCanon, err := parseCanonicalization(tags["c"])
+	if err != nil {
+		return fail(ResultPermError, "%v", err)
+	}
+	sig, err := base64.StdEncoding.DecodeString(tags["b"])
+	if err != nil {
+		return fail(ResultPermError, "invalid signature encoding")
+	}
+	bodyHash, err := base64.StdEncoding.DecodeString(tags["bh"])
+	if err != nil {
+		return fail(ResultPermError, "invalid body hash encoding")
+	}
+
+	// 正文哈希
+	canonBody := canonicalizeBody(body, bodyCanon)
+	if l := tags["l"]; l != "" {
+		n, err := strconv.ParseInt(l, 10, 64)
+		if err != nil || n < 0 || n > int64(len(canonBody)) {
+			return fail(ResultPermError, "invalid body length %q", l)
+		}
+		canonBody = canonBody[:n]
+	}
+	sum := sha256.Sum256(canonBody)
+	if !bytes.Equal(sum[:], bodyHash) {
+		return fail(ResultFail, "body hash did not verify")
+	}
+
+	// 头部哈希：h= 中的字段自下而上取未使用的实例，最后是去掉 b= 值的签名字段本身
+	h := sha256.New()
+	used := make(map[int]bool)
+	for _, name := range signed {
+		for i := len(fields) - 1; i >= 0; i-- {
+			if used[i] || !strings.EqualFold(fields[i].Name, name) {
+				continue
+			}
+			used[i] = true
+			h.Write(canonicalizeHeader(fields[i], headerCanon))
+			break
+		}
+	}
+	stripped := &headerField{Name: sigField.Name, Raw: stripSignature(sigField.Raw)}
+	h.Write(bytes.TrimSuffix(canonicalizeHeader(stripped, headerCanon), []byte("\r\n")))
+	digest := h.Sum(nil)
+
+	key, result, reason := lookupKey(ctx, r, res.Selector, res.Domain, keyType)
+	if key == nil {
+		return fail(result, "%s", reason)
+	}
+	if key.strict && identDomain != res.Domain {
+		return fail(ResultPermError, "key requires identifier domain to equal %s", res.Domain)
+	}
+
+	switch pub := key.pub.(type) {
+	case *rsa.PublicKey:
+		if err := rsa.VerifyPKCS1v15(pub, crypto.SHA256, digest, sig); err != nil {
+			return fail(ResultFail, "signature did not verify")
+		}
+	case ed25519.PublicKey:
+		if !ed25519.Verify(pub, digest, sig) {
+			return fail(ResultFail, "signature did not verify")
+		}
+	}
+	res.Result = ResultPass
+	return res
+}
+
+// dkimKey DNS 中发布的公钥
+type dkimKey struct {
+	pub    crypto.PublicKey
+	strict bool
+}
+
+// lookupKey 查询 selector._domainkey.domain 的公钥，失败时返回 nil 与对应的结果
+func lookupKey(ctx context.Context, r Resolver, selector, domain, keyType string) (*dkimKey, Result, string) {
+	name := selector + "._domainkey." + domain
+	txts, err := r.LookupTXT(ctx, name)
+	if err != nil {
+		if isNotFound(err) {
+			return nil, ResultPermError, "no key for signature at " + name
+		}
+		return nil, ResultTempError, fmt.Sprintf("lookup key %s: %v", name, err)
+	}
+	if len(txts) == 0 {
+		return nil, ResultPermError, "no key for signature at " + name
+	}
+
+	tags, err := parseTags(txts[0])
+	if err != nil {
+		return nil, ResultPermError, fmt.Sprintf("malformed key record: %v", err)
+	}
+	if v := tags["v"]; v != "" && v != "DKIM1" {
+		return nil, ResultPermError, "unsupported key version " + v
+	}
+	if k := strings.ToLower(tags["k"]); (k == "" && keyType != "rsa") || (k != "" && k != keyType) {
+		return nil, ResultPermError, "key type does not match signature algorithm"
+	}
+	if hs := tags["h"]; hs != "" && !containsFold(splitList(hs, ":"), "sha256") {
+		return nil, ResultPermError, "key does not allow sha256"
+	}
+	if s := tags["s"]; s != "" && !containsFold(splitList(s, ":"), "*") && !containsFold(splitList(s, ":"), "email") {
+		return nil, ResultPermError, "key is not for email"
+	}
+	if tags["p"] == "" {
+		return nil, ResultPermError, "key revoked"
+	}
+	der, err := base64.StdEncoding.DecodeString(tags["p"])
+	if err != nil {
+		return nil, ResultPermError, "invalid key encoding"
+	}
+
+	key := &dkimKey{strict: containsFold(splitList(tags["t"], ":"), "s")}
+	if keyType == "ed25519" {
+		if len(der) != ed25519.PublicKeySize {
+			return nil, ResultPermError, "invalid ed25519 key"
+		}
+		key.pub = ed25519.PublicKey(der)
+		return key, ResultPass, ""
+	}
+
+	var rsaKey *rsa.PublicKey
+	if pub, err := x509.ParsePKIXPublicKey(der); err == nil {
+		rsaKey, _ = pub.(*rsa.PublicKey)
+	} else if pub, err := x509.ParsePKCS1PublicKey(der); err == nil {
+		rsaKey = pub
+	}
+	if rsaKey == nil {
+		return nil, ResultPermError, "invalid rsa key"
+	}
+	if rsaKey.N.BitLen() < dkimMinRSABits {
+		return nil, ResultPermError, "rsa key too short"
+	}
+	key.pub = rsaKey
+	return key, ResultPass, ""
+}
+
+// splitMessage 拆分头部与正文，只有 LF 换行的邮件先统一转换为 CRLF
+func splitMessage(raw []byte) ([]byte, []byte) {
+	if bytes.Contains(raw, []byte("\n")) && !bytes.Contains(raw, []byte("\r\n")) {
+		raw = bytes.ReplaceAll(raw, []byte("\n"), []byte("\r\n"))
+	}
+	if bytes.HasPrefix(raw, []byte("\r\n")) {
+		return nil, raw[2:]
+	}
+	if i := bytes.Index(raw, []byte("\r\n\r\n")); i >= 0 {
+		return raw[:i+2], raw[i+4:]
+	}
+	return raw, nil
+}
+
+// parseHeaderFields 按原始字节拆分头部字段，续行归入上一个字段
+func parseHeaderFields(header []byte) []*headerField {
+	var fields []*headerField
+	for len(header) > 0 {
+		end := bytes.Index(header, []byte("\r\n"))
+		line := header
+		if end >= 0 {
+			line = header[:end+2]
+		}
+		header = header[len(line):]
+		if (line[0] == ' ' || line[0] == '\t') && len(fields) > 0 {
+			last := fields[len(fields)-1]
+			last.Raw = append(last.Raw, line...)
+			continue
+		}
+		name, _, ok := bytes.Cut(line, []byte(":"))
+		if !ok {
+			continue
+		}
+		fields = append(fields, &headerField{Name: string(bytes.TrimRight(name, " \t")), Raw: append([]byte{}, line...)})
+	}
+	return fields
+}
+
+// fieldValue 返回字段冒号之后的值
+func fieldValue(raw []byte) string {
+	_, value, _ := bytes.Cut(raw, []byte(":"))
+	return string(value)
+}
+
+// parseTags 解析 tag=value 列表，值中的空白被移除
+func parseTags(s string) (map[string]string, error) {
+	tags := make(map[string]string)
+	for _, part := range strings.Split(s, ";") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(part, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid tag %q", part)
+		}
+		name = strings.TrimSpace(name)
+		if _, dup := tags[name]; dup {
+			return nil, fmt.Errorf("duplicate tag %s", name)
+		}
+		tags[name] = strings.Join(strings.Fields(value), "")
+	}
+	return tags, nil
+}
+
+// stripSignature 清空签名字段中 b= 的值，保留其余字节
+func stripSignature(raw []byte) []byte {
+	colon := bytes.IndexByte(raw, ':')
+	out := append([]byte{}, raw[:colon+1]...)
+	value := raw[colon+1:]
+	for len(value) > 0 {
+		end := bytes.IndexByte(value, ';')
+		part := value
+		if end >= 0 {
+			part = value[:end+1]
+		}
+		value = value[len(part):]
+		name, _, ok := bytes.Cut(part, []byte("="))
+		if ok && string(bytes.TrimSpace(name)) == "b" {
+			eq := bytes.IndexByte(part, '=')
+			out = append(out, part[:eq+1]...)
+			if part[len(part)-1] == ';' {
+				out = append(out, ';')
+			} else if bytes.HasSuffix(part, []byte("\r\n")) {
+				out = append(out, "\r\n"...)
+			}
+			continue
+		}
+		out = append(out, part...)
+	}
+	return out
+}
+
+// parseCanonicalization 解析 c= 标签，缺省为 simple/simple
+func parseCanonicalization(c string) (string, string, error) {
+	if c == "" {
+		return "simple", "simple", nil
+	}
+	header, body, ok := strings.Cut(strings.ToLower(c), "/")
+	if !ok {
+		body = "simple"
+	}
+	for _, v := range []string{header, body} {
+		if v != "simple" && v != "relaxed" {
+			return "", "", fmt.Errorf("unsupported canonicalization %q", c)
+		}
+	}
+	return header, body, nil
+}
+
+// canonicalizeHeader 按 simple 或 relaxed 规范化一个头部字段（RFC 6376 3.4.1、3.4.2）
+func canonicalizeHeader(f *headerField, canon string) []byte {
+	if canon == "simple" {
+		return f.Raw
+	}
+	value := fieldValue(f.Raw)
+	value = strings.NewReplacer("\r\n", "").Replace(value)
+	value = strings.Join(strings.FieldsFunc(value, func(r rune) bool { return r == ' ' || r == '\t' }), " ")
+	return []byte(strings.ToLower(strings.TrimSpace(f.Name)) + ":" + value + "\r\n")
+}
+
+// canonicalizeBody 按 simple 或 relaxed 规范化正文（RFC 6376 3.4.3、3.4.4）
+func canonicalizeBody(body []byte, canon string) []byte {
+	if canon == "relaxed" {
+		lines := bytes.Split(body, []byte("\r\n"))
+		var b bytes.Buffer
+		for i, line := range lines {
+			fields := bytes.FieldsFunc(line, func(r rune) bool { return r == ' ' || r == '\t' })
+			if len(fields) > 0 && (line[0] == ' ' || line[0] == '\t') {
+				b.WriteByte(' ')
+			}
+			b.Write(bytes.Join(fields, []byte(" ")))
+			if i < len(lines)-1 {
+				b.WriteString("\r\n")
+			}
+		}
+		body = b.Bytes()
+	}
+
+	for bytes.HasSuffix(body, []byte("\r\n")) {
+		body = body[:len(body)-2]
+	}
+	if len(body) == 0 {
+		if canon == "relaxed" {
+			return nil
+		}
+		return []byte("\r\n")
+	}
+	return append(append([]byte{}, body...), "\r\n"...)
+}
+
+func splitList(s, sep string) []string {
+	var out []string
+	for _, v := range strings.Split(s, sep) {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+func containsFold(list []string, v string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, v) {
+			return true
+		}
+	}
+	return false
+}
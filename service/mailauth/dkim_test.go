@@ -0,0 +1,234 @@
+package mailauth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+// testMessage 头部不折行、值内只有单个空格，simple 与 relaxed 规范化结果只差在大小写与冒号后的空格
+const testMessage = "From: Alice <alice@example.com>\r\n" +
+	"To: bob@myplaud.com\r\n" +
+	"Subject: Quarterly report\r\n" +
+	"\r\n" +
+	"Numbers attached.\r\n" +
+	"\r\n"
+
+// dkimSigner 测试用的签名方，规范化按 RFC 6376 3.4 独立实现，只覆盖 testMessage 这类简单邮件
+type dkimSigner struct {
+	domain, selector string
+	algorithm        string // rsa-sha256 或 ed25519-sha256
+	canon            string // simple 或 relaxed，头部与正文相同
+	headers          []string
+	key              crypto.Signer
+	extraTags        string
+}
+
+func newRSASigner(t *testing.T) (*dkimSigner, string) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &dkimSigner{domain: "example.com", selector: "s1", algorithm: "rsa-sha256", canon: "relaxed", headers: []string{"From", "To", "Subject"}, key: key}
+	return s, "v=DKIM1; k=rsa; p=" + base64.StdEncoding.EncodeToString(der)
+}
+
+func newEd25519Signer(t *testing.T) (*dkimSigner, string) {
+	t.Helper()
+	pub, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &dkimSigner{domain: "example.com", selector: "ed", algorithm: "ed25519-sha256", canon: "simple", headers: []string{"From", "Subject"}, key: key}
+	return s, "v=DKIM1; k=ed25519; p=" + base64.StdEncoding.EncodeToString(pub)
+}
+
+func (s *dkimSigner) canonHeader(name, value string) string {
+	if s.canon == "simple" {
+		return name + ": " + value + "\r\n"
+	}
+	return strings.ToLower(name) + ":" + strings.Join(strings.Fields(value), " ") + "\r\n"
+}
+
+// sign 返回加上 DKIM-Signature 头的邮件
+func (s *dkimSigner) sign(t *testing.T, raw string) string {
+	t.Helper()
+	header, body, _ := strings.Cut(raw, "\r\n\r\n")
+	body = strings.TrimRight(body, "\r\n") + "\r\n"
+	bh := sha256.Sum256([]byte(body))
+
+	values := make(map[string]string)
+	for _, line := range strings.Split(header, "\r\n") {
+		name, value, _ := strings.Cut(line, ": ")
+		values[strings.ToLower(name)] = value
+	}
+
+	sigValue := "v=1; a=" + s.algorithm + "; c=" + s.canon + "/" + s.canon + "; d=" + s.domain + "; s=" + s.selector +
+		"; h=" + strings.Join(s.headers, ":") + s.extraTags + "; bh=" + base64.StdEncoding.EncodeToString(bh[:]) + "; b="
+	var signed strings.Builder
+	for _, name := range s.headers {
+		signed.WriteString(s.canonHeader(name, values[strings.ToLower(name)]))
+	}
+	signed.WriteString(strings.TrimSuffix(s.canonHeader("DKIM-Signature", sigValue), "\r\n"))
+
+	// ed25519-sha256 对头部哈希再做 PureEdDSA 签名（RFC 8463）
+	digest := sha256.Sum256([]byte(signed.String()))
+	opts := crypto.Hash(crypto.SHA256)
+	if s.algorithm == "ed25519-sha256" {
+		opts = crypto.Hash(0)
+	}
+	sig, err := s.key.Sign(rand.Reader, digest[:], opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return "DKIM-Signature: " + sigValue + base64.StdEncoding.EncodeToString(sig) + "\r\n" + raw
+}
+
+func verifyOne(t *testing.T, r Resolver, raw string) *DKIMResult {
+	t.Helper()
+	results := VerifyDKIM(context.Background(), r, []byte(raw))
+	if len(results) != 1 {
+		t.Fatalf("got %d results, want 1", len(results))
+	}
+	return results[0]
+}
+
+func keyResolver(s *dkimSigner, record string) *StaticResolver {
+	return &StaticResolver{TXT: map[string][]string{s.selector + "._domainkey." + s.domain: {record}}}
+}
+
+func TestVerifyDKIMPass(t *testing.T) {
+	rsaSigner, rsaKey := newRSASigner(t)
+	edSigner, edKey := newEd25519Signer(t)
+	for _, tc := range []struct {
+		signer *dkimSigner
+		record string
+	}{{rsaSigner, rsaKey}, {edSigner, edKey}} {
+		t.Run(tc.signer.algorithm, func(t *testing.T) {
+			res := verifyOne(t, keyResolver(tc.signer, tc.record), tc.signer.sign(t, testMessage))
+			if res.Result != ResultPass {
+				t.Fatalf("result = %s (%s), want pass", res.Result, res.Reason)
+			}
+			if res.Domain != "example.com" || res.Selector != tc.signer.selector || res.Algorithm != tc.signer.algorithm || res.Identifier != "@example.com" {
+				t.Fatalf("unexpected result %+v", res)
+			}
+		})
+	}
+	if got := VerifyDKIM(context.Background(), &StaticResolver{}, []byte(testMessage)); len(got) != 0 {
+		t.Fatalf("unsigned message should have no results, got %d", len(got))
+	}
+}
+
+func TestVerifyDKIMTampered(t *testing.T) {
+	signer, record := newRSASigner(t)
+	r := keyResolver(signer, record)
+	signed := signer.sign(t, testMessage)
+
+	// relaxed 规范化容忍空白变化与正文末尾的空行
+	relaxed := strings.Replace(signed, "Subject: Quarterly report", "Subject:   Quarterly  report", 1) + "\r\n\r\n"
+	if res := verifyOne(t, r, relaxed); res.Result != ResultPass {
+		t.Fatalf("whitespace change = %s (%s), want pass", res.Result, res.Reason)
+	}
+	// 未签名的头部可以增加
+	if res := verifyOne(t, r, "Received: from relay\r\n"+signed); res.Result != ResultPass {
+		t.Fatalf("added unsigned header = %s (%s), want pass", res.Result, res.Reason)
+	}
+
+	body := strings.Replace(signed, "Numbers attached.", "Numbers changed.", 1)
+	if res := verifyOne(t, r, body); res.Result != ResultFail || !strings.Contains(res.Reason, "body hash") {
+		t.Fatalf("modified body = %s (%s), want fail", res.Result, res.Reason)
+	}
+	header := strings.Replace(signed, "Subject: Quarterly report", "Subject: Quarterly invoice", 1)
+	if res := verifyOne(t, r, header); res.Result != ResultFail || !strings.Contains(res.Reason, "signature") {
+		t.Fatalf("modified header = %s (%s), want fail", res.Result, res.Reason)
+	}
+	// 已签名字段自下而上取实例：追加在头部末尾的同名字段会被验证，加在顶部的不会
+	appended := strings.Replace(signed, "Subject: Quarterly report\r\n", "Subject: Quarterly report\r\nTo: mallory@example.net\r\n", 1)
+	if res := verifyOne(t, r, appended); res.Result != ResultFail {
+		t.Fatalf("header appended at bottom = %s (%s), want fail", res.Result, res.Reason)
+	}
+	if res := verifyOne(t, r, "To: mallory@example.net\r\n"+signed); res.Result != ResultPass {
+		t.Fatalf("header prepended at top = %s (%s), want pass", res.Result, res.Reason)
+	}
+
+	simple, simpleKey := newEd25519Signer(t)
+	simpleSigned := simple.sign(t, testMessage)
+	if res := verifyOne(t, keyResolver(simple, simpleKey), strings.Replace(simpleSigned, "Subject: Quarterly report", "Subject:  Quarterly report", 1)); res.Result != ResultFail {
+		t.Fatalf("simple canonicalization should reject whitespace changes, got %s", res.Result)
+	}
+}
+
+func TestVerifyDKIMKeyErrors(t *testing.T) {
+	signer, record := newRSASigner(t)
+	signed := signer.sign(t, testMessage)
+	name := "s1._domainkey.example.com"
+	cases := []struct {
+		name   string
+		txt    []string
+		want   Result
+		reason string
+	}{
+		{"missing key", nil, ResultPermError, "no key"},
+		{"revoked key", []string{"v=DKIM1; k=rsa; p="}, ResultPermError, "revoked"},
+		{"wrong key type", []string{strings.Replace(record, "k=rsa", "k=ed25519", 1)}, ResultPermError, "key type"},
+		{"sha1 only", []string{record + "; h=sha1"}, ResultPermError, "sha256"},
+		{"not for email", []string{record + "; s=tlsrpt"}, ResultPermError, "not for email"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := &StaticResolver{TXT: map[string][]string{}}
+			if tc.txt != nil {
+				r.TXT[name] = tc.txt
+			}
+			res := verifyOne(t, r, signed)
+			if res.Result != tc.want || !strings.Contains(res.Reason, tc.reason) {
+				t.Fatalf("result = %s (%s), want %s containing %q", res.Result, res.Reason, tc.want, tc.reason)
+			}
+		})
+	}
+
+	flaky := &failingResolver{StaticResolver: &StaticResolver{}, fail: map[string]bool{name: true}}
+	if res := verifyOne(t, flaky, signed); res.Result != ResultTempError {
+		t.Fatalf("dns failure = %s, want temperror", res.Result)
+	}
+}
+
+func TestVerifyDKIMSignatureErrors(t *testing.T) {
+	signer, record := newRSASigner(t)
+	r := keyResolver(signer, record)
+
+	noFrom := *signer
+	noFrom.headers = []string{"To", "Subject"}
+	if res := verifyOne(t, r, noFrom.sign(t, testMessage)); res.Result != ResultPermError || !strings.Contains(res.Reason, "from header") {
+		t.Fatalf("unsigned from = %s (%s), want permerror", res.Result, res.Reason)
+	}
+
+	expired := *signer
+	expired.extraTags = "; x=1000000000"
+	if res := verifyOne(t, r, expired.sign(t, testMessage)); res.Result != ResultPermError || !strings.Contains(res.Reason, "expired") {
+		t.Fatalf("expired = %s (%s), want permerror", res.Result, res.Reason)
+	}
+
+	foreign := *signer
+	foreign.extraTags = "; i=alice@example.net"
+	if res := verifyOne(t, r, foreign.sign(t, testMessage)); res.Result != ResultPermError || !strings.Contains(res.Reason, "identifier") {
+		t.Fatalf("foreign identifier = %s (%s), want permerror", res.Result, res.Reason)
+	}
+
+	sha1 := strings.Replace(signer.sign(t, testMessage), "a=rsa-sha256", "a=rsa-sha1", 1)
+	if res := verifyOne(t, r, sha1); res.Result != ResultPermError || !strings.Contains(res.Reason, "not accepted") {
+		t.Fatalf("rsa-sha1 = %s (%s), want permerror", res.Result, res.Reason)
+	}
+}
@@ -0,0 +1,145 @@
+package mailauth
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// DMARC policy constants
+const (
+	DMARCPolicyNone       = "none"
+	DMARCPolicyQuarantine = "quarantine"
+	DMARCPolicyReject     = "reject"
+)
+
+// DMARCResult DMARC 检查结果
+type DMARCResult struct {
+	Result Result
+	// Domain From 头中的域名
+	Domain string
+	// Policy 适用的处理策略，子域名使用 sp=，没有 DMARC 记录时为空
+	Policy      string
+	SPFAligned  bool
+	DKIMAligned bool
+	Reason      string
+}
+
+// dmarcRecord 解析后的 DMARC 记录
+type dmarcRecord struct {
+	policy          string
+	subdomainPolicy string
+	strictSPF       bool
+	strictDKIM      bool
+}
+
+// CheckDMARC 按 RFC 7489 检查 From 域名与通过验证的 SPF、DKIM 域名是否对齐，并返回域名发布的处理策略
+// 组织域名使用内置的公共后缀近似计算，见 OrganizationalDomain
+func CheckDMARC(ctx context.Context, r Resolver, fromDomain string, spf *SPFResult, dkim []*DKIMResult) *DMARCResult {
+	fromDomain = normalizeDomain(fromDomain)
+	res := &DMARCResult{Domain: fromDomain}
+	if !isValidDomain(fromDomain) {
+		res.Result, res.Reason = ResultNone, "no valid from domain"
+		return res
+	}
+
+	record, subdomain, err := lookupDMARC(ctx, r, fromDomain)
+	if err != nil {
+		res.Result, res.Reason = err.result, err.reason
+		return res
+	}
+	if record == nil {
+		res.Result, res.Reason = ResultNone, "no dmarc record for "+fromDomain
+		return res
+	}
+	res.Policy = record.policy
+	if subdomain && record.subdomainPolicy != "" {
+		res.Policy = record.subdomainPolicy
+	}
+
+	if spf != nil && spf.Result == ResultPass {
+		res.SPFAligned = aligned(spf.Domain, fromDomain, record.strictSPF)
+	}
+	for _, d := range dkim {
+		if d.Result == ResultPass && aligned(d.Domain, fromDomain, record.strictDKIM) {
+			res.DKIMAligned = true
+			break
+		}
+	}
+	if res.SPFAligned || res.DKIMAligned {
+		res.Result = ResultPass
+		return res
+	}
+	res.Result, res.Reason = ResultFail, "no aligned spf or dkim pass for "+fromDomain
+	return res
+}
+
+// lookupDMARC 查询 From 域名的 DMARC 记录，没有时回退到组织域名，subdomain 表示使用了组织域名的记录
+func lookupDMARC(ctx context.Context, r Resolver, domain string) (*dmarcRecord, bool, *authError) {
+	record, err := queryDMARC(ctx, r, domain)
+	if err != nil || record != nil {
+		return record, false, err
+	}
+	org := OrganizationalDomain(domain)
+	if org == domain {
+		return nil, false, nil
+	}
+	record, err = queryDMARC(ctx, r, org)
+	return record, record != nil, err
+}
+
+func queryDMARC(ctx context.Context, r Resolver, domain string) (*dmarcRecord, *authError) {
+	name := "_dmarc." + domain
+	txts, err := r.LookupTXT(ctx, name)
+	if err != nil {
+		if isNotFound(err) {
+			return nil, nil
+		}
+		return nil, &authError{result: ResultTempError, reason: fmt.Sprintf("lookup %s: %v", name, err)}
+	}
+
+	var found []string
+	for _, txt := range txts {
+		if v, _, _ := strings.Cut(txt, ";"); strings.EqualFold(strings.ReplaceAll(v, " ", ""), "v=DMARC1") {
+			found = append(found, txt)
+		}
+	}
+	// 没有或有多条记录时视为未发布 DMARC（RFC 7489 6.6.3）
+	if len(found) != 1 {
+		return nil, nil
+	}
+
+	tags, err := parseTags(found[0])
+	if err != nil {
+		return nil, &authError{result: ResultPermError, reason: fmt.Sprintf("malformed dmarc record of %s: %v", domain, err)}
+	}
+	record := &dmarcRecord{
+		policy:          strings.ToLower(tags["p"]),
+		subdomainPolicy: strings.ToLower(tags["sp"]),
+		strictSPF:       strings.EqualFold(tags["aspf"], "s"),
+		strictDKIM:      strings.EqualFold(tags["adkim"], "s"),
+	}
+	if !isDMARCPolicy(record.policy) {
+		return nil, &authError{result: ResultPermError, reason: fmt.Sprintf("invalid dmarc policy %q of %s", tags["p"], domain)}
+	}
+	if record.subdomainPolicy != "" && !isDMARCPolicy(record.subdomainPolicy) {
+		record.subdomainPolicy = ""
+	}
+	return record, nil
+}
+
+func isDMARCPolicy(p string) bool {
+	return p == DMARCPolicyNone || p == DMARCPolicyQuarantine || p == DMARCPolicyReject
+}
+
+// aligned 判断两个域名是否对齐：严格模式要求完全相同，宽松模式要求组织域名相同
+func aligned(domain, fromDomain string, strict bool) bool {
+	domain = normalizeDomain(domain)
+	if domain == "" {
+		return false
+	}
+	if strict {
+		return domain == fromDomain
+	}
+	return OrganizationalDomain(domain) == OrganizationalDomain(fromDomain)
+}
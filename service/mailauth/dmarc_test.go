@@ -0,0 +1,139 @@
+package mailauth
+
+import (
+	"context"
+	"net"
+	"testing"
+)
+
+func TestOrganizationalDomain(t *testing.T) {
+	cases := map[string]string{
+		"example.com":              "example.com",
+		"Mail.Example.COM.":        "example.com",
+		"a.b.example.com":          "example.com",
+		"example.co.uk":            "example.co.uk",
+		"mail.example.co.uk":       "example.co.uk",
+		"co.uk":                    "co.uk",
+		"news.bbc.example.com.cn":  "example.com.cn",
+		"localhost":                "localhost",
+		"deep.sub.example.example": "example.example",
+	}
+	for in, want := range cases {
+		if got := OrganizationalDomain(in); got != want {
+			t.Errorf("OrganizationalDomain(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestDMARCAlignment(t *testing.T) {
+	r := &StaticResolver{TXT: map[string][]string{
+		"_dmarc.example.com": {"v=DMARC1; p=reject"},
+		"_dmarc.strict.com":  {"v=DMARC1; p=quarantine; aspf=s; adkim=s"},
+	}}
+	spfPass := func(domain string) *SPFResult { return &SPFResult{Result: ResultPass, Domain: domain} }
+	dkimPass := func(domain string) []*DKIMResult { return []*DKIMResult{{Result: ResultPass, Domain: domain}} }
+	cases := []struct {
+		name       string
+		from       string
+		spf        *SPFResult
+		dkim       []*DKIMResult
+		want       Result
+		spfAligned bool
+		dkimAlign  bool
+	}{
+		{"spf exact", "example.com", spfPass("example.com"), nil, ResultPass, true, false},
+		{"spf relaxed subdomain", "example.com", spfPass("bounce.example.com"), nil, ResultPass, true, false},
+		{"dkim relaxed subdomain", "news.example.com", nil, dkimPass("example.com"), ResultPass, false, true},
+		{"spf other domain", "example.com", spfPass("example.net"), nil, ResultFail, false, false},
+		{"spf not passing", "example.com", &SPFResult{Result: ResultSoftFail, Domain: "example.com"}, nil, ResultFail, false, false},
+		{"dkim failing", "example.com", nil, []*DKIMResult{{Result: ResultFail, Domain: "example.com"}}, ResultFail, false, false},
+		{"any dkim aligned", "example.com", nil, []*DKIMResult{{Result: ResultPass, Domain: "esp.example"}, {Result: ResultPass, Domain: "example.com"}}, ResultPass, false, true},
+		{"strict spf exact", "strict.com", spfPass("strict.com"), nil, ResultPass, true, false},
+		{"strict spf subdomain", "strict.com", spfPass("bounce.strict.com"), nil, ResultFail, false, false},
+		{"strict dkim subdomain", "strict.com", nil, dkimPass("mail.strict.com"), ResultFail, false, false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			res := CheckDMARC(context.Background(), r, tc.from, tc.spf, tc.dkim)
+			if res.Result != tc.want || res.SPFAligned != tc.spfAligned || res.DKIMAligned != tc.dkimAlign {
+				t.Fatalf("result = %+v, want %s spf=%v dkim=%v", res, tc.want, tc.spfAligned, tc.dkimAlign)
+			}
+		})
+	}
+}
+
+func TestDMARCPolicy(t *testing.T) {
+	r := &failingResolver{
+		StaticResolver: &StaticResolver{TXT: map[string][]string{
+			"_dmarc.example.com":      {"v=DMARC1; p=reject; sp=quarantine"},
+			"_dmarc.own.example.com":  {"v=DMARC1; p=none"},
+			"_dmarc.nosp.example":     {"v=DMARC1; p=quarantine; sp=bogus"},
+			"_dmarc.invalid.example":  {"v=DMARC1; p=block"},
+			"_dmarc.multi.example":    {"v=DMARC1; p=reject", "v=DMARC1; p=none"},
+			"_dmarc.notdmarc.example": {"v=spf1 -all"},
+			"_dmarc.example.co.uk":    {"v=DMARC1; p=reject"},
+		}},
+		fail: map[string]bool{"_dmarc.flaky.example": true},
+	}
+	cases := []struct {
+		from   string
+		want   Result
+		policy string
+	}{
+		{"example.com", ResultFail, DMARCPolicyReject},
+		{"mail.example.com", ResultFail, DMARCPolicyQuarantine}, // 回退到组织域名，使用 sp=
+		{"own.example.com", ResultFail, DMARCPolicyNone},        // 子域名自己的记录优先
+		{"a.nosp.example", ResultFail, DMARCPolicyQuarantine},   // 无效的 sp= 回退到 p=
+		{"shop.example.co.uk", ResultFail, DMARCPolicyReject},   // 多级公共后缀
+		{"invalid.example", ResultPermError, ""},
+		{"multi.example", ResultNone, ""},
+		{"notdmarc.example", ResultNone, ""},
+		{"unknown.example", ResultNone, ""},
+		{"flaky.example", ResultTempError, ""},
+		{"", ResultNone, ""},
+	}
+	for _, c := range cases {
+		res := CheckDMARC(context.Background(), r, c.from, nil, nil)
+		if res.Result != c.want || res.Policy != c.policy {
+			t.Errorf("%q: %s (p=%s, %s), want %s (p=%s)", c.from, res.Result, res.Policy, res.Reason, c.want, c.policy)
+		}
+	}
+}
+
+func TestVerifierFromDomains(t *testing.T) {
+	v := NewVerifier(&StaticResolver{TXT: map[string][]string{
+		"example.com":        {"v=spf1 ip4:192.0.2.0/24 -all"},
+		"_dmarc.example.com": {"v=DMARC1; p=reject"},
+	}})
+	verify := func(header string) *Results {
+		return v.Verify(context.Background(), &Input{
+			RemoteIP: net.ParseIP("192.0.2.1"),
+			Helo:     "mail.example.com",
+			MailFrom: "bounce@example.com",
+			Raw:      []byte(header + "Subject: hi\r\n\r\nbody\r\n"),
+		})
+	}
+
+	res := verify("From: Alice <alice@Example.com>\r\n")
+	if res.FromDomain != "example.com" || res.DMARC.Result != ResultPass || !res.DMARC.SPFAligned {
+		t.Fatalf("single from = %+v / %+v, want dmarc pass", res, res.DMARC)
+	}
+	// 同一域名的多个地址仍按单个域名检查
+	if res := verify("From: a@example.com, b@example.com\r\n"); res.DMARC.Result != ResultPass {
+		t.Fatalf("same domain twice = %s (%s), want pass", res.DMARC.Result, res.DMARC.Reason)
+	}
+	res = verify("From: a@example.com, b@example.net\r\n")
+	if res.DMARC.Result != ResultPermError || res.FromDomain != "example.com" {
+		t.Fatalf("multiple from domains = %+v, want permerror", res.DMARC)
+	}
+	if res := verify("From: a@example.com\r\nFrom: b@example.net\r\n"); res.DMARC.Result != ResultPermError {
+		t.Fatalf("multiple from headers = %s, want permerror", res.DMARC.Result)
+	}
+	if res := verify("To: a@example.com\r\n"); res.DMARC.Result != ResultPermError || res.FromDomain != "" {
+		t.Fatalf("missing from = %+v, want permerror", res.DMARC)
+	}
+	// 无法按 RFC 5322 解析的 From 仍提取域名
+	if res := verify("From: \"broken <alice@example.com>\r\n"); res.FromDomain != "example.com" {
+		t.Fatalf("malformed from domain = %q, want example.com", res.FromDomain)
+	}
+}
@@ -0,0 +1,125 @@
+package mailauth
+
+import (
+	"bytes"
+	"strings"
+)
+
+// ResultsHeader Authentication-Results 头的名称（RFC 8601）
+const ResultsHeader = "Authentication-Results"
+
+// FormatResultsHeader 生成记录检查结果的 Authentication-Results 头，包含结尾的 CRLF
+// 每种方法单独折行，例如：
+//
+//	Authentication-Results: mx.myplaud;
+//		spf=pass smtp.mailfrom=example.com;
+//		dkim=pass header.d=example.com header.s=s1 header.a=rsa-sha256;
+//		dmarc=pass (p=reject) header.from=example.com
+func FormatResultsHeader(authservID string, res *Results) string {
+	var methods []string
+
+	spf := "spf=" + string(ResultNone)
+	if res.SPF != nil {
+		spf = "spf=" + string(res.SPF.Result)
+		if res.SPF.Domain != "" {
+			prop := "smtp.mailfrom"
+			if res.SPF.Identity == SPFIdentityHelo {
+				prop = "smtp.helo"
+			}
+			spf += " " + prop + "=" + propValue(res.SPF.Domain)
+		}
+	}
+	methods = append(methods, spf)
+
+	if len(res.DKIM) == 0 {
+		methods = append(methods, "dkim="+string(ResultNone))
+	}
+	for _, d := range res.DKIM {
+		dkim := "dkim=" + string(d.Result)
+		if d.Domain != "" {
+			dkim += " header.d=" + propValue(d.Domain)
+		}
+		if d.Selector != "" {
+			dkim += " header.s=" + propValue(d.Selector)
+		}
+		if d.Algorithm != "" {
+			dkim += " header.a=" + propValue(d.Algorithm)
+		}
+		methods = append(methods, dkim)
+	}
+
+	dmarc := "dmarc=" + string(ResultNone)
+	if res.DMARC != nil {
+		dmarc = "dmarc=" + string(res.DMARC.Result)
+		if res.DMARC.Policy != "" {
+			dmarc += " (p=" + res.DMARC.Policy + ")"
+		}
+		if res.DMARC.Domain != "" {
+			dmarc += " header.from=" + propValue(res.DMARC.Domain)
+		}
+	}
+	methods = append(methods, dmarc)
+
+	return ResultsHeader + ": " + authservID + ";\r\n\t" + strings.Join(methods, ";\r\n\t") + "\r\n"
+}
+
+// StripResultsHeaders 移除 authserv-id 与 authservID 相同的 Authentication-Results 头
+// 发信方可能伪造本服务的检查结果，写入新的结果前需要移除（RFC 8601 5）
+func StripResultsHeaders(raw []byte, authservID string) []byte {
+	header, body := splitMessage(raw)
+	fields := parseHeaderFields(header)
+	var b bytes.Buffer
+	stripped := false
+	for _, f := range fields {
+		if strings.EqualFold(f.Name, ResultsHeader) && strings.EqualFold(resultsAuthservID(fieldValue(f.Raw)), authservID) {
+			stripped = true
+			continue
+		}
+		b.Write(f.Raw)
+	}
+	if !stripped {
+		return raw
+	}
+	b.WriteString("\r\n")
+	b.Write(body)
+	return b.Bytes()
+}
+
+// resultsAuthservID 返回 Authentication-Results 头中的 authserv-id，忽略注释与版本号
+func resultsAuthservID(value string) string {
+	id, _, _ := strings.Cut(stripComments(value), ";")
+	if fields := strings.Fields(id); len(fields) > 0 {
+		return fields[0]
+	}
+	return ""
+}
+
+// stripComments 去掉括号中的注释
+func stripComments(v string) string {
+	var b strings.Builder
+	depth := 0
+	for i := 0; i < len(v); i++ {
+		switch c := v[i]; {
+		case c == '\\' && depth > 0:
+			i++
+		case c == '(':
+			depth++
+		case c == ')' && depth > 0:
+			depth--
+		case depth == 0:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// propValue 属性值包含 token 以外的字符时使用 quoted-string，@ 保留以便表示邮件地址
+func propValue(v string) string {
+	for i := 0; i < len(v); i++ {
+		c := v[i]
+		if c <= ' ' || c >= 0x7f || strings.IndexByte(`()<>,;:\"/[]?=`, c) >= 0 {
+			return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\r", "", "\n", "").Replace(v) + `"`
+		}
+	}
+	return v
+}
@@ -0,0 +1,91 @@
+package mailauth
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestFormatResultsHeader(t *testing.T) {
+	cases := []struct {
+		name string
+		res  *Results
+		want string
+	}{
+		{
+			"full",
+			&Results{
+				SPF: &SPFResult{Result: ResultPass, Identity: SPFIdentityMailFrom, Domain: "example.com"},
+				DKIM: []*DKIMResult{
+					{Result: ResultPass, Domain: "example.com", Selector: "s1", Algorithm: "rsa-sha256"},
+					{Result: ResultPermError, Domain: "esp.example"},
+				},
+				DMARC: &DMARCResult{Result: ResultPass, Domain: "example.com", Policy: DMARCPolicyReject},
+			},
+			"Authentication-Results: mx.myplaud;\r\n" +
+				"\tspf=pass smtp.mailfrom=example.com;\r\n" +
+				"\tdkim=pass header.d=example.com header.s=s1 header.a=rsa-sha256;\r\n" +
+				"\tdkim=permerror header.d=esp.example;\r\n" +
+				"\tdmarc=pass (p=reject) header.from=example.com\r\n",
+		},
+		{
+			"helo identity",
+			&Results{
+				SPF:   &SPFResult{Result: ResultSoftFail, Identity: SPFIdentityHelo, Domain: "mail.example.com"},
+				DMARC: &DMARCResult{Result: ResultNone, Domain: "example.com"},
+			},
+			"Authentication-Results: mx.myplaud;\r\n" +
+				"\tspf=softfail smtp.helo=mail.example.com;\r\n" +
+				"\tdkim=none;\r\n" +
+				"\tdmarc=none header.from=example.com\r\n",
+		},
+		{
+			"quoted values",
+			&Results{
+				DKIM: []*DKIMResult{{Result: ResultFail, Domain: "example.com", Selector: "a b\"c"}},
+			},
+			"Authentication-Results: mx.myplaud;\r\n" +
+				"\tspf=none;\r\n" +
+				"\tdkim=fail header.d=example.com header.s=\"a b\\\"c\";\r\n" +
+				"\tdmarc=none\r\n",
+		},
+		{
+			"nothing checked",
+			&Results{},
+			"Authentication-Results: mx.myplaud;\r\n\tspf=none;\r\n\tdkim=none;\r\n\tdmarc=none\r\n",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := FormatResultsHeader("mx.myplaud", tc.res); got != tc.want {
+				t.Fatalf("got\n%q\nwant\n%q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestStripResultsHeaders(t *testing.T) {
+	raw := "Received: from relay\r\n" +
+		"Authentication-Results: mx.myplaud; spf=pass smtp.mailfrom=evil.example\r\n" +
+		"authentication-results: MX.MYPLAUD (forged) 1;\r\n\tdkim=pass header.d=bank.example\r\n" +
+		"Authentication-Results: (comment) mx.myplaud; dmarc=pass\r\n" +
+		"Authentication-Results: mx.myplaud.evil; spf=pass\r\n" +
+		"Authentication-Results: mx.google.com; spf=pass smtp.mailfrom=example.com\r\n" +
+		"From: a@example.com\r\n" +
+		"\r\n" +
+		"Authentication-Results: mx.myplaud; spf=pass\r\n"
+	want := "Received: from relay\r\n" +
+		"Authentication-Results: mx.myplaud.evil; spf=pass\r\n" +
+		"Authentication-Results: mx.google.com; spf=pass smtp.mailfrom=example.com\r\n" +
+		"From: a@example.com\r\n" +
+		"\r\n" +
+		"Authentication-Results: mx.myplaud; spf=pass\r\n"
+	if got := StripResultsHeaders([]byte(raw), "mx.myplaud"); string(got) != want {
+		t.Fatalf("got\n%q\nwant\n%q", got, want)
+	}
+
+	// 没有需要移除的头时原样返回
+	clean := []byte("Authentication-Results: mx.google.com; spf=pass\r\nFrom: a@example.com\r\n\r\nbody\r\n")
+	if got := StripResultsHeaders(clean, "mx.myplaud"); !bytes.Equal(got, clean) || &got[0] != &clean[0] {
+		t.Fatalf("unchanged message should be returned as is: %q", got)
+	}
+}
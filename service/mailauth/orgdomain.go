@@ -0,0 +1,39 @@
+package mailauth
+
+import "strings"
+
+// multiLabelSuffixes 常见的多级公共后缀，用于近似计算组织域名
+// 没有内置完整的 Public Suffix List，不在列表中的后缀按一级处理
+var multiLabelSuffixes = map[string]bool{
+	"co.uk": true, "org.uk": true, "ac.uk": true, "gov.uk": true, "me.uk": true, "ltd.uk": true, "plc.uk": true,
+	"com.cn": true, "net.cn": true, "org.cn": true, "gov.cn": true, "edu.cn": true,
+	"com.hk": true, "org.hk": true, "net.hk": true, "edu.hk": true, "gov.hk": true,
+	"com.tw": true, "org.tw": true, "net.tw": true, "edu.tw": true, "gov.tw": true,
+	"co.jp": true, "ne.jp": true, "or.jp": true, "ac.jp": true, "go.jp": true,
+	"co.kr": true, "or.kr": true, "ac.kr": true, "go.kr": true,
+	"com.au": true, "net.au": true, "org.au": true, "edu.au": true, "gov.au": true,
+	"co.nz": true, "org.nz": true, "net.nz": true,
+	"com.sg": true, "org.sg": true, "edu.sg": true, "gov.sg": true,
+	"com.my": true, "com.ph": true, "co.th": true, "co.id": true, "com.vn": true,
+	"co.in": true, "net.in": true, "org.in": true, "gov.in": true,
+	"com.br": true, "net.br": true, "org.br": true, "gov.br": true,
+	"com.mx": true, "com.ar": true, "com.co": true, "com.tr": true,
+	"co.za": true, "com.ng": true, "co.il": true, "com.sa": true, "com.eg": true,
+}
+
+// OrganizationalDomain 返回域名的组织域名（RFC 7489 3.2），即公共后缀下的一级域名
+func OrganizationalDomain(domain string) string {
+	domain = normalizeDomain(domain)
+	labels := strings.Split(domain, ".")
+	if len(labels) <= 2 {
+		return domain
+	}
+	n := 2
+	if multiLabelSuffixes[strings.Join(labels[len(labels)-2:], ".")] {
+		n = 3
+	}
+	if len(labels) <= n {
+		return domain
+	}
+	return strings.Join(labels[len(labels)-n:], ".")
+}
@@ -0,0 +1,72 @@
+package mailauth
+
+import (
+	"context"
+	"errors"
+	"net"
+)
+
+// Resolver 认证检查使用的 DNS 查询接口，*net.Resolver 实现了该接口，离线测试时可替换为固定记录
+type Resolver interface {
+	LookupTXT(ctx context.Context, name string) ([]string, error)
+	LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error)
+	LookupMX(ctx context.Context, name string) ([]*net.MX, error)
+}
+
+// DefaultResolver 使用系统 DNS 配置的 Resolver
+var DefaultResolver Resolver = net.DefaultResolver
+
+// Result 认证检查结果，取值与 RFC 8601 Authentication-Results 中的结果一致
+type Result string
+
+// Result constants
+const (
+	ResultNone      Result = "none"
+	ResultPass      Result = "pass"
+	ResultFail      Result = "fail"
+	ResultSoftFail  Result = "softfail"
+	ResultNeutral   Result = "neutral"
+	ResultTempError Result = "temperror"
+	ResultPermError Result = "permerror"
+)
+
+// isNotFound 域名不存在或没有对应类型的记录
+func isNotFound(err error) bool {
+	var dnsErr *net.DNSError
+	return errors.As(err, &dnsErr) && dnsErr.IsNotFound
+}
+
+// StaticResolver 从固定记录应答的 Resolver，用于离线测试与本地开发，未登记的名称按域名不存在处理
+type StaticResolver struct {
+	TXT map[string][]string
+	IP  map[string][]net.IPAddr
+	MX  map[string][]*net.MX
+}
+
+// LookupTXT 实现 Resolver
+func (r *StaticResolver) LookupTXT(ctx context.Context, name string) ([]string, error) {
+	if v, ok := r.TXT[normalizeDomain(name)]; ok {
+		return v, nil
+	}
+	return nil, notFound(name)
+}
+
+// LookupIPAddr 实现 Resolver
+func (r *StaticResolver) LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error) {
+	if v, ok := r.IP[normalizeDomain(host)]; ok {
+		return v, nil
+	}
+	return nil, notFound(host)
+}
+
+// LookupMX 实现 Resolver
+func (r *StaticResolver) LookupMX(ctx context.Context, name string) ([]*net.MX, error) {
+	if v, ok := r.MX[normalizeDomain(name)]; ok {
+		return v, nil
+	}
+	return nil, notFound(name)
+}
+
+func notFound(name string) error {
+	return &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+}
@@ -0,0 +1,618 @@
+package mailauth
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const (
+	// spfMaxLookups 一次检查最多执行的触发 DNS 查询的机制与修饰符数量（RFC 7208 4.6.4）
+	spfMaxLookups = 10
+	// spfMaxVoidLookups 最多允许的空结果查询次数
+	spfMaxVoidLookups = 2
+	// spfMaxMX mx 机制最多处理的 MX 记录数
+	spfMaxMX = 10
+)
+
+// SPF identity constants
+const (
+	SPFIdentityMailFrom = "mailfrom"
+	SPFIdentityHelo     = "helo"
+)
+
+// SPFResult SPF 检查结果
+type SPFResult struct {
+	Result Result
+	// Domain 被检查的域名，MAIL FROM 为空（退信）时为 HELO 域名
+	Domain string
+	// Identity 被检查的身份，见 SPFIdentity 常量
+	Identity string
+	Reason   string
+}
+
+// authError 中止检查的错误，result 为 temperror 或 permerror
+type authError struct {
+	result Result
+	reason string
+}
+
+func (e *authError) Error() string {
+	return string(e.result) + ": " + e.reason
+}
+
+func spfPermError(format string, args ...any) error {
+	return &authError{result: ResultPermError, reason: fmt.Sprintf(format, args...)}
+}
+
+func spfTempError(format string, args ...any) error {
+	return &authError{result: ResultTempError, reason: fmt.Sprintf(format, args...)}
+}
+
+// CheckSPF 按 RFC 7208 检查 ip 是否被授权使用 mailFrom 的域名发信，mailFrom 为空（退信）时检查 HELO 域名
+// 不支持 ptr 机制（RFC 7208 不推荐使用），按不匹配处理
+func CheckSPF(ctx context.Context, r Resolver, ip net.IP, helo, mailFrom string) *SPFResult {
+	sender, identity := mailFrom, SPFIdentityMailFrom
+	if sender == "" {
+		sender, identity = "postmaster@"+helo, SPFIdentityHelo
+	}
+	local, domain := "postmaster", sender
+	if at := strings.LastIndex(sender, "@"); at >= 0 {
+		local, domain = sender[:at], sender[at+1:]
+	}
+	if local == "" {
+		local = "postmaster"
+	}
+	domain = normalizeDomain(domain)
+
+	res := &SPFResult{Domain: domain, Identity: identity}
+	switch {
+	case ip == nil:
+		res.Result, res.Reason = ResultNone, "client ip unknown"
+		return res
+	case !isValidDomain(domain):
+		res.Result, res.Reason = ResultNone, "no valid domain to check"
+		return res
+	}
+
+	c := &spfChecker{r: r, ip: ip, sender: local + "@" + domain, local: local, senderDomain: domain, helo: helo}
+	result, reason, err := c.evaluate(ctx, domain)
+	if err != nil {
+		var e *authError
+		if errors.As(err, &e) {
+			result, reason = e.result, e.reason
+		} else {
+			result, reason = ResultTempError, err.Error()
+		}
+	}
+	res.Result, res.Reason = result, reason
+	return res
+}
+
+type spfChecker struct {
+	r            Resolver
+	ip           net.IP
+	sender       string
+	local        string
+	senderDomain string
+	helo         string
+	lookups      int
+	voids        int
+}
+
+// evaluate 对 domain 执行 check_host()，temperror 与 permerror 以 *authError 返回
+func (c *spfChecker) evaluate(ctx context.Context, domain string) (Result, string, error) {
+	record, err := c.lookupRecord(ctx, domain)
+	if err != nil {
+		return "", "", err
+	}
+	if record == "" {
+		return ResultNone, "no spf record for " + domain, nil
+	}
+
+	var redirect string
+	for _, term := range strings.Fields(record)[1:] {
+		if name, value, ok := splitModifier(term); ok {
+			if name == "redirect" {
+				if redirect != "" {
+					return "", "", spfPermError("duplicate redirect in spf record of %s", domain)
+				}
+				redirect = value
+			}
+			// exp 与未知的修饰符忽略
+			continue
+		}
+
+		qualifier := ResultPass
+		switch term[0] {
+		case '+':
+			term = term[1:]
+		case '-':
+			qualifier, term = ResultFail, term[1:]
+		case '~':
+			qualifier, term = ResultSoftFail, term[1:]
+		case '?':
+			qualifier, term = ResultNeutral, term[1:]
+		}
+		matched, err := c.mechanism(ctx, domain, term)
+		if err != nil {
+			return "", "", err
+		}
+		if matched {
+			return qualifier, fmt.Sprintf("%s matched %s of %s", c.ip, term, domain), nil
+		}
+	}
+
+	if redirect != "" {
+		if err := c.countLookup(); err != nil {
+			return "", "", err
+		}
+		target, err := c.expand(redirect, domain)
+		if err != nil {
+			return "", "", err
+		}
+		result, reason, err := c.evaluate(ctx, target)
+		if err != nil {
+			return "", "", err
+		}
+		if result == ResultNone {
+			return "", "", spfPermError("redirect target %s has no spf record", target)
+		}
+		return result, reason, nil
+	}
+	return ResultNeutral, "no mechanism matched in spf record of " + domain, nil
+}
+
+// mechanism 判断一个机制是否匹配客户端 IP
+func (c *spfChecker) mechanism(ctx context.Context, domain, term string) (bool, error) {
+	name, arg := term, ""
+	if i := strings.IndexAny(term, ":/"); i >= 0 {
+		name, arg = term[:i], term[i:]
+	}
+
+	switch strings.ToLower(name) {
+	case "all":
+		if arg != "" {
+			return false, spfPermError("invalid mechanism %q", term)
+		}
+		return true, nil
+
+	case "include":
+		spec, ok := strings.CutPrefix(arg, ":")
+		if !ok || spec == "" {
+			return false, spfPermError("invalid mechanism %q", term)
+		}
+		if err := c.countLookup(); err != nil {
+			return false, err
+		}
+		target, err := c.expand(spec, domain)
+		if err != nil {
+			return false, err
+		}
+		result, _, err := c.evaluate(ctx, target)
+		if err != nil {
+			return false, err
+		}
+		switch result {
+		case ResultPass:
+			return true, nil
+		case ResultNone:
+			return false, spfPermError("included domain %s has no spf record", target)
+		default:
+			return false, nil
+		}
+
+	case "a":
+		if err := c.countLookup(); err != nil {
+			return false, err
+		}
+		target, v4, v6, err := c.domainCIDR(arg, domain)
+		if err != nil {
+			return false, err
+		}
+		ips, err := c.lookupIP(ctx, target, true)
+		if err != nil {
+			return false, err
+		}
+		return c.matchIPs(ips, v4, v6), nil
+
+	case "mx":
+		if err := c.countLookup(); err != nil {
+			return false, err
+		}
+		target, v4, v6, err := c.domainCIDR(arg, domain)
+		if err != nil {
+			return false, err
+		}
+		mxs, err := c.r.LookupMX(ctx, target)
+		if err != nil {
+			if !isNotFound(err) {
+				return false, spfTempError("lookup mx of %s: %v", target, err)
+			}
+			if err := c.countVoid(); err != nil {
+				return false, err
+			}
+			return false, nil
+		}
+		if len(mxs) > spfMaxMX {
+			return false, spfPermError("too many mx records for %s", target)
+		}
+		for _, mx := range mxs {
+			ips, err := c.lookupIP(ctx, strings.TrimSuffix(mx.Host, "."), false)
+			if err != nil {
+				return false, err
+			}
+			if c.matchIPs(ips, v4, v6) {
+				return true, nil
+			}
+		}
+		return false, nil
+
+	case "ptr":
+		if err := c.countLookup(); err != nil {
+			return false, err
+		}
+		return false, nil
+
+	case "ip4", "ip6":
+		spec, ok := strings.CutPrefix(arg, ":")
+		if !ok || spec == "" {
+			return false, spfPermError("invalid mechanism %q", term)
+		}
+		if !strings.Contains(spec, "/") {
+			if strings.EqualFold(name, "ip4") {
+				spec += "/32"
+			} else {
+				spec += "/128"
+			}
+		}
+		_, network, err := net.ParseCIDR(spec)
+		if err != nil || (network.IP.To4() != nil) != strings.EqualFold(name, "ip4") {
+			return false, spfPermError("invalid mechanism %q", term)
+		}
+		if (c.ip.To4() != nil) != (network.IP.To4() != nil) {
+			return false, nil
+		}
+		return network.Contains(c.ip), nil
+
+	case "exists":
+		spec, ok := strings.CutPrefix(arg, ":")
+		if !ok || spec == "" {
+			return false, spfPermError("invalid mechanism %q", term)
+		}
+		if err := c.countLookup(); err != nil {
+			return false, err
+		}
+		target, err := c.expand(spec, domain)
+		if err != nil {
+			return false, err
+		}
+		ips, err := c.lookupIP(ctx, target, true)
+		if err != nil {
+			return false, err
+		}
+		for _, ip := range ips {
+			if ip.To4() != nil {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+	return false, spfPermError("unknown mechanism %q", term)
+}
+
+// lookupRecord 查询 domain 的 SPF 记录，没有记录时返回空字符串
+func (c *spfChecker) lookupRecord(ctx context.Context, domain string) (string, error) {
+	txts, err := c.r.LookupTXT(ctx, domain)
+	if err != nil {
+		if isNotFound(err) {
+			return "", nil
+		}
+		return "", spfTempError("lookup spf record of %s: %v", domain, err)
+	}
+	var records []string
+	for _, txt := range txts {
+		lower := strings.ToLower(txt)
+		if lower == "v=spf1" || strings.HasPrefix(lower, "v=spf1 ") {
+			records = append(records, txt)
+		}
+	}
+	switch len(records) {
+	case 0:
+		return "", nil
+	case 1:
+		return records[0], nil
+	default:
+		return "", spfPermError("multiple spf records for %s", domain)
+	}
+}
+
+// lookupIP 查询主机的 IP，countVoid 为 true 时空结果计入空查询次数
+func (c *spfChecker) lookupIP(ctx context.Context, host string, countVoid bool) ([]net.IP, error) {
+	addrs, err := c.r.LookupIPAddr(ctx, host)
+	if err != nil && !isNotFound(err) {
+		return nil, spfTempError("lookup address of %s: %v", host, err)
+	}
+	if len(addrs) == 0 {
+		if countVoid {
+			return nil, c.countVoid()
+		}
+		return nil, nil
+	}
+	ips := make([]net.IP, 0, len(addrs))
+	for _, a := range addrs {
+		ips = append(ips, a.IP)
+	}
+	return ips, nil
+}
+
+func (c *spfChecker) countLookup() error {
+	c.lookups++
+	if c.lookups > spfMaxLookups {
+		return spfPermError("too many dns lookups")
+	}
+	return nil
+}
+
+func (c *spfChecker) countVoid() error {
+	c.voids++
+	if c.voids > spfMaxVoidLookups {
+		return spfPermError("too many void dns lookups")
+	}
+	return nil
+}
+
+// matchIPs 按前缀长度比较客户端 IP 与查询到的 IP
+func (c *spfChecker) matchIPs(ips []net.IP, v4, v6 int) bool {
+	client4 := c.ip.To4()
+	for _, ip := range ips {
+		if ip4 := ip.To4(); ip4 != nil {
+			if client4 != nil && ip4.Mask(net.CIDRMask(v4, 32)).Equal(client4.Mask(net.CIDRMask(v4, 32))) {
+				return true
+			}
+			continue
+		}
+		if client4 == nil && ip.Mask(net.CIDRMask(v6, 128)).Equal(c.ip.Mask(net.CIDRMask(v6, 128))) {
+			return true
+		}
+	}
+	return false
+}
+
+// domainCIDR 解析 a 与 mx 机制的参数：[:domain-spec][/ip4-cidr][//ip6-cidr]
+func (c *spfChecker) domainCIDR(arg, domain string) (string, int, int, error) {
+	target, v4, v6 := domain, 32, 128
+	if spec, ok := strings.CutPrefix(arg, ":"); ok {
+		end := cidrStart(spec)
+		spec, arg = spec[:end], spec[end:]
+		if spec == "" {
+			return "", 0, 0, spfPermError("empty domain spec")
+		}
+		expanded, err := c.expand(spec, domain)
+		if err != nil {
+			return "", 0, 0, err
+		}
+		target = expanded
+	}
+	if arg == "" {
+		return target, v4, v6, nil
+	}
+
+	p4, p6, has6 := strings.Cut(arg, "//")
+	var err error
+	if p4 != "" {
+		if v4, err = parsePrefix(strings.TrimPrefix(p4, "/"), 32); err != nil || !strings.HasPrefix(p4, "/") {
+			return "", 0, 0, spfPermError("invalid cidr %q", arg)
+		}
+	}
+	if has6 {
+		if v6, err = parsePrefix(p6, 128); err != nil {
+			return "", 0, 0, spfPermError("invalid cidr %q", arg)
+		}
+	}
+	return target, v4, v6, nil
+}
+
+// cidrStart 返回 domain-spec 之后 CIDR 部分的起始位置，宏中的 / 不作为分隔
+func cidrStart(spec string) int {
+	depth := 0
+	for i := 0; i < len(spec); i++ {
+		switch spec[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+		case '/':
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return len(spec)
+}
+
+func parsePrefix(v string, max int) (int, error) {
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 || n > max {
+		return 0, fmt.Errorf("invalid prefix %q", v)
+	}
+	return n, nil
+}
+
+// expand 展开 domain-spec 中的宏（RFC 7208 7），结果过长时从左侧截断标签
+func (c *spfChecker) expand(spec, domain string) (string, error) {
+	var b strings.Builder
+	for i := 0; i < len(spec); i++ {
+		if spec[i] != '%' {
+			b.WriteByte(spec[i])
+			continue
+		}
+		if i+1 >= len(spec) {
+			return "", spfPermError("invalid macro in %q", spec)
+		}
+		i++
+		switch spec[i] {
+		case '%':
+			b.WriteByte('%')
+		case '_':
+			b.WriteByte(' ')
+		case '-':
+			b.WriteString("%20")
+		case '{':
+			end := strings.IndexByte(spec[i:], '}')
+			if end < 0 {
+				return "", spfPermError("invalid macro in %q", spec)
+			}
+			value, err := c.macro(spec[i+1:i+end], domain)
+			if err != nil {
+				return "", err
+			}
+			b.WriteString(value)
+			i += end
+		default:
+			return "", spfPermError("invalid macro in %q", spec)
+		}
+	}
+
+	out := strings.TrimSuffix(b.String(), ".")
+	for len(out) > 253 {
+		i := strings.IndexByte(out, '.')
+		if i < 0 {
+			break
+		}
+		out = out[i+1:]
+	}
+	return out, nil
+}
+
+// macro 展开一个宏：字母 [位数] [r] [分隔符]
+func (c *spfChecker) macro(body, domain string) (string, error) {
+	if body == "" {
+		return "", spfPermError("empty macro")
+	}
+	letter := body[0]
+	var value string
+	switch letter | 0x20 {
+	case 's':
+		value = c.sender
+	case 'l':
+		value = c.local
+	case 'o':
+		value = c.senderDomain
+	case 'd':
+		value = domain
+	case 'i':
+		value = macroIP(c.ip)
+	case 'p':
+		value = "unknown"
+	case 'v':
+		value = "ip6"
+		if c.ip.To4() != nil {
+			value = "in-addr"
+		}
+	case 'h':
+		value = c.helo
+	default:
+		return "", spfPermError("invalid macro letter %q", letter)
+	}
+
+	rest := body[1:]
+	j := 0
+	for j < len(rest) && rest[j] >= '0' && rest[j] <= '9' {
+		j++
+	}
+	keep := 0
+	if j > 0 {
+		n, err := strconv.Atoi(rest[:j])
+		if err != nil || n == 0 {
+			return "", spfPermError("invalid macro %q", body)
+		}
+		keep = n
+	}
+	rest = rest[j:]
+	reverse := false
+	if rest != "" && (rest[0] == 'r' || rest[0] == 'R') {
+		reverse, rest = true, rest[1:]
+	}
+	delims := rest
+	if strings.Trim(delims, ".-+,/_=") != "" {
+		return "", spfPermError("invalid macro delimiter in %q", body)
+	}
+	if delims == "" {
+		delims = "."
+	}
+
+	parts := strings.FieldsFunc(value, func(r rune) bool { return strings.ContainsRune(delims, r) })
+	if reverse {
+		for a, b := 0, len(parts)-1; a < b; a, b = a+1, b-1 {
+			parts[a], parts[b] = parts[b], parts[a]
+		}
+	}
+	if keep > 0 && keep < len(parts) {
+		parts = parts[len(parts)-keep:]
+	}
+	value = strings.Join(parts, ".")
+	if letter >= 'A' && letter <= 'Z' {
+		value = strings.ReplaceAll(url.QueryEscape(value), "+", "%20")
+	}
+	return value, nil
+}
+
+// macroIP %{i} 的取值：IPv4 为点分十进制，IPv6 为点分隔的半字节
+func macroIP(ip net.IP) string {
+	if ip4 := ip.To4(); ip4 != nil {
+		return ip4.String()
+	}
+	h := hex.EncodeToString(ip.To16())
+	nibbles := make([]string, 0, len(h))
+	for _, ch := range h {
+		nibbles = append(nibbles, string(ch))
+	}
+	return strings.Join(nibbles, ".")
+}
+
+// splitModifier 拆分 name=value 形式的修饰符
+func splitModifier(term string) (string, string, bool) {
+	i := strings.IndexByte(term, '=')
+	if i <= 0 {
+		return "", "", false
+	}
+	name := term[:i]
+	for j := 0; j < len(name); j++ {
+		ch := name[j]
+		alpha := (ch|0x20) >= 'a' && (ch|0x20) <= 'z'
+		if !alpha && (j == 0 || !(ch >= '0' && ch <= '9' || ch == '-' || ch == '_' || ch == '.')) {
+			return "", "", false
+		}
+	}
+	return strings.ToLower(name), term[i+1:], true
+}
+
+// normalizeDomain 小写并去掉末尾的点
+func normalizeDomain(domain string) string {
+	return strings.TrimSuffix(strings.ToLower(strings.TrimSpace(domain)), ".")
+}
+
+// isValidDomain 是否为至少包含两个标签的合法域名
+func isValidDomain(domain string) bool {
+	if len(domain) == 0 || len(domain) > 253 || !strings.Contains(domain, ".") {
+		return false
+	}
+	for _, label := range strings.Split(domain, ".") {
+		if len(label) == 0 || len(label) > 63 {
+			return false
+		}
+		for i := 0; i < len(label); i++ {
+			ch := label[i]
+			if !(ch >= 'a' && ch <= 'z' || ch >= '0' && ch <= '9' || ch == '-' || ch == '_') {
+				return false
+			}
+		}
+	}
+	return true
+}
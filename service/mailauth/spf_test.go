@@ -0,0 +1,222 @@
+package mailauth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+)
+
+// failingResolver 对 fail 中的名称返回临时错误，其余名称交给 StaticResolver
+type failingResolver struct {
+	*StaticResolver
+	fail map[string]bool
+}
+
+func (r *failingResolver) LookupTXT(ctx context.Context, name string) ([]string, error) {
+	if r.fail[name] {
+		return nil, &net.DNSError{Err: "server misbehaving", Name: name, IsTemporary: true}
+	}
+	return r.StaticResolver.LookupTXT(ctx, name)
+}
+
+func ipAddrs(ips ...string) []net.IPAddr {
+	addrs := make([]net.IPAddr, 0, len(ips))
+	for _, ip := range ips {
+		addrs = append(addrs, net.IPAddr{IP: net.ParseIP(ip)})
+	}
+	return addrs
+}
+
+func checkSPF(r Resolver, ip, mailFrom string) *SPFResult {
+	return CheckSPF(context.Background(), r, net.ParseIP(ip), "mail.example.com", mailFrom)
+}
+
+func TestSPFMechanisms(t *testing.T) {
+	r := &StaticResolver{
+		TXT: map[string][]string{
+			"example.com":   {"google-site-verification=abc", "v=spf1 ip4:192.0.2.0/24 ip6:2001:db8::/32 a mx:mx.example.com/28 -all"},
+			"soft.example":  {"v=spf1 ~all"},
+			"open.example":  {"v=spf1 ?all"},
+			"empty.example": {"v=spf1"},
+		},
+		IP: map[string][]net.IPAddr{
+			"example.com":      ipAddrs("198.51.100.1"),
+			"mail.example.com": ipAddrs("198.51.100.64"),
+		},
+		MX: map[string][]*net.MX{"mx.example.com": {{Host: "mail.example.com.", Pref: 10}}},
+	}
+	cases := []struct {
+		ip, from string
+		want     Result
+	}{
+		{"192.0.2.55", "a@example.com", ResultPass},
+		{"2001:db8::1", "a@example.com", ResultPass},
+		{"198.51.100.1", "a@example.com", ResultPass},  // a
+		{"198.51.100.70", "a@example.com", ResultPass}, // mx /28
+		{"198.51.100.80", "a@example.com", ResultFail},
+		{"203.0.113.1", "a@soft.example", ResultSoftFail},
+		{"203.0.113.1", "a@open.example", ResultNeutral},
+		{"203.0.113.1", "a@empty.example", ResultNeutral},
+		{"203.0.113.1", "a@unknown.example", ResultNone},
+	}
+	for _, c := range cases {
+		if got := checkSPF(r, c.ip, c.from); got.Result != c.want {
+			t.Errorf("%s from %s: %s (%s), want %s", c.from, c.ip, got.Result, got.Reason, c.want)
+		}
+	}
+}
+
+func TestSPFIdentity(t *testing.T) {
+	r := &StaticResolver{TXT: map[string][]string{
+		"example.com":      {"v=spf1 -all"},
+		"mail.example.com": {"v=spf1 ip4:192.0.2.1 -all"},
+	}}
+	// 退信（MAIL FROM 为空）检查 HELO 域名
+	res := checkSPF(r, "192.0.2.1", "")
+	if res.Result != ResultPass || res.Identity != SPFIdentityHelo || res.Domain != "mail.example.com" {
+		t.Fatalf("bounce = %+v, want pass for helo mail.example.com", res)
+	}
+	res = checkSPF(r, "192.0.2.1", "a@Example.COM.")
+	if res.Result != ResultFail || res.Identity != SPFIdentityMailFrom || res.Domain != "example.com" {
+		t.Fatalf("mailfrom = %+v, want fail for example.com", res)
+	}
+	if res := CheckSPF(context.Background(), r, nil, "", "a@example.com"); res.Result != ResultNone {
+		t.Fatalf("unknown client ip = %s, want none", res.Result)
+	}
+}
+
+func TestSPFInclude(t *testing.T) {
+	r := &StaticResolver{TXT: map[string][]string{
+		"example.com":           {"v=spf1 include:_spf.provider.example include:_spf.other.example -all"},
+		"_spf.provider.example": {"v=spf1 ip4:198.51.100.0/24 ~all"},
+		"_spf.other.example":    {"v=spf1 ip4:203.0.113.0/24 -all"},
+		"broken.example":        {"v=spf1 include:missing.example -all"},
+	}}
+	if res := checkSPF(r, "198.51.100.7", "a@example.com"); res.Result != ResultPass {
+		t.Fatalf("first include = %s (%s), want pass", res.Result, res.Reason)
+	}
+	if res := checkSPF(r, "203.0.113.7", "a@example.com"); res.Result != ResultPass {
+		t.Fatalf("second include = %s (%s), want pass", res.Result, res.Reason)
+	}
+	// 被包含记录的 ~all 只表示不匹配，结果由外层的 -all 决定
+	if res := checkSPF(r, "192.0.2.7", "a@example.com"); res.Result != ResultFail {
+		t.Fatalf("no include matched = %s, want fail", res.Result)
+	}
+	if res := checkSPF(r, "192.0.2.7", "a@broken.example"); res.Result != ResultPermError {
+		t.Fatalf("include without record = %s, want permerror", res.Result)
+	}
+}
+
+func TestSPFRedirect(t *testing.T) {
+	r := &StaticResolver{TXT: map[string][]string{
+		"example.com":      {"v=spf1 ip4:192.0.2.1 redirect=_spf.example.com"},
+		"_spf.example.com": {"v=spf1 ip4:198.51.100.0/24 -all"},
+		"loose.example":    {"v=spf1 redirect=example.com ~all"},
+		"dangling.example": {"v=spf1 redirect=missing.example"},
+		"twice.example":    {"v=spf1 redirect=a.example redirect=b.example"},
+	}}
+	cases := []struct {
+		ip, from string
+		want     Result
+	}{
+		{"192.0.2.1", "a@example.com", ResultPass},         // 先匹配本记录的机制
+		{"198.51.100.9", "a@example.com", ResultPass},      // 再跟随 redirect
+		{"203.0.113.9", "a@example.com", ResultFail},       // 结果取自目标记录
+		{"203.0.113.9", "a@loose.example", ResultSoftFail}, // 有 all 机制时忽略 redirect
+		{"203.0.113.9", "a@dangling.example", ResultPermError},
+		{"203.0.113.9", "a@twice.example", ResultPermError},
+	}
+	for _, c := range cases {
+		if got := checkSPF(r, c.ip, c.from); got.Result != c.want {
+			t.Errorf("%s from %s: %s (%s), want %s", c.from, c.ip, got.Result, got.Reason, c.want)
+		}
+	}
+}
+
+// includeChain 生成 d0 -> d1 -> ... -> dn 的 include 链，dn 允许所有 IP
+func includeChain(n int) *StaticResolver {
+	r := &StaticResolver{TXT: map[string][]string{}}
+	for i := 0; i < n; i++ {
+		r.TXT[fmt.Sprintf("d%d.example", i)] = []string{fmt.Sprintf("v=spf1 include:d%d.example -all", i+1)}
+	}
+	r.TXT[fmt.Sprintf("d%d.example", n)] = []string{"v=spf1 +all"}
+	return r
+}
+
+func TestSPFLookupLimit(t *testing.T) {
+	if res := checkSPF(includeChain(spfMaxLookups), "192.0.2.1", "a@d0.example"); res.Result != ResultPass {
+		t.Fatalf("%d lookups = %s (%s), want pass", spfMaxLookups, res.Result, res.Reason)
+	}
+	res := checkSPF(includeChain(spfMaxLookups+1), "192.0.2.1", "a@d0.example")
+	if res.Result != ResultPermError || !strings.Contains(res.Reason, "too many dns lookups") {
+		t.Fatalf("%d lookups = %s (%s), want permerror", spfMaxLookups+1, res.Result, res.Reason)
+	}
+
+	// redirect 同样计入查询次数
+	r := includeChain(spfMaxLookups)
+	r.TXT["start.example"] = []string{"v=spf1 redirect=d0.example"}
+	if res := checkSPF(r, "192.0.2.1", "a@start.example"); res.Result != ResultPermError {
+		t.Fatalf("redirect over limit = %s, want permerror", res.Result)
+	}
+}
+
+func TestSPFVoidLookupLimit(t *testing.T) {
+	r := &StaticResolver{TXT: map[string][]string{
+		"two.example":   {"v=spf1 a:v1.example mx:v2.example -all"},
+		"three.example": {"v=spf1 a:v1.example mx:v2.example exists:v3.example -all"},
+	}}
+	if res := checkSPF(r, "192.0.2.1", "a@two.example"); res.Result != ResultFail {
+		t.Fatalf("%d void lookups = %s (%s), want fail", spfMaxVoidLookups, res.Result, res.Reason)
+	}
+	res := checkSPF(r, "192.0.2.1", "a@three.example")
+	if res.Result != ResultPermError || !strings.Contains(res.Reason, "void") {
+		t.Fatalf("%d void lookups = %s (%s), want permerror", spfMaxVoidLookups+1, res.Result, res.Reason)
+	}
+}
+
+func TestSPFRecordErrors(t *testing.T) {
+	r := &failingResolver{
+		StaticResolver: &StaticResolver{TXT: map[string][]string{
+			"multi.example":   {"v=spf1 -all", "v=spf1 +all"},
+			"bad.example":     {"v=spf1 ip4:999.0.0.1 -all"},
+			"unknown.example": {"v=spf1 foo:bar -all"},
+			"flaky.example":   {"v=spf1 include:dns.flaky.example -all"},
+		}},
+		fail: map[string]bool{"down.example": true, "dns.flaky.example": true},
+	}
+	cases := []struct {
+		from string
+		want Result
+	}{
+		{"a@multi.example", ResultPermError},
+		{"a@bad.example", ResultPermError},
+		{"a@unknown.example", ResultPermError},
+		{"a@down.example", ResultTempError},
+		{"a@flaky.example", ResultTempError},
+	}
+	for _, c := range cases {
+		if got := checkSPF(r, "192.0.2.1", c.from); got.Result != c.want {
+			t.Errorf("%s: %s (%s), want %s", c.from, got.Result, got.Reason, c.want)
+		}
+	}
+}
+
+func TestSPFMacros(t *testing.T) {
+	r := &StaticResolver{
+		TXT: map[string][]string{"example.com": {"v=spf1 exists:%{ir}.%{l1r-}.allow.%{d} -all"}},
+		IP:  map[string][]net.IPAddr{"1.2.0.192.bob.allow.example.com": ipAddrs("127.0.0.2")},
+	}
+	if res := checkSPF(r, "192.0.2.1", "bob-smith@example.com"); res.Result != ResultPass {
+		t.Fatalf("macro exists = %s (%s), want pass", res.Result, res.Reason)
+	}
+	if res := checkSPF(r, "192.0.2.1", "alice@example.com"); res.Result != ResultFail {
+		t.Fatalf("macro exists for other sender = %s (%s), want fail", res.Result, res.Reason)
+	}
+	var e *authError
+	if _, err := (&spfChecker{ip: net.ParseIP("192.0.2.1")}).expand("%{z}", "example.com"); !errors.As(err, &e) || e.result != ResultPermError {
+		t.Fatalf("unknown macro err = %v, want permerror", err)
+	}
+}
@@ -0,0 +1,135 @@
+package mailauth
+
+import (
+	"context"
+	"net"
+	"net/mail"
+	"regexp"
+	"strings"
+)
+
+// Input 认证检查的输入
+type Input struct {
+	// RemoteIP 连接方 IP，为空时（如外部邮箱同步的邮件）不检查 SPF
+	RemoteIP net.IP
+	Helo     string
+	MailFrom string
+	Raw      []byte
+}
+
+// Results 一封邮件的 SPF、DKIM 与 DMARC 检查结果
+type Results struct {
+	SPF  *SPFResult
+	DKIM []*DKIMResult
+	// DMARC From 头缺失或包含多个域名时为 permerror
+	DMARC *DMARCResult
+	// FromDomain From 头中的域名
+	FromDomain string
+}
+
+// DKIMPass 是否有任一 DKIM 签名验证通过
+func (r *Results) DKIMPass() bool {
+	for _, d := range r.DKIM {
+		if d.Result == ResultPass {
+			return true
+		}
+	}
+	return false
+}
+
+// BestDKIM 返回最能代表邮件 DKIM 状态的签名：优先与 From 域名对齐的通过签名，其次任一通过签名，再次第一个签名
+// 没有签名时返回 nil
+func (r *Results) BestDKIM() *DKIMResult {
+	var pass *DKIMResult
+	for _, d := range r.DKIM {
+		if d.Result != ResultPass {
+			continue
+		}
+		if r.FromDomain != "" && aligned(d.Domain, r.FromDomain, false) {
+			return d
+		}
+		if pass == nil {
+			pass = d
+		}
+	}
+	if pass != nil {
+		return pass
+	}
+	if len(r.DKIM) > 0 {
+		return r.DKIM[0]
+	}
+	return nil
+}
+
+// Verifier 邮件认证检查
+type Verifier struct {
+	resolver Resolver
+}
+
+// NewVerifier 创建 Verifier，resolver 为 nil 时使用 DefaultResolver
+func NewVerifier(resolver Resolver) *Verifier {
+	if resolver == nil {
+		resolver = DefaultResolver
+	}
+	return &Verifier{resolver: resolver}
+}
+
+// Verify 依次检查 SPF、DKIM 与 DMARC，DNS 查询受 ctx 的超时控制
+func (v *Verifier) Verify(ctx context.Context, in *Input) *Results {
+	res := &Results{
+		SPF:  CheckSPF(ctx, v.resolver, in.RemoteIP, in.Helo, in.MailFrom),
+		DKIM: VerifyDKIM(ctx, v.resolver, in.Raw),
+	}
+
+	domains := fromDomains(in.Raw)
+	switch len(domains) {
+	case 0:
+		res.DMARC = &DMARCResult{Result: ResultPermError, Reason: "no from domain"}
+	case 1:
+		res.FromDomain = domains[0]
+		res.DMARC = CheckDMARC(ctx, v.resolver, res.FromDomain, res.SPF, res.DKIM)
+	default:
+		res.FromDomain = domains[0]
+		res.DMARC = &DMARCResult{Result: ResultPermError, Domain: domains[0], Reason: "multiple from domains"}
+	}
+	return res
+}
+
+// addrSpecRe 无法按 RFC 5322 解析 From 时，用于提取地址中的域名
+var addrSpecRe = regexp.MustCompile(`[^\s<>@,;:"()]+@([A-Za-z0-9][A-Za-z0-9.-]*)`)
+
+// fromDomains 返回 From 头中出现的不同域名
+func fromDomains(raw []byte) []string {
+	header, _ := splitMessage(raw)
+	var values []string
+	for _, f := range parseHeaderFields(header) {
+		if strings.EqualFold(f.Name, "From") {
+			values = append(values, strings.TrimSpace(strings.ReplaceAll(fieldValue(f.Raw), "\r\n", "")))
+		}
+	}
+
+	seen := make(map[string]bool)
+	var domains []string
+	add := func(addr string) {
+		at := strings.LastIndex(addr, "@")
+		if at < 0 {
+			return
+		}
+		if d := normalizeDomain(addr[at+1:]); d != "" && !seen[d] {
+			seen[d] = true
+			domains = append(domains, d)
+		}
+	}
+	for _, v := range values {
+		if list, err := mail.ParseAddressList(v); err == nil {
+			for _, a := range list {
+				add(a.Address)
+			}
+			continue
+		}
+		for _, m := range addrSpecRe.FindAllStringSubmatch(v, -1) {
+			add("@" + m[1])
+		}
+	}
+	return domains
+}
@@ -0,0 +1,95 @@
+package mailsync
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"sync"
+	"testing"
+
+	datamodel "plaud-emails/data/model"
+	appconfig "plaud-emails/pkg/config"
+	"plaud-emails/pkg/secretbox"
+	"plaud-emails/service/message"
+)
+
+const testUserID = "u1"
+
+// fakeStore 记录写入的邮件，按 (linkedEmailID, externalID) 去重
+type fakeStore struct {
+	mu     sync.Mutex
+	stored []*message.StoreInput
+	seen   map[string]bool
+	// maxMessages 大于 0 时模拟邮件数配额
+	maxMessages int
+}
+
+func newFakeStore() *fakeStore {
+	return &fakeStore{seen: make(map[string]bool)}
+}
+
+func (f *fakeStore) ExistsExternal(_ context.Context, _ uint64, externalID string) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.seen[externalID], nil
+}
+
+func (f *fakeStore) CheckQuota(_ context.Context, _ string, _ int64) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.maxMessages > 0 && len(f.stored) >= f.maxMessages {
+		return message.ErrQuotaExceeded
+	}
+	return nil
+}
+
+func (f *fakeStore) Store(_ context.Context, in *message.StoreInput) (*datamodel.Message, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.seen[in.ExternalID] = true
+	f.stored = append(f.stored, in)
+	return &datamodel.Message{ID: uint64(len(f.stored)), UserID: in.UserID}, nil
+}
+
+// externalIDs 按写入顺序返回邮件的 ExternalID
+func (f *fakeStore) externalIDs() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	ids := make([]string, 0, len(f.stored))
+	for _, in := range f.stored {
+		ids = append(ids, in.ExternalID)
+	}
+	return ids
+}
+
+// fakeMailboxes 所有用户都有可用的专属邮箱
+type fakeMailboxes struct{}
+
+func (fakeMailboxes) GetByUserID(_ context.Context, userID string) (*datamodel.MindAdvisorUser, error) {
+	return &datamodel.MindAdvisorUser{
+		UserID:         userID,
+		DedicatedEmail: userID + "@myplaud.com",
+		Status:         datamodel.MindAdvisorStatusActive,
+	}, nil
+}
+
+func testSyncConfig() *appconfig.MailSyncConfig {
+	return &appconfig.MailSyncConfig{
+		BatchSize:       appconfig.DefaultMailSyncBatchSize,
+		InitialBackfill: 2,
+		MaxMessageBytes: 1 << 20,
+	}
+}
+
+func testBox(t *testing.T) *secretbox.Box {
+	t.Helper()
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatal(err)
+	}
+	box, err := secretbox.New(base64.StdEncoding.EncodeToString(key))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return box
+}
@@ -0,0 +1,249 @@
+package mailsync
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"plaud-emails/dao"
+	datamodel "plaud-emails/data/model"
+	appconfig "plaud-emails/pkg/config"
+	"plaud-emails/pkg/gmail"
+	"plaud-emails/pkg/oauth2"
+	"plaud-emails/pkg/secretbox"
+	"plaud-emails/service/message"
+
+	"github.com/Plaud-AI/plaud-go-scaffold/pkg/logger"
+
+	"gorm.io/gorm"
+)
+
+// GmailConnector Gmail 绑定与同步，负责 OAuth2 授权及基于 history id 的增量同步
+type GmailConnector struct {
+	conf     *appconfig.MailSyncConfig
+	oauth    *oauth2.Config
+	api      *gmail.Client
+	box      *secretbox.Box
+	messages messageStore
+	userDao  mailboxLookup
+}
+
+var _ Syncer = (*GmailConnector)(nil)
+
+// NewGmailConnector 创建 GmailConnector，gmailConf 为 nil 时返回 nil
+func NewGmailConnector(db *gorm.DB, gmailConf *appconfig.GmailConfig, conf *appconfig.MailSyncConfig, box *secretbox.Box, messages *message.MessageService) *GmailConnector {
+	if gmailConf == nil {
+		return nil
+	}
+	return &GmailConnector{
+		conf: conf,
+		oauth: &oauth2.Config{
+			ClientID:     gmailConf.ClientID,
+			ClientSecret: gmailConf.ClientSecret,
+			AuthURL:      gmailConf.AuthURL,
+			TokenURL:     gmailConf.TokenURL,
+			RevokeURL:    gmailConf.RevokeURL,
+			RedirectURL:  gmailConf.RedirectURL,
+			Scopes:       gmailConf.Scopes,
+		},
+		api:      gmail.NewClient(gmailConf.APIBaseURL),
+		box:      box,
+		messages: messages,
+		userDao:  dao.NewMindAdvisorUserDao(db),
+	}
+}
+
+// Source 实现 Syncer
+func (g *GmailConnector) Source() string {
+	return datamodel.LinkedEmailSourceGmail
+}
+
+// AuthCodeURL 生成授权页面地址，要求离线授权以获得 refresh token
+func (g *GmailConnector) AuthCodeURL(state string) string {
+	return g.oauth.AuthCodeURL(state, map[string]string{
+		"access_type":            "offline",
+		"prompt":                 "consent",
+		"include_granted_scopes": "true",
+	})
+}
+
+// Exchange 用授权码换取令牌
+func (g *GmailConnector) Exchange(ctx context.Context, code string) (*oauth2.Token, error) {
+	return g.oauth.Exchange(ctx, code)
+}
+
+// Identify 查询授权账户的邮箱地址
+func (g *GmailConnector) Identify(ctx context.Context, tok *oauth2.Token) (string, error) {
+	profile, err := g.api.GetProfile(ctx, tok.AccessToken)
+	if err != nil {
+		return "", err
+	}
+	return profile.EmailAddress, nil
+}
+
+// Revoke 撤销授权凭据
+func (g *GmailConnector) Revoke(ctx context.Context, cred *datamodel.OAuthCredential) error {
+	tok, err := OpenOAuthToken(g.box, cred)
+	if err != nil {
+		return err
+	}
+	return g.oauth.Revoke(ctx, tok.RefreshToken)
+}
+
+// Sync 拉取 SyncCursor（history id）之后收件箱新增的邮件
+// 首次同步或 history 过期时回填最近的邮件并以当前 history id 作为新游标
+func (g *GmailConnector) Sync(ctx context.Context, link *datamodel.MindAdvisorLinkedEmail) error {
+	user, err := g.userDao.GetByUserID(ctx, link.UserID)
+	if err != nil {
+		return err
+	}
+	if user == nil || !user.IsActive() {
+		return fmt.Errorf("mailbox of user %s is unavailable", link.UserID)
+	}
+
+	token, err := oauthAccessToken(ctx, g.oauth, g.box, link)
+	if err != nil {
+		return err
+	}
+
+	s := &gmailSync{g: g, link: link, token: token, dedicatedEmail: user.DedicatedEmail}
+	if link.Extra.SyncCursor == "" {
+		err = s.backfill(ctx)
+	} else {
+		err = s.history(ctx)
+		if gmail.IsNotFound(err) {
+			logger.WarnfCtx(ctx, "history of linked email %d expired, resync", link.ID)
+			link.Extra.SyncCursor = ""
+			err = s.backfill(ctx)
+		}
+	}
+	if gmail.IsUnauthorized(err) {
+		expireOAuthToken(link)
+	}
+	if s.stored > 0 {
+		logger.InfofCtx(ctx, "linked email %d synced %d gmail messages, history id %s", link.ID, s.stored, link.Extra.SyncCursor)
+	}
+	return err
+}
+
+// gmailSync 单次同步的状态
+type gmailSync struct {
+	g              *GmailConnector
+	link           *datamodel.MindAdvisorLinkedEmail
+	token          string
+	dedicatedEmail string
+	fetched        int
+	stored         int
+}
+
+// backfill 回填最近的邮件，先取 history id 以免遗漏回填期间到达的邮件
+func (s *gmailSync) backfill(ctx context.Context) error {
+	profile, err := s.g.api.GetProfile(ctx, s.token)
+	if err != nil {
+		return err
+	}
+	ids, err := s.g.api.ListMessageIDs(ctx, s.token, s.g.conf.InitialBackfill)
+	if err != nil {
+		return err
+	}
+	// 列表按时间倒序，从最旧的开始入库
+	for i := len(ids) - 1; i >= 0; i-- {
+		if err := s.syncMessage(ctx, ids[i]); err != nil {
+			return err
+		}
+	}
+	s.link.Extra.SyncCursor = profile.HistoryID
+	return nil
+}
+
+// history 按 history 记录增量同步，每处理完一条记录推进游标
+// 单次同步达到 BatchSize 后停止，剩余部分留到下次
+func (s *gmailSync) history(ctx context.Context) error {
+	// 分页令牌与查询起点绑定，翻页时起点保持不变
+	start := s.link.Extra.SyncCursor
+	var pageToken string
+	for {
+		page, err := s.g.api.ListHistory(ctx, s.token, start, pageToken)
+		if err != nil {
+			return err
+		}
+		for _, h := range page.History {
+			for _, added := range h.MessagesAdded {
+				if !hasLabel(added.Message.LabelIDs, "INBOX") {
+					continue
+				}
+				if err := s.syncMessage(ctx, added.Message.ID); err != nil {
+					return err
+				}
+			}
+			s.link.Extra.SyncCursor = h.ID
+			if s.fetched >= s.g.conf.BatchSize {
+				return nil
+			}
+		}
+		if page.NextPageToken == "" {
+			if page.HistoryID != "" {
+				s.link.Extra.SyncCursor = page.HistoryID
+			}
+			return nil
+		}
+		pageToken = page.NextPageToken
+	}
+}
+
+// syncMessage 拉取并保存一封邮件，已入库或超限的邮件跳过
+func (s *gmailSync) syncMessage(ctx context.Context, id string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	exists, err := s.g.messages.ExistsExternal(ctx, s.link.ID, id)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return nil
+	}
+
+	s.fetched++
+	msg, err := s.g.api.GetRawMessage(ctx, s.token, id, s.g.conf.MaxMessageBytes)
+	if err != nil {
+		if gmail.IsNotFound(err) {
+			// 拉取前已被删除
+			return nil
+		}
+		return err
+	}
+	if msg == nil {
+		logger.WarnfCtx(ctx, "skip oversize gmail message %s of linked email %d", id, s.link.ID)
+		return nil
+	}
+
+	receivedAt := msg.InternalDate
+	if receivedAt.IsZero() {
+		receivedAt = time.Now()
+	}
+	err = storeWithinQuota(ctx, s.g.messages, &message.StoreInput{
+		UserID:         s.link.UserID,
+		DedicatedEmail: s.dedicatedEmail,
+		Source:         datamodel.MessageSourceGmail,
+		LinkedEmailID:  s.link.ID,
+		ExternalID:     id,
+		ReceivedAt:     receivedAt,
+		Raw:            msg.Raw,
+	})
+	if err != nil {
+		return err
+	}
+	s.stored++
+	return nil
+}
+
+func hasLabel(labels []string, label string) bool {
+	for _, l := range labels {
+		if strings.EqualFold(l, label) {
+			return true
+		}
+	}
+	return false
+}
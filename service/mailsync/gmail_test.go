@@ -0,0 +1,312 @@
+package mailsync
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	datamodel "plaud-emails/data/model"
+	appconfig "plaud-emails/pkg/config"
+	"plaud-emails/pkg/oauth2"
+	"plaud-emails/service/message"
+)
+
+// fakeGmail Google 令牌端点与 Gmail API 的桩
+type fakeGmail struct {
+	t *testing.T
+
+	mu           sync.Mutex
+	accessToken  string // API 接受的访问令牌
+	refreshGrant string // 令牌端点返回的错误码，为空时刷新成功
+	refreshes    int
+	historyID    string   // profile 返回的最新 history id
+	inbox        []string // 收件箱邮件 id，按时间倒序
+	minHistory   int      // 早于此值的 startHistoryId 返回 404
+	history      map[string][]historyPage
+}
+
+// historyPage 以 pageToken 区分的 history 分页
+type historyPage struct {
+	token string
+	body  map[string]any
+}
+
+func newFakeGmail(t *testing.T) (*fakeGmail, *httptest.Server) {
+	f := &fakeGmail{t: t, accessToken: "at", historyID: "100", history: make(map[string][]historyPage)}
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+	return f, srv
+}
+
+func (f *fakeGmail) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if r.URL.Path == "/token" {
+		_ = r.ParseForm()
+		f.refreshes++
+		if r.PostForm.Get("grant_type") != "refresh_token" || r.PostForm.Get("refresh_token") != "rt" {
+			f.t.Errorf("unexpected token request %v", r.PostForm)
+		}
+		if f.refreshGrant != "" {
+			w.WriteHeader(http.StatusBadRequest)
+			writeJSON(w, map[string]any{"error": f.refreshGrant})
+			return
+		}
+		f.accessToken = "at" + strconv.Itoa(f.refreshes+1)
+		writeJSON(w, map[string]any{"access_token": f.accessToken, "expires_in": 3600})
+		return
+	}
+
+	if r.Header.Get("Authorization") != "Bearer "+f.accessToken {
+		w.WriteHeader(http.StatusUnauthorized)
+		writeJSON(w, map[string]any{"error": map[string]any{"message": "invalid credentials"}})
+		return
+	}
+	q := r.URL.Query()
+	switch {
+	case r.URL.Path == "/gmail/v1/users/me/profile":
+		writeJSON(w, map[string]any{"emailAddress": "alice@gmail.com", "historyId": f.historyID})
+	case r.URL.Path == "/gmail/v1/users/me/messages":
+		max, _ := strconv.Atoi(q.Get("maxResults"))
+		var list []map[string]any
+		for _, id := range f.inbox {
+			if len(list) < max {
+				list = append(list, map[string]any{"id": id})
+			}
+		}
+		writeJSON(w, map[string]any{"messages": list})
+	case r.URL.Path == "/gmail/v1/users/me/history":
+		start, _ := strconv.Atoi(q.Get("startHistoryId"))
+		if start < f.minHistory {
+			w.WriteHeader(http.StatusNotFound)
+			writeJSON(w, map[string]any{"error": map[string]any{"message": "Requested entity was not found."}})
+			return
+		}
+		for _, p := range f.history[q.Get("startHistoryId")] {
+			if p.token == q.Get("pageToken") {
+				writeJSON(w, p.body)
+				return
+			}
+		}
+		writeJSON(w, map[string]any{"historyId": q.Get("startHistoryId")})
+	case strings.HasPrefix(r.URL.Path, "/gmail/v1/users/me/messages/"):
+		id := strings.TrimPrefix(r.URL.Path, "/gmail/v1/users/me/messages/")
+		raw := "Subject: " + id + "\r\n\r\nbody\r\n"
+		writeJSON(w, map[string]any{
+			"id":           id,
+			"internalDate": "1700000000000",
+			"sizeEstimate": len(raw),
+			"raw":          base64.URLEncoding.EncodeToString([]byte(raw)),
+		})
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// historyAdded 构造一条新增邮件的 history 记录
+func historyAdded(id string, messages map[string][]string) map[string]any {
+	var added []map[string]any
+	for msgID, labels := range messages {
+		added = append(added, map[string]any{"message": map[string]any{"id": msgID, "labelIds": labels}})
+	}
+	return map[string]any{"id": id, "messagesAdded": added}
+}
+
+func newGmailTest(t *testing.T, expiry time.Time) (*fakeGmail, *GmailConnector, *fakeStore, *datamodel.MindAdvisorLinkedEmail) {
+	t.Helper()
+	fake, srv := newFakeGmail(t)
+	box := testBox(t)
+	store := newFakeStore()
+	g := NewGmailConnector(nil, &appconfig.GmailConfig{
+		ClientID:   "client",
+		TokenURL:   srv.URL + "/token",
+		APIBaseURL: srv.URL,
+	}, testSyncConfig(), box, nil)
+	g.messages = store
+	g.userDao = fakeMailboxes{}
+
+	cred, err := SealOAuthToken(box, &oauth2.Token{AccessToken: "at", RefreshToken: "rt", Expiry: expiry})
+	if err != nil {
+		t.Fatal(err)
+	}
+	link := &datamodel.MindAdvisorLinkedEmail{
+		ID:     2,
+		UserID: testUserID,
+		Email:  "alice@gmail.com",
+		Source: datamodel.LinkedEmailSourceGmail,
+		Extra:  &datamodel.LinkedEmailExtra{OAuth: cred},
+	}
+	return fake, g, store, link
+}
+
+func TestGmailSyncFirstSyncBackfills(t *testing.T) {
+	fake, g, store, link := newGmailTest(t, time.Now().Add(time.Hour))
+	fake.inbox = []string{"m3", "m2", "m1"}
+
+	if err := g.Sync(context.Background(), link); err != nil {
+		t.Fatalf("sync: %v", err)
+	}
+	// 回填最近两封，从旧到新入库
+	if got, want := store.externalIDs(), []string{"m2", "m3"}; !slices.Equal(got, want) {
+		t.Fatalf("stored %v, want %v", got, want)
+	}
+	if link.Extra.SyncCursor != "100" {
+		t.Fatalf("cursor = %q, want 100", link.Extra.SyncCursor)
+	}
+	in := store.stored[0]
+	if in.Source != datamodel.MessageSourceGmail || in.LinkedEmailID != link.ID || in.ReceivedAt.UnixMilli() != 1700000000000 {
+		t.Fatalf("unexpected store input %+v", in)
+	}
+}
+
+func TestGmailSyncHistoryIncremental(t *testing.T) {
+	fake, g, store, link := newGmailTest(t, time.Now().Add(time.Hour))
+	link.Extra.SyncCursor = "100"
+	fake.history["100"] = []historyPage{
+		{token: "", body: map[string]any{
+			"history": []map[string]any{
+				historyAdded("101", map[string][]string{"m4": {"INBOX", "UNREAD"}}),
+				historyAdded("102", map[string][]string{"m5": {"SENT"}}),
+			},
+			"nextPageToken": "p2",
+			"historyId":     "105",
+		}},
+		{token: "p2", body: map[string]any{
+			"history":   []map[string]any{historyAdded("103", map[string][]string{"m6": {"INBOX"}})},
+			"historyId": "105",
+		}},
+	}
+
+	if err := g.Sync(context.Background(), link); err != nil {
+		t.Fatalf("sync: %v", err)
+	}
+	if got, want := store.externalIDs(), []string{"m4", "m6"}; !slices.Equal(got, want) {
+		t.Fatalf("stored %v, want %v", got, want)
+	}
+	if link.Extra.SyncCursor != "105" {
+		t.Fatalf("cursor = %q, want 105", link.Extra.SyncCursor)
+	}
+}
+
+func TestGmailSyncExpiredHistoryResyncs(t *testing.T) {
+	fake, g, store, link := newGmailTest(t, time.Now().Add(time.Hour))
+	link.Extra.SyncCursor = "5"
+	fake.minHistory = 50
+	fake.historyID = "120"
+	fake.inbox = []string{"m9", "m8"}
+
+	if err := g.Sync(context.Background(), link); err != nil {
+		t.Fatalf("sync: %v", err)
+	}
+	if got, want := store.externalIDs(), []string{"m8", "m9"}; !slices.Equal(got, want) {
+		t.Fatalf("stored %v, want %v", got, want)
+	}
+	if link.Extra.SyncCursor != "120" {
+		t.Fatalf("cursor = %q, want 120", link.Extra.SyncCursor)
+	}
+}
+
+func TestGmailSyncRefreshesExpiredToken(t *testing.T) {
+	fake, g, _, link := newGmailTest(t, time.Now().Add(-time.Minute))
+	fake.inbox = []string{"m1"}
+
+	if err := g.Sync(context.Background(), link); err != nil {
+		t.Fatalf("sync: %v", err)
+	}
+	if fake.refreshes != 1 {
+		t.Fatalf("refreshes = %d, want 1", fake.refreshes)
+	}
+	tok, err := OpenOAuthToken(g.box, link.Extra.OAuth)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tok.AccessToken != fake.accessToken || tok.RefreshToken != "rt" || !tok.Valid() {
+		t.Fatalf("refreshed credential not stored: %+v", tok)
+	}
+}
+
+func TestGmailSyncUnauthorizedExpiresToken(t *testing.T) {
+	fake, g, _, link := newGmailTest(t, time.Now().Add(time.Hour))
+	fake.accessToken = "rotated"
+
+	if err := g.Sync(context.Background(), link); err == nil {
+		t.Fatal("sync should fail with a rejected access token")
+	}
+	if link.Extra.OAuth.AccessToken != "" || link.Extra.OAuth.Expiry == nil || !link.Extra.OAuth.Expiry.Before(time.Now()) {
+		t.Fatalf("rejected access token should be expired: %+v", link.Extra.OAuth)
+	}
+}
+
+func TestGmailSyncRevokedGrantMarksAuthError(t *testing.T) {
+	fake, g, store, link := newGmailTest(t, time.Now().Add(-time.Minute))
+	fake.refreshGrant = "invalid_grant"
+	link.SyncFailures = 2
+
+	err := g.Sync(context.Background(), link)
+	if !errors.Is(err, ErrRevoked) {
+		t.Fatalf("err = %v, want ErrRevoked", err)
+	}
+	if len(store.stored) != 0 {
+		t.Fatalf("nothing should be stored, got %v", store.externalIDs())
+	}
+
+	s := &Scheduler{conf: testSyncConfig()}
+	out := s.outcome(context.Background(), link, err, time.Now())
+	if out.status != datamodel.LinkedEmailSyncStatusAuthError || out.reason != datamodel.SyncReasonRevoked {
+		t.Fatalf("outcome = %s/%s, want auth_error/revoked", out.status, out.reason)
+	}
+	if !out.deactivate || out.next != nil || out.failures != 3 {
+		t.Fatalf("revoked link should be deactivated and unscheduled: %+v", out)
+	}
+}
+
+func TestGmailSyncPausesWhenQuotaExceeded(t *testing.T) {
+	fake, g, store, link := newGmailTest(t, time.Now().Add(time.Hour))
+	link.Extra.SyncCursor = "100"
+	link.SyncFailures = 1
+	store.maxMessages = 1
+	fake.history["100"] = []historyPage{{token: "", body: map[string]any{
+		"history": []map[string]any{
+			historyAdded("101", map[string][]string{"m4": {"INBOX"}}),
+			historyAdded("102", map[string][]string{"m5": {"INBOX"}}),
+		},
+		"historyId": "105",
+	}}}
+
+	err := g.Sync(context.Background(), link)
+	if !errors.Is(err, message.ErrQuotaExceeded) {
+		t.Fatalf("err = %v, want ErrQuotaExceeded", err)
+	}
+	// 游标停在未入库的邮件之前，恢复后从该邮件继续
+	if got := store.externalIDs(); !slices.Equal(got, []string{"m4"}) {
+		t.Fatalf("stored %v, want [m4]", got)
+	}
+	if link.Extra.SyncCursor != "101" {
+		t.Fatalf("cursor = %q, want 101", link.Extra.SyncCursor)
+	}
+
+	now := time.Now()
+	s := &Scheduler{conf: testSyncConfig()}
+	out := s.outcome(context.Background(), link, err, now)
+	if out.status != datamodel.LinkedEmailSyncStatusBackoff || out.reason != datamodel.SyncReasonQuota {
+		t.Fatalf("outcome = %s/%s, want backoff/quota_exceeded", out.status, out.reason)
+	}
+	if out.failures != 1 || out.next == nil || !out.next.Equal(now.Add(quotaPause)) {
+		t.Fatalf("quota pause should not count as a failure: %+v", out)
+	}
+}
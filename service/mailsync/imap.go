@@ -0,0 +1,200 @@
+package mailsync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"plaud-emails/dao"
+	datamodel "plaud-emails/data/model"
+	appconfig "plaud-emails/pkg/config"
+	"plaud-emails/pkg/imap"
+	"plaud-emails/pkg/secretbox"
+	"plaud-emails/service/message"
+
+	"github.com/Plaud-AI/plaud-go-scaffold/pkg/logger"
+
+	"gorm.io/gorm"
+)
+
+// DefaultIMAPMailbox 默认同步的邮箱目录
+const DefaultIMAPMailbox = "INBOX"
+
+// IMAPSyncer 基于 UIDVALIDITY/UID 的 IMAP 增量同步
+type IMAPSyncer struct {
+	conf     *appconfig.MailSyncConfig
+	box      *secretbox.Box
+	messages messageStore
+	userDao  mailboxLookup
+	// dial 连接并登录账户，测试时可替换
+	dial func(ctx context.Context, acct *datamodel.IMAPAccount, password string) (*imap.Client, error)
+}
+
+var _ Syncer = (*IMAPSyncer)(nil)
+
+// NewIMAPSyncer 创建 IMAPSyncer，box 用于解密账户密码
+func NewIMAPSyncer(db *gorm.DB, conf *appconfig.MailSyncConfig, box *secretbox.Box, messages *message.MessageService) *IMAPSyncer {
+	return &IMAPSyncer{
+		conf:     conf,
+		box:      box,
+		messages: messages,
+		userDao:  dao.NewMindAdvisorUserDao(db),
+		dial: func(ctx context.Context, acct *datamodel.IMAPAccount, password string) (*imap.Client, error) {
+			return DialIMAP(ctx, acct, password, conf.MaxMessageBytes)
+		},
+	}
+}
+
+// Source 实现 Syncer
+func (s *IMAPSyncer) Source() string {
+	return datamodel.LinkedEmailSourceIMAP
+}
+
+// Sync 拉取 LastUID 之后的新邮件写入收件存储
+// UIDVALIDITY 变化时游标失效，按首次同步处理
+func (s *IMAPSyncer) Sync(ctx context.Context, link *datamodel.MindAdvisorLinkedEmail) error {
+	if link.Extra == nil || link.Extra.IMAP == nil {
+		return ErrAccountMissing
+	}
+	acct := link.Extra.IMAP
+	if s.box == nil {
+		return errors.New("credential key not configured")
+	}
+	password, err := s.box.Open(acct.Password)
+	if err != nil {
+		return fmt.Errorf("decrypt imap password failed: %w", err)
+	}
+
+	user, err := s.userDao.GetByUserID(ctx, link.UserID)
+	if err != nil {
+		return err
+	}
+	if user == nil || !user.IsActive() {
+		return fmt.Errorf("mailbox of user %s is unavailable", link.UserID)
+	}
+
+	client, err := s.dial(ctx, acct, string(password))
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	defer client.Logout()
+
+	mailbox := acct.Mailbox
+	if mailbox == "" {
+		mailbox = DefaultIMAPMailbox
+	}
+	status, err := client.Examine(mailbox)
+	if err != nil {
+		return err
+	}
+	if status.UIDValidity != acct.UIDValidity {
+		if acct.UIDValidity != 0 {
+			logger.WarnfCtx(ctx, "linked email %d uidvalidity changed %d -> %d, resync", link.ID, acct.UIDValidity, status.UIDValidity)
+		}
+		acct.UIDValidity = status.UIDValidity
+		acct.LastUID = 0
+	}
+
+	uids, err := client.UIDSearchFrom(acct.LastUID + 1)
+	if err != nil {
+		return err
+	}
+	if acct.LastUID == 0 && len(uids) > s.conf.InitialBackfill {
+		// 首次同步只回填最近的邮件
+		skipped := uids[:len(uids)-s.conf.InitialBackfill]
+		acct.LastUID = skipped[len(skipped)-1]
+		uids = uids[len(skipped):]
+	}
+	if len(uids) > s.conf.BatchSize {
+		uids = uids[:s.conf.BatchSize]
+	}
+
+	var stored int
+	for _, uid := range uids {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		ok, err := s.syncMessage(ctx, client, link, user.DedicatedEmail, uid)
+		if err != nil {
+			return err
+		}
+		if ok {
+			stored++
+		}
+		acct.LastUID = uid
+	}
+
+	if stored > 0 {
+		logger.InfofCtx(ctx, "linked email %d synced %d messages, last uid %d", link.ID, stored, acct.LastUID)
+	}
+	return nil
+}
+
+// syncMessage 拉取并保存一封邮件，已入库或超限的邮件跳过
+func (s *IMAPSyncer) syncMessage(ctx context.Context, client *imap.Client, link *datamodel.MindAdvisorLinkedEmail, dedicatedEmail string, uid uint32) (bool, error) {
+	externalID := fmt.Sprintf("%d:%d", link.Extra.IMAP.UIDValidity, uid)
+	exists, err := s.messages.ExistsExternal(ctx, link.ID, externalID)
+	if err != nil {
+		return false, err
+	}
+	if exists {
+		return false, nil
+	}
+
+	size, err := client.FetchSize(uid)
+	if err != nil {
+		return false, err
+	}
+	if size > s.conf.MaxMessageBytes {
+		logger.WarnfCtx(ctx, "skip oversize message uid %d of linked email %d: %d bytes", uid, link.ID, size)
+		return false, nil
+	}
+
+	raw, err := client.FetchRaw(uid)
+	if err != nil {
+		return false, err
+	}
+	if raw == nil {
+		// 拉取前已被删除
+		return false, nil
+	}
+
+	err = storeWithinQuota(ctx, s.messages, &message.StoreInput{
+		UserID:         link.UserID,
+		DedicatedEmail: dedicatedEmail,
+		Source:         datamodel.MessageSourceIMAP,
+		LinkedEmailID:  link.ID,
+		ExternalID:     externalID,
+		ReceivedAt:     time.Now(),
+		Raw:            raw,
+	})
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// DialIMAP 连接并登录 IMAP 账户，凭据错误时返回 ErrAuthFailed
+func DialIMAP(ctx context.Context, acct *datamodel.IMAPAccount, password string, maxMessageBytes int64) (*imap.Client, error) {
+	addr := net.JoinHostPort(acct.Host, strconv.Itoa(acct.Port))
+	client, err := imap.Dial(ctx, addr, imap.Options{
+		TLS:        acct.TLS,
+		Timeout:    30 * time.Second,
+		MaxLiteral: maxMessageBytes,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("connect imap %s failed: %w", addr, err)
+	}
+	if err := client.Login(acct.Username, password); err != nil {
+		client.Close()
+		if errors.Is(err, imap.ErrAuthFailed) {
+			return nil, fmt.Errorf("%w: %v", ErrAuthFailed, err)
+		}
+		return nil, err
+	}
+	return client, nil
+}
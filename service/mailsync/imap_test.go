@@ -0,0 +1,143 @@
+package mailsync
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	datamodel "plaud-emails/data/model"
+	"plaud-emails/pkg/imap"
+	"plaud-emails/pkg/imap/imaptest"
+)
+
+func newIMAPTest(t *testing.T) (*imaptest.Server, *IMAPSyncer, *fakeStore, *datamodel.MindAdvisorLinkedEmail) {
+	t.Helper()
+	srv, err := imaptest.NewServer()
+	if err != nil {
+		t.Fatalf("start imap server: %v", err)
+	}
+	t.Cleanup(srv.Close)
+
+	box := testBox(t)
+	sealed, err := box.Seal([]byte(srv.Password))
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := newFakeStore()
+	syncer := &IMAPSyncer{
+		conf:     testSyncConfig(),
+		box:      box,
+		messages: store,
+		userDao:  fakeMailboxes{},
+		dial: func(ctx context.Context, acct *datamodel.IMAPAccount, password string) (*imap.Client, error) {
+			client, err := imap.Dial(ctx, srv.Addr(), imap.Options{
+				Timeout:      5 * time.Second,
+				TLSConfig:    srv.ClientTLSConfig(),
+				AllowPrivate: true,
+			})
+			if err != nil {
+				return nil, err
+			}
+			if err := client.Login(acct.Username, password); err != nil {
+				client.Close()
+				return nil, err
+			}
+			return client, nil
+		},
+	}
+	link := &datamodel.MindAdvisorLinkedEmail{
+		ID:     1,
+		UserID: testUserID,
+		Source: datamodel.LinkedEmailSourceIMAP,
+		Extra: &datamodel.LinkedEmailExtra{IMAP: &datamodel.IMAPAccount{
+			Host:     "127.0.0.1",
+			Username: srv.Username,
+			Password: sealed,
+		}},
+	}
+	return srv, syncer, store, link
+}
+
+func imapMessages(uids ...uint32) []imaptest.Message {
+	msgs := make([]imaptest.Message, 0, len(uids))
+	for _, uid := range uids {
+		msgs = append(msgs, imaptest.Message{UID: uid, Raw: []byte(fmt.Sprintf("Subject: %d\r\n\r\nbody\r\n", uid))})
+	}
+	return msgs
+}
+
+func TestIMAPSyncFirstSyncBackfillsRecent(t *testing.T) {
+	srv, syncer, store, link := newIMAPTest(t)
+	srv.Append(imapMessages(1, 2, 5, 9)...)
+
+	if err := syncer.Sync(context.Background(), link); err != nil {
+		t.Fatalf("sync: %v", err)
+	}
+	// InitialBackfill 为 2，只回填最近的两封
+	if got, want := store.externalIDs(), []string{"1:5", "1:9"}; !slices.Equal(got, want) {
+		t.Fatalf("stored %v, want %v", got, want)
+	}
+	acct := link.Extra.IMAP
+	if acct.UIDValidity != 1 || acct.LastUID != 9 {
+		t.Fatalf("cursor = %d/%d, want 1/9", acct.UIDValidity, acct.LastUID)
+	}
+	if store.stored[0].Source != datamodel.MessageSourceIMAP || store.stored[0].DedicatedEmail != "u1@myplaud.com" {
+		t.Fatalf("unexpected store input %+v", store.stored[0])
+	}
+}
+
+func TestIMAPSyncResumesFromLastUID(t *testing.T) {
+	srv, syncer, store, link := newIMAPTest(t)
+	srv.Append(imapMessages(1, 2)...)
+	if err := syncer.Sync(context.Background(), link); err != nil {
+		t.Fatalf("first sync: %v", err)
+	}
+
+	// 没有新邮件时 "n:*" 仍返回最大 UID，不能重复写入
+	if err := syncer.Sync(context.Background(), link); err != nil {
+		t.Fatalf("idle sync: %v", err)
+	}
+	srv.Append(imapMessages(3, 4, 6)...)
+	if err := syncer.Sync(context.Background(), link); err != nil {
+		t.Fatalf("incremental sync: %v", err)
+	}
+	if got, want := store.externalIDs(), []string{"1:1", "1:2", "1:3", "1:4", "1:6"}; !slices.Equal(got, want) {
+		t.Fatalf("stored %v, want %v", got, want)
+	}
+	if link.Extra.IMAP.LastUID != 6 {
+		t.Fatalf("last uid = %d, want 6", link.Extra.IMAP.LastUID)
+	}
+	var searches []string
+	for _, cmd := range srv.Commands() {
+		if strings.HasPrefix(cmd, "UID SEARCH") {
+			searches = append(searches, cmd)
+		}
+	}
+	if want := []string{"UID SEARCH UID 1:*", "UID SEARCH UID 3:*", "UID SEARCH UID 3:*"}; !slices.Equal(searches, want) {
+		t.Fatalf("searches %v, want %v", searches, want)
+	}
+}
+
+func TestIMAPSyncResyncsOnUIDValidityChange(t *testing.T) {
+	srv, syncer, store, link := newIMAPTest(t)
+	srv.Append(imapMessages(1, 2)...)
+	if err := syncer.Sync(context.Background(), link); err != nil {
+		t.Fatalf("first sync: %v", err)
+	}
+
+	// 服务端重建邮箱，UID 从头编号
+	srv.SetUIDValidity(7, imapMessages(1, 2, 3)...)
+	if err := syncer.Sync(context.Background(), link); err != nil {
+		t.Fatalf("resync: %v", err)
+	}
+	if got, want := store.externalIDs(), []string{"1:1", "1:2", "7:2", "7:3"}; !slices.Equal(got, want) {
+		t.Fatalf("stored %v, want %v", got, want)
+	}
+	acct := link.Extra.IMAP
+	if acct.UIDValidity != 7 || acct.LastUID != 3 {
+		t.Fatalf("cursor = %d/%d, want 7/3", acct.UIDValidity, acct.LastUID)
+	}
+}
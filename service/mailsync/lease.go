@@ -0,0 +1,119 @@
+package mailsync
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/Plaud-AI/plaud-go-scaffold/pkg/rdb"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// leaseCache 绑定邮箱同步租约，值为持有者标识，过期时间即租约时长
+var leaseCache = rdb.NewCacheConfig("plaud-emails:mail-sync:lease", 0, false)
+
+var (
+	// acquireLeaseScript 租约空闲或已由自己持有时获取并设置过期时间
+	acquireLeaseScript = rdb.NewScript(`
+local v = redis.call('GET', KEYS[1])
+if v == false or v == ARGV[1] then
+	redis.call('SET', KEYS[1], ARGV[1], 'PX', ARGV[2])
+	return 1
+end
+return 0`)
+
+	// renewLeaseScript 仅持有者可以续期
+	renewLeaseScript = rdb.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('PEXPIRE', KEYS[1], ARGV[2])
+end
+return 0`)
+
+	// releaseLeaseScript 仅持有者可以释放
+	releaseLeaseScript = rdb.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0`)
+)
+
+// Lease 绑定邮箱当前的同步租约
+type Lease struct {
+	Owner string
+	TTL   time.Duration
+}
+
+// leaseManager 基于 Redis 的绑定邮箱同步租约，保证多副本不会同时同步同一账户
+type leaseManager struct {
+	client *rdb.Client
+	owner  string
+	ttl    time.Duration
+}
+
+func (m *leaseManager) key(id uint64) string {
+	return leaseCache.NewParamKey(strconv.FormatUint(id, 10)).Key()
+}
+
+// init 预加载脚本，失败时 RunScript 会回退到 EVAL
+func (m *leaseManager) init(ctx context.Context) error {
+	for _, script := range []*rdb.Script{acquireLeaseScript, renewLeaseScript, releaseLeaseScript} {
+		if err := m.client.InitScriptIfAbsent(ctx, script); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Acquire 尝试获取租约
+func (m *leaseManager) Acquire(ctx context.Context, id uint64) (bool, error) {
+	return m.run(ctx, acquireLeaseScript, id)
+}
+
+// Renew 续期租约，返回 false 表示租约已丢失
+func (m *leaseManager) Renew(ctx context.Context, id uint64) (bool, error) {
+	return m.run(ctx, renewLeaseScript, id)
+}
+
+// Release 释放租约
+func (m *leaseManager) Release(ctx context.Context, id uint64) error {
+	_, err := m.run(ctx, releaseLeaseScript, id)
+	return err
+}
+
+func (m *leaseManager) run(ctx context.Context, script *rdb.Script, id uint64) (bool, error) {
+	n, err := m.client.RunScript(ctx, script, []string{m.key(id)}, m.owner, m.ttl.Milliseconds()).Int()
+	if err != nil {
+		return false, err
+	}
+	return n == 1, nil
+}
+
+// Get 批量查询租约，未被持有的绑定邮箱不在结果中
+func (m *leaseManager) Get(ctx context.Context, ids []uint64) (map[uint64]*Lease, error) {
+	if len(ids) == 0 {
+		return map[uint64]*Lease{}, nil
+	}
+	owners := make([]*redis.StringCmd, len(ids))
+	ttls := make([]*redis.DurationCmd, len(ids))
+	_, err := m.client.GetClient().Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, id := range ids {
+			owners[i] = pipe.Get(ctx, m.key(id))
+			ttls[i] = pipe.PTTL(ctx, m.key(id))
+		}
+		return nil
+	})
+	if err != nil && err != redis.Nil {
+		return nil, err
+	}
+
+	leases := make(map[uint64]*Lease, len(ids))
+	for i, id := range ids {
+		owner, err := owners[i].Result()
+		if err != nil {
+			continue
+		}
+		leases[id] = &Lease{Owner: owner, TTL: ttls[i].Val()}
+	}
+	return leases, nil
+}
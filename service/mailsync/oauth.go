@@ -0,0 +1,102 @@
+package mailsync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	datamodel "plaud-emails/data/model"
+	"plaud-emails/pkg/oauth2"
+	"plaud-emails/pkg/secretbox"
+)
+
+// SealOAuthToken 加密令牌，生成可持久化的授权凭据
+func SealOAuthToken(box *secretbox.Box, tok *oauth2.Token) (*datamodel.OAuthCredential, error) {
+	if box == nil {
+		return nil, errors.New("credential key not configured")
+	}
+	access, err := box.Seal([]byte(tok.AccessToken))
+	if err != nil {
+		return nil, err
+	}
+	refresh, err := box.Seal([]byte(tok.RefreshToken))
+	if err != nil {
+		return nil, err
+	}
+	cred := &datamodel.OAuthCredential{
+		AccessToken:  access,
+		RefreshToken: refresh,
+		Scope:        tok.Scope,
+	}
+	if !tok.Expiry.IsZero() {
+		expiry := tok.Expiry
+		cred.Expiry = &expiry
+	}
+	return cred, nil
+}
+
+// OpenOAuthToken 解密授权凭据
+func OpenOAuthToken(box *secretbox.Box, cred *datamodel.OAuthCredential) (*oauth2.Token, error) {
+	if box == nil {
+		return nil, errors.New("credential key not configured")
+	}
+	tok := &oauth2.Token{Scope: cred.Scope}
+	if cred.AccessToken != "" {
+		access, err := box.Open(cred.AccessToken)
+		if err != nil {
+			return nil, fmt.Errorf("decrypt access token failed: %w", err)
+		}
+		tok.AccessToken = string(access)
+	}
+	refresh, err := box.Open(cred.RefreshToken)
+	if err != nil {
+		return nil, fmt.Errorf("decrypt refresh token failed: %w", err)
+	}
+	tok.RefreshToken = string(refresh)
+	if cred.Expiry != nil {
+		tok.Expiry = *cred.Expiry
+	}
+	return tok, nil
+}
+
+// oauthAccessToken 返回可用的访问令牌，过期时刷新并把新凭据写回 link.Extra
+// 授权被撤销时返回 ErrRevoked
+func oauthAccessToken(ctx context.Context, conf *oauth2.Config, box *secretbox.Box, link *datamodel.MindAdvisorLinkedEmail) (string, error) {
+	if link.Extra == nil || link.Extra.OAuth == nil {
+		return "", ErrAccountMissing
+	}
+	tok, err := OpenOAuthToken(box, link.Extra.OAuth)
+	if err != nil {
+		return "", err
+	}
+	if tok.Valid() {
+		return tok.AccessToken, nil
+	}
+
+	fresh, err := conf.Refresh(ctx, tok.RefreshToken)
+	if err != nil {
+		if oauth2.IsInvalidGrant(err) {
+			return "", fmt.Errorf("%w: %v", ErrRevoked, err)
+		}
+		return "", err
+	}
+	if fresh.Scope == "" {
+		fresh.Scope = tok.Scope
+	}
+	cred, err := SealOAuthToken(box, fresh)
+	if err != nil {
+		return "", err
+	}
+	link.Extra.OAuth = cred
+	return fresh.AccessToken, nil
+}
+
+// expireOAuthToken 丢弃被服务商拒绝的访问令牌，下次同步时强制刷新
+func expireOAuthToken(link *datamodel.MindAdvisorLinkedEmail) {
+	if link.Extra != nil && link.Extra.OAuth != nil {
+		expired := time.Unix(0, 0)
+		link.Extra.OAuth.AccessToken = ""
+		link.Extra.OAuth.Expiry = &expired
+	}
+}
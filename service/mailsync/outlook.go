@@ -0,0 +1,237 @@
+package mailsync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"plaud-emails/dao"
+	datamodel "plaud-emails/data/model"
+	appconfig "plaud-emails/pkg/config"
+	"plaud-emails/pkg/graph"
+	"plaud-emails/pkg/oauth2"
+	"plaud-emails/pkg/secretbox"
+	"plaud-emails/service/message"
+
+	"github.com/Plaud-AI/plaud-go-scaffold/pkg/logger"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// initialSyncWindow 首次同步只回填该时间窗口内收到的邮件
+	initialSyncWindow = 7 * 24 * time.Hour
+	// resyncOverlap delta 令牌失效后全量重新同步时，与上次同步时间重叠的窗口
+	resyncOverlap = 24 * time.Hour
+)
+
+// OutlookConnector Outlook 绑定与同步，负责 OAuth2 授权及基于 Graph delta 查询的增量同步
+type OutlookConnector struct {
+	conf     *appconfig.MailSyncConfig
+	oauth    *oauth2.Config
+	api      *graph.Client
+	box      *secretbox.Box
+	messages messageStore
+	userDao  mailboxLookup
+}
+
+var _ Syncer = (*OutlookConnector)(nil)
+
+// NewOutlookConnector 创建 OutlookConnector，outlookConf 为 nil 时返回 nil
+func NewOutlookConnector(db *gorm.DB, outlookConf *appconfig.OutlookConfig, conf *appconfig.MailSyncConfig, box *secretbox.Box, messages *message.MessageService) *OutlookConnector {
+	if outlookConf == nil {
+		return nil
+	}
+	return &OutlookConnector{
+		conf: conf,
+		oauth: &oauth2.Config{
+			ClientID:     outlookConf.ClientID,
+			ClientSecret: outlookConf.ClientSecret,
+			AuthURL:      outlookConf.AuthURL(),
+			TokenURL:     outlookConf.TokenURL(),
+			RedirectURL:  outlookConf.RedirectURL,
+			Scopes:       outlookConf.Scopes,
+		},
+		api:      graph.NewClient(outlookConf.GraphBaseURL),
+		box:      box,
+		messages: messages,
+		userDao:  dao.NewMindAdvisorUserDao(db),
+	}
+}
+
+// Source 实现 Syncer
+func (o *OutlookConnector) Source() string {
+	return datamodel.LinkedEmailSourceOutlook
+}
+
+// AuthCodeURL 生成授权页面地址
+func (o *OutlookConnector) AuthCodeURL(state string) string {
+	return o.oauth.AuthCodeURL(state, map[string]string{
+		"response_mode": "query",
+		"prompt":        "select_account",
+	})
+}
+
+// Exchange 用授权码换取令牌
+func (o *OutlookConnector) Exchange(ctx context.Context, code string) (*oauth2.Token, error) {
+	return o.oauth.Exchange(ctx, code)
+}
+
+// Identify 查询授权账户的邮箱地址
+func (o *OutlookConnector) Identify(ctx context.Context, tok *oauth2.Token) (string, error) {
+	user, err := o.api.GetMe(ctx, tok.AccessToken)
+	if err != nil {
+		return "", err
+	}
+	return user.Address(), nil
+}
+
+// Revoke 撤销授权凭据，Microsoft 身份平台不提供撤销端点，直接丢弃凭据即可
+func (o *OutlookConnector) Revoke(ctx context.Context, cred *datamodel.OAuthCredential) error {
+	return nil
+}
+
+// Sync 从 SyncCursor（delta/next 链接）继续同步收件箱
+// 无游标或 delta 令牌失效时从头分页全量同步，已入库的邮件按 ExternalID 去重
+func (o *OutlookConnector) Sync(ctx context.Context, link *datamodel.MindAdvisorLinkedEmail) error {
+	user, err := o.userDao.GetByUserID(ctx, link.UserID)
+	if err != nil {
+		return err
+	}
+	if user == nil || !user.IsActive() {
+		return fmt.Errorf("mailbox of user %s is unavailable", link.UserID)
+	}
+
+	token, err := oauthAccessToken(ctx, o.oauth, o.box, link)
+	if err != nil {
+		return err
+	}
+
+	s := &outlookSync{o: o, link: link, token: token, dedicatedEmail: user.DedicatedEmail}
+	if link.Extra.SyncCursor == "" {
+		err = s.resync(ctx)
+	} else {
+		err = s.delta(ctx, link.Extra.SyncCursor)
+		if graph.IsSyncStateExpired(err) {
+			logger.WarnfCtx(ctx, "delta token of linked email %d expired, resync", link.ID)
+			err = s.resync(ctx)
+		}
+	}
+
+	var rateLimit *graph.RateLimitError
+	switch {
+	case errors.As(err, &rateLimit):
+		err = &RetryAfterError{RetryAfter: rateLimit.RetryAfter, Err: err}
+	case graph.IsUnauthorized(err):
+		expireOAuthToken(link)
+	}
+	if s.stored > 0 {
+		logger.InfofCtx(ctx, "linked email %d synced %d outlook messages", link.ID, s.stored)
+	}
+	return err
+}
+
+// outlookSync 单次同步的状态
+type outlookSync struct {
+	o              *OutlookConnector
+	link           *datamodel.MindAdvisorLinkedEmail
+	token          string
+	dedicatedEmail string
+	fetched        int
+	stored         int
+}
+
+// resync 从头全量同步，首次同步只回填最近的邮件，重新同步只补齐上次同步之后的邮件
+// 截止时间随游标一起保存，全量同步跨多次执行时保持不变
+func (s *outlookSync) resync(ctx context.Context) error {
+	since := time.Now().Add(-initialSyncWindow)
+	if s.link.LastSyncAt != nil {
+		since = s.link.LastSyncAt.Add(-resyncOverlap)
+	}
+	s.link.Extra.SyncCursor = ""
+	s.link.Extra.ResyncSince = &since
+	return s.delta(ctx, s.o.api.InboxDeltaURL())
+}
+
+// delta 沿 next 链接逐页同步直到拿到新的 delta 链接
+// 单次同步达到 BatchSize 后在页边界停止，游标指向下一页
+func (s *outlookSync) delta(ctx context.Context, link string) error {
+	since := s.link.Extra.ResyncSince
+	for {
+		page, err := s.o.api.Delta(ctx, s.token, link)
+		if err != nil {
+			return err
+		}
+		for _, m := range page.Messages {
+			if m.Removed != nil {
+				continue
+			}
+			if since != nil && m.ReceivedDateTime != nil && m.ReceivedDateTime.Before(*since) {
+				continue
+			}
+			if err := s.syncMessage(ctx, m); err != nil {
+				return err
+			}
+		}
+		if s.fetched >= s.o.conf.BatchSize && page.NextLink != "" {
+			// 当前页已处理完，从下一页继续
+			s.link.Extra.SyncCursor = page.NextLink
+			return nil
+		}
+		if page.NextLink == "" {
+			s.link.Extra.SyncCursor = page.DeltaLink
+			s.link.Extra.ResyncSince = nil
+			return nil
+		}
+		s.link.Extra.SyncCursor = page.NextLink
+		link = page.NextLink
+	}
+}
+
+// syncMessage 拉取并保存一封邮件，已入库或超限的邮件跳过
+func (s *outlookSync) syncMessage(ctx context.Context, m *graph.DeltaMessage) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	exists, err := s.o.messages.ExistsExternal(ctx, s.link.ID, m.ID)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return nil
+	}
+
+	s.fetched++
+	raw, err := s.o.api.GetMIME(ctx, s.token, m.ID, s.o.conf.MaxMessageBytes)
+	if err != nil {
+		if graph.IsNotFound(err) {
+			// 拉取前已被删除
+			return nil
+		}
+		return err
+	}
+	if raw == nil {
+		logger.WarnfCtx(ctx, "skip oversize outlook message %s of linked email %d", m.ID, s.link.ID)
+		return nil
+	}
+
+	receivedAt := time.Now()
+	if m.ReceivedDateTime != nil {
+		receivedAt = *m.ReceivedDateTime
+	}
+	err = storeWithinQuota(ctx, s.o.messages, &message.StoreInput{
+		UserID:         s.link.UserID,
+		DedicatedEmail: s.dedicatedEmail,
+		Source:         datamodel.MessageSourceOutlook,
+		LinkedEmailID:  s.link.ID,
+		ExternalID:     m.ID,
+		ReceivedAt:     receivedAt,
+		Raw:            raw,
+	})
+	if err != nil {
+		return err
+	}
+	s.stored++
+	return nil
+}
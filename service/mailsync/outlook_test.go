@@ -0,0 +1,260 @@
+package mailsync
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	datamodel "plaud-emails/data/model"
+	appconfig "plaud-emails/pkg/config"
+	"plaud-emails/pkg/oauth2"
+)
+
+const graphDeltaPath = "/v1.0/me/mailFolders/inbox/messages/delta"
+
+// fakeGraph Microsoft Graph 邮件 API 的桩
+// delta 页面按查询参数中的 $skiptoken 或 $deltatoken 区分，无令牌时为全量同步的第一页
+type fakeGraph struct {
+	t   *testing.T
+	url string
+
+	mu         sync.Mutex
+	pages      map[string]map[string]any
+	expired    map[string]int // 已失效的令牌及返回的状态码
+	throttle   string         // 非空时所有请求返回 429，值为 Retry-After
+	deltaCalls []string
+}
+
+func newFakeGraph(t *testing.T) *fakeGraph {
+	f := &fakeGraph{t: t, pages: make(map[string]map[string]any), expired: make(map[string]int)}
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+	f.url = srv.URL
+	return f
+}
+
+func (f *fakeGraph) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if r.Header.Get("Authorization") != "Bearer at" {
+		w.WriteHeader(http.StatusUnauthorized)
+		writeJSON(w, map[string]any{"error": map[string]any{"code": "InvalidAuthenticationToken"}})
+		return
+	}
+	if f.throttle != "" {
+		w.Header().Set("Retry-After", f.throttle)
+		w.WriteHeader(http.StatusTooManyRequests)
+		writeJSON(w, map[string]any{"error": map[string]any{"code": "ApplicationThrottled"}})
+		return
+	}
+	switch {
+	case r.URL.Path == graphDeltaPath:
+		q := r.URL.Query()
+		token := q.Get("$skiptoken") + q.Get("$deltatoken")
+		f.deltaCalls = append(f.deltaCalls, token)
+		if status, ok := f.expired[token]; ok {
+			w.WriteHeader(status)
+			writeJSON(w, map[string]any{"error": map[string]any{"code": "syncStateNotFound", "message": "delta token expired"}})
+			return
+		}
+		page, ok := f.pages[token]
+		if !ok {
+			f.t.Errorf("unexpected delta token %q", token)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		writeJSON(w, page)
+	case strings.HasPrefix(r.URL.Path, "/v1.0/me/messages/") && strings.HasSuffix(r.URL.Path, "/$value"):
+		id := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/v1.0/me/messages/"), "/$value")
+		_, _ = w.Write([]byte("Subject: " + id + "\r\n\r\nbody\r\n"))
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+// page 构造一页 delta 结果，next 与 delta 为下一页或新 delta 链接的令牌
+func (f *fakeGraph) page(next, delta string, messages ...map[string]any) map[string]any {
+	p := map[string]any{"value": messages}
+	if next != "" {
+		p["@odata.nextLink"] = f.url + graphDeltaPath + "?$skiptoken=" + next
+	}
+	if delta != "" {
+		p["@odata.deltaLink"] = f.url + graphDeltaPath + "?$deltatoken=" + delta
+	}
+	return p
+}
+
+func graphMessage(id string, received time.Time) map[string]any {
+	return map[string]any{"id": id, "receivedDateTime": received.UTC().Format(time.RFC3339)}
+}
+
+func newOutlookTest(t *testing.T) (*fakeGraph, *OutlookConnector, *fakeStore, *datamodel.MindAdvisorLinkedEmail) {
+	t.Helper()
+	fake := newFakeGraph(t)
+	box := testBox(t)
+	store := newFakeStore()
+	o := NewOutlookConnector(nil, &appconfig.OutlookConfig{
+		ClientID:     "client",
+		Tenant:       "common",
+		AuthorityURL: fake.url,
+		GraphBaseURL: fake.url,
+	}, testSyncConfig(), box, nil)
+	o.messages = store
+	o.userDao = fakeMailboxes{}
+
+	cred, err := SealOAuthToken(box, &oauth2.Token{AccessToken: "at", RefreshToken: "rt", Expiry: time.Now().Add(time.Hour)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	link := &datamodel.MindAdvisorLinkedEmail{
+		ID:     3,
+		UserID: testUserID,
+		Email:  "bob@outlook.com",
+		Source: datamodel.LinkedEmailSourceOutlook,
+		Extra:  &datamodel.LinkedEmailExtra{OAuth: cred},
+	}
+	return fake, o, store, link
+}
+
+func TestOutlookSyncPagesToDeltaLink(t *testing.T) {
+	fake, o, store, link := newOutlookTest(t)
+	now := time.Now()
+	fake.pages[""] = fake.page("s2", "",
+		graphMessage("m1", now.Add(-time.Hour)),
+		graphMessage("old", now.Add(-30*24*time.Hour)))
+	fake.pages["s2"] = fake.page("", "d1",
+		graphMessage("m2", now.Add(-time.Minute)),
+		map[string]any{"id": "gone", "@removed": map[string]any{"reason": "deleted"}})
+	fake.pages["d1"] = fake.page("", "d2", graphMessage("m3", now))
+
+	// 首次同步沿 nextLink 翻页直到 deltaLink，窗口外与已删除的邮件跳过
+	if err := o.Sync(context.Background(), link); err != nil {
+		t.Fatalf("first sync: %v", err)
+	}
+	if got, want := store.externalIDs(), []string{"m1", "m2"}; !slices.Equal(got, want) {
+		t.Fatalf("stored %v, want %v", got, want)
+	}
+	if want := fake.url + graphDeltaPath + "?$deltatoken=d1"; link.Extra.SyncCursor != want {
+		t.Fatalf("cursor = %q, want %q", link.Extra.SyncCursor, want)
+	}
+	if link.Extra.ResyncSince != nil {
+		t.Fatal("resync window should be cleared after reaching the delta link")
+	}
+
+	// 增量同步从保存的 deltaLink 继续
+	if err := o.Sync(context.Background(), link); err != nil {
+		t.Fatalf("delta sync: %v", err)
+	}
+	if got, want := store.externalIDs(), []string{"m1", "m2", "m3"}; !slices.Equal(got, want) {
+		t.Fatalf("stored %v, want %v", got, want)
+	}
+	if !strings.HasSuffix(link.Extra.SyncCursor, "$deltatoken=d2") {
+		t.Fatalf("cursor = %q, want delta token d2", link.Extra.SyncCursor)
+	}
+}
+
+func TestOutlookSyncStopsAtBatchBoundary(t *testing.T) {
+	fake, o, store, link := newOutlookTest(t)
+	o.conf.BatchSize = 1
+	now := time.Now()
+	fake.pages[""] = fake.page("s2", "", graphMessage("m1", now))
+	fake.pages["s2"] = fake.page("", "d1", graphMessage("m2", now))
+
+	if err := o.Sync(context.Background(), link); err != nil {
+		t.Fatalf("sync: %v", err)
+	}
+	if got := store.externalIDs(); !slices.Equal(got, []string{"m1"}) {
+		t.Fatalf("stored %v, want [m1]", got)
+	}
+	if !strings.HasSuffix(link.Extra.SyncCursor, "$skiptoken=s2") || link.Extra.ResyncSince == nil {
+		t.Fatalf("cursor should point to the next page and keep the resync window: %q", link.Extra.SyncCursor)
+	}
+
+	if err := o.Sync(context.Background(), link); err != nil {
+		t.Fatalf("continue sync: %v", err)
+	}
+	if got := store.externalIDs(); !slices.Equal(got, []string{"m1", "m2"}) {
+		t.Fatalf("stored %v, want [m1 m2]", got)
+	}
+	if !strings.HasSuffix(link.Extra.SyncCursor, "$deltatoken=d1") {
+		t.Fatalf("cursor = %q, want delta token d1", link.Extra.SyncCursor)
+	}
+}
+
+func TestOutlookSyncResyncsOnExpiredDelta(t *testing.T) {
+	for name, status := range map[string]int{"syncStateNotFound": http.StatusBadRequest, "gone": http.StatusGone} {
+		t.Run(name, func(t *testing.T) {
+			fake, o, store, link := newOutlookTest(t)
+			now := time.Now()
+			lastSync := now.Add(-2 * time.Hour)
+			link.LastSyncAt = &lastSync
+			link.Extra.SyncCursor = fake.url + graphDeltaPath + "?$deltatoken=stale"
+			fake.expired["stale"] = status
+			fake.pages[""] = fake.page("", "fresh",
+				graphMessage("recent", now.Add(-3*time.Hour)),
+				graphMessage("synced-long-ago", now.Add(-3*24*time.Hour)))
+
+			if err := o.Sync(context.Background(), link); err != nil {
+				t.Fatalf("sync: %v", err)
+			}
+			if got, want := fake.deltaCalls, []string{"stale", ""}; !slices.Equal(got, want) {
+				t.Fatalf("delta calls %v, want %v", got, want)
+			}
+			// 全量重新同步只补齐上次同步前 resyncOverlap 之后的邮件
+			if got := store.externalIDs(); !slices.Equal(got, []string{"recent"}) {
+				t.Fatalf("stored %v, want [recent]", got)
+			}
+			if !strings.HasSuffix(link.Extra.SyncCursor, "$deltatoken=fresh") {
+				t.Fatalf("cursor = %q, want delta token fresh", link.Extra.SyncCursor)
+			}
+		})
+	}
+}
+
+func TestOutlookSyncRejectsForeignDeltaLink(t *testing.T) {
+	fake, o, _, link := newOutlookTest(t)
+	link.Extra.SyncCursor = "https://attacker.example" + graphDeltaPath + "?$deltatoken=x"
+	fake.pages[""] = fake.page("", "d1")
+
+	if err := o.Sync(context.Background(), link); err != nil {
+		t.Fatalf("sync: %v", err)
+	}
+	if got := fake.deltaCalls; !slices.Equal(got, []string{""}) {
+		t.Fatalf("foreign delta link should trigger a resync from the configured host, calls %v", got)
+	}
+}
+
+func TestOutlookSyncThrottledReturnsRetryAfter(t *testing.T) {
+	fake, o, store, link := newOutlookTest(t)
+	fake.throttle = "7"
+	link.SyncFailures = 1
+
+	err := o.Sync(context.Background(), link)
+	var retryAfter *RetryAfterError
+	if !errors.As(err, &retryAfter) {
+		t.Fatalf("err = %v, want RetryAfterError", err)
+	}
+	if retryAfter.RetryAfter != 7*time.Second {
+		t.Fatalf("retry after %s, want 7s", retryAfter.RetryAfter)
+	}
+	if len(store.stored) != 0 {
+		t.Fatalf("nothing should be stored, got %v", store.externalIDs())
+	}
+
+	now := time.Now()
+	s := &Scheduler{conf: testSyncConfig()}
+	out := s.outcome(context.Background(), link, err, now)
+	if out.status != datamodel.LinkedEmailSyncStatusBackoff || out.reason != datamodel.SyncReasonThrottled {
+		t.Fatalf("outcome = %s/%s, want backoff/throttled", out.status, out.reason)
+	}
+	if out.failures != 1 || out.next == nil || !out.next.Equal(now.Add(7*time.Second)) {
+		t.Fatalf("throttling should not count as a failure and retry after 7s: %+v", out)
+	}
+}
@@ -0,0 +1,484 @@
+package mailsync
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	mathrand "math/rand"
+	"os"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"plaud-emails/dao"
+	datamodel "plaud-emails/data/model"
+	appconfig "plaud-emails/pkg/config"
+	"plaud-emails/service/message"
+
+	"github.com/Plaud-AI/plaud-go-scaffold/pkg/logger"
+	"github.com/Plaud-AI/plaud-go-scaffold/pkg/rdb"
+	"github.com/Plaud-AI/plaud-go-scaffold/pkg/svc"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// backoffBase 首次失败后的退避时间，之后每次失败翻倍
+	backoffBase = time.Minute
+	// leaseMargin 租约在同步超时之外额外保留的时间
+	leaseMargin = 30 * time.Second
+	// intervalJitter 同步间隔的随机抖动比例，避免大量账户集中到期
+	intervalJitter = 0.1
+	// quotaPause 专属邮箱配额已满时暂停同步的时间，用户清理邮件后下次同步自动恢复
+	quotaPause = time.Hour
+)
+
+// 错误定义
+var (
+	// ErrAuthFailed 外部邮箱凭据失效，需要用户重新授权，不会自动重试
+	ErrAuthFailed = errors.New("linked email authentication failed")
+	// ErrRevoked 用户已撤销授权，绑定会被停用并等待重新授权
+	ErrRevoked = errors.New("linked email authorization revoked")
+	// ErrAccountMissing 绑定邮箱缺少同步所需的账户信息
+	ErrAccountMissing = errors.New("linked email account not configured")
+)
+
+// RetryAfterError 服务商限流，RetryAfter 之后才能再次同步
+type RetryAfterError struct {
+	RetryAfter time.Duration
+	Err        error
+}
+
+func (e *RetryAfterError) Error() string {
+	return fmt.Sprintf("throttled, retry after %s: %v", e.RetryAfter, e.Err)
+}
+
+func (e *RetryAfterError) Unwrap() error { return e.Err }
+
+// Syncer 某一类外部邮箱的增量同步实现
+// Sync 负责拉取新邮件并在 link.Extra 中推进同步游标，游标由 Scheduler 持久化
+type Syncer interface {
+	Source() string
+	Sync(ctx context.Context, link *datamodel.MindAdvisorLinkedEmail) error
+}
+
+// Scheduler 外部邮箱同步调度服务
+// 定期挑选 next_sync_at 到期的绑定邮箱，通过 Redis 租约保证同一账户同一时间只在一个副本上同步，
+// 并根据同步结果推进 sync_status 与下次同步时间
+type Scheduler struct {
+	svc.BaseService
+	conf           *appconfig.MailSyncConfig
+	linkedEmailDao *dao.MindAdvisorLinkedEmailDao
+	states         *StateMachine
+	leases         *leaseManager
+	syncers        map[string]Syncer
+	sources        []string
+
+	running sync.Map // 本副本正在同步的绑定邮箱 id
+	active  atomic.Int32
+	sem     chan struct{}
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+}
+
+// NewScheduler 创建 Scheduler，conf 未启用或未配置 Redis 时服务处于禁用状态
+func NewScheduler(db *gorm.DB, redisClient *rdb.Client, conf *appconfig.MailSyncConfig, syncers ...Syncer) *Scheduler {
+	s := &Scheduler{
+		conf:           conf,
+		linkedEmailDao: dao.NewMindAdvisorLinkedEmailDao(db),
+		states:         NewStateMachine(db),
+		syncers:        make(map[string]Syncer, len(syncers)),
+		sem:            make(chan struct{}, conf.Concurrency),
+	}
+	if redisClient != nil {
+		s.leases = &leaseManager{
+			client: redisClient,
+			owner:  newOwnerID(),
+			ttl:    time.Duration(conf.TimeoutSeconds)*time.Second + leaseMargin,
+		}
+	}
+	for _, syncer := range syncers {
+		s.syncers[syncer.Source()] = syncer
+		s.sources = append(s.sources, syncer.Source())
+	}
+	sort.Strings(s.sources)
+	return s
+}
+
+// newOwnerID 生成租约持有者标识，形如 hostname-pid-random
+func newOwnerID() string {
+	host, _ := os.Hostname()
+	b := make([]byte, 4)
+	_, _ = rand.Read(b)
+	return fmt.Sprintf("%s-%d-%s", host, os.Getpid(), hex.EncodeToString(b))
+}
+
+// Enabled 是否启用同步
+func (s *Scheduler) Enabled() bool {
+	return s.conf != nil && s.conf.Enabled && s.leases != nil && len(s.syncers) > 0
+}
+
+// Owner 本副本的租约持有者标识
+func (s *Scheduler) Owner() string {
+	if s.leases == nil {
+		return ""
+	}
+	return s.leases.owner
+}
+
+// Sources 参与调度的绑定邮箱来源
+func (s *Scheduler) Sources() []string {
+	return s.sources
+}
+
+// Init 初始化服务
+func (s *Scheduler) Init(ctx context.Context) error {
+	if s.IsInited() {
+		return nil
+	}
+	if s.Enabled() {
+		if err := s.leases.init(ctx); err != nil {
+			logger.Warnf("load mail sync lease scripts error: %v", err)
+		}
+	}
+	s.SetInited(true)
+	return nil
+}
+
+// Start 启动调度循环
+func (s *Scheduler) Start(ctx context.Context) error {
+	if s.IsStarted() {
+		return nil
+	}
+	if !s.Enabled() {
+		logger.Warnf("mail sync scheduler is disabled")
+		s.SetStarted(true)
+		return nil
+	}
+
+	runCtx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+	s.wg.Add(1)
+	go s.loop(runCtx)
+
+	logger.Infof("start mail sync scheduler %s, poll %ds, concurrency %d",
+		s.leases.owner, s.conf.PollIntervalSeconds, s.conf.Concurrency)
+	s.SetStarted(true)
+	return nil
+}
+
+// Stop 停止调度并等待进行中的同步结束
+func (s *Scheduler) Stop(ctx context.Context) error {
+	if s.IsStopped() {
+		return nil
+	}
+	defer s.SetStopped(true)
+	if s.cancel == nil {
+		return nil
+	}
+
+	s.cancel()
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		logger.Infof("stop mail sync scheduler")
+	case <-ctx.Done():
+		logger.Warnf("stop mail sync scheduler timeout: %v", ctx.Err())
+	}
+	return nil
+}
+
+// loop 定时挑选到期的绑定邮箱
+func (s *Scheduler) loop(ctx context.Context) {
+	defer s.wg.Done()
+	ticker := time.NewTicker(time.Duration(s.conf.PollIntervalSeconds) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		s.dispatch(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// dispatch 按空闲并发数挑选到期的绑定邮箱并异步同步
+func (s *Scheduler) dispatch(ctx context.Context) {
+	free := cap(s.sem) - int(s.active.Load())
+	if free <= 0 {
+		return
+	}
+	// 多取一些，部分账户可能已被其他副本持有
+	links, err := s.linkedEmailDao.ListDueForSync(ctx, s.sources, time.Now(), free*2)
+	if err != nil {
+		if ctx.Err() == nil {
+			logger.Errorf("list due linked emails error: %v", err)
+		}
+		return
+	}
+
+	for _, link := range links {
+		if _, ok := s.running.Load(link.ID); ok {
+			continue
+		}
+		select {
+		case s.sem <- struct{}{}:
+		default:
+			return
+		}
+		s.active.Add(1)
+		s.running.Store(link.ID, time.Now())
+		s.wg.Add(1)
+		go func(id uint64) {
+			defer s.wg.Done()
+			defer func() {
+				s.running.Delete(id)
+				s.active.Add(-1)
+				<-s.sem
+			}()
+			s.SyncOne(ctx, id)
+		}(link.ID)
+	}
+}
+
+// SyncOne 获取租约后同步单个绑定邮箱，并持久化同步状态、游标与下次同步时间
+// 未能获取租约（其他副本正在同步）时直接返回
+func (s *Scheduler) SyncOne(ctx context.Context, id uint64) {
+	ok, err := s.leases.Acquire(ctx, id)
+	if err != nil {
+		logger.Errorf("acquire sync lease of linked email %d error: %v", id, err)
+		return
+	}
+	if !ok {
+		return
+	}
+	defer func() {
+		if err := s.leases.Release(context.WithoutCancel(ctx), id); err != nil {
+			logger.Warnf("release sync lease of linked email %d error: %v", id, err)
+		}
+	}()
+
+	// 持有租约后重新加载，避免使用其他副本刚写回之前的旧游标
+	link, err := s.linkedEmailDao.GetByID(ctx, id)
+	if err != nil {
+		logger.Errorf("get linked email %d for sync error: %v", id, err)
+		return
+	}
+	now := time.Now()
+	if link == nil || !link.IsActive() || !link.Verified || (link.NextSyncAt != nil && link.NextSyncAt.After(now)) {
+		return
+	}
+	from := link.CurrentSyncStatus()
+	if from == datamodel.LinkedEmailSyncStatusAuthError || from == datamodel.LinkedEmailSyncStatusDisabled {
+		return
+	}
+	syncer, ok := s.syncers[link.Source]
+	if !ok {
+		return
+	}
+
+	// 同步期间把下次同步时间推到租约之后，进程崩溃时租约过期即可被重新调度
+	reason := datamodel.SyncReasonStarted
+	if from == datamodel.LinkedEmailSyncStatusSyncing {
+		reason = datamodel.SyncReasonLeaseReset
+	}
+	err = s.states.Apply(ctx, link, &Transition{
+		To:      datamodel.LinkedEmailSyncStatusSyncing,
+		Reason:  reason,
+		Columns: map[string]any{"next_sync_at": now.Add(s.leases.ttl)},
+	})
+	if err != nil {
+		logger.Warnf("start sync of linked email %d error: %v", id, err)
+		return
+	}
+
+	syncCtx, cancel := context.WithTimeout(ctx, time.Duration(s.conf.TimeoutSeconds)*time.Second)
+	defer cancel()
+	var lost atomic.Bool
+	go s.keepLease(syncCtx, cancel, id, &lost)
+
+	err = syncer.Sync(syncCtx, link)
+	if lost.Load() {
+		// 账户已由其他副本接管，不再写回状态
+		return
+	}
+	s.finish(ctx, link, err)
+}
+
+// keepLease 同步期间定期续期租约，租约丢失时取消同步
+func (s *Scheduler) keepLease(ctx context.Context, cancel context.CancelFunc, id uint64, lost *atomic.Bool) {
+	ticker := time.NewTicker(s.leases.ttl / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			ok, err := s.leases.Renew(ctx, id)
+			if err != nil {
+				logger.Warnf("renew sync lease of linked email %d error: %v", id, err)
+				continue
+			}
+			if !ok {
+				logger.Warnf("sync lease of linked email %d lost, cancel sync", id)
+				lost.Store(true)
+				cancel()
+				return
+			}
+		}
+	}
+}
+
+// finish 根据同步结果推进状态机
+func (s *Scheduler) finish(ctx context.Context, link *datamodel.MindAdvisorLinkedEmail, err error) {
+	now := time.Now()
+	if link.Extra == nil {
+		link.Extra = &datamodel.LinkedEmailExtra{}
+	}
+	link.Extra.LastSyncError = ""
+
+	out := s.outcome(ctx, link, err, now)
+	columns := map[string]any{"last_sync_at": now}
+	if out.deactivate {
+		columns["status"] = datamodel.MindAdvisorStatusInactive
+		logger.Infof("linked email %d (%s) authorization revoked, deactivated", link.ID, link.Source)
+	}
+	if err != nil {
+		link.Extra.LastSyncError = err.Error()
+		logger.Warnf("sync linked email %d (%s) error: %v, status %s, failures %d",
+			link.ID, link.Source, err, out.status, out.failures)
+	}
+
+	// 即使同步失败，已入库部分的游标也需要保存
+	columns["sync_failures"] = out.failures
+	columns["next_sync_at"] = out.next
+	columns["extra"] = link.Extra
+	// 停止过程中也要写回状态，避免停留在 syncing
+	err = s.states.Apply(context.WithoutCancel(ctx), link, &Transition{
+		To:      out.status,
+		Reason:  out.reason,
+		Err:     err,
+		Columns: columns,
+	})
+	if errors.Is(err, ErrStaleSyncStatus) {
+		// 同步期间用户重新授权，以新的绑定状态为准
+		logger.Infof("linked email %d sync status changed during sync, drop result", link.ID)
+	} else if err != nil {
+		logger.Errorf("update linked email %d sync status error: %v", link.ID, err)
+	}
+}
+
+// syncOutcome 一次同步结果对应的状态迁移
+type syncOutcome struct {
+	status     datamodel.LinkedEmailSyncStatus
+	reason     string
+	failures   int
+	next       *time.Time // 下次同步时间，为 nil 时停止调度
+	deactivate bool       // 是否停用绑定
+}
+
+// outcome 将同步结果映射为状态迁移
+//
+//	成功           -> ok，按同步间隔调度
+//	限流           -> backoff，Retry-After 之后重试，不计入失败次数
+//	配额已满       -> backoff，暂停 quotaPause 后重试，不计入失败次数
+//	其他失败       -> backoff，按失败次数指数退避
+//	凭据失效       -> auth_error，停止调度直到用户重新授权
+//	授权已撤销     -> auth_error，绑定停用，用户重新授权后恢复
+//	服务停止中断   -> pending，立即重新调度
+func (s *Scheduler) outcome(ctx context.Context, link *datamodel.MindAdvisorLinkedEmail, err error, now time.Time) *syncOutcome {
+	var retryAfter *RetryAfterError
+	switch {
+	case err == nil:
+		at := now.Add(jitter(time.Duration(s.conf.IntervalSeconds) * time.Second))
+		return &syncOutcome{status: datamodel.LinkedEmailSyncStatusOK, reason: datamodel.SyncReasonSucceeded, next: &at}
+	case ctx.Err() != nil && errors.Is(err, context.Canceled):
+		return &syncOutcome{status: datamodel.LinkedEmailSyncStatusPending, reason: datamodel.SyncReasonInterrupted,
+			failures: link.SyncFailures, next: &now}
+	case errors.As(err, &retryAfter):
+		at := now.Add(retryAfter.RetryAfter)
+		return &syncOutcome{status: datamodel.LinkedEmailSyncStatusBackoff, reason: datamodel.SyncReasonThrottled,
+			failures: link.SyncFailures, next: &at}
+	case errors.Is(err, message.ErrQuotaExceeded):
+		at := now.Add(quotaPause)
+		return &syncOutcome{status: datamodel.LinkedEmailSyncStatusBackoff, reason: datamodel.SyncReasonQuota,
+			failures: link.SyncFailures, next: &at}
+	case errors.Is(err, ErrRevoked):
+		return &syncOutcome{status: datamodel.LinkedEmailSyncStatusAuthError, reason: datamodel.SyncReasonRevoked,
+			failures: link.SyncFailures + 1, deactivate: true}
+	case errors.Is(err, ErrAuthFailed):
+		return &syncOutcome{status: datamodel.LinkedEmailSyncStatusAuthError, reason: datamodel.SyncReasonAuthFailed,
+			failures: link.SyncFailures + 1}
+	default:
+		at := now.Add(s.backoff(link.SyncFailures + 1))
+		return &syncOutcome{status: datamodel.LinkedEmailSyncStatusBackoff, reason: datamodel.SyncReasonFailed,
+			failures: link.SyncFailures + 1, next: &at}
+	}
+}
+
+// backoff 第 failures 次连续失败后的退避时间
+func (s *Scheduler) backoff(failures int) time.Duration {
+	max := time.Duration(s.conf.MaxBackoffSeconds) * time.Second
+	d := backoffBase
+	for i := 1; i < failures && d < max; i++ {
+		d *= 2
+	}
+	if d > max {
+		d = max
+	}
+	return d
+}
+
+func jitter(d time.Duration) time.Duration {
+	delta := float64(d) * intervalJitter
+	return d + time.Duration((mathrand.Float64()*2-1)*delta)
+}
+
+// ScheduleEntry 绑定邮箱的调度状态
+type ScheduleEntry struct {
+	Link    *datamodel.MindAdvisorLinkedEmail
+	Lease   *Lease
+	Running bool // 是否正在本副本上同步
+}
+
+// ListSchedule 分页查询绑定邮箱的调度与租约状态，syncStatus 为空时不过滤
+func (s *Scheduler) ListSchedule(ctx context.Context, syncStatus datamodel.LinkedEmailSyncStatus, afterID uint64, limit int) ([]*ScheduleEntry, error) {
+	if len(s.sources) == 0 {
+		return nil, nil
+	}
+	links, err := s.linkedEmailDao.ListSchedule(ctx, s.sources, syncStatus, afterID, limit)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]uint64, 0, len(links))
+	for _, link := range links {
+		ids = append(ids, link.ID)
+	}
+	leases := map[uint64]*Lease{}
+	if s.leases != nil {
+		if leases, err = s.leases.Get(ctx, ids); err != nil {
+			return nil, err
+		}
+	}
+
+	entries := make([]*ScheduleEntry, 0, len(links))
+	for _, link := range links {
+		_, running := s.running.Load(link.ID)
+		entries = append(entries, &ScheduleEntry{Link: link, Lease: leases[link.ID], Running: running})
+	}
+	return entries, nil
+}
+
+// Running 本副本正在同步的绑定邮箱数量
+func (s *Scheduler) Running() int {
+	return int(s.active.Load())
+}
@@ -0,0 +1,137 @@
+package mailsync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"plaud-emails/dao"
+	datamodel "plaud-emails/data/model"
+
+	"gorm.io/gorm"
+)
+
+// 错误定义
+var (
+	// ErrInvalidTransition 状态机不允许的同步状态迁移
+	ErrInvalidTransition = errors.New("invalid sync status transition")
+	// ErrStaleSyncStatus 迁移前同步状态已被其他流程修改
+	ErrStaleSyncStatus = errors.New("sync status changed concurrently")
+)
+
+// syncTransitions 同步状态机允许的迁移
+// 任意状态都可以因重新授权回到 pending；syncing -> syncing 用于接管租约过期的同步
+var syncTransitions = map[datamodel.LinkedEmailSyncStatus][]datamodel.LinkedEmailSyncStatus{
+	datamodel.LinkedEmailSyncStatusNone: {
+		datamodel.LinkedEmailSyncStatusPending,
+	},
+	datamodel.LinkedEmailSyncStatusPending: {
+		datamodel.LinkedEmailSyncStatusPending,
+		datamodel.LinkedEmailSyncStatusSyncing,
+		datamodel.LinkedEmailSyncStatusDisabled,
+	},
+	datamodel.LinkedEmailSyncStatusSyncing: {
+		datamodel.LinkedEmailSyncStatusPending,
+		datamodel.LinkedEmailSyncStatusSyncing,
+		datamodel.LinkedEmailSyncStatusOK,
+		datamodel.LinkedEmailSyncStatusBackoff,
+		datamodel.LinkedEmailSyncStatusAuthError,
+		datamodel.LinkedEmailSyncStatusDisabled,
+	},
+	datamodel.LinkedEmailSyncStatusOK: {
+		datamodel.LinkedEmailSyncStatusPending,
+		datamodel.LinkedEmailSyncStatusSyncing,
+		datamodel.LinkedEmailSyncStatusDisabled,
+	},
+	datamodel.LinkedEmailSyncStatusBackoff: {
+		datamodel.LinkedEmailSyncStatusPending,
+		datamodel.LinkedEmailSyncStatusSyncing,
+		datamodel.LinkedEmailSyncStatusDisabled,
+	},
+	datamodel.LinkedEmailSyncStatusAuthError: {
+		datamodel.LinkedEmailSyncStatusPending,
+		datamodel.LinkedEmailSyncStatusDisabled,
+	},
+	datamodel.LinkedEmailSyncStatusDisabled: {
+		datamodel.LinkedEmailSyncStatusPending,
+	},
+}
+
+// ValidateTransition 校验同步状态迁移是否合法
+func ValidateTransition(from, to datamodel.LinkedEmailSyncStatus) error {
+	for _, allowed := range syncTransitions[from] {
+		if allowed == to {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %q -> %q", ErrInvalidTransition, from, to)
+}
+
+// RecordTransition 校验迁移并写入迁移记录，绑定本身由调用方在同一事务中更新
+func RecordTransition(ctx context.Context, tx *gorm.DB, link *datamodel.MindAdvisorLinkedEmail,
+	from, to datamodel.LinkedEmailSyncStatus, reason string, syncErr error) error {
+	if err := ValidateTransition(from, to); err != nil {
+		return err
+	}
+	event := &datamodel.LinkedEmailSyncEvent{
+		LinkedEmailID: link.ID,
+		UserID:        link.UserID,
+		FromStatus:    from,
+		ToStatus:      to,
+		Reason:        reason,
+	}
+	if syncErr != nil {
+		event.Error = syncErr.Error()
+	}
+	return dao.NewLinkedEmailSyncEventDao(tx).Create(ctx, event)
+}
+
+// Transition 一次同步状态迁移
+type Transition struct {
+	To      datamodel.LinkedEmailSyncStatus
+	Reason  string
+	Err     error
+	Columns map[string]any // 随状态一起更新的其他列
+}
+
+// StateMachine 绑定邮箱同步状态机，负责校验迁移、按当前状态条件更新并记录迁移历史
+type StateMachine struct {
+	linkedEmailDao *dao.MindAdvisorLinkedEmailDao
+}
+
+// NewStateMachine 创建 StateMachine
+func NewStateMachine(db *gorm.DB) *StateMachine {
+	return &StateMachine{linkedEmailDao: dao.NewMindAdvisorLinkedEmailDao(db)}
+}
+
+// Apply 执行迁移，link 的当前状态即迁移起点
+// 数据库中的状态已不是起点时返回 ErrStaleSyncStatus，成功后更新 link.SyncStatus
+func (m *StateMachine) Apply(ctx context.Context, link *datamodel.MindAdvisorLinkedEmail, t *Transition) error {
+	from := link.CurrentSyncStatus()
+	if err := ValidateTransition(from, t.To); err != nil {
+		return err
+	}
+
+	columns := make(map[string]any, len(t.Columns)+1)
+	for k, v := range t.Columns {
+		columns[k] = v
+	}
+	columns["sync_status"] = t.To
+
+	err := m.linkedEmailDao.ExecTx(ctx, func(tx *gorm.DB) error {
+		ok, err := dao.NewMindAdvisorLinkedEmailDao(tx).UpdateColumnsIfSyncStatus(ctx, link.ID, from, columns)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return ErrStaleSyncStatus
+		}
+		return RecordTransition(ctx, tx, link, from, t.To, t.Reason, t.Err)
+	})
+	if err != nil {
+		return err
+	}
+	to := t.To
+	link.SyncStatus = &to
+	return nil
+}
@@ -0,0 +1,36 @@
+package mailsync
+
+import (
+	"context"
+
+	"plaud-emails/dao"
+	datamodel "plaud-emails/data/model"
+	"plaud-emails/service/message"
+)
+
+// messageStore 同步写入收件存储所需的能力，由 *message.MessageService 实现
+type messageStore interface {
+	ExistsExternal(ctx context.Context, linkedEmailID uint64, externalID string) (bool, error)
+	CheckQuota(ctx context.Context, userID string, size int64) error
+	Store(ctx context.Context, in *message.StoreInput) (*datamodel.Message, error)
+}
+
+// mailboxLookup 查询用户专属邮箱，由 *dao.MindAdvisorUserDao 实现
+type mailboxLookup interface {
+	GetByUserID(ctx context.Context, userID string) (*datamodel.MindAdvisorUser, error)
+}
+
+// storeWithinQuota 检查配额后保存邮件，超出配额时返回 message.ErrQuotaExceeded
+// 同步在此中止，游标停在该邮件之前，恢复同步后从该邮件继续
+func storeWithinQuota(ctx context.Context, messages messageStore, in *message.StoreInput) error {
+	if err := messages.CheckQuota(ctx, in.UserID, int64(len(in.Raw))); err != nil {
+		return err
+	}
+	_, err := messages.Store(ctx, in)
+	return err
+}
+
+var (
+	_ messageStore  = (*message.MessageService)(nil)
+	_ mailboxLookup = (*dao.MindAdvisorUserDao)(nil)
+)
@@ -0,0 +1,242 @@
+package message
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"plaud-emails/dao"
+	datamodel "plaud-emails/data/model"
+	"plaud-emails/pkg/textutil"
+	"plaud-emails/service/mimeparse"
+
+	"github.com/Plaud-AI/plaud-go-scaffold/pkg/logger"
+	"github.com/gabriel-vasile/mimetype"
+
+	"gorm.io/gorm"
+)
+
+// ErrAttachmentNotFound 附件不存在
+var ErrAttachmentNotFound = errors.New("attachment not found")
+
+const attachmentContentType = "application/octet-stream"
+
+// ListAttachments 按序号查询用户一封邮件的附件
+func (s *MessageService) ListAttachments(ctx context.Context, userID string, messageID uint64) ([]*datamodel.Attachment, error) {
+	attachments, err := s.attachmentDao.ListByMessageID(ctx, userID, messageID)
+	if err != nil {
+		logger.ErrorfCtx(ctx, "list attachments of message %d error: %v", messageID, err)
+		return nil, err
+	}
+	return attachments, nil
+}
+
+// GetAttachment 获取用户一封邮件的附件，不存在时返回 ErrAttachmentNotFound
+func (s *MessageService) GetAttachment(ctx context.Context, userID string, messageID, id uint64) (*datamodel.Attachment, error) {
+	attachment, err := s.attachmentDao.GetByMessageIDAndID(ctx, userID, messageID, id)
+	if err != nil {
+		logger.ErrorfCtx(ctx, "get attachment error: %v", err)
+		return nil, err
+	}
+	if attachment == nil {
+		return nil, ErrAttachmentNotFound
+	}
+	return attachment, nil
+}
+
+// WriteAttachment 将附件内容写入 w
+func (s *MessageService) WriteAttachment(ctx context.Context, attachment *datamodel.Attachment, w io.Writer) error {
+	if s.storage == nil {
+		return ErrStorageNotConfigured
+	}
+	if _, _, err := s.storage.GetStream(ctx, attachment.S3Bucket, attachment.S3Key, w); err != nil {
+		logger.ErrorfCtx(ctx, "get attachment object %s error: %v", attachment.S3Key, err)
+		return err
+	}
+	return nil
+}
+
+// ExtractAttachments 为附件功能上线前入库的邮件拆分附件，返回处理的邮件数
+func (s *MessageService) ExtractAttachments(ctx context.Context, userID string) (int, error) {
+	if s.storage == nil || s.conf == nil || s.conf.Bucket == "" {
+		return 0, ErrStorageNotConfigured
+	}
+	var count int
+	var afterID uint64
+	for {
+		msgs, err := s.messageDao.ListUnextracted(ctx, userID, afterID, purgeBatchSize)
+		if err != nil {
+			logger.ErrorfCtx(ctx, "list unextracted messages error: %v", err)
+			return count, err
+		}
+		for _, msg := range msgs {
+			afterID = msg.ID
+			parsed, err := s.Parse(ctx, msg)
+			if err != nil {
+				logger.WarnfCtx(ctx, "parse message %d for attachments error: %v", msg.ID, err)
+				continue
+			}
+			err = s.messageDao.ExecTx(ctx, func(tx *gorm.DB) error {
+				if _, err := dao.NewMindAdvisorUserDao(tx).GetByUserIDForUpdate(ctx, userID); err != nil {
+					return err
+				}
+				// 并发执行时其他请求可能已处理该邮件
+				exists, err := dao.NewAttachmentDao(tx).ExistsByMessageID(ctx, msg.ID)
+				if err != nil || exists {
+					return err
+				}
+				return s.saveAttachments(ctx, tx, msg, parsed)
+			})
+			if err != nil {
+				logger.ErrorfCtx(ctx, "extract attachments of message %d error: %v", msg.ID, err)
+				return count, err
+			}
+			count++
+		}
+		if len(msgs) < purgeBatchSize {
+			break
+		}
+	}
+	logger.InfofCtx(ctx, "extracted attachments of %d messages, user: %s", count, userID)
+	return count, nil
+}
+
+// saveAttachments 将解析出的附件写入 S3 并创建记录，需在持有用户行锁的事务中调用
+// 同一用户内容相同的附件只保存一份；没有记录引用的内容总是在锁内重新上传，不会被并发的 removeAttachments 删除
+func (s *MessageService) saveAttachments(ctx context.Context, tx *gorm.DB, msg *datamodel.Message, parsed *mimeparse.Message) error {
+	if parsed == nil || len(parsed.Attachments) == 0 {
+		return nil
+	}
+	attachmentDao := dao.NewAttachmentDao(tx)
+	stored := make(map[string]*datamodel.Attachment, len(parsed.Attachments))
+	rows := make([]*datamodel.Attachment, 0, len(parsed.Attachments))
+	for i, a := range parsed.Attachments {
+		sum := sha256.Sum256(a.Data)
+		digest := hex.EncodeToString(sum[:])
+
+		existing, ok := stored[digest]
+		if !ok {
+			var err error
+			existing, err = attachmentDao.GetBySHA256(ctx, msg.UserID, digest)
+			if err != nil {
+				return err
+			}
+			if existing == nil {
+				existing = &datamodel.Attachment{S3Bucket: s.conf.Bucket, S3Key: s.attachmentKey(msg.UserID, digest)}
+				metadata := map[string]string{"user-id": msg.UserID}
+				if _, err := s.storage.PutStream(ctx, existing.S3Bucket, existing.S3Key, bytes.NewReader(a.Data), attachmentContentType, int64(len(a.Data)), metadata, ""); err != nil {
+					logger.ErrorfCtx(ctx, "put attachment object %s error: %v", existing.S3Key, err)
+					return err
+				}
+			}
+			stored[digest] = existing
+		}
+
+		rows = append(rows, &datamodel.Attachment{
+			UserID:       msg.UserID,
+			MessageID:    msg.ID,
+			PartIndex:    i,
+			Filename:     textutil.Truncate(a.Filename, 255),
+			DeclaredType: textutil.Truncate(a.ContentType, 255),
+			SniffedType:  sniffType(a.Data),
+			Size:         int64(len(a.Data)),
+			ContentID:    textutil.Truncate(a.ContentID, 255),
+			Inline:       a.Inline,
+			SHA256:       digest,
+			S3Bucket:     existing.S3Bucket,
+			S3Key:        existing.S3Key,
+		})
+	}
+	return attachmentDao.CreateBatch(ctx, rows)
+}
+
+// removeAttachments 删除邮件的附件记录，inlineToo 为 false 时保留内嵌附件
+// 需在持有用户行锁的事务中调用，没有其他记录引用的对象在提交前删除
+func (s *MessageService) removeAttachments(ctx context.Context, tx *gorm.DB, userID string, messageID uint64, inlineToo bool) error {
+	attachmentDao := dao.NewAttachmentDao(tx)
+	attachments, err := attachmentDao.ListByMessageID(ctx, userID, messageID)
+	if err != nil || len(attachments) == 0 {
+		return err
+	}
+
+	ids := make([]uint64, 0, len(attachments))
+	var removed []*datamodel.Attachment
+	for _, a := range attachments {
+		if a.Inline && !inlineToo {
+			continue
+		}
+		ids = append(ids, a.ID)
+		removed = append(removed, a)
+	}
+	if err := attachmentDao.DeleteByIDs(ctx, ids); err != nil {
+		return err
+	}
+
+	checked := make(map[string]bool, len(removed))
+	for _, a := range removed {
+		if checked[a.SHA256] {
+			continue
+		}
+		checked[a.SHA256] = true
+		other, err := attachmentDao.GetBySHA256(ctx, userID, a.SHA256)
+		if err != nil {
+			return err
+		}
+		if other != nil || s.storage == nil {
+			continue
+		}
+		// 删除失败只留下无人引用的对象，下次收到相同内容时会被覆盖，不影响记录
+		if err := s.storage.DeleteObject(ctx, a.S3Bucket, a.S3Key); err != nil {
+			logger.ErrorfCtx(ctx, "delete attachment object %s error: %v", a.S3Key, err)
+		}
+	}
+	return nil
+}
+
+// purgeAttachments 删除用户的全部附件对象与记录
+func (s *MessageService) purgeAttachments(ctx context.Context, userID string) error {
+	deleted := make(map[string]bool)
+	var afterID uint64
+	for {
+		attachments, err := s.attachmentDao.ListByUserID(ctx, userID, afterID, purgeBatchSize)
+		if err != nil {
+			logger.ErrorfCtx(ctx, "list attachments for purge error: %v", err)
+			return err
+		}
+		for _, a := range attachments {
+			afterID = a.ID
+			if deleted[a.S3Key] {
+				continue
+			}
+			if err := s.storage.DeleteObject(ctx, a.S3Bucket, a.S3Key); err != nil {
+				logger.ErrorfCtx(ctx, "delete attachment object %s error: %v", a.S3Key, err)
+				return err
+			}
+			deleted[a.S3Key] = true
+		}
+		if len(attachments) < purgeBatchSize {
+			break
+		}
+	}
+	if err := s.attachmentDao.DeleteByUserID(ctx, userID); err != nil {
+		logger.ErrorfCtx(ctx, "delete purged attachments error: %v", err)
+		return err
+	}
+	return nil
+}
+
+// attachmentKey 生成附件对象 key：{prefix}/{user_id}/attachments/{sha256}
+func (s *MessageService) attachmentKey(userID, digest string) string {
+	return fmt.Sprintf("%s/%s/attachments/%s", s.conf.KeyPrefix, userID, digest)
+}
+
+// sniffType 根据内容识别附件类型，只保留媒体类型
+func sniffType(data []byte) string {
+	mediaType, _, _ := strings.Cut(mimetype.Detect(data).String(), ";")
+	return strings.TrimSpace(mediaType)
+}
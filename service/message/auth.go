@@ -0,0 +1,28 @@
+package message
+
+import (
+	datamodel "plaud-emails/data/model"
+	"plaud-emails/pkg/textutil"
+	"plaud-emails/service/mailauth"
+)
+
+// fillAuth 将发信方认证结果写入邮件记录，DKIM 只记录最具代表性的签名，完整结果见原始邮件的 Authentication-Results 头
+func fillAuth(msg *datamodel.Message, res *mailauth.Results) {
+	if res == nil {
+		return
+	}
+	if res.SPF != nil {
+		msg.AuthSPF = string(res.SPF.Result)
+		msg.AuthSPFDomain = textutil.Truncate(res.SPF.Domain, 255)
+	}
+	msg.AuthDKIM = string(mailauth.ResultNone)
+	if d := res.BestDKIM(); d != nil {
+		msg.AuthDKIM = string(d.Result)
+		msg.AuthDKIMDomain = textutil.Truncate(d.Domain, 255)
+	}
+	if res.DMARC != nil {
+		msg.AuthDMARC = string(res.DMARC.Result)
+		msg.AuthDMARCPolicy = res.DMARC.Policy
+	}
+	msg.AuthFromDomain = textutil.Truncate(res.FromDomain, 255)
+}
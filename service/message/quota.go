@@ -0,0 +1,387 @@
+package message
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"plaud-emails/dao"
+	datamodel "plaud-emails/data/model"
+
+	"github.com/Plaud-AI/plaud-go-scaffold/pkg/logger"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// reconcileBatchSize 每轮核算的用户数量
+	reconcileBatchSize = 100
+	// noticeFrom 系统通知邮件的发件人
+	noticeFrom = "Myplaud <noreply@myplaud>"
+)
+
+// ErrQuotaExceeded 邮箱存储用量已达到配额
+var ErrQuotaExceeded = errors.New("mailbox storage quota exceeded")
+
+// Usage 用户的存储用量与所在档位的配额，配额为 0 表示不限制
+type Usage struct {
+	Cohort      string
+	Bytes       int64
+	Messages    int64
+	MaxBytes    int64
+	MaxMessages int64
+	// SoftLimitPercent 用量达到配额的该百分比时提醒用户
+	SoftLimitPercent int
+	WarnedAt         *time.Time
+	ReconciledAt     *time.Time
+}
+
+// Exceeds 再存入一封 size 字节的邮件是否会超出配额
+func (u *Usage) Exceeds(size int64) bool {
+	if u.MaxBytes > 0 && u.Bytes+size > u.MaxBytes {
+		return true
+	}
+	return u.MaxMessages > 0 && u.Messages+1 > u.MaxMessages
+}
+
+// OverSoftLimit 字节数或邮件数是否达到软限额
+func (u *Usage) OverSoftLimit() bool {
+	percent := int64(u.SoftLimitPercent)
+	if u.MaxBytes > 0 && u.Bytes*100 >= u.MaxBytes*percent {
+		return true
+	}
+	return u.MaxMessages > 0 && u.Messages*100 >= u.MaxMessages*percent
+}
+
+// GetUsage 查询用户的存储用量与配额档位
+func (s *MessageService) GetUsage(ctx context.Context, userID string) (*Usage, error) {
+	record, err := s.usageDao.GetByUserID(ctx, userID)
+	if err != nil {
+		logger.ErrorfCtx(ctx, "get mailbox usage error: %v", err)
+		return nil, err
+	}
+	usage, err := s.newUsage(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if record != nil {
+		usage.Bytes = record.Bytes
+		usage.Messages = record.Messages
+		usage.WarnedAt = record.WarnedAt
+		usage.ReconciledAt = record.ReconciledAt
+	}
+	return usage, nil
+}
+
+// newUsage 根据用户的内测批次确定配额档位
+func (s *MessageService) newUsage(ctx context.Context, userID string) (*Usage, error) {
+	reg, err := s.betaRegDao.GetByUserID(ctx, userID)
+	if err != nil {
+		logger.ErrorfCtx(ctx, "get beta registration error: %v", err)
+		return nil, err
+	}
+	cohort := s.quotaConf.DefaultCohort
+	if reg != nil && reg.Cohort != "" {
+		if _, ok := s.quotaConf.Tiers[reg.Cohort]; ok {
+			cohort = reg.Cohort
+		}
+	}
+	tier := s.quotaConf.Tier(cohort)
+	return &Usage{
+		Cohort:           cohort,
+		MaxBytes:         tier.MaxBytes,
+		MaxMessages:      tier.MaxMessages,
+		SoftLimitPercent: s.quotaConf.SoftLimitPercent,
+	}, nil
+}
+
+// CheckQuota 检查用户能否再存入一封 size 字节的邮件，超出配额时返回 ErrQuotaExceeded
+func (s *MessageService) CheckQuota(ctx context.Context, userID string, size int64) error {
+	usage, err := s.GetUsage(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if usage.Exceeds(size) {
+		return ErrQuotaExceeded
+	}
+	return nil
+}
+
+// Delete 删除用户的一封邮件，用量在同一事务中扣减，S3 对象在提交后删除
+// 邮件或用户处于法律保留时返回 ErrLegalHold
+func (s *MessageService) Delete(ctx context.Context, userID string, id uint64) error {
+	msg, err := s.Get(ctx, userID, id)
+	if err != nil {
+		return err
+	}
+	if err := s.checkLegalHold(ctx, msg); err != nil {
+		return err
+	}
+
+	err = s.messageDao.ExecTx(ctx, func(tx *gorm.DB) error {
+		modSeq, err := dao.NewMindAdvisorUserDao(tx).NextModSeq(ctx, userID)
+		if err != nil {
+			return err
+		}
+		deleted, err := dao.NewMessageDao(tx).MarkDeleted(ctx, userID, id, modSeq)
+		if err != nil {
+			return err
+		}
+		if !deleted {
+			return ErrMessageNotFound
+		}
+		if err := s.removeAttachments(ctx, tx, userID, id, true); err != nil {
+			return err
+		}
+		return addUsage(ctx, tx, msg, -msg.Size, -1)
+	})
+	if err != nil {
+		if !errors.Is(err, ErrMessageNotFound) {
+			logger.ErrorfCtx(ctx, "delete message error: %v", err)
+		}
+		return err
+	}
+
+	if s.storage != nil {
+		if err := s.storage.DeleteObject(ctx, msg.S3Bucket, msg.S3Key); err != nil {
+			logger.ErrorfCtx(ctx, "delete message object %s error: %v", msg.S3Key, err)
+		}
+	}
+	s.notifyRemoved(ctx, msg)
+	s.clearWarningIfBelow(ctx, userID)
+	return nil
+}
+
+// addUsage 在事务中累加邮件占用的用量，系统通知不计入配额
+func addUsage(ctx context.Context, tx *gorm.DB, msg *datamodel.Message, bytes, messages int64) error {
+	if msg.Source == datamodel.MessageSourceSystem {
+		return nil
+	}
+	return dao.NewMailboxUsageDao(tx).Add(ctx, msg.UserID, bytes, messages)
+}
+
+// warnIfOverSoftLimit 用量达到软限额时向用户的专属邮箱投递一封提醒，每次超出只提醒一次
+func (s *MessageService) warnIfOverSoftLimit(ctx context.Context, userID, dedicatedEmail string) {
+	usage, err := s.GetUsage(ctx, userID)
+	if err != nil || usage.WarnedAt != nil || !usage.OverSoftLimit() {
+		return
+	}
+	marked, err := s.usageDao.MarkWarned(ctx, userID, time.Now())
+	if err != nil {
+		logger.ErrorfCtx(ctx, "mark mailbox usage warned error: %v", err)
+		return
+	}
+	if !marked {
+		return
+	}
+
+	_, err = s.Store(ctx, &StoreInput{
+		UserID:         userID,
+		DedicatedEmail: dedicatedEmail,
+		Source:         datamodel.MessageSourceSystem,
+		Raw:            quotaNotice(dedicatedEmail, usage),
+	})
+	if err != nil {
+		logger.ErrorfCtx(ctx, "store quota notice for user %s error: %v", userID, err)
+		return
+	}
+	logger.InfofCtx(ctx, "quota soft limit notice sent to user %s, %d bytes / %d messages", userID, usage.Bytes, usage.Messages)
+}
+
+// clearWarningIfBelow 用量回落到软限额以下时清空提醒标记
+func (s *MessageService) clearWarningIfBelow(ctx context.Context, userID string) {
+	usage, err := s.GetUsage(ctx, userID)
+	if err != nil || usage.WarnedAt == nil || usage.OverSoftLimit() {
+		return
+	}
+	if err := s.usageDao.ClearWarned(ctx, userID); err != nil {
+		logger.ErrorfCtx(ctx, "clear mailbox usage warned error: %v", err)
+	}
+}
+
+// quotaNotice 生成软限额提醒邮件
+func quotaNotice(to string, usage *Usage) []byte {
+	var b strings.Builder
+	b.WriteString("From: " + noticeFrom + "\r\n")
+	b.WriteString("To: <" + to + ">\r\n")
+	b.WriteString("Subject: Your mailbox is almost full\r\n")
+	b.WriteString("Date: " + time.Now().Format(time.RFC1123Z) + "\r\n")
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(fmt.Sprintf("Your mailbox has used %s", formatUsage(usage.Bytes, usage.MaxBytes, formatBytes)))
+	b.WriteString(fmt.Sprintf(" and %s.\r\n\r\n", formatUsage(usage.Messages, usage.MaxMessages, formatCount)))
+	b.WriteString("New mail will be rejected once the quota is reached. Delete messages you no longer need to free up space.\r\n")
+	return []byte(b.String())
+}
+
+// formatUsage 格式化用量与配额，配额为 0 时只输出用量
+func formatUsage(used, limit int64, format func(int64) string) string {
+	if limit <= 0 {
+		return format(used)
+	}
+	return fmt.Sprintf("%s of %s (%d%%)", format(used), format(limit), used*100/limit)
+}
+
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+func formatCount(n int64) string {
+	return fmt.Sprintf("%d messages", n)
+}
+
+// Reconcile 根据邮件记录重新核算用户的用量，修正累加产生的偏差
+// verifyObjects 为 true 时逐个检查 S3 对象，对象缺失的邮件被标记为已删除，对象大小与记录不一致时以 S3 为准
+func (s *MessageService) Reconcile(ctx context.Context, userID string, verifyObjects bool) (*Usage, error) {
+	if verifyObjects {
+		if err := s.verifyObjects(ctx, userID); err != nil {
+			return nil, err
+		}
+	}
+
+	err := s.messageDao.ExecTx(ctx, func(tx *gorm.DB) error {
+		// 先锁住用量记录，核算期间入库的邮件在本事务提交后再累加
+		usageDao := dao.NewMailboxUsageDao(tx)
+		before, err := usageDao.GetByUserIDForUpdate(ctx, userID)
+		if err != nil {
+			return err
+		}
+		bytes, count, err := dao.NewMessageDao(tx).SumByUserID(ctx, userID)
+		if err != nil {
+			return err
+		}
+		if before != nil && (before.Bytes != bytes || before.Messages != count) {
+			logger.WarnfCtx(ctx, "mailbox usage of user %s drifted: %d bytes / %d messages, actual %d bytes / %d messages",
+				userID, before.Bytes, before.Messages, bytes, count)
+		}
+		return usageDao.SetUsage(ctx, userID, bytes, count, time.Now())
+	})
+	if err != nil {
+		logger.ErrorfCtx(ctx, "reconcile mailbox usage error: %v", err)
+		return nil, err
+	}
+
+	s.clearWarningIfBelow(ctx, userID)
+	return s.GetUsage(ctx, userID)
+}
+
+// verifyObjects 检查用户邮件的 S3 对象，修正缺失对象与大小不一致的记录
+func (s *MessageService) verifyObjects(ctx context.Context, userID string) error {
+	if s.storage == nil {
+		return ErrStorageNotConfigured
+	}
+	var afterID uint64
+	for {
+		msgs, err := s.messageDao.ListActiveAfterID(ctx, userID, afterID, purgeBatchSize)
+		if err != nil {
+			logger.ErrorfCtx(ctx, "list messages for verify error: %v", err)
+			return err
+		}
+		if len(msgs) == 0 {
+			return nil
+		}
+		for _, msg := range msgs {
+			afterID = msg.ID
+			exists, info, err := s.storage.Exists(ctx, msg.S3Bucket, msg.S3Key)
+			if err != nil {
+				logger.ErrorfCtx(ctx, "stat message object %s error: %v", msg.S3Key, err)
+				return err
+			}
+			switch {
+			case !exists:
+				logger.WarnfCtx(ctx, "message %d of user %s lost its object %s, mark deleted", msg.ID, userID, msg.S3Key)
+				err := s.messageDao.ExecTx(ctx, func(tx *gorm.DB) error {
+					modSeq, err := dao.NewMindAdvisorUserDao(tx).NextModSeq(ctx, userID)
+					if err != nil {
+						return err
+					}
+					if _, err := dao.NewMessageDao(tx).MarkDeleted(ctx, userID, msg.ID, modSeq); err != nil {
+						return err
+					}
+					return s.removeAttachments(ctx, tx, userID, msg.ID, true)
+				})
+				if err != nil {
+					return err
+				}
+				s.notifyRemoved(ctx, msg)
+			case info != nil && info.Size != msg.Size:
+				logger.WarnfCtx(ctx, "message %d of user %s size %d differs from object size %d", msg.ID, userID, msg.Size, info.Size)
+				if err := s.messageDao.UpdateSize(ctx, msg.ID, info.Size); err != nil {
+					return err
+				}
+			}
+		}
+	}
+}
+
+// reconcileLoop 定期核算超过 ReconcileIntervalSeconds 未核算的用量
+func (s *MessageService) reconcileLoop(ctx context.Context, interval time.Duration) {
+	defer s.wg.Done()
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		s.reconcileDue(ctx, interval)
+	}
+}
+
+// reconcileDue 核算一批到期的用量，通过更新 reconciled_at 抢占，避免多副本重复执行
+// 有邮件但还没有用量记录的用户先补建记录，随后作为从未核算的记录优先核算
+func (s *MessageService) reconcileDue(ctx context.Context, interval time.Duration) {
+	s.seedUsages(ctx)
+	now := time.Now()
+	usages, err := s.usageDao.ListReconcileDue(ctx, now.Add(-interval), reconcileBatchSize)
+	if err != nil {
+		if ctx.Err() == nil {
+			logger.Errorf("list mailbox usages due for reconcile error: %v", err)
+		}
+		return
+	}
+	for _, usage := range usages {
+		if ctx.Err() != nil {
+			return
+		}
+		ok, err := s.usageDao.ClaimReconcile(ctx, usage, now)
+		if err != nil {
+			logger.Errorf("claim mailbox usage reconcile of user %s error: %v", usage.UserID, err)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		_, _ = s.Reconcile(ctx, usage.UserID, false)
+	}
+}
+
+// seedUsages 为有邮件但没有用量记录的用户创建空的用量记录
+func (s *MessageService) seedUsages(ctx context.Context) {
+	userIDs, err := s.messageDao.ListUsersWithoutUsage(ctx, reconcileBatchSize)
+	if err != nil {
+		if ctx.Err() == nil {
+			logger.Errorf("list users without mailbox usage error: %v", err)
+		}
+		return
+	}
+	for _, userID := range userIDs {
+		if err := s.usageDao.Add(ctx, userID, 0, 0); err != nil {
+			logger.Errorf("seed mailbox usage of user %s error: %v", userID, err)
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package message
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"time"
+
+	"plaud-emails/dao"
+	datamodel "plaud-emails/data/model"
+	"plaud-emails/service/mimeparse"
+
+	"github.com/Plaud-AI/plaud-go-scaffold/pkg/logger"
+
+	"gorm.io/gorm"
+)
+
+// SetLegalHold 设置或解除用户一封邮件的法律保留，保留期间邮件不会被删除
+func (s *MessageService) SetLegalHold(ctx context.Context, userID string, id uint64, hold bool) error {
+	found, err := s.messageDao.SetLegalHold(ctx, userID, id, hold)
+	if err != nil {
+		logger.ErrorfCtx(ctx, "set message legal hold error: %v", err)
+		return err
+	}
+	if !found {
+		return ErrMessageNotFound
+	}
+	logger.InfofCtx(ctx, "legal hold of message %d of user %s set to %t", id, userID, hold)
+	return nil
+}
+
+// checkLegalHold 邮件或所属用户处于法律保留时返回 ErrLegalHold
+func (s *MessageService) checkLegalHold(ctx context.Context, msg *datamodel.Message) error {
+	if msg.LegalHold {
+		return ErrLegalHold
+	}
+	user, err := s.userDao.GetByUserID(ctx, msg.UserID)
+	if err != nil {
+		logger.ErrorfCtx(ctx, "get mind advisor user error: %v", err)
+		return err
+	}
+	if user != nil && user.LegalHold {
+		return ErrLegalHold
+	}
+	return nil
+}
+
+// Expire 清除保留期到期的邮件：记录标记为墓碑并扣减用量，提交后删除 S3 对象
+// 返回 false 表示邮件已被其他副本清除、已删除或处于法律保留
+func (s *MessageService) Expire(ctx context.Context, msg *datamodel.Message) (bool, error) {
+	if s.storage == nil {
+		return false, ErrStorageNotConfigured
+	}
+
+	var expired bool
+	err := s.messageDao.ExecTx(ctx, func(tx *gorm.DB) error {
+		modSeq, err := dao.NewMindAdvisorUserDao(tx).NextModSeq(ctx, msg.UserID)
+		if err != nil {
+			return err
+		}
+		ok, err := dao.NewMessageDao(tx).MarkExpired(ctx, msg.ID, time.Now(), modSeq)
+		if err != nil || !ok {
+			return err
+		}
+		expired = true
+		if err := s.removeAttachments(ctx, tx, msg.UserID, msg.ID, true); err != nil {
+			return err
+		}
+		return addUsage(ctx, tx, msg, -msg.Size, -1)
+	})
+	if err != nil {
+		logger.ErrorfCtx(ctx, "expire message %d error: %v", msg.ID, err)
+		return false, err
+	}
+	if !expired {
+		return false, nil
+	}
+	s.notifyRemoved(ctx, msg)
+
+	if err := s.storage.DeleteObject(ctx, msg.S3Bucket, msg.S3Key); err != nil {
+		// 记录已是墓碑，不会再次被清理，对象删除失败只能记录日志
+		logger.ErrorfCtx(ctx, "delete expired message object %s error: %v", msg.S3Key, err)
+		return true, err
+	}
+	return true, nil
+}
+
+// StripAttachments 从原始邮件中移除附件：写入移除附件后的新对象，记录指向新对象后删除旧对象
+// 返回释放的字节数，0 表示没有可移除的附件或邮件已被其他副本处理
+func (s *MessageService) StripAttachments(ctx context.Context, msg *datamodel.Message) (int64, error) {
+	if s.storage == nil {
+		return 0, ErrStorageNotConfigured
+	}
+
+	raw, err := s.ReadRaw(ctx, msg)
+	if err != nil {
+		return 0, err
+	}
+	stripped, removed, err := mimeparse.StripAttachments(raw, attachmentNotice)
+	if err != nil {
+		logger.WarnfCtx(ctx, "strip attachments of message %d error: %v", msg.ID, err)
+		return 0, err
+	}
+
+	now := time.Now()
+	if len(removed) == 0 {
+		// 没有可移除的附件（如顶层即为附件的邮件），只记录处理时间，避免每轮重复读取
+		if err := s.messageDao.MarkAttachmentsPurged(ctx, msg.ID, now); err != nil {
+			logger.ErrorfCtx(ctx, "mark attachments purged of message %d error: %v", msg.ID, err)
+			return 0, err
+		}
+		return 0, nil
+	}
+
+	size := int64(len(stripped))
+	newKey := s.objectKey(msg.UserID, msg.ReceivedAt)
+
+	metadata := map[string]string{"user-id": msg.UserID}
+	if _, err := s.storage.PutStream(ctx, msg.S3Bucket, newKey, bytes.NewReader(stripped), rawContentType, size, metadata, ""); err != nil {
+		logger.ErrorfCtx(ctx, "put stripped message object %s error: %v", newKey, err)
+		return 0, err
+	}
+
+	var replaced bool
+	err = s.messageDao.ExecTx(ctx, func(tx *gorm.DB) error {
+		// 附件变化需要增量同步的客户端感知，NextModSeq 同时锁定用户记录
+		modSeq, err := dao.NewMindAdvisorUserDao(tx).NextModSeq(ctx, msg.UserID)
+		if err != nil {
+			return err
+		}
+		ok, err := dao.NewMessageDao(tx).ReplaceStrippedObject(ctx, msg.ID, msg.S3Key, newKey, size, now, modSeq)
+		if err != nil || !ok {
+			return err
+		}
+		replaced = true
+		// 拆分出的附件同样移除，内嵌附件随原始邮件保留
+		if err := s.removeAttachments(ctx, tx, msg.UserID, msg.ID, false); err != nil {
+			return err
+		}
+		return addUsage(ctx, tx, msg, size-msg.Size, 0)
+	})
+	if err != nil || !replaced {
+		if err != nil {
+			logger.ErrorfCtx(ctx, "replace stripped message object of %d error: %v", msg.ID, err)
+		}
+		// 新对象没有被引用，删除避免产生孤儿对象
+		if delErr := s.storage.DeleteObject(ctx, msg.S3Bucket, newKey); delErr != nil {
+			logger.ErrorfCtx(ctx, "delete orphan message object %s error: %v", newKey, delErr)
+		}
+		return 0, err
+	}
+
+	if err := s.storage.DeleteObject(ctx, msg.S3Bucket, msg.S3Key); err != nil {
+		logger.ErrorfCtx(ctx, "delete original message object %s error: %v", msg.S3Key, err)
+	}
+	return msg.Size - size, nil
+}
+
+// attachmentNotice 替换被移除附件的说明文字
+func attachmentNotice(a *mimeparse.StrippedAttachment) string {
+	name := a.Filename
+	if name == "" {
+		name = a.ContentType
+	}
+	return fmt.Sprintf("[Attachment %q (%s) was removed by the retention policy of this mailbox.]", name, formatBytes(a.Size))
+}
@@ -0,0 +1,505 @@
+package message
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"plaud-emails/dao"
+	datamodel "plaud-emails/data/model"
+	appconfig "plaud-emails/pkg/config"
+	"plaud-emails/pkg/textutil"
+	"plaud-emails/service/mailauth"
+	"plaud-emails/service/mimeparse"
+
+	"github.com/Plaud-AI/plaud-go-scaffold/pkg/aws"
+	"github.com/Plaud-AI/plaud-go-scaffold/pkg/logger"
+	"github.com/Plaud-AI/plaud-go-scaffold/pkg/svc"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// DefaultListLimit 列表默认分页大小
+	DefaultListLimit = 20
+	// MaxListLimit 列表最大分页大小
+	MaxListLimit = 100
+
+	rawContentType = "message/rfc822"
+	snippetRunes   = 140
+	purgeBatchSize = 100
+	// maxReferences 索引中保留的 References 数量，超出时保留首个（会话根）与最近的引用
+	maxReferences = 20
+)
+
+// 错误定义
+var (
+	ErrStorageNotConfigured = errors.New("message storage not configured")
+	ErrMessageNotFound      = errors.New("message not found")
+	ErrLegalHold            = errors.New("message is under legal hold")
+)
+
+// ObjectStorage 邮件对象存储，*aws.S3 实现了该接口
+type ObjectStorage interface {
+	PutStream(ctx context.Context, bucket, key string, body io.Reader, contentType string, contentLength int64, metadata map[string]string, contentEncoding string) (*aws.S3ObjectInfo, error)
+	GetStream(ctx context.Context, bucket, key string, w io.Writer) (int64, *aws.S3ObjectInfo, error)
+	Exists(ctx context.Context, bucket, key string) (bool, *aws.S3ObjectInfo, error)
+	DeleteObject(ctx context.Context, bucket, key string) error
+}
+
+// StoredListener 邮件入库后的回调，parsed 在邮件无法解析时为 nil
+type StoredListener interface {
+	OnMessageStored(ctx context.Context, msg *datamodel.Message, parsed *mimeparse.Message)
+}
+
+// RemovedListener 邮件被删除或保留期到期清除后的回调
+type RemovedListener interface {
+	OnMessageRemoved(ctx context.Context, msg *datamodel.Message)
+}
+
+// FolderListener 邮件被用户移动到系统文件夹后的回调，ids 为实际更新的邮件
+type FolderListener interface {
+	OnFolderChanged(ctx context.Context, userID string, ids []uint64, folder string)
+}
+
+// MessageService 收件存储服务
+type MessageService struct {
+	svc.BaseService
+	messageDao *dao.MessageDao
+	usageDao   *dao.MailboxUsageDao
+	userDao    *dao.MindAdvisorUserDao
+	betaRegDao *dao.BetaInviteRegistrationDao
+	labelDao   *dao.UserLabelDao
+	// messageLabelDao 邮件与自定义标签的关联
+	messageLabelDao *dao.MessageLabelDao
+	attachmentDao   *dao.AttachmentDao
+	storage         ObjectStorage
+	conf            *appconfig.MessageStoreConfig
+	quotaConf       *appconfig.QuotaConfig
+	listeners       []StoredListener
+	removed         []RemovedListener
+	folderListeners []FolderListener
+	spamScorer      SpamScorer
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// New 创建 MessageService，storage 为 nil 时无法写入新邮件
+func New(db *gorm.DB, storage ObjectStorage, conf *appconfig.MessageStoreConfig, quotaConf *appconfig.QuotaConfig) *MessageService {
+	return &MessageService{
+		messageDao:      dao.NewMessageDao(db),
+		usageDao:        dao.NewMailboxUsageDao(db),
+		userDao:         dao.NewMindAdvisorUserDao(db),
+		betaRegDao:      dao.NewBetaInviteRegistrationDao(db),
+		labelDao:        dao.NewUserLabelDao(db),
+		messageLabelDao: dao.NewMessageLabelDao(db),
+		attachmentDao:   dao.NewAttachmentDao(db),
+		storage:         storage,
+		conf:            conf,
+		quotaConf:       quotaConf,
+	}
+}
+
+// AddStoredListener 注册入库回调，需在服务启动前调用
+func (s *MessageService) AddStoredListener(l StoredListener) {
+	s.listeners = append(s.listeners, l)
+}
+
+// AddRemovedListener 注册删除回调，需在服务启动前调用
+func (s *MessageService) AddRemovedListener(l RemovedListener) {
+	s.removed = append(s.removed, l)
+}
+
+// AddFolderListener 注册文件夹变更回调，需在服务启动前调用
+func (s *MessageService) AddFolderListener(l FolderListener) {
+	s.folderListeners = append(s.folderListeners, l)
+}
+
+// notifyRemoved 通知邮件已被删除
+func (s *MessageService) notifyRemoved(ctx context.Context, msg *datamodel.Message) {
+	for _, l := range s.removed {
+		l.OnMessageRemoved(ctx, msg)
+	}
+}
+
+// StoreInput 存储邮件的输入
+type StoreInput struct {
+	UserID         string
+	DedicatedEmail string
+	Alias          string
+	Tag            string
+	Label          string
+	Muted          bool
+	Source         string
+	EnvelopeFrom   string
+	RemoteIP       string
+	Helo           string
+	LinkedEmailID  uint64
+	// ExternalID 外部邮箱中的邮件 id；SMTP 收信时为投递幂等键
+	ExternalID string
+	ReceivedAt time.Time
+	Raw        []byte
+	// Auth 收信时的 SPF、DKIM 与 DMARC 检查结果，未检查时为 nil
+	Auth *mailauth.Results
+}
+
+// Store 保存一封邮件：原始 MIME 写入 S3，头部索引写入 MySQL，附件拆分后按内容去重另存，存储用量在同一事务中累加（系统通知不计入）
+// 设置了垃圾邮件评分时，判定为垃圾邮件的放入 spam 文件夹
+// Store 本身不检查配额，由调用方通过 CheckQuota 决定是否接收
+func (s *MessageService) Store(ctx context.Context, in *StoreInput) (*datamodel.Message, error) {
+	if s.storage == nil || s.conf == nil || s.conf.Bucket == "" {
+		return nil, ErrStorageNotConfigured
+	}
+
+	receivedAt := in.ReceivedAt
+	if receivedAt.IsZero() {
+		receivedAt = time.Now()
+	}
+
+	msg := &datamodel.Message{
+		UserID:         in.UserID,
+		DedicatedEmail: in.DedicatedEmail,
+		Alias:          in.Alias,
+		Tag:            in.Tag,
+		Label:          in.Label,
+		Muted:          in.Muted,
+		Folder:         ruleFolder(in.Label),
+		Source:         in.Source,
+		EnvelopeFrom:   textutil.Truncate(in.EnvelopeFrom, 512),
+		RemoteIP:       in.RemoteIP,
+		LinkedEmailID:  in.LinkedEmailID,
+		ExternalID:     in.ExternalID,
+		Size:           int64(len(in.Raw)),
+		S3Bucket:       s.conf.Bucket,
+		S3Key:          s.objectKey(in.UserID, receivedAt),
+		Status:         datamodel.MessageStatusActive,
+		ReceivedAt:     receivedAt,
+	}
+	parsed := fillIndex(ctx, msg, in.Raw)
+	fillAuth(msg, in.Auth)
+	s.scoreSpam(ctx, msg, in, parsed)
+
+	metadata := map[string]string{"user-id": in.UserID}
+	if _, err := s.storage.PutStream(ctx, msg.S3Bucket, msg.S3Key, bytes.NewReader(in.Raw), rawContentType, msg.Size, metadata, ""); err != nil {
+		logger.ErrorfCtx(ctx, "put message object %s error: %v", msg.S3Key, err)
+		return nil, err
+	}
+
+	labelID := s.ruleLabelID(ctx, msg.UserID, msg.Label)
+	err := s.messageDao.ExecTx(ctx, func(tx *gorm.DB) error {
+		modSeq, err := dao.NewMindAdvisorUserDao(tx).NextModSeq(ctx, msg.UserID)
+		if err != nil {
+			return err
+		}
+		msg.ModSeq = modSeq
+		if err := dao.NewMessageDao(tx).Create(ctx, msg); err != nil {
+			return err
+		}
+		if labelID > 0 {
+			if err := dao.NewMessageLabelDao(tx).Add(ctx, msg.UserID, []uint64{msg.ID}, []uint64{labelID}); err != nil {
+				return err
+			}
+		}
+		if err := s.saveAttachments(ctx, tx, msg, parsed); err != nil {
+			return err
+		}
+		return addUsage(ctx, tx, msg, msg.Size, 1)
+	})
+	if err != nil {
+		logger.ErrorfCtx(ctx, "create message error: %v", err)
+		// 回滚已上传的对象，避免产生孤儿对象
+		if delErr := s.storage.DeleteObject(ctx, msg.S3Bucket, msg.S3Key); delErr != nil {
+			logger.ErrorfCtx(ctx, "delete orphan message object %s error: %v", msg.S3Key, delErr)
+		}
+		return nil, err
+	}
+
+	for _, l := range s.listeners {
+		l.OnMessageStored(ctx, msg, parsed)
+	}
+	if msg.Source != datamodel.MessageSourceSystem {
+		s.warnIfOverSoftLimit(ctx, msg.UserID, msg.DedicatedEmail)
+	}
+	return msg, nil
+}
+
+// ExistsExternal 检查外部邮箱同步的邮件是否已入库，用于同步去重
+func (s *MessageService) ExistsExternal(ctx context.Context, linkedEmailID uint64, externalID string) (bool, error) {
+	exists, err := s.messageDao.ExistsByExternalID(ctx, linkedEmailID, externalID)
+	if err != nil {
+		logger.ErrorfCtx(ctx, "check external message error: %v", err)
+		return false, err
+	}
+	return exists, nil
+}
+
+// DeliveredSince 检查 since 之后是否已保存过相同投递幂等键（StoreInput.ExternalID）的收信邮件
+func (s *MessageService) DeliveredSince(ctx context.Context, userID, key string, since time.Time) (bool, error) {
+	exists, err := s.messageDao.ExistsDelivered(ctx, userID, key, since)
+	if err != nil {
+		logger.ErrorfCtx(ctx, "check delivered message error: %v", err)
+		return false, err
+	}
+	return exists, nil
+}
+
+// List 按 id 倒序分页查询用户的邮件，返回下一页游标（0 表示没有更多）
+func (s *MessageService) List(ctx context.Context, userID string, filter *dao.MessageFilter, cursor uint64, limit int) ([]*datamodel.Message, uint64, error) {
+	if limit <= 0 {
+		limit = DefaultListLimit
+	}
+	if limit > MaxListLimit {
+		limit = MaxListLimit
+	}
+
+	msgs, err := s.messageDao.ListByUserID(ctx, userID, filter, cursor, limit+1)
+	if err != nil {
+		logger.ErrorfCtx(ctx, "list messages error: %v", err)
+		return nil, 0, err
+	}
+
+	var next uint64
+	if len(msgs) > limit {
+		msgs = msgs[:limit]
+		next = msgs[limit-1].ID
+	}
+	return msgs, next, nil
+}
+
+// Get 获取用户的一封邮件，不存在时返回 ErrMessageNotFound
+func (s *MessageService) Get(ctx context.Context, userID string, id uint64) (*datamodel.Message, error) {
+	msg, err := s.messageDao.GetByUserIDAndID(ctx, userID, id)
+	if err != nil {
+		logger.ErrorfCtx(ctx, "get message error: %v", err)
+		return nil, err
+	}
+	if msg == nil {
+		return nil, ErrMessageNotFound
+	}
+	return msg, nil
+}
+
+// WriteRaw 将原始邮件写入 w
+func (s *MessageService) WriteRaw(ctx context.Context, msg *datamodel.Message, w io.Writer) error {
+	if s.storage == nil {
+		return ErrStorageNotConfigured
+	}
+	if _, _, err := s.storage.GetStream(ctx, msg.S3Bucket, msg.S3Key, w); err != nil {
+		logger.ErrorfCtx(ctx, "get message object %s error: %v", msg.S3Key, err)
+		return err
+	}
+	return nil
+}
+
+// ReadRaw 读取完整的原始邮件
+func (s *MessageService) ReadRaw(ctx context.Context, msg *datamodel.Message) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.Grow(int(msg.Size))
+	if err := s.WriteRaw(ctx, msg, &buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Parse 读取并解析邮件，返回规范化后的正文与附件清单
+func (s *MessageService) Parse(ctx context.Context, msg *datamodel.Message) (*mimeparse.Message, error) {
+	raw, err := s.ReadRaw(ctx, msg)
+	if err != nil {
+		return nil, err
+	}
+	parsed, err := mimeparse.Parse(raw)
+	if err != nil {
+		logger.ErrorfCtx(ctx, "parse message %d error: %v", msg.ID, err)
+		return nil, err
+	}
+	return parsed, nil
+}
+
+// FillReferences 重新解析邮件并回填 In-Reply-To 与 References 索引，用于会话功能上线前入库的邮件
+func (s *MessageService) FillReferences(ctx context.Context, msg *datamodel.Message) error {
+	parsed, err := s.Parse(ctx, msg)
+	if err != nil {
+		return err
+	}
+	msg.InReplyTo = textutil.Truncate(parsed.InReplyTo, 255)
+	msg.ReferenceIDs = joinReferences(parsed.References)
+	if err := s.messageDao.UpdateReferences(ctx, msg.ID, msg.InReplyTo, msg.ReferenceIDs); err != nil {
+		logger.ErrorfCtx(ctx, "update references of message %d error: %v", msg.ID, err)
+		return err
+	}
+	return nil
+}
+
+// PurgeUser 清除用户的全部邮件：先删除 S3 对象再删除索引，失败时可重复执行
+// 仍有设置了法律保留的邮件时保留这些邮件及附件、标签与用量，返回 ErrLegalHold，由调用方稍后重试
+// 实现 mindadvisor.MailboxPurger
+func (s *MessageService) PurgeUser(ctx context.Context, userID string) error {
+	if s.storage == nil {
+		return ErrStorageNotConfigured
+	}
+	purged, err := purgeMessages(ctx, s.messageDao, s.storage, userID)
+	if err != nil {
+		return err
+	}
+	if err := s.purgeAttachments(ctx, userID); err != nil {
+		return err
+	}
+	if err := s.usageDao.DeleteByUserID(ctx, userID); err != nil {
+		logger.ErrorfCtx(ctx, "delete purged mailbox usage error: %v", err)
+		return err
+	}
+	if err := s.messageLabelDao.DeleteByUserID(ctx, userID); err != nil {
+		logger.ErrorfCtx(ctx, "delete purged message labels error: %v", err)
+		return err
+	}
+	if err := s.labelDao.DeleteByUserID(ctx, userID); err != nil {
+		logger.ErrorfCtx(ctx, "delete purged labels error: %v", err)
+		return err
+	}
+	logger.InfofCtx(ctx, "purged %d messages of user %s", purged, userID)
+	return nil
+}
+
+// purgeStore 清除邮件所需的数据访问，*dao.MessageDao 实现了该接口
+type purgeStore interface {
+	ListForPurge(ctx context.Context, userID string, limit int) ([]*datamodel.Message, error)
+	DeleteByIDs(ctx context.Context, ids []uint64) error
+	CountOnLegalHold(ctx context.Context, userID string) (int64, error)
+}
+
+var _ purgeStore = (*dao.MessageDao)(nil)
+
+// purgeMessages 分批删除用户未设置法律保留的邮件对象与记录，返回删除的数量
+// 设置了法律保留的邮件及其对象保留，此时返回 ErrLegalHold
+func purgeMessages(ctx context.Context, messages purgeStore, storage ObjectStorage, userID string) (int, error) {
+	var purged int
+	for {
+		msgs, err := messages.ListForPurge(ctx, userID, purgeBatchSize)
+		if err != nil {
+			logger.ErrorfCtx(ctx, "list messages for purge error: %v", err)
+			return purged, err
+		}
+		if len(msgs) == 0 {
+			break
+		}
+		ids := make([]uint64, 0, len(msgs))
+		for _, msg := range msgs {
+			if err := storage.DeleteObject(ctx, msg.S3Bucket, msg.S3Key); err != nil {
+				logger.ErrorfCtx(ctx, "delete message object %s error: %v", msg.S3Key, err)
+				return purged, err
+			}
+			ids = append(ids, msg.ID)
+		}
+		if err := messages.DeleteByIDs(ctx, ids); err != nil {
+			logger.ErrorfCtx(ctx, "delete purged messages error: %v", err)
+			return purged, err
+		}
+		purged += len(ids)
+	}
+
+	held, err := messages.CountOnLegalHold(ctx, userID)
+	if err != nil {
+		logger.ErrorfCtx(ctx, "count messages on legal hold error: %v", err)
+		return purged, err
+	}
+	if held > 0 {
+		logger.InfofCtx(ctx, "purged %d messages of user %s, %d messages under legal hold kept", purged, userID, held)
+		return purged, ErrLegalHold
+	}
+	return purged, nil
+}
+
+// objectKey 生成邮件对象 key：{prefix}/{user_id}/{yyyy/mm/dd}/{random}.eml
+func (s *MessageService) objectKey(userID string, t time.Time) string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return fmt.Sprintf("%s/%s/%s/%s.eml", s.conf.KeyPrefix, userID, t.UTC().Format("2006/01/02"), hex.EncodeToString(b))
+}
+
+// fillIndex 从解析结果中提取索引字段，解析失败时保留空值并返回 nil
+func fillIndex(ctx context.Context, msg *datamodel.Message, raw []byte) *mimeparse.Message {
+	parsed, err := mimeparse.Parse(raw)
+	if err != nil {
+		logger.WarnfCtx(ctx, "parse message for user %s error: %v", msg.UserID, err)
+		return nil
+	}
+
+	msg.MessageID = textutil.Truncate(parsed.MessageID, 255)
+	msg.InReplyTo = textutil.Truncate(parsed.InReplyTo, 255)
+	msg.ReferenceIDs = joinReferences(parsed.References)
+	msg.FromAddr = textutil.Truncate(mimeparse.FormatAddressList(parsed.From), 512)
+	msg.ToAddrs = mimeparse.FormatAddressList(parsed.To)
+	msg.Subject = textutil.Truncate(parsed.Subject, 1024)
+	msg.SentAt = parsed.Date
+	msg.HasAttachments = parsed.HasAttachments()
+	msg.Snippet = textutil.Truncate(parsed.Snippet(snippetRunes), 512)
+	return parsed
+}
+
+// joinReferences 将 References 拼接为空格分隔的字符串，过长的引用链只保留首个与最近的引用
+func joinReferences(refs []string) string {
+	kept := make([]string, 0, len(refs))
+	for _, ref := range refs {
+		if len(ref) <= 255 && !strings.ContainsAny(ref, " \t") {
+			kept = append(kept, ref)
+		}
+	}
+	if len(kept) > maxReferences {
+		kept = append(kept[:1], kept[len(kept)-maxReferences+1:]...)
+	}
+	return strings.Join(kept, " ")
+}
+
+// SplitReferences 解析索引中空格分隔的 References
+func SplitReferences(v string) []string {
+	return strings.Fields(v)
+}
+
+// Init 初始化服务
+func (s *MessageService) Init(ctx context.Context) error {
+	if s.IsInited() {
+		return nil
+	}
+	if s.storage == nil {
+		logger.Warnf("message storage not configured, inbound messages will be deferred")
+	}
+	s.SetInited(true)
+	return nil
+}
+
+// Start 启动服务
+func (s *MessageService) Start(ctx context.Context) error {
+	if s.IsStarted() {
+		return nil
+	}
+	runCtx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+	s.wg.Add(1)
+	go s.reconcileLoop(runCtx, time.Duration(s.quotaConf.ReconcileIntervalSeconds)*time.Second)
+
+	logger.Infof("start message service")
+	s.SetStarted(true)
+	return nil
+}
+
+// Stop 停止服务
+func (s *MessageService) Stop(ctx context.Context) error {
+	if s.IsStopped() {
+		return nil
+	}
+	defer s.SetStopped(true)
+	if s.cancel != nil {
+		s.cancel()
+		s.wg.Wait()
+	}
+	logger.Infof("stop message service")
+	return nil
+}
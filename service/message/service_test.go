@@ -0,0 +1,129 @@
+package message
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"testing"
+
+	datamodel "plaud-emails/data/model"
+
+	"github.com/Plaud-AI/plaud-go-scaffold/pkg/aws"
+)
+
+// fakePurgeStore 按 dao.MessageDao 的语义保存邮件记录，ListForPurge 不返回法律保留的邮件
+type fakePurgeStore struct {
+	msgs []*datamodel.Message
+}
+
+func (f *fakePurgeStore) ListForPurge(_ context.Context, userID string, limit int) ([]*datamodel.Message, error) {
+	var out []*datamodel.Message
+	for _, m := range f.msgs {
+		if m.UserID == userID && !m.LegalHold && len(out) < limit {
+			out = append(out, m)
+		}
+	}
+	return out, nil
+}
+
+func (f *fakePurgeStore) DeleteByIDs(_ context.Context, ids []uint64) error {
+	f.msgs = slices.DeleteFunc(f.msgs, func(m *datamodel.Message) bool { return slices.Contains(ids, m.ID) })
+	return nil
+}
+
+func (f *fakePurgeStore) CountOnLegalHold(_ context.Context, userID string) (int64, error) {
+	var n int64
+	for _, m := range f.msgs {
+		if m.UserID == userID && m.LegalHold {
+			n++
+		}
+	}
+	return n, nil
+}
+
+// fakeStorage 记录删除的对象
+type fakeStorage struct {
+	deleted []string
+	failKey string
+}
+
+func (f *fakeStorage) PutStream(context.Context, string, string, io.Reader, string, int64, map[string]string, string) (*aws.S3ObjectInfo, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (f *fakeStorage) GetStream(context.Context, string, string, io.Writer) (int64, *aws.S3ObjectInfo, error) {
+	return 0, nil, errors.New("not implemented")
+}
+
+func (f *fakeStorage) Exists(context.Context, string, string) (bool, *aws.S3ObjectInfo, error) {
+	return false, nil, nil
+}
+
+func (f *fakeStorage) DeleteObject(_ context.Context, _, key string) error {
+	if key == f.failKey {
+		return errors.New("s3 unavailable")
+	}
+	f.deleted = append(f.deleted, key)
+	return nil
+}
+
+func purgeFixture(n int, held ...uint64) *fakePurgeStore {
+	store := &fakePurgeStore{}
+	for i := 1; i <= n; i++ {
+		id := uint64(i)
+		store.msgs = append(store.msgs, &datamodel.Message{ID: id, UserID: "u1", S3Key: fmt.Sprintf("u1/%d.eml", i), LegalHold: slices.Contains(held, id)})
+	}
+	store.msgs = append(store.msgs, &datamodel.Message{ID: 1000, UserID: "u2", S3Key: "other"})
+	return store
+}
+
+func TestPurgeMessages(t *testing.T) {
+	store := purgeFixture(purgeBatchSize + 5)
+	storage := &fakeStorage{}
+	purged, err := purgeMessages(context.Background(), store, storage, "u1")
+	if err != nil || purged != purgeBatchSize+5 {
+		t.Fatalf("purged = %d err = %v, want %d", purged, err, purgeBatchSize+5)
+	}
+	if len(storage.deleted) != purged || len(store.msgs) != 1 || store.msgs[0].UserID != "u2" {
+		t.Fatalf("deleted %d objects, remaining %d rows; other users must be kept", len(storage.deleted), len(store.msgs))
+	}
+}
+
+func TestPurgeMessagesKeepsLegalHold(t *testing.T) {
+	store := purgeFixture(3, 2)
+	heldKey := store.msgs[1].S3Key
+	storage := &fakeStorage{}
+	purged, err := purgeMessages(context.Background(), store, storage, "u1")
+	if !errors.Is(err, ErrLegalHold) || purged != 2 {
+		t.Fatalf("purged = %d err = %v, want 2 and ErrLegalHold", purged, err)
+	}
+	if slices.Contains(storage.deleted, heldKey) {
+		t.Fatal("object of a held message must not be deleted")
+	}
+	if held, _ := store.CountOnLegalHold(context.Background(), "u1"); held != 1 || len(store.msgs) != 2 {
+		t.Fatalf("held message should survive the purge, remaining %d rows", len(store.msgs))
+	}
+
+	// 解除保留后再次清除
+	store.msgs[0].LegalHold = false
+	if _, err := purgeMessages(context.Background(), store, storage, "u1"); err != nil {
+		t.Fatalf("purge after release: %v", err)
+	}
+	if !slices.Contains(storage.deleted, heldKey) {
+		t.Fatal("released message should be purged")
+	}
+}
+
+func TestPurgeMessagesStorageError(t *testing.T) {
+	store := purgeFixture(3)
+	storage := &fakeStorage{failKey: store.msgs[1].S3Key}
+	if _, err := purgeMessages(context.Background(), store, storage, "u1"); err == nil {
+		t.Fatal("storage error should abort the purge")
+	}
+	// 对象删除失败时记录保留，重试时不会遗留 S3 对象
+	if len(store.msgs) != 4 {
+		t.Fatalf("rows must be kept until their objects are deleted, remaining %d", len(store.msgs))
+	}
+}
@@ -0,0 +1,50 @@
+package message
+
+import (
+	"context"
+
+	datamodel "plaud-emails/data/model"
+	"plaud-emails/service/mimeparse"
+
+	"github.com/Plaud-AI/plaud-go-scaffold/pkg/logger"
+)
+
+// SpamVerdict 垃圾邮件评分结果
+type SpamVerdict struct {
+	Score float64
+	Spam  bool
+	// Report 命中规则的说明，JSON 格式，保存在邮件记录中供邮件详情展示
+	Report string
+}
+
+// SpamScorer 入库前对邮件进行垃圾邮件评分，parsed 在邮件无法解析时为 nil
+// 返回 nil 表示不评分
+type SpamScorer interface {
+	Score(ctx context.Context, in *StoreInput, parsed *mimeparse.Message) (*SpamVerdict, error)
+}
+
+// SetSpamScorer 设置垃圾邮件评分，需在服务启动前调用
+func (s *MessageService) SetSpamScorer(scorer SpamScorer) {
+	s.spamScorer = scorer
+}
+
+// scoreSpam 对邮件评分并记录结果，判定为垃圾邮件时放入 spam 文件夹
+// 评分失败时按正常邮件处理，不影响收信
+func (s *MessageService) scoreSpam(ctx context.Context, msg *datamodel.Message, in *StoreInput, parsed *mimeparse.Message) {
+	if s.spamScorer == nil {
+		return
+	}
+	verdict, err := s.spamScorer.Score(ctx, in, parsed)
+	if err != nil {
+		logger.WarnfCtx(ctx, "score spam for user %s error: %v", msg.UserID, err)
+		return
+	}
+	if verdict == nil {
+		return
+	}
+	msg.SpamScore = verdict.Score
+	msg.SpamReport = verdict.Report
+	if verdict.Spam && msg.Folder != datamodel.FolderTrash {
+		msg.Folder = datamodel.FolderSpam
+	}
+}
@@ -0,0 +1,48 @@
+package message
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	datamodel "plaud-emails/data/model"
+	"plaud-emails/service/mimeparse"
+)
+
+// stubScorer 返回固定的评分结果
+type stubScorer struct {
+	verdict *SpamVerdict
+	err     error
+}
+
+func (s *stubScorer) Score(context.Context, *StoreInput, *mimeparse.Message) (*SpamVerdict, error) {
+	return s.verdict, s.err
+}
+
+func TestScoreSpamFolder(t *testing.T) {
+	cases := []struct {
+		name   string
+		scorer SpamScorer
+		folder string
+		want   string
+		score  float64
+	}{
+		{"no scorer", nil, datamodel.FolderInbox, datamodel.FolderInbox, 0},
+		{"not scored", &stubScorer{}, datamodel.FolderInbox, datamodel.FolderInbox, 0},
+		{"scorer error", &stubScorer{err: errors.New("timeout")}, datamodel.FolderInbox, datamodel.FolderInbox, 0},
+		{"below threshold", &stubScorer{verdict: &SpamVerdict{Score: 4.9, Report: "{}"}}, datamodel.FolderInbox, datamodel.FolderInbox, 4.9},
+		{"over threshold", &stubScorer{verdict: &SpamVerdict{Score: 7, Spam: true, Report: "{}"}}, datamodel.FolderInbox, datamodel.FolderSpam, 7},
+		{"rule moved to archive", &stubScorer{verdict: &SpamVerdict{Score: 7, Spam: true, Report: "{}"}}, datamodel.FolderArchive, datamodel.FolderSpam, 7},
+		{"rule moved to trash", &stubScorer{verdict: &SpamVerdict{Score: 7, Spam: true, Report: "{}"}}, datamodel.FolderTrash, datamodel.FolderTrash, 7},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			s := &MessageService{spamScorer: tc.scorer}
+			msg := &datamodel.Message{UserID: "u1", Folder: tc.folder}
+			s.scoreSpam(context.Background(), msg, &StoreInput{UserID: "u1"}, nil)
+			if msg.Folder != tc.want || msg.SpamScore != tc.score {
+				t.Fatalf("folder = %s score = %v, want %s %v", msg.Folder, msg.SpamScore, tc.want, tc.score)
+			}
+		})
+	}
+}
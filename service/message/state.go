@@ -0,0 +1,360 @@
+package message
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"plaud-emails/dao"
+	datamodel "plaud-emails/data/model"
+
+	"github.com/Plaud-AI/plaud-go-scaffold/pkg/logger"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// MaxBulkMessages 一次批量更新最多的邮件数
+	MaxBulkMessages = 500
+	// MaxLabels 每个用户最多的自定义标签数
+	MaxLabels = 200
+	// LabelNameMaxLen 标签名称的最大长度
+	LabelNameMaxLen = 64
+	// DefaultChangesLimit 增量同步默认分页大小
+	DefaultChangesLimit = 100
+	// MaxChangesLimit 增量同步最大分页大小
+	MaxChangesLimit = 500
+)
+
+// 错误定义
+var (
+	ErrInvalidFolder    = errors.New("folder must be one of inbox, archive, trash and spam")
+	ErrInvalidLabelName = errors.New("label name must be 1-64 characters and must not be a system label")
+	ErrLabelExists      = errors.New("label already exists")
+	ErrLabelNotFound    = errors.New("label not found")
+	ErrTooManyLabels    = errors.New("too many labels")
+	ErrInvalidBulk      = errors.New("ids must contain 1-500 messages")
+	ErrEmptyUpdate      = errors.New("nothing to update")
+	ErrInvalidCursor    = errors.New("invalid cursor")
+)
+
+// StateUpdate 批量更新邮件状态，nil 或空值的字段不修改
+type StateUpdate struct {
+	IDs            []uint64
+	Seen           *bool
+	Starred        *bool
+	Important      *bool
+	Folder         string
+	AddLabelIDs    []uint64
+	RemoveLabelIDs []uint64
+}
+
+// UpdateState 批量更新用户邮件的标记、文件夹与标签，不属于用户或已删除的邮件忽略
+// 返回更新的邮件数与本次变更的 modseq
+func (s *MessageService) UpdateState(ctx context.Context, userID string, u *StateUpdate) (int, uint64, error) {
+	if len(u.IDs) == 0 || len(u.IDs) > MaxBulkMessages {
+		return 0, 0, ErrInvalidBulk
+	}
+	if u.Folder != "" && !datamodel.IsFolder(u.Folder) {
+		return 0, 0, ErrInvalidFolder
+	}
+	if u.Seen == nil && u.Starred == nil && u.Important == nil && u.Folder == "" &&
+		len(u.AddLabelIDs) == 0 && len(u.RemoveLabelIDs) == 0 {
+		return 0, 0, ErrEmptyUpdate
+	}
+
+	var updatedIDs []uint64
+	var modSeq uint64
+	err := s.messageDao.ExecTx(ctx, func(tx *gorm.DB) error {
+		labelIDs := dedupIDs(append(append([]uint64{}, u.AddLabelIDs...), u.RemoveLabelIDs...))
+		count, err := dao.NewUserLabelDao(tx).CountByIDs(ctx, userID, labelIDs)
+		if err != nil {
+			return err
+		}
+		if int(count) != len(labelIDs) {
+			return ErrLabelNotFound
+		}
+
+		messageDao := dao.NewMessageDao(tx)
+		ids, err := messageDao.ListActiveIDs(ctx, userID, dedupIDs(u.IDs))
+		if err != nil || len(ids) == 0 {
+			return err
+		}
+		if modSeq, err = dao.NewMindAdvisorUserDao(tx).NextModSeq(ctx, userID); err != nil {
+			return err
+		}
+
+		update := &dao.MessageStateUpdate{Seen: u.Seen, Starred: u.Starred, Important: u.Important, Folder: u.Folder}
+		if _, err := messageDao.UpdateState(ctx, userID, ids, update, modSeq); err != nil {
+			return err
+		}
+		labelDao := dao.NewMessageLabelDao(tx)
+		if err := labelDao.Add(ctx, userID, ids, dedupIDs(u.AddLabelIDs)); err != nil {
+			return err
+		}
+		if err := labelDao.Remove(ctx, userID, ids, dedupIDs(u.RemoveLabelIDs)); err != nil {
+			return err
+		}
+		updatedIDs = ids
+		return nil
+	})
+	if err != nil {
+		if !errors.Is(err, ErrLabelNotFound) {
+			logger.ErrorfCtx(ctx, "update message state error: %v", err)
+		}
+		return 0, 0, err
+	}
+	if u.Folder != "" && len(updatedIDs) > 0 {
+		for _, l := range s.folderListeners {
+			l.OnFolderChanged(ctx, userID, updatedIDs, u.Folder)
+		}
+	}
+	return len(updatedIDs), modSeq, nil
+}
+
+// LabelIDs 查询一组邮件的用户标签 id
+func (s *MessageService) LabelIDs(ctx context.Context, userID string, msgs []*datamodel.Message) (map[uint64][]uint64, error) {
+	ids := make([]uint64, 0, len(msgs))
+	for _, m := range msgs {
+		ids = append(ids, m.ID)
+	}
+	rows, err := s.messageLabelDao.ListByMessageIDs(ctx, userID, ids)
+	if err != nil {
+		logger.ErrorfCtx(ctx, "list message labels error: %v", err)
+		return nil, err
+	}
+	result := make(map[uint64][]uint64, len(msgs))
+	for _, r := range rows {
+		result[r.MessageID] = append(result[r.MessageID], r.LabelID)
+	}
+	return result, nil
+}
+
+// ListLabels 查询用户的自定义标签
+func (s *MessageService) ListLabels(ctx context.Context, userID string) ([]*datamodel.UserLabel, error) {
+	labels, err := s.labelDao.ListByUserID(ctx, userID)
+	if err != nil {
+		logger.ErrorfCtx(ctx, "list labels error: %v", err)
+		return nil, err
+	}
+	return labels, nil
+}
+
+// CreateLabel 创建自定义标签，名称不区分大小写且不能与系统标签重名
+func (s *MessageService) CreateLabel(ctx context.Context, userID, name string) (*datamodel.UserLabel, error) {
+	name, err := normalizeLabelName(name)
+	if err != nil {
+		return nil, err
+	}
+
+	label := &datamodel.UserLabel{UserID: userID, Name: name}
+	err = s.labelDao.ExecTx(ctx, func(tx *gorm.DB) error {
+		txDao := dao.NewUserLabelDao(tx)
+		count, err := txDao.CountByUserID(ctx, userID)
+		if err != nil {
+			return err
+		}
+		if count >= MaxLabels {
+			return ErrTooManyLabels
+		}
+		return txDao.Create(ctx, label)
+	})
+	if err != nil {
+		if strings.Contains(err.Error(), "Duplicate entry") {
+			return nil, ErrLabelExists
+		}
+		if !errors.Is(err, ErrTooManyLabels) {
+			logger.ErrorfCtx(ctx, "create label error: %v", err)
+		}
+		return nil, err
+	}
+	return label, nil
+}
+
+// RenameLabel 重命名自定义标签，邮件通过标签 id 关联，不需要更新邮件
+func (s *MessageService) RenameLabel(ctx context.Context, userID string, id uint64, name string) (*datamodel.UserLabel, error) {
+	name, err := normalizeLabelName(name)
+	if err != nil {
+		return nil, err
+	}
+
+	label, err := s.labelDao.GetByUserIDAndID(ctx, userID, id)
+	if err != nil {
+		logger.ErrorfCtx(ctx, "get label error: %v", err)
+		return nil, err
+	}
+	if label == nil {
+		return nil, ErrLabelNotFound
+	}
+	label.Name = name
+	if err := s.labelDao.Update(ctx, label); err != nil {
+		if strings.Contains(err.Error(), "Duplicate entry") {
+			return nil, ErrLabelExists
+		}
+		logger.ErrorfCtx(ctx, "rename label error: %v", err)
+		return nil, err
+	}
+	return label, nil
+}
+
+// DeleteLabel 删除自定义标签，带有该标签的邮件记录新的 modseq
+func (s *MessageService) DeleteLabel(ctx context.Context, userID string, id uint64) error {
+	err := s.labelDao.ExecTx(ctx, func(tx *gorm.DB) error {
+		labelDao := dao.NewUserLabelDao(tx)
+		label, err := labelDao.GetByUserIDAndID(ctx, userID, id)
+		if err != nil {
+			return err
+		}
+		if label == nil {
+			return ErrLabelNotFound
+		}
+		modSeq, err := dao.NewMindAdvisorUserDao(tx).NextModSeq(ctx, userID)
+		if err != nil {
+			return err
+		}
+		if err := dao.NewMessageDao(tx).TouchByLabelID(ctx, userID, id, modSeq); err != nil {
+			return err
+		}
+		if err := dao.NewMessageLabelDao(tx).DeleteByLabelID(ctx, id); err != nil {
+			return err
+		}
+		return labelDao.DeleteByID(ctx, id)
+	})
+	if err != nil {
+		if !errors.Is(err, ErrLabelNotFound) {
+			logger.ErrorfCtx(ctx, "delete label error: %v", err)
+		}
+		return err
+	}
+	return nil
+}
+
+// ruleFolder 标签规则指定的标签与系统标签同名时，邮件直接放入对应文件夹
+func ruleFolder(label string) string {
+	if folder := strings.ToLower(strings.TrimSpace(label)); datamodel.IsFolder(folder) {
+		return folder
+	}
+	return datamodel.FolderInbox
+}
+
+// ruleLabelID 返回标签规则指定的自定义标签 id，标签不存在时创建
+// 在入库事务之前调用，并发创建同名标签时读取已创建的标签；超出数量上限或失败时返回 0，不影响收信
+func (s *MessageService) ruleLabelID(ctx context.Context, userID, name string) uint64 {
+	name = strings.TrimSpace(name)
+	if name == "" || datamodel.IsFolder(strings.ToLower(name)) {
+		return 0
+	}
+
+	label, err := s.labelDao.GetByName(ctx, userID, name)
+	if err == nil && label == nil {
+		label, err = s.CreateLabel(ctx, userID, name)
+		if errors.Is(err, ErrLabelExists) {
+			label, err = s.labelDao.GetByName(ctx, userID, name)
+		}
+	}
+	if err != nil || label == nil {
+		logger.WarnfCtx(ctx, "skip rule label %q of user %s: %v", name, userID, err)
+		return 0
+	}
+	return label.ID
+}
+
+// normalizeLabelName 去除首尾空白并校验标签名称
+func normalizeLabelName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || utf8.RuneCountInString(name) > LabelNameMaxLen || datamodel.IsFolder(strings.ToLower(name)) {
+		return "", ErrInvalidLabelName
+	}
+	return name, nil
+}
+
+func dedupIDs(ids []uint64) []uint64 {
+	out := make([]uint64, 0, len(ids))
+	seen := make(map[uint64]bool, len(ids))
+	for _, id := range ids {
+		if id == 0 || seen[id] {
+			continue
+		}
+		seen[id] = true
+		out = append(out, id)
+	}
+	return out
+}
+
+// ChangeCursor 增量同步的分页位置
+type ChangeCursor struct {
+	ModSeq uint64
+	ID     uint64
+}
+
+// String 格式化为 "{modseq}-{id}"
+func (c *ChangeCursor) String() string {
+	return fmt.Sprintf("%d-%d", c.ModSeq, c.ID)
+}
+
+// ParseChangeCursor 解析 "{modseq}-{id}" 形式的游标
+func ParseChangeCursor(v string) (*ChangeCursor, error) {
+	seq, id, ok := strings.Cut(v, "-")
+	if !ok {
+		return nil, ErrInvalidCursor
+	}
+	c := &ChangeCursor{}
+	var err error
+	if c.ModSeq, err = strconv.ParseUint(seq, 10, 64); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	if c.ID, err = strconv.ParseUint(id, 10, 64); err != nil || c.ID == 0 {
+		return nil, ErrInvalidCursor
+	}
+	return c, nil
+}
+
+// Changes 增量同步结果
+type Changes struct {
+	// Messages modseq 大于 since 的邮件，包括已删除的记录
+	Messages []*datamodel.Message
+	// HighestModSeq 邮箱当前的 modseq，没有下一页时客户端保存该值作为下次同步的 since
+	HighestModSeq uint64
+	Next          *ChangeCursor
+}
+
+// ListChanges 查询 modseq 大于 since 的邮件状态变化，按 modseq 升序分页
+func (s *MessageService) ListChanges(ctx context.Context, userID string, since uint64, cursor *ChangeCursor, limit int) (*Changes, error) {
+	if limit <= 0 {
+		limit = DefaultChangesLimit
+	}
+	if limit > MaxChangesLimit {
+		limit = MaxChangesLimit
+	}
+
+	// 先读取邮箱的 modseq 再查询变化：不大于该值的变化都已提交，不会被遗漏
+	user, err := s.userDao.GetByUserID(ctx, userID)
+	if err != nil {
+		logger.ErrorfCtx(ctx, "get mind advisor user error: %v", err)
+		return nil, err
+	}
+	changes := &Changes{}
+	if user != nil {
+		changes.HighestModSeq = user.ModSeq
+	}
+
+	var afterModSeq, afterID uint64
+	if cursor != nil {
+		afterModSeq, afterID = cursor.ModSeq, cursor.ID
+	}
+	msgs, err := s.messageDao.ListChanged(ctx, userID, since, afterModSeq, afterID, limit+1)
+	if err != nil {
+		logger.ErrorfCtx(ctx, "list changed messages error: %v", err)
+		return nil, err
+	}
+	if len(msgs) > limit {
+		msgs = msgs[:limit]
+		last := msgs[limit-1]
+		changes.Next = &ChangeCursor{ModSeq: last.ModSeq, ID: last.ID}
+	}
+	changes.Messages = msgs
+	return changes, nil
+}
@@ -0,0 +1,86 @@
+package mimeparse
+
+import (
+	"io"
+	"mime"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/htmlindex"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/encoding/traditionalchinese"
+	"golang.org/x/text/transform"
+)
+
+// charsetAliases 邮件中常见但 WHATWG 编码表未收录或映射不理想的字符集别名
+var charsetAliases = map[string]encoding.Encoding{
+	"gb2312":     simplifiedchinese.GBK,
+	"x-gbk":      simplifiedchinese.GBK,
+	"cp936":      simplifiedchinese.GBK,
+	"gb18030":    simplifiedchinese.GB18030,
+	"big5-hkscs": traditionalchinese.Big5,
+	"x-x-big5":   traditionalchinese.Big5,
+	"cp950":      traditionalchinese.Big5,
+	"hz-gb-2312": simplifiedchinese.HZGB2312,
+	"us-ascii":   nil,
+	"ascii":      nil,
+}
+
+// lookupEncoding 查找字符集对应的编码，返回 nil 表示按 UTF-8/ASCII 原样处理
+func lookupEncoding(charset string) (encoding.Encoding, bool) {
+	name := strings.ToLower(strings.Trim(strings.TrimSpace(charset), `"'`))
+	if name == "" || name == "utf-8" || name == "utf8" {
+		return nil, true
+	}
+	if enc, ok := charsetAliases[name]; ok {
+		return enc, true
+	}
+	enc, err := htmlindex.Get(name)
+	if err != nil {
+		return nil, false
+	}
+	return enc, true
+}
+
+// CharsetReader 将指定字符集的输入转换为 UTF-8，未知字符集原样返回
+func CharsetReader(charset string, input io.Reader) (io.Reader, error) {
+	enc, _ := lookupEncoding(charset)
+	if enc == nil {
+		return input, nil
+	}
+	return transform.NewReader(input, enc.NewDecoder()), nil
+}
+
+// decodeCharset 将字节按字符集转换为合法的 UTF-8 字符串
+func decodeCharset(data []byte, charset string) string {
+	enc, known := lookupEncoding(charset)
+	if enc != nil {
+		if out, _, err := transform.Bytes(enc.NewDecoder(), data); err == nil {
+			return string(out)
+		}
+	}
+	declared := strings.TrimSpace(charset) != ""
+	if (!declared || !known) && !utf8.Valid(data) {
+		// 未声明或未知字符集且不是 UTF-8 时，国内邮件最常见的是 GBK
+		if out, _, err := transform.Bytes(simplifiedchinese.GBK.NewDecoder(), data); err == nil && utf8.Valid(out) {
+			return string(out)
+		}
+	}
+	return strings.ToValidUTF8(string(data), "�")
+}
+
+var wordDecoder = &mime.WordDecoder{CharsetReader: CharsetReader}
+
+// DecodeHeader 解码 RFC 2047 编码的头部值，支持 GBK、Big5、Shift_JIS 等字符集
+func DecodeHeader(v string) string {
+	decoded, err := wordDecoder.DecodeHeader(v)
+	if err != nil {
+		return strings.ToValidUTF8(v, "�")
+	}
+	if !utf8.ValidString(decoded) {
+		// 部分客户端直接发送未编码的 8bit 头部
+		return decodeCharset([]byte(decoded), "")
+	}
+	return decoded
+}
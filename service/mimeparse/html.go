@@ -0,0 +1,298 @@
+package mimeparse
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// allowedTags 允许保留的 HTML 标签及其可用属性
+var allowedTags = map[atom.Atom][]string{
+	atom.A:          {"href", "title", "name"},
+	atom.Abbr:       {"title"},
+	atom.B:          nil,
+	atom.Blockquote: {"cite"},
+	atom.Br:         nil,
+	atom.Caption:    nil,
+	atom.Center:     nil,
+	atom.Code:       nil,
+	atom.Col:        {"span", "width"},
+	atom.Colgroup:   {"span", "width"},
+	atom.Dd:         nil,
+	atom.Del:        nil,
+	atom.Div:        nil,
+	atom.Dl:         nil,
+	atom.Dt:         nil,
+	atom.Em:         nil,
+	atom.Font:       {"color", "face", "size"},
+	atom.H1:         nil,
+	atom.H2:         nil,
+	atom.H3:         nil,
+	atom.H4:         nil,
+	atom.H5:         nil,
+	atom.H6:         nil,
+	atom.Hr:         nil,
+	atom.I:          nil,
+	atom.Img:        {"src", "alt", "title", "width", "height"},
+	atom.Ins:        nil,
+	atom.Li:         nil,
+	atom.Ol:         {"start", "type"},
+	atom.P:          nil,
+	atom.Pre:        nil,
+	atom.Q:          {"cite"},
+	atom.S:          nil,
+	atom.Small:      nil,
+	atom.Span:       nil,
+	atom.Strike:     nil,
+	atom.Strong:     nil,
+	atom.Sub:        nil,
+	atom.Sup:        nil,
+	atom.Table:      {"border", "cellpadding", "cellspacing", "width"},
+	atom.Tbody:      nil,
+	atom.Td:         {"colspan", "rowspan", "width", "height"},
+	atom.Tfoot:      nil,
+	atom.Th:         {"colspan", "rowspan", "width", "height", "scope"},
+	atom.Thead:      nil,
+	atom.Tr:         nil,
+	atom.Tt:         nil,
+	atom.U:          nil,
+	atom.Ul:         nil,
+}
+
+// globalAttrs 所有允许标签上都可保留的属性
+var globalAttrs = []string{"align", "valign", "bgcolor", "color", "dir", "lang", "style"}
+
+// droppedWithContent 连同内容一起丢弃的标签
+var droppedWithContent = map[atom.Atom]bool{
+	atom.Script:   true,
+	atom.Style:    true,
+	atom.Title:    true,
+	atom.Head:     true,
+	atom.Iframe:   true,
+	atom.Frame:    true,
+	atom.Frameset: true,
+	atom.Object:   true,
+	atom.Embed:    true,
+	atom.Applet:   true,
+	atom.Noscript: true,
+	atom.Template: true,
+	atom.Svg:      true,
+	atom.Math:     true,
+	atom.Select:   true,
+	atom.Textarea: true,
+	atom.Button:   true,
+}
+
+var (
+	// unsafeStyle 可执行脚本或加载外部资源的样式写法
+	unsafeStyle = regexp.MustCompile(`(?i)expression\s*\(|javascript:|vbscript:|url\s*\(|@import|behavior\s*:|-moz-binding`)
+	// safeDataImage 允许内嵌的 data URI 图片类型，SVG 可以携带脚本因此不允许
+	safeDataImage = regexp.MustCompile(`(?i)^data:image/(png|jpe?g|gif|webp|bmp);base64,`)
+)
+
+// SanitizeHTML 清洗邮件 HTML，仅保留白名单中的标签与属性
+// 移除脚本、事件属性、危险的链接协议与样式
+func SanitizeHTML(src string) string {
+	var b strings.Builder
+	z := html.NewTokenizer(strings.NewReader(src))
+	skipDepth := 0
+	var skipAtom atom.Atom
+
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			// io.EOF 或者畸形输入，均以已处理的部分为准
+			break
+		}
+
+		tok := z.Token()
+		switch tt {
+		case html.StartTagToken, html.SelfClosingTagToken:
+			if skipDepth > 0 {
+				if tok.DataAtom == skipAtom && tt == html.StartTagToken {
+					skipDepth++
+				}
+				continue
+			}
+			if droppedWithContent[tok.DataAtom] {
+				if tt == html.StartTagToken && !isVoid(tok.DataAtom) {
+					skipDepth = 1
+					skipAtom = tok.DataAtom
+				}
+				continue
+			}
+			attrs, ok := allowedTags[tok.DataAtom]
+			if !ok {
+				continue
+			}
+			tok.Attr = sanitizeAttrs(tok.DataAtom, tok.Attr, attrs)
+			if tok.DataAtom == atom.A {
+				tok.Attr = append(tok.Attr,
+					html.Attribute{Key: "rel", Val: "noopener noreferrer nofollow"},
+					html.Attribute{Key: "target", Val: "_blank"})
+			}
+			b.WriteString(tok.String())
+		case html.EndTagToken:
+			if skipDepth > 0 {
+				if tok.DataAtom == skipAtom {
+					skipDepth--
+				}
+				continue
+			}
+			if _, ok := allowedTags[tok.DataAtom]; ok {
+				b.WriteString(tok.String())
+			}
+		case html.TextToken:
+			if skipDepth > 0 {
+				continue
+			}
+			b.WriteString(html.EscapeString(tok.Data))
+		}
+	}
+	return b.String()
+}
+
+// sanitizeAttrs 过滤属性，只保留白名单且值安全的属性
+func sanitizeAttrs(a atom.Atom, attrs []html.Attribute, allowed []string) []html.Attribute {
+	out := attrs[:0]
+	for _, attr := range attrs {
+		key := strings.ToLower(attr.Key)
+		if attr.Namespace != "" || strings.HasPrefix(key, "on") {
+			continue
+		}
+		if !slices.Contains(allowed, key) && !slices.Contains(globalAttrs, key) {
+			continue
+		}
+		switch key {
+		case "href", "cite":
+			if !isSafeURL(attr.Val, false) {
+				continue
+			}
+		case "src":
+			if !isSafeURL(attr.Val, a == atom.Img) {
+				continue
+			}
+		case "style":
+			if unsafeStyle.MatchString(attr.Val) {
+				continue
+			}
+		}
+		attr.Key = key
+		out = append(out, attr)
+	}
+	return out
+}
+
+// isSafeURL 判断链接协议是否安全，图片额外允许 cid: 与安全的 data URI
+func isSafeURL(v string, image bool) bool {
+	u := strings.ToLower(strings.TrimSpace(v))
+	// 去掉控制字符与空白，防止 "java\tscript:" 之类的绕过
+	u = strings.Map(func(r rune) rune {
+		if r <= ' ' {
+			return -1
+		}
+		return r
+	}, u)
+	switch {
+	case strings.HasPrefix(u, "http://"), strings.HasPrefix(u, "https://"):
+		return true
+	case strings.HasPrefix(u, "mailto:") && !image:
+		return true
+	case strings.HasPrefix(u, "#") && !image:
+		return true
+	case image && strings.HasPrefix(u, "cid:"):
+		return true
+	case image && safeDataImage.MatchString(u):
+		return true
+	}
+	return false
+}
+
+// blockTags 转纯文本时需要换行的块级标签
+var blockTags = map[atom.Atom]bool{
+	atom.Br: true, atom.P: true, atom.Div: true, atom.Tr: true, atom.Li: true,
+	atom.H1: true, atom.H2: true, atom.H3: true, atom.H4: true, atom.H5: true, atom.H6: true,
+	atom.Blockquote: true, atom.Pre: true, atom.Hr: true, atom.Table: true,
+}
+
+var (
+	blankLines     = regexp.MustCompile(`\n{3,}`)
+	trailingSpaces = regexp.MustCompile(` +\n`)
+)
+
+// HTMLToText 将 HTML 转换为纯文本，用于没有 text/plain 部分的邮件
+func HTMLToText(src string) string {
+	var b strings.Builder
+	z := html.NewTokenizer(strings.NewReader(src))
+	skipDepth := 0
+	var skipAtom atom.Atom
+
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			break
+		}
+		tok := z.Token()
+		switch tt {
+		case html.StartTagToken, html.SelfClosingTagToken:
+			if skipDepth > 0 {
+				if tok.DataAtom == skipAtom && tt == html.StartTagToken {
+					skipDepth++
+				}
+				continue
+			}
+			if droppedWithContent[tok.DataAtom] && tt == html.StartTagToken && !isVoid(tok.DataAtom) {
+				skipDepth = 1
+				skipAtom = tok.DataAtom
+				continue
+			}
+			if blockTags[tok.DataAtom] {
+				b.WriteByte('\n')
+			}
+		case html.EndTagToken:
+			if skipDepth > 0 {
+				if tok.DataAtom == skipAtom {
+					skipDepth--
+				}
+				continue
+			}
+			if blockTags[tok.DataAtom] {
+				b.WriteByte('\n')
+			}
+		case html.TextToken:
+			if skipDepth > 0 {
+				continue
+			}
+			// 文本两侧的空白（含 &nbsp;）折叠为一个空格，避免相邻元素中的单词粘连
+			if first, _ := utf8.DecodeRuneInString(tok.Data); unicode.IsSpace(first) {
+				writeSpace(&b)
+			}
+			b.WriteString(strings.Join(strings.Fields(tok.Data), " "))
+			if last, _ := utf8.DecodeLastRuneInString(tok.Data); unicode.IsSpace(last) {
+				writeSpace(&b)
+			}
+		}
+	}
+	text := trailingSpaces.ReplaceAllString(b.String(), "\n")
+	return strings.TrimSpace(blankLines.ReplaceAllString(text, "\n\n"))
+}
+
+// writeSpace 在非空且不以空白结尾的输出后追加一个空格
+func writeSpace(b *strings.Builder) {
+	if s := b.String(); s != "" && s[len(s)-1] != ' ' && s[len(s)-1] != '\n' {
+		b.WriteByte(' ')
+	}
+}
+
+func isVoid(a atom.Atom) bool {
+	switch a {
+	case atom.Embed, atom.Frame:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,67 @@
+package mimeparse
+
+import "testing"
+
+func TestSanitizeHTML(t *testing.T) {
+	const rel = ` rel="noopener noreferrer nofollow" target="_blank"`
+	cases := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"allowed tags", `<p align="center">Hi <b>there</b><br/></p>`, `<p align="center">Hi <b>there</b><br/></p>`},
+		{"script with content", `<p>a</p><script>alert(1)</script><p>b</p>`, `<p>a</p><p>b</p>`},
+		{"nested dropped tags", `<svg><svg><a href="https://x">in</a></svg>still</svg>out`, `out`},
+		{"style block", `<style>body{background:url(https://t/x)}</style>text`, `text`},
+		{"iframe", `<iframe src="https://evil.example"></iframe>ok`, `ok`},
+		{"event handlers", `<div onclick="x()" ONMOUSEOVER="y()">t</div>`, `<div>t</div>`},
+		{"unknown tag keeps text", `<form action="/x"><input value="v"><blink>t</blink></form>`, `t`},
+		{"disallowed attribute", `<p id="a" class="b" title="c">t</p>`, `<p>t</p>`},
+		{"link", `<a href="https://example.com/?a=1&amp;b=2" target="_self">x</a>`, `<a href="https://example.com/?a=1&amp;b=2"` + rel + `>x</a>`},
+		{"mailto link", `<A HREF="mailto:bob@example.com">x</A>`, `<a href="mailto:bob@example.com"` + rel + `>x</a>`},
+		{"javascript link", `<a href="javascript:alert(1)">x</a>`, `<a` + rel + `>x</a>`},
+		{"obfuscated javascript", `<a href=" java&#09;script:alert(1)">x</a>`, `<a` + rel + `>x</a>`},
+		{"data link", `<a href="data:text/html;base64,PHNjcmlwdD4=">x</a>`, `<a` + rel + `>x</a>`},
+		{"cid image", `<img src="cid:logo@example.com" alt="logo">`, `<img src="cid:logo@example.com" alt="logo">`},
+		{"png data image", `<img src="data:image/png;base64,iVBORw0KGgo=">`, `<img src="data:image/png;base64,iVBORw0KGgo=">`},
+		{"svg data image", `<img src="data:image/svg+xml;base64,PHN2Zz4=">`, `<img>`},
+		{"mailto image", `<img src="mailto:bob@example.com">`, `<img>`},
+		{"safe style", `<span style="color: red">t</span>`, `<span style="color: red">t</span>`},
+		{"style expression", `<span style="width: expression(alert(1))">t</span>`, `<span>t</span>`},
+		{"style url", `<div style="background: URL(https://t.example/p.gif)">t</div>`, `<div>t</div>`},
+		{"escaped text", `<p>1 &lt; 2 &amp; <b>"q"</b></p>`, `<p>1 &lt; 2 &amp; <b>&#34;q&#34;</b></p>`},
+		{"unclosed script", `ok<script>alert(1)`, `ok`},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := SanitizeHTML(tc.in); got != tc.want {
+				t.Fatalf("SanitizeHTML(%q)\n got %q\nwant %q", tc.in, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestHTMLToText(t *testing.T) {
+	cases := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"paragraphs", `<p>first</p><p>second</p>`, "first\n\nsecond"},
+		{"line break", `a<br>b`, "a\nb"},
+		{"inline elements", `<b>Hello</b> <i>big</i> world`, "Hello big world"},
+		{"space after inline element", `<b>Hello</b>, <a href="#">world</a>!`, "Hello, world!"},
+		{"nbsp", `Hello&nbsp;<b>there</b>`, "Hello there"},
+		{"collapsed whitespace", "<div>\n  a \t b\n</div>", "a b"},
+		{"dropped content", `<head><title>T</title></head><script>x()</script><p>body</p>`, "body"},
+		{"blank lines", `<p>a</p><br><br><br><p>b</p>`, "a\n\nb"},
+		{"list", `<ul><li>one</li><li>two</li></ul>`, "one\n\ntwo"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := HTMLToText(tc.in); got != tc.want {
+				t.Fatalf("HTMLToText(%q) = %q, want %q", tc.in, got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,324 @@
+package mimeparse
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"time"
+)
+
+const (
+	// MaxDepth 嵌套 multipart 与 message/rfc822 的最大层数
+	MaxDepth = 10
+	// MaxParts 单封邮件最多处理的 MIME 部分数量
+	MaxParts = 500
+)
+
+// 错误定义
+var (
+	ErrTooDeep      = errors.New("mime structure too deep")
+	ErrTooManyParts = errors.New("too many mime parts")
+)
+
+// Address 邮件地址
+type Address struct {
+	Name    string
+	Address string
+}
+
+// String 格式化为 "Name <addr>"
+func (a *Address) String() string {
+	if a.Name == "" {
+		return a.Address
+	}
+	return fmt.Sprintf("%s <%s>", a.Name, a.Address)
+}
+
+// Attachment 附件，Inline 表示正文中引用的内嵌资源（如 cid 图片）
+type Attachment struct {
+	Filename    string
+	ContentType string
+	ContentID   string
+	Inline      bool
+	Size        int64
+	Data        []byte
+}
+
+// Message 解析后的规范化邮件
+type Message struct {
+	Header      mail.Header
+	From        []*Address
+	To          []*Address
+	Cc          []*Address
+	ReplyTo     []*Address
+	Subject     string
+	Date        *time.Time
+	MessageID   string
+	InReplyTo   string
+	References  []string
+	Text        string
+	HTML        string
+	Attachments []*Attachment
+	// Embedded 作为附件转发的 message/rfc822 邮件
+	Embedded []*Message
+}
+
+// HasAttachments 是否包含非内嵌附件
+func (m *Message) HasAttachments() bool {
+	for _, a := range m.Attachments {
+		if !a.Inline {
+			return true
+		}
+	}
+	return false
+}
+
+// Snippet 返回正文摘要，空白折叠后按字符截断
+func (m *Message) Snippet(n int) string {
+	s := []rune(strings.Join(strings.Fields(m.Text), " "))
+	if len(s) > n {
+		s = s[:n]
+	}
+	return string(s)
+}
+
+// Parse 解析原始邮件，畸形的部分会被跳过而不是整体失败
+func Parse(raw []byte) (*Message, error) {
+	p := &parser{}
+	return p.parseMessage(raw, 0)
+}
+
+type parser struct {
+	parts int
+}
+
+func (p *parser) parseMessage(raw []byte, depth int) (*Message, error) {
+	if depth > MaxDepth {
+		return nil, ErrTooDeep
+	}
+	m, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		return nil, fmt.Errorf("read message failed: %w", err)
+	}
+
+	msg := &Message{
+		Header:     m.Header,
+		From:       parseAddressList(m.Header.Get("From")),
+		To:         parseAddressList(m.Header.Get("To")),
+		Cc:         parseAddressList(m.Header.Get("Cc")),
+		ReplyTo:    parseAddressList(m.Header.Get("Reply-To")),
+		Subject:    DecodeHeader(m.Header.Get("Subject")),
+		MessageID:  firstMsgID(m.Header.Get("Message-ID")),
+		InReplyTo:  firstMsgID(m.Header.Get("In-Reply-To")),
+		References: parseMsgIDs(m.Header.Get("References")),
+	}
+	if date, err := m.Header.Date(); err == nil {
+		date = date.UTC()
+		msg.Date = &date
+	}
+
+	var texts, htmls []string
+	if err := p.walk(msg, textproto.MIMEHeader(m.Header), m.Body, depth, &texts, &htmls); err != nil {
+		return nil, err
+	}
+
+	msg.Text = strings.Join(texts, "\n")
+	if len(htmls) > 0 {
+		joined := strings.Join(htmls, "\n")
+		msg.HTML = SanitizeHTML(joined)
+		if msg.Text == "" {
+			msg.Text = HTMLToText(joined)
+		}
+	}
+	return msg, nil
+}
+
+// walk 递归遍历 MIME 树，收集正文与附件
+func (p *parser) walk(msg *Message, header textproto.MIMEHeader, body io.Reader, depth int, texts, htmls *[]string) error {
+	if depth > MaxDepth {
+		return ErrTooDeep
+	}
+	p.parts++
+	if p.parts > MaxParts {
+		return ErrTooManyParts
+	}
+
+	mediaType, params, err := mime.ParseMediaType(header.Get("Content-Type"))
+	if err != nil || mediaType == "" {
+		// 缺失或畸形的 Content-Type 按 RFC 2045 视为 text/plain
+		mediaType, params = "text/plain", map[string]string{}
+	}
+
+	if strings.HasPrefix(mediaType, "multipart/") {
+		boundary := params["boundary"]
+		if boundary == "" {
+			return nil
+		}
+		mr := multipart.NewReader(body, boundary)
+		for {
+			part, err := mr.NextRawPart()
+			if err != nil {
+				// io.EOF 或缺少结束边界，已解析的部分仍然保留
+				return nil
+			}
+			if err := p.walk(msg, part.Header, part, depth+1, texts, htmls); err != nil {
+				return err
+			}
+		}
+	}
+
+	data, _ := io.ReadAll(decodeTransfer(body, header.Get("Content-Transfer-Encoding")))
+
+	disposition, dparams, _ := mime.ParseMediaType(header.Get("Content-Disposition"))
+	filename := partFilename(dparams, params)
+	isAttachment := disposition == "attachment" || filename != ""
+
+	switch {
+	case mediaType == "message/rfc822":
+		if embedded, err := p.parseMessage(data, depth+1); err == nil {
+			msg.Embedded = append(msg.Embedded, embedded)
+			if filename == "" && embedded.Subject != "" {
+				filename = embedded.Subject + ".eml"
+			}
+		} else if errors.Is(err, ErrTooDeep) || errors.Is(err, ErrTooManyParts) {
+			return err
+		}
+		isAttachment = true
+	case mediaType == "text/plain" && !isAttachment:
+		*texts = append(*texts, decodeCharset(data, params["charset"]))
+		return nil
+	case mediaType == "text/html" && !isAttachment:
+		*htmls = append(*htmls, decodeCharset(data, params["charset"]))
+		return nil
+	}
+
+	contentID := firstMsgID(header.Get("Content-ID"))
+	msg.Attachments = append(msg.Attachments, &Attachment{
+		Filename:    filename,
+		ContentType: mediaType,
+		ContentID:   contentID,
+		Inline:      disposition == "inline" || (disposition == "" && contentID != ""),
+		Size:        int64(len(data)),
+		Data:        data,
+	})
+	return nil
+}
+
+// partFilename 优先取 Content-Disposition 的 filename，其次 Content-Type 的 name
+func partFilename(dparams, params map[string]string) string {
+	name := dparams["filename"]
+	if name == "" {
+		name = params["name"]
+	}
+	if name == "" {
+		return ""
+	}
+	// 很多客户端在参数里直接使用 RFC 2047 编码或未编码的 GBK 文件名
+	name = DecodeHeader(name)
+	// 只保留文件名部分，防止路径穿越
+	if i := strings.LastIndexAny(name, `/\`); i >= 0 {
+		name = name[i+1:]
+	}
+	return strings.TrimSpace(name)
+}
+
+// decodeTransfer 按 Content-Transfer-Encoding 解码
+func decodeTransfer(r io.Reader, encoding string) io.Reader {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "base64":
+		return base64.NewDecoder(base64.StdEncoding, &base64Cleaner{r: r})
+	case "quoted-printable":
+		return quotedprintable.NewReader(r)
+	default:
+		return r
+	}
+}
+
+// base64Cleaner 过滤 base64 正文中的非法字符，兼容不规范的客户端
+type base64Cleaner struct {
+	r io.Reader
+}
+
+func (c *base64Cleaner) Read(p []byte) (int, error) {
+	for {
+		n, err := c.r.Read(p)
+		j := 0
+		for _, b := range p[:n] {
+			if (b >= 'A' && b <= 'Z') || (b >= 'a' && b <= 'z') || (b >= '0' && b <= '9') || b == '+' || b == '/' || b == '=' {
+				p[j] = b
+				j++
+			}
+		}
+		if j > 0 || err != nil {
+			return j, err
+		}
+	}
+}
+
+var addressParser = &mail.AddressParser{WordDecoder: wordDecoder}
+
+// parseAddressList 解析地址列表，无法解析时保留解码后的原始值
+func parseAddressList(v string) []*Address {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return nil
+	}
+	list, err := addressParser.ParseList(v)
+	if err != nil {
+		return []*Address{{Address: DecodeHeader(v)}}
+	}
+	out := make([]*Address, 0, len(list))
+	for _, a := range list {
+		out = append(out, &Address{Name: a.Name, Address: strings.ToLower(a.Address)})
+	}
+	return out
+}
+
+// FormatAddressList 将地址列表格式化为逗号分隔的字符串
+func FormatAddressList(list []*Address) string {
+	parts := make([]string, 0, len(list))
+	for _, a := range list {
+		parts = append(parts, a.String())
+	}
+	return strings.Join(parts, ", ")
+}
+
+// parseMsgIDs 解析 References 之类的 msg-id 列表
+func parseMsgIDs(v string) []string {
+	var ids []string
+	for {
+		start := strings.IndexByte(v, '<')
+		if start < 0 {
+			break
+		}
+		end := strings.IndexByte(v[start:], '>')
+		if end < 0 {
+			break
+		}
+		if id := strings.TrimSpace(v[start+1 : start+end]); id != "" {
+			ids = append(ids, id)
+		}
+		v = v[start+end+1:]
+	}
+	if len(ids) == 0 {
+		// 兼容没有尖括号的写法
+		ids = strings.Fields(v)
+	}
+	return ids
+}
+
+func firstMsgID(v string) string {
+	ids := parseMsgIDs(v)
+	if len(ids) == 0 {
+		return ""
+	}
+	return ids[0]
+}
@@ -0,0 +1,260 @@
+package mimeparse
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// textMessage 构造单部分的 text/plain 邮件，body 为原始字节
+func textMessage(contentType, transfer, body string) string {
+	raw := "From: alice@example.com\r\nTo: bob@myplaud.com\r\nSubject: test\r\nMIME-Version: 1.0\r\n"
+	if contentType != "" {
+		raw += "Content-Type: " + contentType + "\r\n"
+	}
+	if transfer != "" {
+		raw += "Content-Transfer-Encoding: " + transfer + "\r\n"
+	}
+	return raw + "\r\n" + body + "\r\n"
+}
+
+func mustParse(t *testing.T, raw string) *Message {
+	t.Helper()
+	msg, err := Parse([]byte(raw))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	return msg
+}
+
+func TestParseCharsets(t *testing.T) {
+	cases := []struct {
+		name        string
+		contentType string
+		transfer    string
+		body        string
+		want        string
+	}{
+		{"iso-8859-1 quoted-printable", `text/plain; charset="ISO-8859-1"`, "quoted-printable", "R=E9union =E9t=E9", "Réunion été"},
+		{"gb2312 base64", "text/plain; charset=gb2312", "base64", "xOO6w6Os\r\nysC95w==", "你好，世界"},
+		{"shift_jis quoted-printable", "text/plain; charset=Shift_JIS", "quoted-printable", "=82=B1=82=F1=82=C9=82=BF=82=CD", "こんにちは"},
+		{"big5 8bit", "text/plain; charset=big5", "8bit", "\xb7|\xc4\xb3\xac\xf6\xadn", "會議紀要"},
+		{"koi8-r base64", "text/plain; charset=koi8-r", "base64", "8NLJ18XU", "Привет"},
+		{"utf-8 base64", "text/plain; charset=utf-8", "base64", "5rWL6K+V6YKu5Lu2", "测试邮件"},
+		// 不规范的客户端会在 base64 中夹杂非法字符
+		{"base64 with junk", "text/plain; charset=utf-8", "base64", "5rWL6K+V\t6YKu*5Lu2", "测试邮件"},
+		// 未声明字符集的 8bit 正文按 GBK 兜底
+		{"undeclared gbk", "", "8bit", "\xc4\xe3\xba\xc3\xa3\xac\xca\xc0\xbd\xe7", "你好，世界"},
+		{"unknown charset gbk", "text/plain; charset=x-unknown", "8bit", "\xc4\xe3\xba\xc3", "你好"},
+		{"unknown charset utf-8", "text/plain; charset=x-unknown", "8bit", "测试", "测试"},
+		// 声明为 UTF-8 时不做猜测，连续的非法字节替换为一个 U+FFFD
+		{"invalid utf-8", "text/plain; charset=utf-8", "8bit", "ok\xc4\xe3", "ok�"},
+		{"malformed content-type", "text/plain; charset", "", "hello", "hello"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			msg := mustParse(t, textMessage(tc.contentType, tc.transfer, tc.body))
+			if got := strings.TrimSpace(msg.Text); got != tc.want {
+				t.Fatalf("text = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestParseEncodedHeaders(t *testing.T) {
+	cases := []struct {
+		name    string
+		subject string
+		want    string
+	}{
+		{"gb2312 b", "=?GB2312?B?xOO6w6OsysC95w==?=", "你好，世界"},
+		{"iso-8859-1 q", "=?iso-8859-1?Q?R=E9union_=E9t=E9?=", "Réunion été"},
+		{"shift_jis b", "=?shift_jis?B?grGC8YLJgr+CzQ==?=", "こんにちは"},
+		{"big5 b", "=?big5?B?t3zEs6z2rW4=?=", "會議紀要"},
+		// 相邻编码字之间的空白在解码后丢弃
+		{"adjacent words", "=?UTF-8?B?5rWL6K+V?= =?UTF-8?B?6YKu5Lu2?=", "测试邮件"},
+		{"mixed plain text", "Re: =?UTF-8?Q?caf=C3=A9?= tomorrow", "Re: café tomorrow"},
+		{"folded", "=?UTF-8?B?5rWL6K+V?=\r\n =?UTF-8?B?6YKu5Lu2?=", "测试邮件"},
+		{"raw 8bit gbk", "\xc4\xe3\xba\xc3", "你好"},
+		// 未知字符集原样保留编码字中的字节
+		{"unknown charset", "=?x-unknown?Q?abc?=", "abc"},
+		{"plain", "Quarterly report", "Quarterly report"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			raw := "From: alice@example.com\r\nSubject: " + tc.subject + "\r\n\r\nbody\r\n"
+			if got := mustParse(t, raw).Subject; got != tc.want {
+				t.Fatalf("subject = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestParseAddresses(t *testing.T) {
+	raw := "From: =?UTF-8?B?5rWL6K+V?= <Alice@Example.COM>\r\n" +
+		"To: \"Bob\" <bob@myplaud.com>, =?GB2312?B?xOO6ww==?= <carol@myplaud.com>\r\n" +
+		"Cc: undisclosed-recipients:;\r\n" +
+		"Reply-To: not an address\r\n" +
+		"Message-ID: <m1@example.com>\r\n" +
+		"In-Reply-To: <p1@example.com> (comment)\r\n" +
+		"References: <r1@example.com>\r\n <p1@example.com>\r\n" +
+		"\r\nbody\r\n"
+	msg := mustParse(t, raw)
+	if len(msg.From) != 1 || msg.From[0].Name != "测试" || msg.From[0].Address != "alice@example.com" {
+		t.Fatalf("from = %+v", msg.From)
+	}
+	if got := FormatAddressList(msg.To); got != "Bob <bob@myplaud.com>, 你好 <carol@myplaud.com>" {
+		t.Fatalf("to = %q", got)
+	}
+	if len(msg.ReplyTo) != 1 || msg.ReplyTo[0].Address != "not an address" {
+		t.Fatalf("unparsable reply-to should keep the raw value, got %+v", msg.ReplyTo)
+	}
+	if msg.MessageID != "m1@example.com" || msg.InReplyTo != "p1@example.com" ||
+		strings.Join(msg.References, " ") != "r1@example.com p1@example.com" {
+		t.Fatalf("ids = %q %q %v", msg.MessageID, msg.InReplyTo, msg.References)
+	}
+}
+
+// forwarded 作为附件转发的邮件，本身也带一个附件
+const forwarded = "From: carol@example.com\r\n" +
+	"Subject: =?UTF-8?B?5rWL6K+V6YKu5Lu2?=\r\n" +
+	"Content-Type: multipart/mixed; boundary=\"inner\"\r\n" +
+	"\r\n" +
+	"--inner\r\n" +
+	"Content-Type: text/plain; charset=gb2312\r\n" +
+	"Content-Transfer-Encoding: base64\r\n" +
+	"\r\n" +
+	"xOO6w6OsysC95w==\r\n" +
+	"--inner\r\n" +
+	"Content-Type: application/pdf; name=\"=?GBK?B?1tDOxLi9vP4ucGRm?=\"\r\n" +
+	"Content-Disposition: attachment\r\n" +
+	"Content-Transfer-Encoding: base64\r\n" +
+	"\r\n" +
+	"JVBERi0xLjQK\r\n" +
+	"--inner--\r\n"
+
+const nestedMessage = "From: Alice <alice@example.com>\r\n" +
+	"To: bob@myplaud.com\r\n" +
+	"Subject: Fwd: report\r\n" +
+	"Date: Sun, 1 Mar 2026 18:00:00 +0800\r\n" +
+	"MIME-Version: 1.0\r\n" +
+	"Content-Type: multipart/mixed; boundary=\"outer\"\r\n" +
+	"\r\n" +
+	"This is a multi-part message in MIME format.\r\n" +
+	"--outer\r\n" +
+	"Content-Type: multipart/alternative; boundary=\"alt\"\r\n" +
+	"\r\n" +
+	"--alt\r\n" +
+	"Content-Type: text/plain; charset=utf-8\r\n" +
+	"\r\n" +
+	"See the forwarded message.\r\n" +
+	"--alt\r\n" +
+	"Content-Type: text/html; charset=utf-8\r\n" +
+	"\r\n" +
+	"<p onclick=\"x()\">See the <b>forwarded</b> message.</p><script>alert(1)</script>\r\n" +
+	"--alt--\r\n" +
+	"--outer\r\n" +
+	"Content-Type: image/png\r\n" +
+	"Content-ID: <logo@example.com>\r\n" +
+	"Content-Transfer-Encoding: base64\r\n" +
+	"\r\n" +
+	"iVBORw0KGgo=\r\n" +
+	"--outer\r\n" +
+	"Content-Type: message/rfc822\r\n" +
+	"\r\n" +
+	forwarded +
+	"--outer\r\n" +
+	"Content-Type: text/plain; name=\"../../notes.txt\"\r\n" +
+	"Content-Disposition: attachment; filename*=UTF-8''%E7%BA%AA%E8%A6%81.txt\r\n" +
+	"\r\n" +
+	"attached text\r\n" +
+	"--outer--\r\n"
+
+func TestParseNestedMessage(t *testing.T) {
+	msg := mustParse(t, nestedMessage)
+	if msg.Date == nil || msg.Date.Hour() != 10 {
+		t.Fatalf("date should be converted to UTC, got %v", msg.Date)
+	}
+	if strings.TrimSpace(msg.Text) != "See the forwarded message." {
+		t.Fatalf("text = %q", msg.Text)
+	}
+	if strings.Contains(msg.HTML, "script") || strings.Contains(msg.HTML, "onclick") || !strings.Contains(msg.HTML, "<b>forwarded</b>") {
+		t.Fatalf("html not sanitized: %q", msg.HTML)
+	}
+
+	if len(msg.Attachments) != 3 {
+		t.Fatalf("%d attachments, want 3", len(msg.Attachments))
+	}
+	logo, eml, notes := msg.Attachments[0], msg.Attachments[1], msg.Attachments[2]
+	if !logo.Inline || logo.ContentID != "logo@example.com" || logo.Size != 8 {
+		t.Fatalf("inline image = %+v", logo)
+	}
+	if eml.ContentType != "message/rfc822" || eml.Filename != "测试邮件.eml" || eml.Inline {
+		t.Fatalf("embedded attachment = %+v", eml)
+	}
+	if notes.Filename != "纪要.txt" || string(notes.Data) != "attached text" {
+		t.Fatalf("text attachment = %+v", notes)
+	}
+	if !msg.HasAttachments() {
+		t.Fatal("HasAttachments should be true")
+	}
+
+	if len(msg.Embedded) != 1 {
+		t.Fatalf("%d embedded messages, want 1", len(msg.Embedded))
+	}
+	inner := msg.Embedded[0]
+	if inner.Subject != "测试邮件" || strings.TrimSpace(inner.Text) != "你好，世界" {
+		t.Fatalf("embedded = %q %q", inner.Subject, inner.Text)
+	}
+	if len(inner.Attachments) != 1 || inner.Attachments[0].Filename != "中文附件.pdf" || string(inner.Attachments[0].Data) != "%PDF-1.4\n" {
+		t.Fatalf("embedded attachment = %+v", inner.Attachments)
+	}
+	// 内层附件只属于内层邮件
+	if strings.Contains(msg.Text, "你好") {
+		t.Fatal("embedded text must not leak into the outer body")
+	}
+}
+
+func TestParseHTMLOnly(t *testing.T) {
+	msg := mustParse(t, textMessage("text/html; charset=utf-8", "",
+		"<html><head><title>t</title><style>p{}</style></head><body><p>Hello&nbsp;<a href=\"javascript:alert(1)\">there</a></p><div>second   line</div></body></html>"))
+	if msg.Text != "Hello there\n\nsecond line" {
+		t.Fatalf("text = %q", msg.Text)
+	}
+	if msg.HTML != "<p>Hello\u00a0<a rel=\"noopener noreferrer nofollow\" target=\"_blank\">there</a></p><div>second   line</div>\n" {
+		t.Fatalf("html = %q", msg.HTML)
+	}
+}
+
+func TestParseLimits(t *testing.T) {
+	raw := "Subject: leaf\r\n\r\nbody\r\n"
+	for i := 0; i <= MaxDepth; i++ {
+		raw = "Subject: wrap\r\nContent-Type: message/rfc822\r\n\r\n" + raw
+	}
+	if _, err := Parse([]byte(raw)); !errors.Is(err, ErrTooDeep) {
+		t.Fatalf("err = %v, want ErrTooDeep", err)
+	}
+
+	var b strings.Builder
+	b.WriteString("Content-Type: multipart/mixed; boundary=b\r\n\r\n")
+	for i := 0; i < MaxParts; i++ {
+		b.WriteString("--b\r\nContent-Type: text/plain\r\n\r\nx\r\n")
+	}
+	b.WriteString("--b--\r\n")
+	if _, err := Parse([]byte(b.String())); !errors.Is(err, ErrTooManyParts) {
+		t.Fatalf("err = %v, want ErrTooManyParts", err)
+	}
+}
+
+func TestParseMalformedMultipart(t *testing.T) {
+	// 缺少结束边界与 boundary 参数时保留已解析的部分
+	raw := "Content-Type: multipart/mixed; boundary=b\r\n\r\n--b\r\nContent-Type: text/plain\r\n\r\nfirst\r\n--b\r\nContent-Type: text/plain\r\n\r\nsecond"
+	msg := mustParse(t, raw)
+	if !strings.Contains(msg.Text, "first") {
+		t.Fatalf("text = %q", msg.Text)
+	}
+	msg = mustParse(t, "Content-Type: multipart/mixed\r\n\r\n--b\r\n\r\nbody\r\n")
+	if msg.Text != "" || len(msg.Attachments) != 0 {
+		t.Fatalf("multipart without boundary = %q %v", msg.Text, msg.Attachments)
+	}
+}
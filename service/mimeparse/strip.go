@@ -0,0 +1,178 @@
+package mimeparse
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"mime"
+	"net/textproto"
+	"strings"
+)
+
+// StrippedAttachment 被移除的附件
+type StrippedAttachment struct {
+	Filename    string
+	ContentType string
+	Size        int64
+}
+
+// StripAttachments 将非内嵌附件替换为一段说明文字，其余部分按原样保留
+// note 生成替换附件的纯文本说明；顶层不是 multipart 的邮件不做处理
+// 返回重写后的邮件与被移除的附件，没有附件被移除时返回原始内容
+func StripAttachments(raw []byte, note func(a *StrippedAttachment) string) ([]byte, []*StrippedAttachment, error) {
+	s := &stripper{note: note}
+	out, err := s.entity(raw, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(s.removed) == 0 {
+		return raw, nil, nil
+	}
+	return out, s.removed, nil
+}
+
+type stripper struct {
+	note    func(a *StrippedAttachment) string
+	parts   int
+	removed []*StrippedAttachment
+}
+
+// entity 处理一个 MIME 实体（头部 + 空行 + 正文），返回替换后的内容
+func (s *stripper) entity(raw []byte, depth int) ([]byte, error) {
+	if depth > MaxDepth {
+		return nil, ErrTooDeep
+	}
+	s.parts++
+	if s.parts > MaxParts {
+		return nil, ErrTooManyParts
+	}
+
+	headerEnd, bodyStart := splitHeader(raw)
+	if bodyStart < 0 {
+		return raw, nil
+	}
+	header, err := textproto.NewReader(bufio.NewReader(io.MultiReader(
+		bytes.NewReader(raw[:headerEnd]), strings.NewReader("\r\n\r\n")))).ReadMIMEHeader()
+	if err != nil {
+		// 头部畸形的部分原样保留
+		return raw, nil
+	}
+
+	mediaType, params, err := mime.ParseMediaType(header.Get("Content-Type"))
+	if err != nil || mediaType == "" {
+		mediaType, params = "text/plain", map[string]string{}
+	}
+
+	if strings.HasPrefix(mediaType, "multipart/") {
+		boundary := params["boundary"]
+		if boundary == "" {
+			return raw, nil
+		}
+		body, err := s.multipart(raw[bodyStart:], boundary, depth)
+		if err != nil {
+			return nil, err
+		}
+		out := make([]byte, 0, bodyStart+len(body))
+		out = append(out, raw[:bodyStart]...)
+		return append(out, body...), nil
+	}
+	if depth == 0 {
+		return raw, nil
+	}
+
+	disposition, dparams, _ := mime.ParseMediaType(header.Get("Content-Disposition"))
+	filename := partFilename(dparams, params)
+	contentID := firstMsgID(header.Get("Content-ID"))
+	isAttachment := disposition == "attachment" || filename != "" || mediaType == "message/rfc822"
+	inline := disposition == "inline" || (disposition == "" && contentID != "")
+	if !isAttachment || inline {
+		return raw, nil
+	}
+
+	size, _ := io.Copy(io.Discard, decodeTransfer(bytes.NewReader(raw[bodyStart:]), header.Get("Content-Transfer-Encoding")))
+	a := &StrippedAttachment{Filename: filename, ContentType: mediaType, Size: size}
+	s.removed = append(s.removed, a)
+
+	var b bytes.Buffer
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("Content-Transfer-Encoding: 8bit\r\n")
+	b.WriteString("Content-Disposition: inline\r\n\r\n")
+	b.WriteString(s.note(a))
+	return b.Bytes(), nil
+}
+
+// multipart 逐个处理 multipart 正文中的部分，分隔行、前言与结语按原样保留
+func (s *stripper) multipart(body []byte, boundary string, depth int) ([]byte, error) {
+	delim := []byte("--" + boundary)
+	starts := delimiterLines(body, delim)
+	if len(starts) == 0 {
+		return body, nil
+	}
+
+	var out bytes.Buffer
+	out.Grow(len(body))
+	out.Write(body[:starts[0]])
+	for i, start := range starts {
+		lineEnd := bytes.IndexByte(body[start:], '\n')
+		closing := bytes.HasPrefix(body[start+len(delim):], []byte("--"))
+		if closing || lineEnd < 0 || i == len(starts)-1 {
+			// 结束分隔行或缺少结束分隔行，剩余内容原样保留
+			out.Write(body[start:])
+			return out.Bytes(), nil
+		}
+		lineEnd += start + 1
+		out.Write(body[start:lineEnd])
+
+		// 分隔行之前的换行属于分隔符
+		part := body[lineEnd:starts[i+1]]
+		sep := []byte{}
+		if bytes.HasSuffix(part, []byte("\r\n")) {
+			part, sep = part[:len(part)-2], []byte("\r\n")
+		} else if bytes.HasSuffix(part, []byte("\n")) {
+			part, sep = part[:len(part)-1], []byte("\n")
+		}
+		replaced, err := s.entity(part, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		out.Write(replaced)
+		out.Write(sep)
+	}
+	return out.Bytes(), nil
+}
+
+// delimiterLines 返回位于行首的分隔符位置
+func delimiterLines(body, delim []byte) []int {
+	var starts []int
+	for offset := 0; offset < len(body); {
+		i := bytes.Index(body[offset:], delim)
+		if i < 0 {
+			break
+		}
+		pos := offset + i
+		if pos == 0 || body[pos-1] == '\n' {
+			starts = append(starts, pos)
+		}
+		offset = pos + len(delim)
+	}
+	return starts
+}
+
+// splitHeader 返回头部结束位置与正文起始位置，找不到空行时 bodyStart 为 -1
+func splitHeader(raw []byte) (headerEnd, bodyStart int) {
+	if bytes.HasPrefix(raw, []byte("\r\n")) {
+		return 0, 2
+	}
+	if bytes.HasPrefix(raw, []byte("\n")) {
+		return 0, 1
+	}
+	crlf := bytes.Index(raw, []byte("\r\n\r\n"))
+	lf := bytes.Index(raw, []byte("\n\n"))
+	switch {
+	case crlf >= 0 && (lf < 0 || crlf < lf):
+		return crlf, crlf + 4
+	case lf >= 0:
+		return lf, lf + 2
+	}
+	return 0, -1
+}
@@ -0,0 +1,126 @@
+package mindadvisor
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"plaud-emails/dao"
+	datamodel "plaud-emails/data/model"
+
+	"github.com/Plaud-AI/plaud-go-scaffold/pkg/logger"
+
+	"gorm.io/gorm"
+)
+
+// 错误定义
+var (
+	ErrTooManyAliases = errors.New("too many aliases")
+	ErrAliasNotFound  = errors.New("alias not found")
+)
+
+// AddAlias 为用户添加别名邮箱，地址规则与专属邮箱相同
+func (s *MindAdvisorService) AddAlias(ctx context.Context, userID, localPart string) (*datamodel.MindAdvisorAlias, error) {
+	if err := s.validateLocalPart(ctx, localPart); err != nil {
+		return nil, err
+	}
+
+	address := normalizeLocalPart(localPart) + EmailDomain
+
+	var result *datamodel.MindAdvisorAlias
+
+	err := s.userDao.ExecTx(ctx, func(tx *gorm.DB) error {
+		user, err := dao.NewMindAdvisorUserDao(tx).GetByUserID(ctx, userID)
+		if err != nil {
+			return err
+		}
+		if user == nil || user.DedicatedEmail == "" {
+			return ErrMailboxNotCreated
+		}
+		if user.IsDeleted() {
+			return ErrMailboxDeleted
+		}
+
+		aliasDao := dao.NewMindAdvisorAliasDao(tx)
+		count, err := aliasDao.CountByUserID(ctx, userID)
+		if err != nil {
+			return err
+		}
+		if count >= int64(s.conf.MaxAliases) {
+			return ErrTooManyAliases
+		}
+
+		now := time.Now()
+		if err := s.claimAddress(ctx, tx, userID, address, now); err != nil {
+			return err
+		}
+
+		alias := &datamodel.MindAdvisorAlias{UserID: userID, Address: address}
+		if err := aliasDao.Create(ctx, alias); err != nil {
+			if strings.Contains(err.Error(), "Duplicate entry") {
+				return ErrEmailAlreadyExists
+			}
+			return err
+		}
+		if err := s.registerUnicodeAddress(ctx, tx, userID, address, now); err != nil {
+			return err
+		}
+
+		result = alias
+		return nil
+	})
+
+	if err != nil {
+		logger.ErrorfCtx(ctx, "add alias error: %v", err)
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// RemoveAlias 删除别名，删除后立即停止收信，隔离期内其他用户不能申请
+func (s *MindAdvisorService) RemoveAlias(ctx context.Context, userID string, id uint64) error {
+	err := s.userDao.ExecTx(ctx, func(tx *gorm.DB) error {
+		aliasDao := dao.NewMindAdvisorAliasDao(tx)
+		alias, err := aliasDao.GetByUserIDAndID(ctx, userID, id)
+		if err != nil {
+			return err
+		}
+		if alias == nil {
+			return ErrAliasNotFound
+		}
+		if err := aliasDao.DeleteByID(ctx, alias.ID); err != nil {
+			return err
+		}
+		now := time.Now()
+		return s.retireAddress(ctx, tx, userID, alias.Address, now, now)
+	})
+
+	if err != nil && !errors.Is(err, ErrAliasNotFound) {
+		logger.ErrorfCtx(ctx, "remove alias error: %v", err)
+	}
+	return err
+}
+
+// ListAliases 查询用户的别名
+func (s *MindAdvisorService) ListAliases(ctx context.Context, userID string) ([]*datamodel.MindAdvisorAlias, error) {
+	aliases, err := s.aliasDao.ListByUserID(ctx, userID)
+	if err != nil {
+		logger.ErrorfCtx(ctx, "list aliases error: %v", err)
+		return nil, err
+	}
+	return aliases, nil
+}
+
+// resolveAlias 查询别名所属用户
+func (s *MindAdvisorService) resolveAlias(ctx context.Context, address string) (*datamodel.MindAdvisorUser, error) {
+	alias, err := s.aliasDao.GetByAddress(ctx, address)
+	if err != nil {
+		return nil, err
+	}
+	if alias == nil {
+		return nil, nil
+	}
+	return s.userDao.GetByUserID(ctx, alias.UserID)
+}
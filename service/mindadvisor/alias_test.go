@@ -0,0 +1,23 @@
+package mindadvisor
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestAddAliasValidatesLocalPart(t *testing.T) {
+	// 别名与专属邮箱使用相同的地址规则，校验失败时不访问数据库
+	s := newTestService(false)
+	cases := map[string]error{
+		"abc":       ErrInvalidLocalPartLength,
+		"jane doe":  ErrInvalidLocalPartChars,
+		"jane+news": ErrInvalidLocalPartChars,
+		"Admin":     ErrReservedWord,
+	}
+	for lp, want := range cases {
+		if _, err := s.AddAlias(context.Background(), "u1", lp); !errors.Is(err, want) {
+			t.Errorf("AddAlias(%q) err = %v, want %v", lp, err, want)
+		}
+	}
+}
@@ -0,0 +1,252 @@
+package mindadvisor
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/Plaud-AI/plaud-go-scaffold/pkg/logger"
+)
+
+// 不可用原因
+const (
+	UnavailableLength      = "length"
+	UnavailableChars       = "chars"
+	UnavailableReserved    = "reserved"
+	UnavailableTaken       = "taken"
+	UnavailableQuarantined = "quarantined"
+	UnavailableConfusable  = "confusable"
+)
+
+// MaxSuggestions 最多返回的候选名数量
+const MaxSuggestions = 5
+
+// Availability local_part 可用性检查结果
+type Availability struct {
+	LocalPart string
+	Available bool
+	// Reason 不可用原因，可用时为空
+	Reason string
+	// Suggestions 不可用时推荐的可用候选名，按推荐程度排序
+	Suggestions []string
+}
+
+// SuggestionHint 生成候选名的用户信息
+type SuggestionHint struct {
+	Name  string
+	Email string
+}
+
+// CheckAvailability 检查 local_part 能否被用户申请为专属邮箱，不可用时根据用户信息推荐候选名
+// 用户自己当前的专属邮箱视为可用
+func (s *MindAdvisorService) CheckAvailability(ctx context.Context, userID, localPart string, hint *SuggestionHint) (*Availability, error) {
+	lower := normalizeLocalPart(localPart)
+	result := &Availability{LocalPart: lower}
+
+	if err := s.validateLocalPart(ctx, localPart); err != nil {
+		switch {
+		case errors.Is(err, ErrInvalidLocalPartLength):
+			result.Reason = UnavailableLength
+		case errors.Is(err, ErrInvalidLocalPartChars):
+			result.Reason = UnavailableChars
+		default:
+			result.Reason = UnavailableReserved
+		}
+	} else {
+		reasons, err := s.addressStates(ctx, userID, []string{lower})
+		if err != nil {
+			return nil, err
+		}
+		result.Reason = reasons[lower]
+		if result.Reason == "" && s.conf.UnicodeLocalPart {
+			owner, err := s.confusableOwner(ctx, s.db, userID, lower+EmailDomain, time.Now())
+			if err != nil {
+				logger.ErrorfCtx(ctx, "check confusable address error: %v", err)
+				return nil, err
+			}
+			if owner != "" {
+				result.Reason = UnavailableConfusable
+			}
+		}
+	}
+	if result.Reason == "" {
+		result.Available = true
+		return result, nil
+	}
+
+	suggestions, err := s.suggest(ctx, userID, lower, hint)
+	if err != nil {
+		return nil, err
+	}
+	result.Suggestions = suggestions
+	return result, nil
+}
+
+// addressStates 批量查询 local_part 被占用的原因，可用的 local_part 不出现在结果中
+func (s *MindAdvisorService) addressStates(ctx context.Context, userID string, localParts []string) (map[string]string, error) {
+	addresses := make([]string, 0, len(localParts))
+	for _, lp := range localParts {
+		addresses = append(addresses, lp+EmailDomain)
+	}
+
+	reasons := make(map[string]string)
+	users, err := s.userDao.ListByDedicatedEmails(ctx, addresses)
+	if err != nil {
+		logger.ErrorfCtx(ctx, "list users by dedicated emails error: %v", err)
+		return nil, err
+	}
+	for _, u := range users {
+		if u.UserID != userID {
+			reasons[strings.TrimSuffix(u.DedicatedEmail, EmailDomain)] = UnavailableTaken
+		}
+	}
+	aliases, err := s.aliasDao.ListByAddresses(ctx, addresses)
+	if err != nil {
+		logger.ErrorfCtx(ctx, "list aliases by addresses error: %v", err)
+		return nil, err
+	}
+	for _, a := range aliases {
+		reasons[strings.TrimSuffix(a.Address, EmailDomain)] = UnavailableTaken
+	}
+	retired, err := s.retiredAddressDao.ListByAddresses(ctx, addresses)
+	if err != nil {
+		logger.ErrorfCtx(ctx, "list retired addresses error: %v", err)
+		return nil, err
+	}
+	now := time.Now()
+	for _, r := range retired {
+		lp := strings.TrimSuffix(r.Address, EmailDomain)
+		if _, ok := reasons[lp]; !ok && r.UserID != userID && r.QuarantinedAt(now) {
+			reasons[lp] = UnavailableQuarantined
+		}
+	}
+	if !s.conf.UnicodeLocalPart {
+		return reasons, nil
+	}
+	// 国际化地址的兜底地址在其有效期内同样视为占用
+	fallbacks, err := s.unicodeAddressDao.ListByFallbackAddresses(ctx, addresses)
+	if err != nil {
+		logger.ErrorfCtx(ctx, "list unicode addresses by fallback error: %v", err)
+		return nil, err
+	}
+	for _, f := range fallbacks {
+		lp := strings.TrimSuffix(f.FallbackAddress, EmailDomain)
+		if _, ok := reasons[lp]; ok {
+			continue
+		}
+		owner, err := s.liveOwner(ctx, s.db, f.Address, now)
+		if err != nil {
+			logger.ErrorfCtx(ctx, "get fallback owner error: %v", err)
+			return nil, err
+		}
+		if owner != "" && owner != userID {
+			reasons[lp] = UnavailableTaken
+		}
+	}
+	return reasons, nil
+}
+
+// suggest 生成候选名并过滤掉已被占用的
+func (s *MindAdvisorService) suggest(ctx context.Context, userID, requested string, hint *SuggestionHint) ([]string, error) {
+	candidates := s.suggestionCandidates(requested, hint, time.Now())
+	if len(candidates) == 0 {
+		return nil, nil
+	}
+
+	reasons, err := s.addressStates(ctx, userID, candidates)
+	if err != nil {
+		return nil, err
+	}
+	suggestions := make([]string, 0, MaxSuggestions)
+	for _, lp := range candidates {
+		if _, taken := reasons[lp]; taken {
+			continue
+		}
+		suggestions = append(suggestions, lp)
+		if len(suggestions) == MaxSuggestions {
+			break
+		}
+	}
+	return suggestions, nil
+}
+
+// suggestionCandidates 按推荐程度生成通过校验的候选名，不检查占用
+// 依次来自用户姓名、账户邮箱的 local_part，以及请求的 local_part 加数字后缀；
+// 国际化 local_part 以其 ASCII 转写作为基础
+func (s *MindAdvisorService) suggestionCandidates(requested string, hint *SuggestionHint, now time.Time) []string {
+	var candidates []string
+	seen := map[string]bool{requested: true}
+	add := func(lp string) {
+		lp = sanitizeLocalPart(lp)
+		if _, err := s.checkLocalPart(lp); seen[lp] || err != nil {
+			return
+		}
+		seen[lp] = true
+		candidates = append(candidates, lp)
+	}
+
+	if hint != nil {
+		if words := nameWords(hint.Name); len(words) > 0 {
+			first, last := words[0], words[len(words)-1]
+			add(strings.Join(words, "."))
+			add(strings.Join(words, ""))
+			if len(words) > 1 {
+				add(first[:1] + last)
+				add(first + "." + last[:1])
+				add(last + "." + first)
+			}
+		}
+		if at := strings.LastIndex(hint.Email, "@"); at > 0 {
+			local := strings.ToLower(hint.Email[:at])
+			local, _, _ = strings.Cut(local, SubaddressSeparator)
+			add(local)
+			add(strings.NewReplacer("_", ".", "-", ".").Replace(local))
+		}
+	}
+	if !isASCII(requested) {
+		requested = romanize(requested)
+		add(requested)
+	}
+	base := sanitizeLocalPart(requested)
+	if len(base) > LocalPartMaxLen-4 {
+		base = base[:LocalPartMaxLen-4]
+	}
+	if base != "" {
+		year := strconv.Itoa(now.Year())
+		add(base + year)
+		add(base + "." + year[2:])
+		for i := 1; i <= 9; i++ {
+			add(base + strconv.Itoa(i))
+		}
+	}
+	return candidates
+}
+
+// nameWords 将姓名拆分为小写的字母数字单词，非 ASCII 字符会被忽略
+func nameWords(name string) []string {
+	fields := strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return !(r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)))
+	})
+	return fields
+}
+
+// sanitizeLocalPart 去掉不合法字符与多余的点，并截断到最大长度
+func sanitizeLocalPart(lp string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(lp) {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+			b.WriteRune(r)
+		case r == '.' && b.Len() > 0 && !strings.HasSuffix(b.String(), "."):
+			b.WriteRune(r)
+		}
+	}
+	out := b.String()
+	if len(out) > LocalPartMaxLen {
+		out = out[:LocalPartMaxLen]
+	}
+	return strings.Trim(out, ".")
+}
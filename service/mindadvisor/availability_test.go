@@ -0,0 +1,77 @@
+package mindadvisor
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNameWords(t *testing.T) {
+	cases := map[string][]string{
+		"Jane Doe":         {"jane", "doe"},
+		"  O'Brien-Smith ": {"o", "brien", "smith"},
+		"张三 Zhang":         {"zhang"},
+		"José":             {"jos"},
+		"":                 nil,
+	}
+	for name, want := range cases {
+		if got := nameWords(name); !slices.Equal(got, want) {
+			t.Errorf("nameWords(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestSanitizeLocalPart(t *testing.T) {
+	cases := map[string]string{
+		"Jane.Doe":                  "jane.doe",
+		"..jane...doe..":            "jane.doe",
+		"jane_doe-1":                "janedoe1",
+		"张三":                        "",
+		"abcdefghij.klmnopqrstuvwx": "abcdefghij.klmnopqrs",
+	}
+	for in, want := range cases {
+		if got := sanitizeLocalPart(in); got != want {
+			t.Errorf("sanitizeLocalPart(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestSuggestionCandidates(t *testing.T) {
+	s := newTestService(false)
+	now := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	got := s.suggestionCandidates("jane", &SuggestionHint{Name: "Jane Doe", Email: "Jane_Doe+shop@gmail.com"}, now)
+	want := []string{"jane.doe", "janedoe", "jdoe", "jane.d", "doe.jane", "jane2026", "jane.26"}
+	for i := 1; i <= 9; i++ {
+		want = append(want, "jane"+string(rune('0'+i)))
+	}
+	if !slices.Equal(got, want) {
+		t.Fatalf("candidates = %q\nwant %q", got, want)
+	}
+
+	// 过短、被屏蔽或与请求相同的候选名被过滤
+	got = s.suggestionCandidates("postmaster", &SuggestionHint{Name: "Al", Email: "postmaster@example.com"}, now)
+	if len(got) != 0 {
+		t.Fatalf("candidates = %q, want none", got)
+	}
+
+	// 过长的请求截断后再加后缀
+	got = s.suggestionCandidates("abcdefghijklmnopqrst", nil, now)
+	if len(got) == 0 || got[0] != "abcdefghijklmnop2026" {
+		t.Fatalf("candidates = %q", got)
+	}
+	for _, lp := range got {
+		if _, err := s.checkLocalPart(lp); err != nil || strings.Contains(lp, "..") {
+			t.Errorf("invalid candidate %q: %v", lp, err)
+		}
+	}
+}
+
+func TestSuggestionCandidatesUnicode(t *testing.T) {
+	s := newTestService(true)
+	got := s.suggestionCandidates("张伟", nil, time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC))
+	if len(got) == 0 || !isASCII(got[0]) {
+		t.Fatalf("internationalized local_part should be suggested by its romanization, got %q", got)
+	}
+}
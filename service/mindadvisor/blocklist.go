@@ -0,0 +1,326 @@
+package mindadvisor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	datamodel "plaud-emails/data/model"
+	appconfig "plaud-emails/pkg/config"
+
+	"github.com/Plaud-AI/plaud-go-scaffold/pkg/logger"
+)
+
+// BlocklistPatternMaxLen 屏蔽规则模式的最大长度
+const BlocklistPatternMaxLen = 128
+
+// 错误定义
+var (
+	ErrInvalidBlocklistRule  = errors.New("blocklist rule must have a valid match, pattern and category")
+	ErrBlocklistRuleExists   = errors.New("blocklist rule already exists")
+	ErrBlocklistRuleNotFound = errors.New("blocklist rule not found")
+)
+
+// BlockRule 生效中的屏蔽规则
+type BlockRule struct {
+	// ID 管理后台维护的规则 id，来自配置的规则为 0
+	ID       uint64
+	Match    string
+	Pattern  string
+	Category string
+	re       *regexp.Regexp
+}
+
+// String 规则的可读描述，用于日志
+func (r *BlockRule) String() string {
+	if r.ID > 0 {
+		return fmt.Sprintf("#%d %s %s:%q", r.ID, r.Category, r.Match, r.Pattern)
+	}
+	return fmt.Sprintf("config %s %s:%q", r.Category, r.Match, r.Pattern)
+}
+
+// matches 判断小写后的 local_part 是否命中规则
+func (r *BlockRule) matches(lower string) bool {
+	switch r.Match {
+	case appconfig.BlocklistMatchExact:
+		return lower == r.Pattern
+	case appconfig.BlocklistMatchPrefix:
+		return strings.HasPrefix(lower, r.Pattern)
+	case appconfig.BlocklistMatchSubstring:
+		return strings.Contains(lower, r.Pattern)
+	case appconfig.BlocklistMatchRegex:
+		return r.re.MatchString(lower)
+	}
+	return false
+}
+
+// newBlockRule 校验并编译屏蔽规则，非正则规则的模式统一转为小写，正则规则忽略大小写匹配
+func newBlockRule(id uint64, match, pattern, category string) (*BlockRule, error) {
+	match = strings.ToLower(strings.TrimSpace(match))
+	category = strings.ToLower(strings.TrimSpace(category))
+	pattern = strings.TrimSpace(pattern)
+	if pattern == "" || len(pattern) > BlocklistPatternMaxLen {
+		return nil, ErrInvalidBlocklistRule
+	}
+	switch category {
+	case appconfig.BlocklistCategoryReserved, appconfig.BlocklistCategoryProfanity, appconfig.BlocklistCategoryImpersonation:
+	default:
+		return nil, ErrInvalidBlocklistRule
+	}
+
+	rule := &BlockRule{ID: id, Match: match, Category: category}
+	switch match {
+	case appconfig.BlocklistMatchExact, appconfig.BlocklistMatchPrefix, appconfig.BlocklistMatchSubstring:
+		rule.Pattern = strings.ToLower(pattern)
+	case appconfig.BlocklistMatchRegex:
+		// local_part 比较前已转为小写，模式中的大写字母需忽略大小写才能命中
+		re, err := regexp.Compile("(?i)" + pattern)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidBlocklistRule, err)
+		}
+		rule.Pattern = pattern
+		rule.re = re
+	default:
+		return nil, ErrInvalidBlocklistRule
+	}
+	return rule, nil
+}
+
+// Blocklist local_part 屏蔽词表，合并配置与管理后台维护的规则
+// 两部分规则分别更新，读取时不加锁
+type Blocklist struct {
+	mu          sync.Mutex
+	configRules []*BlockRule
+	adminRules  []*BlockRule
+	rules       atomic.Pointer[[]*BlockRule]
+}
+
+// NewBlocklist 根据配置创建 Blocklist
+func NewBlocklist(conf []*appconfig.BlocklistRuleConfig) *Blocklist {
+	b := &Blocklist{}
+	b.SetConfigRules(conf)
+	return b
+}
+
+// SetConfigRules 替换来自配置的规则，非法的规则会被跳过
+func (b *Blocklist) SetConfigRules(conf []*appconfig.BlocklistRuleConfig) {
+	rules := make([]*BlockRule, 0, len(conf))
+	for _, c := range conf {
+		if c == nil {
+			continue
+		}
+		rule, err := newBlockRule(0, c.Match, c.Pattern, c.Category)
+		if err != nil {
+			logger.Errorf("skip invalid blocklist rule %s:%q: %v", c.Match, c.Pattern, err)
+			continue
+		}
+		rules = append(rules, rule)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.configRules = rules
+	b.publish()
+}
+
+// setAdminRules 替换管理后台维护的规则
+func (b *Blocklist) setAdminRules(models []*datamodel.MailboxBlocklistRule) {
+	rules := make([]*BlockRule, 0, len(models))
+	for _, m := range models {
+		rule, err := newBlockRule(m.ID, m.Match, m.Pattern, m.Category)
+		if err != nil {
+			logger.Errorf("skip invalid blocklist rule #%d: %v", m.ID, err)
+			continue
+		}
+		rules = append(rules, rule)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.adminRules = rules
+	b.publish()
+}
+
+// publish 合并两部分规则，调用方需持有 mu
+func (b *Blocklist) publish() {
+	rules := make([]*BlockRule, 0, len(b.configRules)+len(b.adminRules))
+	rules = append(rules, b.configRules...)
+	rules = append(rules, b.adminRules...)
+	b.rules.Store(&rules)
+}
+
+// Match 返回 local_part 命中的第一条规则，未命中返回 nil
+func (b *Blocklist) Match(localPart string) *BlockRule {
+	rules := b.rules.Load()
+	if rules == nil {
+		return nil
+	}
+	lower := strings.ToLower(localPart)
+	for _, rule := range *rules {
+		if rule.matches(lower) {
+			return rule
+		}
+	}
+	return nil
+}
+
+// ConfigRules 返回来自配置的规则
+func (b *Blocklist) ConfigRules() []*BlockRule {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.configRules
+}
+
+// OnConfigChanged 实现 AppConfig 监听器，配置热更新时替换来自配置的屏蔽规则
+func (s *MindAdvisorService) OnConfigChanged(conf *appconfig.AppConfig) {
+	if conf == nil {
+		return
+	}
+	rules := conf.GetBlocklistConfig().Rules
+	s.blocklist.SetConfigRules(rules)
+	logger.Infof("blocklist config reloaded, %d rules", len(rules))
+}
+
+// Blocklist 返回生效中的屏蔽词表
+func (s *MindAdvisorService) Blocklist() *Blocklist {
+	return s.blocklist
+}
+
+// reloadBlocklist 从数据库重新加载管理后台维护的规则
+func (s *MindAdvisorService) reloadBlocklist(ctx context.Context) error {
+	models, err := s.blocklistRuleDao.List(ctx)
+	if err != nil {
+		return err
+	}
+	s.blocklist.setAdminRules(models)
+	return nil
+}
+
+// refreshBlocklistLoop 定期重新加载规则，使其他副本上的修改生效
+func (s *MindAdvisorService) refreshBlocklistLoop(ctx context.Context, interval time.Duration) {
+	defer s.wg.Done()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if err := s.reloadBlocklist(ctx); err != nil && ctx.Err() == nil {
+			logger.Errorf("reload blocklist rules error: %v", err)
+		}
+	}
+}
+
+// BlocklistRuleInput 屏蔽规则输入
+type BlocklistRuleInput struct {
+	Match    string
+	Pattern  string
+	Category string
+	Note     string
+}
+
+// ListBlocklistRules 查询管理后台维护的规则
+func (s *MindAdvisorService) ListBlocklistRules(ctx context.Context) ([]*datamodel.MailboxBlocklistRule, error) {
+	rules, err := s.blocklistRuleDao.List(ctx)
+	if err != nil {
+		logger.ErrorfCtx(ctx, "list blocklist rules error: %v", err)
+		return nil, err
+	}
+	return rules, nil
+}
+
+// CreateBlocklistRule 创建屏蔽规则，立即在本副本生效
+func (s *MindAdvisorService) CreateBlocklistRule(ctx context.Context, in *BlocklistRuleInput) (*datamodel.MailboxBlocklistRule, error) {
+	rule, err := newBlockRule(0, in.Match, in.Pattern, in.Category)
+	if err != nil {
+		return nil, err
+	}
+	existing, err := s.blocklistRuleDao.GetByMatchAndPattern(ctx, rule.Match, rule.Pattern)
+	if err != nil {
+		logger.ErrorfCtx(ctx, "get blocklist rule error: %v", err)
+		return nil, err
+	}
+	if existing != nil {
+		return nil, ErrBlocklistRuleExists
+	}
+
+	m := &datamodel.MailboxBlocklistRule{
+		Match:    rule.Match,
+		Pattern:  rule.Pattern,
+		Category: rule.Category,
+		Note:     strings.TrimSpace(in.Note),
+	}
+	if err := s.blocklistRuleDao.Create(ctx, m); err != nil {
+		logger.ErrorfCtx(ctx, "create blocklist rule error: %v", err)
+		return nil, err
+	}
+	logger.InfofCtx(ctx, "blocklist rule #%d created: %s", m.ID, rule)
+	s.reloadBlocklistAfterChange(ctx)
+	return m, nil
+}
+
+// UpdateBlocklistRule 更新屏蔽规则，立即在本副本生效
+func (s *MindAdvisorService) UpdateBlocklistRule(ctx context.Context, id uint64, in *BlocklistRuleInput) (*datamodel.MailboxBlocklistRule, error) {
+	rule, err := newBlockRule(id, in.Match, in.Pattern, in.Category)
+	if err != nil {
+		return nil, err
+	}
+	m, err := s.blocklistRuleDao.GetByID(ctx, id)
+	if err != nil {
+		logger.ErrorfCtx(ctx, "get blocklist rule error: %v", err)
+		return nil, err
+	}
+	if m == nil {
+		return nil, ErrBlocklistRuleNotFound
+	}
+	existing, err := s.blocklistRuleDao.GetByMatchAndPattern(ctx, rule.Match, rule.Pattern)
+	if err != nil {
+		logger.ErrorfCtx(ctx, "get blocklist rule error: %v", err)
+		return nil, err
+	}
+	if existing != nil && existing.ID != id {
+		return nil, ErrBlocklistRuleExists
+	}
+
+	m.Match = rule.Match
+	m.Pattern = rule.Pattern
+	m.Category = rule.Category
+	m.Note = strings.TrimSpace(in.Note)
+	if err := s.blocklistRuleDao.Update(ctx, m); err != nil {
+		logger.ErrorfCtx(ctx, "update blocklist rule error: %v", err)
+		return nil, err
+	}
+	logger.InfofCtx(ctx, "blocklist rule updated: %s", rule)
+	s.reloadBlocklistAfterChange(ctx)
+	return m, nil
+}
+
+// DeleteBlocklistRule 删除屏蔽规则，立即在本副本生效
+func (s *MindAdvisorService) DeleteBlocklistRule(ctx context.Context, id uint64) error {
+	deleted, err := s.blocklistRuleDao.DeleteByID(ctx, id)
+	if err != nil {
+		logger.ErrorfCtx(ctx, "delete blocklist rule error: %v", err)
+		return err
+	}
+	if !deleted {
+		return ErrBlocklistRuleNotFound
+	}
+	logger.InfofCtx(ctx, "blocklist rule #%d deleted", id)
+	s.reloadBlocklistAfterChange(ctx)
+	return nil
+}
+
+// reloadBlocklistAfterChange 规则变更后重新加载，失败时等待下次定期刷新
+func (s *MindAdvisorService) reloadBlocklistAfterChange(ctx context.Context) {
+	if err := s.reloadBlocklist(ctx); err != nil {
+		logger.ErrorfCtx(ctx, "reload blocklist rules error: %v", err)
+	}
+}
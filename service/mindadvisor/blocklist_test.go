@@ -0,0 +1,85 @@
+package mindadvisor
+
+import (
+	"errors"
+	"testing"
+
+	datamodel "plaud-emails/data/model"
+	appconfig "plaud-emails/pkg/config"
+)
+
+func TestBlocklistMatch(t *testing.T) {
+	b := NewBlocklist([]*appconfig.BlocklistRuleConfig{
+		{Match: appconfig.BlocklistMatchExact, Pattern: " Admin ", Category: appconfig.BlocklistCategoryReserved},
+		{Match: appconfig.BlocklistMatchPrefix, Pattern: "Support", Category: appconfig.BlocklistCategoryImpersonation},
+		{Match: appconfig.BlocklistMatchSubstring, Pattern: "badword", Category: appconfig.BlocklistCategoryProfanity},
+		{Match: appconfig.BlocklistMatchRegex, Pattern: `^Pay(Pal|ment)s?\d*$`, Category: appconfig.BlocklistCategoryImpersonation},
+		nil,
+		{Match: appconfig.BlocklistMatchRegex, Pattern: `(`, Category: appconfig.BlocklistCategoryReserved},
+		{Match: "glob", Pattern: "x*", Category: appconfig.BlocklistCategoryReserved},
+	})
+	if got := len(b.ConfigRules()); got != 4 {
+		t.Fatalf("%d rules loaded, invalid rules should be skipped", got)
+	}
+
+	cases := map[string]string{
+		"admin":        appconfig.BlocklistMatchExact,
+		"ADMIN":        appconfig.BlocklistMatchExact,
+		"admins":       "",
+		"support-team": appconfig.BlocklistMatchPrefix,
+		"my.support":   "",
+		"xbadwordx":    appconfig.BlocklistMatchSubstring,
+		"paypal":       appconfig.BlocklistMatchRegex,
+		"PayPal2":      appconfig.BlocklistMatchRegex,
+		"payments":     appconfig.BlocklistMatchRegex,
+		"paypalx":      "",
+		"jane":         "",
+	}
+	for localPart, want := range cases {
+		rule := b.Match(localPart)
+		got := ""
+		if rule != nil {
+			got = rule.Match
+		}
+		if got != want {
+			t.Errorf("Match(%q) = %q, want %q", localPart, got, want)
+		}
+	}
+}
+
+func TestBlocklistAdminRules(t *testing.T) {
+	b := NewBlocklist(nil)
+	b.setAdminRules([]*datamodel.MailboxBlocklistRule{
+		{ID: 7, Match: appconfig.BlocklistMatchRegex, Pattern: `^CEO\d+$`, Category: appconfig.BlocklistCategoryImpersonation},
+		{ID: 8, Match: appconfig.BlocklistMatchExact, Pattern: "", Category: appconfig.BlocklistCategoryReserved},
+	})
+	rule := b.Match("ceo1")
+	if rule == nil || rule.ID != 7 || rule.Pattern != `^CEO\d+$` {
+		t.Fatalf("admin regex with upper-case letters should match, got %v", rule)
+	}
+
+	// 替换配置规则不影响管理后台维护的规则
+	b.SetConfigRules([]*appconfig.BlocklistRuleConfig{{Match: appconfig.BlocklistMatchExact, Pattern: "root", Category: appconfig.BlocklistCategoryReserved}})
+	if b.Match("root") == nil || b.Match("ceo2") == nil {
+		t.Fatal("config and admin rules should both be active")
+	}
+	b.setAdminRules(nil)
+	if b.Match("ceo2") != nil {
+		t.Fatal("removed admin rule should no longer match")
+	}
+}
+
+func TestNewBlockRuleInvalid(t *testing.T) {
+	cases := []struct{ match, pattern, category string }{
+		{appconfig.BlocklistMatchExact, "", appconfig.BlocklistCategoryReserved},
+		{appconfig.BlocklistMatchExact, "x", "spam"},
+		{"glob", "x", appconfig.BlocklistCategoryReserved},
+		{appconfig.BlocklistMatchRegex, "[a-", appconfig.BlocklistCategoryReserved},
+		{appconfig.BlocklistMatchExact, string(make([]byte, BlocklistPatternMaxLen+1)), appconfig.BlocklistCategoryReserved},
+	}
+	for _, c := range cases {
+		if _, err := newBlockRule(0, c.match, c.pattern, c.category); !errors.Is(err, ErrInvalidBlocklistRule) {
+			t.Errorf("newBlockRule(%q, %q, %q) err = %v, want ErrInvalidBlocklistRule", c.match, c.pattern, c.category, err)
+		}
+	}
+}
@@ -0,0 +1,325 @@
+package mindadvisor
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"plaud-emails/dao"
+	datamodel "plaud-emails/data/model"
+	"plaud-emails/pkg/textutil"
+
+	"github.com/Plaud-AI/plaud-go-scaffold/pkg/logger"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// purgeBatchSize 每轮清除的邮箱数量
+	purgeBatchSize = 20
+	// purgeLease 抢占清除任务后推迟 purge_at 的时长，副本异常退出时到期后由其他副本重试
+	purgeLease = time.Hour
+	// auditReasonMaxLen 审计原因的最大长度
+	auditReasonMaxLen = 512
+)
+
+// ErrMailboxDeleted 邮箱已删除，等待清除
+var ErrMailboxDeleted = errors.New("mailbox has been deleted")
+
+// MailboxPurger 邮箱保留期到期后清除关联数据，需要可重复执行
+// 返回错误时邮箱记录保留并在 purgeLease 后重试，仍有需要保留的数据（如设置了法律保留的邮件）时同样返回错误
+type MailboxPurger interface {
+	PurgeUser(ctx context.Context, userID string) error
+}
+
+// AddPurger 注册数据清除回调，需在服务启动前调用
+func (s *MindAdvisorService) AddPurger(p MailboxPurger) {
+	s.purgers = append(s.purgers, p)
+}
+
+// DeactivateMailbox 停用专属邮箱，停用期间新邮件被退回，别名与旧地址同样停止收信
+func (s *MindAdvisorService) DeactivateMailbox(ctx context.Context, userID, actor, reason string) (*datamodel.MindAdvisorUser, error) {
+	return s.transitMailbox(ctx, userID, actor, reason, datamodel.MailboxAuditDeactivate, deactivate)
+}
+
+// ReactivateMailbox 重新启用已停用的专属邮箱，恢复收信
+func (s *MindAdvisorService) ReactivateMailbox(ctx context.Context, userID, actor, reason string) (*datamodel.MindAdvisorUser, error) {
+	return s.transitMailbox(ctx, userID, actor, reason, datamodel.MailboxAuditReactivate, reactivate)
+}
+
+// DeleteMailbox 软删除专属邮箱，立即停止收信与外部邮箱同步
+// 保留 DeletionRetentionDays 后由后台任务清除邮件、S3 对象、绑定邮箱与别名
+func (s *MindAdvisorService) DeleteMailbox(ctx context.Context, userID, actor, reason string) (*datamodel.MindAdvisorUser, error) {
+	return s.transitMailbox(ctx, userID, actor, reason, datamodel.MailboxAuditDelete, s.markDeleted)
+}
+
+// deactivate 停用有效的邮箱，已停用时返回 false
+func deactivate(user *datamodel.MindAdvisorUser, now time.Time) bool {
+	if !user.IsActive() {
+		return false
+	}
+	user.Status = datamodel.MindAdvisorStatusInactive
+	user.DeactivatedAt = &now
+	return true
+}
+
+// reactivate 恢复已停用的邮箱，已有效时返回 false
+func reactivate(user *datamodel.MindAdvisorUser, now time.Time) bool {
+	if user.IsActive() {
+		return false
+	}
+	user.Status = datamodel.MindAdvisorStatusActive
+	user.DeactivatedAt = nil
+	return true
+}
+
+// markDeleted 软删除邮箱并设置清除时间
+func (s *MindAdvisorService) markDeleted(user *datamodel.MindAdvisorUser, now time.Time) bool {
+	user.Status = datamodel.MindAdvisorStatusSoftDeleted
+	user.DeletedAt = &now
+	purgeAt := now.Add(time.Duration(s.conf.DeletionRetentionDays) * 24 * time.Hour)
+	user.PurgeAt = &purgeAt
+	return true
+}
+
+// SetLegalHold 设置或解除邮箱的法律保留，保留期间不执行保留期清理与删除后的数据清除
+func (s *MindAdvisorService) SetLegalHold(ctx context.Context, userID string, hold bool, actor, reason string) (*datamodel.MindAdvisorUser, error) {
+	var result *datamodel.MindAdvisorUser
+
+	err := s.userDao.ExecTx(ctx, func(tx *gorm.DB) error {
+		txDao := dao.NewMindAdvisorUserDao(tx)
+		// Update 整行保存，加锁读取避免覆盖并发事务写入的状态、保留或地址字段
+		user, err := txDao.GetByUserIDForUpdate(ctx, userID)
+		if err != nil {
+			return err
+		}
+		if user == nil || user.DedicatedEmail == "" {
+			return ErrMailboxNotCreated
+		}
+		result = user
+		if user.LegalHold == hold {
+			return nil
+		}
+
+		user.LegalHold = hold
+		if err := txDao.Update(ctx, user); err != nil {
+			return err
+		}
+		action := datamodel.MailboxAuditHold
+		if !hold {
+			action = datamodel.MailboxAuditRelease
+		}
+		return s.audit(ctx, tx, user, action, user.Status, actor, reason)
+	})
+
+	if err != nil {
+		if !errors.Is(err, ErrMailboxNotCreated) {
+			logger.ErrorfCtx(ctx, "set mailbox legal hold error: %v", err)
+		}
+		return nil, err
+	}
+	return result, nil
+}
+
+// transitMailbox 在事务中变更邮箱状态并写入审计记录
+// apply 返回 false 表示已处于目标状态，直接返回当前记录；已删除的邮箱不能再变更
+func (s *MindAdvisorService) transitMailbox(ctx context.Context, userID, actor, reason, action string,
+	apply func(user *datamodel.MindAdvisorUser, now time.Time) bool) (*datamodel.MindAdvisorUser, error) {
+	var result *datamodel.MindAdvisorUser
+
+	err := s.userDao.ExecTx(ctx, func(tx *gorm.DB) error {
+		txDao := dao.NewMindAdvisorUserDao(tx)
+		// Update 整行保存，加锁读取避免覆盖并发事务写入的状态、保留或地址字段
+		user, err := txDao.GetByUserIDForUpdate(ctx, userID)
+		if err != nil {
+			return err
+		}
+		if user == nil || user.DedicatedEmail == "" {
+			return ErrMailboxNotCreated
+		}
+		if user.IsDeleted() {
+			if action == datamodel.MailboxAuditDelete {
+				result = user
+				return nil
+			}
+			return ErrMailboxDeleted
+		}
+
+		from := user.Status
+		if !apply(user, time.Now()) {
+			result = user
+			return nil
+		}
+		if err := txDao.Update(ctx, user); err != nil {
+			return err
+		}
+		if action == datamodel.MailboxAuditDelete {
+			if err := dao.NewMindAdvisorLinkedEmailDao(tx).SoftDeleteByUserID(ctx, userID); err != nil {
+				return err
+			}
+		}
+		if err := s.audit(ctx, tx, user, action, from, actor, reason); err != nil {
+			return err
+		}
+
+		result = user
+		return nil
+	})
+
+	if err != nil {
+		if !errors.Is(err, ErrMailboxNotCreated) && !errors.Is(err, ErrMailboxDeleted) {
+			logger.ErrorfCtx(ctx, "%s mailbox error: %v", action, err)
+		}
+		return nil, err
+	}
+	return result, nil
+}
+
+// audit 写入审计记录，需在状态变更的事务中调用
+func (s *MindAdvisorService) audit(ctx context.Context, tx *gorm.DB, user *datamodel.MindAdvisorUser, action string, from int16, actor, reason string) error {
+	reason = textutil.Truncate(strings.TrimSpace(reason), auditReasonMaxLen)
+	logger.InfofCtx(ctx, "mailbox %s of user %s: %s by %s", user.DedicatedEmail, user.UserID, action, actor)
+	return dao.NewMindAdvisorMailboxAuditDao(tx).Create(ctx, &datamodel.MindAdvisorMailboxAudit{
+		UserID:         user.UserID,
+		DedicatedEmail: user.DedicatedEmail,
+		Action:         action,
+		FromStatus:     from,
+		ToStatus:       user.Status,
+		Actor:          actor,
+		Reason:         reason,
+	})
+}
+
+// ListMailboxAudits 按 id 倒序分页查询用户邮箱的审计记录
+func (s *MindAdvisorService) ListMailboxAudits(ctx context.Context, userID string, beforeID uint64, limit int) ([]*datamodel.MindAdvisorMailboxAudit, error) {
+	audits, err := s.auditDao.ListByUserID(ctx, userID, beforeID, limit)
+	if err != nil {
+		logger.ErrorfCtx(ctx, "list mailbox audits error: %v", err)
+		return nil, err
+	}
+	return audits, nil
+}
+
+// purgeLoop 定期清除保留期已到的邮箱
+func (s *MindAdvisorService) purgeLoop(ctx context.Context, interval time.Duration) {
+	defer s.wg.Done()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		s.purgeDue(ctx)
+	}
+}
+
+// purgeDue 清除一批到期的邮箱，通过推迟 purge_at 抢占，避免多副本重复执行
+func (s *MindAdvisorService) purgeDue(ctx context.Context) {
+	now := time.Now()
+	users, err := s.userDao.ListPurgeDue(ctx, now, purgeBatchSize)
+	if err != nil {
+		if ctx.Err() == nil {
+			logger.Errorf("list mailboxes due for purge error: %v", err)
+		}
+		return
+	}
+
+	for _, user := range users {
+		if ctx.Err() != nil {
+			return
+		}
+		ok, err := s.userDao.ClaimPurge(ctx, user.ID, *user.PurgeAt, now.Add(purgeLease))
+		if err != nil {
+			logger.Errorf("claim mailbox purge of user %s error: %v", user.UserID, err)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		if err := s.purgeMailbox(ctx, user); err != nil {
+			logger.Errorf("purge mailbox of user %s error: %v, retry after %s", user.UserID, err, purgeLease)
+		}
+	}
+}
+
+// purgeMailbox 清除邮箱的全部数据，专属邮箱与别名地址进入隔离期，审计记录保留
+// 处于法律保留的邮箱不清除，抢占推迟的 purge_at 到期后再次检查
+// 邮箱下仍有法律保留的邮件时由 MailboxPurger 返回错误，邮箱记录同样保留到下次检查
+func (s *MindAdvisorService) purgeMailbox(ctx context.Context, user *datamodel.MindAdvisorUser) error {
+	if user.LegalHold {
+		logger.Infof("mailbox of user %s is under legal hold, purge suspended", user.UserID)
+		return nil
+	}
+	for _, p := range s.purgers {
+		if err := p.PurgeUser(ctx, user.UserID); err != nil {
+			return err
+		}
+	}
+
+	return s.userDao.ExecTx(ctx, func(tx *gorm.DB) error {
+		now := time.Now()
+		addresses := []string{user.DedicatedEmail}
+
+		aliasDao := dao.NewMindAdvisorAliasDao(tx)
+		aliases, err := aliasDao.ListByUserID(ctx, user.UserID)
+		if err != nil {
+			return err
+		}
+		for _, alias := range aliases {
+			addresses = append(addresses, alias.Address)
+		}
+		if err := aliasDao.DeleteByUserID(ctx, user.UserID); err != nil {
+			return err
+		}
+
+		if s.conf.UnicodeLocalPart {
+			unicodeDao := dao.NewMindAdvisorUnicodeAddressDao(tx)
+			addrs, err := unicodeDao.ListByUserID(ctx, user.UserID)
+			if err != nil {
+				return err
+			}
+			for _, addr := range addrs {
+				addresses = append(addresses, addr.FallbackAddress)
+			}
+			if err := unicodeDao.DeleteByUserID(ctx, user.UserID); err != nil {
+				return err
+			}
+		}
+
+		// 释放的地址不再收信，隔离期内其他用户不能申请
+		retiredDao := dao.NewMindAdvisorRetiredAddressDao(tx)
+		for _, address := range addresses {
+			retired, err := retiredDao.GetByAddress(ctx, address)
+			if err != nil {
+				return err
+			}
+			if retired != nil {
+				continue
+			}
+			if err := s.retireAddress(ctx, tx, user.UserID, address, now, now); err != nil {
+				return err
+			}
+		}
+
+		if err := dao.NewMindAdvisorTagRuleDao(tx).DeleteByUserID(ctx, user.UserID); err != nil {
+			return err
+		}
+		if err := dao.NewRetentionPolicyDao(tx).DeleteByUserID(ctx, user.UserID); err != nil {
+			return err
+		}
+		if err := dao.NewLinkedEmailSyncEventDao(tx).DeleteByUserID(ctx, user.UserID); err != nil {
+			return err
+		}
+		if err := dao.NewMindAdvisorLinkedEmailDao(tx).DeleteByUserID(ctx, user.UserID); err != nil {
+			return err
+		}
+		if err := dao.NewMindAdvisorUserDao(tx).DeleteByID(ctx, user.ID); err != nil {
+			return err
+		}
+		return s.audit(ctx, tx, user, datamodel.MailboxAuditPurge, user.Status, datamodel.MailboxAuditActorSystem, "retention expired")
+	})
+}
@@ -0,0 +1,78 @@
+package mindadvisor
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	datamodel "plaud-emails/data/model"
+	appconfig "plaud-emails/pkg/config"
+)
+
+func TestMailboxTransitions(t *testing.T) {
+	now := time.Date(2026, 3, 1, 8, 0, 0, 0, time.UTC)
+	user := &datamodel.MindAdvisorUser{Status: datamodel.MindAdvisorStatusActive}
+
+	if !deactivate(user, now) || user.IsActive() || user.DeactivatedAt == nil || !user.DeactivatedAt.Equal(now) {
+		t.Fatalf("deactivate: %+v", user)
+	}
+	if deactivate(user, now.Add(time.Hour)) || !user.DeactivatedAt.Equal(now) {
+		t.Fatal("deactivating an inactive mailbox should be a no-op")
+	}
+	if !reactivate(user, now) || !user.IsActive() || user.DeactivatedAt != nil {
+		t.Fatalf("reactivate: %+v", user)
+	}
+	if reactivate(user, now) {
+		t.Fatal("reactivating an active mailbox should be a no-op")
+	}
+
+	s := newTestService(false)
+	s.conf.DeletionRetentionDays = appconfig.DefaultMailboxDeletionRetention
+	if !s.markDeleted(user, now) || !user.IsDeleted() || !user.DeletedAt.Equal(now) {
+		t.Fatalf("markDeleted: %+v", user)
+	}
+	if want := now.AddDate(0, 0, appconfig.DefaultMailboxDeletionRetention); !user.PurgeAt.Equal(want) {
+		t.Fatalf("purge_at = %v, want %v", user.PurgeAt, want)
+	}
+}
+
+// fakePurger 记录调用并返回预设的错误
+type fakePurger struct {
+	calls []string
+	err   error
+}
+
+func (f *fakePurger) PurgeUser(_ context.Context, userID string) error {
+	f.calls = append(f.calls, userID)
+	return f.err
+}
+
+func TestPurgeMailboxLegalHold(t *testing.T) {
+	s := newTestService(false)
+	p := &fakePurger{}
+	s.AddPurger(p)
+	user := &datamodel.MindAdvisorUser{UserID: "u1", Status: datamodel.MindAdvisorStatusSoftDeleted, LegalHold: true}
+	if err := s.purgeMailbox(context.Background(), user); err != nil {
+		t.Fatalf("purge of a held mailbox: %v", err)
+	}
+	if len(p.calls) != 0 {
+		t.Fatal("held mailbox must not be purged")
+	}
+}
+
+func TestPurgeMailboxPurgerError(t *testing.T) {
+	// 清除回调失败时保留邮箱记录，后续回调与数据库清理都不执行
+	s := newTestService(false)
+	failing := &fakePurger{err: errors.New("legal hold")}
+	next := &fakePurger{}
+	s.AddPurger(failing)
+	s.AddPurger(next)
+	user := &datamodel.MindAdvisorUser{UserID: "u1", Status: datamodel.MindAdvisorStatusSoftDeleted}
+	if err := s.purgeMailbox(context.Background(), user); !errors.Is(err, failing.err) {
+		t.Fatalf("err = %v, want the purger error", err)
+	}
+	if len(failing.calls) != 1 || len(next.calls) != 0 {
+		t.Fatalf("calls = %v %v", failing.calls, next.calls)
+	}
+}
@@ -0,0 +1,416 @@
+package mindadvisor
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// romanize 将国际化 local_part 转写为 ASCII，用于生成兜底地址
+// 汉字使用普通话拼音（仅覆盖常用姓名用字），假名使用平文式罗马字，谚文使用韩国文化观光部罗马字，
+// 拉丁、西里尔、希腊字母去掉附加符号后转写；无法转写的字符被跳过
+func romanize(localPart string) string {
+	var b strings.Builder
+	runes := []rune(norm.NFC.String(localPart))
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case r < unicode.MaxASCII:
+			b.WriteRune(r)
+		case unicode.Is(unicode.Hiragana, r), unicode.Is(unicode.Katakana, r), r == 'ー':
+			n := romanizeKana(&b, runes[i:])
+			i += n - 1
+		case r >= hangulBase && r <= hangulLast:
+			b.WriteString(romanizeHangul(r))
+		case unicode.Is(unicode.Han, r):
+			if py, ok := pinyinTable[r]; ok {
+				b.WriteString(py)
+			}
+		default:
+			b.WriteString(romanizeLetter(r))
+		}
+	}
+	return b.String()
+}
+
+// romanizeLetter 转写拉丁、西里尔与希腊字母，转写表中没有的字母先分解去掉附加符号
+// й 等带附加符号的字母有独立的转写，需在分解前查表
+func romanizeLetter(r rune) string {
+	if s, ok := letterTable[unicode.ToLower(r)]; ok {
+		return s
+	}
+	var out strings.Builder
+	for _, d := range norm.NFD.String(string(r)) {
+		if unicode.Is(unicode.Mn, d) {
+			continue
+		}
+		if d < unicode.MaxASCII {
+			out.WriteRune(d)
+		} else if s, ok := letterTable[unicode.ToLower(d)]; ok {
+			out.WriteString(s)
+		}
+	}
+	return out.String()
+}
+
+var letterTable = map[rune]string{
+	// 拉丁字母
+	'ß': "ss", 'æ': "ae", 'ø': "o", 'œ': "oe", 'đ': "d", 'ð': "d", 'ł': "l", 'þ': "th", 'ı': "i",
+	// 西里尔字母
+	'а': "a", 'б': "b", 'в': "v", 'г': "g", 'д': "d", 'е': "e", 'ж': "zh", 'з': "z", 'и': "i",
+	'й': "y", 'к': "k", 'л': "l", 'м': "m", 'н': "n", 'о': "o", 'п': "p", 'р': "r", 'с': "s",
+	'т': "t", 'у': "u", 'ф': "f", 'х': "kh", 'ц': "ts", 'ч': "ch", 'ш': "sh", 'щ': "shch",
+	'ъ': "", 'ы': "y", 'ь': "", 'э': "e", 'ю': "yu", 'я': "ya", 'і': "i", 'є': "ye", 'ґ': "g",
+	// 希腊字母
+	'α': "a", 'β': "v", 'γ': "g", 'δ': "d", 'ε': "e", 'ζ': "z", 'η': "i", 'θ': "th", 'ι': "i",
+	'κ': "k", 'λ': "l", 'μ': "m", 'ν': "n", 'ξ': "x", 'ο': "o", 'π': "p", 'ρ': "r", 'σ': "s",
+	'ς': "s", 'τ': "t", 'υ': "y", 'φ': "f", 'χ': "ch", 'ψ': "ps", 'ω': "o",
+}
+
+// kanaTable 平假名的平文式罗马字，片假名先映射到平假名
+var kanaTable = map[rune]string{
+	'あ': "a", 'い': "i", 'う': "u", 'え': "e", 'お': "o",
+	'か': "ka", 'き': "ki", 'く': "ku", 'け': "ke", 'こ': "ko",
+	'が': "ga", 'ぎ': "gi", 'ぐ': "gu", 'げ': "ge", 'ご': "go",
+	'さ': "sa", 'し': "shi", 'す': "su", 'せ': "se", 'そ': "so",
+	'ざ': "za", 'じ': "ji", 'ず': "zu", 'ぜ': "ze", 'ぞ': "zo",
+	'た': "ta", 'ち': "chi", 'つ': "tsu", 'て': "te", 'と': "to",
+	'だ': "da", 'ぢ': "ji", 'づ': "zu", 'で': "de", 'ど': "do",
+	'な': "na", 'に': "ni", 'ぬ': "nu", 'ね': "ne", 'の': "no",
+	'は': "ha", 'ひ': "hi", 'ふ': "fu", 'へ': "he", 'ほ': "ho",
+	'ば': "ba", 'び': "bi", 'ぶ': "bu", 'べ': "be", 'ぼ': "bo",
+	'ぱ': "pa", 'ぴ': "pi", 'ぷ': "pu", 'ぺ': "pe", 'ぽ': "po",
+	'ま': "ma", 'み': "mi", 'む': "mu", 'め': "me", 'も': "mo",
+	'や': "ya", 'ゆ': "yu", 'よ': "yo",
+	'ら': "ra", 'り': "ri", 'る': "ru", 'れ': "re", 'ろ': "ro",
+	'わ': "wa", 'ゐ': "i", 'ゑ': "e", 'を': "o", 'ん': "n", 'ゔ': "vu",
+	'ぁ': "a", 'ぃ': "i", 'ぅ': "u", 'ぇ': "e", 'ぉ': "o", 'ゎ': "wa",
+}
+
+// romanizeKana 转写从 runes[0] 开始的一个假名，处理拗音、促音与长音，返回消耗的字符数
+func romanizeKana(b *strings.Builder, runes []rune) int {
+	r := toHiragana(runes[0])
+	switch r {
+	case 'ー':
+		// 长音省略
+		return 1
+	case 'っ':
+		// 促音重复下一个假名的辅音
+		if len(runes) > 1 {
+			var next strings.Builder
+			romanizeKana(&next, runes[1:])
+			if s := next.String(); s != "" && !strings.ContainsRune("aiueon", rune(s[0])) {
+				if strings.HasPrefix(s, "ch") {
+					b.WriteByte('t')
+				} else {
+					b.WriteByte(s[0])
+				}
+			}
+		}
+		return 1
+	}
+
+	s, ok := kanaTable[r]
+	if !ok {
+		return 1
+	}
+	if len(runes) > 1 {
+		switch next := toHiragana(runes[1]); next {
+		case 'ゃ', 'ゅ', 'ょ':
+			// 拗音：きゃ kya、しゃ sha、ちゃ cha、じゃ ja
+			if strings.HasSuffix(s, "i") && len(s) > 1 {
+				stem := strings.TrimSuffix(s, "i")
+				y := kanaTable[next+1]
+				if strings.HasSuffix(stem, "sh") || strings.HasSuffix(stem, "ch") || stem == "j" {
+					y = y[1:]
+				}
+				b.WriteString(stem + y)
+				return 2
+			}
+		case 'ぁ', 'ぃ', 'ぅ', 'ぇ', 'ぉ':
+			// 外来音：ふぁ fa、てぃ ti
+			if len(s) > 1 {
+				b.WriteString(s[:len(s)-1] + kanaTable[next])
+				return 2
+			}
+		}
+	}
+	b.WriteString(s)
+	return 1
+}
+
+// toHiragana 片假名映射到对应的平假名
+func toHiragana(r rune) rune {
+	if r >= 'ァ' && r <= 'ヶ' {
+		return r - ('ァ' - 'ぁ')
+	}
+	return r
+}
+
+const (
+	hangulBase = 0xAC00
+	hangulLast = 0xD7A3
+)
+
+var (
+	hangulInitials = []string{"g", "kk", "n", "d", "tt", "r", "m", "b", "pp", "s", "ss", "", "j", "jj", "ch", "k", "t", "p", "h"}
+	hangulVowels   = []string{"a", "ae", "ya", "yae", "eo", "e", "yeo", "ye", "o", "wa", "wae", "oe", "yo", "u", "wo", "we", "wi", "yu", "eu", "ui", "i"}
+	hangulFinals   = []string{"", "k", "k", "k", "n", "n", "n", "t", "l", "k", "m", "l", "l", "l", "p", "l", "m", "p", "p", "t", "t", "ng", "t", "t", "k", "t", "p", "t"}
+)
+
+// romanizeHangul 按音节分解转写谚文，不处理音节间的音变
+func romanizeHangul(r rune) string {
+	i := int(r - hangulBase)
+	return hangulInitials[i/588] + hangulVowels[(i%588)/28] + hangulFinals[i%28]
+}
+
+// pinyinTable 常用姓名用字的拼音，多音字取姓名中的常见读音
+var pinyinTable = func() map[rune]string {
+	table := make(map[rune]string)
+	for _, line := range strings.Split(pinyinData, "\n") {
+		py, chars, ok := strings.Cut(strings.TrimSpace(line), " ")
+		if !ok {
+			continue
+		}
+		for _, c := range chars {
+			table[c] = py
+		}
+	}
+	return table
+}()
+
+const pinyinData = `
+ai 艾爱
+an 安
+ao 敖奥
+bai 白百柏
+ban 班
+bao 包宝保鲍寶
+bei 贝北
+bin 彬斌滨宾
+bing 冰兵秉
+bo 博波伯
+bu 步卜
+cai 蔡才彩财
+cao 曹草
+cen 岑
+chang 常昌畅
+chao 超朝巢
+chen 陈晨辰臣陳
+cheng 程成诚城承誠
+chi 池驰
+chong 崇
+chu 楚初储
+chuan 川传
+chun 春纯淳
+ci 慈
+cong 丛聪聰
+cui 崔翠
+da 达大
+dai 戴黛
+dan 丹
+dang 党
+de 德
+deng 邓登鄧
+di 狄迪笛
+ding 丁鼎定
+dong 董东冬栋東
+dou 窦
+du 杜都
+duan 段端
+en 恩
+er 尔
+fan 范樊凡帆範
+fang 方房芳
+fei 费飞菲
+fen 芬
+feng 冯封丰凤峰锋枫风馮鳳豐鋒楓
+fu 傅付符福富甫芙
+gan 甘
+gang 刚钢鋼
+gao 高
+ge 葛戈歌
+geng 耿
+gong 龚宫弓功龔
+gu 古顾谷
+guan 关管冠
+guang 光广
+gui 桂贵貴
+guo 郭国果國
+hai 海
+han 韩寒涵翰汉含韓
+hang 杭航
+hao 郝浩豪好皓昊
+he 何贺和赫鹤荷賀鶴
+heng 恒衡
+hong 洪红宏鸿虹弘紅鴻
+hou 侯厚
+hu 胡虎湖
+hua 华花桦華
+huai 怀
+huan 欢环焕
+huang 黄皇煌黃
+hui 惠慧辉晖卉輝
+huo 霍火
+ji 纪季吉姬冀继济
+jia 贾佳嘉家
+jian 简建剑健坚劍
+jiang 江姜蒋疆
+jiao 焦娇
+jie 杰洁捷婕傑
+jin 金晋锦进瑾
+jing 井景静晶京敬婧菁靜
+jiu 久玖
+ju 居菊
+juan 娟
+jun 俊君军骏峻軍駿
+kai 凯开
+kang 康
+ke 柯可科克
+kong 孔
+kuang 匡
+kun 坤昆
+lai 赖来賴
+lan 兰蓝岚澜
+lang 郎朗
+le 乐樂
+lei 雷蕾磊
+leng 冷
+li 李黎丽力立利莉理礼麗
+lian 连莲廉
+liang 梁良亮
+liao 廖
+lin 林琳霖麟
+ling 凌玲灵铃
+liu 刘柳留劉
+long 龙隆龍
+lou 楼
+lu 卢陆鲁路露璐陸盧魯
+lv 吕律绿
+luan 栾
+lun 伦
+luo 罗骆洛羅
+ma 马馬
+mai 麦
+man 曼满
+mao 毛茅
+mei 梅美媚
+meng 孟蒙梦萌
+mi 米
+miao 苗妙
+min 闵敏民珉閔
+ming 明铭鸣銘鳴
+mo 莫墨
+mu 穆木牧沐
+na 娜
+nan 南楠
+ni 倪妮
+ning 宁凝
+niu 牛
+ou 欧歐
+pan 潘盼
+pang 庞
+pei 裴沛培佩
+peng 彭鹏鵬
+ping 平萍
+pu 蒲浦普
+qi 齐戚祁琪奇启琦齊
+qian 钱谦倩錢
+qiang 强強
+qiao 乔桥巧
+qin 秦钦琴勤
+qing 青清晴庆卿
+qiu 邱秋丘
+qu 曲瞿
+quan 全权泉
+ran 冉然
+rao 饶
+ren 任仁
+rong 荣容蓉融
+ru 茹如儒
+ruan 阮
+rui 瑞锐睿蕊
+run 润
+ruo 若
+sang 桑
+sen 森
+sha 沙莎
+shan 山单珊善杉
+shang 尚商
+shao 邵少韶
+shen 沈申深
+sheng 盛胜圣生勝聖
+shi 石史施师诗时世士詩
+shu 舒书淑树書
+shuang 双霜
+shun 顺
+si 司思斯
+song 宋松颂
+su 苏素蘇
+sun 孙孫
+tai 泰
+tan 谭谈
+tang 唐汤棠
+tao 陶涛桃濤
+teng 滕腾
+tian 田天甜
+ting 婷亭庭
+tong 童佟通彤桐
+tu 涂图
+wan 万宛婉萬
+wang 王汪旺望
+wei 魏韦卫伟薇维威巍偉韋衛維瑋
+wen 温文闻雯聞
+weng 翁
+wu 吴武伍吾吳
+xi 席西希熙曦喜
+xia 夏霞
+xian 冼贤先仙娴
+xiang 向项湘翔祥香
+xiao 肖萧晓小笑筱蕭曉
+xie 谢謝
+xin 辛新欣心馨鑫
+xing 邢星兴幸興
+xiong 熊雄
+xiu 秀修
+xu 徐许旭許
+xuan 宣轩萱玄璇軒
+xue 薛雪学
+xun 荀寻迅勋
+ya 雅亚娅
+yan 严颜燕言岩艳彦妍顏嚴艷
+yang 杨阳羊洋扬楊陽
+yao 姚瑶耀遥
+ye 叶业烨葉
+yi 易伊一怡依艺仪毅益宜藝儀
+yin 尹殷银音茵銀
+ying 英应莹颖影樱穎瑩
+yong 永勇雍
+you 尤游友佑
+yu 于余俞虞宇雨玉语渝羽钰語鈺
+yuan 袁元源远苑媛圆
+yue 岳月悦越跃
+yun 云芸韵昀雲
+zang 臧
+ze 泽澤
+zeng 曾
+zhai 翟
+zhan 詹展湛
+zhang 张章彰張
+zhao 赵昭趙
+zhe 哲
+zhen 甄珍真震贞
+zheng 郑正政征铮鄭
+zhi 志智芝之致
+zhong 钟仲忠中鍾
+zhou 周州舟洲
+zhu 朱祝竹珠诸
+zhuang 庄壮莊
+zhuo 卓
+zi 子紫梓姿
+zong 宗
+zou 邹鄒
+zu 祖
+zuo 左
+`
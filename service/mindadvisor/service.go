@@ -5,9 +5,12 @@ import (
 	"errors"
 	"regexp"
 	"strings"
+	"sync"
+	"time"
 
 	"plaud-emails/dao"
 	datamodel "plaud-emails/data/model"
+	appconfig "plaud-emails/pkg/config"
 
 	"github.com/Plaud-AI/plaud-go-scaffold/pkg/logger"
 	"github.com/Plaud-AI/plaud-go-scaffold/pkg/svc"
@@ -24,21 +27,23 @@ const (
 	LocalPartMaxLen = 20
 )
 
-// 保留词列表
-var reservedWords = []string{"admin", "support", "root", "plaud", "system", "help", "info", "mail", "postmaster", "webmaster"}
-
-// 合法字符正则：仅允许字母、数字、点
+// 合法字符正则：仅允许字母、数字、点，"+" 保留为子地址分隔符
 var localPartRegex = regexp.MustCompile(`^[a-z0-9.]+$`)
 
 // 错误定义
 var (
-	ErrInvalidLocalPartLength   = errors.New("local_part length must be between 4 and 20 characters")
-	ErrInvalidLocalPartChars    = errors.New("local_part can only contain lowercase letters, numbers, and dots")
-	ErrReservedWord             = errors.New("local_part contains reserved word")
-	ErrEmailAlreadyExists       = errors.New("email already exists")
-	ErrUserAlreadyHasMailbox    = errors.New("user already has mailbox")
-	ErrMailboxConflict          = errors.New("mailbox already created with different local_part")
-	ErrInvalidSalutation        = errors.New("salutation must be Mr or Mrs")
+	ErrInvalidLocalPartLength = errors.New("local_part length must be between 4 and 20 characters")
+	ErrInvalidLocalPartChars  = errors.New("local_part can only contain lowercase letters, numbers, and dots")
+	ErrReservedWord           = errors.New("local_part is reserved or not allowed")
+	ErrEmailAlreadyExists     = errors.New("email already exists")
+	ErrUserAlreadyHasMailbox  = errors.New("user already has mailbox")
+	ErrMailboxConflict        = errors.New("mailbox already created with different local_part")
+	ErrInvalidSalutation      = errors.New("salutation must be at most 32 characters without line breaks")
+	ErrRecipientNotFound      = errors.New("recipient not found")
+	ErrMailboxInactive        = errors.New("mailbox is inactive")
+	ErrMailboxNotCreated      = errors.New("mailbox not created")
+	ErrRenameCooldown         = errors.New("local_part was changed recently")
+	ErrAddressQuarantined     = errors.New("email address was recently released")
 
 	// Beta registration errors
 	ErrUserAlreadyRegistered  = errors.New("user already registered")
@@ -49,34 +54,57 @@ var (
 // MindAdvisorService 心智幕僚服务
 type MindAdvisorService struct {
 	svc.BaseService
-	userDao        *dao.MindAdvisorUserDao
-	linkedEmailDao *dao.MindAdvisorLinkedEmailDao
-	betaRegDao     *dao.BetaInviteRegistrationDao
-	db             *gorm.DB
+	userDao           *dao.MindAdvisorUserDao
+	linkedEmailDao    *dao.MindAdvisorLinkedEmailDao
+	betaRegDao        *dao.BetaInviteRegistrationDao
+	retiredAddressDao *dao.MindAdvisorRetiredAddressDao
+	aliasDao          *dao.MindAdvisorAliasDao
+	unicodeAddressDao *dao.MindAdvisorUnicodeAddressDao
+	tagRuleDao        *dao.MindAdvisorTagRuleDao
+	blocklistRuleDao  *dao.MailboxBlocklistRuleDao
+	auditDao          *dao.MindAdvisorMailboxAuditDao
+	blocklist         *Blocklist
+	purgers           []MailboxPurger
+	conf              *appconfig.MailboxConfig
+	blocklistConf     *appconfig.BlocklistConfig
+	db                *gorm.DB
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
 }
 
 // New 创建 MindAdvisorService
-func New(db *gorm.DB) *MindAdvisorService {
+func New(db *gorm.DB, conf *appconfig.MailboxConfig, blocklistConf *appconfig.BlocklistConfig) *MindAdvisorService {
 	return &MindAdvisorService{
-		userDao:        dao.NewMindAdvisorUserDao(db),
-		linkedEmailDao: dao.NewMindAdvisorLinkedEmailDao(db),
-		betaRegDao:     dao.NewBetaInviteRegistrationDao(db),
-		db:             db,
+		userDao:           dao.NewMindAdvisorUserDao(db),
+		linkedEmailDao:    dao.NewMindAdvisorLinkedEmailDao(db),
+		betaRegDao:        dao.NewBetaInviteRegistrationDao(db),
+		retiredAddressDao: dao.NewMindAdvisorRetiredAddressDao(db),
+		aliasDao:          dao.NewMindAdvisorAliasDao(db),
+		unicodeAddressDao: dao.NewMindAdvisorUnicodeAddressDao(db),
+		tagRuleDao:        dao.NewMindAdvisorTagRuleDao(db),
+		blocklistRuleDao:  dao.NewMailboxBlocklistRuleDao(db),
+		auditDao:          dao.NewMindAdvisorMailboxAuditDao(db),
+		blocklist:         NewBlocklist(blocklistConf.Rules),
+		conf:              conf,
+		blocklistConf:     blocklistConf,
+		db:                db,
 	}
 }
 
 // CreateMailbox 创建专属邮箱
 func (s *MindAdvisorService) CreateMailbox(ctx context.Context, userID, localPart, salutation string) (*datamodel.MindAdvisorUser, error) {
 	// 输入校验
-	if err := s.validateLocalPart(localPart); err != nil {
+	if err := s.validateLocalPart(ctx, localPart); err != nil {
 		return nil, err
 	}
 
-	if err := s.validateSalutation(salutation); err != nil {
+	salutation = strings.TrimSpace(salutation)
+	if err := validateSalutation(salutation); err != nil {
 		return nil, err
 	}
 
-	dedicatedEmail := localPart + EmailDomain
+	dedicatedEmail := normalizeLocalPart(localPart) + EmailDomain
 
 	var result *datamodel.MindAdvisorUser
 
@@ -91,6 +119,10 @@ func (s *MindAdvisorService) CreateMailbox(ctx context.Context, userID, localPar
 		}
 
 		if existing != nil {
+			// 已删除的邮箱清除前不能重新创建
+			if existing.IsDeleted() {
+				return ErrMailboxDeleted
+			}
 			// 用户已存在邮箱
 			if existing.DedicatedEmail != "" {
 				// 如果已创建的邮箱与请求的不同，返回冲突错误
@@ -104,19 +136,17 @@ func (s *MindAdvisorService) CreateMailbox(ctx context.Context, userID, localPar
 		}
 
 		// 检查邮箱地址是否被占用
-		emailExists, err := txDao.GetByDedicatedEmail(ctx, dedicatedEmail)
-		if err != nil {
+		now := time.Now()
+		if err := s.claimAddress(ctx, tx, userID, dedicatedEmail, now); err != nil {
 			return err
 		}
-		if emailExists != nil {
-			return ErrEmailAlreadyExists
-		}
 
 		// 创建新记录
 		newUser := &datamodel.MindAdvisorUser{
 			UserID:         userID,
 			DedicatedEmail: dedicatedEmail,
 			Config: &datamodel.MindAdvisorUserConfig{
+				Version:    datamodel.MindAdvisorUserConfigVersion,
 				Salutation: salutation,
 			},
 			Status: datamodel.MindAdvisorStatusActive,
@@ -134,6 +164,9 @@ func (s *MindAdvisorService) CreateMailbox(ctx context.Context, userID, localPar
 			}
 			return err
 		}
+		if err := s.registerUnicodeAddress(ctx, tx, userID, dedicatedEmail, now); err != nil {
+			return err
+		}
 
 		result = newUser
 		return nil
@@ -147,6 +180,142 @@ func (s *MindAdvisorService) CreateMailbox(ctx context.Context, userID, localPar
 	return result, nil
 }
 
+// RenameMailbox 更换专属邮箱的 local_part
+// 旧地址在 RetiredRouteDays 内继续投递给该用户，QuarantineDays 内其他用户不能申请；
+// 两次更换之间至少间隔 RenameCooldownDays，换回自己保留中的旧地址同样受间隔限制
+func (s *MindAdvisorService) RenameMailbox(ctx context.Context, userID, localPart string) (*datamodel.MindAdvisorUser, error) {
+	if err := s.validateLocalPart(ctx, localPart); err != nil {
+		return nil, err
+	}
+
+	dedicatedEmail := normalizeLocalPart(localPart) + EmailDomain
+
+	var result *datamodel.MindAdvisorUser
+
+	err := s.userDao.ExecTx(ctx, func(tx *gorm.DB) error {
+		txDao := dao.NewMindAdvisorUserDao(tx)
+
+		// Update 整行保存，加锁读取避免覆盖并发事务写入的状态、保留或设置字段
+		user, err := txDao.GetByUserIDForUpdate(ctx, userID)
+		if err != nil {
+			return err
+		}
+		if user == nil || user.DedicatedEmail == "" {
+			return ErrMailboxNotCreated
+		}
+		if user.IsDeleted() {
+			return ErrMailboxDeleted
+		}
+		// 与当前地址相同则直接返回
+		if user.DedicatedEmail == dedicatedEmail {
+			result = user
+			return nil
+		}
+
+		now := time.Now()
+		if now.Before(s.renameAllowedAt(user)) {
+			return ErrRenameCooldown
+		}
+
+		if err := s.claimAddress(ctx, tx, userID, dedicatedEmail, now); err != nil {
+			return err
+		}
+		if err := s.registerUnicodeAddress(ctx, tx, userID, dedicatedEmail, now); err != nil {
+			return err
+		}
+
+		// 保留旧地址
+		routeUntil := now.Add(time.Duration(s.conf.RetiredRouteDays) * 24 * time.Hour)
+		if err := s.retireAddress(ctx, tx, userID, user.DedicatedEmail, routeUntil, now); err != nil {
+			return err
+		}
+
+		user.DedicatedEmail = dedicatedEmail
+		user.RenamedAt = &now
+		if err := txDao.Update(ctx, user); err != nil {
+			if strings.Contains(err.Error(), "Duplicate entry") {
+				return ErrEmailAlreadyExists
+			}
+			return err
+		}
+
+		result = user
+		return nil
+	})
+
+	if err != nil {
+		logger.ErrorfCtx(ctx, "rename mailbox error: %v", err)
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// renameAllowedAt 用户下次可以更换 local_part 的时间，从未更换过时返回零值
+func (s *MindAdvisorService) renameAllowedAt(user *datamodel.MindAdvisorUser) time.Time {
+	if user.RenamedAt == nil {
+		return time.Time{}
+	}
+	return user.RenamedAt.Add(time.Duration(s.conf.RenameCooldownDays) * 24 * time.Hour)
+}
+
+// claimAddress 检查地址能否被用户申请为专属邮箱或别名，需在事务中调用
+// 已过隔离期或属于该用户自己的旧地址记录会被删除
+func (s *MindAdvisorService) claimAddress(ctx context.Context, tx *gorm.DB, userID, dedicatedEmail string, now time.Time) error {
+	owner, err := dao.NewMindAdvisorUserDao(tx).GetByDedicatedEmail(ctx, dedicatedEmail)
+	if err != nil {
+		return err
+	}
+	if owner != nil {
+		return ErrEmailAlreadyExists
+	}
+	alias, err := dao.NewMindAdvisorAliasDao(tx).GetByAddress(ctx, dedicatedEmail)
+	if err != nil {
+		return err
+	}
+	if alias != nil {
+		return ErrEmailAlreadyExists
+	}
+	if s.conf.UnicodeLocalPart {
+		if err := s.claimUnicodeAddress(ctx, tx, userID, dedicatedEmail, now); err != nil {
+			return err
+		}
+	}
+
+	retiredDao := dao.NewMindAdvisorRetiredAddressDao(tx)
+	retired, err := retiredDao.GetByAddress(ctx, dedicatedEmail)
+	if err != nil {
+		return err
+	}
+	if retired == nil {
+		return nil
+	}
+	if retired.UserID != userID && retired.QuarantinedAt(now) {
+		return ErrAddressQuarantined
+	}
+	return retiredDao.DeleteByID(ctx, retired.ID)
+}
+
+// retireAddress 保留用户不再使用的地址，routeUntil 之前继续收信，隔离期从 now 起算
+func (s *MindAdvisorService) retireAddress(ctx context.Context, tx *gorm.DB, userID, address string, routeUntil, now time.Time) error {
+	return dao.NewMindAdvisorRetiredAddressDao(tx).Create(ctx, &datamodel.MindAdvisorRetiredAddress{
+		UserID:          userID,
+		Address:         address,
+		RouteUntil:      routeUntil,
+		QuarantineUntil: now.Add(time.Duration(s.conf.QuarantineDays) * 24 * time.Hour),
+	})
+}
+
+// ListRetiredAddresses 查询用户更换过的旧地址
+func (s *MindAdvisorService) ListRetiredAddresses(ctx context.Context, userID string) ([]*datamodel.MindAdvisorRetiredAddress, error) {
+	addrs, err := s.retiredAddressDao.ListByUserID(ctx, userID)
+	if err != nil {
+		logger.ErrorfCtx(ctx, "list retired addresses error: %v", err)
+		return nil, err
+	}
+	return addrs, nil
+}
+
 // GetMailbox 获取用户的专属邮箱
 func (s *MindAdvisorService) GetMailbox(ctx context.Context, userID string) (*datamodel.MindAdvisorUser, error) {
 	user, err := s.userDao.GetByUserID(ctx, userID)
@@ -157,45 +326,126 @@ func (s *MindAdvisorService) GetMailbox(ctx context.Context, userID string) (*da
 	return user, nil
 }
 
-// GetUserByDedicatedEmail 根据专属邮箱查询用户
+// GetUserByDedicatedEmail 根据专属邮箱或别名查询用户
 func (s *MindAdvisorService) GetUserByDedicatedEmail(ctx context.Context, email string) (*datamodel.MindAdvisorUser, error) {
-	user, err := s.userDao.GetByDedicatedEmail(ctx, email)
+	address := normalizeAddress(email)
+	user, err := s.userDao.GetByDedicatedEmail(ctx, address)
 	if err != nil {
 		logger.ErrorfCtx(ctx, "get user by dedicated email error: %v", err)
 		return nil, err
 	}
+	if user != nil {
+		return user, nil
+	}
+	user, err = s.resolveAlias(ctx, address)
+	if err != nil {
+		logger.ErrorfCtx(ctx, "get user by alias error: %v", err)
+		return nil, err
+	}
 	return user, nil
 }
 
-// validateLocalPart 校验 local_part
-func (s *MindAdvisorService) validateLocalPart(localPart string) error {
-	// 长度校验
-	if len(localPart) < LocalPartMinLen || len(localPart) > LocalPartMaxLen {
-		return ErrInvalidLocalPartLength
-	}
+// Recipient 收件地址的解析结果
+type Recipient struct {
+	User *datamodel.MindAdvisorUser
+	// Alias 命中的别名地址，通过专属邮箱或旧地址收信时为空
+	Alias string
+}
 
-	// 转小写后校验字符
-	lower := strings.ToLower(localPart)
-	if !localPartRegex.MatchString(lower) {
-		return ErrInvalidLocalPartChars
+// ResolveRecipient 解析收件地址对应的用户，仅 active 状态的邮箱可以收信，已删除的邮箱视为不存在
+// 依次匹配专属邮箱、别名，以及更换 local_part 后仍在保留期内的旧地址，启用国际化地址时还会匹配 ASCII 兜底地址
+func (s *MindAdvisorService) ResolveRecipient(ctx context.Context, dedicatedEmail string) (*Recipient, error) {
+	address := normalizeAddress(dedicatedEmail)
+	user, err := s.userDao.GetByDedicatedEmail(ctx, address)
+	if err != nil {
+		logger.ErrorfCtx(ctx, "resolve recipient error: %v", err)
+		return nil, err
 	}
-
-	// 敏感词过滤
-	for _, word := range reservedWords {
-		if strings.Contains(lower, word) {
-			return ErrReservedWord
+	rcpt := &Recipient{User: user}
+	if rcpt.User == nil {
+		if rcpt.User, err = s.resolveAlias(ctx, address); err != nil {
+			logger.ErrorfCtx(ctx, "resolve alias recipient error: %v", err)
+			return nil, err
+		}
+		if rcpt.User != nil {
+			rcpt.Alias = address
 		}
 	}
+	if rcpt.User == nil {
+		if rcpt.User, err = s.resolveRetired(ctx, address); err != nil {
+			logger.ErrorfCtx(ctx, "resolve retired recipient error: %v", err)
+			return nil, err
+		}
+	}
+	if rcpt.User == nil && s.conf.UnicodeLocalPart && isASCII(address) {
+		if rcpt.User, err = s.resolveFallback(ctx, address); err != nil {
+			logger.ErrorfCtx(ctx, "resolve fallback recipient error: %v", err)
+			return nil, err
+		}
+		if rcpt.User != nil {
+			rcpt.Alias = address
+		}
+	}
+	if rcpt.User == nil || rcpt.User.IsDeleted() {
+		return nil, ErrRecipientNotFound
+	}
+	if !rcpt.User.IsActive() {
+		return nil, ErrMailboxInactive
+	}
+	return rcpt, nil
+}
 
-	return nil
+// resolveRetired 查询仍在保留期内的旧地址所属用户
+func (s *MindAdvisorService) resolveRetired(ctx context.Context, address string) (*datamodel.MindAdvisorUser, error) {
+	retired, err := s.retiredAddressDao.GetByAddress(ctx, address)
+	if err != nil {
+		return nil, err
+	}
+	if retired == nil || !retired.RoutesAt(time.Now()) {
+		return nil, nil
+	}
+	return s.userDao.GetByUserID(ctx, retired.UserID)
 }
 
-// validateSalutation 校验称呼
-func (s *MindAdvisorService) validateSalutation(salutation string) error {
-	if salutation != "Mr" && salutation != "Mrs" {
-		return ErrInvalidSalutation
+// validateLocalPart 校验用户请求的 local_part，被屏蔽规则拒绝时记录日志
+func (s *MindAdvisorService) validateLocalPart(ctx context.Context, localPart string) error {
+	rule, err := s.checkLocalPart(localPart)
+	if rule != nil {
+		logger.InfofCtx(ctx, "local_part %q rejected by blocklist rule %s", normalizeLocalPart(localPart), rule)
 	}
-	return nil
+	return err
+}
+
+// checkLocalPart 校验 local_part，被屏蔽规则拒绝时同时返回命中的规则
+// 启用国际化地址时，非 ASCII 的 local_part 按 NFC 规范化后校验；不记录日志，生成候选名时用于批量过滤
+func (s *MindAdvisorService) checkLocalPart(localPart string) (*BlockRule, error) {
+	lower := normalizeLocalPart(localPart)
+	if s.conf.UnicodeLocalPart && !isASCII(lower) {
+		if err := validateUnicodeLocalPart(lower); err != nil {
+			return nil, err
+		}
+	} else {
+		// 长度校验
+		if len(localPart) < LocalPartMinLen || len(localPart) > LocalPartMaxLen {
+			return nil, ErrInvalidLocalPartLength
+		}
+
+		// 转小写后校验字符
+		if !localPartRegex.MatchString(lower) {
+			return nil, ErrInvalidLocalPartChars
+		}
+	}
+
+	// 保留词与滥用词过滤，国际化地址同时检查混淆骨架，如西里尔字母拼写的 admin
+	rule := s.blocklist.Match(lower)
+	if rule == nil && !isASCII(lower) {
+		rule = s.blocklist.Match(skeleton(lower))
+	}
+	if rule != nil {
+		return rule, ErrReservedWord
+	}
+
+	return nil, nil
 }
 
 // Init 初始化服务
@@ -203,6 +453,9 @@ func (s *MindAdvisorService) Init(ctx context.Context) error {
 	if s.IsInited() {
 		return nil
 	}
+	if err := s.reloadBlocklist(ctx); err != nil {
+		logger.Warnf("load blocklist rules error: %v", err)
+	}
 	s.SetInited(true)
 	return nil
 }
@@ -212,6 +465,12 @@ func (s *MindAdvisorService) Start(ctx context.Context) error {
 	if s.IsStarted() {
 		return nil
 	}
+	runCtx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+	s.wg.Add(2)
+	go s.refreshBlocklistLoop(runCtx, time.Duration(s.blocklistConf.RefreshSeconds)*time.Second)
+	go s.purgeLoop(runCtx, time.Duration(s.conf.PurgeIntervalSeconds)*time.Second)
+
 	logger.Infof("start mind advisor service")
 	s.SetStarted(true)
 	return nil
@@ -223,6 +482,10 @@ func (s *MindAdvisorService) Stop(ctx context.Context) error {
 		return nil
 	}
 	defer s.SetStopped(true)
+	if s.cancel != nil {
+		s.cancel()
+		s.wg.Wait()
+	}
 	logger.Infof("stop mind advisor service")
 	return nil
 }
@@ -316,9 +579,9 @@ func (s *MindAdvisorService) IsBetaRegistered(ctx context.Context, userID string
 	return exists, nil
 }
 
-// HasLinkedEmail 检查用户是否已绑定邮箱
+// HasLinkedEmail 检查用户是否已绑定邮箱，仅统计已验证的绑定
 func (s *MindAdvisorService) HasLinkedEmail(ctx context.Context, userID string) (bool, error) {
-	exists, err := s.linkedEmailDao.ExistsByUserID(ctx, userID)
+	exists, err := s.linkedEmailDao.ExistsVerifiedByUserID(ctx, userID)
 	if err != nil {
 		logger.ErrorfCtx(ctx, "check linked email error: %v", err)
 		return false, err
@@ -0,0 +1,87 @@
+package mindadvisor
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	datamodel "plaud-emails/data/model"
+	appconfig "plaud-emails/pkg/config"
+)
+
+// newTestService 创建不连接数据库的服务，只能调用不访问 dao 的方法
+func newTestService(unicodeLocalPart bool) *MindAdvisorService {
+	return &MindAdvisorService{
+		conf: &appconfig.MailboxConfig{
+			RenameCooldownDays: appconfig.DefaultMailboxRenameCooldownDays,
+			RetiredRouteDays:   appconfig.DefaultMailboxRetiredRouteDays,
+			QuarantineDays:     appconfig.DefaultMailboxQuarantineDays,
+			MaxAliases:         appconfig.DefaultMailboxMaxAliases,
+			UnicodeLocalPart:   unicodeLocalPart,
+		},
+		blocklist: NewBlocklist([]*appconfig.BlocklistRuleConfig{
+			{Match: appconfig.BlocklistMatchExact, Pattern: "admin", Category: appconfig.BlocklistCategoryReserved},
+			{Match: appconfig.BlocklistMatchPrefix, Pattern: "postmaster", Category: appconfig.BlocklistCategoryReserved},
+		}),
+	}
+}
+
+func TestCheckLocalPart(t *testing.T) {
+	s := newTestService(false)
+	cases := map[string]error{
+		"jane":                  nil,
+		"Jane.Doe":              nil,
+		"j.d.2026":              nil,
+		"abcdefghijklmnopqrst":  nil,
+		"abc":                   ErrInvalidLocalPartLength,
+		"abcdefghijklmnopqrstu": ErrInvalidLocalPartLength,
+		"jane_doe":              ErrInvalidLocalPartChars,
+		"jane+tag":              ErrInvalidLocalPartChars,
+		"jané":                  ErrInvalidLocalPartChars,
+		"ADMIN":                 ErrReservedWord,
+		"postmaster1":           ErrReservedWord,
+	}
+	for lp, want := range cases {
+		rule, err := s.checkLocalPart(lp)
+		if !errors.Is(err, want) {
+			t.Errorf("checkLocalPart(%q) err = %v, want %v", lp, err, want)
+		}
+		if (rule != nil) != errors.Is(err, ErrReservedWord) {
+			t.Errorf("checkLocalPart(%q) rule = %v, should be set only for reserved words", lp, rule)
+		}
+	}
+}
+
+func TestRenameAllowedAt(t *testing.T) {
+	s := newTestService(false)
+	if at := s.renameAllowedAt(&datamodel.MindAdvisorUser{}); !at.IsZero() {
+		t.Fatalf("never renamed mailbox should be renamable, got %v", at)
+	}
+	renamed := time.Date(2026, 3, 1, 8, 0, 0, 0, time.UTC)
+	at := s.renameAllowedAt(&datamodel.MindAdvisorUser{RenamedAt: &renamed})
+	if want := renamed.AddDate(0, 0, appconfig.DefaultMailboxRenameCooldownDays); !at.Equal(want) {
+		t.Fatalf("renameAllowedAt = %v, want %v", at, want)
+	}
+}
+
+func TestRetiredAddressWindows(t *testing.T) {
+	now := time.Date(2026, 3, 1, 8, 0, 0, 0, time.UTC)
+	addr := &datamodel.MindAdvisorRetiredAddress{
+		RouteUntil:      now.AddDate(0, 0, appconfig.DefaultMailboxRetiredRouteDays),
+		QuarantineUntil: now.AddDate(0, 0, appconfig.DefaultMailboxQuarantineDays),
+	}
+	cases := []struct {
+		at                  time.Time
+		routes, quarantined bool
+	}{
+		{now, true, true},
+		{addr.RouteUntil.Add(-time.Second), true, true},
+		{addr.RouteUntil, false, true},
+		{addr.QuarantineUntil, false, false},
+	}
+	for _, c := range cases {
+		if addr.RoutesAt(c.at) != c.routes || addr.QuarantinedAt(c.at) != c.quarantined {
+			t.Errorf("at %v: routes = %v quarantined = %v, want %v %v", c.at, addr.RoutesAt(c.at), addr.QuarantinedAt(c.at), c.routes, c.quarantined)
+		}
+	}
+}
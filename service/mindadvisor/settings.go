@@ -0,0 +1,226 @@
+package mindadvisor
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+	_ "time/tzdata" // 容器镜像可能不带时区数据库
+	"unicode"
+	"unicode/utf8"
+
+	"plaud-emails/dao"
+	datamodel "plaud-emails/data/model"
+
+	"github.com/Plaud-AI/plaud-go-scaffold/pkg/logger"
+
+	"golang.org/x/text/language"
+	"gorm.io/gorm"
+)
+
+// 邮箱设置的长度限制，按字符计算
+const (
+	SalutationMaxLen       = 32
+	DisplayNameMaxLen      = 64
+	AutoReplySubjectMaxLen = 200
+	AutoReplyBodyMaxLen    = 4000
+)
+
+// 错误定义
+var (
+	ErrInvalidDisplayName = errors.New("display_name must be at most 64 characters without line breaks")
+	ErrInvalidLanguage    = errors.New("language must be a valid BCP 47 tag")
+	ErrInvalidTimezone    = errors.New("timezone must be a valid IANA time zone name")
+	ErrInvalidDigest      = errors.New("digest must have frequency off, daily or weekly, hour 0-23 and weekday 0-6")
+	ErrInvalidAutoReply   = errors.New("auto_reply subject must be at most 200 characters and body at most 4000 characters, body is required when enabled")
+)
+
+// SettingsPatch 邮箱设置的部分更新，nil 字段保持不变
+// Digest 与 AutoReply 整体替换
+type SettingsPatch struct {
+	Salutation  *string
+	DisplayName *string
+	Language    *string
+	Timezone    *string
+	Digest      *datamodel.DigestSchedule
+	AutoReply   *datamodel.AutoReply
+}
+
+// GetSettings 获取邮箱设置，旧版本的配置读取时已升级到当前版本
+func (s *MindAdvisorService) GetSettings(ctx context.Context, userID string) (*datamodel.MindAdvisorUserConfig, error) {
+	user, err := s.userDao.GetByUserID(ctx, userID)
+	if err != nil {
+		logger.ErrorfCtx(ctx, "get mailbox settings error: %v", err)
+		return nil, err
+	}
+	if user == nil || user.DedicatedEmail == "" {
+		return nil, ErrMailboxNotCreated
+	}
+	return userConfig(user), nil
+}
+
+// UpdateSettings 校验并更新邮箱设置
+func (s *MindAdvisorService) UpdateSettings(ctx context.Context, userID string, patch *SettingsPatch) (*datamodel.MindAdvisorUserConfig, error) {
+	if err := normalizeSettingsPatch(patch); err != nil {
+		return nil, err
+	}
+
+	var result *datamodel.MindAdvisorUserConfig
+
+	err := s.userDao.ExecTx(ctx, func(tx *gorm.DB) error {
+		txDao := dao.NewMindAdvisorUserDao(tx)
+		// 加锁读取：并发的 PATCH 依次合并到最新配置，整行保存也不会覆盖状态或保留字段
+		user, err := txDao.GetByUserIDForUpdate(ctx, userID)
+		if err != nil {
+			return err
+		}
+		if user == nil || user.DedicatedEmail == "" {
+			return ErrMailboxNotCreated
+		}
+		if user.IsDeleted() {
+			return ErrMailboxDeleted
+		}
+
+		conf := userConfig(user)
+		if patch.Salutation != nil {
+			conf.Salutation = *patch.Salutation
+		}
+		if patch.DisplayName != nil {
+			conf.DisplayName = *patch.DisplayName
+		}
+		if patch.Language != nil {
+			conf.Language = *patch.Language
+		}
+		if patch.Timezone != nil {
+			conf.Timezone = *patch.Timezone
+		}
+		if patch.Digest != nil {
+			conf.Digest = patch.Digest
+		}
+		if patch.AutoReply != nil {
+			conf.AutoReply = patch.AutoReply
+		}
+		conf.Version = datamodel.MindAdvisorUserConfigVersion
+
+		user.Config = conf
+		if err := txDao.Update(ctx, user); err != nil {
+			return err
+		}
+		result = conf
+		return nil
+	})
+
+	if err != nil {
+		if !errors.Is(err, ErrMailboxNotCreated) && !errors.Is(err, ErrMailboxDeleted) {
+			logger.ErrorfCtx(ctx, "update mailbox settings error: %v", err)
+		}
+		return nil, err
+	}
+	return result, nil
+}
+
+// userConfig 返回用户配置的副本，未设置时返回当前版本的空配置
+func userConfig(user *datamodel.MindAdvisorUser) *datamodel.MindAdvisorUserConfig {
+	if user.Config == nil {
+		return &datamodel.MindAdvisorUserConfig{Version: datamodel.MindAdvisorUserConfigVersion}
+	}
+	conf := *user.Config
+	return &conf
+}
+
+// normalizeSettingsPatch 去除首尾空白并校验各字段，语言标签转为规范形式
+func normalizeSettingsPatch(patch *SettingsPatch) error {
+	if patch.Salutation != nil {
+		v := strings.TrimSpace(*patch.Salutation)
+		if err := validateSalutation(v); err != nil {
+			return err
+		}
+		patch.Salutation = &v
+	}
+	if patch.DisplayName != nil {
+		v := strings.TrimSpace(*patch.DisplayName)
+		if !validText(v, DisplayNameMaxLen) {
+			return ErrInvalidDisplayName
+		}
+		patch.DisplayName = &v
+	}
+	if patch.Language != nil {
+		v := strings.TrimSpace(*patch.Language)
+		if v != "" {
+			tag, err := language.Parse(v)
+			if err != nil {
+				return ErrInvalidLanguage
+			}
+			v = tag.String()
+		}
+		patch.Language = &v
+	}
+	if patch.Timezone != nil {
+		v := strings.TrimSpace(*patch.Timezone)
+		// time.LoadLocation 接受 "Local"，用户时区必须是明确的 IANA 名称
+		if v != "" {
+			if _, err := time.LoadLocation(v); err != nil || v == "Local" {
+				return ErrInvalidTimezone
+			}
+		}
+		patch.Timezone = &v
+	}
+	if patch.Digest != nil {
+		if err := validateDigest(patch.Digest); err != nil {
+			return err
+		}
+	}
+	if patch.AutoReply != nil {
+		r := patch.AutoReply
+		r.Subject = strings.TrimSpace(r.Subject)
+		r.Body = strings.TrimSpace(r.Body)
+		if !validText(r.Subject, AutoReplySubjectMaxLen) || utf8.RuneCountInString(r.Body) > AutoReplyBodyMaxLen ||
+			!utf8.ValidString(r.Body) || (r.Enabled && r.Body == "") {
+			return ErrInvalidAutoReply
+		}
+	}
+	return nil
+}
+
+// validateSalutation 校验称呼，允许空值与本地化的称呼
+func validateSalutation(salutation string) error {
+	if !validText(salutation, SalutationMaxLen) {
+		return ErrInvalidSalutation
+	}
+	return nil
+}
+
+// validateDigest 校验摘要计划，off 时忽略时间
+func validateDigest(d *datamodel.DigestSchedule) error {
+	d.Frequency = strings.ToLower(strings.TrimSpace(d.Frequency))
+	switch d.Frequency {
+	case datamodel.DigestFrequencyOff:
+		d.Hour, d.Weekday = 0, 0
+		return nil
+	case datamodel.DigestFrequencyDaily:
+		d.Weekday = 0
+	case datamodel.DigestFrequencyWeekly:
+		if d.Weekday < 0 || d.Weekday > 6 {
+			return ErrInvalidDigest
+		}
+	default:
+		return ErrInvalidDigest
+	}
+	if d.Hour < 0 || d.Hour > 23 {
+		return ErrInvalidDigest
+	}
+	return nil
+}
+
+// validText 单行文本：合法 UTF-8，不超过 maxLen 个字符且不含控制字符，会用于邮件头
+func validText(v string, maxLen int) bool {
+	if !utf8.ValidString(v) || utf8.RuneCountInString(v) > maxLen {
+		return false
+	}
+	for _, r := range v {
+		if unicode.IsControl(r) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,114 @@
+package mindadvisor
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	datamodel "plaud-emails/data/model"
+)
+
+func ptr(v string) *string { return &v }
+
+func TestNormalizeSettingsPatch(t *testing.T) {
+	patch := &SettingsPatch{
+		Salutation:  ptr("  Dr  "),
+		DisplayName: ptr(" 张伟 "),
+		Language:    ptr("zh-hans-cn"),
+		Timezone:    ptr(" Asia/Shanghai "),
+		Digest:      &datamodel.DigestSchedule{Frequency: " Weekly ", Hour: 8, Weekday: 1},
+		AutoReply:   &datamodel.AutoReply{Enabled: true, Subject: " Away ", Body: " Back on Monday. \n"},
+	}
+	if err := normalizeSettingsPatch(patch); err != nil {
+		t.Fatal(err)
+	}
+	if *patch.Salutation != "Dr" || *patch.DisplayName != "张伟" || *patch.Language != "zh-Hans-CN" || *patch.Timezone != "Asia/Shanghai" {
+		t.Fatalf("patch = %q %q %q %q", *patch.Salutation, *patch.DisplayName, *patch.Language, *patch.Timezone)
+	}
+	if patch.Digest.Frequency != datamodel.DigestFrequencyWeekly || patch.AutoReply.Subject != "Away" || patch.AutoReply.Body != "Back on Monday." {
+		t.Fatalf("digest = %+v auto_reply = %+v", patch.Digest, patch.AutoReply)
+	}
+
+	// 空值表示清除
+	empty := &SettingsPatch{Language: ptr(" "), Timezone: ptr("")}
+	if err := normalizeSettingsPatch(empty); err != nil || *empty.Language != "" || *empty.Timezone != "" {
+		t.Fatalf("clearing fields: %v", err)
+	}
+}
+
+func TestNormalizeSettingsPatchInvalid(t *testing.T) {
+	cases := []struct {
+		name  string
+		patch *SettingsPatch
+		want  error
+	}{
+		{"long salutation", &SettingsPatch{Salutation: ptr(strings.Repeat("a", SalutationMaxLen+1))}, ErrInvalidSalutation},
+		{"salutation line break", &SettingsPatch{Salutation: ptr("Dr\r\nBcc: x")}, ErrInvalidSalutation},
+		{"long display name", &SettingsPatch{DisplayName: ptr(strings.Repeat("名", DisplayNameMaxLen+1))}, ErrInvalidDisplayName},
+		{"invalid utf-8 display name", &SettingsPatch{DisplayName: ptr("a\xffb")}, ErrInvalidDisplayName},
+		{"language", &SettingsPatch{Language: ptr("not a language")}, ErrInvalidLanguage},
+		{"timezone", &SettingsPatch{Timezone: ptr("Mars/Olympus")}, ErrInvalidTimezone},
+		{"local timezone", &SettingsPatch{Timezone: ptr("Local")}, ErrInvalidTimezone},
+		{"digest", &SettingsPatch{Digest: &datamodel.DigestSchedule{Frequency: "hourly"}}, ErrInvalidDigest},
+		{"auto reply without body", &SettingsPatch{AutoReply: &datamodel.AutoReply{Enabled: true, Body: "  "}}, ErrInvalidAutoReply},
+		{"auto reply subject line break", &SettingsPatch{AutoReply: &datamodel.AutoReply{Subject: "a\nb", Body: "x"}}, ErrInvalidAutoReply},
+		{"long auto reply body", &SettingsPatch{AutoReply: &datamodel.AutoReply{Body: strings.Repeat("x", AutoReplyBodyMaxLen+1)}}, ErrInvalidAutoReply},
+	}
+	for _, tc := range cases {
+		if err := normalizeSettingsPatch(tc.patch); !errors.Is(err, tc.want) {
+			t.Errorf("%s: err = %v, want %v", tc.name, err, tc.want)
+		}
+	}
+
+	// 校验失败时不访问数据库
+	s := newTestService(false)
+	if _, err := s.UpdateSettings(context.Background(), "u1", cases[0].patch); !errors.Is(err, ErrInvalidSalutation) {
+		t.Fatalf("UpdateSettings err = %v", err)
+	}
+}
+
+func TestValidateDigest(t *testing.T) {
+	cases := []struct {
+		in   datamodel.DigestSchedule
+		want datamodel.DigestSchedule
+		ok   bool
+	}{
+		{datamodel.DigestSchedule{Frequency: "OFF", Hour: 30, Weekday: 9}, datamodel.DigestSchedule{Frequency: "off"}, true},
+		{datamodel.DigestSchedule{Frequency: "daily", Hour: 23, Weekday: 3}, datamodel.DigestSchedule{Frequency: "daily", Hour: 23}, true},
+		{datamodel.DigestSchedule{Frequency: "weekly", Hour: 0, Weekday: 6}, datamodel.DigestSchedule{Frequency: "weekly", Weekday: 6}, true},
+		{datamodel.DigestSchedule{Frequency: "daily", Hour: 24}, datamodel.DigestSchedule{}, false},
+		{datamodel.DigestSchedule{Frequency: "weekly", Hour: 8, Weekday: 7}, datamodel.DigestSchedule{}, false},
+		{datamodel.DigestSchedule{Frequency: "weekly", Hour: -1}, datamodel.DigestSchedule{}, false},
+		{datamodel.DigestSchedule{}, datamodel.DigestSchedule{}, false},
+	}
+	for _, c := range cases {
+		d := c.in
+		err := validateDigest(&d)
+		if c.ok != (err == nil) || (c.ok && d != c.want) {
+			t.Errorf("validateDigest(%+v) = %+v, %v", c.in, d, err)
+		}
+	}
+}
+
+func TestUserConfig(t *testing.T) {
+	conf := userConfig(&datamodel.MindAdvisorUser{})
+	if conf.Version != datamodel.MindAdvisorUserConfigVersion {
+		t.Fatalf("default config version = %d", conf.Version)
+	}
+	user := &datamodel.MindAdvisorUser{Config: &datamodel.MindAdvisorUserConfig{Version: 1, Salutation: "Mr"}}
+	conf = userConfig(user)
+	conf.Salutation = "Dr"
+	if user.Config.Salutation != "Mr" {
+		t.Fatal("userConfig should return a copy")
+	}
+
+	// 版本 0 的配置读取时升级到当前版本
+	var old datamodel.MindAdvisorUserConfig
+	if err := old.Scan([]byte(`{"salutation":"Mrs"}`)); err != nil {
+		t.Fatal(err)
+	}
+	if old.Version != datamodel.MindAdvisorUserConfigVersion || old.Salutation != "Mrs" {
+		t.Fatalf("migrated config = %+v", old)
+	}
+}
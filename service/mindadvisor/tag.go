@@ -0,0 +1,141 @@
+package mindadvisor
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	datamodel "plaud-emails/data/model"
+
+	"github.com/Plaud-AI/plaud-go-scaffold/pkg/logger"
+)
+
+const (
+	// SubaddressSeparator local_part 与子地址标签的分隔符，如 jane+receipts@myplaud
+	SubaddressSeparator = "+"
+
+	// Tag 限制
+	TagMaxLen   = 32
+	LabelMaxLen = 64
+)
+
+// 合法标签正则：仅允许小写字母、数字、点、下划线与连字符
+var tagRegex = regexp.MustCompile(`^[a-z0-9._-]+$`)
+
+// 错误定义
+var (
+	ErrInvalidTag           = errors.New("tag must be 1-32 lowercase letters, numbers, dots, underscores or hyphens")
+	ErrInvalidLabel         = errors.New("label must be at most 64 characters")
+	ErrInvalidForwardTarget = errors.New("forward target must be an active verified linked email")
+	ErrTagRuleNotFound      = errors.New("tag rule not found")
+)
+
+// SplitSubaddress 拆分 local_part 中的子地址标签，没有标签时 tag 为空
+func SplitSubaddress(localPart string) (base, tag string) {
+	base, tag, _ = strings.Cut(localPart, SubaddressSeparator)
+	return base, tag
+}
+
+// ValidateTag 校验子地址标签
+func ValidateTag(tag string) error {
+	if tag == "" || len(tag) > TagMaxLen || !tagRegex.MatchString(tag) {
+		return ErrInvalidTag
+	}
+	return nil
+}
+
+// TagRuleInput 标签规则输入
+type TagRuleInput struct {
+	Label                string
+	Mute                 bool
+	ForwardLinkedEmailID uint64
+}
+
+// SetTagRule 创建或覆盖用户某个标签的规则
+func (s *MindAdvisorService) SetTagRule(ctx context.Context, userID, tag string, in *TagRuleInput) (*datamodel.MindAdvisorTagRule, error) {
+	tag = strings.ToLower(tag)
+	if err := ValidateTag(tag); err != nil {
+		return nil, err
+	}
+	label := strings.TrimSpace(in.Label)
+	if utf8.RuneCountInString(label) > LabelMaxLen {
+		return nil, ErrInvalidLabel
+	}
+	if in.ForwardLinkedEmailID > 0 {
+		link, err := s.GetForwardTarget(ctx, userID, in.ForwardLinkedEmailID)
+		if err != nil {
+			return nil, err
+		}
+		if link == nil {
+			return nil, ErrInvalidForwardTarget
+		}
+	}
+
+	rule, err := s.tagRuleDao.GetByUserIDAndTag(ctx, userID, tag)
+	if err != nil {
+		logger.ErrorfCtx(ctx, "get tag rule error: %v", err)
+		return nil, err
+	}
+	if rule == nil {
+		rule = &datamodel.MindAdvisorTagRule{UserID: userID, Tag: tag}
+	}
+	rule.Label = label
+	rule.Mute = in.Mute
+	rule.ForwardLinkedEmailID = in.ForwardLinkedEmailID
+	if err := s.tagRuleDao.Save(ctx, rule); err != nil {
+		logger.ErrorfCtx(ctx, "save tag rule error: %v", err)
+		return nil, err
+	}
+	return rule, nil
+}
+
+// ListTagRules 查询用户的标签规则
+func (s *MindAdvisorService) ListTagRules(ctx context.Context, userID string) ([]*datamodel.MindAdvisorTagRule, error) {
+	rules, err := s.tagRuleDao.ListByUserID(ctx, userID)
+	if err != nil {
+		logger.ErrorfCtx(ctx, "list tag rules error: %v", err)
+		return nil, err
+	}
+	return rules, nil
+}
+
+// DeleteTagRule 删除用户某个标签的规则
+func (s *MindAdvisorService) DeleteTagRule(ctx context.Context, userID, tag string) error {
+	deleted, err := s.tagRuleDao.DeleteByUserIDAndTag(ctx, userID, strings.ToLower(tag))
+	if err != nil {
+		logger.ErrorfCtx(ctx, "delete tag rule error: %v", err)
+		return err
+	}
+	if !deleted {
+		return ErrTagRuleNotFound
+	}
+	return nil
+}
+
+// GetTagRule 查询收信时适用的标签规则，没有规则时返回 nil
+func (s *MindAdvisorService) GetTagRule(ctx context.Context, userID, tag string) (*datamodel.MindAdvisorTagRule, error) {
+	if tag == "" {
+		return nil, nil
+	}
+	rule, err := s.tagRuleDao.GetByUserIDAndTag(ctx, userID, tag)
+	if err != nil {
+		logger.ErrorfCtx(ctx, "get tag rule error: %v", err)
+		return nil, err
+	}
+	return rule, nil
+}
+
+// GetForwardTarget 查询可作为转发目标的绑定邮箱，需已通过可信方式验证且处于有效状态，否则返回 nil
+func (s *MindAdvisorService) GetForwardTarget(ctx context.Context, userID string, linkedEmailID uint64) (*datamodel.MindAdvisorLinkedEmail, error) {
+	link, err := s.linkedEmailDao.GetByUserIDAndID(ctx, userID, linkedEmailID)
+	if err != nil {
+		logger.ErrorfCtx(ctx, "get linked email error: %v", err)
+		return nil, err
+	}
+	if link == nil || !link.IsActive() || !link.IsTrustedVerified() {
+		return nil, nil
+	}
+	return link, nil
+}
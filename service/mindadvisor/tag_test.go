@@ -0,0 +1,51 @@
+package mindadvisor
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestSplitSubaddress(t *testing.T) {
+	cases := []struct{ in, base, tag string }{
+		{"jane", "jane", ""},
+		{"jane+receipts", "jane", "receipts"},
+		{"jane+", "jane", ""},
+		// 只按第一个分隔符拆分，标签中的 "+" 由 ValidateTag 拒绝
+		{"jane+a+b", "jane", "a+b"},
+	}
+	for _, c := range cases {
+		base, tag := SplitSubaddress(c.in)
+		if base != c.base || tag != c.tag {
+			t.Errorf("SplitSubaddress(%q) = %q, %q, want %q, %q", c.in, base, tag, c.base, c.tag)
+		}
+	}
+}
+
+func TestValidateTag(t *testing.T) {
+	valid := []string{"receipts", "a", "news.2026", "my_list-1", strings.Repeat("x", TagMaxLen)}
+	for _, tag := range valid {
+		if err := ValidateTag(tag); err != nil {
+			t.Errorf("ValidateTag(%q) = %v", tag, err)
+		}
+	}
+	invalid := []string{"", "Receipts", "a+b", "a b", "账单", strings.Repeat("x", TagMaxLen+1)}
+	for _, tag := range invalid {
+		if err := ValidateTag(tag); !errors.Is(err, ErrInvalidTag) {
+			t.Errorf("ValidateTag(%q) = %v, want ErrInvalidTag", tag, err)
+		}
+	}
+}
+
+func TestSetTagRuleValidation(t *testing.T) {
+	// 标签与显示名校验失败时不访问数据库
+	s := newTestService(false)
+	if _, err := s.SetTagRule(context.Background(), "u1", "bad tag", &TagRuleInput{}); !errors.Is(err, ErrInvalidTag) {
+		t.Fatalf("err = %v, want ErrInvalidTag", err)
+	}
+	in := &TagRuleInput{Label: strings.Repeat("标", LabelMaxLen+1)}
+	if _, err := s.SetTagRule(context.Background(), "u1", "Receipts", in); !errors.Is(err, ErrInvalidLabel) {
+		t.Fatalf("err = %v, want ErrInvalidLabel", err)
+	}
+}
@@ -0,0 +1,399 @@
+package mindadvisor
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"plaud-emails/dao"
+	datamodel "plaud-emails/data/model"
+
+	"github.com/Plaud-AI/plaud-go-scaffold/pkg/logger"
+
+	"golang.org/x/text/unicode/norm"
+	"gorm.io/gorm"
+)
+
+const (
+	// UnicodeLocalPartMinLen 国际化 local_part 的最小字符数，允许两个字的中文名
+	UnicodeLocalPartMinLen = 2
+	// localPartMaxBytes RFC 5321 对 local_part 的字节数限制
+	localPartMaxBytes = 64
+	// maxFallbackAttempts 生成 ASCII 兜底地址时最多尝试的候选数
+	maxFallbackAttempts = 20
+)
+
+// ErrAddressConfusable 与其他用户的地址在视觉上易混淆
+var ErrAddressConfusable = errors.New("local_part is confusable with an existing address")
+
+// isASCII 是否只包含 ASCII 字符
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+// normalizeLocalPart 统一 local_part 的存储形式：NFC 规范化并转为小写
+func normalizeLocalPart(localPart string) string {
+	if isASCII(localPart) {
+		return strings.ToLower(localPart)
+	}
+	return norm.NFC.String(strings.ToLower(norm.NFC.String(localPart)))
+}
+
+// normalizeAddress 规范化完整地址的 local_part，域名转为小写
+func normalizeAddress(address string) string {
+	at := strings.LastIndex(address, "@")
+	if at < 0 {
+		return normalizeLocalPart(address)
+	}
+	return normalizeLocalPart(address[:at]) + strings.ToLower(address[at:])
+}
+
+// 国际化 local_part 可以互相组合的文字：中日韩文字之间以及与拉丁字母组合；
+// 其他文字（如西里尔、希腊字母）只能单独使用，避免 аpple 这类混合文字的仿冒
+var (
+	combinableScripts = []*unicode.RangeTable{unicode.Latin, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul, unicode.Bopomofo}
+	standaloneScripts = []*unicode.RangeTable{unicode.Cyrillic, unicode.Greek, unicode.Arabic, unicode.Hebrew, unicode.Thai, unicode.Devanagari}
+)
+
+// letterScript 返回字母所属的文字，不支持的文字返回 nil
+func letterScript(r rune) *unicode.RangeTable {
+	// 长音符与叠字符属于通用文字，按假名与汉字处理
+	switch r {
+	case 'ー':
+		return unicode.Katakana
+	case '々':
+		return unicode.Han
+	}
+	for _, t := range combinableScripts {
+		if unicode.Is(t, r) {
+			return t
+		}
+	}
+	for _, t := range standaloneScripts {
+		if unicode.Is(t, r) {
+			return t
+		}
+	}
+	return nil
+}
+
+// validateUnicodeLocalPart 校验已规范化的国际化 local_part 的长度与字符
+// 允许字母、组合附加符号、ASCII 数字与点
+func validateUnicodeLocalPart(lower string) error {
+	n := utf8.RuneCountInString(lower)
+	if n < UnicodeLocalPartMinLen || n > LocalPartMaxLen || len(lower) > localPartMaxBytes {
+		return fmt.Errorf("%w: internationalized local_part must be %d-%d characters",
+			ErrInvalidLocalPartLength, UnicodeLocalPartMinLen, LocalPartMaxLen)
+	}
+
+	var standalone *unicode.RangeTable
+	combinable := false
+	for i, r := range lower {
+		switch {
+		case r == '.', r >= '0' && r <= '9':
+		case unicode.In(r, unicode.Mn, unicode.Mc):
+			if i == 0 {
+				return ErrInvalidLocalPartChars
+			}
+		case unicode.IsLetter(r):
+			script := letterScript(r)
+			if script == nil {
+				return ErrInvalidLocalPartChars
+			}
+			isCombinable := false
+			for _, t := range combinableScripts {
+				if t == script {
+					isCombinable = true
+				}
+			}
+			if isCombinable {
+				combinable = true
+			} else if standalone == nil {
+				standalone = script
+			} else if standalone != script {
+				return fmt.Errorf("%w: mixed scripts", ErrInvalidLocalPartChars)
+			}
+		default:
+			return ErrInvalidLocalPartChars
+		}
+	}
+	if standalone != nil && combinable {
+		return fmt.Errorf("%w: mixed scripts", ErrInvalidLocalPartChars)
+	}
+	return nil
+}
+
+// confusables 视觉上与拉丁字母或常用汉字相近的字符，映射到同一个原型
+var confusables = map[rune]rune{
+	// 西里尔字母
+	'а': 'a', 'в': 'b', 'г': 'r', 'е': 'e', 'ё': 'e', 'к': 'k', 'о': 'o', 'п': 'n', 'р': 'p', 'с': 'c',
+	'у': 'y', 'х': 'x', 'ь': 'b', 'і': 'i', 'ї': 'i', 'ј': 'j', 'ѕ': 's', 'ԁ': 'd', 'һ': 'h', 'ԛ': 'q',
+	'ԝ': 'w', 'ӏ': 'l',
+	// 希腊字母
+	'α': 'a', 'β': 'b', 'γ': 'y', 'ε': 'e', 'η': 'n', 'ι': 'i', 'κ': 'k', 'ν': 'v', 'ο': 'o', 'ρ': 'p',
+	'τ': 't', 'υ': 'u', 'χ': 'x', 'ω': 'w',
+	// 拉丁字母变体
+	'ı': 'i', 'ɡ': 'g', 'ɑ': 'a', 'ℓ': 'l',
+	// 与汉字同形的片假名
+	'ー': '一', 'ロ': '口', 'カ': '力', 'エ': '工', 'ニ': '二', 'ハ': '八', 'タ': '夕', 'ト': '卜', 'ヘ': 'へ',
+}
+
+// skeleton 计算 local_part 的混淆骨架，骨架相同的地址视觉上难以区分
+// 兼容分解统一全角等变体，拉丁、希腊与西里尔字母去掉附加符号后映射到原型
+func skeleton(localPart string) string {
+	var b strings.Builder
+	var base rune
+	for _, r := range norm.NFKD.String(strings.ToLower(localPart)) {
+		if unicode.Is(unicode.Mn, r) {
+			if unicode.In(base, unicode.Latin, unicode.Greek, unicode.Cyrillic) {
+				continue
+			}
+			b.WriteRune(r)
+			continue
+		}
+		base = r
+		if p, ok := confusables[r]; ok {
+			r = p
+		}
+		b.WriteRune(r)
+	}
+	return norm.NFC.String(b.String())
+}
+
+// liveOwner 返回地址当前的占用者：专属邮箱、别名、隔离期内的旧地址，以及仍有效的兜底地址
+func (s *MindAdvisorService) liveOwner(ctx context.Context, tx *gorm.DB, address string, now time.Time) (string, error) {
+	owner, err := dao.NewMindAdvisorUserDao(tx).GetByDedicatedEmail(ctx, address)
+	if err != nil {
+		return "", err
+	}
+	if owner != nil {
+		return owner.UserID, nil
+	}
+	alias, err := dao.NewMindAdvisorAliasDao(tx).GetByAddress(ctx, address)
+	if err != nil {
+		return "", err
+	}
+	if alias != nil {
+		return alias.UserID, nil
+	}
+	retired, err := dao.NewMindAdvisorRetiredAddressDao(tx).GetByAddress(ctx, address)
+	if err != nil {
+		return "", err
+	}
+	if retired != nil && retired.QuarantinedAt(now) {
+		return retired.UserID, nil
+	}
+	if !s.conf.UnicodeLocalPart || !isASCII(address) {
+		return "", nil
+	}
+	// 兜底地址随其国际化地址一同失效
+	rec, err := dao.NewMindAdvisorUnicodeAddressDao(tx).GetByFallbackAddress(ctx, address)
+	if err != nil || rec == nil {
+		return "", err
+	}
+	return s.liveOwner(ctx, tx, rec.Address, now)
+}
+
+// claimUnicodeAddress 申请地址时的国际化检查，需在事务中调用
+// 地址不能是其他用户仍有效的兜底地址，也不能与其他用户的地址混淆；失效的记录会被清理
+func (s *MindAdvisorService) claimUnicodeAddress(ctx context.Context, tx *gorm.DB, userID, address string, now time.Time) error {
+	unicodeDao := dao.NewMindAdvisorUnicodeAddressDao(tx)
+
+	if isASCII(address) {
+		rec, err := unicodeDao.GetByFallbackAddress(ctx, address)
+		if err != nil {
+			return err
+		}
+		if rec != nil {
+			owner, err := s.liveOwner(ctx, tx, rec.Address, now)
+			if err != nil {
+				return err
+			}
+			if owner != "" && owner != userID {
+				return ErrEmailAlreadyExists
+			}
+			if owner == "" {
+				if err := unicodeDao.DeleteByID(ctx, rec.ID); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	owner, err := s.confusableOwner(ctx, tx, userID, address, now)
+	if err != nil {
+		return err
+	}
+	if owner != "" {
+		logger.InfofCtx(ctx, "address %s rejected as confusable with an address of user %s", address, owner)
+		return ErrAddressConfusable
+	}
+
+	rec, err := unicodeDao.GetByAddress(ctx, address)
+	if err != nil {
+		return err
+	}
+	if rec != nil && rec.UserID != userID {
+		return unicodeDao.DeleteByID(ctx, rec.ID)
+	}
+	return nil
+}
+
+// confusableOwner 查找与地址骨架相同、属于其他用户且仍有效的地址，返回其占用者
+func (s *MindAdvisorService) confusableOwner(ctx context.Context, tx *gorm.DB, userID, address string, now time.Time) (string, error) {
+	localPart := strings.TrimSuffix(address, EmailDomain)
+	sk := skeleton(localPart)
+
+	// 骨架是 ASCII 时与同名的 ASCII 地址比较，如西里尔字母的 јane 与 jane
+	if sk != localPart && isASCII(sk) {
+		owner, err := s.liveOwner(ctx, tx, sk+EmailDomain, now)
+		if err != nil {
+			return "", err
+		}
+		if owner != "" && owner != userID {
+			return owner, nil
+		}
+	}
+
+	recs, err := dao.NewMindAdvisorUnicodeAddressDao(tx).ListBySkeleton(ctx, sk)
+	if err != nil {
+		return "", err
+	}
+	for _, rec := range recs {
+		if rec.Address == address || rec.UserID == userID {
+			continue
+		}
+		owner, err := s.liveOwner(ctx, tx, rec.Address, now)
+		if err != nil {
+			return "", err
+		}
+		if owner != "" && owner != userID {
+			return owner, nil
+		}
+	}
+	return "", nil
+}
+
+// registerUnicodeAddress 为用户新申请的国际化地址记录骨架并生成 ASCII 兜底地址，需在事务中调用
+// ASCII 地址不做处理；地址已有该用户的记录时沿用原兜底地址
+func (s *MindAdvisorService) registerUnicodeAddress(ctx context.Context, tx *gorm.DB, userID, address string, now time.Time) error {
+	if isASCII(address) {
+		return nil
+	}
+	unicodeDao := dao.NewMindAdvisorUnicodeAddressDao(tx)
+	existing, err := unicodeDao.GetByAddress(ctx, address)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		return nil
+	}
+
+	localPart := strings.TrimSuffix(address, EmailDomain)
+	fallback, err := s.generateFallback(ctx, tx, localPart, now)
+	if err != nil {
+		return err
+	}
+	rec := &datamodel.MindAdvisorUnicodeAddress{
+		UserID:          userID,
+		Address:         address,
+		Skeleton:        skeleton(localPart),
+		FallbackAddress: fallback,
+	}
+	if err := unicodeDao.Create(ctx, rec); err != nil {
+		return err
+	}
+	logger.InfofCtx(ctx, "unicode address %s registered with fallback %s", address, fallback)
+	return nil
+}
+
+// generateFallback 生成未被占用的 ASCII 兜底地址
+// 依次尝试转写结果本身与加数字后缀，无法转写时使用地址的哈希
+func (s *MindAdvisorService) generateFallback(ctx context.Context, tx *gorm.DB, localPart string, now time.Time) (string, error) {
+	base := sanitizeLocalPart(romanize(localPart))
+	if len(base) < 2 {
+		sum := sha256.Sum256([]byte(localPart))
+		base = "u" + hex.EncodeToString(sum[:])[:9]
+	}
+	if len(base) > LocalPartMaxLen-3 {
+		base = strings.Trim(base[:LocalPartMaxLen-3], ".")
+	}
+
+	unicodeDao := dao.NewMindAdvisorUnicodeAddressDao(tx)
+	for i := 0; i < maxFallbackAttempts; i++ {
+		candidate := base
+		if i > 0 || len(candidate) < LocalPartMinLen {
+			candidate = base + strconv.Itoa(i+1)
+		}
+		for len(candidate) < LocalPartMinLen {
+			candidate += "0"
+		}
+		if _, err := s.checkLocalPart(candidate); err != nil {
+			continue
+		}
+		address := candidate + EmailDomain
+		owner, err := s.liveOwner(ctx, tx, address, now)
+		if err != nil {
+			return "", err
+		}
+		if owner != "" {
+			continue
+		}
+		// 已失效但尚未清理的兜底地址同样跳过，避免唯一索引冲突
+		rec, err := unicodeDao.GetByFallbackAddress(ctx, address)
+		if err != nil {
+			return "", err
+		}
+		if rec == nil {
+			return address, nil
+		}
+	}
+	return "", fmt.Errorf("no fallback address available for %s", localPart)
+}
+
+// resolveFallback 查询兜底地址对应的国际化地址所属用户
+func (s *MindAdvisorService) resolveFallback(ctx context.Context, address string) (*datamodel.MindAdvisorUser, error) {
+	rec, err := s.unicodeAddressDao.GetByFallbackAddress(ctx, address)
+	if err != nil {
+		return nil, err
+	}
+	if rec == nil {
+		return nil, nil
+	}
+	user, err := s.userDao.GetByDedicatedEmail(ctx, rec.Address)
+	if err != nil || user != nil {
+		return user, err
+	}
+	if user, err = s.resolveAlias(ctx, rec.Address); err != nil || user != nil {
+		return user, err
+	}
+	return s.resolveRetired(ctx, rec.Address)
+}
+
+// GetFallbackAddress 查询国际化地址的 ASCII 兜底地址，ASCII 地址或未启用国际化时返回空
+func (s *MindAdvisorService) GetFallbackAddress(ctx context.Context, address string) (string, error) {
+	if !s.conf.UnicodeLocalPart || isASCII(address) {
+		return "", nil
+	}
+	rec, err := s.unicodeAddressDao.GetByAddress(ctx, address)
+	if err != nil {
+		logger.ErrorfCtx(ctx, "get unicode address error: %v", err)
+		return "", err
+	}
+	if rec == nil {
+		return "", nil
+	}
+	return rec.FallbackAddress, nil
+}
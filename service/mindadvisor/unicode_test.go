@@ -0,0 +1,124 @@
+package mindadvisor
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeLocalPart(t *testing.T) {
+	cases := map[string]string{
+		"Jane.Doe":   "jane.doe",
+		"Jose\u0301": "josé",
+		"ÉLODIE":     "élodie",
+		"张伟":         "张伟",
+	}
+	for in, want := range cases {
+		if got := normalizeLocalPart(in); got != want {
+			t.Errorf("normalizeLocalPart(%q) = %q, want %q", in, got, want)
+		}
+	}
+	if got := normalizeAddress("Jose\u0301@MyPlaud"); got != "josé@myplaud" {
+		t.Errorf("normalizeAddress = %q", got)
+	}
+}
+
+func TestValidateUnicodeLocalPart(t *testing.T) {
+	cases := map[string]error{
+		"张伟":                    nil,
+		"josé":                  nil,
+		"やまだー":                  nil,
+		"佐々木":                   nil,
+		"김민준":                   nil,
+		"дмитрий":               nil,
+		"zhang.伟2":              nil,
+		"张":                     ErrInvalidLocalPartLength,
+		strings.Repeat("伟", 21): ErrInvalidLocalPartLength,
+		strings.Repeat("𠀀", 17): ErrInvalidLocalPartLength,
+		"张_伟":                   ErrInvalidLocalPartChars,
+		"😀😀":                    ErrInvalidLocalPartChars,
+		"\u0301ab":              ErrInvalidLocalPartChars,
+		"аpple":                 ErrInvalidLocalPartChars,
+		"дмитрийα":              ErrInvalidLocalPartChars,
+		"дима伟":                 ErrInvalidLocalPartChars,
+	}
+	for lp, want := range cases {
+		if err := validateUnicodeLocalPart(lp); !errors.Is(err, want) {
+			t.Errorf("validateUnicodeLocalPart(%q) = %v, want %v", lp, err, want)
+		}
+	}
+}
+
+func TestSkeleton(t *testing.T) {
+	same := [][2]string{
+		{"јane", "jane"},
+		{"ｊａｎｅ", "jane"},
+		{"josé", "jose"},
+		{"αdmin", "admin"},
+		{"ロ一", "口一"},
+		{"カー", "力一"},
+	}
+	for _, p := range same {
+		if skeleton(p[0]) != skeleton(p[1]) {
+			t.Errorf("skeleton(%q) = %q, skeleton(%q) = %q, want equal", p[0], skeleton(p[0]), p[1], skeleton(p[1]))
+		}
+	}
+	different := [][2]string{
+		{"张伟", "张玮"},
+		{"jane", "jone"},
+		// 汉字以外文字的附加符号保留，如韩文与日文的浊点
+		{"が", "か"},
+	}
+	for _, p := range different {
+		if skeleton(p[0]) == skeleton(p[1]) {
+			t.Errorf("skeleton(%q) == skeleton(%q) = %q, want different", p[0], p[1], skeleton(p[0]))
+		}
+	}
+}
+
+func TestRomanize(t *testing.T) {
+	cases := map[string]string{
+		"张伟":       "zhangwei",
+		"李.娜":      "li.na",
+		"さとう":      "satou",
+		"きょうこ":     "kyouko",
+		"しゃしん":     "shashin",
+		"がっこう":     "gakkou",
+		"まっちゃ":     "matcha",
+		"コーヒー":     "kohi",
+		"ファン":      "fan",
+		"김민준":      "gimminjun",
+		"josé":     "jose",
+		"Дмитрий":  "dmitriy",
+		"Ελένη":    "eleni",
+		"straße":   "strasse",
+		"😀ab":      "ab",
+		"jane2026": "jane2026",
+	}
+	for in, want := range cases {
+		if got := romanize(in); got != want {
+			t.Errorf("romanize(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestCheckLocalPartUnicode(t *testing.T) {
+	s := newTestService(true)
+	cases := map[string]error{
+		"张伟":    nil,
+		"Josè":  nil,
+		"张":     ErrInvalidLocalPartLength,
+		"abc":   ErrInvalidLocalPartLength,
+		"аdmin": ErrInvalidLocalPartChars,
+		"ａｄｍｉｎ": ErrReservedWord,
+	}
+	for lp, want := range cases {
+		if _, err := s.checkLocalPart(lp); !errors.Is(err, want) {
+			t.Errorf("checkLocalPart(%q) = %v, want %v", lp, err, want)
+		}
+	}
+	// 未启用国际化地址时按 ASCII 规则拒绝
+	if _, err := newTestService(false).checkLocalPart("张伟"); !errors.Is(err, ErrInvalidLocalPartLength) && !errors.Is(err, ErrInvalidLocalPartChars) {
+		t.Errorf("checkLocalPart without unicode support = %v", err)
+	}
+}
@@ -0,0 +1,334 @@
+package retention
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"plaud-emails/dao"
+	datamodel "plaud-emails/data/model"
+	appconfig "plaud-emails/pkg/config"
+	"plaud-emails/service/message"
+
+	"github.com/Plaud-AI/plaud-go-scaffold/pkg/logger"
+	"github.com/Plaud-AI/plaud-go-scaffold/pkg/svc"
+	"github.com/Plaud-AI/plaud-library-go/observability/core"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"gorm.io/gorm"
+)
+
+const (
+	// MinDays 用户可设置的最短保留天数
+	MinDays = 1
+	// MaxDays 全局规则为永久保留时用户可设置的最长保留天数
+	MaxDays = 3650
+
+	kindMessage    = "message"
+	kindAttachment = "attachment"
+)
+
+// ErrInvalidDays 保留天数不合法
+var ErrInvalidDays = errors.New("retention days must be between 1 and the global retention")
+
+// Policy 用户生效的保留策略，天数为 0 表示永久保留
+type Policy struct {
+	MessageDays    int
+	AttachmentDays int
+	// Custom 用户是否设置了自定义策略，未设置的字段沿用全局规则
+	Custom bool
+	// GlobalMessageDays 与 GlobalAttachmentDays 为全局规则，也是用户可设置的上限
+	GlobalMessageDays    int
+	GlobalAttachmentDays int
+}
+
+// RetentionService 邮件保留策略服务，后台定期清除到期邮件与附件
+// 每封邮件通过条件更新抢占，多副本同时清理时不会重复扣减用量
+type RetentionService struct {
+	svc.BaseService
+	policyDao  *dao.RetentionPolicyDao
+	messageDao *dao.MessageDao
+	messages   *message.MessageService
+	conf       *appconfig.RetentionConfig
+
+	purgedMessages metric.Int64Counter
+	purgedBytes    metric.Int64Counter
+	failures       metric.Int64Counter
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// New 创建 RetentionService，需在 observability 初始化之后调用，否则指标不会上报
+func New(db *gorm.DB, messages *message.MessageService, conf *appconfig.RetentionConfig) *RetentionService {
+	s := &RetentionService{
+		policyDao:  dao.NewRetentionPolicyDao(db),
+		messageDao: dao.NewMessageDao(db),
+		messages:   messages,
+		conf:       conf,
+	}
+
+	meter := core.GetMeter("plaud-emails/retention")
+	var err error
+	if s.purgedMessages, err = meter.Int64Counter("mailbox.retention.purged_messages",
+		metric.WithDescription("Messages or attachments removed by retention policies"), metric.WithUnit("{message}")); err != nil {
+		logger.Warnf("create retention metric error: %v", err)
+	}
+	if s.purgedBytes, err = meter.Int64Counter("mailbox.retention.purged_bytes",
+		metric.WithDescription("Storage bytes released by retention policies"), metric.WithUnit("By")); err != nil {
+		logger.Warnf("create retention metric error: %v", err)
+	}
+	if s.failures, err = meter.Int64Counter("mailbox.retention.failures",
+		metric.WithDescription("Messages that failed to be removed by retention policies"), metric.WithUnit("{message}")); err != nil {
+		logger.Warnf("create retention metric error: %v", err)
+	}
+	return s
+}
+
+// GetPolicy 查询用户生效的保留策略
+func (s *RetentionService) GetPolicy(ctx context.Context, userID string) (*Policy, error) {
+	record, err := s.policyDao.GetByUserID(ctx, userID)
+	if err != nil {
+		logger.ErrorfCtx(ctx, "get retention policy error: %v", err)
+		return nil, err
+	}
+
+	policy := &Policy{
+		MessageDays:          s.conf.MessageDays,
+		AttachmentDays:       s.conf.AttachmentDays,
+		GlobalMessageDays:    s.conf.MessageDays,
+		GlobalAttachmentDays: s.conf.AttachmentDays,
+	}
+	if record != nil {
+		policy.Custom = true
+		if record.MessageDays != nil {
+			policy.MessageDays = *record.MessageDays
+		}
+		if record.AttachmentDays != nil {
+			policy.AttachmentDays = *record.AttachmentDays
+		}
+	}
+	return policy, nil
+}
+
+// SetPolicy 设置用户的保留策略，字段为 nil 时沿用全局规则
+// 用户只能缩短保留期，不能超过全局规则
+func (s *RetentionService) SetPolicy(ctx context.Context, userID string, messageDays, attachmentDays *int) (*Policy, error) {
+	if !validDays(messageDays, s.conf.MessageDays) || !validDays(attachmentDays, s.conf.AttachmentDays) {
+		return nil, ErrInvalidDays
+	}
+
+	if messageDays == nil && attachmentDays == nil {
+		return s.ResetPolicy(ctx, userID)
+	}
+	err := s.policyDao.Upsert(ctx, &datamodel.RetentionPolicy{
+		UserID:         userID,
+		MessageDays:    messageDays,
+		AttachmentDays: attachmentDays,
+	})
+	if err != nil {
+		logger.ErrorfCtx(ctx, "set retention policy error: %v", err)
+		return nil, err
+	}
+	return s.GetPolicy(ctx, userID)
+}
+
+// ResetPolicy 删除用户的保留策略，恢复使用全局规则
+func (s *RetentionService) ResetPolicy(ctx context.Context, userID string) (*Policy, error) {
+	if err := s.policyDao.DeleteByUserID(ctx, userID); err != nil {
+		logger.ErrorfCtx(ctx, "reset retention policy error: %v", err)
+		return nil, err
+	}
+	return s.GetPolicy(ctx, userID)
+}
+
+// validDays 检查用户设置的保留天数，global 为 0 表示全局永久保留
+func validDays(days *int, global int) bool {
+	if days == nil {
+		return true
+	}
+	max := MaxDays
+	if global > 0 {
+		max = global
+	}
+	return *days >= MinDays && *days <= max
+}
+
+// rule 一条生效的保留规则
+type rule struct {
+	kind  string
+	query *dao.RetentionQuery
+}
+
+// rules 根据全局规则与用户策略生成本轮的清理规则，邮件过期先于附件移除
+func (s *RetentionService) rules(ctx context.Context, now time.Time) ([]*rule, error) {
+	policies, err := s.policyDao.ListAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var messageRules, attachmentRules []*rule
+	var messageCustom, attachmentCustom []string
+	for _, p := range policies {
+		if p.MessageDays != nil {
+			messageCustom = append(messageCustom, p.UserID)
+			if *p.MessageDays > 0 {
+				messageRules = append(messageRules, &rule{kind: kindMessage, query: &dao.RetentionQuery{
+					UserID: p.UserID,
+					Before: now.AddDate(0, 0, -*p.MessageDays),
+				}})
+			}
+		}
+		if p.AttachmentDays != nil {
+			attachmentCustom = append(attachmentCustom, p.UserID)
+			if *p.AttachmentDays > 0 {
+				attachmentRules = append(attachmentRules, &rule{kind: kindAttachment, query: &dao.RetentionQuery{
+					UserID:      p.UserID,
+					Before:      now.AddDate(0, 0, -*p.AttachmentDays),
+					Attachments: true,
+				}})
+			}
+		}
+	}
+	if s.conf.MessageDays > 0 {
+		messageRules = append(messageRules, &rule{kind: kindMessage, query: &dao.RetentionQuery{
+			ExcludeUserIDs: messageCustom,
+			Before:         now.AddDate(0, 0, -s.conf.MessageDays),
+		}})
+	}
+	if s.conf.AttachmentDays > 0 {
+		attachmentRules = append(attachmentRules, &rule{kind: kindAttachment, query: &dao.RetentionQuery{
+			ExcludeUserIDs: attachmentCustom,
+			Before:         now.AddDate(0, 0, -s.conf.AttachmentDays),
+			Attachments:    true,
+		}})
+	}
+	return append(messageRules, attachmentRules...), nil
+}
+
+// sweepLoop 定期清理保留期已到的邮件与附件
+func (s *RetentionService) sweepLoop(ctx context.Context, interval time.Duration) {
+	defer s.wg.Done()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		s.sweep(ctx)
+	}
+}
+
+// sweep 执行一轮清理
+func (s *RetentionService) sweep(ctx context.Context) {
+	rules, err := s.rules(ctx, time.Now())
+	if err != nil {
+		if ctx.Err() == nil {
+			logger.Errorf("list retention policies error: %v", err)
+		}
+		return
+	}
+
+	for _, r := range rules {
+		if ctx.Err() != nil {
+			return
+		}
+		count, bytes := s.apply(ctx, r)
+		if count > 0 {
+			logger.Infof("retention removed %d %ss (%d bytes), user: %q", count, r.kind, bytes, r.query.UserID)
+		}
+	}
+}
+
+// apply 按一条规则分批清理，返回处理的邮件数与释放的字节数
+func (s *RetentionService) apply(ctx context.Context, r *rule) (count, bytes int64) {
+	attrs := metric.WithAttributes(attribute.String("kind", r.kind))
+	var afterID uint64
+	for ctx.Err() == nil {
+		msgs, err := s.messageDao.ListExpired(ctx, r.query, afterID, s.conf.BatchSize)
+		if err != nil {
+			if ctx.Err() == nil {
+				logger.Errorf("list expired messages error: %v", err)
+			}
+			return count, bytes
+		}
+
+		for _, msg := range msgs {
+			if ctx.Err() != nil {
+				return count, bytes
+			}
+			afterID = msg.ID
+
+			var released int64
+			var done bool
+			if r.kind == kindMessage {
+				done, err = s.messages.Expire(ctx, msg)
+				released = msg.Size
+			} else {
+				released, err = s.messages.StripAttachments(ctx, msg)
+				done = released > 0
+			}
+			if err != nil {
+				s.failures.Add(ctx, 1, attrs)
+			}
+			if !done {
+				continue
+			}
+			count++
+			bytes += released
+			s.purgedMessages.Add(ctx, 1, attrs)
+			s.purgedBytes.Add(ctx, released, attrs)
+		}
+
+		if len(msgs) < s.conf.BatchSize {
+			break
+		}
+	}
+	return count, bytes
+}
+
+// Init 初始化服务
+func (s *RetentionService) Init(ctx context.Context) error {
+	if s.IsInited() {
+		return nil
+	}
+	s.SetInited(true)
+	return nil
+}
+
+// Start 启动服务，未启用时不运行清理任务
+func (s *RetentionService) Start(ctx context.Context) error {
+	if s.IsStarted() {
+		return nil
+	}
+	if s.conf.Enabled {
+		runCtx, cancel := context.WithCancel(context.Background())
+		s.cancel = cancel
+		s.wg.Add(1)
+		go s.sweepLoop(runCtx, time.Duration(s.conf.SweepIntervalSeconds)*time.Second)
+	} else {
+		logger.Warnf("retention sweeper is disabled")
+	}
+	logger.Infof("start retention service")
+	s.SetStarted(true)
+	return nil
+}
+
+// Stop 停止服务
+func (s *RetentionService) Stop(ctx context.Context) error {
+	if !s.IsStarted() || s.IsStopped() {
+		return nil
+	}
+	defer s.SetStopped(true)
+	if s.cancel != nil {
+		s.cancel()
+		s.wg.Wait()
+	}
+	logger.Infof("stop retention service")
+	return nil
+}
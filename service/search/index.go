@@ -0,0 +1,34 @@
+package search
+
+import (
+	"context"
+	"time"
+)
+
+// Document 一封邮件的检索文档
+type Document struct {
+	// ID 邮件记录 id
+	ID             uint64
+	UserID         string
+	From           string
+	To             string
+	Subject        string
+	Body           string
+	Filenames      []string
+	Tag            string
+	HasAttachments bool
+	ReceivedAt     time.Time
+}
+
+// Index 检索后端，所有操作都限定在 userID 范围内
+// 默认实现为 MySQL FULLTEXT，更换后端时实现该接口即可
+type Index interface {
+	// Put 写入或覆盖文档
+	Put(ctx context.Context, doc *Document) error
+	// Delete 删除一封邮件的文档
+	Delete(ctx context.Context, userID string, id uint64) error
+	// DeleteUser 删除用户的全部文档
+	DeleteUser(ctx context.Context, userID string) error
+	// Search 按邮件 id 倒序返回匹配的邮件 id，beforeID 为 0 时从最新开始
+	Search(ctx context.Context, userID string, q *Query, beforeID uint64, limit int) ([]uint64, error)
+}
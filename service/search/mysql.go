@@ -0,0 +1,84 @@
+package search
+
+import (
+	"context"
+	"strings"
+
+	"plaud-emails/dao"
+	datamodel "plaud-emails/data/model"
+
+	"gorm.io/gorm"
+)
+
+// MySQLIndex 基于 MySQL FULLTEXT（ngram 分词）的检索后端
+type MySQLIndex struct {
+	searchDao *dao.MessageSearchDao
+}
+
+// NewMySQLIndex 创建 MySQLIndex
+func NewMySQLIndex(db *gorm.DB) *MySQLIndex {
+	return &MySQLIndex{searchDao: dao.NewMessageSearchDao(db)}
+}
+
+// Put 写入或覆盖文档
+func (x *MySQLIndex) Put(ctx context.Context, doc *Document) error {
+	return x.searchDao.Upsert(ctx, &datamodel.MessageSearchDoc{
+		ID:             doc.ID,
+		UserID:         doc.UserID,
+		FromText:       doc.From,
+		ToText:         doc.To,
+		Subject:        doc.Subject,
+		Body:           doc.Body,
+		Filenames:      strings.Join(doc.Filenames, "\n"),
+		Tag:            doc.Tag,
+		HasAttachments: doc.HasAttachments,
+		ReceivedAt:     doc.ReceivedAt,
+	})
+}
+
+// Delete 删除一封邮件的文档
+func (x *MySQLIndex) Delete(ctx context.Context, userID string, id uint64) error {
+	return x.searchDao.DeleteByID(ctx, userID, id)
+}
+
+// DeleteUser 删除用户的全部文档
+func (x *MySQLIndex) DeleteUser(ctx context.Context, userID string) error {
+	return x.searchDao.DeleteByUserID(ctx, userID)
+}
+
+// Search 将检索条件转换为 BOOLEAN MODE 表达式后查询
+func (x *MySQLIndex) Search(ctx context.Context, userID string, q *Query, beforeID uint64, limit int) ([]uint64, error) {
+	// label: 为系统标签时按文件夹过滤，未指定文件夹时不检索垃圾箱与垃圾邮件
+	var folder, label string
+	if datamodel.IsFolder(q.Label) {
+		folder = q.Label
+	} else {
+		label = q.Label
+	}
+	filter := &dao.SearchFilter{
+		Content:        booleanExpr(q.Terms),
+		From:           booleanExpr(q.From),
+		To:             booleanExpr(q.To),
+		Subject:        booleanExpr(q.Subject),
+		Filename:       booleanExpr(q.Filenames),
+		HasAttachments: q.HasAttachment,
+		Folder:         folder,
+		Label:          label,
+		Tag:            q.Tag,
+		Before:         q.Before,
+		After:          q.After,
+	}
+	return x.searchDao.Search(ctx, userID, filter, beforeID, limit)
+}
+
+// booleanExpr 将每个词转为必须匹配的短语，如 +"hello world"，避免用户输入被解释为操作符
+func booleanExpr(terms []string) string {
+	parts := make([]string, 0, len(terms))
+	for _, t := range terms {
+		t = strings.TrimSpace(strings.ReplaceAll(t, `"`, " "))
+		if t != "" {
+			parts = append(parts, `+"`+t+`"`)
+		}
+	}
+	return strings.Join(parts, " ")
+}
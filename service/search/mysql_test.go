@@ -0,0 +1,22 @@
+package search
+
+import "testing"
+
+func TestBooleanExpr(t *testing.T) {
+	cases := []struct {
+		terms []string
+		want  string
+	}{
+		{nil, ""},
+		{[]string{"hello"}, `+"hello"`},
+		{[]string{"hello world", "bob"}, `+"hello world" +"bob"`},
+		// 用户输入中的引号与布尔操作符不会逃出短语
+		{[]string{`a" -b +"c`}, `+"a  -b + c"`},
+		{[]string{`"`, " "}, ""},
+	}
+	for _, tc := range cases {
+		if got := booleanExpr(tc.terms); got != tc.want {
+			t.Errorf("booleanExpr(%q) = %q, want %q", tc.terms, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package search
+
+import (
+	"errors"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	// MaxQueryTerms 一次检索最多的条件数量
+	MaxQueryTerms = 20
+	// MaxTermRunes 单个检索词的最大长度
+	MaxTermRunes = 128
+)
+
+// 错误定义
+var (
+	ErrEmptyQuery   = errors.New("search query is empty")
+	ErrInvalidQuery = errors.New("invalid search query")
+)
+
+// Query 解析后的检索条件，同一字段的多个词需同时匹配
+type Query struct {
+	Terms     []string
+	From      []string
+	To        []string
+	Subject   []string
+	Filenames []string
+
+	HasAttachment bool
+	Label         string
+	Tag           string
+	// Before 只匹配该时间之前收到的邮件，After 只匹配该时间及之后收到的邮件
+	Before *time.Time
+	After  *time.Time
+}
+
+// dateLayouts before: 与 after: 支持的日期格式
+var dateLayouts = []string{"2006-01-02", "2006/01/02"}
+
+// ParseQuery 解析检索语句，支持自由文本、"短语" 与以下操作符：
+//
+//	from:  to:  subject:  filename:  has:attachment  label:  tag:  before:YYYY-MM-DD  after:YYYY-MM-DD
+//
+// 操作符的值可使用双引号，如 from:"John Smith"；未知操作符按普通文本处理
+// loc 为解析日期使用的时区
+func ParseQuery(q string, loc *time.Location) (*Query, error) {
+	tokens := tokenize(q)
+	if len(tokens) == 0 {
+		return nil, ErrEmptyQuery
+	}
+	if len(tokens) > MaxQueryTerms {
+		return nil, ErrInvalidQuery
+	}
+
+	query := &Query{}
+	for _, tok := range tokens {
+		if utf8.RuneCountInString(tok.value) > MaxTermRunes {
+			return nil, ErrInvalidQuery
+		}
+		switch tok.key {
+		case "":
+			query.Terms = append(query.Terms, tok.value)
+		case "from":
+			query.From = append(query.From, tok.value)
+		case "to":
+			query.To = append(query.To, tok.value)
+		case "subject":
+			query.Subject = append(query.Subject, tok.value)
+		case "filename":
+			query.Filenames = append(query.Filenames, tok.value)
+		case "has":
+			if strings.ToLower(tok.value) != "attachment" {
+				return nil, ErrInvalidQuery
+			}
+			query.HasAttachment = true
+		case "label":
+			query.Label = strings.ToLower(tok.value)
+		case "tag":
+			query.Tag = strings.ToLower(tok.value)
+		case "before", "after":
+			t, ok := parseDate(tok.value, loc)
+			if !ok {
+				return nil, ErrInvalidQuery
+			}
+			if tok.key == "before" {
+				query.Before = &t
+			} else {
+				query.After = &t
+			}
+		}
+	}
+	return query, nil
+}
+
+// token 检索语句中的一个词，key 为空表示自由文本
+type token struct {
+	key   string
+	value string
+}
+
+// operators 支持的操作符
+var operators = map[string]bool{
+	"from": true, "to": true, "subject": true, "filename": true,
+	"has": true, "label": true, "tag": true, "before": true, "after": true,
+}
+
+// tokenize 按空白切分检索语句，双引号内的空白不切分，空值的操作符忽略
+func tokenize(q string) []token {
+	var tokens []token
+	for i := 0; i < len(q); {
+		for i < len(q) && isSpace(q[i]) {
+			i++
+		}
+		if i >= len(q) {
+			break
+		}
+
+		var key string
+		start := i
+		for i < len(q) && !isSpace(q[i]) && q[i] != ':' && q[i] != '"' {
+			i++
+		}
+		if i < len(q) && q[i] == ':' && operators[strings.ToLower(q[start:i])] {
+			key = strings.ToLower(q[start:i])
+			i++
+		} else {
+			i = start
+		}
+
+		var value string
+		if i < len(q) && q[i] == '"' {
+			end := strings.IndexByte(q[i+1:], '"')
+			if end < 0 {
+				value, i = q[i+1:], len(q)
+			} else {
+				value, i = q[i+1:i+1+end], i+end+2
+			}
+		} else {
+			start = i
+			for i < len(q) && !isSpace(q[i]) {
+				i++
+			}
+			value = q[start:i]
+		}
+
+		value = strings.Join(strings.Fields(value), " ")
+		if value != "" {
+			tokens = append(tokens, token{key: key, value: value})
+		}
+	}
+	return tokens
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\r' || c == '\n'
+}
+
+func parseDate(v string, loc *time.Location) (time.Time, bool) {
+	for _, layout := range dateLayouts {
+		if t, err := time.ParseInLocation(layout, v, loc); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
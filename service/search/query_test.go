@@ -0,0 +1,62 @@
+package search
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseQuery(t *testing.T) {
+	shanghai := time.FixedZone("CST", 8*3600)
+	before := time.Date(2026, 3, 1, 0, 0, 0, 0, shanghai)
+	after := time.Date(2026, 1, 15, 0, 0, 0, 0, shanghai)
+
+	cases := []struct {
+		q    string
+		want *Query
+	}{
+		{"invoice", &Query{Terms: []string{"invoice"}}},
+		{`  "quarterly   report"  budget `, &Query{Terms: []string{"quarterly report", "budget"}}},
+		{`from:"John Smith" FROM:alice@example.com to:bob`, &Query{From: []string{"John Smith", "alice@example.com"}, To: []string{"bob"}}},
+		{`subject:"Re: plan" filename:report.pdf`, &Query{Subject: []string{"Re: plan"}, Filenames: []string{"report.pdf"}}},
+		{"has:Attachment label:Work tag:Receipts", &Query{HasAttachment: true, Label: "work", Tag: "receipts"}},
+		{"before:2026-03-01 after:2026/01/15", &Query{Before: &before, After: &after}},
+		// 未知操作符与带冒号的文本按普通文本处理
+		{"https://example.com cc:bob", &Query{Terms: []string{"https://example.com", "cc:bob"}}},
+		// 空值的操作符忽略，未闭合的引号取到结尾
+		{`from: "" subject:"open ended`, &Query{Subject: []string{"open ended"}}},
+		{"报告 from:张三", &Query{Terms: []string{"报告"}, From: []string{"张三"}}},
+	}
+	for _, tc := range cases {
+		got, err := ParseQuery(tc.q, shanghai)
+		if err != nil {
+			t.Errorf("ParseQuery(%q) error: %v", tc.q, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("ParseQuery(%q) = %+v, want %+v", tc.q, got, tc.want)
+		}
+	}
+}
+
+func TestParseQueryInvalid(t *testing.T) {
+	cases := map[string]error{
+		"":                                    ErrEmptyQuery,
+		`   from: "" `:                        ErrEmptyQuery,
+		"has:pdf":                             ErrInvalidQuery,
+		"before:yesterday":                    ErrInvalidQuery,
+		"after:2026-13-01":                    ErrInvalidQuery,
+		strings.Repeat("a ", MaxQueryTerms+1): ErrInvalidQuery,
+		strings.Repeat("长", MaxTermRunes+1):   ErrInvalidQuery,
+	}
+	for q, want := range cases {
+		if _, err := ParseQuery(q, time.UTC); !errors.Is(err, want) {
+			t.Errorf("ParseQuery(%.20q) err = %v, want %v", q, err, want)
+		}
+	}
+	if _, err := ParseQuery(strings.Repeat("长", MaxTermRunes), time.UTC); err != nil {
+		t.Errorf("term of %d runes should be accepted: %v", MaxTermRunes, err)
+	}
+}
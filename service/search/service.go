@@ -0,0 +1,227 @@
+package search
+
+import (
+	"context"
+	"time"
+
+	"plaud-emails/dao"
+	datamodel "plaud-emails/data/model"
+	"plaud-emails/pkg/textutil"
+	"plaud-emails/service/message"
+	"plaud-emails/service/mimeparse"
+
+	"github.com/Plaud-AI/plaud-go-scaffold/pkg/logger"
+	"github.com/Plaud-AI/plaud-go-scaffold/pkg/svc"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// DefaultListLimit 检索结果默认分页大小
+	DefaultListLimit = 20
+	// MaxListLimit 检索结果最大分页大小
+	MaxListLimit = 100
+
+	// maxBodyBytes 正文写入索引的最大字节数
+	maxBodyBytes     = 64 << 10
+	reindexBatchSize = 100
+)
+
+// SearchService 邮件全文检索服务，入库时写入索引，删除邮件时同步删除
+type SearchService struct {
+	svc.BaseService
+	index      Index
+	messageDao *dao.MessageDao
+	userDao    *dao.MindAdvisorUserDao
+	messages   *message.MessageService
+}
+
+// New 创建使用 MySQL FULLTEXT 后端的 SearchService
+func New(db *gorm.DB, messages *message.MessageService) *SearchService {
+	return NewWithIndex(db, NewMySQLIndex(db), messages)
+}
+
+// NewWithIndex 创建使用指定检索后端的 SearchService
+func NewWithIndex(db *gorm.DB, index Index, messages *message.MessageService) *SearchService {
+	return &SearchService{
+		index:      index,
+		messageDao: dao.NewMessageDao(db),
+		userDao:    dao.NewMindAdvisorUserDao(db),
+		messages:   messages,
+	}
+}
+
+// Search 检索用户的邮件，按 id 倒序分页，返回下一页游标（0 表示没有更多）
+// 日期按用户设置的时区解析，检索语句无效时返回 ErrEmptyQuery 或 ErrInvalidQuery
+func (s *SearchService) Search(ctx context.Context, userID, q string, cursor uint64, limit int) ([]*datamodel.Message, uint64, error) {
+	if limit <= 0 {
+		limit = DefaultListLimit
+	}
+	if limit > MaxListLimit {
+		limit = MaxListLimit
+	}
+
+	loc, err := s.location(ctx, userID)
+	if err != nil {
+		return nil, 0, err
+	}
+	query, err := ParseQuery(q, loc)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	ids, err := s.index.Search(ctx, userID, query, cursor, limit+1)
+	if err != nil {
+		logger.ErrorfCtx(ctx, "search messages error: %v", err)
+		return nil, 0, err
+	}
+	var next uint64
+	if len(ids) > limit {
+		ids = ids[:limit]
+		next = ids[limit-1]
+	}
+
+	// 再次按 user_id 读取邮件，索引与邮件状态不一致时以邮件记录为准
+	found, err := s.messageDao.ListByIDs(ctx, userID, ids)
+	if err != nil {
+		logger.ErrorfCtx(ctx, "list searched messages error: %v", err)
+		return nil, 0, err
+	}
+	byID := make(map[uint64]*datamodel.Message, len(found))
+	for _, m := range found {
+		byID[m.ID] = m
+	}
+	msgs := make([]*datamodel.Message, 0, len(ids))
+	for _, id := range ids {
+		if m, ok := byID[id]; ok {
+			msgs = append(msgs, m)
+		}
+	}
+	return msgs, next, nil
+}
+
+// location 返回用户设置的时区，未设置时使用 UTC
+func (s *SearchService) location(ctx context.Context, userID string) (*time.Location, error) {
+	user, err := s.userDao.GetByUserID(ctx, userID)
+	if err != nil {
+		logger.ErrorfCtx(ctx, "get mind advisor user error: %v", err)
+		return nil, err
+	}
+	if user == nil || user.Config == nil || user.Config.Timezone == "" {
+		return time.UTC, nil
+	}
+	loc, err := time.LoadLocation(user.Config.Timezone)
+	if err != nil {
+		return time.UTC, nil
+	}
+	return loc, nil
+}
+
+// OnMessageStored 实现 message.StoredListener，将新邮件写入索引
+func (s *SearchService) OnMessageStored(ctx context.Context, msg *datamodel.Message, parsed *mimeparse.Message) {
+	if err := s.index.Put(ctx, newDocument(msg, parsed)); err != nil {
+		// 未写入索引的邮件可通过重建接口补齐
+		logger.ErrorfCtx(ctx, "index message %d error: %v", msg.ID, err)
+	}
+}
+
+// OnMessageRemoved 实现 message.RemovedListener，从索引中删除邮件
+func (s *SearchService) OnMessageRemoved(ctx context.Context, msg *datamodel.Message) {
+	if err := s.index.Delete(ctx, msg.UserID, msg.ID); err != nil {
+		logger.ErrorfCtx(ctx, "delete search document of message %d error: %v", msg.ID, err)
+	}
+}
+
+// PurgeUser 删除用户的全部索引，实现 mindadvisor.MailboxPurger
+func (s *SearchService) PurgeUser(ctx context.Context, userID string) error {
+	if err := s.index.DeleteUser(ctx, userID); err != nil {
+		logger.ErrorfCtx(ctx, "delete search documents of user %s error: %v", userID, err)
+		return err
+	}
+	return nil
+}
+
+// Reindex 为用户尚未写入索引的邮件补齐索引，返回处理的邮件数
+// 无法读取原始邮件时只索引头部字段与摘要
+func (s *SearchService) Reindex(ctx context.Context, userID string) (int, error) {
+	var count int
+	var afterID uint64
+	for {
+		msgs, err := s.messageDao.ListUnindexed(ctx, userID, afterID, reindexBatchSize)
+		if err != nil {
+			logger.ErrorfCtx(ctx, "list unindexed messages error: %v", err)
+			return count, err
+		}
+		for _, msg := range msgs {
+			afterID = msg.ID
+			parsed, err := s.messages.Parse(ctx, msg)
+			if err != nil {
+				logger.WarnfCtx(ctx, "parse message %d for search error: %v", msg.ID, err)
+			}
+			if err := s.index.Put(ctx, newDocument(msg, parsed)); err != nil {
+				logger.ErrorfCtx(ctx, "index message %d error: %v", msg.ID, err)
+				return count, err
+			}
+			count++
+		}
+		if len(msgs) < reindexBatchSize {
+			break
+		}
+	}
+	logger.InfofCtx(ctx, "reindexed %d messages, user: %s", count, userID)
+	return count, nil
+}
+
+// newDocument 由邮件记录与解析结果生成检索文档，parsed 为 nil 时正文使用摘要
+func newDocument(msg *datamodel.Message, parsed *mimeparse.Message) *Document {
+	doc := &Document{
+		ID:             msg.ID,
+		UserID:         msg.UserID,
+		From:           msg.FromAddr,
+		To:             msg.ToAddrs,
+		Subject:        msg.Subject,
+		Body:           msg.Snippet,
+		Tag:            msg.Tag,
+		HasAttachments: msg.HasAttachments,
+		ReceivedAt:     msg.ReceivedAt,
+	}
+	if parsed == nil {
+		return doc
+	}
+	doc.Body = textutil.Truncate(parsed.Text, maxBodyBytes)
+	for _, a := range parsed.Attachments {
+		if !a.Inline && a.Filename != "" {
+			doc.Filenames = append(doc.Filenames, a.Filename)
+		}
+	}
+	return doc
+}
+
+// Init 初始化服务
+func (s *SearchService) Init(ctx context.Context) error {
+	if s.IsInited() {
+		return nil
+	}
+	s.SetInited(true)
+	return nil
+}
+
+// Start 启动服务
+func (s *SearchService) Start(ctx context.Context) error {
+	if s.IsStarted() {
+		return nil
+	}
+	logger.Infof("start search service")
+	s.SetStarted(true)
+	return nil
+}
+
+// Stop 停止服务
+func (s *SearchService) Stop(ctx context.Context) error {
+	if s.IsStopped() {
+		return nil
+	}
+	defer s.SetStopped(true)
+	logger.Infof("stop search service")
+	return nil
+}
@@ -0,0 +1,34 @@
+package search
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	datamodel "plaud-emails/data/model"
+	"plaud-emails/service/mimeparse"
+)
+
+func TestNewDocument(t *testing.T) {
+	msg := &datamodel.Message{ID: 7, UserID: "u1", FromAddr: "alice@example.com", Subject: "plan", Snippet: "short", HasAttachments: true}
+	doc := newDocument(msg, nil)
+	if doc.ID != 7 || doc.UserID != "u1" || doc.Body != "short" || !doc.HasAttachments || doc.Filenames != nil {
+		t.Fatalf("document without parsed body = %+v", doc)
+	}
+
+	parsed := &mimeparse.Message{
+		Text: strings.Repeat("报", maxBodyBytes),
+		Attachments: []*mimeparse.Attachment{
+			{Filename: "report.pdf"},
+			{Filename: "logo.png", Inline: true},
+			{Filename: ""},
+		},
+	}
+	doc = newDocument(msg, parsed)
+	if len(doc.Body) > maxBodyBytes || !utf8.ValidString(doc.Body) {
+		t.Fatalf("body of %d bytes should be truncated on a rune boundary", len(doc.Body))
+	}
+	if len(doc.Filenames) != 1 || doc.Filenames[0] != "report.pdf" {
+		t.Fatalf("filenames = %v, inline and unnamed parts should be skipped", doc.Filenames)
+	}
+}
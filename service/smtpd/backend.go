@@ -0,0 +1,83 @@
+package smtpd
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"time"
+)
+
+// Recipient 已解析的收件人
+type Recipient struct {
+	// Address RCPT TO 中的原始地址
+	Address string
+	// UserID 收件人所属用户
+	UserID string
+	// DedicatedEmail 收件人的专属邮箱
+	DedicatedEmail string
+	// Alias 命中的别名地址，为空表示投递到专属邮箱
+	Alias string
+	// Tag 子地址标签，如 jane+receipts@myplaud 中的 receipts
+	Tag string
+}
+
+// Envelope 一次 SMTP 事务的信封信息
+type Envelope struct {
+	ID         string
+	RemoteAddr net.Addr
+	Helo       string
+	MailFrom   string
+	Recipients []*Recipient
+	ReceivedAt time.Time
+	// SMTPUTF8 MAIL 命令是否声明了 SMTPUTF8
+	SMTPUTF8 bool
+	// Size MAIL 命令声明的邮件大小，未声明时为 0
+	Size int64
+}
+
+// RemoteIP 获取发信方 IP
+func (e *Envelope) RemoteIP() net.IP {
+	if addr, ok := e.RemoteAddr.(*net.TCPAddr); ok {
+		return addr.IP
+	}
+	return nil
+}
+
+// Backend SMTP 服务的业务后端
+type Backend interface {
+	// ResolveRecipient 解析 RCPT TO 地址，不可投递时返回 *Error
+	// size 为 MAIL FROM 声明的 SIZE，未声明时为 0
+	ResolveRecipient(ctx context.Context, address string, size int64) (*Recipient, error)
+	// Deliver 投递一封完整的邮件，data 已包含 Received 头
+	Deliver(ctx context.Context, env *Envelope, data []byte) error
+}
+
+// Error 带 SMTP 响应码的错误
+type Error struct {
+	Code         int
+	EnhancedCode string
+	Message      string
+}
+
+// Error 实现 error 接口
+func (e *Error) Error() string {
+	return fmt.Sprintf("%d %s %s", e.Code, e.EnhancedCode, e.Message)
+}
+
+// NewError 创建 SMTP 错误
+func NewError(code int, enhancedCode, message string) *Error {
+	return &Error{Code: code, EnhancedCode: enhancedCode, Message: message}
+}
+
+// 常用的 SMTP 错误
+var (
+	ErrMailboxNotFound    = NewError(550, "5.1.1", "mailbox unavailable")
+	ErrMailboxDisabled    = NewError(550, "5.2.1", "the recipient has deactivated this mailbox and is not accepting new mail")
+	ErrRelayDenied        = NewError(550, "5.7.1", "relay not permitted")
+	ErrMessageTooLarge    = NewError(552, "5.3.4", "message size exceeds fixed maximum message size")
+	ErrMailboxFull        = NewError(552, "5.2.2", "mailbox full, storage quota exceeded")
+	ErrTooManyRecipients  = NewError(452, "4.5.3", "too many recipients")
+	ErrTemporaryFailure   = NewError(451, "4.3.0", "requested action aborted: local error in processing")
+	ErrShuttingDown       = NewError(421, "4.3.2", "service shutting down")
+	ErrTooManyConnections = NewError(421, "4.7.0", "too many connections, try again later")
+)
@@ -0,0 +1,197 @@
+package smtpd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	appconfig "plaud-emails/pkg/config"
+
+	"github.com/Plaud-AI/plaud-go-scaffold/pkg/logger"
+	"github.com/Plaud-AI/plaud-go-scaffold/pkg/svc"
+)
+
+// shutdownTimeout 优雅停止时等待进行中会话的最长时间
+const shutdownTimeout = 30 * time.Second
+
+// Server 内置 SMTP 收信服务
+type Server struct {
+	svc.BaseService
+	conf    *appconfig.SMTPConfig
+	backend Backend
+
+	mu       sync.Mutex
+	listener net.Listener
+	sessions map[*session]struct{}
+	wg       sync.WaitGroup
+
+	shuttingDown atomic.Bool
+}
+
+// NewServer 创建 SMTP 服务，conf 为 nil 时服务处于禁用状态
+func NewServer(conf *appconfig.SMTPConfig, backend Backend) *Server {
+	return &Server{
+		conf:     conf,
+		backend:  backend,
+		sessions: make(map[*session]struct{}),
+	}
+}
+
+// Enabled 是否启用 SMTP 服务
+func (s *Server) Enabled() bool {
+	return s.conf != nil && s.conf.IP != "" && s.conf.Port != 0
+}
+
+// ListenAndServe 监听端口并在后台接收连接，运行期错误写入 errChan
+// 未启用时返回 false
+func (s *Server) ListenAndServe(errChan chan<- error) (bool, error) {
+	if !s.Enabled() {
+		logger.Warnf("smtp server is disabled")
+		return false, nil
+	}
+	if s.backend == nil {
+		return false, errors.New("smtp backend is nil")
+	}
+
+	addr := fmt.Sprintf("%s:%d", s.conf.IP, s.conf.Port)
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		return false, fmt.Errorf("listen smtp %s failed: %w", addr, err)
+	}
+
+	s.mu.Lock()
+	s.listener = lis
+	s.mu.Unlock()
+
+	go func() {
+		logger.Infof("smtp server started at %s", addr)
+		if err := s.serve(lis); err != nil {
+			errChan <- fmt.Errorf("smtp server failed: %v", err)
+		}
+	}()
+	return true, nil
+}
+
+// serve 接收连接直至监听器关闭
+func (s *Server) serve(lis net.Listener) error {
+	var tempDelay time.Duration
+	for {
+		conn, err := lis.Accept()
+		if err != nil {
+			if s.shuttingDown.Load() {
+				return nil
+			}
+			var ne net.Error
+			if errors.As(err, &ne) && ne.Timeout() {
+				if tempDelay == 0 {
+					tempDelay = 5 * time.Millisecond
+				} else {
+					tempDelay *= 2
+				}
+				if tempDelay > time.Second {
+					tempDelay = time.Second
+				}
+				logger.Warnf("smtp accept error: %v, retrying in %v", err, tempDelay)
+				time.Sleep(tempDelay)
+				continue
+			}
+			return err
+		}
+		tempDelay = 0
+
+		sess := newSession(s, conn)
+		if err := s.trackSession(sess); err != nil {
+			go sess.reject(err)
+			continue
+		}
+		go func() {
+			defer s.untrackSession(sess)
+			sess.serve()
+		}()
+	}
+}
+
+// trackSession 记录会话，正在停止时返回 ErrShuttingDown，超过连接上限时返回 ErrTooManyConnections
+func (s *Server) trackSession(sess *session) *Error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.shuttingDown.Load() {
+		return ErrShuttingDown
+	}
+	if len(s.sessions) >= s.conf.MaxConnections {
+		logger.Warnf("smtp too many connections, reject %s", sess.remoteAddr)
+		return ErrTooManyConnections
+	}
+	s.sessions[sess] = struct{}{}
+	s.wg.Add(1)
+	return nil
+}
+
+// untrackSession 移除会话
+func (s *Server) untrackSession(sess *session) {
+	s.mu.Lock()
+	delete(s.sessions, sess)
+	s.mu.Unlock()
+	s.wg.Done()
+}
+
+// Stop 优雅停止：关闭监听，等待进行中的会话结束，超时后强制断开
+func (s *Server) Stop(ctx context.Context) error {
+	if s.IsStopped() {
+		return nil
+	}
+	defer s.SetStopped(true)
+
+	s.shuttingDown.Store(true)
+
+	s.mu.Lock()
+	lis := s.listener
+	s.mu.Unlock()
+	if lis == nil {
+		return nil
+	}
+
+	logger.Infof("shutting down smtp server...")
+	if err := lis.Close(); err != nil {
+		logger.Warnf("close smtp listener error: %v", err)
+	}
+
+	// 空闲会话会在下一条命令时收到 421，这里主动唤醒阻塞在读上的会话
+	s.mu.Lock()
+	for sess := range s.sessions {
+		sess.interruptIdle()
+	}
+	s.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+
+	timer := time.NewTimer(shutdownTimeout)
+	defer timer.Stop()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		s.forceClose()
+	case <-timer.C:
+		s.forceClose()
+	}
+	logger.Infof("smtp server stopped")
+	return nil
+}
+
+// forceClose 强制关闭所有会话连接
+func (s *Server) forceClose() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for sess := range s.sessions {
+		_ = sess.conn.Close()
+	}
+	logger.Warnf("smtp server force closed %d sessions", len(s.sessions))
+}
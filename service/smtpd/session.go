@@ -0,0 +1,507 @@
+package smtpd
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Plaud-AI/plaud-go-scaffold/pkg/logger"
+)
+
+const (
+	// maxLineLength 命令行最大长度（RFC 5321 4.5.3.1.4 为 512，这里适当放宽）
+	maxLineLength = 2048
+	// maxBadCommands 连续错误命令上限
+	maxBadCommands = 10
+	// rejectTimeout 拒绝连接时写入响应的超时，拒绝在独立的 goroutine 中进行，不阻塞接收新连接
+	rejectTimeout = 5 * time.Second
+)
+
+var errLineTooLong = errors.New("line too long")
+
+// session 单个 SMTP 连接会话
+type session struct {
+	server     *Server
+	conn       net.Conn
+	reader     *bufio.Reader
+	writer     *bufio.Writer
+	remoteAddr net.Addr
+
+	helo        string
+	env         *Envelope
+	badCommands int
+
+	// idle 标记会话是否在等待下一条命令，停止服务时用于唤醒
+	idle atomic.Bool
+}
+
+func newSession(server *Server, conn net.Conn) *session {
+	return &session{
+		server:     server,
+		conn:       conn,
+		reader:     bufio.NewReaderSize(conn, 4096),
+		writer:     bufio.NewWriter(conn),
+		remoteAddr: conn.RemoteAddr(),
+	}
+}
+
+// reject 直接拒绝连接，不读取对方发送的内容
+func (s *session) reject(e *Error) {
+	_ = s.conn.SetWriteDeadline(time.Now().Add(rejectTimeout))
+	if _, err := fmt.Fprintf(s.conn, "%d %s %s\r\n", e.Code, e.EnhancedCode, e.Message); err != nil {
+		logger.Debugf("smtp reject %s write error: %v", s.remoteAddr, err)
+	}
+	_ = s.conn.Close()
+}
+
+// interruptIdle 唤醒空闲会话，使其尽快响应 421 并退出
+func (s *session) interruptIdle() {
+	if s.idle.Load() {
+		_ = s.conn.SetReadDeadline(time.Now())
+	}
+}
+
+// serve 处理会话直至 QUIT 或连接断开
+func (s *session) serve() {
+	defer func() {
+		if r := recover(); r != nil {
+			logger.Errorf("smtp session %s panic: %v", s.remoteAddr, r)
+		}
+		_ = s.conn.Close()
+	}()
+
+	conf := s.server.conf
+	s.reply(220, conf.Hostname+" ESMTP ready")
+
+	for {
+		if s.server.shuttingDown.Load() {
+			s.replyError(ErrShuttingDown)
+			return
+		}
+
+		s.idle.Store(true)
+		_ = s.conn.SetReadDeadline(time.Now().Add(time.Duration(conf.ReadTimeoutSeconds) * time.Second))
+		line, err := s.readLine()
+		s.idle.Store(false)
+		if err != nil {
+			if errors.Is(err, errLineTooLong) {
+				s.reply(500, "5.5.2 line too long")
+				continue
+			}
+			if !s.replyTimeout(err) && !errors.Is(err, io.EOF) {
+				logger.Debugf("smtp session %s read error: %v", s.remoteAddr, err)
+			}
+			return
+		}
+
+		verb, arg := parseCommand(line)
+		if quit := s.handle(verb, arg); quit {
+			return
+		}
+		if s.badCommands >= maxBadCommands {
+			s.reply(421, "4.7.0 too many errors")
+			return
+		}
+	}
+}
+
+// handle 处理单条命令，返回 true 表示结束会话
+func (s *session) handle(verb, arg string) bool {
+	switch verb {
+	case "HELO":
+		s.handleHelo(arg, false)
+	case "EHLO":
+		s.handleHelo(arg, true)
+	case "MAIL":
+		s.handleMail(arg)
+	case "RCPT":
+		s.handleRcpt(arg)
+	case "DATA":
+		return s.handleData()
+	case "RSET":
+		s.env = nil
+		s.reply(250, "2.0.0 OK")
+	case "NOOP":
+		s.reply(250, "2.0.0 OK")
+	case "VRFY":
+		s.reply(252, "2.5.0 cannot VRFY user")
+	case "HELP":
+		s.reply(214, "2.0.0 see RFC 5321")
+	case "QUIT":
+		s.reply(221, "2.0.0 bye")
+		return true
+	case "STARTTLS", "AUTH", "EXPN", "TURN", "ETRN", "BDAT":
+		s.reply(502, "5.5.1 command not implemented")
+	default:
+		s.badCommands++
+		s.reply(500, "5.5.2 syntax error, command unrecognized")
+	}
+	return false
+}
+
+func (s *session) handleHelo(arg string, extended bool) {
+	if arg == "" {
+		s.badCommands++
+		s.reply(501, "5.5.4 domain/address argument required")
+		return
+	}
+	s.helo = arg
+	s.env = nil
+
+	hostname := s.server.conf.Hostname
+	if !extended {
+		s.reply(250, hostname)
+		return
+	}
+	lines := []string{
+		hostname + " greets " + arg,
+		"PIPELINING",
+		"SIZE " + strconv.FormatInt(s.server.conf.MaxMessageBytes, 10),
+		"8BITMIME",
+		"ENHANCEDSTATUSCODES",
+	}
+	if s.server.conf.SMTPUTF8 {
+		lines = append(lines, "SMTPUTF8")
+	}
+	s.replyLines(250, lines)
+}
+
+func (s *session) handleMail(arg string) {
+	if s.helo == "" {
+		s.badCommands++
+		s.reply(503, "5.5.1 send HELO/EHLO first")
+		return
+	}
+	if s.env != nil {
+		s.badCommands++
+		s.reply(503, "5.5.1 nested MAIL command")
+		return
+	}
+
+	addr, params, ok := parsePath(arg, "FROM:")
+	if !ok {
+		s.badCommands++
+		s.reply(501, "5.5.4 syntax: MAIL FROM:<address>")
+		return
+	}
+
+	utf8Mail := false
+	var declaredSize int64
+	for _, p := range params {
+		k, v, _ := strings.Cut(p, "=")
+		switch strings.ToUpper(k) {
+		case "SIZE":
+			size, err := strconv.ParseInt(v, 10, 64)
+			if err != nil || size < 0 {
+				s.reply(501, "5.5.4 invalid SIZE parameter")
+				return
+			}
+			if size > s.server.conf.MaxMessageBytes {
+				s.replyError(ErrMessageTooLarge)
+				return
+			}
+			declaredSize = size
+		case "BODY":
+			// 7BIT / 8BITMIME 均接受
+		case "SMTPUTF8":
+			if !s.server.conf.SMTPUTF8 {
+				s.reply(555, "5.5.4 unsupported parameter "+k)
+				return
+			}
+			utf8Mail = true
+		default:
+			s.reply(555, "5.5.4 unsupported parameter "+k)
+			return
+		}
+	}
+
+	s.env = &Envelope{
+		ID:         newEnvelopeID(),
+		RemoteAddr: s.remoteAddr,
+		Helo:       s.helo,
+		MailFrom:   addr,
+		SMTPUTF8:   utf8Mail,
+		Size:       declaredSize,
+	}
+	s.reply(250, "2.1.0 OK")
+}
+
+func (s *session) handleRcpt(arg string) {
+	if s.env == nil {
+		s.badCommands++
+		s.reply(503, "5.5.1 need MAIL command first")
+		return
+	}
+
+	addr, _, ok := parsePath(arg, "TO:")
+	if !ok || addr == "" {
+		s.badCommands++
+		s.reply(501, "5.5.4 syntax: RCPT TO:<address>")
+		return
+	}
+	// RFC 6531：未声明 SMTPUTF8 的事务不能使用国际化地址
+	if !s.env.SMTPUTF8 && !isASCII(addr) {
+		s.reply(553, "5.6.7 non-ASCII address requires SMTPUTF8")
+		return
+	}
+
+	for _, r := range s.env.Recipients {
+		if strings.EqualFold(r.Address, addr) {
+			s.reply(250, "2.1.5 OK")
+			return
+		}
+	}
+	if len(s.env.Recipients) >= s.server.conf.MaxRecipients {
+		s.replyError(ErrTooManyRecipients)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.writeTimeout())
+	defer cancel()
+	rcpt, err := s.server.backend.ResolveRecipient(ctx, addr, s.env.Size)
+	if err != nil {
+		var smtpErr *Error
+		if errors.As(err, &smtpErr) {
+			logger.Infof("smtp %s reject rcpt %s from %s: %v", s.env.ID, addr, s.env.MailFrom, err)
+			s.replyError(smtpErr)
+			return
+		}
+		logger.Errorf("smtp %s resolve rcpt %s error: %v", s.env.ID, addr, err)
+		s.replyError(ErrTemporaryFailure)
+		return
+	}
+
+	s.env.Recipients = append(s.env.Recipients, rcpt)
+	s.reply(250, "2.1.5 OK")
+}
+
+// handleData 接收邮件内容并投递，返回 true 表示结束会话
+func (s *session) handleData() bool {
+	if s.env == nil || len(s.env.Recipients) == 0 {
+		s.badCommands++
+		s.reply(503, "5.5.1 need RCPT command first")
+		return false
+	}
+
+	s.reply(354, "end data with <CR><LF>.<CR><LF>")
+
+	conf := s.server.conf
+	body, err := s.readData(conf.MaxMessageBytes, time.Duration(conf.ReadTimeoutSeconds)*time.Second)
+	env := s.env
+	s.env = nil
+	if err != nil {
+		if errors.Is(err, ErrMessageTooLarge) {
+			logger.Infof("smtp %s message too large from %s", env.ID, env.MailFrom)
+			s.replyError(ErrMessageTooLarge)
+			return false
+		}
+		if !s.replyTimeout(err) {
+			logger.Debugf("smtp %s read data error: %v", env.ID, err)
+		}
+		return true
+	}
+
+	env.ReceivedAt = time.Now()
+	data := append(s.receivedHeader(env), body...)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*s.writeTimeout())
+	defer cancel()
+	if err := s.server.backend.Deliver(ctx, env, data); err != nil {
+		var smtpErr *Error
+		if errors.As(err, &smtpErr) {
+			s.replyError(smtpErr)
+			return false
+		}
+		logger.Errorf("smtp %s deliver error: %v", env.ID, err)
+		s.replyError(ErrTemporaryFailure)
+		return false
+	}
+
+	s.reply(250, "2.0.0 OK queued as "+env.ID)
+	return false
+}
+
+// readData 读取 DATA 内容并去除点填充，超过上限时继续读完再返回 ErrMessageTooLarge
+// 每次读取前延长读超时，持续发送数据的大邮件不会因整体耗时超过 timeout 被中断
+func (s *session) readData(maxBytes int64, timeout time.Duration) ([]byte, error) {
+	var buf bytes.Buffer
+	tooLarge := false
+	for {
+		_ = s.conn.SetReadDeadline(time.Now().Add(timeout))
+		line, err := s.reader.ReadSlice('\n')
+		if err != nil && !errors.Is(err, bufio.ErrBufferFull) {
+			return nil, err
+		}
+		full := errors.Is(err, bufio.ErrBufferFull)
+
+		if !full && (bytes.Equal(line, []byte(".\r\n")) || bytes.Equal(line, []byte(".\n"))) {
+			break
+		}
+		if !full && len(line) > 0 && line[0] == '.' {
+			line = line[1:]
+		}
+		if tooLarge {
+			continue
+		}
+		if int64(buf.Len()+len(line)) > maxBytes {
+			tooLarge = true
+			buf.Reset()
+			continue
+		}
+		buf.Write(line)
+
+		// 超长行分段读取，后续分段不做点填充处理
+		for full {
+			_ = s.conn.SetReadDeadline(time.Now().Add(timeout))
+			line, err = s.reader.ReadSlice('\n')
+			full = errors.Is(err, bufio.ErrBufferFull)
+			if err != nil && !full {
+				return nil, err
+			}
+			if tooLarge {
+				continue
+			}
+			if int64(buf.Len()+len(line)) > maxBytes {
+				tooLarge = true
+				buf.Reset()
+				continue
+			}
+			buf.Write(line)
+		}
+	}
+	if tooLarge {
+		return nil, ErrMessageTooLarge
+	}
+	return buf.Bytes(), nil
+}
+
+// receivedHeader 生成 Received 追踪头
+func (s *session) receivedHeader(env *Envelope) []byte {
+	remote := s.remoteAddr.String()
+	if ip := env.RemoteIP(); ip != nil {
+		remote = "[" + ip.String() + "]"
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "Received: from %s (%s)\r\n", env.Helo, remote)
+	fmt.Fprintf(&b, "\tby %s with ESMTP id %s", s.server.conf.Hostname, env.ID)
+	if len(env.Recipients) == 1 {
+		fmt.Fprintf(&b, "\r\n\tfor <%s>", env.Recipients[0].Address)
+	}
+	fmt.Fprintf(&b, "; %s\r\n", env.ReceivedAt.Format(time.RFC1123Z))
+	return []byte(b.String())
+}
+
+// readLine 读取一行命令，去掉行尾 CRLF
+func (s *session) readLine() (string, error) {
+	line, err := s.reader.ReadSlice('\n')
+	if errors.Is(err, bufio.ErrBufferFull) || len(line) > maxLineLength {
+		// 丢弃该行剩余内容
+		for errors.Is(err, bufio.ErrBufferFull) {
+			_, err = s.reader.ReadSlice('\n')
+		}
+		if err != nil {
+			return "", err
+		}
+		return "", errLineTooLong
+	}
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(line), "\r\n"), nil
+}
+
+// replyTimeout 读超时时回复 421 后由调用方断开连接，停止服务期间回复 ErrShuttingDown，返回是否为读超时
+func (s *session) replyTimeout(err error) bool {
+	var ne net.Error
+	if !errors.As(err, &ne) || !ne.Timeout() {
+		return false
+	}
+	if s.server.shuttingDown.Load() {
+		s.replyError(ErrShuttingDown)
+	} else {
+		s.reply(421, "4.4.2 "+s.server.conf.Hostname+" timeout exceeded")
+	}
+	return true
+}
+
+func (s *session) writeTimeout() time.Duration {
+	return time.Duration(s.server.conf.WriteTimeoutSeconds) * time.Second
+}
+
+func (s *session) reply(code int, text string) {
+	s.replyLines(code, []string{text})
+}
+
+func (s *session) replyError(e *Error) {
+	s.reply(e.Code, e.EnhancedCode+" "+e.Message)
+}
+
+func (s *session) replyLines(code int, lines []string) {
+	_ = s.conn.SetWriteDeadline(time.Now().Add(s.writeTimeout()))
+	for i, line := range lines {
+		sep := "-"
+		if i == len(lines)-1 {
+			sep = " "
+		}
+		fmt.Fprintf(s.writer, "%d%s%s\r\n", code, sep, line)
+	}
+	if err := s.writer.Flush(); err != nil {
+		logger.Debugf("smtp session %s write error: %v", s.remoteAddr, err)
+	}
+}
+
+// parseCommand 拆分命令动词与参数
+func parseCommand(line string) (verb, arg string) {
+	verb, arg, _ = strings.Cut(strings.TrimSpace(line), " ")
+	return strings.ToUpper(verb), strings.TrimSpace(arg)
+}
+
+// parsePath 解析 "FROM:<addr> PARAM=VALUE" 形式的参数
+func parsePath(arg, prefix string) (addr string, params []string, ok bool) {
+	if len(arg) < len(prefix) || !strings.EqualFold(arg[:len(prefix)], prefix) {
+		return "", nil, false
+	}
+	rest := strings.TrimSpace(arg[len(prefix):])
+	if !strings.HasPrefix(rest, "<") {
+		return "", nil, false
+	}
+	end := strings.Index(rest, ">")
+	if end < 0 {
+		return "", nil, false
+	}
+	addr = rest[1:end]
+	// 去掉源路由 @a,@b:user@domain
+	if i := strings.LastIndex(addr, ":"); i >= 0 && strings.HasPrefix(addr, "@") {
+		addr = addr[i+1:]
+	}
+	params = strings.Fields(rest[end+1:])
+	return addr, params, true
+}
+
+// isASCII 判断字符串是否只包含 ASCII 字符
+func isASCII(str string) bool {
+	for i := 0; i < len(str); i++ {
+		if str[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+// newEnvelopeID 生成信封 ID
+func newEnvelopeID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return strings.ToUpper(hex.EncodeToString(b))
+}
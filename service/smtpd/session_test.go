@@ -0,0 +1,343 @@
+package smtpd
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/textproto"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	appconfig "plaud-emails/pkg/config"
+)
+
+// fakeBackend 只接受 known 中的收件人，记录投递的邮件
+type fakeBackend struct {
+	mu        sync.Mutex
+	known     map[string]string
+	delivered [][]byte
+}
+
+func (b *fakeBackend) ResolveRecipient(_ context.Context, address string, _ int64) (*Recipient, error) {
+	switch {
+	case strings.HasPrefix(address, "broken@"):
+		return nil, errors.New("db unavailable")
+	case strings.HasPrefix(address, "off@"):
+		return nil, ErrMailboxDisabled
+	}
+	userID, ok := b.known[strings.ToLower(address)]
+	if !ok {
+		return nil, ErrMailboxNotFound
+	}
+	return &Recipient{Address: address, UserID: userID, DedicatedEmail: address}, nil
+}
+
+func (b *fakeBackend) Deliver(_ context.Context, _ *Envelope, data []byte) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.delivered = append(b.delivered, data)
+	return nil
+}
+
+func testConfig() *appconfig.SMTPConfig {
+	return &appconfig.SMTPConfig{
+		Hostname:            "mx.test",
+		MaxMessageBytes:     1024,
+		MaxRecipients:       2,
+		MaxConnections:      10,
+		ReadTimeoutSeconds:  5,
+		WriteTimeoutSeconds: 5,
+	}
+}
+
+// startServer 在随机端口上启动服务，测试结束时停止
+func startServer(t *testing.T, conf *appconfig.SMTPConfig) (*Server, *fakeBackend, string) {
+	t.Helper()
+	backend := &fakeBackend{known: map[string]string{"jane@myplaud.com": "u1", "bob@myplaud.com": "u2", "amy@myplaud.com": "u3"}}
+	s := NewServer(conf, backend)
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.listener = lis
+	go func() { _ = s.serve(lis) }()
+	t.Cleanup(func() { _ = s.Stop(context.Background()) })
+	return s, backend, lis.Addr().String()
+}
+
+// dial 建立连接并读取问候语
+func dial(t *testing.T, addr string) *textproto.Conn {
+	t.Helper()
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = conn.SetDeadline(time.Now().Add(10 * time.Second))
+	c := textproto.NewConn(conn)
+	t.Cleanup(func() { _ = c.Close() })
+	expect(t, c, 220, "mx.test")
+	return c
+}
+
+// expect 读取一条响应，校验响应码与文本前缀
+func expect(t *testing.T, c *textproto.Conn, code int, prefix string) string {
+	t.Helper()
+	got, msg, err := c.ReadResponse(0)
+	if err != nil {
+		t.Fatalf("read response: %v", err)
+	}
+	if got != code || !strings.HasPrefix(msg, prefix) {
+		t.Fatalf("response = %d %q, want %d %q", got, msg, code, prefix)
+	}
+	return msg
+}
+
+func send(t *testing.T, c *textproto.Conn, code int, prefix, format string, args ...any) string {
+	t.Helper()
+	if err := c.PrintfLine(format, args...); err != nil {
+		t.Fatal(err)
+	}
+	return expect(t, c, code, prefix)
+}
+
+// expectClosed 确认服务端已断开连接
+func expectClosed(t *testing.T, c *textproto.Conn) {
+	t.Helper()
+	if _, err := c.ReadLine(); !errors.Is(err, io.EOF) {
+		t.Fatalf("connection should be closed, got %v", err)
+	}
+}
+
+func TestSessionDeliver(t *testing.T) {
+	_, backend, addr := startServer(t, testConfig())
+	c := dial(t, addr)
+
+	ehlo := send(t, c, 250, "mx.test greets", "EHLO client.example")
+	if !strings.Contains(ehlo, "SIZE 1024") || !strings.Contains(ehlo, "PIPELINING") {
+		t.Fatalf("ehlo extensions = %q", ehlo)
+	}
+	send(t, c, 250, "2.1.0", "MAIL FROM:<alice@example.com>")
+	send(t, c, 250, "2.1.5", "RCPT TO:<Jane@myplaud.com>")
+	send(t, c, 250, "2.1.5", "RCPT TO:<jane@myplaud.com>") // 重复的收件人不占用上限
+	send(t, c, 354, "", "DATA")
+	send(t, c, 250, "2.0.0 OK queued as", "Subject: hi\r\n\r\n..leading dot\r\nbye\r\n.")
+	send(t, c, 221, "2.0.0", "QUIT")
+
+	if len(backend.delivered) != 1 {
+		t.Fatalf("delivered %d messages, want 1", len(backend.delivered))
+	}
+	data := string(backend.delivered[0])
+	if !strings.HasPrefix(data, "Received: from client.example ([127.0.0.1])\r\n\tby mx.test with ESMTP id ") ||
+		!strings.Contains(data, "for <Jane@myplaud.com>") {
+		t.Fatalf("missing Received header: %q", data)
+	}
+	if !strings.HasSuffix(data, "Subject: hi\r\n\r\n.leading dot\r\nbye\r\n") {
+		t.Fatalf("body should be dot-unstuffed: %q", data)
+	}
+}
+
+func TestSessionRcptRejected(t *testing.T) {
+	_, backend, addr := startServer(t, testConfig())
+	c := dial(t, addr)
+
+	send(t, c, 503, "5.5.1", "MAIL FROM:<alice@example.com>") // 需先 HELO
+	send(t, c, 250, "mx.test", "HELO client.example")
+	send(t, c, 503, "5.5.1", "RCPT TO:<jane@myplaud.com>") // 需先 MAIL
+	send(t, c, 250, "2.1.0", "MAIL FROM:<>")
+	send(t, c, 550, "5.1.1", "RCPT TO:<nobody@myplaud.com>")
+	send(t, c, 550, "5.2.1", "RCPT TO:<off@myplaud.com>")
+	send(t, c, 451, "4.3.0", "RCPT TO:<broken@myplaud.com>")
+	send(t, c, 553, "5.6.7", "RCPT TO:<jäne@myplaud.com>")
+	send(t, c, 501, "5.5.4", "RCPT TO:jane@myplaud.com")
+	send(t, c, 503, "5.5.1", "DATA") // 没有有效收件人
+	send(t, c, 250, "2.1.5", "RCPT TO:<jane@myplaud.com>")
+	send(t, c, 250, "2.1.5", "RCPT TO:<bob@myplaud.com>")
+	send(t, c, 452, "4.5.3", "RCPT TO:<amy@myplaud.com>")
+	send(t, c, 250, "2.0.0", "RSET")
+	send(t, c, 503, "5.5.1", "DATA")
+	if len(backend.delivered) != 0 {
+		t.Fatalf("nothing should be delivered, got %d", len(backend.delivered))
+	}
+}
+
+func TestSessionSizeLimit(t *testing.T) {
+	_, backend, addr := startServer(t, testConfig())
+	c := dial(t, addr)
+
+	send(t, c, 250, "mx.test", "EHLO client.example")
+	send(t, c, 552, "5.3.4", "MAIL FROM:<alice@example.com> SIZE=2048")
+	send(t, c, 501, "5.5.4", "MAIL FROM:<alice@example.com> SIZE=-1")
+	send(t, c, 250, "2.1.0", "MAIL FROM:<alice@example.com> SIZE=100 BODY=8BITMIME")
+	send(t, c, 250, "2.1.5", "RCPT TO:<jane@myplaud.com>")
+	send(t, c, 354, "", "DATA")
+	// 超出上限时读完整封邮件再拒绝，会话可以继续使用
+	body := strings.Repeat(strings.Repeat("x", 99)+"\r\n", 20)
+	send(t, c, 552, "5.3.4", "%s.", body)
+	send(t, c, 250, "2.0.0", "NOOP")
+	if len(backend.delivered) != 0 {
+		t.Fatalf("oversized message should not be delivered")
+	}
+
+	send(t, c, 250, "2.1.0", "MAIL FROM:<alice@example.com>")
+	send(t, c, 250, "2.1.5", "RCPT TO:<jane@myplaud.com>")
+	send(t, c, 354, "", "DATA")
+	send(t, c, 250, "2.0.0", "small\r\n.")
+}
+
+func TestSessionReadTimeout(t *testing.T) {
+	conf := testConfig()
+	conf.ReadTimeoutSeconds = 1
+	_, backend, addr := startServer(t, conf)
+
+	// 等待命令超时
+	idle := dial(t, addr)
+	expect(t, idle, 421, "4.4.2")
+	expectClosed(t, idle)
+
+	// DATA 阶段超时同样回复 421 后断开，不投递不完整的邮件
+	c := dial(t, addr)
+	send(t, c, 250, "mx.test", "HELO client.example")
+	send(t, c, 250, "2.1.0", "MAIL FROM:<alice@example.com>")
+	send(t, c, 250, "2.1.5", "RCPT TO:<jane@myplaud.com>")
+	send(t, c, 354, "", "DATA")
+	if err := c.PrintfLine("Subject: partial"); err != nil {
+		t.Fatal(err)
+	}
+	expect(t, c, 421, "4.4.2")
+	expectClosed(t, c)
+	if len(backend.delivered) != 0 {
+		t.Fatal("partial message should not be delivered")
+	}
+}
+
+func TestServerTooManyConnections(t *testing.T) {
+	conf := testConfig()
+	conf.MaxConnections = 1
+	_, _, addr := startServer(t, conf)
+
+	first := dial(t, addr)
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+	second := textproto.NewConn(conn)
+	defer second.Close()
+	expect(t, second, 421, "4.7.0")
+	expectClosed(t, second)
+
+	// 连接结束后恢复接收
+	send(t, first, 221, "2.0.0", "QUIT")
+	expectClosed(t, first)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		conn, err := net.Dial("tcp", addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+		c := textproto.NewConn(conn)
+		code, _, err := c.ReadResponse(0)
+		_ = c.Close()
+		if err == nil && code == 220 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("new connections should be accepted after the session ended, got %d %v", code, err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// pipeListener 通过 net.Pipe 提供连接，对方不读取时写入会一直阻塞
+type pipeListener struct {
+	conns  chan net.Conn
+	closed chan struct{}
+	once   sync.Once
+}
+
+func newPipeListener() *pipeListener {
+	return &pipeListener{conns: make(chan net.Conn), closed: make(chan struct{})}
+}
+
+// dial 返回客户端一侧的连接
+func (l *pipeListener) dial() net.Conn {
+	server, client := net.Pipe()
+	l.conns <- server
+	return client
+}
+
+func (l *pipeListener) Accept() (net.Conn, error) {
+	select {
+	case c := <-l.conns:
+		return c, nil
+	case <-l.closed:
+		return nil, net.ErrClosed
+	}
+}
+
+func (l *pipeListener) Close() error {
+	l.once.Do(func() { close(l.closed) })
+	return nil
+}
+
+func (l *pipeListener) Addr() net.Addr { return &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1)} }
+
+func TestServerRejectDoesNotBlockAccept(t *testing.T) {
+	conf := testConfig()
+	conf.MaxConnections = 1
+	s := NewServer(conf, &fakeBackend{})
+	lis := newPipeListener()
+	s.listener = lis
+	go func() { _ = s.serve(lis) }()
+	t.Cleanup(func() { _ = s.Stop(context.Background()) })
+
+	first := textproto.NewConn(lis.dial())
+	defer first.Close()
+	expect(t, first, 220, "mx.test")
+
+	// 不读取响应的客户端不能阻塞后续连接的接收
+	stalled := lis.dial()
+	defer stalled.Close()
+
+	accepted := make(chan struct{})
+	go func() {
+		c := textproto.NewConn(lis.dial())
+		defer c.Close()
+		if code, _, err := c.ReadResponse(0); err == nil && code == 421 {
+			close(accepted)
+		}
+	}()
+	select {
+	case <-accepted:
+	case <-time.After(time.Second):
+		t.Fatal("a stalled rejected client blocked the accept loop")
+	}
+}
+
+func TestServerShutdown(t *testing.T) {
+	s, _, addr := startServer(t, testConfig())
+	c := dial(t, addr)
+	send(t, c, 250, "mx.test", "EHLO client.example")
+
+	stopped := make(chan struct{})
+	go func() {
+		_ = s.Stop(context.Background())
+		close(stopped)
+	}()
+	// 空闲会话被唤醒并收到 421
+	expect(t, c, 421, "4.3.2")
+	expectClosed(t, c)
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("stop should return once idle sessions have ended")
+	}
+	if conn, err := net.Dial("tcp", addr); err == nil {
+		_ = conn.Close()
+		t.Fatal("listener should be closed after stop")
+	}
+}
@@ -0,0 +1,86 @@
+package spam
+
+import (
+	"context"
+	"fmt"
+
+	"plaud-emails/service/mailauth"
+)
+
+// AuthChecker 根据发信方的 SPF、DKIM 与 DMARC 检查结果评分
+type AuthChecker struct {
+	verifier *mailauth.Verifier
+}
+
+// NewAuthChecker 创建 AuthChecker，resolver 为 nil 时使用系统 DNS
+func NewAuthChecker(resolver mailauth.Resolver) *AuthChecker {
+	return &AuthChecker{verifier: mailauth.NewVerifier(resolver)}
+}
+
+// Name 实现 Checker
+func (c *AuthChecker) Name() string { return "auth" }
+
+// Check 实现 Checker，优先使用收信时已完成的检查结果
+func (c *AuthChecker) Check(ctx context.Context, in *Input) ([]*Hit, error) {
+	if in.Auth != nil {
+		return authHits(in.Auth), nil
+	}
+	res := c.verifier.Verify(ctx, &mailauth.Input{
+		RemoteIP: in.RemoteIP,
+		Helo:     in.Helo,
+		MailFrom: in.MailFrom,
+		Raw:      in.Raw,
+	})
+	return authHits(res), nil
+}
+
+// authHits 将认证结果转换为评分规则，temperror 与 permerror（如不支持的签名算法）不计分
+func authHits(res *mailauth.Results) []*Hit {
+	var hits []*Hit
+	if spf := res.SPF; spf != nil {
+		switch spf.Result {
+		case mailauth.ResultFail:
+			hits = append(hits, &Hit{Rule: "SPF_FAIL", Score: 1.5, Description: fmt.Sprintf("SPF fail for %s", spf.Domain)})
+		case mailauth.ResultSoftFail:
+			hits = append(hits, &Hit{Rule: "SPF_SOFTFAIL", Score: 0.7, Description: fmt.Sprintf("SPF softfail for %s", spf.Domain)})
+		case mailauth.ResultPass:
+			hits = append(hits, &Hit{Rule: "SPF_PASS", Score: -0.1, Description: fmt.Sprintf("SPF pass for %s", spf.Domain)})
+		}
+	}
+
+	var valid, invalid string
+	for _, d := range res.DKIM {
+		switch d.Result {
+		case mailauth.ResultPass:
+			if valid == "" {
+				valid = d.Domain
+			}
+		case mailauth.ResultFail:
+			if invalid == "" {
+				invalid = d.Domain
+			}
+		}
+	}
+	if valid != "" {
+		hits = append(hits, &Hit{Rule: "DKIM_VALID", Score: -0.2, Description: "valid DKIM signature from " + valid})
+	} else if invalid != "" {
+		hits = append(hits, &Hit{Rule: "DKIM_INVALID", Score: 0.5, Description: "invalid DKIM signature from " + invalid})
+	}
+
+	if d := res.DMARC; d != nil {
+		switch d.Result {
+		case mailauth.ResultPass:
+			hits = append(hits, &Hit{Rule: "DMARC_PASS", Score: -0.5, Description: "DMARC pass for " + d.Domain})
+		case mailauth.ResultFail:
+			switch d.Policy {
+			case mailauth.DMARCPolicyReject:
+				hits = append(hits, &Hit{Rule: "DMARC_REJECT", Score: 5, Description: fmt.Sprintf("DMARC fail for %s with p=reject", d.Domain)})
+			case mailauth.DMARCPolicyQuarantine:
+				hits = append(hits, &Hit{Rule: "DMARC_QUARANTINE", Score: 3.5, Description: fmt.Sprintf("DMARC fail for %s with p=quarantine", d.Domain)})
+			default:
+				hits = append(hits, &Hit{Rule: "DMARC_FAIL", Score: 1, Description: fmt.Sprintf("DMARC fail for %s with p=none", d.Domain)})
+			}
+		}
+	}
+	return hits
+}
@@ -0,0 +1,67 @@
+package spam
+
+import (
+	"context"
+	"net"
+	"slices"
+	"testing"
+
+	"plaud-emails/service/mailauth"
+)
+
+// bankResolver bank.example 只允许 192.0.2.0/24 发信，DMARC 策略为 reject
+func bankResolver() *mailauth.StaticResolver {
+	return &mailauth.StaticResolver{TXT: map[string][]string{
+		"bank.example":        {"v=spf1 ip4:192.0.2.0/24 -all"},
+		"_dmarc.bank.example": {"v=DMARC1; p=reject"},
+	}}
+}
+
+const bankMessage = "From: Bank <notice@bank.example>\r\nTo: a@myplaud.com\r\nSubject: notice\r\n\r\nhello\r\n"
+
+func TestAuthCheckerResolvesRecords(t *testing.T) {
+	c := NewAuthChecker(bankResolver())
+	cases := []struct {
+		name string
+		ip   string
+		want []string
+	}{
+		{"authorized sender", "192.0.2.10", []string{"SPF_PASS", "DMARC_PASS"}},
+		{"spoofed sender", "203.0.113.9", []string{"SPF_FAIL", "DMARC_REJECT"}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			hits, err := c.Check(context.Background(), &Input{
+				RemoteIP: net.ParseIP(tc.ip),
+				Helo:     "mail.bank.example",
+				MailFrom: "bounce@bank.example",
+				Raw:      []byte(bankMessage),
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := rules(hits); !slices.Equal(got, tc.want) {
+				t.Fatalf("rules = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestAuthCheckerPrefersInboundResults(t *testing.T) {
+	// 没有任何 DNS 记录，命中的规则只能来自收信时的检查结果
+	c := NewAuthChecker(&mailauth.StaticResolver{})
+	hits, _ := c.Check(context.Background(), &Input{
+		Raw: []byte(bankMessage),
+		Auth: &mailauth.Results{
+			SPF: &mailauth.SPFResult{Result: mailauth.ResultSoftFail, Domain: "bank.example"},
+			DKIM: []*mailauth.DKIMResult{
+				{Result: mailauth.ResultFail, Domain: "bank.example"},
+				{Result: mailauth.ResultPermError, Domain: "other.example"},
+			},
+			DMARC: &mailauth.DMARCResult{Result: mailauth.ResultFail, Domain: "bank.example", Policy: mailauth.DMARCPolicyQuarantine},
+		},
+	})
+	if got, want := rules(hits), []string{"SPF_SOFTFAIL", "DKIM_INVALID", "DMARC_QUARANTINE"}; !slices.Equal(got, want) {
+		t.Fatalf("rules = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,239 @@
+package spam
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+
+	"plaud-emails/dao"
+	datamodel "plaud-emails/data/model"
+	"plaud-emails/service/mimeparse"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// maxTokens 每封邮件最多提取的不同 token 数
+	maxTokens = 1000
+	// maxBodyBytes 正文参与分词的最大字节数
+	maxBodyBytes = 64 << 10
+	// minWordLen、maxWordLen 参与分词的拉丁字母单词长度范围
+	minWordLen = 3
+	maxWordLen = 40
+
+	// bayesStrength、bayesPrior 未充分训练的 token 向 0.5 收缩的强度与先验概率（Robinson）
+	bayesStrength = 1.0
+	bayesPrior    = 0.5
+	// bayesMinDeviation 概率与 0.5 的差小于该值的 token 不参与计算
+	bayesMinDeviation = 0.1
+	// bayesMaxClues 参与计算的 token 数上限，取偏离 0.5 最多的 token
+	bayesMaxClues = 150
+)
+
+// bayesBands 贝叶斯概率对应的评分，概率不在任何区间内时不计分
+var bayesBands = []struct {
+	rule     string
+	min, max float64
+	score    float64
+}{
+	{"BAYES_99", 0.99, 1.01, 3.5},
+	{"BAYES_95", 0.95, 0.99, 2.5},
+	{"BAYES_80", 0.80, 0.95, 1.5},
+	{"BAYES_20", 0.05, 0.20, -0.5},
+	{"BAYES_05", 0.01, 0.05, -1.5},
+	{"BAYES_00", -0.01, 0.01, -2.5},
+}
+
+// tokenCounts 查询用户训练过的 token 计数，由 *dao.SpamTokenDao 实现
+type tokenCounts interface {
+	ListByHashes(ctx context.Context, userID string, hashes []uint64) ([]*datamodel.SpamToken, error)
+}
+
+// trainedStats 查询用户已训练的邮件数，由 *dao.SpamStatsDao 实现
+type trainedStats interface {
+	GetByUserID(ctx context.Context, userID string) (*datamodel.SpamStats, error)
+}
+
+var (
+	_ tokenCounts  = (*dao.SpamTokenDao)(nil)
+	_ trainedStats = (*dao.SpamStatsDao)(nil)
+)
+
+// BayesChecker 按用户训练的词频计算邮件是垃圾邮件的概率
+// 用户标记的垃圾邮件与正常邮件都达到 minMessages 封之前不计分
+type BayesChecker struct {
+	tokenDao    tokenCounts
+	statsDao    trainedStats
+	minMessages int64
+}
+
+// NewBayesChecker 创建 BayesChecker
+func NewBayesChecker(db *gorm.DB, minMessages int) *BayesChecker {
+	return &BayesChecker{
+		tokenDao:    dao.NewSpamTokenDao(db),
+		statsDao:    dao.NewSpamStatsDao(db),
+		minMessages: int64(minMessages),
+	}
+}
+
+// Name 实现 Checker
+func (c *BayesChecker) Name() string { return "bayes" }
+
+// Check 实现 Checker
+func (c *BayesChecker) Check(ctx context.Context, in *Input) ([]*Hit, error) {
+	if in.Parsed == nil {
+		return nil, nil
+	}
+	prob, ok, err := c.Classify(ctx, in.UserID, Tokenize(in.Parsed))
+	if err != nil || !ok {
+		return nil, err
+	}
+	for _, b := range bayesBands {
+		if prob >= b.min && prob < b.max {
+			return []*Hit{{Rule: b.rule, Score: b.score, Description: fmt.Sprintf("Bayesian spam probability is %.1f%%", prob*100)}}, nil
+		}
+	}
+	return nil, nil
+}
+
+// Classify 计算一组 token 属于垃圾邮件的概率，训练数据不足或没有显著 token 时 ok 为 false
+// 单个 token 的概率使用 Robinson 方法，合并使用 Fisher 卡方检验（与 SpamBayes 相同）
+func (c *BayesChecker) Classify(ctx context.Context, userID string, hashes []uint64) (prob float64, ok bool, err error) {
+	stats, err := c.statsDao.GetByUserID(ctx, userID)
+	if err != nil || stats == nil || stats.SpamMessages < c.minMessages || stats.HamMessages < c.minMessages {
+		return 0, false, err
+	}
+	tokens, err := c.tokenDao.ListByHashes(ctx, userID, hashes)
+	if err != nil {
+		return 0, false, err
+	}
+
+	nSpam, nHam := float64(stats.SpamMessages), float64(stats.HamMessages)
+	clues := make([]float64, 0, len(tokens))
+	for _, t := range tokens {
+		spamRatio := math.Min(float64(t.SpamCount)/nSpam, 1)
+		hamRatio := math.Min(float64(t.HamCount)/nHam, 1)
+		if spamRatio+hamRatio == 0 {
+			continue
+		}
+		p := spamRatio / (spamRatio + hamRatio)
+		n := float64(t.SpamCount + t.HamCount)
+		f := (bayesStrength*bayesPrior + n*p) / (bayesStrength + n)
+		if math.Abs(f-0.5) >= bayesMinDeviation {
+			clues = append(clues, f)
+		}
+	}
+	if len(clues) == 0 {
+		return 0, false, nil
+	}
+	sort.Slice(clues, func(i, j int) bool { return math.Abs(clues[i]-0.5) > math.Abs(clues[j]-0.5) })
+	if len(clues) > bayesMaxClues {
+		clues = clues[:bayesMaxClues]
+	}
+
+	var lnSpam, lnHam float64
+	for _, f := range clues {
+		lnSpam += math.Log(1 - f)
+		lnHam += math.Log(f)
+	}
+	df := 2 * len(clues)
+	s := 1 - chi2Q(-2*lnSpam, df)
+	h := 1 - chi2Q(-2*lnHam, df)
+	return (s - h + 1) / 2, true, nil
+}
+
+// chi2Q 自由度为偶数 df 的卡方分布的上尾概率
+func chi2Q(x2 float64, df int) float64 {
+	m := x2 / 2
+	term := math.Exp(-m)
+	sum := term
+	for i := 1; i < df/2; i++ {
+		term *= m / float64(i)
+		sum += term
+	}
+	return math.Min(sum, 1)
+}
+
+// Tokenize 提取邮件的 token 哈希：主题与正文的单词、中日韩文字的二元组、发件域名与链接域名
+func Tokenize(p *mimeparse.Message) []uint64 {
+	seen := make(map[uint64]bool)
+	var hashes []uint64
+	add := func(token string) {
+		if len(hashes) >= maxTokens {
+			return
+		}
+		h := fnv.New64a()
+		_, _ = h.Write([]byte(token))
+		if sum := h.Sum64(); !seen[sum] {
+			seen[sum] = true
+			hashes = append(hashes, sum)
+		}
+	}
+
+	for _, w := range words(p.Subject) {
+		add("subject:" + w)
+	}
+	if len(p.From) > 0 {
+		if d := addressDomain(p.From[0].Address); d != "" {
+			add("from:" + d)
+		}
+	}
+	body := p.Text
+	if body == "" && p.HTML != "" {
+		body = mimeparse.HTMLToText(p.HTML)
+	}
+	if len(body) > maxBodyBytes {
+		body = body[:maxBodyBytes]
+	}
+	for _, l := range extractLinks(body, p.HTML) {
+		if host := normalizeHost(l.URL.Hostname()); host != "" {
+			add("url:" + host)
+		}
+	}
+	for _, w := range words(body) {
+		add(w)
+	}
+	return hashes
+}
+
+// words 将文本切分为小写单词，中日韩文字按相邻两字切分
+func words(text string) []string {
+	var out []string
+	var word []rune
+	var han []rune
+	flushWord := func() {
+		if len(word) >= minWordLen && len(word) <= maxWordLen {
+			out = append(out, strings.ToLower(string(word)))
+		}
+		word = word[:0]
+	}
+	flushHan := func() {
+		if len(han) == 1 {
+			out = append(out, string(han))
+		}
+		for i := 0; i+1 < len(han); i++ {
+			out = append(out, string(han[i:i+2]))
+		}
+		han = han[:0]
+	}
+	for _, r := range text {
+		switch {
+		case unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul):
+			flushWord()
+			han = append(han, r)
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || r == '$' || r == '\'' || r == '-':
+			flushHan()
+			word = append(word, r)
+		default:
+			flushWord()
+			flushHan()
+		}
+	}
+	flushWord()
+	flushHan()
+	return out
+}
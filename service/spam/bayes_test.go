@@ -0,0 +1,186 @@
+package spam
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"slices"
+	"testing"
+
+	datamodel "plaud-emails/data/model"
+	"plaud-emails/service/mimeparse"
+)
+
+// fakeCorpus 按用户保存训练数据，与 SpamService.Train 写入 token 与统计表的方式相同
+type fakeCorpus struct {
+	tokens map[string]map[uint64]*datamodel.SpamToken
+	stats  map[string]*datamodel.SpamStats
+}
+
+func newFakeCorpus() *fakeCorpus {
+	return &fakeCorpus{tokens: make(map[string]map[uint64]*datamodel.SpamToken), stats: make(map[string]*datamodel.SpamStats)}
+}
+
+func (f *fakeCorpus) ListByHashes(_ context.Context, userID string, hashes []uint64) ([]*datamodel.SpamToken, error) {
+	var tokens []*datamodel.SpamToken
+	for _, h := range hashes {
+		if t := f.tokens[userID][h]; t != nil {
+			tokens = append(tokens, t)
+		}
+	}
+	return tokens, nil
+}
+
+func (f *fakeCorpus) GetByUserID(_ context.Context, userID string) (*datamodel.SpamStats, error) {
+	return f.stats[userID], nil
+}
+
+// train 将一封邮件训练为垃圾邮件或正常邮件
+func (f *fakeCorpus) train(userID string, p *mimeparse.Message, spam bool) {
+	if f.tokens[userID] == nil {
+		f.tokens[userID] = make(map[uint64]*datamodel.SpamToken)
+		f.stats[userID] = &datamodel.SpamStats{UserID: userID}
+	}
+	for _, h := range Tokenize(p) {
+		t := f.tokens[userID][h]
+		if t == nil {
+			t = &datamodel.SpamToken{UserID: userID, TokenHash: h}
+			f.tokens[userID][h] = t
+		}
+		if spam {
+			t.SpamCount++
+		} else {
+			t.HamCount++
+		}
+	}
+	if spam {
+		f.stats[userID].SpamMessages++
+	} else {
+		f.stats[userID].HamMessages++
+	}
+}
+
+// tokenHash 与 Tokenize 相同的 token 哈希
+func tokenHash(token string) uint64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(token))
+	return h.Sum64()
+}
+
+func spamMessage(i int) *mimeparse.Message {
+	return &mimeparse.Message{
+		Subject: fmt.Sprintf("Cheap pills discount %d", i),
+		From:    []*mimeparse.Address{{Address: "deals@pharma.example"}},
+		Text:    "Buy cheap pills now, limited discount offer, visit https://pharma.example/buy",
+	}
+}
+
+func hamMessage(i int) *mimeparse.Message {
+	return &mimeparse.Message{
+		Subject: fmt.Sprintf("Project meeting notes %d", i),
+		From:    []*mimeparse.Address{{Address: "colleague@work.example"}},
+		Text:    "Attached the meeting notes for the quarterly project review, agenda items below",
+	}
+}
+
+func newTrainedChecker(minMessages int) (*BayesChecker, *fakeCorpus) {
+	corpus := newFakeCorpus()
+	for i := 0; i < 5; i++ {
+		corpus.train("u1", spamMessage(i), true)
+		corpus.train("u1", hamMessage(i), false)
+	}
+	return &BayesChecker{tokenDao: corpus, statsDao: corpus, minMessages: int64(minMessages)}, corpus
+}
+
+func TestTokenize(t *testing.T) {
+	p := &mimeparse.Message{
+		Subject: "Re: Hello World",
+		From:    []*mimeparse.Address{{Address: "a@Example.COM"}},
+		Text:    "hello hello an 会议纪要 https://Docs.Example.org/x",
+	}
+	hashes := Tokenize(p)
+	if !slices.Equal(hashes, Tokenize(p)) {
+		t.Fatal("tokenize should be deterministic")
+	}
+	want := map[string]bool{
+		"subject:hello": true, "subject:world": true, "from:example.com": true, "url:docs.example.org": true,
+		"hello": true, "会议": true, "议纪": true, "纪要": true,
+	}
+	got := make(map[uint64]bool)
+	for _, h := range hashes {
+		if got[h] {
+			t.Fatal("tokens should be deduplicated")
+		}
+		got[h] = true
+	}
+	for token := range want {
+		if !got[tokenHash(token)] {
+			t.Errorf("missing token %q", token)
+		}
+	}
+	if got[tokenHash("an")] {
+		t.Error("words shorter than minWordLen should be skipped")
+	}
+	if got := words("Re: Hello World"); !slices.Equal(got, []string{"hello", "world"}) {
+		t.Errorf("words = %v", got)
+	}
+}
+
+func TestBayesClassify(t *testing.T) {
+	c, _ := newTrainedChecker(3)
+	ctx := context.Background()
+
+	prob, ok, err := c.Classify(ctx, "u1", Tokenize(spamMessage(99)))
+	if err != nil || !ok || prob < 0.95 {
+		t.Fatalf("spam prob = %v ok=%v err=%v, want > 0.95", prob, ok, err)
+	}
+	prob, ok, err = c.Classify(ctx, "u1", Tokenize(hamMessage(99)))
+	if err != nil || !ok || prob > 0.05 {
+		t.Fatalf("ham prob = %v ok=%v err=%v, want < 0.05", prob, ok, err)
+	}
+	if _, ok, _ := c.Classify(ctx, "u1", Tokenize(&mimeparse.Message{Text: "zebra xylophone quokka"})); ok {
+		t.Fatal("unseen tokens should not produce a verdict")
+	}
+}
+
+func TestBayesPerUser(t *testing.T) {
+	c, corpus := newTrainedChecker(3)
+	ctx := context.Background()
+
+	// 其他用户的训练数据不影响 u2
+	if _, ok, _ := c.Classify(ctx, "u2", Tokenize(spamMessage(99))); ok {
+		t.Fatal("untrained user should not be classified")
+	}
+	// u2 训练的邮件数不足 minMessages 时不计分
+	corpus.train("u2", spamMessage(1), true)
+	corpus.train("u2", hamMessage(1), false)
+	if _, ok, _ := c.Classify(ctx, "u2", Tokenize(spamMessage(99))); ok {
+		t.Fatal("user below minMessages should not be classified")
+	}
+	// u2 把 u1 眼中的垃圾邮件训练为正常邮件，两人的判定相反
+	for i := 0; i < 5; i++ {
+		corpus.train("u2", spamMessage(i), false)
+		corpus.train("u2", hamMessage(i), true)
+	}
+	prob, ok, _ := c.Classify(ctx, "u2", Tokenize(spamMessage(99)))
+	if !ok || prob > 0.05 {
+		t.Fatalf("u2 prob = %v ok=%v, want < 0.05", prob, ok)
+	}
+}
+
+func TestBayesCheckBands(t *testing.T) {
+	c, _ := newTrainedChecker(3)
+	ctx := context.Background()
+
+	hits, err := c.Check(ctx, &Input{UserID: "u1", Parsed: spamMessage(99)})
+	if err != nil || len(hits) != 1 || hits[0].Rule != "BAYES_99" || hits[0].Score <= 0 {
+		t.Fatalf("spam hits = %v, err %v", rules(hits), err)
+	}
+	hits, err = c.Check(ctx, &Input{UserID: "u1", Parsed: hamMessage(99)})
+	if err != nil || len(hits) != 1 || hits[0].Rule != "BAYES_00" || hits[0].Score >= 0 {
+		t.Fatalf("ham hits = %v, err %v", rules(hits), err)
+	}
+	if hits, _ := c.Check(ctx, &Input{UserID: "u1"}); hits != nil {
+		t.Fatalf("unparsed message should not be scored: %v", rules(hits))
+	}
+}
@@ -0,0 +1,146 @@
+package spam
+
+import (
+	"context"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+
+	"plaud-emails/service/mailauth"
+	"plaud-emails/service/mimeparse"
+)
+
+// futureDateSkew Date 头晚于当前时间超过该值视为伪造
+const futureDateSkew = 24 * time.Hour
+
+var (
+	// executableExts 可执行或可被系统直接运行的附件扩展名
+	executableExts = map[string]bool{
+		".exe": true, ".scr": true, ".bat": true, ".cmd": true, ".com": true, ".pif": true,
+		".js": true, ".jse": true, ".vbs": true, ".vbe": true, ".wsf": true, ".hta": true,
+		".jar": true, ".msi": true, ".ps1": true, ".lnk": true, ".cpl": true, ".iso": true,
+	}
+	// htmlExts 常用于钓鱼登录页的 HTML 附件扩展名
+	htmlExts = map[string]bool{".html": true, ".htm": true, ".shtml": true, ".xhtml": true}
+
+	// nameAddrRe 显示名中出现的邮件地址
+	nameAddrRe = regexp.MustCompile(`[A-Za-z0-9._%+-]+@([A-Za-z0-9-]+(?:\.[A-Za-z0-9-]+)+)`)
+)
+
+// HeaderChecker 检查邮件头与结构上的常见垃圾邮件特征
+type HeaderChecker struct {
+	now func() time.Time
+}
+
+// NewHeaderChecker 创建 HeaderChecker
+func NewHeaderChecker() *HeaderChecker {
+	return &HeaderChecker{now: time.Now}
+}
+
+// Name 实现 Checker
+func (c *HeaderChecker) Name() string { return "header" }
+
+// Check 实现 Checker
+func (c *HeaderChecker) Check(ctx context.Context, in *Input) ([]*Hit, error) {
+	p := in.Parsed
+	if p == nil {
+		return []*Hit{{Rule: "UNPARSABLE_MESSAGE", Score: 1, Description: "message could not be parsed"}}, nil
+	}
+
+	var hits []*Hit
+	if len(p.From) == 0 {
+		hits = append(hits, &Hit{Rule: "MISSING_FROM", Score: 2, Description: "missing From header"})
+	}
+	switch {
+	case p.Date == nil:
+		hits = append(hits, &Hit{Rule: "MISSING_DATE", Score: 1, Description: "missing Date header"})
+	case p.Date.After(c.now().Add(futureDateSkew)):
+		hits = append(hits, &Hit{Rule: "DATE_IN_FUTURE", Score: 1.5, Description: "Date header is more than a day in the future"})
+	}
+	if p.MessageID == "" {
+		hits = append(hits, &Hit{Rule: "MISSING_MESSAGE_ID", Score: 1, Description: "missing Message-ID header"})
+	}
+	if len(p.To) == 0 && len(p.Cc) == 0 {
+		hits = append(hits, &Hit{Rule: "MISSING_TO", Score: 0.5, Description: "no recipients in To or Cc"})
+	}
+
+	if len(p.From) > 0 {
+		from := p.From[0]
+		fromDomain := addressDomain(from.Address)
+		if d := nameAddrDomain(from.Name); d != "" && fromDomain != "" &&
+			mailauth.OrganizationalDomain(d) != mailauth.OrganizationalDomain(fromDomain) {
+			hits = append(hits, &Hit{Rule: "FROM_NAME_SPOOF", Score: 2.5, Description: "From display name shows an address at " + d + " but was sent from " + fromDomain})
+		}
+		if len(p.ReplyTo) > 0 && fromDomain != "" {
+			if d := addressDomain(p.ReplyTo[0].Address); d != "" &&
+				mailauth.OrganizationalDomain(d) != mailauth.OrganizationalDomain(fromDomain) {
+				hits = append(hits, &Hit{Rule: "REPLYTO_DIFFERENT_DOMAIN", Score: 0.8, Description: "Reply-To domain " + d + " differs from From domain " + fromDomain})
+			}
+		}
+	}
+
+	if isAllCaps(p.Subject) {
+		hits = append(hits, &Hit{Rule: "SUBJECT_ALL_CAPS", Score: 1, Description: "subject is all capitals"})
+	}
+	if strings.Contains(p.Subject, "!!!") || strings.Contains(p.Subject, "$$$") || strings.Count(p.Subject, "!") >= 4 {
+		hits = append(hits, &Hit{Rule: "SUBJECT_EXCESS_PUNCT", Score: 0.5, Description: "subject has excessive punctuation"})
+	}
+
+	if name := findAttachment(p, executableExts); name != "" {
+		hits = append(hits, &Hit{Rule: "EXECUTABLE_ATTACHMENT", Score: 3, Description: "executable attachment " + name})
+	}
+	if name := findAttachment(p, htmlExts); name != "" {
+		hits = append(hits, &Hit{Rule: "HTML_ATTACHMENT", Score: 1.5, Description: "HTML attachment " + name})
+	}
+	return hits, nil
+}
+
+// addressDomain 返回邮件地址的小写域名
+func addressDomain(addr string) string {
+	at := strings.LastIndex(addr, "@")
+	if at < 0 {
+		return ""
+	}
+	return strings.TrimSuffix(strings.ToLower(addr[at+1:]), ".")
+}
+
+// nameAddrDomain 返回显示名中伪装的邮件地址的域名
+func nameAddrDomain(name string) string {
+	m := nameAddrRe.FindStringSubmatch(name)
+	if m == nil {
+		return ""
+	}
+	return strings.ToLower(m[1])
+}
+
+// isAllCaps 主题至少包含 10 个字母且全部为大写
+func isAllCaps(subject string) bool {
+	letters := 0
+	for _, r := range subject {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		if !unicode.IsUpper(r) {
+			return false
+		}
+		letters++
+	}
+	return letters >= 10
+}
+
+// findAttachment 返回第一个扩展名在 exts 中的附件名，包括作为附件转发的邮件中的附件
+func findAttachment(p *mimeparse.Message, exts map[string]bool) string {
+	for _, a := range p.Attachments {
+		if exts[strings.ToLower(path.Ext(strings.TrimSpace(a.Filename)))] {
+			return a.Filename
+		}
+	}
+	for _, e := range p.Embedded {
+		if name := findAttachment(e, exts); name != "" {
+			return name
+		}
+	}
+	return ""
+}
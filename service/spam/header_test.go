@@ -0,0 +1,106 @@
+package spam
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"plaud-emails/service/mimeparse"
+)
+
+// parse 解析测试邮件，raw 使用 LF 换行
+func parse(t *testing.T, raw string) *mimeparse.Message {
+	t.Helper()
+	p, err := mimeparse.Parse([]byte(raw))
+	if err != nil {
+		t.Fatalf("parse message: %v", err)
+	}
+	return p
+}
+
+// rules 返回命中的规则名
+func rules(hits []*Hit) []string {
+	names := make([]string, 0, len(hits))
+	for _, h := range hits {
+		names = append(names, h.Rule)
+	}
+	return names
+}
+
+func newTestHeaderChecker() *HeaderChecker {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	return &HeaderChecker{now: func() time.Time { return now }}
+}
+
+func TestHeaderCheckerCleanMessage(t *testing.T) {
+	p := parse(t, "From: Alice <alice@example.com>\n"+
+		"To: bob@myplaud.com\n"+
+		"Reply-To: alice@mail.example.com\n"+
+		"Subject: Lunch tomorrow?\n"+
+		"Date: Sun, 1 Mar 2026 10:00:00 +0000\n"+
+		"Message-ID: <m1@example.com>\n\n"+
+		"See you at noon.\n")
+
+	hits, err := newTestHeaderChecker().Check(context.Background(), &Input{Parsed: p})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hits) != 0 {
+		t.Fatalf("clean message hit %v", rules(hits))
+	}
+}
+
+func TestHeaderCheckerRules(t *testing.T) {
+	cases := []struct {
+		name string
+		raw  string
+		want []string
+	}{
+		{
+			name: "missing headers",
+			raw:  "Subject: hello\n\nbody\n",
+			want: []string{"MISSING_FROM", "MISSING_DATE", "MISSING_MESSAGE_ID", "MISSING_TO"},
+		},
+		{
+			name: "future date and shouting subject",
+			raw: "From: a@example.com\nTo: b@myplaud.com\nMessage-ID: <x@example.com>\n" +
+				"Date: Fri, 6 Mar 2026 10:00:00 +0000\nSubject: YOU HAVE WON A PRIZE!!!\n\nbody\n",
+			want: []string{"DATE_IN_FUTURE", "SUBJECT_ALL_CAPS", "SUBJECT_EXCESS_PUNCT"},
+		},
+		{
+			name: "display name spoof and foreign reply-to",
+			raw: "From: \"support@paypal.com\" <support@evil.example>\nReply-To: collect@other.example\n" +
+				"To: b@myplaud.com\nMessage-ID: <x@evil.example>\nDate: Sun, 1 Mar 2026 10:00:00 +0000\nSubject: Account\n\nbody\n",
+			want: []string{"FROM_NAME_SPOOF", "REPLYTO_DIFFERENT_DOMAIN"},
+		},
+		{
+			name: "dangerous attachments",
+			raw: "From: a@example.com\nTo: b@myplaud.com\nMessage-ID: <x@example.com>\nDate: Sun, 1 Mar 2026 10:00:00 +0000\n" +
+				"Subject: Invoice\nMIME-Version: 1.0\nContent-Type: multipart/mixed; boundary=b\n\n" +
+				"--b\nContent-Type: text/plain\n\nsee attached\n" +
+				"--b\nContent-Type: application/octet-stream\nContent-Disposition: attachment; filename=\"invoice.PDF.exe\"\n\nMZ\n" +
+				"--b\nContent-Type: text/html\nContent-Disposition: attachment; filename=\"login.htm\"\n\n<form></form>\n" +
+				"--b--\n",
+			want: []string{"EXECUTABLE_ATTACHMENT", "HTML_ATTACHMENT"},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			hits, err := newTestHeaderChecker().Check(context.Background(), &Input{Parsed: parse(t, c.raw)})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := rules(hits); !slices.Equal(got, c.want) {
+				t.Fatalf("rules = %v, want %v", got, c.want)
+			}
+		})
+	}
+}
+
+func TestHeaderCheckerUnparsable(t *testing.T) {
+	hits, _ := newTestHeaderChecker().Check(context.Background(), &Input{})
+	if got := rules(hits); !slices.Equal(got, []string{"UNPARSABLE_MESSAGE"}) {
+		t.Fatalf("rules = %v, want [UNPARSABLE_MESSAGE]", got)
+	}
+}
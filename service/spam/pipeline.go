@@ -0,0 +1,84 @@
+package spam
+
+import (
+	"context"
+	"math"
+	"net"
+
+	"plaud-emails/service/mailauth"
+	"plaud-emails/service/mimeparse"
+
+	"github.com/Plaud-AI/plaud-go-scaffold/pkg/logger"
+)
+
+// Input 一封待评分的邮件
+type Input struct {
+	UserID string
+	Source string
+	// RemoteIP 发信方 IP，外部邮箱同步的邮件为 nil
+	RemoteIP net.IP
+	Helo     string
+	MailFrom string
+	Raw      []byte
+	// Parsed 邮件无法解析时为 nil
+	Parsed *mimeparse.Message
+	// Auth 收信时已完成的认证检查结果，为 nil 时由 AuthChecker 重新检查
+	Auth *mailauth.Results
+}
+
+// Hit 命中的一条规则，Score 为正时增加垃圾邮件可能性，为负时降低
+type Hit struct {
+	Rule        string  `json:"rule"`
+	Score       float64 `json:"score"`
+	Description string  `json:"description"`
+}
+
+// Checker 评分规则集，每个 Checker 独立检查邮件并返回命中的规则
+type Checker interface {
+	Name() string
+	Check(ctx context.Context, in *Input) ([]*Hit, error)
+}
+
+// Report 评分报告，序列化后保存在邮件记录中
+type Report struct {
+	Score     float64 `json:"score"`
+	Threshold float64 `json:"threshold"`
+	Spam      bool    `json:"spam"`
+	Hits      []*Hit  `json:"rules"`
+}
+
+// Pipeline 依次执行各 Checker 并累加得分
+type Pipeline struct {
+	checkers  []Checker
+	threshold float64
+}
+
+// NewPipeline 创建 Pipeline，得分不低于 threshold 时判定为垃圾邮件
+func NewPipeline(threshold float64, checkers ...Checker) *Pipeline {
+	return &Pipeline{checkers: checkers, threshold: threshold}
+}
+
+// Add 追加 Checker，需在开始评分前调用
+func (p *Pipeline) Add(c Checker) {
+	p.checkers = append(p.checkers, c)
+}
+
+// Run 执行评分，单个 Checker 失败时跳过并记录日志，不影响其他规则
+func (p *Pipeline) Run(ctx context.Context, in *Input) *Report {
+	report := &Report{Threshold: p.threshold, Hits: []*Hit{}}
+	var score float64
+	for _, c := range p.checkers {
+		hits, err := c.Check(ctx, in)
+		if err != nil {
+			logger.WarnfCtx(ctx, "spam checker %s for user %s error: %v", c.Name(), in.UserID, err)
+			continue
+		}
+		for _, h := range hits {
+			score += h.Score
+			report.Hits = append(report.Hits, h)
+		}
+	}
+	report.Score = math.Round(score*100) / 100
+	report.Spam = report.Score >= p.threshold
+	return report
+}
@@ -0,0 +1,123 @@
+package spam
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	datamodel "plaud-emails/data/model"
+	appconfig "plaud-emails/pkg/config"
+	"plaud-emails/service/message"
+	"plaud-emails/service/mimeparse"
+)
+
+// stubChecker 返回固定的规则或错误
+type stubChecker struct {
+	hits []*Hit
+	err  error
+}
+
+func (c *stubChecker) Name() string { return "stub" }
+
+func (c *stubChecker) Check(context.Context, *Input) ([]*Hit, error) { return c.hits, c.err }
+
+func TestPipelineThreshold(t *testing.T) {
+	broken := &stubChecker{err: errors.New("dns timeout")}
+	cases := []struct {
+		name   string
+		scores []float64
+		spam   bool
+		score  float64
+	}{
+		{"below threshold", []float64{2, 2.99}, false, 4.99},
+		{"at threshold", []float64{3, 2}, true, 5},
+		{"negative rules offset", []float64{6, -1.5}, false, 4.5},
+		{"rounded to cents", []float64{0.1, 0.2}, false, 0.3},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var hits []*Hit
+			for i, s := range tc.scores {
+				hits = append(hits, &Hit{Rule: string(rune('A' + i)), Score: s})
+			}
+			report := NewPipeline(5, broken, &stubChecker{hits: hits}).Run(context.Background(), &Input{UserID: "u1"})
+			if report.Spam != tc.spam || report.Score != tc.score || report.Threshold != 5 {
+				t.Fatalf("report = %+v, want spam=%v score=%v", report, tc.spam, tc.score)
+			}
+			if len(report.Hits) != len(tc.scores) {
+				t.Fatalf("a failing checker should be skipped without dropping other hits: %v", rules(report.Hits))
+			}
+		})
+	}
+}
+
+func newTestSpamService(t *testing.T) *SpamService {
+	t.Helper()
+	s := New(nil, nil, &appconfig.SpamConfig{
+		Enabled:          true,
+		Threshold:        5,
+		TimeoutSeconds:   5,
+		URLBlocklist:     []string{"prize.example"},
+		BayesMinMessages: 10,
+	}, bankResolver())
+	corpus := newFakeCorpus()
+	s.bayes.tokenDao, s.bayes.statsDao = corpus, corpus
+	if err := s.Init(context.Background()); err != nil {
+		t.Fatalf("init: %v", err)
+	}
+	return s
+}
+
+func TestSpamServiceScore(t *testing.T) {
+	s := newTestSpamService(t)
+	ctx := context.Background()
+	score := func(source, ip, raw string) *message.SpamVerdict {
+		t.Helper()
+		parsed, _ := mimeparse.Parse([]byte(raw))
+		verdict, err := s.Score(ctx, &message.StoreInput{
+			UserID:       "u1",
+			Source:       source,
+			RemoteIP:     ip,
+			Helo:         "mail.bank.example",
+			EnvelopeFrom: "bounce@bank.example",
+			Raw:          []byte(raw),
+		}, parsed)
+		if err != nil {
+			t.Fatalf("score: %v", err)
+		}
+		return verdict
+	}
+
+	legit := "From: Bank <notice@bank.example>\r\nTo: a@myplaud.com\r\nSubject: Statement\r\n" +
+		"Date: Sun, 1 Mar 2026 10:00:00 +0000\r\nMessage-ID: <s1@bank.example>\r\n\r\nYour statement is ready.\r\n"
+	verdict := score(datamodel.MessageSourceSMTP, "192.0.2.10", legit)
+	if verdict == nil || verdict.Spam || verdict.Score >= 0 {
+		t.Fatalf("authenticated mail should not be spam: %+v", verdict)
+	}
+
+	phish := "From: Bank <notice@bank.example>\r\nTo: a@myplaud.com\r\nSubject: Verify\r\n" +
+		"Date: Sun, 1 Mar 2026 10:00:00 +0000\r\nMessage-ID: <p1@bank.example>\r\n\r\nClaim at https://win.prize.example/now\r\n"
+	verdict = score(datamodel.MessageSourceSMTP, "203.0.113.9", phish)
+	if verdict == nil || !verdict.Spam || verdict.Score < 5 {
+		t.Fatalf("spoofed mail with blocklisted link should be spam: %+v", verdict)
+	}
+	report := ParseReport(&datamodel.Message{SpamReport: verdict.Report})
+	if report == nil || report.Score != verdict.Score {
+		t.Fatalf("report should round-trip: %+v", report)
+	}
+	if got := rules(report.Hits); !slices.Contains(got, "DMARC_REJECT") || !slices.Contains(got, "URL_BLOCKLISTED") {
+		t.Fatalf("report rules = %v", got)
+	}
+
+	// 外部邮箱同步的邮件已经过原邮箱的过滤，不评分
+	if verdict := score(datamodel.MessageSourceIMAP, "", phish); verdict != nil {
+		t.Fatalf("synced mail should not be scored: %+v", verdict)
+	}
+}
+
+func TestParseReportInvalid(t *testing.T) {
+	if ParseReport(&datamodel.Message{}) != nil || ParseReport(&datamodel.Message{SpamReport: "{"}) != nil {
+		t.Fatal("missing or malformed reports should parse as nil")
+	}
+}
@@ -0,0 +1,311 @@
+package spam
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"plaud-emails/dao"
+	datamodel "plaud-emails/data/model"
+	appconfig "plaud-emails/pkg/config"
+	"plaud-emails/service/mailauth"
+	"plaud-emails/service/message"
+	"plaud-emails/service/mimeparse"
+
+	"github.com/Plaud-AI/plaud-go-scaffold/pkg/logger"
+	"github.com/Plaud-AI/plaud-go-scaffold/pkg/svc"
+	"github.com/Plaud-AI/plaud-library-go/observability/core"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"gorm.io/gorm"
+)
+
+// trainQueueSize 等待训练的文件夹变更数，队列满时丢弃新的训练请求
+const trainQueueSize = 1000
+
+// trainJob 用户将一批邮件移入垃圾邮件或移出时的训练任务
+type trainJob struct {
+	userID string
+	ids    []uint64
+	class  int8
+}
+
+// SpamService 收信垃圾邮件评分服务
+// 入库前依次执行各评分规则，得分达到阈值的邮件放入 spam 文件夹；用户移动邮件时在后台训练其贝叶斯分类器
+type SpamService struct {
+	svc.BaseService
+	tokenDao *dao.SpamTokenDao
+	statsDao *dao.SpamStatsDao
+	messages *message.MessageService
+	conf     *appconfig.SpamConfig
+	resolver mailauth.Resolver
+	bayes    *BayesChecker
+	extra    []Checker
+	pipeline *Pipeline
+	queue    chan *trainJob
+
+	scored  metric.Int64Counter
+	trained metric.Int64Counter
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// New 创建 SpamService，resolver 为 nil 时使用系统 DNS；需在 observability 初始化之后调用，否则指标不会上报
+func New(db *gorm.DB, messages *message.MessageService, conf *appconfig.SpamConfig, resolver mailauth.Resolver) *SpamService {
+	s := &SpamService{
+		tokenDao: dao.NewSpamTokenDao(db),
+		statsDao: dao.NewSpamStatsDao(db),
+		messages: messages,
+		conf:     conf,
+		resolver: resolver,
+		bayes:    NewBayesChecker(db, conf.BayesMinMessages),
+		queue:    make(chan *trainJob, trainQueueSize),
+	}
+
+	meter := core.GetMeter("plaud-emails/spam")
+	var err error
+	if s.scored, err = meter.Int64Counter("mailbox.spam.scored",
+		metric.WithDescription("Inbound messages scored by the spam pipeline"), metric.WithUnit("{message}")); err != nil {
+		logger.Warnf("create spam metric error: %v", err)
+	}
+	if s.trained, err = meter.Int64Counter("mailbox.spam.trained",
+		metric.WithDescription("Messages trained into per-user Bayesian classifiers"), metric.WithUnit("{message}")); err != nil {
+		logger.Warnf("create spam metric error: %v", err)
+	}
+	return s
+}
+
+// AddChecker 追加评分规则，需在服务初始化前调用
+func (s *SpamService) AddChecker(c Checker) {
+	s.extra = append(s.extra, c)
+}
+
+// Score 实现 message.SpamScorer，只对 SMTP 收到的邮件评分，外部邮箱同步的邮件已经过原邮箱的过滤
+func (s *SpamService) Score(ctx context.Context, in *message.StoreInput, parsed *mimeparse.Message) (*message.SpamVerdict, error) {
+	if !s.conf.Enabled || s.pipeline == nil || in.Source != datamodel.MessageSourceSMTP {
+		return nil, nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(s.conf.TimeoutSeconds)*time.Second)
+	defer cancel()
+
+	report := s.pipeline.Run(ctx, &Input{
+		UserID:   in.UserID,
+		Source:   in.Source,
+		RemoteIP: net.ParseIP(in.RemoteIP),
+		Helo:     in.Helo,
+		MailFrom: in.EnvelopeFrom,
+		Raw:      in.Raw,
+		Parsed:   parsed,
+		Auth:     in.Auth,
+	})
+	data, err := json.Marshal(report)
+	if err != nil {
+		return nil, err
+	}
+	s.scored.Add(ctx, 1, metric.WithAttributes(attribute.Bool("spam", report.Spam)))
+	if report.Spam {
+		logger.InfofCtx(ctx, "inbound message from <%s> for user %s scored %.2f as spam", in.EnvelopeFrom, in.UserID, report.Score)
+	}
+	return &message.SpamVerdict{Score: report.Score, Spam: report.Spam, Report: string(data)}, nil
+}
+
+// ParseReport 解析邮件记录中保存的评分报告，没有评分时返回 nil
+func ParseReport(msg *datamodel.Message) *Report {
+	if msg.SpamReport == "" {
+		return nil
+	}
+	var report Report
+	if err := json.Unmarshal([]byte(msg.SpamReport), &report); err != nil {
+		return nil
+	}
+	return &report
+}
+
+// OnFolderChanged 实现 message.FolderListener：移入 spam 时训练为垃圾邮件，移入收件箱或归档时训练为正常邮件
+// 训练需要读取原始邮件，在后台执行
+func (s *SpamService) OnFolderChanged(ctx context.Context, userID string, ids []uint64, folder string) {
+	var class int8
+	switch folder {
+	case datamodel.FolderSpam:
+		class = datamodel.SpamTrainedSpam
+	case datamodel.FolderInbox, datamodel.FolderArchive:
+		class = datamodel.SpamTrainedHam
+	default:
+		return
+	}
+	select {
+	case s.queue <- &trainJob{userID: userID, ids: ids, class: class}:
+	default:
+		logger.WarnfCtx(ctx, "spam train queue is full, skip %d messages of user %s", len(ids), userID)
+	}
+}
+
+// Train 将用户的一封邮件训练为 class，邮件已训练为其他分类时先撤销原来的训练
+func (s *SpamService) Train(ctx context.Context, userID string, id uint64, class int8) error {
+	msg, err := s.messages.Get(ctx, userID, id)
+	if err != nil {
+		if errors.Is(err, message.ErrMessageNotFound) {
+			return nil
+		}
+		return err
+	}
+	if !msg.IsActive() || msg.SpamTrained == class || msg.Source == datamodel.MessageSourceSystem {
+		return nil
+	}
+	parsed, err := s.messages.Parse(ctx, msg)
+	if err != nil {
+		return err
+	}
+	hashes := Tokenize(parsed)
+	// 固定加锁顺序，避免同一用户并发训练时死锁
+	slices.Sort(hashes)
+
+	var spamDelta, hamDelta int64
+	switch class {
+	case datamodel.SpamTrainedSpam:
+		spamDelta = 1
+	case datamodel.SpamTrainedHam:
+		hamDelta = 1
+	}
+	switch msg.SpamTrained {
+	case datamodel.SpamTrainedSpam:
+		spamDelta = -1
+	case datamodel.SpamTrainedHam:
+		hamDelta = -1
+	}
+
+	err = s.statsDao.ExecTx(ctx, func(tx *gorm.DB) error {
+		// 条件更新抢占，邮件在读取后被再次移动时放弃本次训练
+		ok, err := dao.NewMessageDao(tx).SetSpamTrained(ctx, msg.ID, msg.SpamTrained, class)
+		if err != nil || !ok {
+			return err
+		}
+		if err := dao.NewSpamTokenDao(tx).Add(ctx, userID, hashes, spamDelta, hamDelta); err != nil {
+			return err
+		}
+		return dao.NewSpamStatsDao(tx).Add(ctx, userID, spamDelta, hamDelta)
+	})
+	if err != nil {
+		logger.ErrorfCtx(ctx, "train spam classifier with message %d error: %v", msg.ID, err)
+		return err
+	}
+	s.trained.Add(ctx, 1, metric.WithAttributes(attribute.Bool("spam", class == datamodel.SpamTrainedSpam)))
+	return nil
+}
+
+// PurgeUser 删除用户的贝叶斯分类器数据，实现 mindadvisor.MailboxPurger
+func (s *SpamService) PurgeUser(ctx context.Context, userID string) error {
+	if err := s.tokenDao.DeleteByUserID(ctx, userID); err != nil {
+		logger.ErrorfCtx(ctx, "delete purged spam tokens error: %v", err)
+		return err
+	}
+	if err := s.statsDao.DeleteByUserID(ctx, userID); err != nil {
+		logger.ErrorfCtx(ctx, "delete purged spam stats error: %v", err)
+		return err
+	}
+	return nil
+}
+
+// trainLoop 依次处理训练任务，单封邮件失败时跳过
+func (s *SpamService) trainLoop(ctx context.Context) {
+	defer s.wg.Done()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case job := <-s.queue:
+			for _, id := range job.ids {
+				if ctx.Err() != nil {
+					return
+				}
+				_ = s.Train(ctx, job.userID, id, job.class)
+			}
+		}
+	}
+}
+
+// loadBlocklist 合并配置中的域名与黑名单文件中的域名
+func loadBlocklist(conf *appconfig.SpamConfig) ([]string, error) {
+	domains := append([]string{}, conf.URLBlocklist...)
+	if conf.URLBlocklistFile == "" {
+		return domains, nil
+	}
+	f, err := os.Open(conf.URLBlocklistFile)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		domains = append(domains, line)
+	}
+	return domains, scanner.Err()
+}
+
+// Init 初始化服务，加载 URL 黑名单并组装评分规则
+func (s *SpamService) Init(ctx context.Context) error {
+	if s.IsInited() {
+		return nil
+	}
+	blocklist, err := loadBlocklist(s.conf)
+	if err != nil {
+		logger.Errorf("load spam url blocklist %s error: %v", s.conf.URLBlocklistFile, err)
+		return err
+	}
+	s.pipeline = NewPipeline(s.conf.Threshold,
+		NewAuthChecker(s.resolver),
+		NewHeaderChecker(),
+		NewURLChecker(blocklist),
+		s.bayes,
+	)
+	for _, c := range s.extra {
+		s.pipeline.Add(c)
+	}
+	if !s.conf.Enabled {
+		logger.Warnf("spam scoring disabled, inbound messages will not be filtered")
+	}
+	s.SetInited(true)
+	return nil
+}
+
+// Start 启动服务
+func (s *SpamService) Start(ctx context.Context) error {
+	if s.IsStarted() {
+		return nil
+	}
+	runCtx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+	s.wg.Add(1)
+	go s.trainLoop(runCtx)
+
+	logger.Infof("start spam service")
+	s.SetStarted(true)
+	return nil
+}
+
+// Stop 停止服务，队列中尚未训练的邮件被丢弃
+func (s *SpamService) Stop(ctx context.Context) error {
+	if s.IsStopped() {
+		return nil
+	}
+	defer s.SetStopped(true)
+	if s.cancel != nil {
+		s.cancel()
+		s.wg.Wait()
+	}
+	logger.Infof("stop spam service")
+	return nil
+}
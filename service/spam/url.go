@@ -0,0 +1,186 @@
+package spam
+
+import (
+	"context"
+	"net"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"plaud-emails/service/mailauth"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// maxURLs 每封邮件最多检查的 URL 数
+const maxURLs = 200
+
+var (
+	// textURLRe 纯文本正文中的 URL
+	textURLRe = regexp.MustCompile(`(?i)https?://[^\s<>"'()\[\]]+`)
+	// linkTextRe 链接文字中显示的网址或域名
+	linkTextRe = regexp.MustCompile(`(?i)^(?:https?://)?((?:[a-z0-9-]+\.)+[a-z]{2,})(?:[/:?#]\S*)?$`)
+)
+
+// link 邮件中的一个链接，Text 为 HTML 链接显示的文字，纯文本中的 URL 为空
+type link struct {
+	URL  *url.URL
+	Text string
+}
+
+// URLChecker 根据本地域名黑名单与链接特征检查邮件中的 URL
+type URLChecker struct {
+	blocklist map[string]bool
+}
+
+// NewURLChecker 创建 URLChecker，黑名单中的域名同时匹配其子域名
+func NewURLChecker(blocklist []string) *URLChecker {
+	c := &URLChecker{blocklist: make(map[string]bool, len(blocklist))}
+	for _, d := range blocklist {
+		if d = normalizeHost(d); d != "" {
+			c.blocklist[d] = true
+		}
+	}
+	return c
+}
+
+// Name 实现 Checker
+func (c *URLChecker) Name() string { return "url" }
+
+// Check 实现 Checker，每条规则只计分一次
+func (c *URLChecker) Check(ctx context.Context, in *Input) ([]*Hit, error) {
+	if in.Parsed == nil {
+		return nil, nil
+	}
+
+	hits := make(map[string]*Hit)
+	var order []string
+	add := func(h *Hit) {
+		if hits[h.Rule] == nil {
+			hits[h.Rule] = h
+			order = append(order, h.Rule)
+		}
+	}
+	for _, l := range extractLinks(in.Parsed.Text, in.Parsed.HTML) {
+		host := normalizeHost(l.URL.Hostname())
+		if host == "" {
+			continue
+		}
+		if listed := c.blocked(host); listed != "" {
+			add(&Hit{Rule: "URL_BLOCKLISTED", Score: 5, Description: "link to blocklisted domain " + listed})
+		}
+		if net.ParseIP(host) != nil {
+			add(&Hit{Rule: "URL_IP_HOST", Score: 1.5, Description: "link to numeric IP address " + host})
+		}
+		if l.URL.User != nil {
+			add(&Hit{Rule: "URL_USERINFO", Score: 2, Description: "link with user info before host " + host})
+		}
+		if strings.HasPrefix(host, "xn--") || strings.Contains(host, ".xn--") {
+			add(&Hit{Rule: "URL_PUNYCODE", Score: 1, Description: "link to internationalized domain " + host})
+		}
+		if shown := shownHost(l.Text); shown != "" &&
+			mailauth.OrganizationalDomain(shown) != mailauth.OrganizationalDomain(host) {
+			add(&Hit{Rule: "URL_TEXT_MISMATCH", Score: 2.5, Description: "link text shows " + shown + " but points to " + host})
+		}
+	}
+
+	result := make([]*Hit, 0, len(order))
+	for _, rule := range order {
+		result = append(result, hits[rule])
+	}
+	return result, nil
+}
+
+// blocked 返回命中的黑名单域名，依次检查主机名及其上级域名
+func (c *URLChecker) blocked(host string) string {
+	for d := host; d != ""; {
+		if c.blocklist[d] {
+			return d
+		}
+		i := strings.IndexByte(d, '.')
+		if i < 0 {
+			break
+		}
+		d = d[i+1:]
+	}
+	return ""
+}
+
+// extractLinks 提取纯文本与 HTML 正文中的链接，最多 maxURLs 个
+func extractLinks(text, htmlBody string) []*link {
+	var links []*link
+	add := func(raw, shown string) bool {
+		u, err := url.Parse(strings.TrimSpace(raw))
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			return true
+		}
+		links = append(links, &link{URL: u, Text: strings.TrimSpace(shown)})
+		return len(links) < maxURLs
+	}
+
+	if htmlBody != "" {
+		z := html.NewTokenizer(strings.NewReader(htmlBody))
+		var href string
+		var shown strings.Builder
+		inLink := false
+	loop:
+		for {
+			tt := z.Next()
+			switch tt {
+			case html.ErrorToken:
+				break loop
+			case html.StartTagToken:
+				tok := z.Token()
+				if tok.DataAtom != atom.A {
+					continue
+				}
+				if inLink && !add(href, shown.String()) {
+					return links
+				}
+				href, inLink = "", true
+				shown.Reset()
+				for _, attr := range tok.Attr {
+					if attr.Key == "href" {
+						href = attr.Val
+					}
+				}
+			case html.EndTagToken:
+				if tok := z.Token(); tok.DataAtom == atom.A && inLink {
+					inLink = false
+					if !add(href, shown.String()) {
+						return links
+					}
+				}
+			case html.TextToken:
+				if inLink {
+					shown.Write(z.Text())
+				}
+			}
+		}
+		if inLink && !add(href, shown.String()) {
+			return links
+		}
+	}
+
+	for _, raw := range textURLRe.FindAllString(text, -1) {
+		if !add(strings.TrimRight(raw, ".,;:!?"), "") {
+			break
+		}
+	}
+	return links
+}
+
+// shownHost 链接文字本身是网址或域名时返回其主机名
+func shownHost(text string) string {
+	m := linkTextRe.FindStringSubmatch(strings.TrimSpace(text))
+	if m == nil {
+		return ""
+	}
+	return normalizeHost(m[1])
+}
+
+// normalizeHost 小写并去掉末尾的点
+func normalizeHost(host string) string {
+	return strings.TrimSuffix(strings.ToLower(strings.TrimSpace(host)), ".")
+}
@@ -0,0 +1,78 @@
+package spam
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+
+	"plaud-emails/service/mimeparse"
+)
+
+func TestURLCheckerRules(t *testing.T) {
+	c := NewURLChecker([]string{"Bad.Example.", "  "})
+	cases := []struct {
+		name       string
+		text, html string
+		want       []string
+	}{
+		{
+			name: "clean links",
+			text: "Docs at https://docs.example.com/start.",
+			html: `<a href="https://www.example.com/a">example.com</a>`,
+		},
+		{
+			name: "blocklisted subdomain",
+			text: "Claim at https://win.bad.example/prize",
+			want: []string{"URL_BLOCKLISTED"},
+		},
+		{
+			name: "numeric host and user info",
+			text: "http://192.0.2.7/login and https://paypal.com@login.example/verify",
+			want: []string{"URL_IP_HOST", "URL_USERINFO"},
+		},
+		{
+			name: "punycode host",
+			html: `<a href="https://xn--pypal-4ve.com/">click</a>`,
+			want: []string{"URL_PUNYCODE"},
+		},
+		{
+			name: "link text shows another domain",
+			html: `<p><a href="https://collect.evil.example/x">https://www.mybank.com/login</a></p>` +
+				`<a href="https://mail.example.com/">www.example.com</a>`,
+			want: []string{"URL_TEXT_MISMATCH"},
+		},
+		{
+			name: "each rule scores once",
+			text: "https://a.bad.example https://b.bad.example https://bad.example",
+			want: []string{"URL_BLOCKLISTED"},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			hits, err := c.Check(context.Background(), &Input{Parsed: &mimeparse.Message{Text: tc.text, HTML: tc.html}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := rules(hits); !slices.Equal(got, tc.want) {
+				t.Fatalf("rules = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestExtractLinksLimit(t *testing.T) {
+	var b strings.Builder
+	for i := 0; i < maxURLs+50; i++ {
+		fmt.Fprintf(&b, "https://host%d.example/ ", i)
+	}
+	if got := len(extractLinks(b.String(), "")); got != maxURLs {
+		t.Fatalf("extracted %d links, want %d", got, maxURLs)
+	}
+
+	links := extractLinks("", `<a href="javascript:alert(1)">x</a><a href="mailto:a@b.c">y</a><a href="https://ok.example">z</a>`)
+	if len(links) != 1 || links[0].URL.Host != "ok.example" || links[0].Text != "z" {
+		t.Fatalf("only http links should be kept: %+v", links)
+	}
+}
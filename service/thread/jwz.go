@@ -0,0 +1,280 @@
+package thread
+
+import (
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Node 参与 JWZ 算法的一封邮件
+type Node struct {
+	// ID 邮件记录 id
+	ID         uint64
+	MessageID  string
+	InReplyTo  string
+	References []string
+	Subject    string
+	Date       time.Time
+}
+
+// Container JWZ 算法中的容器，Node 为 nil 表示被引用但不在集合中的邮件
+type Container struct {
+	Node     *Node
+	Parent   *Container
+	Children []*Container
+}
+
+// Entry 展开后的会话树节点
+type Entry struct {
+	Node *Node
+	// ParentID 最近的非空祖先邮件 id，根节点为 0
+	ParentID uint64
+	Depth    int
+}
+
+// Build 按 JWZ 算法（https://www.jwz.org/doc/threading.html）构建会话树，返回按时间排序的根集合
+func Build(nodes []*Node) []*Container {
+	idTable := make(map[string]*Container, len(nodes))
+	// 按创建顺序记录容器，保证结果与 map 遍历顺序无关
+	var all []*Container
+	get := func(id string) *Container {
+		c, ok := idTable[id]
+		if !ok {
+			c = &Container{}
+			idTable[id] = c
+			all = append(all, c)
+		}
+		return c
+	}
+
+	// 1. 根据 References 与 In-Reply-To 建立父子关系
+	for _, n := range nodes {
+		id := n.MessageID
+		if id == "" || (idTable[id] != nil && idTable[id].Node != nil) {
+			// 缺失或重复的 Message-ID 使用不会与真实 msg-id 冲突的占位 id
+			id = "\x00" + strconv.FormatUint(n.ID, 10)
+		}
+		c := get(id)
+		c.Node = n
+
+		var prev *Container
+		for _, ref := range referencesOf(n) {
+			if ref == id {
+				continue
+			}
+			rc := get(ref)
+			if prev != nil && rc.Parent == nil && !reachable(rc, prev) {
+				link(prev, rc)
+			}
+			prev = rc
+		}
+		// 最后一个引用是直接父邮件，以邮件自身的 References 为准
+		if prev != nil && !reachable(c, prev) {
+			unlink(c)
+			link(prev, c)
+		} else if prev == nil && c.Parent != nil {
+			unlink(c)
+		}
+	}
+
+	// 2. 找出根集合
+	var roots []*Container
+	for _, c := range all {
+		if c.Parent == nil {
+			roots = append(roots, c)
+		}
+	}
+
+	// 3. 删除空容器
+	roots = prune(roots, true)
+
+	// 4. 按主题合并根集合
+	roots = groupBySubject(roots)
+
+	// 5. 按时间排序
+	sortContainers(roots)
+	return roots
+}
+
+// Flatten 深度优先展开会话树
+func Flatten(roots []*Container) []*Entry {
+	var entries []*Entry
+	var walk func(c *Container, parentID uint64, depth int)
+	walk = func(c *Container, parentID uint64, depth int) {
+		if c.Node != nil {
+			entries = append(entries, &Entry{Node: c.Node, ParentID: parentID, Depth: depth})
+			parentID = c.Node.ID
+			depth++
+		}
+		for _, child := range c.Children {
+			walk(child, parentID, depth)
+		}
+	}
+	for _, root := range roots {
+		walk(root, 0, 0)
+	}
+	return entries
+}
+
+// referencesOf 返回邮件的引用链，In-Reply-To 不在 References 末尾时追加
+func referencesOf(n *Node) []string {
+	refs := n.References
+	if n.InReplyTo != "" && (len(refs) == 0 || refs[len(refs)-1] != n.InReplyTo) {
+		refs = append(append([]string{}, refs...), n.InReplyTo)
+	}
+	return refs
+}
+
+// reachable 判断 to 是否为 from 本身或 from 的后代，用于避免成环
+func reachable(from, to *Container) bool {
+	if from == to {
+		return true
+	}
+	for _, child := range from.Children {
+		if reachable(child, to) {
+			return true
+		}
+	}
+	return false
+}
+
+func link(parent, child *Container) {
+	child.Parent = parent
+	parent.Children = append(parent.Children, child)
+}
+
+func unlink(c *Container) {
+	if c.Parent == nil {
+		return
+	}
+	siblings := c.Parent.Children
+	for i, s := range siblings {
+		if s == c {
+			c.Parent.Children = append(siblings[:i:i], siblings[i+1:]...)
+			break
+		}
+	}
+	c.Parent = nil
+}
+
+// prune 删除没有邮件的容器：没有子节点的直接删除，其余的由子节点顶替
+// 根集合中有多个子节点的空容器保留，表示若干回复共同引用了一封缺失的邮件
+func prune(list []*Container, root bool) []*Container {
+	out := make([]*Container, 0, len(list))
+	for _, c := range list {
+		c.Children = prune(c.Children, false)
+		if c.Node != nil {
+			out = append(out, c)
+			continue
+		}
+		switch {
+		case len(c.Children) == 0:
+		case root && len(c.Children) > 1:
+			out = append(out, c)
+		default:
+			for _, child := range c.Children {
+				child.Parent = c.Parent
+				out = append(out, child)
+			}
+			c.Children = nil
+		}
+	}
+	return out
+}
+
+// groupBySubject 合并根集合中主题相同的会话，处理不带 References 的客户端
+func groupBySubject(roots []*Container) []*Container {
+	table := make(map[string]*Container, len(roots))
+	for _, c := range roots {
+		key, reply := subjectOf(c)
+		if key == "" {
+			continue
+		}
+		old, ok := table[key]
+		if !ok {
+			table[key] = c
+			continue
+		}
+		// 优先保留空容器，其次保留非回复的邮件
+		_, oldReply := subjectOf(old)
+		if (c.Node == nil && old.Node != nil) || (old.Node != nil && oldReply && !reply) {
+			table[key] = c
+		}
+	}
+
+	out := make([]*Container, 0, len(roots))
+	for _, c := range roots {
+		if c.Parent != nil {
+			// 已被放入合并用的空容器
+			continue
+		}
+		key, reply := subjectOf(c)
+		target := table[key]
+		if key == "" || target == c {
+			out = append(out, c)
+			continue
+		}
+		_, targetReply := subjectOf(target)
+		switch {
+		case target.Node == nil && c.Node == nil:
+			// 两个空容器：合并子节点
+			for _, child := range c.Children {
+				child.Parent = target
+			}
+			target.Children = append(target.Children, c.Children...)
+			c.Children = nil
+		case target.Node == nil:
+			link(target, c)
+		case !targetReply && reply:
+			// 回复归入原邮件
+			link(target, c)
+		default:
+			// 主题相同的两封原邮件或两封回复：放在同一个空容器下
+			holder := &Container{Node: nil}
+			link(holder, target)
+			link(holder, c)
+			table[key] = holder
+			replaced := false
+			for i, r := range out {
+				if r == target {
+					out[i], replaced = holder, true
+				}
+			}
+			if !replaced {
+				out = append(out, holder)
+			}
+		}
+	}
+	return out
+}
+
+// subjectOf 返回会话的主题键，空容器取第一个子节点的主题
+func subjectOf(c *Container) (string, bool) {
+	for c.Node == nil {
+		if len(c.Children) == 0 {
+			return "", false
+		}
+		c = c.Children[0]
+	}
+	return SubjectKey(c.Node.Subject)
+}
+
+// sortContainers 递归按时间排序，空容器以最早的子节点时间排序
+func sortContainers(list []*Container) {
+	for _, c := range list {
+		sortContainers(c.Children)
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		return dateOf(list[i]).Before(dateOf(list[j]))
+	})
+}
+
+func dateOf(c *Container) time.Time {
+	for c.Node == nil {
+		if len(c.Children) == 0 {
+			return time.Time{}
+		}
+		c = c.Children[0]
+	}
+	return c.Node.Date
+}
@@ -0,0 +1,139 @@
+package thread
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+var base = time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+
+// node 构造测试邮件，id 同时决定 Message-ID 与时间顺序
+func node(id uint64, subject string, refs ...string) *Node {
+	return &Node{
+		ID:         id,
+		MessageID:  fmt.Sprintf("m%d@example.com", id),
+		References: refs,
+		Subject:    subject,
+		Date:       base.Add(time.Duration(id) * time.Minute),
+	}
+}
+
+func ref(id uint64) string {
+	return fmt.Sprintf("m%d@example.com", id)
+}
+
+// shape 将会话树格式化为 "id(parent)@depth" 列表，空容器下的根邮件 parent 为 0
+func shape(roots []*Container) string {
+	var parts []string
+	for _, e := range Flatten(roots) {
+		parts = append(parts, fmt.Sprintf("%d(%d)@%d", e.Node.ID, e.ParentID, e.Depth))
+	}
+	return strings.Join(parts, " ")
+}
+
+func TestBuild(t *testing.T) {
+	cases := []struct {
+		name  string
+		nodes []*Node
+		roots int
+		want  string
+	}{
+		{
+			name:  "reference chain",
+			nodes: []*Node{node(3, "Re: plan", ref(1), ref(2)), node(1, "plan"), node(2, "Re: plan", ref(1))},
+			roots: 1,
+			want:  "1(0)@0 2(1)@1 3(2)@2",
+		},
+		{
+			name:  "in-reply-to only",
+			nodes: []*Node{node(1, "plan"), {ID: 2, MessageID: ref(2), InReplyTo: ref(1), Subject: "x", Date: base.Add(2 * time.Minute)}},
+			roots: 1,
+			want:  "1(0)@0 2(1)@1",
+		},
+		{
+			// 缺失的父邮件只有一个回复时由回复顶替
+			name:  "missing parent with one reply",
+			nodes: []*Node{node(2, "Re: plan", ref(1))},
+			roots: 1,
+			want:  "2(0)@0",
+		},
+		{
+			// 多个回复共同引用缺失的邮件时保留空容器作为根
+			name:  "missing parent with two replies",
+			nodes: []*Node{node(2, "Re: a", ref(1)), node(3, "Re: b", ref(1))},
+			roots: 1,
+			want:  "2(0)@0 3(0)@0",
+		},
+		{
+			// 中间缺失的邮件被删除，子邮件挂到上一级
+			name:  "missing middle message",
+			nodes: []*Node{node(1, "plan"), node(3, "Re: plan", ref(1), ref(2))},
+			roots: 1,
+			want:  "1(0)@0 3(1)@1",
+		},
+		{
+			name:  "reply without references grouped by subject",
+			nodes: []*Node{node(2, "RE: Lunch"), node(1, "Lunch")},
+			roots: 1,
+			want:  "1(0)@0 2(1)@1",
+		},
+		{
+			name:  "same subject originals share a holder",
+			nodes: []*Node{node(1, "Weekly sync"), node(2, "Weekly sync")},
+			roots: 1,
+			want:  "1(0)@0 2(0)@0",
+		},
+		{
+			name:  "unrelated threads sorted by date",
+			nodes: []*Node{node(2, "beta"), node(1, "alpha"), node(3, "Re: alpha", ref(1))},
+			roots: 2,
+			want:  "1(0)@0 3(1)@1 2(0)@0",
+		},
+		{
+			// 重复或缺失的 Message-ID 不会覆盖已有邮件
+			name:  "duplicate and missing message-id",
+			nodes: []*Node{node(1, "a"), {ID: 2, MessageID: ref(1), Subject: "b", Date: base.Add(2 * time.Minute)}, {ID: 3, Subject: "c", Date: base.Add(3 * time.Minute)}},
+			roots: 3,
+			want:  "1(0)@0 2(0)@0 3(0)@0",
+		},
+		{
+			// 互相引用的邮件不会成环，后处理的邮件成环的引用被忽略
+			name:  "reference cycle",
+			nodes: []*Node{node(1, "a", ref(2)), node(2, "b", ref(1))},
+			roots: 1,
+			want:  "2(0)@0 1(2)@1",
+		},
+		{
+			// 邮件自身的 References 决定直接父邮件，覆盖其他邮件引用链推断的关系
+			name:  "own references win",
+			nodes: []*Node{node(4, "Re: x", ref(1), ref(3)), node(1, "x"), node(2, "Re: x", ref(1)), node(3, "Re: x", ref(1), ref(2))},
+			roots: 1,
+			want:  "1(0)@0 2(1)@1 3(2)@2 4(3)@3",
+		},
+		{
+			name:  "self reference ignored",
+			nodes: []*Node{node(1, "a", ref(1))},
+			roots: 1,
+			want:  "1(0)@0",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			roots := Build(tc.nodes)
+			if len(roots) != tc.roots {
+				t.Fatalf("%d roots, want %d: %s", len(roots), tc.roots, shape(roots))
+			}
+			if got := shape(roots); got != tc.want {
+				t.Fatalf("shape = %s, want %s", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestBuildEmpty(t *testing.T) {
+	if roots := Build(nil); len(roots) != 0 {
+		t.Fatalf("roots = %v", roots)
+	}
+}
@@ -0,0 +1,398 @@
+package thread
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"plaud-emails/dao"
+	datamodel "plaud-emails/data/model"
+	"plaud-emails/service/message"
+	"plaud-emails/service/mimeparse"
+
+	"github.com/Plaud-AI/plaud-go-scaffold/pkg/logger"
+	"github.com/Plaud-AI/plaud-go-scaffold/pkg/svc"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// DefaultListLimit 会话列表默认分页大小
+	DefaultListLimit = 20
+	// MaxListLimit 会话列表最大分页大小
+	MaxListLimit = 100
+	// MaxThreadMessages 会话详情最多返回的邮件数，超出时返回最近的邮件
+	MaxThreadMessages = 200
+
+	// subjectWindow 主题回退匹配的时间窗口，超出窗口的同主题邮件视为新会话
+	subjectWindow    = 30 * 24 * time.Hour
+	rebuildBatchSize = 100
+)
+
+// ErrThreadNotFound 会话不存在
+var ErrThreadNotFound = errors.New("thread not found")
+
+// DetailEntry 会话详情中的一封邮件，ParentID 为会话树中的父邮件 id，根邮件为 0
+type DetailEntry struct {
+	Message  *datamodel.Message
+	ParentID uint64
+	Depth    int
+}
+
+// Detail 会话详情
+type Detail struct {
+	Thread  *datamodel.MessageThread
+	Entries []*DetailEntry
+	// Truncated 会话邮件数超过 MaxThreadMessages，只返回了最近的邮件
+	Truncated bool
+}
+
+// ThreadService 邮件会话服务
+// 入库时通过 Message-ID 映射表定位会话，每封邮件只需一次索引查询，不会重新计算整个邮箱的会话
+type ThreadService struct {
+	svc.BaseService
+	threadDao  *dao.MessageThreadDao
+	refDao     *dao.MessageThreadRefDao
+	messageDao *dao.MessageDao
+	messages   *message.MessageService
+}
+
+// New 创建 ThreadService
+func New(db *gorm.DB, messages *message.MessageService) *ThreadService {
+	return &ThreadService{
+		threadDao:  dao.NewMessageThreadDao(db),
+		refDao:     dao.NewMessageThreadRefDao(db),
+		messageDao: dao.NewMessageDao(db),
+		messages:   messages,
+	}
+}
+
+// OnMessageStored 实现 message.StoredListener，为新邮件分配会话
+func (s *ThreadService) OnMessageStored(ctx context.Context, msg *datamodel.Message, parsed *mimeparse.Message) {
+	if err := s.Assign(ctx, msg); err != nil {
+		// 未分配会话的邮件可通过重建接口补齐
+		logger.ErrorfCtx(ctx, "assign thread of message %d error: %v", msg.ID, err)
+	}
+}
+
+// OnMessageRemoved 实现 message.RemovedListener，更新会话的邮件数与最近邮件
+func (s *ThreadService) OnMessageRemoved(ctx context.Context, msg *datamodel.Message) {
+	if msg.ThreadID == 0 {
+		return
+	}
+	err := s.threadDao.ExecTx(ctx, func(tx *gorm.DB) error {
+		if _, err := dao.NewMindAdvisorUserDao(tx).GetByUserIDForUpdate(ctx, msg.UserID); err != nil {
+			return err
+		}
+		threadDao := dao.NewMessageThreadDao(tx)
+		thread, err := threadDao.GetByUserIDAndID(ctx, msg.UserID, msg.ThreadID)
+		if err != nil || thread == nil {
+			return err
+		}
+		if err := refreshSummary(ctx, dao.NewMessageDao(tx), thread); err != nil {
+			return err
+		}
+		return threadDao.Update(ctx, thread)
+	})
+	if err != nil {
+		logger.ErrorfCtx(ctx, "update thread %d after message %d removed error: %v", msg.ThreadID, msg.ID, err)
+	}
+}
+
+// PurgeUser 清除用户的全部会话，实现 mindadvisor.MailboxPurger
+func (s *ThreadService) PurgeUser(ctx context.Context, userID string) error {
+	if err := s.refDao.DeleteByUserID(ctx, userID); err != nil {
+		logger.ErrorfCtx(ctx, "delete thread refs of user %s error: %v", userID, err)
+		return err
+	}
+	if err := s.threadDao.DeleteByUserID(ctx, userID); err != nil {
+		logger.ErrorfCtx(ctx, "delete threads of user %s error: %v", userID, err)
+		return err
+	}
+	return nil
+}
+
+// Assign 为邮件分配会话：按 Message-ID、In-Reply-To 与 References 查找已有会话，
+// 找不到时回复邮件按主题回退匹配，仍找不到则新建会话；邮件连接了多个会话时合并为一个
+// 同一用户的分配通过锁定用户记录串行执行
+func (s *ThreadService) Assign(ctx context.Context, msg *datamodel.Message) error {
+	refs := messageRefs(msg)
+	key, reply := SubjectKey(msg.Subject)
+
+	var threadID uint64
+	err := s.threadDao.ExecTx(ctx, func(tx *gorm.DB) error {
+		if _, err := dao.NewMindAdvisorUserDao(tx).GetByUserIDForUpdate(ctx, msg.UserID); err != nil {
+			return err
+		}
+		threadDao := dao.NewMessageThreadDao(tx)
+		refDao := dao.NewMessageThreadRefDao(tx)
+		messageDao := dao.NewMessageDao(tx)
+
+		ids, err := refDao.ListThreadIDs(ctx, msg.UserID, refs)
+		if err != nil {
+			return err
+		}
+		threads, err := threadDao.ListByIDs(ctx, msg.UserID, ids)
+		if err != nil {
+			return err
+		}
+
+		var thread *datamodel.MessageThread
+		if len(threads) > 0 {
+			// 保留最早创建的会话，其余会话并入
+			thread = threads[0]
+			for _, other := range threads[1:] {
+				if err := merge(ctx, tx, thread, other); err != nil {
+					return err
+				}
+			}
+		} else if reply && key != "" {
+			// 不带 References 的客户端回复时按主题回退匹配
+			candidate, err := threadDao.GetLatestBySubjectKey(ctx, msg.UserID, key)
+			if err != nil {
+				return err
+			}
+			if candidate != nil && msg.ReceivedAt.Sub(candidate.LatestAt) <= subjectWindow {
+				thread = candidate
+			}
+		}
+		if thread == nil {
+			thread = &datamodel.MessageThread{
+				UserID:     msg.UserID,
+				Subject:    msg.Subject,
+				SubjectKey: key,
+				LatestAt:   msg.ReceivedAt,
+			}
+			if err := threadDao.Create(ctx, thread); err != nil {
+				return err
+			}
+		}
+
+		if err := refDao.Upsert(ctx, msg.UserID, refs, thread.ID); err != nil {
+			return err
+		}
+		if err := messageDao.SetThreadID(ctx, msg.ID, thread.ID); err != nil {
+			return err
+		}
+
+		thread.MessageCount++
+		thread.Participants.Add(participant(msg.FromAddr))
+		if msg.ID > thread.LatestMessageID {
+			setLatest(thread, msg)
+		}
+		threadID = thread.ID
+		return threadDao.Update(ctx, thread)
+	})
+	if err != nil {
+		return err
+	}
+	msg.ThreadID = threadID
+	return nil
+}
+
+// merge 将会话 other 并入 thread，邮件与映射改为指向 thread 后删除 other
+func merge(ctx context.Context, tx *gorm.DB, thread, other *datamodel.MessageThread) error {
+	if err := dao.NewMessageDao(tx).MoveThread(ctx, thread.UserID, other.ID, thread.ID); err != nil {
+		return err
+	}
+	if err := dao.NewMessageThreadRefDao(tx).MoveThread(ctx, other.ID, thread.ID); err != nil {
+		return err
+	}
+	if err := dao.NewMessageThreadDao(tx).DeleteByID(ctx, other.ID); err != nil {
+		return err
+	}
+
+	thread.MessageCount += other.MessageCount
+	for _, p := range other.Participants {
+		thread.Participants.Add(p)
+	}
+	if other.LatestMessageID > thread.LatestMessageID {
+		thread.LatestMessageID = other.LatestMessageID
+		thread.LatestFrom = other.LatestFrom
+		thread.LatestSnippet = other.LatestSnippet
+		thread.LatestAt = other.LatestAt
+	}
+	logger.InfofCtx(ctx, "thread %d merged into thread %d, user: %s", other.ID, thread.ID, thread.UserID)
+	return nil
+}
+
+// refreshSummary 重新统计会话的邮件数与最近邮件，参与者只增不减
+func refreshSummary(ctx context.Context, messageDao *dao.MessageDao, thread *datamodel.MessageThread) error {
+	count, err := messageDao.CountByThreadID(ctx, thread.UserID, thread.ID)
+	if err != nil {
+		return err
+	}
+	latest, err := messageDao.ListByThreadID(ctx, thread.UserID, thread.ID, 1)
+	if err != nil {
+		return err
+	}
+	thread.MessageCount = int(count)
+	if len(latest) > 0 {
+		setLatest(thread, latest[0])
+	}
+	return nil
+}
+
+func setLatest(thread *datamodel.MessageThread, msg *datamodel.Message) {
+	thread.LatestMessageID = msg.ID
+	thread.LatestFrom = msg.FromAddr
+	thread.LatestSnippet = msg.Snippet
+	thread.LatestAt = msg.ReceivedAt
+}
+
+// messageRefs 返回邮件自身与引用的 Message-ID，去重后保持顺序
+func messageRefs(msg *datamodel.Message) []string {
+	candidates := append([]string{msg.MessageID, msg.InReplyTo}, message.SplitReferences(msg.ReferenceIDs)...)
+	refs := make([]string, 0, len(candidates))
+	seen := make(map[string]bool, len(candidates))
+	for _, ref := range candidates {
+		if ref == "" || seen[ref] {
+			continue
+		}
+		seen[ref] = true
+		refs = append(refs, ref)
+	}
+	return refs
+}
+
+// participant 从 "Name <addr>" 形式的发件人中提取小写地址
+func participant(from string) string {
+	if i := strings.LastIndexByte(from, '<'); i >= 0 {
+		if j := strings.IndexByte(from[i:], '>'); j > 0 {
+			from = from[i+1 : i+j]
+		}
+	}
+	return strings.ToLower(strings.TrimSpace(from))
+}
+
+// List 按最近邮件倒序分页查询用户的会话，返回下一页游标（0 表示没有更多）
+func (s *ThreadService) List(ctx context.Context, userID string, cursor uint64, limit int) ([]*datamodel.MessageThread, uint64, error) {
+	if limit <= 0 {
+		limit = DefaultListLimit
+	}
+	if limit > MaxListLimit {
+		limit = MaxListLimit
+	}
+
+	threads, err := s.threadDao.ListByUserID(ctx, userID, cursor, limit+1)
+	if err != nil {
+		logger.ErrorfCtx(ctx, "list threads error: %v", err)
+		return nil, 0, err
+	}
+
+	var next uint64
+	if len(threads) > limit {
+		threads = threads[:limit]
+		next = threads[limit-1].LatestMessageID
+	}
+	return threads, next, nil
+}
+
+// Get 获取会话详情，邮件按 JWZ 算法排列为会话树，不存在时返回 ErrThreadNotFound
+func (s *ThreadService) Get(ctx context.Context, userID string, id uint64) (*Detail, error) {
+	thread, err := s.threadDao.GetByUserIDAndID(ctx, userID, id)
+	if err != nil {
+		logger.ErrorfCtx(ctx, "get thread error: %v", err)
+		return nil, err
+	}
+	if thread == nil || thread.MessageCount == 0 {
+		return nil, ErrThreadNotFound
+	}
+
+	msgs, err := s.messageDao.ListByThreadID(ctx, userID, id, MaxThreadMessages+1)
+	if err != nil {
+		logger.ErrorfCtx(ctx, "list thread messages error: %v", err)
+		return nil, err
+	}
+	detail := &Detail{Thread: thread}
+	if len(msgs) > MaxThreadMessages {
+		msgs = msgs[:MaxThreadMessages]
+		detail.Truncated = true
+	}
+
+	byID := make(map[uint64]*datamodel.Message, len(msgs))
+	nodes := make([]*Node, 0, len(msgs))
+	for i := len(msgs) - 1; i >= 0; i-- {
+		m := msgs[i]
+		byID[m.ID] = m
+		date := m.ReceivedAt
+		if m.SentAt != nil {
+			date = *m.SentAt
+		}
+		nodes = append(nodes, &Node{
+			ID:         m.ID,
+			MessageID:  m.MessageID,
+			InReplyTo:  m.InReplyTo,
+			References: message.SplitReferences(m.ReferenceIDs),
+			Subject:    m.Subject,
+			Date:       date,
+		})
+	}
+	for _, e := range Flatten(Build(nodes)) {
+		detail.Entries = append(detail.Entries, &DetailEntry{Message: byID[e.Node.ID], ParentID: e.ParentID, Depth: e.Depth})
+	}
+	return detail, nil
+}
+
+// Rebuild 为用户尚未分配会话的邮件补齐会话，按入库顺序处理
+// 缺少引用索引的邮件先读取原始邮件回填，返回处理的邮件数
+func (s *ThreadService) Rebuild(ctx context.Context, userID string) (int, error) {
+	var count int
+	var afterID uint64
+	for {
+		msgs, err := s.messageDao.ListUnthreaded(ctx, userID, afterID, rebuildBatchSize)
+		if err != nil {
+			logger.ErrorfCtx(ctx, "list unthreaded messages error: %v", err)
+			return count, err
+		}
+		for _, msg := range msgs {
+			afterID = msg.ID
+			if msg.InReplyTo == "" && msg.ReferenceIDs == "" {
+				if err := s.messages.FillReferences(ctx, msg); err != nil {
+					// 无法读取原始邮件时按已有索引分配
+					logger.WarnfCtx(ctx, "fill references of message %d error: %v", msg.ID, err)
+				}
+			}
+			if err := s.Assign(ctx, msg); err != nil {
+				logger.ErrorfCtx(ctx, "assign thread of message %d error: %v", msg.ID, err)
+				return count, err
+			}
+			count++
+		}
+		if len(msgs) < rebuildBatchSize {
+			break
+		}
+	}
+	logger.InfofCtx(ctx, "rebuilt threads of %d messages, user: %s", count, userID)
+	return count, nil
+}
+
+// Init 初始化服务
+func (s *ThreadService) Init(ctx context.Context) error {
+	if s.IsInited() {
+		return nil
+	}
+	s.SetInited(true)
+	return nil
+}
+
+// Start 启动服务
+func (s *ThreadService) Start(ctx context.Context) error {
+	if s.IsStarted() {
+		return nil
+	}
+	logger.Infof("start thread service")
+	s.SetStarted(true)
+	return nil
+}
+
+// Stop 停止服务
+func (s *ThreadService) Stop(ctx context.Context) error {
+	if s.IsStopped() {
+		return nil
+	}
+	defer s.SetStopped(true)
+	logger.Infof("stop thread service")
+	return nil
+}
@@ -0,0 +1,32 @@
+package thread
+
+import (
+	"slices"
+	"testing"
+
+	datamodel "plaud-emails/data/model"
+)
+
+func TestMessageRefs(t *testing.T) {
+	msg := &datamodel.Message{MessageID: "c@x", InReplyTo: "b@x", ReferenceIDs: "a@x b@x c@x"}
+	if got := messageRefs(msg); !slices.Equal(got, []string{"c@x", "b@x", "a@x"}) {
+		t.Fatalf("refs = %v", got)
+	}
+	if got := messageRefs(&datamodel.Message{}); len(got) != 0 {
+		t.Fatalf("refs of a message without ids = %v", got)
+	}
+}
+
+func TestParticipant(t *testing.T) {
+	cases := map[string]string{
+		"Alice <Alice@Example.com>":    "alice@example.com",
+		`"Doe, J" <j.doe@example.com>`: "j.doe@example.com",
+		" BOB@example.com ":            "bob@example.com",
+		"broken <":                     "broken <",
+	}
+	for from, want := range cases {
+		if got := participant(from); got != want {
+			t.Errorf("participant(%q) = %q, want %q", from, got, want)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package thread
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"plaud-emails/pkg/textutil"
+)
+
+// subjectKeyMaxLen 主题键的最大长度，与 mind_advisor_threads.subject_key 一致
+const subjectKeyMaxLen = 255
+
+// replyPrefixes 表示回复的主题前缀，包括常见客户端的本地化写法
+var replyPrefixes = map[string]bool{
+	"re": true, "aw": true, "sv": true, "vs": true, "antw": true, "odp": true,
+	"回复": true, "回覆": true, "答复": true,
+}
+
+// forwardPrefixes 表示转发的主题前缀
+var forwardPrefixes = map[string]bool{
+	"fwd": true, "fw": true, "wg": true, "tr": true, "rv": true,
+	"转发": true, "轉寄": true,
+}
+
+// SubjectKey 去掉主题中的 Re:、Fwd:、[list] 等前缀并规范化，返回主题键与是否为回复
+func SubjectKey(subject string) (string, bool) {
+	s := strings.TrimSpace(subject)
+	reply := false
+	for {
+		rest, isReply, ok := trimPrefix(s)
+		if !ok {
+			break
+		}
+		reply = reply || isReply
+		s = rest
+	}
+
+	key := strings.ToLower(strings.Join(strings.Fields(s), " "))
+	return textutil.Truncate(key, subjectKeyMaxLen), reply
+}
+
+// trimPrefix 去掉一个前缀，支持 "Re:"、"Re[2]:"、"回复："与邮件列表的 "[list]"
+func trimPrefix(s string) (rest string, reply bool, ok bool) {
+	if strings.HasPrefix(s, "[") {
+		end := strings.IndexByte(s, ']')
+		if end < 0 {
+			return s, false, false
+		}
+		return strings.TrimSpace(s[end+1:]), false, true
+	}
+
+	colon := strings.IndexAny(s, ":：")
+	if colon <= 0 {
+		return s, false, false
+	}
+	word := strings.ToLower(strings.TrimSpace(s[:colon]))
+	// 去掉 Re[2] 与 Re(2) 形式的回复计数
+	if i := strings.IndexAny(word, "[("); i > 0 && strings.Trim(word[i:], "[]()0123456789") == "" {
+		word = word[:i]
+	}
+	if !replyPrefixes[word] && !forwardPrefixes[word] {
+		return s, false, false
+	}
+	_, size := utf8.DecodeRuneInString(s[colon:])
+	return strings.TrimSpace(s[colon+size:]), replyPrefixes[word], true
+}
@@ -0,0 +1,46 @@
+package thread
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestSubjectKey(t *testing.T) {
+	cases := []struct {
+		subject string
+		key     string
+		reply   bool
+	}{
+		{"Quarterly report", "quarterly report", false},
+		{"  Quarterly   Report ", "quarterly report", false},
+		{"Re: Quarterly report", "quarterly report", true},
+		{"RE:Re: quarterly report", "quarterly report", true},
+		{"Fwd: Quarterly report", "quarterly report", false},
+		{"Fwd: Re: Quarterly report", "quarterly report", true},
+		{"Re[2]: Quarterly report", "quarterly report", true},
+		{"Re(3): Quarterly report", "quarterly report", true},
+		{"[team] Re: [team] Quarterly report", "quarterly report", true},
+		{"AW: Quarterly report", "quarterly report", true},
+		{"回复：季度报告", "季度报告", true},
+		{"转发: 回复: 季度报告", "季度报告", true},
+		// 不认识的前缀与正文中的冒号保留
+		{"Note: Quarterly report", "note: quarterly report", false},
+		{"Re:", "", true},
+		{"[unclosed list", "[unclosed list", false},
+		{"", "", false},
+	}
+	for _, tc := range cases {
+		key, reply := SubjectKey(tc.subject)
+		if key != tc.key || reply != tc.reply {
+			t.Errorf("SubjectKey(%q) = %q, %v, want %q, %v", tc.subject, key, reply, tc.key, tc.reply)
+		}
+	}
+}
+
+func TestSubjectKeyMaxLen(t *testing.T) {
+	key, _ := SubjectKey("Re: a" + strings.Repeat("报", 100))
+	if len(key) > subjectKeyMaxLen || !utf8.ValidString(key) || !strings.HasPrefix(key, "a报") {
+		t.Fatalf("key = %q (%d bytes)", key, len(key))
+	}
+}